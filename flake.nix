@@ -16,7 +16,7 @@
             pname = "crit";
             inherit version;
             src = self;
-            vendorHash = "sha256-n2yA86hAhSipIhQw9HSKubCVT4RrPdau+/Ve7ebrevc=";
+            vendorHash = "sha256-mXbRxJsVPgSdzVLByhE5kh073W8S6M32QcpzWZ6tpC4=";
             # Tests run in dedicated CI jobs (test + e2e); the Nix sandbox's
             # /build TMPDIR cleanup races with the debounced review file writer.
             doCheck = false;
@@ -1,6 +1,61 @@
 (function() {
   'use strict';
 
+  // ===== CSRF =====
+  // Mutating requests carry the token from /api/config; the server refuses
+  // browser requests without it (csrf.go).
+  let csrfToken = '';
+  const nativeFetch = window.fetch.bind(window);
+  function fetch(input, init) {
+    const method = ((init && init.method) || 'GET').toUpperCase();
+    if (!csrfToken || method === 'GET' || method === 'HEAD') return nativeFetch(input, init);
+    const headers = new Headers((init && init.headers) || {});
+    headers.set('X-Crit-CSRF', csrfToken);
+    return nativeFetch(input, Object.assign({}, init, { headers: headers }));
+  }
+
+  // ===== Access token =====
+  // The server traded ?token= for a cookie on this page load (accesstoken.go);
+  // drop it from the address bar so it isn't bookmarked or shared.
+  const pageParams = new URLSearchParams(location.search);
+  if (pageParams.has('token')) {
+    pageParams.delete('token');
+    const query = pageParams.toString();
+    history.replaceState(history.state, '', location.pathname + (query ? '?' + query : '') + location.hash);
+  }
+
+  // ===== Localization =====
+  // UI strings come from the locale bundle served by /api/i18n (i18n.go),
+  // loaded in init. Static markup names its strings with data-i18n
+  // (textContent), data-i18n-title, data-i18n-aria-label and
+  // data-i18n-placeholder.
+  let i18n = {};
+  let uiLocale;
+
+  // t returns the string for key with {name} placeholders filled from vars.
+  function t(key, vars) {
+    let str = Object.prototype.hasOwnProperty.call(i18n, key) ? i18n[key] : key;
+    if (vars) str = str.replace(/\{(\w+)\}/g, (m, name) => name in vars ? vars[name] : m);
+    return str;
+  }
+
+  function linesDeleted(count) {
+    return t(count === 1 ? 'lines_deleted_one' : 'lines_deleted_other', { n: count });
+  }
+
+  function applyI18n(root) {
+    const attrs = { 'data-i18n-title': 'title', 'data-i18n-aria-label': 'aria-label', 'data-i18n-placeholder': 'placeholder' };
+    root.querySelectorAll('[data-i18n]').forEach(el => {
+      if (i18n[el.dataset.i18n]) el.textContent = i18n[el.dataset.i18n];
+    });
+    Object.keys(attrs).forEach(attr => {
+      root.querySelectorAll('[' + attr + ']').forEach(el => {
+        const str = i18n[el.getAttribute(attr)];
+        if (str) el.setAttribute(attrs[attr], str);
+      });
+    });
+  }
+
   // ===== Comment Markdown Renderer =====
   const commentMd = window.markdownit({
     html: false,
@@ -36,6 +91,34 @@
     return '<span class="file-ref">' + escapeHtml(path) + '</span>';
   };
 
+  // ===== Comment Reference Inline Rule =====
+  // #<id> becomes a link when the server resolved it (env.refs), so stray
+  // hashes and IDs of deleted comments stay plain text.
+  commentMd.inline.ruler.push('comment_ref', function(state, silent) {
+    const start = state.pos;
+    if (state.src.charCodeAt(start) !== 0x23 /* # */) return false;
+    if (start > 0 && /[\w&\/#]/.test(state.src[start - 1])) return false;
+    const m = /^#([cr]_?[0-9a-z]+)\b/.exec(state.src.slice(start, state.posMax));
+    const ref = m && (state.env.refs || []).find(function(r) { return r.id === m[1]; });
+    if (!ref) return false;
+    if (!silent) {
+      const token = state.push('comment_ref', '', 0);
+      token.meta = ref;
+    }
+    state.pos = start + m[0].length;
+    return true;
+  });
+  commentMd.renderer.rules.comment_ref = function(tokens, idx) {
+    return commentRefLink(tokens[idx].meta);
+  };
+
+  function commentRefLink(ref) {
+    let title = 'Review comment';
+    if (ref.path) title = (ref.start_line ? lineRefLabel(ref.start_line, ref.end_line) : 'File comment') + ' in ' + ref.path;
+    return '<a class="comment-ref" href="#" data-ref-id="' + escapeHtml(ref.id) + '" data-ref-path="' + escapeHtml(ref.path || '') + '"' +
+      ' title="' + escapeHtml(title) + '">#' + escapeHtml(ref.id) + '</a>';
+  }
+
   // ===== Suggestion Diff Renderer =====
   function renderSuggestionDiff(suggestionContent, originalLines) {
     const sugLines = suggestionContent.replace(/\n$/, '').split('\n');
@@ -164,6 +247,7 @@
   let shareURL = '';
   let hostedURL = '';
   let deleteToken = '';
+  let shareExpiresAt = '';
   let configAuthor = '';
   let uiState = 'reviewing';
   let waitingHasComments = false;
@@ -173,6 +257,8 @@
   let reviewComments = []; // review-level (general) comments
   let reviewCommentFormActive = false; // is the review comment form open?
   let reviewCommentEditingId = null; // id of review comment being edited, or null
+  let checklist = []; // configured review checklist: [{text, checked}]
+  let commentTemplates = []; // saved comment templates (strings)
 
   let settingsPanelOpen = false;
   let settingsPanelTab = 'settings';
@@ -192,6 +278,8 @@
   let activeForms = [];  // Array of { formKey, filePath, afterBlockIndex, startLine, endLine, editingId, side }
   let prData = null;     // PR metadata from /api/config (set once on load)
   let agentEnabled = false;
+  let jiraEnabled = false;
+  let linearEnabled = false;
   let agentName = 'agent';
   const pendingAgentRequests = new Set();
 
@@ -205,8 +293,10 @@
   const ICON_CHEVRON = '<svg viewBox="0 0 16 16" fill="currentColor" width="16" height="16"><path d="M12.78 5.22a.75.75 0 0 1 0 1.06l-4.25 4.25a.75.75 0 0 1-1.06 0L3.22 6.28a.75.75 0 0 1 1.06-1.06L8 8.94l3.72-3.72a.75.75 0 0 1 1.06 0Z"/></svg>';
   const ICON_EDIT = '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M17 3a2.85 2.83 0 1 1 4 4L7.5 20.5 2 22l1.5-5.5Z"/><path d="m15 5 4 4"/></svg>';
   const ICON_DELETE = '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M3 6h18"/><path d="M19 6v14c0 1-1 2-2 2H7c-1 0-2-1-2-2V6"/><path d="M8 6V4c0-1 1-2 2-2h4c1 0 2 1 2 2v2"/></svg>';
+  const ICON_PIN = '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><line x1="12" y1="17" x2="12" y2="22"/><path d="M5 17h14v-1.76a2 2 0 0 0-1.11-1.79l-1.78-.9A2 2 0 0 1 15 10.76V6h1a2 2 0 0 0 0-4H8a2 2 0 0 0 0 4h1v4.76a2 2 0 0 1-1.11 1.79l-1.78.9A2 2 0 0 0 5 15.24Z"/></svg>';
   const ICON_RESOLVE = '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><polyline points="20 6 9 17 4 12"/></svg>';
   const ICON_UNRESOLVE = '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M3 12a9 9 0 0 1 9-9 9 9 0 0 1 6.36 2.64M21 12a9 9 0 0 1-9 9 9 9 0 0 1-6.36-2.64"/><polyline points="21 3 21 8 16 8"/><polyline points="3 21 3 16 8 16"/></svg>';
+  const ICON_TICKET = '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M2 9a3 3 0 0 1 0 6v2a2 2 0 0 0 2 2h16a2 2 0 0 0 2-2v-2a3 3 0 0 1 0-6V7a2 2 0 0 0-2-2H4a2 2 0 0 0-2 2Z"/><path d="M13 5v2"/><path d="M13 17v2"/><path d="M13 11v2"/></svg>';
   const ICON_CLIPBOARD = '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="1.5" stroke-linecap="round" stroke-linejoin="round"><rect x="9" y="9" width="13" height="13" rx="2"/><path d="M5 15H4a2 2 0 0 1-2-2V4a2 2 0 0 1 2-2h9a2 2 0 0 1 2 2v1"/></svg>';
   const ICON_CHECK_SMALL = '<svg viewBox="0 0 24 24" fill="none" stroke="currentColor" stroke-width="2" stroke-linecap="round" stroke-linejoin="round"><path d="M20 6L9 17l-5-5"/></svg>';
   const ICON_COMMENT = '<svg width="16" height="16" viewBox="0 0 16 16" fill="currentColor"><path d="M1 2.75C1 1.784 1.784 1 2.75 1h10.5c.966 0 1.75.784 1.75 1.75v7.5A1.75 1.75 0 0 1 13.25 12H9.06l-2.573 2.573A1.458 1.458 0 0 1 4 13.543V12H2.75A1.75 1.75 0 0 1 1 10.25Zm1.75-.25a.25.25 0 0 0-.25.25v7.5c0 .138.112.25.25.25h2a.75.75 0 0 1 .75.75v2.19l2.72-2.72a.749.749 0 0 1 .53-.22h4.5a.25.25 0 0 0 .25-.25v-7.5a.25.25 0 0 0-.25-.25Z"/></svg>';
@@ -311,7 +401,7 @@
         previousLineBlocks: null,
         tocItems: [],
         collapsed: true,
-        viewMode: (session.mode === 'git') ? 'diff' : 'document',
+        viewMode: (session.mode === 'git' || session.compare_base) ? 'diff' : 'document',
         additions: fi.additions || 0,
         deletions: fi.deletions || 0,
         lazy: true,
@@ -328,7 +418,7 @@
   async function loadSingleFile(fi, scope) {
     // Orphaned files have no content or diff — only fetch comments
     if (fi.orphaned) {
-      const comments = await fetch('/api/file/comments?path=' + enc(fi.path))
+      const comments = await fetch('/api/file/comments?path=' + enc(fi.path) + '&all=true')
         .then(function(r) { return r.ok ? r.json() : []; })
         .catch(function() { return []; });
       return {
@@ -362,7 +452,7 @@
     }
     const [fileRes, commentsRes, diffRes] = await Promise.all([
       fetch('/api/file?path=' + enc(fi.path)).then(function(r) { return r.ok ? r.json() : { content: '' }; }).catch(function() { return { content: '' }; }),
-      fetch('/api/file/comments?path=' + enc(fi.path)).then(function(r) { return r.ok ? r.json() : []; }).catch(function() { return []; }),
+      fetch('/api/file/comments?path=' + enc(fi.path) + '&all=true').then(function(r) { return r.ok ? r.json() : []; }).catch(function() { return []; }),
       fetch(diffUrl).then(function(r) { return r.ok ? r.json() : { hunks: [] }; }).catch(function() { return { hunks: [] }; }),
     ]);
 
@@ -378,12 +468,16 @@
       previousLineBlocks: null,
       tocItems: [],
       collapsed: fi.status === 'deleted',
-      viewMode: (session.mode === 'git') ? 'diff' : 'document',
+      viewMode: (session.mode === 'git' || session.compare_base) ? 'diff' : 'document',
       additions: fi.additions || 0,
       deletions: fi.deletions || 0,
       lazy: false,
       orphaned: false,
       fileHash: fileRes.file_hash || '',
+      language: fileRes.language || '',
+      image: fileRes.image || null,
+      totalLines: fileRes.total_lines || 0,
+      chunkEnd: fileRes.count !== undefined ? fileRes.start + fileRes.count - 1 : 0,
     };
 
     // Mark large diffs for deferred rendering
@@ -397,7 +491,7 @@
     // Pre-highlight code files for diff rendering
     if (f.fileType === 'code') {
       f.highlightCache = preHighlightFile(f);
-      f.lang = langFromPath(f.path);
+      f.lang = f.language || langFromPath(f.path);
 
       // In file mode, build line blocks so code files render as document view
       if (session.mode !== 'git') {
@@ -532,25 +626,42 @@
     document.getElementById('filesContainer').innerHTML =
       '<div class="loading" style="padding: 40px; text-align: center; color: var(--crit-editor-fg-muted);">Loading...</div>';
 
-    const [sessionRes, configRes] = await Promise.all([
+    const [sessionRes, configRes, settingsRes, localeRes] = await Promise.all([
       fetchWhenReady('/api/session?scope=' + enc(diffScope)).then(r => r.json()),
       fetchWhenReady('/api/config').then(r => r.json()),
+      fetch('/api/settings').then(r => r.ok ? r.json() : {}).catch(() => ({})),
+      fetch('/api/i18n').then(r => r.ok ? r.json() : {}).catch(() => ({})),
     ]);
+    applyDisplaySettings(settingsRes);
+    i18n = localeRes.strings || {};
+    uiLocale = localeRes.locale;
+    if (localeRes.lang) document.documentElement.lang = localeRes.lang;
+    applyI18n(document);
 
     session = sessionRes;
     reviewComments = sessionRes.review_comments || [];
 
+    loadTemplates();
+    fetch('/api/checklist')
+      .then(r => r.ok ? r.json() : [])
+      .then(items => { checklist = items || []; renderCommentsPanel(); })
+      .catch(() => { /* fire-and-forget */ });
+
     // Fire-and-forget: verify file list endpoint is available for @-mention autocomplete
     fetch('/api/files/list')
       .then(r => { if (r.ok) filePickerReady = true; })
       .catch(() => { /* fire-and-forget */ });
 
     // Config
+    csrfToken = configRes.csrf_token || '';
     shareURL = configRes.share_url || '';
     hostedURL = configRes.hosted_url || '';
     deleteToken = configRes.delete_token || '';
+    shareExpiresAt = configRes.share_expires_at || '';
     configAuthor = configRes.author || '';
     agentEnabled = configRes.agent_cmd_enabled || false;
+    jiraEnabled = configRes.jira_enabled || false;
+    linearEnabled = configRes.linear_enabled || false;
     agentName = configRes.agent_name || 'agent';
 
     if (shareURL && session.mode !== 'git') {
@@ -599,7 +710,12 @@
     } else if (session.mode !== 'git' && session.files && session.files.length === 1) {
       document.getElementById('branchContext').style.display = '';
       document.querySelector('.branch-icon').innerHTML = '<svg width="14" height="14" viewBox="0 0 16 16" fill="currentColor"><path fill-rule="evenodd" d="M3.75 1.5a.25.25 0 0 0-.25.25v12.5c0 .138.112.25.25.25h8.5a.25.25 0 0 0 .25-.25V6H9.75A1.75 1.75 0 0 1 8 4.25V1.5H3.75zm5.75.56v2.19c0 .138.112.25.25.25h2.19L9.5 2.06zM2 1.75C2 .784 2.784 0 3.75 0h5.086c.464 0 .909.184 1.237.513l3.414 3.414c.329.328.513.773.513 1.237v8.086A1.75 1.75 0 0 1 12.25 15h-8.5A1.75 1.75 0 0 1 2 13.25V1.75z"/></svg>';
-      document.getElementById('branchName').textContent = session.files[0].path.split('/').pop();
+      let contextName = session.files[0].path.split('/').pop();
+      if (session.compare_base) contextName = session.compare_base.split('/').pop() + ' → ' + contextName;
+      document.getElementById('branchName').textContent = contextName;
+    }
+    if (session.read_only_source) {
+      document.getElementById('readOnlySource').style.display = '';
     }
 
     // PR overview panel toggle
@@ -671,7 +787,8 @@
     buildToc();
     updateCommentCount();
     updateViewedCount();
-    restoreDrafts();
+    await restoreDrafts();
+    revealLinkedLines();
   }
 
   // Show/hide the Toggle Diff button and Split/Unified toggle in file mode
@@ -719,7 +836,7 @@
   // highlightedLines[lineNum] = highlighted HTML for that line.
   function preHighlightFile(file) {
     if (!file.content || file.fileType !== 'code') return null;
-    const lang = langFromPath(file.path);
+    const lang = file.language || langFromPath(file.path);
     if (!lang || !hljs.getLanguage(lang)) return null;
     try {
       const highlighted = hljs.highlight(file.content, { language: lang, ignoreIllegals: true }).value;
@@ -735,30 +852,118 @@
     }
   }
 
+  // Display settings from /api/settings (tab_width, show_trailing_whitespace,
+  // wrap in the config file). Tab width and wrapping are CSS; trailing
+  // whitespace is marked in the line HTML by markTrailingWhitespace.
+  let showTrailingWhitespace = false;
+  let displayTimezone; // IANA zone from the timezone config key; undefined is the browser's
+
+  function applyDisplaySettings(settings) {
+    if (settings.tab_width) document.documentElement.style.setProperty('--crit-tab-size', settings.tab_width);
+    showTrailingWhitespace = !!settings.show_trailing_whitespace;
+    if (settings.wrap === 'on' || settings.wrap === 'off') document.body.classList.add('wrap-' + settings.wrap);
+    displayTimezone = settings.timezone || undefined;
+  }
+
+  // Wrap the spaces and tabs ending a highlighted line (before any closing
+  // spans) so they can be shown.
+  function markTrailingWhitespace(html) {
+    if (!showTrailingWhitespace) return html;
+    return html.replace(/([ \t]+)((?:<\/span>)*)$/, '<span class="trailing-ws">$1</span>$2');
+  }
+
+  // "Line 12", "Lines 12-14", or with a column span (start_col/end_col)
+  // "Line 12:5-18" / "Lines 12:5-14:3".
+  function lineRefLabel(startLine, endLine, startCol, endCol) {
+    if (startCol) {
+      return startLine === endLine
+        ? 'Line ' + startLine + ':' + startCol + '-' + endCol
+        : 'Lines ' + startLine + ':' + startCol + '-' + endLine + ':' + endCol;
+    }
+    return startLine === endLine ? 'Line ' + startLine : 'Lines ' + startLine + '-' + endLine;
+  }
+
   // Get highlighted HTML for a single diff line.
   // Uses pre-highlighted cache for new-side lines, falls back to per-line for old-side.
   function highlightDiffLine(content, lineNum, side, highlightCache, lang) {
     // Try cache first (new-side lines: context and additions have NewNum mapped to file.content)
     if (highlightCache && lineNum > 0 && side !== 'old' && highlightCache[lineNum]) {
-      return highlightCache[lineNum];
+      return markTrailingWhitespace(highlightCache[lineNum]);
     }
     // Fallback: highlight individual line
     if (lang && hljs.getLanguage(lang)) {
       try {
-        return hljs.highlight(content, { language: lang, ignoreIllegals: true }).value;
+        return markTrailingWhitespace(hljs.highlight(content, { language: lang, ignoreIllegals: true }).value);
       } catch {}
     }
-    return escapeHtml(content);
+    return markTrailingWhitespace(escapeHtml(content));
   }
 
   // ===== Markdown Parsing =====
   function parseMarkdown(content) {
-    const tokens = documentMd.parse(content, {});
-    const blocks = buildLineBlocks(tokens, documentMd, content);
+    const fm = splitFrontmatter(content);
+    const body = fm ? fm.blanked : content;
+    const tokens = documentMd.parse(body, {});
+    let blocks = buildLineBlocks(tokens, documentMd, body);
+    if (fm) {
+      blocks = fm.blocks.concat(blocks.filter(function(b) { return b.startLine > fm.endLine; }));
+    }
     const tocItems = extractTocItems(tokens);
     return { blocks, tocItems };
   }
 
+  // Split a leading YAML frontmatter block into one block per top-level field
+  // so each field can be commented on (the server's parseFrontmatter applies
+  // the same rules). markdown-it gets the document with those lines blanked,
+  // which keeps its line numbers and stops it rendering the fences as rules.
+  function splitFrontmatter(content) {
+    const lines = content.split('\n');
+    if (lines[0].trimEnd() !== '---') return null;
+    let end = -1;
+    for (let i = 1; i < lines.length; i++) {
+      const t = lines[i].trimEnd();
+      if (t === '---' || t === '...') { end = i; break; }
+    }
+    if (end < 0) return null;
+
+    const isKeyLine = function(line) { return /^[^\s#-][^:]*:(\s|$)/.test(line); };
+    const blocks = [{ startLine: 1, endLine: 1, html: '<span class="frontmatter-label">Frontmatter</span>', isEmpty: false, cssClass: 'frontmatter-fence' }];
+    let i = 1;
+    while (i < end) {
+      if (!isKeyLine(lines[i])) {
+        blocks.push({ startLine: i + 1, endLine: i + 1, html: escapeHtml(lines[i]), isEmpty: lines[i].trim() === '', cssClass: 'frontmatter-line' });
+        i++;
+        continue;
+      }
+      let last = i;
+      for (let j = i + 1; j < end && !isKeyLine(lines[j]); j++) {
+        if (/^(\s+\S|-(\s|$))/.test(lines[j])) last = j;
+      }
+      const colon = lines[i].indexOf(':');
+      const key = lines[i].slice(0, colon).trim();
+      let value = lines[i].slice(colon + 1).trim();
+      let valueHtml;
+      if (value === '' || value[0] === '|' || value[0] === '>') {
+        valueHtml = '<pre class="frontmatter-value">' + escapeHtml(lines.slice(i + 1, last + 1).join('\n')) + '</pre>';
+      } else {
+        if (value.length >= 2 && (value[0] === '"' || value[0] === "'") && value[value.length - 1] === value[0]) {
+          value = value.slice(1, -1);
+        }
+        valueHtml = '<span class="frontmatter-value">' + escapeHtml(value) + '</span>';
+      }
+      blocks.push({
+        startLine: i + 1, endLine: last + 1,
+        html: '<div class="frontmatter-field"><span class="frontmatter-key">' + escapeHtml(key) + '</span>' + valueHtml + '</div>',
+        isEmpty: false, cssClass: 'frontmatter-line'
+      });
+      i = last + 1;
+    }
+    blocks.push({ startLine: end + 1, endLine: end + 1, html: '', isEmpty: true, cssClass: 'frontmatter-fence' });
+
+    const blanked = lines.map(function(l, idx) { return idx <= end ? '' : l; }).join('\n');
+    return { blocks: blocks, blanked: blanked, endLine: end + 1 };
+  }
+
   function extractTocItems(tokens) {
     const items = [];
     for (let i = 0; i < tokens.length; i++) {
@@ -798,14 +1003,16 @@
   // Build line blocks for code files in file mode (document view)
   function buildCodeLineBlocks(file) {
     const lines = file.content.split('\n');
+    // A partial file ends in a newline that doesn't start another line
+    if (hasMoreLines(file) && lines[lines.length - 1] === '') lines.pop();
     const blocks = [];
     for (let i = 0; i < lines.length; i++) {
       const lineNum = i + 1;
       let html;
       if (file.highlightCache && file.highlightCache[lineNum]) {
-        html = '<code class="hljs">' + file.highlightCache[lineNum] + '</code>';
+        html = '<code class="hljs">' + markTrailingWhitespace(file.highlightCache[lineNum]) + '</code>';
       } else {
-        html = '<code class="hljs">' + escapeHtml(lines[i] || '') + '</code>';
+        html = '<code class="hljs">' + markTrailingWhitespace(escapeHtml(lines[i] || '')) + '</code>';
       }
       blocks.push({
         startLine: lineNum,
@@ -888,7 +1095,7 @@
       const isLast = (ci === codeLines.length - 1 && blockEnd <= ln);
       blocks.push({
         startLine: ln, endLine: ln,
-        html: '<code class="hljs">' + (codeLines[ci] || '&nbsp;') + '</code>',
+        html: '<code class="hljs">' + (markTrailingWhitespace(codeLines[ci]) || '&nbsp;') + '</code>',
         isEmpty: false, cssClass: 'code-line' + (isLast ? ' code-last' : '')
       });
       coveredUpTo = ln;
@@ -1178,17 +1385,17 @@
     const now = Date.now();
     const then = new Date(dateStr).getTime();
     const diff = Math.floor((now - then) / 1000);
-    if (diff < 60) return 'just now';
-    if (diff < 3600) return Math.floor(diff / 60) + 'm ago';
-    if (diff < 86400) return Math.floor(diff / 3600) + 'h ago';
-    if (diff < 604800) return Math.floor(diff / 86400) + 'd ago';
-    return Math.floor(diff / 604800) + 'w ago';
+    if (diff < 60) return t('date_just_now');
+    if (diff < 3600) return t('date_minutes_ago', { n: Math.floor(diff / 60) });
+    if (diff < 86400) return t('date_hours_ago', { n: Math.floor(diff / 3600) });
+    if (diff < 604800) return t('date_days_ago', { n: Math.floor(diff / 86400) });
+    return t('date_weeks_ago', { n: Math.floor(diff / 604800) });
   }
 
   function formatTime(isoStr) {
     if (!isoStr) return '';
     const d = new Date(isoStr);
-    return d.toLocaleTimeString([], { hour: '2-digit', minute: '2-digit' });
+    return d.toLocaleTimeString(uiLocale, { hour: '2-digit', minute: '2-digit', timeZone: displayTimezone });
   }
 
   function getFileByPath(path) {
@@ -1740,6 +1947,8 @@
           file.tocItems = loaded.tocItems;
           file.diffTooLarge = loaded.diffTooLarge;
           file.diffLoaded = loaded.diffLoaded;
+          file.totalLines = loaded.totalLines;
+          file.chunkEnd = loaded.chunkEnd;
           file.lazy = false;
           file._lazyLoading = false;
           if (loaded.highlightCache) file.highlightCache = loaded.highlightCache;
@@ -1886,7 +2095,7 @@
           el.classList.add('outdated-comment');
           const badge = document.createElement('span');
           badge.className = 'outdated-badge';
-          badge.textContent = 'Outdated';
+          badge.textContent = t('outdated');
           const headerLeft = el.querySelector('.comment-header-left');
           if (headerLeft) headerLeft.appendChild(badge);
         }
@@ -1907,13 +2116,15 @@
     if (file.orphaned) {
       const placeholder = document.createElement('div');
       placeholder.className = 'diff-deleted-placeholder orphaned-placeholder';
-      placeholder.textContent = 'This file is no longer part of the review.';
+      placeholder.textContent = t('file_not_in_review');
       body.appendChild(placeholder);
     } else if (file.status === 'deleted' && (!file.diffHunks || file.diffHunks.length === 0)) {
       const deleted = document.createElement('div');
       deleted.className = 'diff-deleted-placeholder';
-      deleted.textContent = 'This file was deleted.';
+      deleted.textContent = t('file_deleted');
       body.appendChild(deleted);
+    } else if (file.fileType === 'image') {
+      body.appendChild(renderImageView(file));
     } else if (showDiff && file.diffTooLarge && !file.diffLoaded) {
       let diffLineCount = 0;
       if (file.diffHunks) {
@@ -1925,7 +2136,7 @@
       placeholder.className = 'diff-large-placeholder';
       placeholder.innerHTML =
         '<p>Large diff not rendered by default.</p>' +
-        '<p class="diff-large-meta">' + diffLineCount.toLocaleString() + ' lines changed</p>' +
+        '<p class="diff-large-meta">' + diffLineCount.toLocaleString(uiLocale) + ' lines changed</p>' +
         '<button class="btn btn-sm">Load diff</button>';
       placeholder.querySelector('button').addEventListener('click', function() {
         file.diffLoaded = true;
@@ -1938,6 +2149,7 @@
       body.appendChild(diffMode === 'split' ? renderRenderedDiffSplit(file) : renderRenderedDiffUnified(file));
     } else {
       body.appendChild(renderDocumentView(file));
+      if (hasMoreLines(file)) body.appendChild(renderMoreLinesSentinel(file));
     }
 
     section.appendChild(body);
@@ -2021,11 +2233,11 @@
     // Labels row
     const leftLabel = document.createElement('div');
     leftLabel.className = 'diff-view-side-label';
-    leftLabel.textContent = 'Previous round';
+    leftLabel.textContent = t('previous_round');
     container.appendChild(leftLabel);
     const rightLabel = document.createElement('div');
     rightLabel.className = 'diff-view-side-label';
-    rightLabel.textContent = 'Current round';
+    rightLabel.textContent = t('current_round');
     container.appendChild(rightLabel);
 
     // Two-pointer merge for horizontal alignment
@@ -2281,6 +2493,104 @@
     return { added: added, modified: modified, deletionPoints: deletionPoints };
   }
 
+  // ===== Image View =====
+  // Comments on images are file-level comments with a region in the image's
+  // natural pixels. Dragging on the image opens the file comment form for the
+  // dragged rectangle; open region comments are drawn over the image.
+  function renderImageView(file) {
+    const container = document.createElement('div');
+    container.className = 'image-review';
+    const img = document.createElement('img');
+    img.src = '/files/' + file.path.split('/').map(enc).join('/') + '?v=' + enc(file.fileHash || '');
+    img.alt = file.path;
+    img.draggable = false;
+    container.appendChild(img);
+
+    function naturalSize() {
+      const size = file.image || {};
+      return {
+        width: size.width || img.naturalWidth || img.width,
+        height: size.height || img.naturalHeight || img.height,
+      };
+    }
+
+    function placeBox(el, region) {
+      const size = naturalSize();
+      if (!size.width || !size.height) return;
+      el.style.left = (region.x / size.width * 100) + '%';
+      el.style.top = (region.y / size.height * 100) + '%';
+      el.style.width = (region.width / size.width * 100) + '%';
+      el.style.height = (region.height / size.height * 100) + '%';
+    }
+
+    const boxes = [];
+    file.comments.forEach(function(c) {
+      if (!c.region || c.resolved) return;
+      const box = document.createElement('div');
+      box.className = 'image-region';
+      box.title = c.body;
+      box.addEventListener('click', function() {
+        const card = document.querySelector('.comment-card[data-comment-id="' + c.id + '"]');
+        if (card) card.scrollIntoView({ behavior: 'smooth', block: 'center' });
+      });
+      container.appendChild(box);
+      boxes.push({ el: box, region: c.region });
+    });
+    const pending = getFormsForFile(file.path).find(function(f) { return f.scope === 'file' && f.region; });
+    if (pending) {
+      const box = document.createElement('div');
+      box.className = 'image-region image-region-pending';
+      container.appendChild(box);
+      boxes.push({ el: box, region: pending.region });
+    }
+    function placeAll() {
+      boxes.forEach(function(b) { placeBox(b.el, b.region); });
+    }
+    if (img.complete) placeAll();
+    img.addEventListener('load', placeAll);
+
+    if (file.orphaned) return container;
+
+    // Drag to select a region
+    let dragStart = null;
+    let dragBox = null;
+    function toImagePoint(e) {
+      const rect = img.getBoundingClientRect();
+      const size = naturalSize();
+      const x = Math.min(Math.max(e.clientX - rect.left, 0), rect.width);
+      const y = Math.min(Math.max(e.clientY - rect.top, 0), rect.height);
+      return { x: Math.round(x / rect.width * size.width), y: Math.round(y / rect.height * size.height) };
+    }
+    function dragRegion(e) {
+      const p = toImagePoint(e);
+      return {
+        x: Math.min(p.x, dragStart.x),
+        y: Math.min(p.y, dragStart.y),
+        width: Math.abs(p.x - dragStart.x),
+        height: Math.abs(p.y - dragStart.y),
+      };
+    }
+    container.addEventListener('mousedown', function(e) {
+      if (e.button !== 0 || e.target.closest('.image-region')) return;
+      e.preventDefault();
+      dragStart = toImagePoint(e);
+      dragBox = document.createElement('div');
+      dragBox.className = 'image-region image-region-pending';
+      container.appendChild(dragBox);
+      placeBox(dragBox, { x: dragStart.x, y: dragStart.y, width: 0, height: 0 });
+      document.addEventListener('mouseup', function(up) {
+        const region = dragRegion(up);
+        dragStart = null;
+        dragBox.remove();
+        if (region.width >= 2 && region.height >= 2) openFileCommentForm(file.path, region);
+      }, { once: true });
+    });
+    container.addEventListener('mousemove', function(e) {
+      if (dragStart) placeBox(dragBox, dragRegion(e));
+    });
+    return container;
+  }
+
   // ===== Document View (Markdown) =====
   function renderDocumentView(file) {
     const container = document.createElement('div');
@@ -2381,7 +2691,7 @@
         const marker0 = document.createElement('div');
         marker0.className = 'deletion-marker';
         marker0.dataset.filePath = file.path;
-        marker0.textContent = '\u2212' + deletionMarkerMap[0].count + ' line' + (deletionMarkerMap[0].count !== 1 ? 's' : '');
+        marker0.textContent = linesDeleted(deletionMarkerMap[0].count);
         container.appendChild(marker0);
       }
 
@@ -2392,7 +2702,7 @@
         const marker = document.createElement('div');
         marker.className = 'deletion-marker';
         marker.dataset.filePath = file.path;
-        marker.textContent = '\u2212' + deletionMarkerMap[block.endLine].count + ' line' + (deletionMarkerMap[block.endLine].count !== 1 ? 's' : '');
+        marker.textContent = linesDeleted(deletionMarkerMap[block.endLine].count);
         container.appendChild(marker);
       }
 
@@ -2417,6 +2727,50 @@
     return container;
   }
 
+  // ===== Chunked Large Files =====
+  // /api/file returns only the first chunk of a large code file (chunkEnd <
+  // totalLines). The rest is fetched a chunk at a time as the end of the
+  // loaded lines scrolls into view.
+  function hasMoreLines(file) {
+    return file.chunkEnd > 0 && file.chunkEnd < file.totalLines;
+  }
+
+  function renderMoreLinesSentinel(file) {
+    const sentinel = document.createElement('div');
+    sentinel.className = 'more-lines-sentinel';
+    sentinel.textContent = 'Loading lines ' + (file.chunkEnd + 1).toLocaleString(uiLocale) +
+      '–' + file.totalLines.toLocaleString(uiLocale) + '…';
+    const observer = new IntersectionObserver(function(entries) {
+      if (!entries.some(function(e) { return e.isIntersecting; })) return;
+      observer.disconnect();
+      loadMoreLines(file);
+    }, { rootMargin: '800px 0px' });
+    observer.observe(sentinel);
+    return sentinel;
+  }
+
+  async function loadMoreLines(file) {
+    if (file._chunkLoading || !hasMoreLines(file)) return;
+    file._chunkLoading = true;
+    try {
+      const res = await fetch('/api/file?path=' + enc(file.path) + '&start=' + (file.chunkEnd + 1));
+      if (!res.ok) return;
+      const chunk = await res.json();
+      // File changed since the first chunk; the file-changed reload replaces it
+      if (chunk.file_hash && file.fileHash && chunk.file_hash !== file.fileHash) return;
+      file.content += chunk.content || '';
+      file.totalLines = chunk.total_lines || file.totalLines;
+      file.chunkEnd = chunk.count ? chunk.start + chunk.count - 1 : file.totalLines;
+      file.highlightCache = preHighlightFile(file);
+      if (session.mode !== 'git') file.lineBlocks = buildCodeLineBlocks(file);
+      renderFileByPath(file.path);
+    } catch {
+      // Leave the sentinel; a re-render retries
+    } finally {
+      file._chunkLoading = false;
+    }
+  }
+
   // ===== Diff Hunk View (Code Files) =====
   function renderDiffHunks(file) {
     if (diffMode === 'split') return renderDiffSplit(file);
@@ -3255,7 +3609,7 @@
       if (headerLeft) {
         const badge = document.createElement('span');
         badge.className = 'outdated-badge';
-        badge.textContent = 'Outdated';
+        badge.textContent = t('outdated');
         headerLeft.appendChild(badge);
       }
       section.appendChild(el);
@@ -4029,17 +4383,20 @@
     focusCommentTextarea(newForm.formKey);
   }
 
-  function openFileCommentForm(filePath) {
+  // region (optional) is an image region the comment is about.
+  function openFileCommentForm(filePath, region) {
     const newForm = {
       filePath: filePath,
       scope: 'file',
       startLine: 0,
       endLine: 0,
-      afterBlockIndex: null
+      afterBlockIndex: null,
+      region: region || null
     };
     const fk = formKey(newForm);
     const existing = activeForms.find(function(f) { return f.formKey === fk; });
     if (existing) {
+      if (region) existing.region = region;
       renderFileByPath(filePath);
       focusCommentTextarea(existing.formKey);
       return;
@@ -4081,19 +4438,41 @@
   }
 
   // ===== Comment Templates =====
+  // Saved on the server (/api/templates) so they follow the reviewer across
+  // ports and reviews; commentTemplates is the copy loaded at startup.
   function getTemplates() {
-    try {
-      const raw = getCookie('crit-templates');
-      if (raw) {
-        const parsed = JSON.parse(raw);
-        if (Array.isArray(parsed)) return parsed;
-      }
-    } catch {}
-    return [];
+    return commentTemplates.slice();
   }
 
   function saveTemplates(templates) {
-    setCookie('crit-templates', JSON.stringify(templates));
+    commentTemplates = templates;
+    fetch('/api/templates', {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(templates),
+    }).then(function(r) {
+      if (!r.ok) throw new Error('Server returned ' + r.status);
+    }).catch(function(err) {
+      console.error('Error saving templates:', err);
+      showMiniToast('Failed to save templates');
+    });
+  }
+
+  // Templates used to live in a cookie; move any left there to the server once.
+  async function loadTemplates() {
+    try {
+      const res = await fetch('/api/templates');
+      if (res.ok) commentTemplates = await res.json();
+    } catch {}
+    const raw = getCookie('crit-templates');
+    if (!raw) return;
+    setCookie('crit-templates', '');
+    try {
+      const legacy = JSON.parse(raw);
+      if (Array.isArray(legacy) && legacy.length > 0) {
+        saveTemplates(commentTemplates.concat(legacy.filter(function(t) { return commentTemplates.indexOf(t) < 0; })));
+      }
+    } catch {}
   }
 
   function populateTemplateBar(bar, textarea) {
@@ -4150,7 +4529,7 @@
 
     const saveTemplateBtn = document.createElement('button');
     saveTemplateBtn.className = 'btn btn-sm';
-    saveTemplateBtn.textContent = '+ Template';
+    saveTemplateBtn.textContent = t('template');
     saveTemplateBtn.addEventListener('click', function(e) {
       e.preventDefault();
       showSaveTemplateDialog(textarea, templateBar);
@@ -4158,7 +4537,7 @@
 
     const suggestBtn = document.createElement('button');
     suggestBtn.className = 'btn btn-sm';
-    suggestBtn.textContent = '\u00B1 Suggest';
+    suggestBtn.textContent = t('suggest');
     suggestBtn.title = 'Insert the selected lines as a suggestion';
     suggestBtn.addEventListener('click', function() { insertSuggestion(textarea); });
 
@@ -4184,11 +4563,11 @@
     dialog.className = 'save-template-dialog';
 
     const title = document.createElement('h3');
-    title.textContent = 'Save as template';
+    title.textContent = t('save_as_template');
     dialog.appendChild(title);
 
     const desc = document.createElement('p');
-    desc.textContent = 'Edit the template text, then save.';
+    desc.textContent = t('edit_template');
     dialog.appendChild(desc);
 
     const input = document.createElement('textarea');
@@ -4202,12 +4581,12 @@
 
     const cancelBtn = document.createElement('button');
     cancelBtn.className = 'btn btn-sm';
-    cancelBtn.textContent = 'Cancel';
+    cancelBtn.textContent = t('cancel');
     cancelBtn.addEventListener('click', function() { overlay.remove(); textarea.focus(); });
 
     const saveBtn = document.createElement('button');
     saveBtn.className = 'btn btn-sm btn-primary';
-    saveBtn.textContent = 'Save';
+    saveBtn.textContent = t('save');
     saveBtn.addEventListener('click', function() {
       const val = input.value.trim();
       if (!val) return;
@@ -4460,7 +4839,7 @@
 
     const cancelBtn = document.createElement('button');
     cancelBtn.className = 'btn btn-sm';
-    cancelBtn.textContent = 'Cancel';
+    cancelBtn.textContent = t('cancel');
     cancelBtn.addEventListener('click', doCancel);
 
     const submitBtn = document.createElement('button');
@@ -4517,9 +4896,7 @@
   }
 
   function createCommentForm(formObj) {
-    const lineRef = formObj.startLine === formObj.endLine
-      ? 'Line ' + formObj.startLine
-      : 'Lines ' + formObj.startLine + '-' + formObj.endLine;
+    const lineRef = lineRefLabel(formObj.startLine, formObj.endLine);
     let initialBody = '';
     if (formObj.editingId) {
       const file = getFileByPath(formObj.filePath);
@@ -4580,6 +4957,15 @@
     textarea.focus();
   }
 
+  // responseError turns a failed API response into an Error with the
+  // server's message: a JSON body's "error" field, or the plain text.
+  async function responseError(res) {
+    const text = (await res.text()).trim();
+    let message = text;
+    try { message = JSON.parse(text).error || text; } catch (_) { /* plain text */ }
+    return new Error(message || 'Server returned ' + res.status);
+  }
+
   async function submitComment(body, formObj) {
     if (!body.trim() || !formObj) return null;
     clearDraft(formObj);
@@ -4595,7 +4981,7 @@
           headers: { 'Content-Type': 'application/json' },
           body: JSON.stringify({ body: body.trim() })
         });
-        if (!res.ok) throw new Error('Server returned ' + res.status);
+        if (!res.ok) throw await responseError(res);
         const updated = await res.json();
         const idx = file.comments.findIndex(c => c.id === formObj.editingId);
         if (idx >= 0) file.comments[idx] = updated;
@@ -4606,6 +4992,7 @@
         };
         if (formObj.scope === 'file') {
           payload.scope = 'file';
+          if (formObj.region) payload.region = formObj.region;
         } else {
           payload.start_line = formObj.startLine;
           payload.end_line = formObj.endLine;
@@ -4619,14 +5006,16 @@
           headers: { 'Content-Type': 'application/json' },
           body: JSON.stringify(payload)
         });
-        if (!res.ok) throw new Error('Server returned ' + res.status);
+        if (!res.ok) throw await responseError(res);
         const newComment = await res.json();
-        file.comments.push(newComment);
+        // A repeat of an open comment comes back as that comment (duplicate_comments).
+        if (!file.comments.some(c => c.id === newComment.id)) file.comments.push(newComment);
         created = newComment;
         userActedThisRound = true;
       }
     } catch (err) {
       console.error('Error saving comment:', err);
+      showMiniToast('Failed to save comment: ' + err.message);
       return null;
     }
 
@@ -4672,23 +5061,31 @@
     return 'crit-draft-' + formObj.formKey;
   }
 
+  // Drafts are also kept by the daemon (/api/drafts), so they survive a
+  // daemon restart: the new daemon's port gives the page a new localStorage.
+  function draftURL(formObj) {
+    return '/api/drafts/' + enc(formObj.formKey);
+  }
+
   function saveDraft(body, formObj) {
     if (!formObj) return;
     const key = getDraftKey(formObj);
     if (!key) return;
+    const draft = JSON.stringify({
+      filePath: formObj.filePath,
+      startLine: formObj.startLine,
+      endLine: formObj.endLine,
+      afterBlockIndex: formObj.afterBlockIndex,
+      editingId: formObj.editingId,
+      side: formObj.side || '',
+      scope: formObj.scope || '',
+      body: body,
+      savedAt: Date.now()
+    });
     try {
-      localStorage.setItem(key, JSON.stringify({
-        filePath: formObj.filePath,
-        startLine: formObj.startLine,
-        endLine: formObj.endLine,
-        afterBlockIndex: formObj.afterBlockIndex,
-        editingId: formObj.editingId,
-        side: formObj.side || '',
-        scope: formObj.scope || '',
-        body: body,
-        savedAt: Date.now()
-      }));
+      localStorage.setItem(key, draft);
     } catch {}
+    fetch(draftURL(formObj), { method: 'PUT', body: draft, keepalive: true }).catch(function() {});
   }
 
   function debouncedSaveDraft(body, formObj) {
@@ -4708,6 +5105,7 @@
     const draftKey = getDraftKey(formObj);
     if (draftKey) {
       try { localStorage.removeItem(draftKey); } catch {}
+      fetch(draftURL(formObj), { method: 'DELETE' }).catch(function() {});
     }
   }
 
@@ -4718,39 +5116,58 @@
     });
   });
 
-  function restoreDrafts() {
+  // restoreDrafts reopens the comment forms of saved drafts: the daemon's
+  // and this origin's localStorage ones, the newer of the two for a form.
+  // The daemon's drafts don't expire; it keeps them until the review file
+  // is cleaned up.
+  async function restoreDrafts() {
     let restored = false;
+    let daemonDrafts = {};
+    try {
+      const res = await fetch('/api/drafts');
+      if (res.ok) daemonDrafts = await res.json();
+    } catch {}
     const keysToProcess = [];
     for (let i = 0; i < localStorage.length; i++) {
       const k = localStorage.key(i);
       if (k && k.startsWith('crit-draft-')) keysToProcess.push(k);
     }
+    Object.keys(daemonDrafts).forEach(function(formKeyValue) {
+      const k = 'crit-draft-' + formKeyValue;
+      if (!keysToProcess.includes(k)) keysToProcess.push(k);
+    });
     for (let ki = 0; ki < keysToProcess.length; ki++) {
       const key = keysToProcess[ki];
+      const fromDaemon = daemonDrafts[key.slice('crit-draft-'.length)];
+      const discard = function() {
+        localStorage.removeItem(key);
+        if (fromDaemon) clearDraft({ formKey: key.slice('crit-draft-'.length) });
+      };
       try {
         const raw = localStorage.getItem(key);
-        if (!raw) continue;
-        const draft = JSON.parse(raw);
+        let draft = raw ? JSON.parse(raw) : null;
+        if (fromDaemon && (!draft || fromDaemon.savedAt > draft.savedAt)) draft = fromDaemon;
+        if (!draft) continue;
 
-        if (Date.now() - draft.savedAt > 24 * 60 * 60 * 1000) {
-          localStorage.removeItem(key);
+        if (draft !== fromDaemon && Date.now() - draft.savedAt > 24 * 60 * 60 * 1000) {
+          discard();
           continue;
         }
 
         const file = getFileByPath(draft.filePath);
-        if (!file) { localStorage.removeItem(key); continue; }
+        if (!file) { discard(); continue; }
 
         if (draft.scope !== 'file' && file.fileType === 'markdown' && file.content) {
           const totalLines = file.content.split('\n').length;
           if (draft.startLine < 1 || draft.endLine > totalLines) {
-            localStorage.removeItem(key);
+            discard();
             continue;
           }
         }
 
         if (draft.editingId) {
           if (!file.comments.find(function(c) { return c.id === draft.editingId; })) {
-            localStorage.removeItem(key);
+            discard();
             continue;
           }
         }
@@ -4771,7 +5188,7 @@
         restored = true;
         localStorage.removeItem(key);
       } catch {
-        localStorage.removeItem(key);
+        discard();
       }
     }
     if (restored) {
@@ -4818,8 +5235,8 @@
 
   // ===== Comment Display =====
   function buildCommentEnv(comment, filePath) {
-    const env = {};
-    const file = getFileByPath(filePath);
+    const env = { refs: comment.refs };
+    const file = filePath ? getFileByPath(filePath) : null;
     if (file && file.content && comment.start_line && comment.end_line && !comment.side) {
       env.originalLines = comment.quote
         ? comment.quote.split('\n')
@@ -4878,12 +5295,17 @@
       roundBadge.textContent = 'R' + comment.review_round;
       headerLeft.appendChild(roundBadge);
     }
+    if (opts.showLineRef && comment.region) {
+      const regionRef = document.createElement('span');
+      regionRef.className = 'comment-line-ref';
+      const r = comment.region;
+      regionRef.textContent = t('region', { x: r.x, y: r.y, width: r.width, height: r.height });
+      headerLeft.appendChild(regionRef);
+    }
     if (opts.showLineRef && comment.scope !== 'file') {
       const lineRef = document.createElement('span');
       lineRef.className = 'comment-line-ref';
-      lineRef.textContent = comment.start_line === comment.end_line
-        ? 'Line ' + comment.start_line
-        : 'Lines ' + comment.start_line + '-' + comment.end_line;
+      lineRef.textContent = lineRefLabel(comment.start_line, comment.end_line, comment.start_col, comment.end_col);
       headerLeft.appendChild(lineRef);
     }
     const time = document.createElement('span');
@@ -4898,11 +5320,48 @@
       headerLeft.appendChild(badge);
     }
 
+    if (comment.severity) {
+      const severityBadge = document.createElement('span');
+      severityBadge.className = 'severity-badge severity-' + comment.severity;
+      severityBadge.textContent = comment.severity;
+      headerLeft.appendChild(severityBadge);
+    }
+
+    if (comment.tool) {
+      const toolBadge = document.createElement('span');
+      toolBadge.className = 'tool-badge';
+      // "auto" is crit's own prose check; anything else is a linter.
+      const auto = comment.tool === 'auto';
+      toolBadge.textContent = auto ? 'auto' : 'tool';
+      toolBadge.title = auto ? 'Found by the prose check' : 'Reported by ' + comment.tool;
+      headerLeft.appendChild(toolBadge);
+    }
+
+    if (comment.issue_key) {
+      const issueLink = document.createElement('a');
+      issueLink.className = 'issue-badge';
+      issueLink.href = comment.issue_url;
+      issueLink.target = '_blank';
+      issueLink.rel = 'noopener';
+      issueLink.title = 'Open the issue created from this comment';
+      issueLink.innerHTML = ICON_TICKET;
+      issueLink.append(comment.issue_key);
+      headerLeft.appendChild(issueLink);
+    }
+
+    if (comment.pinned) {
+      card.classList.add('pinned');
+      const pinnedBadge = document.createElement('span');
+      pinnedBadge.className = 'pinned-badge';
+      pinnedBadge.innerHTML = ICON_PIN + escapeHtml(t('pinned'));
+      headerLeft.appendChild(pinnedBadge);
+    }
+
     if (comment.drifted) {
       wrapper.classList.add('outdated-comment');
       const driftedBadge = document.createElement('span');
       driftedBadge.className = 'outdated-badge';
-      driftedBadge.textContent = 'Drifted';
+      driftedBadge.textContent = t('drifted');
       headerLeft.appendChild(driftedBadge);
     }
 
@@ -4914,7 +5373,7 @@
 
     const bodyEl = document.createElement('div');
     bodyEl.className = 'comment-body';
-    bodyEl.innerHTML = commentMd.render(comment.body, filePath ? buildCommentEnv(comment, filePath) : undefined);
+    bodyEl.innerHTML = commentMd.render(comment.body, buildCommentEnv(comment, filePath));
 
     card.appendChild(header);
 
@@ -4933,7 +5392,7 @@
 
       const toggleLabel = document.createElement('span');
       toggleLabel.className = 'drifted-toggle-label';
-      toggleLabel.textContent = 'Referenced content at time of review';
+      toggleLabel.textContent = t('referenced_content');
 
       const anchorLines = comment.anchor.split('\n');
       const toggleMeta = document.createElement('span');
@@ -4991,11 +5450,22 @@
 
     card.appendChild(bodyEl);
 
+    if (comment.backlinks && comment.backlinks.length > 0) {
+      const backlinks = document.createElement('div');
+      backlinks.className = 'comment-backlinks';
+      backlinks.innerHTML = 'Referenced by ' + comment.backlinks.map(commentRefLink).join(', ');
+      card.appendChild(backlinks);
+    }
+
     // Render replies
     if (comment.replies && comment.replies.length > 0) {
       card.appendChild(renderReplyList(comment, filePath || '', opts.repliesExtraClass));
     }
 
+    if (comment.disposition) {
+      card.appendChild(renderDisposition(comment, filePath));
+    }
+
     // Pending agent indicator
     if (pendingAgentRequests.has(comment.id)) {
       const pending = document.createElement('div');
@@ -5057,13 +5527,57 @@
       toggleResolveStatus(comment.id, 'file', 'resolve', filePath);
     });
 
+    const pinBtn = document.createElement('button');
+    pinBtn.className = 'pin-btn' + (comment.pinned ? ' pin-btn--active' : '');
+    pinBtn.title = comment.pinned ? 'Unpin' : 'Pin to top';
+    pinBtn.innerHTML = ICON_PIN;
+    pinBtn.addEventListener('click', () => toggleCommentPinned(comment, filePath));
+
     parts.actions.appendChild(resolveBtn);
+    parts.actions.appendChild(pinBtn);
+    appendIssueButtons(parts.actions, comment, filePath);
     parts.actions.appendChild(editBtn);
     parts.actions.appendChild(deleteBtn);
 
     return parts.wrapper;
   }
 
+  const DISPOSITION_LABELS = {
+    'fixed': 'Fixed',
+    'needs-clarification': 'Needs clarification',
+    'disagree': 'Disagrees',
+  };
+
+  // The agent's disposition on a comment (PATCH /api/comment/{id}): what it
+  // did about it and why.
+  function renderDisposition(comment, filePath) {
+    const d = comment.disposition;
+    const el = document.createElement('div');
+    el.className = 'comment-disposition disposition-' + d.status;
+
+    const meta = document.createElement('div');
+    meta.className = 'disposition-meta';
+    const label = document.createElement('span');
+    label.className = 'disposition-label';
+    label.textContent = DISPOSITION_LABELS[d.status] || d.status;
+    meta.appendChild(label);
+    if (d.author) {
+      const author = document.createElement('span');
+      author.className = 'comment-author-badge author-color-' + authorColorIndex(d.author);
+      author.textContent = '@' + d.author;
+      meta.appendChild(author);
+    }
+    el.appendChild(meta);
+
+    if (d.explanation) {
+      const text = document.createElement('div');
+      text.className = 'disposition-explanation';
+      text.innerHTML = commentMd.render(d.explanation, buildCommentEnv(comment, filePath));
+      el.appendChild(text);
+    }
+    return el;
+  }
+
   // Build a reply list container for a comment's replies
   function renderReplyList(comment, filePath, extraClass) {
     const repliesContainer = document.createElement('div');
@@ -5110,7 +5624,7 @@
       const replyBody = document.createElement('div');
       replyBody.className = 'reply-body';
       replyBody.dataset.rawBody = reply.body;
-      replyBody.innerHTML = commentMd.render(reply.body);
+      replyBody.innerHTML = commentMd.render(reply.body, { refs: comment.refs });
       replyEl.appendChild(replyBody);
 
       repliesContainer.appendChild(replyEl);
@@ -5270,9 +5784,7 @@
     if (comment.scope === 'file') {
       headerText = 'Editing file comment';
     } else {
-      const lineRef = comment.start_line === comment.end_line
-        ? 'Line ' + comment.start_line
-        : 'Lines ' + comment.start_line + '-' + comment.end_line;
+      const lineRef = lineRefLabel(comment.start_line, comment.end_line, comment.start_col, comment.end_col);
       headerText = 'Editing comment on ' + lineRef;
     }
     const formEl = createCommentFormUI({
@@ -5350,12 +5862,118 @@
     }
   }
 
+  // The review checklist: one checkbox per configured gate
+  function createChecklistGroup() {
+    const group = document.createElement('div');
+    group.className = 'comments-panel-file-group checklist-group';
+    const groupName = document.createElement('div');
+    groupName.className = 'comments-panel-file-name';
+    const done = checklist.filter(function(it) { return it.checked; }).length;
+    groupName.textContent = t('checklist', { done: done, total: checklist.length });
+    group.appendChild(groupName);
+    checklist.forEach(function(item) {
+      const label = document.createElement('label');
+      label.className = 'checklist-item';
+      const box = document.createElement('input');
+      box.type = 'checkbox';
+      box.checked = item.checked;
+      box.addEventListener('change', function() { setChecklistItem(item.text, box.checked); });
+      const text = document.createElement('span');
+      text.textContent = item.text;
+      label.appendChild(box);
+      label.appendChild(text);
+      group.appendChild(label);
+    });
+    return group;
+  }
+
+  async function setChecklistItem(text, checked) {
+    try {
+      const res = await fetch('/api/checklist', {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ text: text, checked: checked }),
+      });
+      if (!res.ok) throw new Error('Server returned ' + res.status);
+      checklist = await res.json();
+      userActedThisRound = true;
+    } catch (err) {
+      console.error('Error updating checklist:', err);
+      showMiniToast('Failed to update checklist');
+    }
+    renderCommentsPanel();
+  }
+
+  async function toggleCommentPinned(comment, filePath) {
+    try {
+      const res = await fetch('/api/comment/' + comment.id + '/pin?path=' + enc(filePath), {
+        method: 'PUT',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ pinned: !comment.pinned }),
+      });
+      if (!res.ok) throw new Error('Server returned ' + res.status);
+    } catch (err) {
+      console.error('Error pinning comment:', err);
+      showMiniToast('Failed to ' + (comment.pinned ? 'unpin' : 'pin') + ' comment');
+      return;
+    }
+    refreshFileComments(filePath);
+  }
+
+  // Issue trackers a comment can be turned into, by route name.
+  const ISSUE_TRACKERS = { jira: 'Jira', linear: 'Linear' };
+
+  // Adds a button per configured tracker until the comment has an issue.
+  // filePath is null for review-level comments.
+  function appendIssueButtons(actions, comment, filePath) {
+    if (comment.issue_key) return;
+    if (jiraEnabled) actions.appendChild(createIssueButton(comment, filePath, 'jira'));
+    if (linearEnabled) actions.appendChild(createIssueButton(comment, filePath, 'linear'));
+  }
+
+  function createIssueButton(comment, filePath, tracker) {
+    const btn = document.createElement('button');
+    btn.className = 'issue-btn';
+    btn.title = 'Create ' + ISSUE_TRACKERS[tracker] + ' issue';
+    btn.setAttribute('aria-label', btn.title);
+    btn.innerHTML = ICON_TICKET;
+    btn.addEventListener('click', function(e) {
+      e.stopPropagation();
+      btn.disabled = true;
+      createIssue(comment, filePath, tracker).finally(() => { btn.disabled = false; });
+    });
+    return btn;
+  }
+
+  async function createIssue(comment, filePath, tracker) {
+    const url = filePath
+      ? '/api/comment/' + comment.id + '/' + tracker + '?path=' + enc(filePath)
+      : '/api/review-comment/' + comment.id + '/' + tracker;
+    let updated;
+    try {
+      const res = await fetch(url, { method: 'POST' });
+      if (!res.ok) throw new Error((await res.text()).trim() || 'Server returned ' + res.status);
+      updated = await res.json();
+    } catch (err) {
+      console.error('Error creating ' + ISSUE_TRACKERS[tracker] + ' issue:', err);
+      showMiniToast('Failed to create ' + ISSUE_TRACKERS[tracker] + ' issue: ' + err.message);
+      return;
+    }
+    showMiniToast('Created ' + updated.issue_key);
+    if (filePath) {
+      refreshFileComments(filePath);
+    } else {
+      await refreshReviewComments();
+      renderCommentsPanel();
+    }
+  }
+
   // Re-fetch comments for a file from the API and re-render
   async function refreshFileComments(filePath) {
     const file = getFileByPath(filePath);
     if (!file) return;
     try {
-      const res = await fetch('/api/file/comments?path=' + enc(filePath));
+      const res = await fetch('/api/file/comments?path=' + enc(filePath) + '&all=true');
       if (res.ok) {
         file.comments = await res.json();
       }
@@ -5368,6 +5986,20 @@
     updateTreeCommentBadges();
   }
 
+  // ===== Undo / Redo =====
+  // The server keeps the journal (undo.go); its comments-changed event
+  // re-renders the comments.
+  async function stepCommentHistory(direction) {
+    try {
+      const res = await fetch('/api/' + direction, { method: 'POST' });
+      if (!res.ok) throw await responseError(res);
+      const step = await res.json();
+      showMiniToast(t(direction === 'undo' ? 'undid' : 'redid', { op: t('journal_' + step.op) }));
+    } catch (err) {
+      showMiniToast(err.message);
+    }
+  }
+
   // ===== Review-Level (General) Comments =====
   let reviewCommentSubmitting = false;
   async function addReviewComment(body) {
@@ -5379,13 +6011,13 @@
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ body: body.trim(), author: configAuthor })
       });
-      if (!res.ok) throw new Error('Server returned ' + res.status);
+      if (!res.ok) throw await responseError(res);
       const newComment = await res.json();
-      reviewComments.push(newComment);
+      if (!reviewComments.some(c => c.id === newComment.id)) reviewComments.push(newComment);
       userActedThisRound = true;
     } catch (err) {
       console.error('Error adding review comment:', err);
-      showMiniToast('Failed to add comment');
+      showMiniToast('Failed to add comment: ' + err.message);
       reviewCommentSubmitting = false;
       return;
     }
@@ -5492,7 +6124,7 @@
     const el = createCommentFormUI({
       formObj: formObj,
       headerText: 'Editing comment',
-      submitText: 'Save',
+      submitText: t('save'),
       initialBody: comment.body,
       autoFocus: true,
       onSubmit: function(body) { updateReviewComment(comment.id, body); },
@@ -5504,7 +6136,7 @@
 
   async function refreshReviewComments() {
     try {
-      const res = await fetch('/api/comments');
+      const res = await fetch('/api/comments?all=true');
       if (res.ok) {
         reviewComments = await res.json();
       }
@@ -5531,10 +6163,10 @@
 
     const saveBtn = document.createElement('button');
     saveBtn.className = 'btn btn-sm btn-primary';
-    saveBtn.textContent = 'Save';
+    saveBtn.textContent = t('save');
     const cancelBtn = document.createElement('button');
     cancelBtn.className = 'btn btn-sm';
-    cancelBtn.textContent = 'Cancel';
+    cancelBtn.textContent = t('cancel');
 
     const btnRow = document.createElement('div');
     btnRow.className = 'reply-edit-actions';
@@ -5600,11 +6232,11 @@
 
     const cancelBtn = document.createElement('button');
     cancelBtn.className = 'btn btn-sm';
-    cancelBtn.textContent = 'Cancel';
+    cancelBtn.textContent = t('cancel');
 
     const submitBtn = document.createElement('button');
     submitBtn.className = 'btn btn-sm btn-primary';
-    submitBtn.textContent = 'Reply';
+    submitBtn.textContent = t('reply');
 
     buttons.appendChild(cancelBtn);
     buttons.appendChild(submitBtn);
@@ -5780,7 +6412,7 @@
     }
     renderCommentsPanel();
     if (uiState === 'reviewing') {
-      document.getElementById('finishBtn').textContent = unresolved === 0 ? 'Approve' : 'Finish Review';
+      document.getElementById('finishBtn').textContent = t(unresolved === 0 ? 'approve' : 'finish_review');
     }
   }
 
@@ -5870,6 +6502,7 @@
         e.stopPropagation();
         deleteReviewComment(comment.id);
       });
+      appendIssueButtons(parts.actions, comment, null);
       parts.actions.appendChild(editBtn);
       parts.actions.appendChild(deleteBtn);
     }
@@ -5907,7 +6540,31 @@
       body.appendChild(createReviewCommentFormUI());
     }
 
-    // Render review-level (general) comments first
+    if (checklist.length > 0) {
+      body.appendChild(createChecklistGroup());
+    }
+
+    // Pinned comments lead the panel regardless of file and line order
+    const pinnedGroup = document.createElement('div');
+    pinnedGroup.className = 'comments-panel-file-group';
+    files.forEach(function(file) {
+      file.comments.forEach(function(c) {
+        if (!c.pinned || (c.resolved && !showResolved)) return;
+        if (pinnedGroup.childElementCount === 0) {
+          const groupName = document.createElement('div');
+          groupName.className = 'comments-panel-file-name';
+          groupName.textContent = t('pinned');
+          pinnedGroup.appendChild(groupName);
+        }
+        pinnedGroup.appendChild(createPanelCommentCard(c, file.path));
+      });
+    });
+    if (pinnedGroup.childElementCount > 0) {
+      hasComments = true;
+      body.appendChild(pinnedGroup);
+    }
+
+    // Then review-level (general) comments
     const visibleReviewComments = reviewComments.filter(function(c) {
       return showResolved ? true : !c.resolved;
     });
@@ -5918,7 +6575,7 @@
 
       const groupName = document.createElement('div');
       groupName.className = 'comments-panel-file-name';
-      groupName.textContent = 'Review';
+      groupName.textContent = t('review');
       group.appendChild(groupName);
 
       for (let j = 0; j < visibleReviewComments.length; j++) {
@@ -5936,7 +6593,7 @@
     for (let i = 0; i < files.length; i++) {
       const file = files[i];
       const visibleComments = file.comments.filter(function(c) {
-        return showResolved ? true : !c.resolved;
+        return !c.pinned && (showResolved ? true : !c.resolved);
       });
       if (visibleComments.length === 0) continue;
       hasComments = true;
@@ -5973,6 +6630,19 @@
     body.scrollTop = savedScroll;
   }
 
+  // #<id> links in comment bodies and backlink lists
+  document.addEventListener('click', function(e) {
+    const link = e.target.closest('a.comment-ref');
+    if (!link) return;
+    e.preventDefault();
+    if (link.dataset.refPath) {
+      scrollToComment(link.dataset.refId, link.dataset.refPath);
+      return;
+    }
+    const card = document.querySelector('#commentsPanelBody .comment-card[data-comment-id="' + CSS.escape(link.dataset.refId) + '"]');
+    if (card) card.scrollIntoView({ behavior: 'smooth', block: 'center' });
+  });
+
   function scrollToComment(commentId, filePath) {
     // 1. Find the file section and expand if collapsed
     const section = document.getElementById('file-section-' + filePath);
@@ -5995,6 +6665,50 @@
     }, { once: true });
   }
 
+  // ===== Line Links =====
+  // #plan.md:L42-L50 (#L42-L50 for the first file) opens the review on those
+  // lines. The history export links each line comment this way (lineLink in
+  // history.go).
+  function parseLineHash(hash) {
+    const m = /^#(?:(.+):)?L(\d+)(?:-L(\d+))?$/.exec(hash);
+    if (!m) return null;
+    let filePath = m[1] || '';
+    try { filePath = decodeURIComponent(filePath); } catch (e) { return null; }
+    const start = parseInt(m[2], 10);
+    return { filePath: filePath, start: start, end: m[3] ? Math.max(start, parseInt(m[3], 10)) : start };
+  }
+
+  function revealLinkedLines() {
+    const link = parseLineHash(location.hash);
+    if (!link || files.length === 0) return;
+    const filePath = link.filePath || files[0].path;
+    const section = document.getElementById('file-section-' + filePath);
+    if (!section) return;
+    const file = getFileByPath(filePath);
+    if (file) file.collapsed = false;
+    section.open = true;
+
+    document.querySelectorAll('.line-linked').forEach(el => el.classList.remove('line-linked'));
+    const targets = Array.from(section.querySelectorAll('.line-block[data-start-line], [data-diff-line-num]')).filter(el => {
+      if (el.dataset.diffLineNum) {
+        const n = parseInt(el.dataset.diffLineNum, 10);
+        return el.dataset.diffSide !== 'old' && n >= link.start && n <= link.end;
+      }
+      return parseInt(el.dataset.startLine, 10) <= link.end && parseInt(el.dataset.endLine, 10) >= link.start;
+    });
+    if (targets.length === 0) {
+      scrollToFile(filePath);
+      return;
+    }
+    targets.forEach(el => el.classList.add('line-linked'));
+    const mainHeader = document.querySelector('.header');
+    const offset = (mainHeader ? mainHeader.offsetHeight : 49) + 8;
+    window.scrollTo({ top: targets[0].getBoundingClientRect().top + window.scrollY - offset, behavior: 'instant' });
+    updateTreeActive(filePath);
+  }
+
+  window.addEventListener('hashchange', revealLinkedLines);
+
   // ===== PR Overview Panel =====
   function togglePRPanel() {
     const panel = document.getElementById('prPanel');
@@ -6109,7 +6823,7 @@
 
       const descTitle = document.createElement('div');
       descTitle.className = 'pr-panel-section-title';
-      descTitle.textContent = 'Description';
+      descTitle.textContent = t('description');
       descSection.appendChild(descTitle);
 
       const descBody = document.createElement('div');
@@ -6136,7 +6850,7 @@
   function updateHeaderRound() {
     const el = document.getElementById('headerNotify');
     if (session.review_round > 1) {
-      el.textContent = 'Round #' + session.review_round;
+      el.textContent = t('round', { n: session.review_round });
     }
   }
 
@@ -6153,17 +6867,19 @@
           if (files[fi].comments) unresolvedComments += files[fi].comments.filter(function(c) { return !c.resolved; }).length;
         }
         unresolvedComments += reviewComments.filter(function(c) { return !c.resolved; }).length;
-        finishBtn.textContent = unresolvedComments === 0 ? 'Approve' : 'Finish Review';
+        finishBtn.textContent = t(unresolvedComments === 0 ? 'approve' : 'finish_review');
         finishBtn.disabled = false;
         finishBtn.classList.add('btn-primary');
         document.getElementById('waitingEdits').textContent = '';
+        document.getElementById('waitingAgentStatus').textContent = '';
         waitingOverlay.classList.remove('active');
         break;
       case 'waiting':
-        finishBtn.textContent = 'Waiting...';
+        finishBtn.textContent = t('waiting');
         finishBtn.disabled = true;
         finishBtn.classList.remove('btn-primary');
         document.getElementById('waitingEdits').textContent = '';
+        document.getElementById('waitingAgentStatus').textContent = '';
         document.getElementById('waitingPrompt').style.display = '';
         document.getElementById('waitingClipboard').style.display = '';
         waitingOverlay.classList.add('active');
@@ -6171,6 +6887,52 @@
     }
   }
 
+  // ===== Round-complete handshake =====
+  // The agent's round-complete waits for this tab's ack: loading the next
+  // round re-renders every file, which would throw away a comment that's
+  // still being written. With none open the ack goes out right away.
+  let pendingRoundId = null;
+  let pendingRoundTimer = null;
+
+  function hasUnsavedEdits() {
+    return activeForms.length > 0 || activeReplyForms.size > 0 || reviewCommentFormActive;
+  }
+
+  function handleRoundPending(id) {
+    if (!id || id === pendingRoundId) return;
+    pendingRoundId = id;
+    if (!hasUnsavedEdits()) {
+      ackPendingRound();
+      return;
+    }
+    const el = showToast('round', 'info',
+      '<span>Your agent finished its changes. The next round loads once your open comments are saved or cancelled.</span>' +
+      '<div class="toast-actions">' +
+        '<button class="toast-btn toast-btn-filled" id="roundLoadNowBtn">Load now</button>' +
+      '</div>');
+    el.querySelector('#roundLoadNowBtn').addEventListener('click', ackPendingRound);
+    clearInterval(pendingRoundTimer);
+    pendingRoundTimer = setInterval(function() {
+      if (!hasUnsavedEdits()) ackPendingRound();
+    }, 1000);
+  }
+
+  async function ackPendingRound() {
+    const id = pendingRoundId;
+    if (!id) return;
+    pendingRoundId = null;
+    clearInterval(pendingRoundTimer);
+    dismissToast('round');
+    try {
+      // A 409 means another tab already acknowledged it.
+      await fetch('/api/round-complete/ack', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ round_id: id }),
+      });
+    } catch { /* the next SSE connect replays a still-pending round */ }
+  }
+
   // ===== General Comment Button (in panel header) =====
   document.getElementById('panelAddCommentBtn').addEventListener('click', openReviewCommentForm);
 
@@ -6190,13 +6952,13 @@
           'Your agent has been notified. Waiting for updates\u2026' +
           '<span class="waiting-fallback">If your agent wasn\u2019t listening, paste the prompt below.</span>';
         const clipEl = document.getElementById('waitingClipboard');
-        clipEl.textContent = 'Copy prompt';
+        clipEl.textContent = t('copy_prompt');
         clipEl.classList.remove('clipboard-confirm');
       } else {
         document.getElementById('waitingMessage').textContent =
           'You can close this browser tab, or leave it open for another round.';
         const clipEl = document.getElementById('waitingClipboard');
-        clipEl.textContent = 'Copy prompt';
+        clipEl.textContent = t('copy_prompt');
         clipEl.classList.remove('clipboard-confirm');
       }
 
@@ -6299,15 +7061,15 @@
     try {
       await navigator.clipboard.writeText(prompt);
       const el = document.getElementById('waitingClipboard');
-      el.textContent = '\u2713 Copied';
-      el.setAttribute('aria-label', 'Copied');
+      el.textContent = t('copied');
+      el.setAttribute('aria-label', t('copied_label'));
       announceCopy();
       el.classList.remove('clipboard-confirm');
       void el.offsetWidth;
       el.classList.add('clipboard-confirm');
       setTimeout(function() {
-        el.textContent = 'Copy prompt';
-        el.setAttribute('aria-label', 'Copy prompt');
+        el.textContent = t('copy_prompt');
+        el.setAttribute('aria-label', t('copy_prompt'));
       }, 2000);
     } catch {}
   });
@@ -6404,25 +7166,40 @@
           if (promptEl) promptEl.style.display = 'none';
           if (clipEl) clipEl.style.display = 'none';
           if (waitingHasComments) {
-            document.getElementById('waitingMessage').textContent = 'Waiting for your agent to finish...';
+            document.getElementById('waitingMessage').textContent = t('waiting_for_agent');
           }
         }
       } catch {}
     });
 
+    source.addEventListener('round-pending', function(e) {
+      try {
+        handleRoundPending(JSON.parse(e.data).content);
+      } catch {}
+    });
+
+    // Progress the agent reports via POST /api/agent/status.
+    source.addEventListener('agent-status', function(e) {
+      try {
+        const data = JSON.parse(e.data);
+        const el = document.getElementById('waitingAgentStatus');
+        if (el && uiState === 'waiting') el.textContent = data.content || '';
+      } catch {}
+    });
+
     source.addEventListener('comments-changed', async function() {
       try {
         // Only re-fetch comments data, not file content or diffs (those only
         // change on file-changed events). This reduces O(3N) to O(N) requests.
         await Promise.all(files.map(async function(f) {
-          return fetch('/api/file/comments?path=' + enc(f.path))
+          return fetch('/api/file/comments?path=' + enc(f.path) + '&all=true')
             .then(function(r) { return r.ok ? r.json() : []; })
             .then(function(comments) { f.comments = Array.isArray(comments) ? comments : []; })
             .catch(function() { /* ignore fetch errors */ });
         }));
         // Also refresh review-level comments
         try {
-          const rcRes = await fetch('/api/comments');
+          const rcRes = await fetch('/api/comments?all=true');
           if (rcRes.ok) reviewComments = await rcRes.json();
         } catch {}
         // Save form drafts and focused element before re-render
@@ -6498,15 +7275,15 @@
   function setShareButtonState(state) {
     const btn = document.getElementById('shareBtn');
     if (state === 'shared') {
-      btn.textContent = 'Shared';
+      btn.textContent = t('shared');
       btn.classList.add('btn-success');
       btn.disabled = false;
     } else if (state === 'sharing') {
-      btn.textContent = 'Sharing\u2026';
+      btn.textContent = t('sharing');
       btn.classList.remove('btn-success');
       btn.disabled = true;
     } else {
-      btn.textContent = 'Share';
+      btn.textContent = t('share');
       btn.classList.remove('btn-success');
       btn.disabled = false;
     }
@@ -6537,6 +7314,7 @@
             ICON_CLIPBOARD +
           '</button>' +
         '</div>' +
+        (shareExpiresAt ? '<p class="share-dialog-expiry">Expires ' + escapeHtml(new Date(shareExpiresAt).toLocaleString()) + '</p>' : '') +
         '<div class="share-dialog-actions">' +
           (deleteToken ? '<button class="btn btn-sm btn-danger" id="modalUnpublishBtn">Unpublish</button>' : '') +
           '<button class="btn btn-sm" id="modalCloseBtn">Close</button>' +
@@ -6603,7 +7381,7 @@
 
   async function handleUnpublish() {
     const btn = document.getElementById('confirmUnpublishBtn');
-    if (btn) { btn.textContent = 'Unpublishing\u2026'; btn.disabled = true; }
+    if (btn) { btn.textContent = t('unpublishing'); btn.disabled = true; }
     try {
       const resp = await fetch(shareURL + '/api/reviews', {
         method: 'DELETE',
@@ -6614,6 +7392,7 @@
       if (!alreadyDeleted && !resp.ok) throw new Error('Server error ' + resp.status);
       hostedURL = '';
       deleteToken = '';
+      shareExpiresAt = '';
       fetch('/api/share-url', { method: 'DELETE' }).catch(function() { /* fire-and-forget */ });
       closeShareModal();
       setShareButtonState('default');
@@ -6655,6 +7434,7 @@
       const result = await resp.json();
       hostedURL = result.url;
       deleteToken = result.delete_token || '';
+      shareExpiresAt = result.expires_at || '';
       setShareButtonState('shared');
       showShareModal();
     } catch (err) {
@@ -6675,7 +7455,7 @@
   // Announce copy action to screen readers via live region
   function announceCopy() {
     const el = document.getElementById('copyStatus');
-    if (el) { el.textContent = ''; el.textContent = 'Copied to clipboard'; }
+    if (el) { el.textContent = ''; el.textContent = t('copied_to_clipboard'); }
   }
 
   // ===== Toast System =====
@@ -6932,7 +7712,7 @@
       if (sel && label) label.textContent = sel.short_sha + ' ' + (sel.message.length > 30 ? sel.message.slice(0, 30) + '\u2026' : sel.message);
     } else {
       if (allItem) allItem.classList.add('active');
-      if (label) label.textContent = 'All commits';
+      if (label) label.textContent = t('all_commits');
     }
 
     list.innerHTML = commitList.map(function(c) {
@@ -7590,13 +8370,13 @@
       btn.addEventListener('click', function() {
         const text = btn.dataset.copy;
         navigator.clipboard.writeText(text).then(function() {
-          btn.textContent = '\u2713 Copied';
-          btn.setAttribute('aria-label', 'Copied');
+          btn.textContent = t('copied');
+          btn.setAttribute('aria-label', t('copied_label'));
           announceCopy();
           btn.classList.add('copied');
           setTimeout(function() {
-            btn.textContent = 'Copy';
-            btn.setAttribute('aria-label', 'Copy');
+            btn.textContent = t('copy');
+            btn.setAttribute('aria-label', t('copy'));
             btn.classList.remove('copied');
           }, 1500);
         });
@@ -7623,6 +8403,8 @@
         { key: '<kbd>d</kbd>', action: 'Delete comment on focused block' },
         { key: '<kbd>G</kbd>', action: 'General comment' },
         { key: '<kbd>Ctrl</kbd>+<kbd>Enter</kbd>', action: 'Submit comment' },
+        { key: '<kbd>Ctrl</kbd>+<kbd>Z</kbd>', action: 'Undo last comment action' },
+        { key: '<kbd>Ctrl</kbd>+<kbd>Shift</kbd>+<kbd>Z</kbd>', action: 'Redo' },
       ]},
       { label: 'Review', shortcuts: [
         { key: '<kbd>Shift</kbd>+<kbd>F</kbd>', action: 'Finish review' },
@@ -7766,6 +8548,12 @@
       return;
     }
 
+    if ((e.metaKey || e.ctrlKey) && !e.altKey && (e.key.toLowerCase() === 'z' || e.key === 'y')) {
+      e.preventDefault();
+      stepCommentHistory(e.shiftKey || e.key === 'y' ? 'redo' : 'undo');
+      return;
+    }
+
     if (e.metaKey || e.ctrlKey || e.altKey) return;
 
     switch (e.key) {
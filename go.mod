@@ -3,7 +3,9 @@ module github.com/JoshEllinger/crit
 go 1.26.0
 
 require (
+	github.com/fsnotify/fsnotify v1.10.1
 	github.com/mdp/qrterminal/v3 v3.2.1
+	github.com/yuin/goldmark v1.8.6
 	golang.org/x/term v0.13.0
 	rsc.io/qr v0.2.0
 )
@@ -0,0 +1,52 @@
+package critcore
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The daemon makes a random access token per session and only answers
+// requests that carry it: ?token= on the URL crit opens in the browser, the
+// cookie the server sets from it, or "Authorization: Bearer <token>". The
+// CLI reads the token from the session file (readable only by the user),
+// and agents from `crit status --json`. Loopback binding keeps other
+// machines out; the token also keeps out other local users and pages, and
+// is what a LAN or tunnel mode would rely on.
+
+// accessTokenCookie names the cookie holding the token. Cookies aren't
+// scoped by port, so each daemon gets its own.
+func accessTokenCookie(port int) string {
+	return fmt.Sprintf("crit_token_%d", port)
+}
+
+// admitToken answers 401 on w for requests without the access token. A valid
+// ?token= also sets the cookie, so the page's own requests carry it.
+func (s *Server) admitToken(w http.ResponseWriter, r *http.Request) bool {
+	if s.accessToken == "" {
+		return true
+	}
+	if c, err := r.Cookie(accessTokenCookie(s.port)); err == nil && s.validToken(c.Value) {
+		return true
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && s.validToken(bearer) {
+		return true
+	}
+	if s.validToken(r.URL.Query().Get("token")) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     accessTokenCookie(s.port),
+			Value:    s.accessToken,
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+		return true
+	}
+	http.Error(w, "Unauthorized: open the review with the URL crit printed, which carries the session's token", http.StatusUnauthorized)
+	return false
+}
+
+func (s *Server) validToken(token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.accessToken)) == 1
+}
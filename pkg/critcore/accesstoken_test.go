@@ -0,0 +1,60 @@
+package critcore
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_AccessToken(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.accessToken = "secret"
+	get := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"no token", "/api/session", nil, 401},
+		{"page without token", "/", nil, 401},
+		{"wrong token", "/api/session?token=guess", nil, 401},
+		{"bearer", "/api/session", map[string]string{"Authorization": "Bearer secret"}, 200},
+		{"cookie", "/api/session", map[string]string{"Cookie": accessTokenCookie(s.port) + "=secret"}, 200},
+		{"another daemon's cookie", "/api/session", map[string]string{"Cookie": accessTokenCookie(s.port+1) + "=secret"}, 401},
+	}
+	for _, tt := range tests {
+		if got := get(tt.path, tt.headers).Code; got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// The URL crit opens trades the query token for a cookie.
+	w := get("/api/session?token=secret", nil)
+	if w.Code != 200 {
+		t.Fatalf("query token: status = %d", w.Code)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != accessTokenCookie(s.port) || cookies[0].Value != "secret" || !cookies[0].HttpOnly {
+		t.Errorf("cookies = %v", cookies)
+	}
+}
+
+func TestSessionEntryURL(t *testing.T) {
+	e := sessionEntry{Port: 3100}
+	if got := e.url(""); got != "http://localhost:3100" {
+		t.Errorf("url without token = %q", got)
+	}
+	e.Token = "abc"
+	if got := e.url("/api/health"); got != "http://localhost:3100/api/health?token=abc" {
+		t.Errorf("url = %q", got)
+	}
+}
@@ -0,0 +1,134 @@
+package critcore
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// exportInline is the output_formats value that writes annotated copies of
+// the reviewed files into <review>.review.annotated/, mirroring their paths.
+const exportInline = "inline"
+
+// annotatedExportDir returns the directory annotated copies are written to for
+// the review file at critPath.
+func annotatedExportDir(critPath string) string {
+	return strings.TrimSuffix(critPath, ".json") + ".review.annotated"
+}
+
+// annotationTarget is a review file entry with its on-disk location.
+type annotationTarget struct {
+	path    string // repo-relative, as in the review file
+	absPath string
+}
+
+// writeAnnotatedExport rewrites the annotated copies for cj when the inline
+// format is enabled. The directory is rebuilt from scratch each time so files
+// whose comments were all resolved or deleted disappear from it.
+func (s *Session) writeAnnotatedExport(critPath string, cj CritJSON) {
+	enabled := false
+	for _, f := range s.exportFormats {
+		enabled = enabled || f == exportInline
+	}
+	if !enabled {
+		return
+	}
+
+	s.mu.RLock()
+	var targets []annotationTarget
+	for _, f := range s.Files {
+		if f.Status != "deleted" && !f.Orphaned {
+			targets = append(targets, annotationTarget{path: f.Path, absPath: f.AbsPath})
+		}
+	}
+	s.mu.RUnlock()
+
+	dir := annotatedExportDir(critPath)
+	if err := os.RemoveAll(dir); err != nil {
+		slog.Warn("clearing annotated review", "err", err)
+		return
+	}
+	for _, t := range targets {
+		comments := openComments(cj.Files[t.path].Comments)
+		if len(comments) == 0 {
+			continue
+		}
+		content, err := os.ReadFile(t.absPath)
+		if err != nil {
+			continue
+		}
+		out := filepath.Join(dir, filepath.FromSlash(t.path))
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			slog.Warn("writing annotated review", "err", err)
+			return
+		}
+		if err := atomicWriteFile(out, []byte(annotateContent(string(content), comments)), 0644); err != nil {
+			slog.Warn("writing annotated review", "err", err)
+		}
+	}
+}
+
+func openComments(comments []Comment) []Comment {
+	var open []Comment
+	for _, c := range comments {
+		if !c.Resolved {
+			open = append(open, c)
+		}
+	}
+	return open
+}
+
+// annotateContent returns content with each comment inserted as an HTML
+// comment on its own line right after the comment's last line. File-level
+// comments, comments on removed lines and comments past the end of the file
+// are placed at the top.
+func annotateContent(content string, comments []Comment) string {
+	lines := strings.Split(content, "\n")
+	trailingNewline := len(lines) > 1 && lines[len(lines)-1] == ""
+	if trailingNewline {
+		lines = lines[:len(lines)-1]
+	}
+
+	sorted := append([]Comment(nil), comments...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartLine < sorted[j].StartLine })
+	var header []string
+	after := make(map[int][]string)
+	for _, c := range sorted {
+		switch {
+		case c.Side == "old" && c.EndLine > 0:
+			header = append(header, annotation(c, fmt.Sprintf(" (removed line %s)", lineSpan(c))))
+		case c.Scope == "file" || c.EndLine < 1 || c.EndLine > len(lines):
+			header = append(header, annotation(c, ""))
+		default:
+			after[c.EndLine] = append(after[c.EndLine], annotation(c, ""))
+		}
+	}
+
+	out := append([]string(nil), header...)
+	for i, line := range lines {
+		out = append(out, line)
+		out = append(out, after[i+1]...)
+	}
+	result := strings.Join(out, "\n")
+	if trailingNewline {
+		result += "\n"
+	}
+	return result
+}
+
+// annotation renders c as `<!-- crit <id>: <body> -->`. A "-->" in the body
+// would end the HTML comment early, so it is escaped.
+func annotation(c Comment, note string) string {
+	body := strings.ReplaceAll(strings.TrimSpace(c.Body), "-->", "--&gt;")
+	return fmt.Sprintf("<!-- crit %s%s: %s -->", c.ID, note, body)
+}
+
+func lineSpan(c Comment) string {
+	if c.EndLine > c.StartLine {
+		return fmt.Sprintf("%d-%d", c.StartLine, c.EndLine)
+	}
+	return fmt.Sprintf("%d", c.StartLine)
+}
@@ -0,0 +1,59 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnnotateContent(t *testing.T) {
+	content := "one\ntwo\nthree\n"
+	comments := []Comment{
+		{ID: "c2", StartLine: 2, EndLine: 3, Body: "span --> here"},
+		{ID: "c1", StartLine: 1, EndLine: 1, Body: " first "},
+		{ID: "c3", Scope: "file", Body: "whole file"},
+		{ID: "c4", StartLine: 4, EndLine: 5, Side: "old", Body: "was removed"},
+		{ID: "c5", StartLine: 1, EndLine: 1, Body: "also first"},
+	}
+	want := "<!-- crit c3: whole file -->\n" +
+		"<!-- crit c4 (removed line 4-5): was removed -->\n" +
+		"one\n" +
+		"<!-- crit c1: first -->\n" +
+		"<!-- crit c5: also first -->\n" +
+		"two\n" +
+		"three\n" +
+		"<!-- crit c2: span --&gt; here -->\n"
+	if got := annotateContent(content, comments); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAnnotateContent_NoTrailingNewline(t *testing.T) {
+	got := annotateContent("a\nb", []Comment{{ID: "c1", StartLine: 2, EndLine: 2, Body: "x"}})
+	if want := "a\nb\n<!-- crit c1: x -->"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWriteAnnotatedExport(t *testing.T) {
+	_, sess := newTestServer(t)
+	sess.exportFormats = []string{exportInline}
+	c, _ := sess.AddComment("test.md", 2, 2, "", "fix", "", "")
+	sess.WriteFiles()
+
+	out := filepath.Join(annotatedExportDir(sess.critJSONPath()), "test.md")
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("annotated copy not written: %v", err)
+	}
+	if want := "line1\nline2\n<!-- crit " + c.ID + ": fix -->\nline3\n"; string(data) != want {
+		t.Errorf("annotated = %q, want %q", data, want)
+	}
+
+	// Resolving the only comment drops the file from the annotated copy.
+	sess.SetCommentResolved("test.md", c.ID, true)
+	sess.WriteFiles()
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Error("resolved file should be removed from the annotated copy")
+	}
+}
@@ -0,0 +1,34 @@
+package critcore
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// ReviewPath returns the path of the session's review file, the file
+// WriteFiles writes.
+func (s *Session) ReviewPath() string {
+	return s.critJSONPath()
+}
+
+// LoadReview reads the review file at path.
+func LoadReview(path string) (CritJSON, error) {
+	var cj CritJSON
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cj, err
+	}
+	if err := unmarshalCritJSON(data, &cj); err != nil {
+		return cj, fmt.Errorf("invalid review file %s: %w", path, err)
+	}
+	return cj, nil
+}
+
+// WriteReviewExports writes review, the content of the review file at
+// reviewPath, in each of formats (the output_formats config values: "json",
+// "yaml", "inline", "history") next to it, with times in the local zone.
+// Failures are logged, not returned: exports never block a save.
+func WriteReviewExports(reviewPath string, review CritJSON, formats []string) {
+	writeReviewExports(reviewPath, review, formats, time.Local)
+}
@@ -0,0 +1,56 @@
+package critcore_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/JoshEllinger/crit/pkg/critcore"
+)
+
+// TestAPI drives a review through the stable API only, as an importer would.
+func TestAPI(t *testing.T) {
+	dir := t.TempDir()
+	plan := filepath.Join(dir, "plan.md")
+	if err := os.WriteFile(plan, []byte("# Plan\n\nStep 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	session, err := critcore.NewSessionFromFiles([]string{plan}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.ReviewFilePath = filepath.Join(dir, "review.json")
+	defer session.Shutdown()
+
+	path := session.Files[0].Path // relative to the repo the test runs in, if any
+	c, ok := session.AddComment(path, 3, 3, "", "which step?", "", "reviewer")
+	if !ok {
+		t.Fatal("AddComment failed")
+	}
+	session.WriteFiles()
+
+	review, err := critcore.LoadReview(session.ReviewPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := review.Files[path].Comments; len(got) != 1 || got[0].ID != c.ID || got[0].Body != "which step?" {
+		t.Errorf("review file comments = %+v", got)
+	}
+
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := critcore.NewServer(session, os.DirFS(root), "", "", "", "test", 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/file/comments?path="+url.QueryEscape(path), nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /api/file/comments: status %d", w.Code)
+	}
+}
@@ -0,0 +1,318 @@
+package critcore
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Round verdicts recorded in the archive.
+const (
+	verdictApproved         = "approved"
+	verdictChangesRequested = "changes_requested"
+)
+
+// maxArchivedRounds is how many rounds of one review are kept; older rounds
+// are pruned as new ones are archived.
+const maxArchivedRounds = 100
+
+// roundRecord is one finished review round as archived under
+// <storage root>/history/<hash of the review file path>/round-NNN.json.
+type roundRecord struct {
+	Round          int         `json:"round"`
+	FinishedAt     string      `json:"finished_at"`
+	Verdict        string      `json:"verdict"`
+	Mode           string      `json:"mode"`
+	Branch         string      `json:"branch,omitempty"`
+	BaseRef        string      `json:"base_ref,omitempty"`
+	RepoRoot       string      `json:"repo_root,omitempty"`
+	ReviewComments []Comment   `json:"review_comments,omitempty"`
+	Files          []roundFile `json:"files"`
+}
+
+// roundFile is a file's content and comments at the end of a round.
+type roundFile struct {
+	Path     string    `json:"path"`
+	AbsPath  string    `json:"abs_path"`
+	Content  string    `json:"content"`
+	Comments []Comment `json:"comments"`
+}
+
+// reviewHistoryDir returns the directory holding the archived rounds of the
+// review file critPath, keyed like its backups and journal so the code that
+// removes a review file can remove them too.
+func reviewHistoryDir(critPath string) (string, error) {
+	root, err := storageRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "history", computeFileHash([]byte(critPath))[:16]), nil
+}
+
+// removeReviewHistory deletes the archived rounds of the review file critPath.
+func removeReviewHistory(critPath string) {
+	if dir, err := reviewHistoryDir(critPath); err == nil {
+		os.RemoveAll(dir)
+	}
+}
+
+// pruneArchivedRounds deletes all but the newest keep rounds in dir.
+func pruneArchivedRounds(dir string, keep int) error {
+	matches, err := filepath.Glob(filepath.Join(dir, "round-*.json"))
+	if err != nil {
+		return err
+	}
+	type archived struct {
+		path  string
+		round int
+	}
+	var rounds []archived
+	for _, path := range matches {
+		var n int
+		if _, err := fmt.Sscanf(filepath.Base(path), "round-%d.json", &n); err == nil {
+			rounds = append(rounds, archived{path, n})
+		}
+	}
+	if len(rounds) <= keep {
+		return nil
+	}
+	// round-%03d stops sorting by name past round 999, so sort by number.
+	slices.SortFunc(rounds, func(a, b archived) int { return b.round - a.round })
+	for _, r := range rounds[keep:] {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveRound records the session's state at the end of a round. Files mode
+// archives every file; git mode only the files that were commented on, to keep
+// large diffs out of the archive. No-op when archiving isn't set up.
+func (s *Session) archiveRound(verdict string) {
+	if s.archiveDir == "" {
+		return
+	}
+	rec := s.roundRecord(verdict)
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		slog.Warn("archiving round", "err", err)
+		return
+	}
+	path := filepath.Join(s.archiveDir, fmt.Sprintf("round-%03d.json", rec.Round))
+	if err := atomicWriteFile(path, data, 0644); err != nil {
+		slog.Warn("archiving round", "err", err)
+		return
+	}
+	if err := pruneArchivedRounds(s.archiveDir, maxArchivedRounds); err != nil {
+		slog.Warn("pruning archived rounds", "err", err)
+	}
+}
+
+func (s *Session) roundRecord(verdict string) roundRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	links := s.commentLinksLocked()
+	rec := roundRecord{
+		Round:          max(s.ReviewRound, 1),
+		FinishedAt:     time.Now().UTC().Format(time.RFC3339),
+		Verdict:        verdict,
+		Mode:           s.Mode,
+		Branch:         s.Branch,
+		BaseRef:        s.BaseRef,
+		RepoRoot:       s.RepoRoot,
+		ReviewComments: append([]Comment(nil), s.reviewComments...),
+		Files:          []roundFile{},
+	}
+	for _, f := range s.Files {
+		if f.Orphaned || (s.Mode == "git" && len(f.Comments) == 0) {
+			continue
+		}
+		content := f.Content
+		if f.Lazy {
+			if data, err := os.ReadFile(f.AbsPath); err == nil {
+				content = string(data)
+			}
+		}
+		comments := append([]Comment{}, f.Comments...)
+		links.apply(comments)
+		rec.Files = append(rec.Files, roundFile{
+			Path:     f.Path,
+			AbsPath:  f.AbsPath,
+			Content:  content,
+			Comments: comments,
+		})
+	}
+	links.apply(rec.ReviewComments)
+	return rec
+}
+
+// archivedRound pairs a record with the archive file it was read from.
+type archivedRound struct {
+	path string
+	rec  roundRecord
+}
+
+// findArchivedRounds returns every archived round that includes absPath,
+// oldest first. Both the active and the legacy storage layouts are searched.
+func findArchivedRounds(absPath string) []archivedRound {
+	var roots []string
+	if root, err := storageRoot(); err == nil {
+		roots = append(roots, root)
+	}
+	if legacy := legacyStorageRoot(); legacy != "" {
+		roots = append(roots, legacy)
+	}
+	var found []archivedRound
+	for _, root := range roots {
+		matches, _ := filepath.Glob(filepath.Join(root, "history", "*", "round-*.json"))
+		for _, path := range matches {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			var rec roundRecord
+			if json.Unmarshal(data, &rec) != nil {
+				continue
+			}
+			if rec.file(absPath) != nil {
+				found = append(found, archivedRound{path: path, rec: rec})
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].rec.FinishedAt < found[j].rec.FinishedAt
+	})
+	return found
+}
+
+// file returns the archived entry for absPath, or nil.
+func (r *roundRecord) file(absPath string) *roundFile {
+	for i := range r.Files {
+		if r.Files[i].AbsPath == absPath {
+			return &r.Files[i]
+		}
+	}
+	return nil
+}
+
+func runHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	round := fs.Int("round", 0, "Dump the given round (1-based index into the list)")
+	jsonOutput := fs.Bool("json", false, "Print as JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: crit history [--round N] [--json] <file>")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Lists the archived review rounds that included <file>, or with --round,")
+		fmt.Fprintln(os.Stderr, "prints that round's content snapshot and comments.")
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	absPath, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	rounds := findArchivedRounds(absPath)
+	if resolved, err := filepath.EvalSymlinks(absPath); len(rounds) == 0 && err == nil && resolved != absPath {
+		absPath = resolved
+		rounds = findArchivedRounds(absPath)
+	}
+	if len(rounds) == 0 {
+		fmt.Fprintf(os.Stderr, "No archived rounds for %s\n", fs.Arg(0))
+		os.Exit(1)
+	}
+	if *round == 0 {
+		printHistoryList(rounds, absPath, *jsonOutput)
+		return
+	}
+	if *round < 1 || *round > len(rounds) {
+		fmt.Fprintf(os.Stderr, "Error: --round must be between 1 and %d\n", len(rounds))
+		os.Exit(1)
+	}
+	printHistoryRound(rounds[*round-1].rec, absPath, *jsonOutput)
+}
+
+func printHistoryList(rounds []archivedRound, absPath string, jsonOutput bool) {
+	if jsonOutput {
+		type listEntry struct {
+			Index      int    `json:"index"`
+			Round      int    `json:"round"`
+			FinishedAt string `json:"finished_at"`
+			Verdict    string `json:"verdict"`
+			Comments   int    `json:"comments"`
+			Open       int    `json:"open"`
+			Archive    string `json:"archive"`
+		}
+		list := make([]listEntry, 0, len(rounds))
+		for i, r := range rounds {
+			total, open := countOpenComments(r.rec.file(absPath).Comments)
+			list = append(list, listEntry{i + 1, r.rec.Round, r.rec.FinishedAt, r.rec.Verdict, total, open, r.path})
+		}
+		data, _ := json.MarshalIndent(list, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	for i, r := range rounds {
+		total, open := countOpenComments(r.rec.file(absPath).Comments)
+		fmt.Printf("%3d  round %-3d %s  %-17s %d comment%s (%d open)\n",
+			i+1, r.rec.Round, r.rec.FinishedAt, r.rec.Verdict, total, plural(total), open)
+	}
+	fmt.Println("\nRun 'crit history --round N <file>' to print a round.")
+}
+
+func printHistoryRound(rec roundRecord, absPath string, jsonOutput bool) {
+	f := rec.file(absPath)
+	if jsonOutput {
+		data, _ := json.MarshalIndent(map[string]any{
+			"round":       rec.Round,
+			"finished_at": rec.FinishedAt,
+			"verdict":     rec.Verdict,
+			"path":        f.Path,
+			"content":     f.Content,
+			"comments":    f.Comments,
+		}, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("%s — round %d, %s, %s\n\n", f.Path, rec.Round, rec.FinishedAt, rec.Verdict)
+	fmt.Print(f.Content)
+	if !strings.HasSuffix(f.Content, "\n") {
+		fmt.Println()
+	}
+	fmt.Println()
+	if len(f.Comments) == 0 {
+		fmt.Println("No comments.")
+		return
+	}
+	fmt.Println("Comments:")
+	for _, c := range f.Comments {
+		state := "open"
+		if c.Resolved {
+			state = "resolved"
+		}
+		fmt.Printf("  %s %s [%s]: %s\n", compactLocation(c), c.ID, state, oneLine(expandCommentRefs(c.Body, c.Refs)))
+		for _, r := range c.Replies {
+			fmt.Printf("      %s: %s\n", r.Author, oneLine(r.Body))
+		}
+	}
+}
+
+func countOpenComments(comments []Comment) (total, open int) {
+	for _, c := range comments {
+		if !c.Resolved {
+			open++
+		}
+	}
+	return len(comments), open
+}
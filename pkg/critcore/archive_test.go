@@ -0,0 +1,102 @@
+package critcore
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFinish_ArchivesRound(t *testing.T) {
+	storage := t.TempDir()
+	withProjectStorage(t, storage)
+	s, session := newTestServer(t)
+	session.archiveDir, _ = reviewHistoryDir(session.critJSONPath())
+	absPath := session.Files[0].AbsPath
+
+	session.AddComment("test.md", 1, 1, "", "fix this", "", "")
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/finish", nil))
+
+	rounds := findArchivedRounds(absPath)
+	if len(rounds) != 1 {
+		t.Fatalf("got %d archived rounds, want 1", len(rounds))
+	}
+	rec := rounds[0].rec
+	if rec.Round != 1 || rec.Verdict != verdictChangesRequested {
+		t.Errorf("round=%d verdict=%q", rec.Round, rec.Verdict)
+	}
+	f := rec.file(absPath)
+	if f.Content != "line1\nline2\nline3\n" || len(f.Comments) != 1 {
+		t.Errorf("archived file = %+v", f)
+	}
+	if want := filepath.Join(session.archiveDir, "round-001.json"); rounds[0].path != want {
+		t.Errorf("archive path = %q, want %q", rounds[0].path, want)
+	}
+
+	// A later round is archived alongside, and approval is recorded.
+	session.mu.Lock()
+	session.ReviewRound = 2
+	session.Files[0].Comments[0].Resolved = true
+	session.mu.Unlock()
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/finish", nil))
+
+	rounds = findArchivedRounds(absPath)
+	if len(rounds) != 2 || rounds[1].rec.Round != 2 || rounds[1].rec.Verdict != verdictApproved {
+		t.Fatalf("rounds = %+v", rounds)
+	}
+}
+
+func TestArchiveRound_PrunesAndClears(t *testing.T) {
+	withProjectStorage(t, t.TempDir())
+	_, session := newTestServer(t)
+	critPath := session.critJSONPath()
+	session.archiveDir, _ = reviewHistoryDir(critPath)
+	for round := 1; round <= maxArchivedRounds+2; round++ {
+		session.ReviewRound = round
+		session.archiveRound(verdictChangesRequested)
+	}
+
+	rounds := findArchivedRounds(session.Files[0].AbsPath)
+	if len(rounds) != maxArchivedRounds {
+		t.Fatalf("got %d archived rounds, want %d", len(rounds), maxArchivedRounds)
+	}
+	if _, err := os.Stat(filepath.Join(session.archiveDir, "round-001.json")); !os.IsNotExist(err) {
+		t.Error("oldest round should have been pruned")
+	}
+
+	// Removing the review file removes its history with it.
+	if err := clearCritJSON(filepath.Dir(critPath)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(session.archiveDir); !os.IsNotExist(err) {
+		t.Errorf("history dir still exists after --clear: %v", err)
+	}
+}
+
+func TestArchiveRound_DisabledWithoutDir(t *testing.T) {
+	storage := t.TempDir()
+	withProjectStorage(t, storage)
+	_, session := newTestServer(t)
+	session.archiveRound(verdictApproved)
+	if got := findArchivedRounds(session.Files[0].AbsPath); len(got) != 0 {
+		t.Errorf("expected no archive without archiveDir, got %d", len(got))
+	}
+}
+
+func TestRoundRecord_GitModeOnlyCommentedFiles(t *testing.T) {
+	session := &Session{
+		Mode:        "git",
+		ReviewRound: 3,
+		Files: []*FileEntry{
+			{Path: "a.go", AbsPath: "/r/a.go", Content: "a", Comments: []Comment{{ID: "c1"}}},
+			{Path: "b.go", AbsPath: "/r/b.go", Content: "b"},
+		},
+	}
+	rec := session.roundRecord(verdictChangesRequested)
+	if len(rec.Files) != 1 || rec.Files[0].Path != "a.go" {
+		t.Errorf("files = %+v", rec.Files)
+	}
+	if rec.file("/r/b.go") != nil {
+		t.Error("uncommented git file should not be archived")
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"bufio"
@@ -315,7 +315,7 @@ func runAuthLogout(args []string) {
 		return
 	}
 
-	if _, ok := os.LookupEnv("CRIT_AUTH_TOKEN"); ok {
+	if _, ok := os.LookupEnv(envAuthToken); ok {
 		fmt.Fprintln(os.Stderr, "  Token is set via CRIT_AUTH_TOKEN environment variable and cannot be cleared by logout.")
 		return
 	}
@@ -0,0 +1,275 @@
+package critcore
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A bad write or an accidental bulk delete used to leave the review file with
+// no way back. Before the daemon overwrites or removes the review file, it
+// copies the file on disk to <storage root>/backups/<hash of the review file
+// path>/<UTC time>.json: when the newest backup is older than backupInterval,
+// or right away when the write drops comments. Only the newest `backups`
+// copies are kept. `crit comment --clear` backs up too, and `crit restore`
+// lists the backups and puts one back.
+
+const (
+	defaultBackups = 10
+	backupInterval = 10 * time.Minute
+	// backupTimeLayout sorts in time order; the milliseconds keep two
+	// backups taken in the same second apart.
+	backupTimeLayout = "20060102T150405.000Z"
+)
+
+// reviewBackup is one backup of a review file.
+type reviewBackup struct {
+	path string
+	time time.Time
+}
+
+// reviewBackupDir returns the directory holding the backups of the review
+// file critPath.
+func reviewBackupDir(critPath string) (string, error) {
+	root, err := storageRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "backups", computeFileHash([]byte(critPath))[:16]), nil
+}
+
+// listReviewBackups returns the backups of the review file critPath, newest
+// first.
+func listReviewBackups(critPath string) ([]reviewBackup, error) {
+	dir, err := reviewBackupDir(critPath)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var backups []reviewBackup
+	for _, e := range entries {
+		t, err := time.Parse(backupTimeLayout, strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil || e.IsDir() {
+			continue
+		}
+		backups = append(backups, reviewBackup{path: filepath.Join(dir, e.Name()), time: t})
+	}
+	slices.SortFunc(backups, func(a, b reviewBackup) int { return b.time.Compare(a.time) })
+	return backups, nil
+}
+
+// removeReviewBackups deletes the backups of the review file critPath.
+func removeReviewBackups(critPath string) {
+	if dir, err := reviewBackupDir(critPath); err == nil {
+		os.RemoveAll(dir)
+	}
+}
+
+// backupReviewFile copies the review file critPath into its backups, unless
+// it doesn't exist or matches the newest backup, then prunes all but the
+// newest keep. Unless force is set, it only does so when the newest backup is
+// older than backupInterval or the file has more comments than next, the
+// content about to replace it. It returns whether a backup was made.
+func backupReviewFile(critPath string, next *CritJSON, keep int, force bool) (bool, error) {
+	if keep <= 0 {
+		return false, nil
+	}
+	data, err := os.ReadFile(critPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	backups, err := listReviewBackups(critPath)
+	if err != nil {
+		return false, err
+	}
+	if len(backups) > 0 {
+		if newest, err := os.ReadFile(backups[0].path); err == nil && bytes.Equal(newest, data) {
+			return false, nil
+		}
+	}
+	if !force && len(backups) > 0 && time.Since(backups[0].time) < backupInterval && !dropsComments(data, next) {
+		return false, nil
+	}
+	dir, err := reviewBackupDir(critPath)
+	if err != nil {
+		return false, err
+	}
+	name := time.Now().UTC().Format(backupTimeLayout) + ".json"
+	if err := atomicWriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+		return false, err
+	}
+	return true, pruneReviewBackups(critPath, keep)
+}
+
+// pruneReviewBackups deletes all but the newest keep backups of the review
+// file critPath.
+func pruneReviewBackups(critPath string, keep int) error {
+	backups, err := listReviewBackups(critPath)
+	if err != nil {
+		return err
+	}
+	for _, b := range backups[min(keep, len(backups)):] {
+		os.Remove(b.path)
+	}
+	return nil
+}
+
+// dropsComments reports whether the review file data has more comments than
+// next, or any when next is nil.
+func dropsComments(data []byte, next *CritJSON) bool {
+	var cj CritJSON
+	if unmarshalCritJSON(data, &cj) != nil {
+		return false
+	}
+	var after int
+	if next != nil {
+		after = totalComments(*next)
+	}
+	return totalComments(cj) > after
+}
+
+func totalComments(cj CritJSON) int {
+	unresolved, resolved := countComments(cj)
+	return unresolved + resolved
+}
+
+// backupBeforeWrite backs up the review file before WriteFiles replaces it
+// with next (nil when it removes it). Logs rather than fails: a failed backup
+// shouldn't lose the write.
+func (s *Session) backupBeforeWrite(critPath string, next *CritJSON) {
+	if _, err := backupReviewFile(critPath, next, s.backups, false); err != nil {
+		slog.Warn("backing up review file", "path", critPath, "err", err)
+	}
+}
+
+func parseRestoreFlags(args []string) (commentFlags, int, error) {
+	var f commentFlags
+	var n int
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--output", "-o", "--plan", "--backup":
+			if i+1 >= len(args) {
+				return f, 0, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			switch arg {
+			case "--plan":
+				f.plan = args[i]
+			case "--backup":
+				v, err := strconv.Atoi(args[i])
+				if err != nil || v < 1 {
+					return f, 0, fmt.Errorf("--backup takes a backup number from the list, got %q", args[i])
+				}
+				n = v
+			default:
+				f.outputDir = args[i]
+			}
+		default:
+			return f, 0, fmt.Errorf("unexpected argument %q", arg)
+		}
+	}
+	return f, n, nil
+}
+
+// runRestore implements `crit restore [--backup <n>]`: without --backup it
+// lists the review file's backups, newest first; with it, it puts backup n
+// back, after backing up the current file so the restore can be undone.
+func runRestore(args []string) {
+	if slices.Contains(args, "--help") || slices.Contains(args, "-h") {
+		printRestoreUsage()
+		return
+	}
+	f, n, err := parseRestoreFlags(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		printRestoreUsage()
+		os.Exit(1)
+	}
+	resolveCommentFlags(&f)
+	critPath, err := resolveReviewPath(f.outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	backups, err := listReviewBackups(critPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if n == 0 {
+		printReviewBackups(backups)
+		return
+	}
+	if n > len(backups) {
+		fmt.Fprintf(os.Stderr, "Error: there is no backup %d; run crit restore for the list\n", n)
+		os.Exit(1)
+	}
+	// Restoring never prunes: the backup of the current file comes on top.
+	keep := max(loadShareConfig().BackupCount(), len(backups)+1)
+	if err := restoreReviewBackup(critPath, backups[n-1], keep); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Restored %s from the backup of %s.\n", critPath, backups[n-1].time.Local().Format("2006-01-02 15:04:05"))
+}
+
+// restoreReviewBackup replaces the review file critPath with backup b. The
+// current file is backed up first, and the write-ahead log is dropped so a
+// daemon starting later doesn't replay changes on top of the restored file. A
+// running daemon picks the file up like any external edit.
+func restoreReviewBackup(critPath string, b reviewBackup, keep int) error {
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		return err
+	}
+	var cj CritJSON
+	if err := unmarshalCritJSON(data, &cj); err != nil {
+		return fmt.Errorf("backup %s: %w", b.path, err)
+	}
+	if _, err := backupReviewFile(critPath, nil, keep, true); err != nil {
+		return fmt.Errorf("backing up the current review file: %w", err)
+	}
+	if err := atomicWriteFile(critPath, data, 0644); err != nil {
+		return err
+	}
+	removeReviewJournal(critPath)
+	return nil
+}
+
+func printReviewBackups(backups []reviewBackup) {
+	if len(backups) == 0 {
+		fmt.Println("No backups of this review file yet.")
+		return
+	}
+	for i, b := range backups {
+		comments := "?"
+		if data, err := os.ReadFile(b.path); err == nil {
+			var cj CritJSON
+			if unmarshalCritJSON(data, &cj) == nil {
+				comments = strconv.Itoa(totalComments(cj))
+			}
+		}
+		fmt.Printf("%3d  %s  %s comments\n", i+1, b.time.Local().Format("2006-01-02 15:04:05"), comments)
+	}
+	fmt.Println("\nRestore one with: crit restore --backup <n>")
+}
+
+func printRestoreUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit restore [--backup <n>] [--output <dir>] [--plan <slug>]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Lists the backups of the review file, newest first, or with --backup")
+	fmt.Fprintln(os.Stderr, "puts backup n back (1 is the newest). The current file is backed up")
+	fmt.Fprintln(os.Stderr, "before it's replaced, so a restore can be undone the same way.")
+}
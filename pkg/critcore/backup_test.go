@@ -0,0 +1,167 @@
+package critcore
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func reviewWithComments(n int) CritJSON {
+	cj := CritJSON{Files: map[string]CritJSONFile{"a.md": {}}}
+	f := cj.Files["a.md"]
+	for i := range n {
+		f.Comments = append(f.Comments, Comment{ID: fmt.Sprintf("c%d", i), Body: "x"})
+	}
+	cj.Files["a.md"] = f
+	return cj
+}
+
+func TestBackupReviewFile_IntervalAndDroppedComments(t *testing.T) {
+	withProjectStorage(t, t.TempDir())
+	critPath := filepath.Join(t.TempDir(), "review.json")
+	saveCritJSON(critPath, reviewWithComments(2))
+
+	next := reviewWithComments(2)
+	if made, err := backupReviewFile(critPath, &next, 10, false); !made || err != nil {
+		t.Fatalf("first backup: made=%v err=%v", made, err)
+	}
+	if made, _ := backupReviewFile(critPath, &next, 10, false); made {
+		t.Error("backed up the same content twice")
+	}
+
+	saveCritJSON(critPath, reviewWithComments(3))
+	next = reviewWithComments(4)
+	if made, _ := backupReviewFile(critPath, &next, 10, false); made {
+		t.Error("backed up within the interval without dropping comments")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if made, _ := backupReviewFile(critPath, nil, 10, false); !made {
+		t.Error("removing the file should back it up straight away")
+	}
+	backups, _ := listReviewBackups(critPath)
+	if len(backups) != 2 || !backups[0].time.After(backups[1].time) {
+		t.Errorf("backups = %+v", backups)
+	}
+}
+
+func TestBackupReviewFile_Prunes(t *testing.T) {
+	withProjectStorage(t, t.TempDir())
+	critPath := filepath.Join(t.TempDir(), "review.json")
+	for i := 1; i <= 5; i++ {
+		saveCritJSON(critPath, reviewWithComments(i))
+		backupReviewFile(critPath, nil, 3, true)
+		time.Sleep(2 * time.Millisecond)
+	}
+	backups, _ := listReviewBackups(critPath)
+	if len(backups) != 3 {
+		t.Fatalf("kept %d backups, want 3", len(backups))
+	}
+	cj, _ := loadCritJSON(backups[0].path)
+	if totalComments(cj) != 5 {
+		t.Errorf("newest backup has %d comments, want 5", totalComments(cj))
+	}
+}
+
+func TestBackupReviewFile_Disabled(t *testing.T) {
+	withProjectStorage(t, t.TempDir())
+	critPath := filepath.Join(t.TempDir(), "review.json")
+	saveCritJSON(critPath, reviewWithComments(1))
+	if made, _ := backupReviewFile(critPath, nil, 0, true); made {
+		t.Error("backed up with backups turned off")
+	}
+}
+
+func TestWriteFiles_BacksUpBeforeDeletingLastComment(t *testing.T) {
+	storage := t.TempDir()
+	withProjectStorage(t, storage)
+	_, s := newTestServer(t)
+	s.ReviewFilePath = filepath.Join(storage, "reviews", "key1.json")
+	s.backups = defaultBackups
+	c, _ := s.AddComment("test.md", 1, 1, "", "keep me", "", "")
+	s.WriteFiles()
+	s.DeleteComment("test.md", c.ID)
+	s.WriteFiles()
+
+	if _, err := os.Stat(s.ReviewFilePath); !os.IsNotExist(err) {
+		t.Fatalf("review file should be gone, stat err = %v", err)
+	}
+	backups, _ := listReviewBackups(s.ReviewFilePath)
+	if len(backups) != 1 {
+		t.Fatalf("backups = %+v", backups)
+	}
+	cj, _ := loadCritJSON(backups[0].path)
+	if comments := cj.Files["test.md"].Comments; len(comments) != 1 || comments[0].Body != "keep me" {
+		t.Errorf("backup comments = %+v", comments)
+	}
+}
+
+func TestClearAllComments_RestoreBringsCommentsBack(t *testing.T) {
+	storage := t.TempDir()
+	withProjectStorage(t, storage)
+	srv, s := newTestServer(t)
+	s.ReviewFilePath = filepath.Join(storage, "reviews", "key1.json")
+	s.backups = defaultBackups
+	s.AddComment("test.md", 1, 1, "", "first", "", "")
+	s.AddComment("test.md", 2, 2, "", "second", "", "")
+	s.WriteFiles()
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/comments", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("DELETE /api/comments: status %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(s.ReviewFilePath); !os.IsNotExist(err) {
+		t.Fatalf("review file should be gone, stat err = %v", err)
+	}
+
+	backups, _ := listReviewBackups(s.ReviewFilePath)
+	if len(backups) == 0 {
+		t.Fatal("clearing all comments made no backup")
+	}
+	if err := restoreReviewBackup(s.ReviewFilePath, backups[0], len(backups)+1); err != nil {
+		t.Fatal(err)
+	}
+	cj, _ := loadCritJSON(s.ReviewFilePath)
+	if comments := cj.Files["test.md"].Comments; len(comments) != 2 || comments[0].Body != "first" {
+		t.Errorf("restored comments = %+v", comments)
+	}
+}
+
+func TestRestoreReviewBackup(t *testing.T) {
+	withProjectStorage(t, t.TempDir())
+	critPath := filepath.Join(t.TempDir(), "review.json")
+	saveCritJSON(critPath, reviewWithComments(3))
+	backupReviewFile(critPath, nil, 10, true)
+	time.Sleep(2 * time.Millisecond)
+	saveCritJSON(critPath, reviewWithComments(0))
+	journal, _ := reviewJournalPath(critPath)
+	appendWAL(journal, []walRecord{{Op: walBase}})
+
+	backups, _ := listReviewBackups(critPath)
+	if err := restoreReviewBackup(critPath, backups[0], len(backups)+1); err != nil {
+		t.Fatal(err)
+	}
+	cj, _ := loadCritJSON(critPath)
+	if totalComments(cj) != 3 {
+		t.Errorf("restored file has %d comments, want 3", totalComments(cj))
+	}
+	if backups, _ = listReviewBackups(critPath); len(backups) != 2 {
+		t.Errorf("the replaced file should have been backed up, backups = %+v", backups)
+	}
+	if _, err := os.Stat(journal); !os.IsNotExist(err) {
+		t.Error("restore should drop the write-ahead log")
+	}
+}
+
+func TestConfigBackupCount(t *testing.T) {
+	for backups, want := range map[int]int{0: defaultBackups, -1: 0, 3: 3} {
+		if got := (Config{Backups: backups}).BackupCount(); got != want {
+			t.Errorf("Backups %d: BackupCount() = %d, want %d", backups, got, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package critcore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GET /api/file/blame?path=plan.md returns who last touched each line of a
+// file in a git repository: one entry per line of the working file, with the
+// commit, its author and how old it is, plus the HEAD commit, so the review
+// UI can tell lines from the agent's last commit from long-standing ones.
+// Lines not committed yet carry "uncommitted" and no commit. Blame runs on
+// request and isn't cached; files that aren't in the working tree as shown
+// (revision snapshots, remote documents, notebook renderings, images) have
+// none.
+
+// blameTimeout bounds a single git blame.
+const blameTimeout = 10 * time.Second
+
+// errNoBlame is returned for files blame isn't available for.
+var errNoBlame = errors.New("blame is not available for this file")
+
+// blameLine is the blame of one line.
+type blameLine struct {
+	Line        int    `json:"line"`
+	Commit      string `json:"commit,omitempty"`
+	ShortCommit string `json:"short_commit,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Date        string `json:"date,omitempty"`
+	AgeDays     int    `json:"age_days"`
+	Summary     string `json:"summary,omitempty"`
+	Uncommitted bool   `json:"uncommitted,omitempty"`
+}
+
+// fileBlame is the /api/file/blame response.
+type fileBlame struct {
+	Path  string      `json:"path"`
+	Head  string      `json:"head,omitempty"`
+	Lines []blameLine `json:"lines"`
+}
+
+// FileBlame returns the blame of the file at path.
+func (s *Session) FileBlame(path string) (*fileBlame, error) {
+	s.mu.RLock()
+	f := s.fileByPathLocked(path)
+	if f == nil {
+		s.mu.RUnlock()
+		return nil, errFileNotFound
+	}
+	usable := s.VCS != nil && s.VCS.Name() == "git" &&
+		f.Revision == "" && !f.notebook && f.FileType != "image" && !f.Orphaned
+	repoRoot, absPath := s.RepoRoot, f.AbsPath
+	s.mu.RUnlock()
+	if !usable {
+		return nil, errNoBlame
+	}
+	rel, err := filepath.Rel(repoRoot, absPath)
+	if err != nil || !filepath.IsLocal(rel) {
+		return nil, errNoBlame
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), blameTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "blame", "--line-porcelain", "--", filepath.ToSlash(rel))
+	cmd.Dir = repoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w: git blame failed", errNoBlame)
+	}
+	lines, err := parseBlamePorcelain(out, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	head, _ := exec.Command("git", "-C", repoRoot, "rev-parse", "HEAD").Output()
+	return &fileBlame{Path: path, Head: strings.TrimSpace(string(head)), Lines: lines}, nil
+}
+
+// parseBlamePorcelain parses `git blame --line-porcelain` output, where every
+// line repeats its commit's headers. Ages are counted up to now.
+func parseBlamePorcelain(out []byte, now time.Time) ([]blameLine, error) {
+	var lines []blameLine
+	var cur *blameLine
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		text := sc.Text()
+		if cur == nil {
+			fields := strings.Fields(text)
+			if len(fields) < 3 || len(fields[0]) < 40 {
+				return nil, fmt.Errorf("unexpected git blame output: %q", text)
+			}
+			n, _ := strconv.Atoi(fields[2])
+			cur = &blameLine{Line: n, Commit: fields[0], ShortCommit: fields[0][:7]}
+			if strings.Trim(fields[0], "0") == "" {
+				cur = &blameLine{Line: n, Uncommitted: true}
+			}
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			lines = append(lines, *cur)
+			cur = nil
+			continue
+		}
+		key, value, _ := strings.Cut(text, " ")
+		if cur.Uncommitted {
+			continue
+		}
+		switch key {
+		case "author":
+			cur.Author = value
+		case "summary":
+			cur.Summary = value
+		case "author-time":
+			if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+				t := time.Unix(sec, 0).UTC()
+				cur.Date = t.Format(time.RFC3339)
+				cur.AgeDays = max(0, int(now.Sub(t).Hours()/24))
+			}
+		}
+	}
+	return lines, sc.Err()
+}
+
+// handleFileBlame serves GET /api/file/blame?path=.
+func (s *Server) handleFileBlame(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path query parameter required", http.StatusBadRequest)
+		return
+	}
+	blame, err := s.session.Load().FileBlame(path)
+	switch {
+	case errors.Is(err, errFileNotFound), errors.Is(err, errNoBlame):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, blame)
+	}
+}
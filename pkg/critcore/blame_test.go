@@ -0,0 +1,90 @@
+package critcore
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseBlamePorcelain(t *testing.T) {
+	out := "1f2e3d4c5b6a79881f2e3d4c5b6a79881f2e3d4c 1 1 1\n" +
+		"author Ada\nauthor-mail <ada@example.com>\nauthor-time 1700000000\nauthor-tz +0000\n" +
+		"summary Draft the plan\nfilename plan.md\n\t# Plan\n" +
+		"0000000000000000000000000000000000000000 2 2 1\n" +
+		"author Not Committed Yet\nauthor-time 1700000000\nsummary Version of plan.md from plan.md\nfilename plan.md\n\tnew line\n"
+	now := time.Unix(1700000000, 0).Add(72 * time.Hour)
+	lines, err := parseBlamePorcelain([]byte(out), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []blameLine{
+		{Line: 1, Commit: "1f2e3d4c5b6a79881f2e3d4c5b6a79881f2e3d4c", ShortCommit: "1f2e3d4", Author: "Ada", Date: "2023-11-14T22:13:20Z", AgeDays: 3, Summary: "Draft the plan"},
+		{Line: 2, Uncommitted: true},
+	}
+	if len(lines) != len(want) || lines[0] != want[0] || lines[1] != want[1] {
+		t.Errorf("lines = %+v", lines)
+	}
+	if _, err := parseBlamePorcelain([]byte("garbage\n"), now); err == nil {
+		t.Error("garbage accepted")
+	}
+}
+
+func TestFileBlame(t *testing.T) {
+	dir := initTestRepo(t)
+	writeFile(t, filepath.Join(dir, "plan.md"), "# Plan\n\nStep one.\n")
+	runGit(t, dir, "add", "plan.md")
+	runGit(t, dir, "commit", "-m", "Add plan")
+	writeFile(t, filepath.Join(dir, "plan.md"), "# Plan\n\nStep one.\nStep two.\n")
+	t.Chdir(dir)
+
+	session, err := NewSessionFromFiles([]string{"plan.md"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blame, err := session.FileBlame("plan.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blame.Lines) != 4 || blame.Head == "" {
+		t.Fatalf("blame = %+v", blame)
+	}
+	if l := blame.Lines[0]; l.Commit != blame.Head || l.Author != "Test" || l.Summary != "Add plan" || l.Uncommitted {
+		t.Errorf("committed line = %+v", l)
+	}
+	if l := blame.Lines[3]; !l.Uncommitted || l.Commit != "" || l.Line != 4 {
+		t.Errorf("uncommitted line = %+v", l)
+	}
+	if _, err := session.FileBlame("missing.md"); !errors.Is(err, errFileNotFound) {
+		t.Errorf("unknown path: %v", err)
+	}
+}
+
+func TestFileBlame_DotDotFileName(t *testing.T) {
+	dir := initTestRepo(t)
+	writeFile(t, filepath.Join(dir, "..notes.md"), "# Notes\n")
+	runGit(t, dir, "add", "..notes.md")
+	runGit(t, dir, "commit", "-m", "Add notes")
+	t.Chdir(dir)
+
+	session, err := NewSessionFromFiles([]string{"..notes.md"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blame, err := session.FileBlame("..notes.md"); err != nil || len(blame.Lines) != 1 {
+		t.Fatalf("blame = %+v, err = %v", blame, err)
+	}
+}
+
+func TestHandleFileBlame_NoRepository(t *testing.T) {
+	srv, _ := newTestServer(t)
+	for path, code := range map[string]int{"": http.StatusBadRequest, "test.md": http.StatusNotFound, "nope.md": http.StatusNotFound} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/file/blame?path="+path, nil))
+		if rec.Code != code {
+			t.Errorf("path %q: status %d, want %d", path, rec.Code, code)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"reflect"
@@ -58,6 +58,20 @@ func TestBrowserCommandSpecs(t *testing.T) {
 		}
 	})
 
+	t.Run("wsl finds windows launchers without the windows PATH", func(t *testing.T) {
+		specs := browserCommandSpecs("linux", url, true, func(name string) bool {
+			return name == wslWindowsPaths["powershell.exe"] || name == wslWindowsPaths["cmd.exe"]
+		})
+
+		want := []browserCommandSpec{
+			{name: wslWindowsPaths["powershell.exe"], args: []string{"-NoProfile", "-NonInteractive", "-Command", "Start-Process 'http://localhost:1234?a=1&b=2'"}},
+			{name: wslWindowsPaths["cmd.exe"], args: []string{"/c", `start "" "http://localhost:1234?a=1&b=2"`}},
+		}
+		if !reflect.DeepEqual(specs, want) {
+			t.Fatalf("browserCommandSpecs() = %#v, want %#v", specs, want)
+		}
+	})
+
 	t.Run("plain linux uses xdg-open", func(t *testing.T) {
 		specs := browserCommandSpecs("linux", url, false, func(name string) bool {
 			return name == "xdg-open"
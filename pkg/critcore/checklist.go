@@ -0,0 +1,163 @@
+package critcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Teams have standard gates a plan or change must pass ("covers rollback",
+// "has tests"). The checklist / checklist_file config keys define them; the
+// review UI shows them as checkboxes, the checked state is kept in the review
+// file, and the finish prompt and exports summarize what is still unchecked.
+
+// checklistItem is one checklist gate. Items are identified by their text,
+// so editing the config keeps the state of the items that didn't change.
+type checklistItem struct {
+	Text    string `json:"text"`
+	Checked bool   `json:"checked"`
+}
+
+// checklistMarker matches the list or task-list prefix of a line in a
+// checklist file: "- ", "* ", "1. ", "- [ ] ", "- [x] ".
+var checklistMarker = regexp.MustCompile(`^(?:[-*+]|\d+[.)])(?:\s+|$)(?:\[[ xX]\](?:\s+|$))?`)
+
+// parseChecklist reads one item per line of a checklist file, so a markdown
+// task list works as-is. Blank lines and # headings are skipped.
+func parseChecklist(text string) []string {
+	var items []string
+	for _, line := range strings.Split(textContent([]byte(text)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if item := strings.TrimSpace(checklistMarker.ReplaceAllString(line, "")); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// loadChecklist returns the configured checklist: the items of the file at
+// path when set (relative paths are taken from configDir, like
+// review_template), otherwise items.
+func loadChecklist(items []string, path, configDir string) ([]string, error) {
+	if path == "" {
+		return items, nil
+	}
+	if !filepath.IsAbs(path) && configDir != "" {
+		path = filepath.Join(configDir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading checklist file: %w", err)
+	}
+	return parseChecklist(string(data)), nil
+}
+
+// setChecklist replaces the session's checklist with texts, keeping the
+// checked state of items already in it (restored from the review file). An
+// empty texts keeps whatever the review file recorded.
+func (s *Session) setChecklist(texts []string) {
+	if len(texts) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checked := make(map[string]bool, len(s.checklist))
+	for _, it := range s.checklist {
+		checked[it.Text] = it.Checked
+	}
+	s.checklist = make([]checklistItem, 0, len(texts))
+	seen := map[string]bool{}
+	for _, t := range texts {
+		if t = strings.TrimSpace(t); t != "" && !seen[t] {
+			seen[t] = true
+			s.checklist = append(s.checklist, checklistItem{Text: t, Checked: checked[t]})
+		}
+	}
+}
+
+// GetChecklist returns a copy of the session's checklist.
+func (s *Session) GetChecklist() []checklistItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]checklistItem{}, s.checklist...)
+}
+
+// SetChecklistItem checks or unchecks the item with the given text.
+func (s *Session) SetChecklistItem(text string, checked bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, it := range s.checklist {
+		if it.Text == text {
+			s.checklist[i].Checked = checked
+			s.scheduleWrite()
+			return true
+		}
+	}
+	return false
+}
+
+// mergeChecklistFromDisk takes the checked state of each item from an
+// externally edited review file. Caller holds s.mu.
+func (s *Session) mergeChecklistFromDisk(disk []checklistItem) bool {
+	changed := false
+	for _, d := range disk {
+		for i, it := range s.checklist {
+			if it.Text == d.Text && it.Checked != d.Checked {
+				s.checklist[i].Checked = d.Checked
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// checklistSummary describes items for the agent, e.g. "Review checklist: 1
+// of 3 checked. Not checked yet: Rollback plan; Tests.". Returns "" for an
+// empty checklist.
+func checklistSummary(items []checklistItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	var open []string
+	for _, it := range items {
+		if !it.Checked {
+			open = append(open, it.Text)
+		}
+	}
+	summary := fmt.Sprintf("Review checklist: %d of %d checked.", len(items)-len(open), len(items))
+	if len(open) > 0 {
+		summary += " Not checked yet: " + strings.Join(open, "; ") + "."
+	}
+	return summary
+}
+
+// handleChecklist serves the review checklist.
+// GET /api/checklist
+// PUT /api/checklist {text, checked}
+func (s *Server) handleChecklist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.session.Load().GetChecklist())
+	case http.MethodPut:
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
+		var req checklistItem
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !s.session.Load().SetChecklistItem(req.Text, req.Checked) {
+			http.Error(w, "Checklist item not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, s.session.Load().GetChecklist())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
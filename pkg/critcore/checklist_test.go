@@ -0,0 +1,99 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseChecklist(t *testing.T) {
+	got := parseChecklist("# Plan gates\n\n- [ ] Covers rollback\n- [x] Has tests\n* Names an owner\n2. Lists risks\nPlain line\n-\n")
+	if want := "Covers rollback,Has tests,Names an owner,Lists risks,Plain line"; strings.Join(got, ",") != want {
+		t.Errorf("items = %q, want %s", got, want)
+	}
+}
+
+func TestLoadChecklist(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "gates.md"), []byte("- Has tests\n"), 0644)
+	got, err := loadChecklist([]string{"inline"}, "gates.md", dir)
+	if err != nil || len(got) != 1 || got[0] != "Has tests" {
+		t.Errorf("file checklist = %q, %v", got, err)
+	}
+	if got, _ := loadChecklist([]string{"inline"}, "", dir); len(got) != 1 || got[0] != "inline" {
+		t.Errorf("inline checklist = %q", got)
+	}
+	if _, err := loadChecklist(nil, "missing.md", dir); err == nil {
+		t.Error("expected an error for a missing checklist file")
+	}
+}
+
+func TestSetChecklistKeepsCheckedState(t *testing.T) {
+	_, session := newTestServer(t)
+	session.setChecklist([]string{"Covers rollback", "Has tests"})
+	session.SetChecklistItem("Has tests", true)
+	session.setChecklist([]string{"Has tests", "Names an owner", "Has tests", " "})
+	got := session.GetChecklist()
+	if len(got) != 2 || got[0] != (checklistItem{"Has tests", true}) || got[1] != (checklistItem{"Names an owner", false}) {
+		t.Errorf("checklist = %+v", got)
+	}
+	session.setChecklist(nil)
+	if len(session.GetChecklist()) != 2 {
+		t.Error("an empty config should keep the restored checklist")
+	}
+}
+
+func TestChecklistAPI(t *testing.T) {
+	s, session := newTestServer(t)
+	session.setChecklist([]string{"Covers rollback", "Has tests"})
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("PUT", "/api/checklist", strings.NewReader(`{"text": "Has tests", "checked": true}`)))
+	var items []checklistItem
+	json.Unmarshal(w.Body.Bytes(), &items)
+	if w.Code != 200 || len(items) != 2 || !items[1].Checked {
+		t.Fatalf("PUT: status %d, %+v", w.Code, items)
+	}
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("PUT", "/api/checklist", strings.NewReader(`{"text": "Nope", "checked": true}`)))
+	if w.Code != 404 {
+		t.Errorf("unknown item: status %d, want 404", w.Code)
+	}
+
+	session.WriteFiles()
+	data, err := os.ReadFile(session.critJSONPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cj CritJSON
+	if err := json.Unmarshal(data, &cj); err != nil {
+		t.Fatal(err)
+	}
+	if len(cj.Checklist) != 2 || !cj.Checklist[1].Checked {
+		t.Errorf("review file checklist = %+v", cj.Checklist)
+	}
+	if history := renderReviewHistory(cj, "", nil); !strings.Contains(history, "## Checklist\n\n- [ ] Covers rollback\n- [x] Has tests\n") {
+		t.Errorf("history missing checklist:\n%s", history)
+	}
+}
+
+func TestFinish_ChecklistSummary(t *testing.T) {
+	s, session := newTestServer(t)
+	session.setChecklist([]string{"Covers rollback", "Has tests"})
+	session.SetChecklistItem("Has tests", true)
+	session.AddComment("test.md", 1, 1, "", "fix", "", "")
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/finish", nil))
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	prompt, _ := resp["prompt"].(string)
+	if want := "\n\nReview checklist: 1 of 2 checked. Not checked yet: Covers rollback."; !strings.HasSuffix(prompt, want) {
+		t.Errorf("prompt should end with %q, got:\n%s", want, prompt)
+	}
+}
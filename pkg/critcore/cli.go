@@ -0,0 +1,3229 @@
+package critcore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
+
+	qrterminal "github.com/mdp/qrterminal/v3"
+)
+
+// Set by Main from the Build the crit binary passes in.
+var (
+	frontendFS     fs.FS
+	integrationsFS fs.FS
+
+	version = "dev"
+	commit  = "unknown"
+	date    = "unknown"
+)
+
+// Build is what the crit binary hands Main: the assets it embeds and the
+// version stamped in at link time.
+type Build struct {
+	Frontend     fs.FS // holds frontend/, the review UI
+	Integrations fs.FS // holds integrations/, installed by crit install
+	Version      string
+	Commit       string
+	Date         string
+}
+
+// commandDispatch maps subcommand names to handler functions.
+var commandDispatch = map[string]func([]string){
+	"help":        func([]string) { printHelp() },
+	"--help":      func([]string) { printHelp() },
+	"-h":          func([]string) { printHelp() },
+	"--version":   func([]string) { printVersion() },
+	"-v":          func([]string) { printVersion() },
+	"share":       runShare,
+	"fetch":       runFetch,
+	"unpublish":   runUnpublish,
+	"install":     runInstall,
+	"config":      runConfig,
+	"check":       func([]string) { runCheck() },
+	"pull":        runPull,
+	"push":        runPush,
+	"comment":     runComment,
+	"review":      runReview,
+	"plan":        runPlan,
+	"plan-hook":   func([]string) { runPlanHook() },
+	"auth":        runAuth,
+	"stop":        runStop,
+	"add":         runAdd,
+	"status":      runStatus,
+	"cleanup":     runCleanup,
+	"history":     runHistory,
+	"update":      runUpdate,
+	"completion":  runCompletion,
+	"man":         runMan,
+	"doctor":      runDoctor,
+	"init":        runInit,
+	"import":      runImport,
+	"triage":      runTriage,
+	"compare":     runCompare,
+	"export":      runExport,
+	"serve-share": runServeShare,
+	"resume":      runResume,
+	"restore":     runRestore,
+	"__complete":  runComplete,
+	"_serve":      runServe,
+}
+
+// Main runs the crit command line with os.Args. It is the whole of the crit
+// binary's main function.
+func Main(b Build) {
+	frontendFS, integrationsFS = b.Frontend, b.Integrations
+	version, commit, date = b.Version, b.Commit, b.Date
+	if len(os.Args) < 2 {
+		runReview(nil)
+		return
+	}
+	if handler, ok := commandDispatch[os.Args[1]]; ok {
+		handler(os.Args[2:])
+		return
+	}
+	runReview(os.Args[1:])
+}
+
+type shareFlags struct {
+	outputDir string
+	svcURL    string
+	showQR    bool
+	encrypt   bool
+	expires   string
+	files     []string
+}
+
+func parseShareFlags(args []string) shareFlags {
+	var sf shareFlags
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--output" || arg == "-o":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			sf.outputDir = args[i]
+		case arg == "--share-url":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: --share-url requires a value\n")
+				os.Exit(1)
+			}
+			i++
+			sf.svcURL = args[i]
+		case arg == "--qr":
+			sf.showQR = true
+		case arg == "--encrypt":
+			sf.encrypt = true
+		case arg == "--expires":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: --expires requires a value\n")
+				os.Exit(1)
+			}
+			i++
+			sf.expires = args[i]
+		default:
+			sf.files = append(sf.files, arg)
+		}
+	}
+	return sf
+}
+
+func printShareUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit share [--output <dir>] [--share-url <url>] [--qr] [--encrypt] [--expires <dur>] <file> [file...]")
+	fmt.Fprintln(os.Stderr, "       crit share revoke [<id>]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Shares files to crit-web and prints the review URL.")
+	fmt.Fprintln(os.Stderr, "Comments from the review file are included automatically.")
+	fmt.Fprintln(os.Stderr, "--encrypt (or share_encrypt in config) encrypts a new share with a key kept in")
+	fmt.Fprintln(os.Stderr, "the URL's #fragment, so the share service only stores ciphertext.")
+	fmt.Fprintln(os.Stderr, "--expires (or share_expires in config) makes a new share expire after a number")
+	fmt.Fprintln(os.Stderr, "of days (7d) or hours (12h). crit share revoke deletes the share now.")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Examples:")
+	fmt.Fprintln(os.Stderr, "  crit share plan.md")
+	fmt.Fprintln(os.Stderr, "  crit share plan.md src/main.go")
+	fmt.Fprintln(os.Stderr, "  crit share --qr plan.md")
+	fmt.Fprintln(os.Stderr, "  crit share --encrypt plan.md")
+	fmt.Fprintln(os.Stderr, "  crit share --expires 7d plan.md")
+	os.Exit(1)
+}
+
+func loadShareFiles(paths []string) []shareFile {
+	var files []shareFile
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		relPath := path
+		if filepath.IsAbs(path) {
+			if wd, err := os.Getwd(); err == nil {
+				if rel, err := filepath.Rel(wd, path); err == nil {
+					relPath = rel
+				}
+			}
+		}
+		files = append(files, shareFile{Path: relPath, Content: textContent(content)})
+	}
+	return files
+}
+
+func printQR(url string, showQR bool) {
+	if showQR {
+		fmt.Println()
+		qrterminal.GenerateWithConfig(url, qrterminal.Config{
+			Level:      qrterminal.L,
+			Writer:     os.Stdout,
+			HalfBlocks: true,
+			QuietZone:  1,
+		})
+	}
+}
+
+func runShareExisting(existingCfg CritJSON, critPath string, files []shareFile, sharePaths []string, authToken string, showQR bool) {
+	localIDs := buildLocalIDSet(existingCfg)
+	localFingerprints := buildLocalFingerprints(existingCfg)
+	if webComments, err := fetchNewWebComments(existingCfg.ShareURL, localIDs, localFingerprints, authToken); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: could not pull remote comments: %v\n", err)
+	} else if len(webComments) > 0 {
+		if err := mergeWebComments(critPath, webComments); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: could not merge remote comments: %v\n", err)
+		}
+	}
+
+	allComments, _ := loadCommentsForUpsert(critPath, sharePaths)
+
+	result, err := upsertShareToWeb(existingCfg, files, allComments, authToken)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := updateShareState(critPath, computeShareHash(files, allComments), result.ReviewRound); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save share state: %v\n", err)
+	}
+	if result.Changed {
+		fmt.Printf("Updated (round %d): %s\n", result.ReviewRound, result.URL)
+	} else {
+		fmt.Println(existingCfg.ShareURL)
+	}
+
+	printQR(result.URL, showQR)
+}
+
+func runShareNew(critPath string, files []shareFile, filePaths []string, svcURL, authToken string, showQR bool, opts shareOptions) {
+	comments, reviewRound := loadCommentsForShare(critPath, filePaths)
+
+	url, deleteToken, err := shareFilesToWeb(files, comments, svcURL, reviewRound, authToken, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := persistShareState(critPath, url, deleteToken, shareScope(filePaths), opts.expiresAt); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not save share state to review file: %v\n", err)
+	}
+
+	initialComments, _ := loadCommentsForUpsert(critPath, filePaths)
+	_ = updateShareState(critPath, computeShareHash(files, initialComments), reviewRound)
+
+	fmt.Println(url)
+	if opts.expiresAt != "" {
+		fmt.Printf("Expires %s\n", opts.expiresAt)
+	}
+	printQR(url, showQR)
+
+	if authToken == "" {
+		showLoginHint()
+	}
+}
+
+func runShare(args []string) {
+	if len(args) > 0 && args[0] == "revoke" {
+		runShareRevoke(args[1:])
+		return
+	}
+	sf := parseShareFlags(args)
+
+	if len(sf.files) == 0 {
+		printShareUsage()
+	}
+
+	cfg := loadShareConfig()
+	sf.svcURL = resolveShareURL(sf.svcURL, cfg, defaultShareURL)
+	authToken := resolveAuthToken(cfg)
+
+	files := loadShareFiles(sf.files)
+
+	critPath, err := resolveReviewPath(sf.outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	sharePaths := make([]string, len(files))
+	for i, f := range files {
+		sharePaths[i] = f.Path
+	}
+
+	if existingCfg, ok := loadExistingShareCfg(critPath, sharePaths); ok {
+		runShareExisting(existingCfg, critPath, files, sharePaths, authToken, sf.showQR)
+		return
+	}
+
+	if sf.expires == "" {
+		sf.expires = cfg.ShareExpires
+	}
+	expiresAt, err := shareExpiryTime(sf.expires, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	runShareNew(critPath, files, sharePaths, sf.svcURL, authToken, sf.showQR, shareOptions{encrypt: sf.encrypt || cfg.ShareEncrypt, expiresAt: expiresAt})
+}
+
+func parseFetchOutputDir(args []string) string {
+	outputDir := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--output" || arg == "-o":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			outputDir = args[i]
+		default:
+			fmt.Fprintln(os.Stderr, "Usage: crit fetch [--output <dir>]")
+			fmt.Fprintln(os.Stderr, "       crit fetch [--no-review] <share> [<dir>]")
+			fmt.Fprintln(os.Stderr, "")
+			fmt.Fprintln(os.Stderr, "Fetches comments added on crit-web into the review file.")
+			fmt.Fprintln(os.Stderr, "Requires a prior `crit share` so a share URL is recorded.")
+			fmt.Fprintln(os.Stderr, "With <share>, a link or token, downloads someone's shared review instead.")
+			os.Exit(1)
+		}
+	}
+	return outputDir
+}
+
+func printFetchedComments(webComments []webComment) {
+	fmt.Printf("Fetched %d new comment(s) into review file\n", len(webComments))
+	for _, wc := range webComments {
+		runes := []rune(wc.Body)
+		body := wc.Body
+		if len(runes) > 60 {
+			body = string(runes[:60]) + "..."
+		}
+		if wc.Scope == "review" || wc.FilePath == "" {
+			fmt.Printf("  [review] %s\n", body)
+		} else {
+			fmt.Printf("  [%s:%d] %s\n", wc.FilePath, wc.StartLine, body)
+		}
+	}
+}
+
+func runFetch(args []string) {
+	// Plain `crit fetch` only takes --output; anything else names a share.
+	if len(args) > 0 && args[0] != "--output" && args[0] != "-o" {
+		runFetchShare(args)
+		return
+	}
+	outputDir := parseFetchOutputDir(args)
+
+	critPath, err := resolveReviewPath(outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	data, readErr := os.ReadFile(critPath)
+	if readErr != nil {
+		fmt.Fprintln(os.Stderr, "Error: no review file found. Run `crit share` first.")
+		os.Exit(1)
+	}
+	var cj CritJSON
+	if err := unmarshalCritJSON(data, &cj); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid review file: %v\n", err)
+		os.Exit(1)
+	}
+	if cj.ShareURL == "" {
+		fmt.Fprintln(os.Stderr, "Error: no share URL in review file. Run `crit share` first.")
+		os.Exit(1)
+	}
+
+	authToken := resolveAuthToken(loadShareConfig())
+	localIDs := buildLocalIDSet(cj)
+	localFingerprints := buildLocalFingerprints(cj)
+
+	webComments, err := fetchNewWebComments(cj.ShareURL, localIDs, localFingerprints, authToken)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error fetching remote comments: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(webComments) == 0 {
+		fmt.Println("No new comments.")
+		fmt.Printf("Review file: %s\n", critPath)
+		return
+	}
+
+	if err := mergeWebComments(critPath, webComments); err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving review file: %v\n", err)
+		os.Exit(1)
+	}
+
+	printFetchedComments(webComments)
+	fmt.Printf("Review file: %s\n", critPath)
+}
+
+func runUnpublish(args []string) {
+	unpubOutputDir := ""
+	unpubSvcURL := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--output" || arg == "-o":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			unpubOutputDir = args[i]
+		case arg == "--share-url":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: --share-url requires a value\n")
+				os.Exit(1)
+			}
+			i++
+			unpubSvcURL = args[i]
+		default:
+			fmt.Fprintf(os.Stderr, "Usage: crit unpublish [--output <dir>] [--share-url <url>]\n")
+			os.Exit(1)
+		}
+	}
+	unpublishShare(unpubOutputDir, unpubSvcURL, "")
+}
+
+// unpublishShare deletes the share recorded in the review file in
+// unpubOutputDir, which must be the one id names unless id is "".
+func unpublishShare(unpubOutputDir, unpubSvcURL, id string) {
+	unpubCfg := loadShareConfig()
+	unpubSvcURL = resolveShareURL(unpubSvcURL, unpubCfg, defaultShareURL)
+	unpubAuthToken := resolveAuthToken(unpubCfg)
+
+	critPath, err := resolveReviewPath(unpubOutputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := os.ReadFile(critPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: no review file found. Nothing to unpublish.")
+		os.Exit(1)
+	}
+	var cj CritJSON
+	if err := unmarshalCritJSON(data, &cj); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid review file: %v\n", err)
+		os.Exit(1)
+	}
+	if id != "" && !(cj.DeleteToken != "" && shareMatchesID(cj.ShareURL, id)) {
+		revokeEarlierShare(critPath, cj, id, unpubSvcURL, unpubAuthToken)
+		return
+	}
+	if cj.DeleteToken == "" {
+		fmt.Fprintln(os.Stderr, "No shared review found — nothing to unpublish.")
+		return
+	}
+
+	if err := unpublishFromWeb(unpubSvcURL, cj.DeleteToken, unpubAuthToken); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Clear share state from the review file
+	if err := clearShareState(critPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not clear share state: %v\n", err)
+	}
+
+	fmt.Println("Review unpublished.")
+}
+
+func runInstall(args []string) {
+	args = withConfiguredInstallAgents(args)
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "Usage: crit install <agent> [--global]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Available agents:")
+		for _, a := range availableIntegrations() {
+			fmt.Fprintf(os.Stderr, "  %s\n", a)
+		}
+		fmt.Fprintln(os.Stderr, "  all")
+		os.Exit(1)
+	}
+
+	force := false
+	global := false
+	for _, arg := range args[1:] {
+		if arg == "--force" || arg == "-f" {
+			force = true
+		}
+		if arg == "--global" || arg == "-g" {
+			global = true
+		}
+	}
+
+	target := args[0]
+	if target == "all" {
+		if global {
+			fmt.Fprintln(os.Stderr, "Error: --global is not supported with 'all'")
+			os.Exit(1)
+		}
+		for _, name := range availableIntegrations() {
+			installIntegration(name, force, false)
+		}
+	} else {
+		for _, name := range strings.Split(target, ",") {
+			installIntegration(name, force, global)
+		}
+	}
+}
+
+// withConfiguredInstallAgents prepends the install_agents config value to
+// args when they don't name an agent.
+func withConfiguredInstallAgents(args []string) []string {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args
+	}
+	if agents := configuredInstallAgents(); len(agents) > 0 {
+		return append([]string{strings.Join(agents, ",")}, args...)
+	}
+	return args
+}
+
+// configuredInstallAgents returns the install_agents config value, used when
+// `crit install` is run without naming an agent.
+func configuredInstallAgents() []string {
+	dir, _ := os.Getwd()
+	if vcs := DetectVCS(""); vcs != nil {
+		dir, _ = vcs.RepoRoot()
+	}
+	return LoadConfig(dir).InstallAgents
+}
+
+func runConfig(args []string) {
+	for _, arg := range args {
+		if arg == "--help" || arg == "-h" || arg == "help" {
+			printConfigHelp()
+			return
+		}
+		if arg == "--generate" || arg == "-g" {
+			fmt.Print(defaultConfig().String())
+			return
+		}
+	}
+	configDir := ""
+	if vcs := DetectVCS(""); vcs != nil {
+		configDir, _ = vcs.RepoRoot()
+	}
+	if configDir == "" {
+		configDir, _ = os.Getwd()
+	}
+	cfg := LoadConfig(configDir)
+	fmt.Print(cfg.String())
+}
+
+type pullFlags struct {
+	prFlag    int
+	outputDir string
+}
+
+func parsePullFlags(args []string) pullFlags {
+	var f pullFlags
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--output" || arg == "-o" {
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			f.outputDir = args[i]
+			continue
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Usage: crit pull [--output <dir>] [pr-number]\n")
+			os.Exit(1)
+		}
+		f.prFlag = n
+	}
+	return f
+}
+
+func runPull(args []string) {
+	if err := requireGH(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	f := parsePullFlags(args)
+
+	prNumber, err := detectPR(f.prFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ghComments, err := fetchPRComments(prNumber)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	critPath, err := resolveReviewPath(f.outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var cj CritJSON
+	if data, readErr := os.ReadFile(critPath); readErr == nil {
+		if jsonErr := unmarshalCritJSON(data, &cj); jsonErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: existing review file is invalid, starting fresh: %v\n", jsonErr)
+		}
+	}
+	if cj.Files == nil {
+		cj.Files = make(map[string]CritJSONFile)
+		cj.Branch = CurrentBranch()
+		cfg := LoadConfig("")
+		base := cfg.BaseBranch
+		if base == "" {
+			base = DefaultBranch()
+		}
+		cj.BaseRef, _ = MergeBase(base)
+		cj.ReviewRound = 1
+	}
+
+	added := mergeGHComments(&cj, ghComments)
+
+	if added == 0 {
+		fmt.Printf("No new inline comments found on PR #%d\n", prNumber)
+		return
+	}
+
+	if err := saveCritJSON(critPath, cj); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Pulled %d comments from PR #%d into %s\n", added, prNumber, critPath)
+	fmt.Println("Run 'crit' to view them in the browser.")
+}
+
+type pushFlags struct {
+	prFlag    int
+	dryRun    bool
+	message   string
+	outputDir string
+	eventFlag string
+}
+
+func parsePushFlags(args []string) pushFlags {
+	var f pushFlags
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--dry-run" {
+			f.dryRun = true
+			continue
+		}
+		if arg == "--message" || arg == "-m" {
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: --message requires a value\n")
+				os.Exit(1)
+			}
+			i++
+			f.message = args[i]
+			continue
+		}
+		if arg == "--output" || arg == "-o" {
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: --output requires a value\n")
+				os.Exit(1)
+			}
+			i++
+			f.outputDir = args[i]
+			continue
+		}
+		if arg == "--event" || arg == "-e" {
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: --event requires a value (comment, approve, request-changes)\n")
+				os.Exit(1)
+			}
+			i++
+			f.eventFlag = args[i]
+			continue
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Usage: crit push [--dry-run] [--event <type>] [--message <msg>] [--output <dir>] [pr-number]\n")
+			os.Exit(1)
+		}
+		f.prFlag = n
+	}
+	return f
+}
+
+func displayPushDryRun(ghComments []map[string]interface{}, allReplies []ghReplyForPush, prNumber int, event, message string) {
+	displayEvent := strings.ToLower(strings.ReplaceAll(event, "_", "-"))
+	fmt.Printf("Would post %d comments to PR #%d (event: %s):\n\n", len(ghComments), prNumber, displayEvent)
+	if message != "" {
+		fmt.Printf("  Review body: %s\n\n", message)
+	}
+	for _, c := range ghComments {
+		path, _ := c["path"].(string)
+		line, _ := c["line"].(int)
+		body, _ := c["body"].(string)
+		if sl, ok := c["start_line"].(int); ok {
+			fmt.Printf("  %s:%d-%d\n", path, sl, line)
+		} else {
+			fmt.Printf("  %s:%d\n", path, line)
+		}
+		fmt.Printf("    %s\n\n", body)
+	}
+	for _, reply := range allReplies {
+		fmt.Printf("  Would reply to GitHub comment %d: %.60s\n", reply.ParentGHID, reply.Body)
+	}
+}
+
+func postPushReplies(prNumber int, allReplies []ghReplyForPush) map[replyKey]int64 {
+	replyCount := 0
+	replyIDs := make(map[replyKey]int64)
+	for _, reply := range allReplies {
+		replyID, err := postGHReply(prNumber, reply.ParentGHID, reply.Body)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to post reply: %v\n", err)
+		} else {
+			replyCount++
+			if replyID != 0 {
+				replyIDs[replyKey{ParentGHID: reply.ParentGHID, BodyPrefix: truncateStr(reply.Body, 60)}] = replyID
+			}
+		}
+	}
+	if replyCount > 0 {
+		fmt.Printf("Posted %d replies\n", replyCount)
+	}
+	return replyIDs
+}
+
+func runPush(args []string) {
+	if err := requireGH(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	f := parsePushFlags(args)
+
+	event, err := parsePushEvent(f.eventFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if event == "REQUEST_CHANGES" && f.message == "" {
+		fmt.Fprintf(os.Stderr, "Error: --event request-changes requires --message\n")
+		os.Exit(1)
+	}
+
+	prNumber, err := detectPR(f.prFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	critPath, err := resolveReviewPath(f.outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := os.ReadFile(critPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: no review file found. Run a crit review first.\n")
+		os.Exit(1)
+	}
+	var cj CritJSON
+	if err := unmarshalCritJSON(data, &cj); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid review file: %v\n", err)
+		os.Exit(1)
+	}
+
+	ghComments := critJSONToGHComments(cj)
+	if len(ghComments) == 0 && event == "COMMENT" {
+		fmt.Println("No unresolved comments to push.")
+		return
+	}
+
+	var allReplies []ghReplyForPush
+	for _, cf := range cj.Files {
+		allReplies = append(allReplies, collectNewRepliesForPush(cf)...)
+	}
+
+	if f.dryRun {
+		displayPushDryRun(ghComments, allReplies, prNumber, event, f.message)
+		return
+	}
+
+	displayEvent := strings.ToLower(strings.ReplaceAll(event, "_", "-"))
+	fmt.Printf("Pushing %d comments to PR #%d (%s)...\n", len(ghComments), prNumber, displayEvent)
+	commentIDs, err := createGHReview(prNumber, ghComments, f.message, event)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Posted %d review comments to PR #%d (%s)\n", len(ghComments), prNumber, displayEvent)
+
+	replyIDs := postPushReplies(prNumber, allReplies)
+
+	if err := updateCritJSONWithGitHubIDs(critPath, commentIDs, replyIDs); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update review file with GitHub IDs: %v\n", err)
+	}
+}
+
+type commentFlags struct {
+	outputDir string
+	author    string
+	replyTo   string
+	resolve   bool
+	path      string
+	json      bool
+	plan      string
+	args      []string
+}
+
+func parseCommentFlags(args []string) commentFlags {
+	var f commentFlags
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--plan":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: --plan requires a slug\n")
+				os.Exit(1)
+			}
+			i++
+			f.plan = args[i]
+		case arg == "--output" || arg == "-o":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			f.outputDir = args[i]
+		case arg == "--author":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: --author requires a value\n")
+				os.Exit(1)
+			}
+			i++
+			f.author = args[i]
+		case arg == "--reply-to":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: --reply-to requires a comment ID\n")
+				os.Exit(1)
+			}
+			i++
+			f.replyTo = args[i]
+		case arg == "--resolve":
+			f.resolve = true
+		case arg == "--path":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: --path requires a value\n")
+				os.Exit(1)
+			}
+			i++
+			f.path = args[i]
+		case arg == "--json":
+			f.json = true
+		default:
+			f.args = append(f.args, arg)
+		}
+	}
+	return f
+}
+
+func resolveCommentFlags(f *commentFlags) {
+	// --plan resolves to --output for the plan storage directory
+	if f.plan != "" {
+		if f.outputDir != "" {
+			fmt.Fprintln(os.Stderr, "Error: --plan and --output cannot be used together")
+			os.Exit(1)
+		}
+		var planDirErr error
+		f.outputDir, planDirErr = planStorageDir(slugify(f.plan))
+		if planDirErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", planDirErr)
+			os.Exit(1)
+		}
+	}
+
+	// Resolve author: --author flag > config > VCS user.name
+	if f.author == "" {
+		cfgDir, _ := os.Getwd()
+		if vcs := DetectVCS(""); vcs != nil {
+			cfgDir, _ = vcs.RepoRoot()
+		}
+		cfg := LoadConfig(cfgDir)
+		f.author = cfg.Author
+	}
+}
+
+func runCommentJSON(f commentFlags) {
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+		os.Exit(1)
+	}
+
+	var entries []BulkCommentEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing JSON: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := bulkAddCommentsToCritJSON(entries, f.author, f.outputDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var comments, replies int
+	for _, e := range entries {
+		if e.ReplyTo != "" {
+			replies++
+		} else {
+			comments++
+		}
+	}
+
+	var parts []string
+	if comments > 0 {
+		parts = append(parts, fmt.Sprintf("%d comment%s", comments, plural(comments)))
+	}
+	if replies > 0 {
+		parts = append(parts, fmt.Sprintf("%d repl%s", replies, pluralReply(replies)))
+	}
+	fmt.Printf("Added %s\n", strings.Join(parts, " and "))
+}
+
+func runCommentReply(f commentFlags) {
+	if len(f.args) < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: crit comment --reply-to <comment-id> [--resolve] <body>")
+		os.Exit(1)
+	}
+	replyBody := strings.Join(f.args, " ")
+	if err := addReplyToCritJSON(f.replyTo, replyBody, f.author, f.resolve, f.outputDir, f.path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if f.resolve {
+		fmt.Printf("Replied to %s and marked resolved\n", f.replyTo)
+	} else {
+		fmt.Printf("Replied to %s\n", f.replyTo)
+	}
+}
+
+func runCommentClear(outputDir string) {
+	if err := clearCritJSON(outputDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Cleared review file")
+}
+
+func printCommentUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit comment [--output <dir>] [--author <name>] <body>                    Review-level comment")
+	fmt.Fprintln(os.Stderr, "       crit comment [--output <dir>] [--author <name>] <path> <body>             File-level comment")
+	fmt.Fprintln(os.Stderr, "       crit comment [--output <dir>] [--author <name>] <path>:<line[-end]> <body> Line-level comment")
+	fmt.Fprintln(os.Stderr, "       crit comment [--output <dir>] [--author <name>] <path>:$.<key.path> <body> JSON/YAML value comment")
+	fmt.Fprintln(os.Stderr, "       crit comment --reply-to <id> [--resolve] [--author <name>] <body>")
+	fmt.Fprintln(os.Stderr, "       crit comment --json [--author <name>] [--output <dir>]    Read comments from stdin as JSON")
+	fmt.Fprintln(os.Stderr, "       crit comment [--output <dir>] --clear")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Examples:")
+	fmt.Fprintln(os.Stderr, "  crit comment --author 'Claude' 'Overall this looks good'")
+	fmt.Fprintln(os.Stderr, "  crit comment --author 'Claude' src/auth.go 'Restructure this file'")
+	fmt.Fprintln(os.Stderr, "  crit comment --author 'Claude' main.go:42 'Fix this bug'")
+	fmt.Fprintln(os.Stderr, "  crit comment --author 'Claude' src/auth.go:10-25 'This block needs refactoring'")
+	fmt.Fprintln(os.Stderr, "  crit comment --author 'Claude' 'deploy.yaml:$.services[0].image' 'Pin this tag'")
+	fmt.Fprintln(os.Stderr, "  crit comment --reply-to c_a3f8b2 --resolve --author 'Claude' 'Split into two functions'")
+	fmt.Fprintln(os.Stderr, "  crit comment --output /tmp/reviews main.go:42 'Fix this bug'")
+	fmt.Fprintln(os.Stderr, "  echo '[{\"file\":\"main.go\",\"line\":42,\"body\":\"Fix this\"}]' | crit comment --json --author 'Claude'")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Tips:")
+	fmt.Fprintln(os.Stderr, "  Use --author to identify who left the comment (recommended for AI agents)")
+	fmt.Fprintln(os.Stderr, "  Use single quotes for the body to avoid shell interpretation of backticks")
+	fmt.Fprintln(os.Stderr, "  Use --json for bulk operations (multiple comments/replies in one atomic write)")
+	os.Exit(1)
+}
+
+func runCommentLineLevel(loc string, commentArgs []string, author, outputDir string) {
+	colonIdx := strings.LastIndex(loc, ":")
+	lineSpec := loc[colonIdx+1:]
+	filePath := loc[:colonIdx]
+	var startLine, endLine int
+	if dashIdx := strings.Index(lineSpec, "-"); dashIdx >= 0 {
+		s, err := strconv.Atoi(lineSpec[:dashIdx])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid start line in %q\n", loc)
+			os.Exit(1)
+		}
+		e, err := strconv.Atoi(lineSpec[dashIdx+1:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid end line in %q\n", loc)
+			os.Exit(1)
+		}
+		startLine, endLine = s, e
+	} else {
+		n, err := strconv.Atoi(lineSpec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid line number in %q\n", loc)
+			os.Exit(1)
+		}
+		startLine, endLine = n, n
+	}
+	body := strings.Join(commentArgs[1:], " ")
+	if err := addCommentToCritJSON(filePath, startLine, endLine, body, author, outputDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Added comment on %s:%s\n", filePath, lineSpec)
+}
+
+// runCommentDataPath adds a comment on the lines of the value at dataPath.
+func runCommentDataPath(filePath, dataPath string, commentArgs []string, author, outputDir string) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	startLine, endLine, ok := findDataPath(dataNodes(filePath, textContent(data)), dataPath)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: %s not found in %s\n", dataPath, filePath)
+		os.Exit(1)
+	}
+	body := strings.Join(commentArgs[1:], " ")
+	if err := addCommentToCritJSON(filePath, startLine, endLine, body, author, outputDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Added comment on %s:%s (lines %d-%d)\n", filePath, dataPath, startLine, endLine)
+}
+
+func runComment(args []string) {
+	f := parseCommentFlags(args)
+	resolveCommentFlags(&f)
+
+	if f.json {
+		runCommentJSON(f)
+		return
+	}
+
+	if f.replyTo != "" {
+		runCommentReply(f)
+		return
+	}
+
+	if len(f.args) >= 1 && f.args[0] == "--clear" {
+		runCommentClear(f.outputDir)
+		return
+	}
+
+	if len(f.args) < 1 {
+		printCommentUsage()
+	}
+
+	// 1 arg: review-level comment
+	if len(f.args) == 1 {
+		body := f.args[0]
+		if err := addReviewCommentToCritJSON(body, f.author, f.outputDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Added review comment")
+		return
+	}
+	dispatchCommentLocation(f)
+}
+
+// dispatchCommentLocation adds the comment of a `crit comment <location>
+// <body...>` invocation: on a JSON/YAML value (<file>:$.data.path), on lines
+// (<file>:<line[-end]>) or on a whole file.
+func dispatchCommentLocation(f commentFlags) {
+	// <file>:$.data.path anchors to a JSON/YAML value
+	loc := f.args[0]
+	if i := strings.Index(loc, ":$"); i > 0 && isDataFile(loc[:i]) {
+		runCommentDataPath(loc[:i], loc[i+1:], f.args, f.author, f.outputDir)
+		return
+	}
+
+	// check if first arg has a colon with valid line spec
+	colonIdx := strings.LastIndex(loc, ":")
+	if colonIdx > 0 && looksLikeLineSpec(loc[colonIdx+1:]) {
+		runCommentLineLevel(loc, f.args, f.author, f.outputDir)
+		return
+	}
+
+	// without colon line spec: check if first arg is a file path
+	if len(f.args) >= 2 {
+		candidatePath := f.args[0]
+		if fileExistsOnDiskOrSession(candidatePath, f.outputDir) {
+			body := strings.Join(f.args[1:], " ")
+			if err := addFileCommentToCritJSON(candidatePath, body, f.author, f.outputDir); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Added file comment on %s\n", candidatePath)
+			return
+		}
+	}
+
+	if colonIdx < 0 {
+		fmt.Fprintf(os.Stderr, "Error: invalid location %q — expected <path>:<line[-end]>, or a valid file path for file-level comments\n", loc)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Error: invalid line spec in %q\n", loc)
+	os.Exit(1)
+}
+
+// looksLikeLineSpec returns true if s looks like a line number or range (e.g. "42", "10-25").
+func looksLikeLineSpec(s string) bool {
+	if s == "" {
+		return false
+	}
+	if dashIdx := strings.Index(s, "-"); dashIdx >= 0 {
+		_, err1 := strconv.Atoi(s[:dashIdx])
+		_, err2 := strconv.Atoi(s[dashIdx+1:])
+		return err1 == nil && err2 == nil
+	}
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// fileExistsOnDiskOrSession checks if a path exists as a file on disk or in the review file.
+func fileExistsOnDiskOrSession(path string, outputDir string) bool {
+	// Check disk first (relative to cwd)
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return true
+	}
+	// Check in repo root if we're in a VCS repo
+	if vcs := DetectVCS(""); vcs != nil {
+		if root, err := vcs.RepoRoot(); err == nil {
+			absPath := filepath.Join(root, path)
+			if info, err := os.Stat(absPath); err == nil && !info.IsDir() {
+				return true
+			}
+		}
+	}
+	// Check if it exists in the review file
+	critPath, err := resolveReviewPath(outputDir)
+	if err != nil {
+		return false
+	}
+	cj, err := loadCritJSON(critPath)
+	if err != nil {
+		return false
+	}
+	_, exists := cj.Files[path]
+	return exists
+}
+
+// runReview always uses the daemon pattern: starts a background daemon if needed,
+// connects as a review client, blocks for one review cycle, then exits.
+// Used by `crit review` and by agents.
+type planConfig struct {
+	name          string
+	filePath      string
+	stdinExpected bool
+	port          int
+	noOpen        bool
+	quiet         bool
+	printURL      bool
+	keepAlive     bool
+}
+
+func resolvePlanConfig(args []string) planConfig {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	name := fs.String("name", "", "Plan name/slug for session identification")
+	port := fs.Int("port", 0, "Port to listen on")
+	fs.IntVar(port, "p", 0, "Port (shorthand)")
+	noOpen := fs.Bool("no-open", false, "Don't auto-open browser")
+	quiet := fs.Bool("quiet", false, "Suppress status output")
+	fs.BoolVar(quiet, "q", false, "Suppress status (shorthand)")
+	printURL := fs.Bool("print-url", true, "With --quiet, still print the review URL")
+	keepAlive := fs.Bool("keep-alive", false, "Keep the daemon running after an approved review")
+	fs.Parse(args)
+
+	pc := planConfig{
+		name:      *name,
+		port:      *port,
+		noOpen:    *noOpen,
+		quiet:     *quiet,
+		printURL:  *printURL,
+		keepAlive: *keepAlive,
+	}
+
+	remaining := fs.Args()
+	if len(remaining) > 0 {
+		pc.filePath = remaining[0]
+	} else {
+		pc.stdinExpected = true
+	}
+
+	return pc
+}
+
+func readPlanContent(pc planConfig) []byte {
+	var content []byte
+	var err error
+
+	if pc.filePath != "" {
+		content, err = os.ReadFile(pc.filePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", pc.filePath, err)
+			os.Exit(1)
+		}
+	} else if pc.stdinExpected {
+		if !isStdinPipe() {
+			fmt.Fprintln(os.Stderr, "Error: no file specified and stdin is not a pipe")
+			fmt.Fprintln(os.Stderr, "Usage: crit plan --name <slug> <file>  or  echo \"content\" | crit plan --name <slug>")
+			os.Exit(1)
+		}
+		content, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading stdin: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if len(strings.TrimSpace(string(content))) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: plan content is empty")
+		os.Exit(1)
+	}
+	return content
+}
+
+func resolvePlanSlug(name string, content []byte) string {
+	if name != "" {
+		return slugify(name)
+	}
+	return resolveSlug(content)
+}
+
+// connectOrStartDaemon finds an alive session or starts a new daemon.
+// Without a session for key, it attaches to one already reviewing every
+// file in files (see reviewedFiles), so re-running crit from another
+// directory or with the files spelled differently doesn't start a duplicate.
+// Returns the session entry and whether we started a new daemon.
+func connectOrStartDaemon(key string, args, files []string, noOpen bool) (sessionEntry, bool) {
+	entry, alive := findAliveSession(key)
+	if !alive {
+		entry, alive = findSessionServingFiles(files)
+	}
+	if alive {
+		// Re-open browser if no browser tab is connected (user closed it)
+		if !noOpen && !daemonHasBrowser(entry) {
+			go openBrowser(entry.url(""))
+		}
+		return entry, false
+	}
+
+	var err error
+	entry, err = startDaemon(key, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return entry, true
+}
+
+// announceDaemon tells the user which daemon serves the review. With --quiet
+// only the review URL is printed, for tools wrapping crit, and nothing at all
+// with --print-url=false. It goes to stderr: stdout carries the feedback.
+func announceDaemon(w io.Writer, entry sessionEntry, started, quiet, printURL bool) {
+	switch {
+	case quiet && printURL:
+		fmt.Fprintln(w, entry.url(""))
+	case quiet:
+	case started:
+		fmt.Fprintf(w, "Started crit daemon on port %d (PID %d)\n", entry.Port, entry.PID)
+	default:
+		fmt.Fprintf(w, "Connected to crit daemon on port %d\n", entry.Port)
+	}
+}
+
+func installDaemonSignalHandler(pid int) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		if proc, err := os.FindProcess(pid); err == nil {
+			proc.Signal(syscall.SIGTERM)
+		}
+		os.Exit(0)
+	}()
+}
+
+func killDaemonOnApproval(approved bool, pid int) {
+	if approved {
+		if proc, err := os.FindProcess(pid); err == nil {
+			proc.Signal(syscall.SIGTERM)
+		}
+	}
+}
+
+// backgroundCleanup silently removes stale review files and orphaned session
+// files. It is intended to be called as a goroutine from review entry points
+// so it adds zero perceived latency. All errors are swallowed — no output is
+// written to stdout or stderr.
+func backgroundCleanup() {
+	revDir, err := reviewsDir()
+	if err == nil {
+		stale := findStaleReviews(revDir, 14)
+		deleteStaleReviewsSilent(stale)
+	}
+	cleanOrphanedSessions()
+}
+
+// deleteStaleReviewsSilent is like deleteStaleReviews but swallows all errors.
+// Used by backgroundCleanup to avoid any output to stderr.
+func deleteStaleReviewsSilent(stale []staleReview) {
+	sessDir, _ := sessionsDir()
+	for _, s := range stale {
+		if os.Remove(s.path) != nil {
+			continue
+		}
+		removeReviewExports(s.path)
+		removeReviewJournal(s.path)
+		removeReviewBackups(s.path)
+		removeReviewHistory(s.path)
+		removeResumeState(s.key)
+		if sessDir != "" {
+			os.Remove(filepath.Join(sessDir, s.key+".json"))
+			os.Remove(filepath.Join(sessDir, s.key+".lock"))
+			os.Remove(filepath.Join(sessDir, s.key+".log"))
+		}
+	}
+}
+
+// cleanupOnApproval deletes the review file when the review is approved
+// and cleanup is enabled.
+func cleanupOnApproval(approved bool, reviewPath string, cleanupEnabled bool) {
+	if approved && cleanupEnabled && reviewPath != "" {
+		os.Remove(reviewPath)
+		removeReviewExports(reviewPath)
+		removeReviewJournal(reviewPath)
+		removeReviewHistory(reviewPath)
+	}
+}
+
+func runPlan(args []string) {
+	go backgroundCleanup()
+
+	pc := resolvePlanConfig(args)
+	content := readPlanContent(pc)
+
+	slug := resolvePlanSlug(pc.name, content)
+	storageDir, err := planStorageDir(slug)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ver, err := savePlanVersion(storageDir, content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving plan: %v\n", err)
+		os.Exit(1)
+	}
+	if !pc.quiet {
+		if pc.name == "" {
+			fmt.Fprintf(os.Stderr, "No --name provided, derived slug: %s\n", slug)
+		}
+		fmt.Fprintf(os.Stderr, "Plan '%s' saved as v%03d (%d bytes)\n", slug, ver, len(content))
+	}
+
+	cwd, _ := resolvedCWD()
+	key := planSessionKey(cwd, slug)
+	currentPath := filepath.Join(storageDir, "current.md")
+	daemonArgs := buildPlanDaemonArgs(currentPath, storageDir, slug, pc.port, pc.noOpen, pc.quiet)
+
+	entry, weStartedDaemon := connectOrStartDaemon(key, daemonArgs, nil, pc.noOpen)
+	announceDaemon(os.Stderr, entry, weStartedDaemon, pc.quiet, pc.printURL)
+
+	if weStartedDaemon {
+		installDaemonSignalHandler(entry.PID)
+	}
+
+	approved := runReviewClient(entry, clientStatus(pc.quiet, false))
+	killDaemonOnApproval(approved && !pc.keepAlive, entry.PID)
+	cleanupOnApproval(approved, entry.ReviewPath, LoadConfig(cwd).CleanupOnApproveEnabled())
+}
+
+type planHookEvent struct {
+	SessionID string `json:"session_id"`
+	ToolInput struct {
+		Plan string `json:"plan"`
+	} `json:"tool_input"`
+}
+
+func resolveHookSlug(event planHookEvent, content []byte) string {
+	if event.SessionID != "" {
+		if existing, ok := lookupPlanSlug(event.SessionID); ok {
+			return existing
+		}
+		slug := resolveSlug(content)
+		if err := savePlanSlug(event.SessionID, slug); err != nil {
+			fmt.Fprintf(os.Stderr, "crit plan-hook: warning: could not save slug mapping: %v\n", err)
+		}
+		return slug
+	}
+	return resolveSlug(content)
+}
+
+func emitHookDecision(approved bool, prompt string) {
+	if approved {
+		out, _ := json.Marshal(map[string]any{
+			"hookSpecificOutput": map[string]any{
+				"hookEventName": "PermissionRequest",
+				"decision":      map[string]any{"behavior": "allow"},
+			},
+		})
+		fmt.Println(string(out))
+		return
+	}
+
+	if prompt == "" {
+		prompt = "Review comments pending — address them before proceeding."
+	}
+	out, _ := json.Marshal(map[string]any{
+		"hookSpecificOutput": map[string]any{
+			"hookEventName": "PermissionRequest",
+			"decision": map[string]any{
+				"behavior": "deny",
+				"message":  prompt,
+			},
+		},
+	})
+	fmt.Println(string(out))
+}
+
+// runPlanHook is the PermissionRequest hook handler for ExitPlanMode.
+// It reads the hook event JSON from stdin, extracts the plan content,
+// opens a crit review session, and writes a hookSpecificOutput JSON
+// decision (allow/deny) to stdout.
+func runPlanHook() {
+	go backgroundCleanup()
+
+	var event planHookEvent
+	if err := json.NewDecoder(os.Stdin).Decode(&event); err != nil {
+		fmt.Fprintf(os.Stderr, "crit plan-hook: could not parse stdin: %v\n", err)
+		return
+	}
+	if strings.TrimSpace(event.ToolInput.Plan) == "" {
+		return
+	}
+
+	content := []byte(event.ToolInput.Plan)
+	slug := resolveHookSlug(event, content)
+
+	storageDir, err := planStorageDir(slug)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "crit plan-hook: error resolving storage dir: %v\n", err)
+		return
+	}
+
+	ver, err := savePlanVersion(storageDir, content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "crit plan-hook: error saving plan: %v\n", err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "crit plan-hook: plan '%s' saved as v%03d\n", slug, ver)
+
+	cwd, _ := resolvedCWD()
+	key := planSessionKey(cwd, slug)
+	currentPath := filepath.Join(storageDir, "current.md")
+	daemonArgs := buildPlanDaemonArgs(currentPath, storageDir, slug, 0, false, false)
+
+	entry, alive := findAliveSession(key)
+	weStartedDaemon := false
+
+	if alive {
+		fmt.Fprintf(os.Stderr, "crit plan-hook: connected to daemon on port %d\n", entry.Port)
+		if !daemonHasBrowser(entry) {
+			go openBrowser(entry.url(""))
+		}
+	} else {
+		entry, err = startDaemon(key, daemonArgs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "crit plan-hook: error starting daemon: %v\n", err)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "crit plan-hook: started daemon on port %d (PID %d)\n", entry.Port, entry.PID)
+		weStartedDaemon = true
+	}
+
+	if weStartedDaemon {
+		installDaemonSignalHandler(entry.PID)
+	}
+
+	approved, prompt := runReviewClientRaw(entry)
+	killDaemonOnApproval(approved, entry.PID)
+	cleanupOnApproval(approved, entry.ReviewPath, LoadConfig(cwd).CleanupOnApproveEnabled())
+	emitHookDecision(approved, prompt)
+}
+
+// waitForDaemonReady polls the daemon's /api/session endpoint until it stops
+// returning 503 Service Unavailable (session not yet initialized). Returns the
+// last response status code and body, or an error if the daemon is unreachable
+// or the 5-minute deadline expires.
+func waitForDaemonReady(client *http.Client, entry sessionEntry) (statusCode int, body []byte, err error) {
+	deadline := time.Now().Add(5 * time.Minute)
+	for {
+		resp, reqErr := client.Get(entry.url("/api/session"))
+		if reqErr != nil {
+			return 0, nil, fmt.Errorf("could not reach daemon on port %d: %w", entry.Port, reqErr)
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			return resp.StatusCode, respBody, nil
+		}
+		if time.Now().After(deadline) {
+			return 0, nil, fmt.Errorf("daemon did not become ready within 5 minutes")
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// runReviewClientRaw is like runReviewClient but returns (approved, prompt)
+// without writing to stdout — used by runPlanHook to construct hookSpecificOutput.
+func runReviewClientRaw(entry sessionEntry) (approved bool, prompt string) {
+	client := &http.Client{Timeout: 24 * time.Hour}
+
+	// Wait for the server to finish initializing before calling review-cycle.
+	if _, _, err := waitForDaemonReady(client, entry); err != nil {
+		fmt.Fprintf(os.Stderr, "crit plan-hook: %v\n", err)
+		return true, ""
+	}
+
+	resp, err := client.Post(
+		entry.url("/api/review-cycle"),
+		"application/json",
+		nil,
+	)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "crit plan-hook: could not reach daemon: %v\n", err)
+		return true, "" // allow through on error
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "crit plan-hook: daemon returned %d\n", resp.StatusCode)
+		return true, "" // allow through on infrastructure error
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return true, ""
+	}
+
+	var result struct {
+		Approved bool   `json:"approved"`
+		Prompt   string `json:"prompt"`
+	}
+	json.Unmarshal(body, &result)
+	return result.Approved, result.Prompt
+}
+
+func runReview(args []string) {
+	// Parse args to extract file args (stripping flags like --port, --no-open).
+	// The session key must use only file args to match what runServe computes.
+	sc, err := resolveServerConfig(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if sc == nil {
+		return // --version
+	}
+	// After resolveServerConfig, which picks the config storageRoot reads.
+	go backgroundCleanup()
+
+	cwd, _ := resolvedCWD()
+	branch := ""
+	if vcs := DetectVCS(sc.vcsOverride); vcs != nil {
+		branch = vcs.CurrentBranch()
+	}
+	key := sessionKey(cwd, branch, sessionArgs(sc))
+
+	// Connect to a running daemon with the same session key or already
+	// reviewing the same files, or start one with the raw args — the _serve
+	// process parses them itself.
+	entry, weStartedDaemon := connectOrStartDaemon(key, args, reviewedFiles(cwd, sessionArgs(sc)), sc.noOpen)
+	announceDaemon(os.Stderr, entry, weStartedDaemon, sc.quiet, sc.printURL)
+
+	// If we started the daemon, clean it up on Ctrl+C
+	if weStartedDaemon {
+		installDaemonSignalHandler(entry.PID)
+	}
+
+	approved := runReviewClient(entry, clientStatus(sc.quiet, sc.plain))
+	if sc.bell && sc.bellCommand == "" {
+		(&Status{w: os.Stderr, bell: true}).ring()
+	}
+	killDaemonOnApproval(approved && !sc.keepAlive, entry.PID)
+	cleanupOnApproval(approved, entry.ReviewPath, LoadConfig(cwd).CleanupOnApproveEnabled())
+}
+
+// clientStatus returns the status writer for the live status line a waiting
+// crit client draws on stderr: redrawn in place on a terminal, one line per
+// change with --plain. Piped stderr without --plain (an agent running crit)
+// stays silent, as does --quiet.
+func clientStatus(quiet, plain bool) *Status {
+	if quiet {
+		return nil
+	}
+	fi, err := os.Stderr.Stat()
+	tty := err == nil && fi.Mode()&os.ModeCharDevice != 0
+	if !tty && !plain {
+		return nil
+	}
+	return &Status{w: os.Stderr, color: tty && !plain, live: tty && !plain}
+}
+
+// watchLiveStatus polls the daemon's /api/health once a second and shows its
+// session state on st until the returned stop function is called.
+func watchLiveStatus(entry sessionEntry, st *Status) (stop func()) {
+	if st == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			if health, ok := fetchLiveStatus(entry); ok {
+				st.Live(health, time.Now())
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+		st.EndLive()
+	}
+}
+
+func fetchLiveStatus(entry sessionEntry) (liveStatus, bool) {
+	resp, err := browserClient.Get(entry.url("/api/health"))
+	if err != nil {
+		return liveStatus{}, false
+	}
+	defer resp.Body.Close()
+	var health struct {
+		Session *liveStatus `json:"session"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil || health.Session == nil {
+		return liveStatus{}, false
+	}
+	return *health.Session, true
+}
+
+// readReviewCycleResponse reads and closes the response body, returning an
+// error for non-success status codes. This avoids exitAfterDefer by ensuring
+// the body is closed before the caller decides to os.Exit.
+func readReviewCycleResponse(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGatewayTimeout {
+		return nil, fmt.Errorf("timeout waiting for review")
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	return body, nil
+}
+
+// runReviewClient connects to a running daemon/server, blocks until the user
+// finishes reviewing, prints feedback to stdout, and returns whether the
+// review was approved (no unresolved comments).
+func runReviewClient(entry sessionEntry, st *Status) (approved bool) {
+	client := &http.Client{Timeout: 24 * time.Hour}
+
+	// Wait for the server to finish initializing before calling review-cycle.
+	statusCode, body, err := waitForDaemonReady(client, entry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if statusCode == http.StatusInternalServerError {
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &status) == nil && status.Message != "" {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", status.Message)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: %s\n", body)
+		}
+		os.Exit(1)
+	}
+
+	stop := watchLiveStatus(entry, st)
+	resp, err := client.Post(
+		entry.url("/api/review-cycle"),
+		"application/json",
+		nil,
+	)
+	stop()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: could not reach crit daemon on port %d: %v\n", entry.Port, err)
+		os.Exit(1)
+	}
+
+	body, err = readReviewCycleResponse(resp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Print feedback to stdout
+	os.Stdout.Write(body)
+
+	// Check if the review was approved (no unresolved comments).
+	var result struct {
+		Approved bool `json:"approved"`
+	}
+	if json.Unmarshal(body, &result) == nil {
+		return result.Approved
+	}
+	return false
+}
+
+// TODO: runStop, runStatus, and other subcommands use DetectVCS("") for auto-detection.
+// The --vcs flag from the main server command is not threaded through to these subcommands yet.
+// This is acceptable for v1 since subcommands primarily need to locate the daemon, not run VCS ops.
+func runStop(args []string) {
+	all := false
+	var fileArgs []string
+	for _, arg := range args {
+		if arg == "--all" {
+			all = true
+		} else {
+			fileArgs = append(fileArgs, arg)
+		}
+	}
+
+	cwd, _ := resolvedCWD()
+
+	if all {
+		stopAllDaemonsForCWD(cwd)
+		fmt.Println("All daemons stopped.")
+		return
+	}
+
+	branch := ""
+	if vcs := DetectVCS(""); vcs != nil {
+		branch = vcs.CurrentBranch()
+	}
+
+	// If file args were given, use the exact key (user knows which session).
+	if len(fileArgs) > 0 {
+		key := sessionKey(cwd, branch, fileArgs)
+		if err := stopDaemon(key); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Daemon stopped.")
+		return
+	}
+
+	// No file args: try exact key first (git-mode session with no args).
+	key := sessionKey(cwd, branch, nil)
+	if entry, _ := readSessionFile(key); entry.PID > 0 {
+		if err := stopDaemon(key); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Daemon stopped.")
+		return
+	}
+
+	// Exact key not found — fall back to scanning by cwd + branch.
+	_, foundKey, matchCount := findSessionForCWDBranch(cwd, branch)
+	if matchCount > 1 {
+		fmt.Fprintf(os.Stderr, "Error: multiple daemons running on branch %q. Use 'crit stop --all' or specify file args.\n", branch)
+		os.Exit(1)
+	}
+	if matchCount == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no running daemon found for current directory and branch.")
+		os.Exit(1)
+	}
+
+	if err := stopDaemon(foundKey); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Daemon stopped.")
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
+
+func pluralReply(n int) string {
+	if n == 1 {
+		return "y"
+	}
+	return "ies"
+}
+
+// serverConfig holds the resolved configuration for running the server.
+// It combines CLI flags, environment variables, and config file settings.
+type serverConfig struct {
+	port               int
+	noOpen             bool
+	quiet              bool
+	shareURL           string
+	authToken          string
+	outputDir          string
+	author             string
+	baseBranch         string // --base-branch override for diff base
+	ignorePatterns     []string
+	files              []string // explicit file arguments (empty = git mode)
+	noIntegrationCheck bool
+	noUpdateCheck      bool
+	agentCmd           string
+	planDir            string             // managed storage directory for plan mode
+	compareBase        string             // crit compare: the old file the single file argument is diffed against
+	resume             bool               // crit resume: restore the state the last daemon saved
+	planName           string             // display name for plan content
+	reviewPath         string             // centralized review file path (~/.crit/reviews/<key>.json)
+	vcsOverride        string             // "git", "sl"/"sapling", or "" for auto-detect
+	reviewTemplate     *template.Template // --review-template / review_template; nil = built-in prompt
+	reviewStyle        string             // --review-style / review_style: "verbose" or "compact"
+	reviewFileName     string             // review file name inside outputDir, rendered from review_filename
+	checklist          []string           // review checklist items from checklist / checklist_file
+	notify             bool               // --notify: desktop notification on round-complete
+	bell               bool               // --bell / bell: ring on round-complete and finish
+	bellCommand        string             // bell_command: sound command replacing the terminal bell
+	hooks              hookCommands       // hooks: commands run on comment, round-complete and finish
+	webhooks           []webhookConfig    // webhooks: URLs receiving review events
+	notifiers          []notifierConfig   // notifiers: Slack/Discord summaries on round-complete and finish
+	jira               jiraConfig         // jira: creating issues from comments
+	linear             linearConfig       // linear: creating issues from comments
+	linters            []linterConfig     // linters: run on the files on load and after each round
+	proseCheck         bool               // prose_check: built-in markdown checks
+	plain              bool               // --plain: status line printed per change instead of redrawn
+	printURL           bool               // --print-url: with --quiet, still print the review URL
+	metrics            bool               // --metrics: serve Prometheus metrics at /metrics
+	keepAlive          bool               // --keep-alive: don't stop the daemon after an approved review
+	idleTimeout        time.Duration      // --idle-timeout: shut down after this long without requests or tabs
+	timeout            time.Duration      // --timeout: finish the review and exit this long after start
+	logger             *slog.Logger       // --log-level / --log-format: daemon diagnostics
+	updatePolicy       updatePolicy       // update_channel, update_check_interval, update_proxy
+	cfg                Config             // full resolved config for the settings panel
+}
+
+// serverFlagSet holds the parsed flag values before config resolution.
+type serverFlagSet struct {
+	port        int
+	noOpen      bool
+	showVersion bool
+	shareURL    string
+	outputDir   string
+	quiet       bool
+	noIgnore    bool
+	baseBranch  string
+	vcsOverride string
+	planDir     string
+	planName    string
+	reviewTmpl  string
+	reviewStyle string
+	notify      bool
+	bell        bool
+	plain       bool
+	printURL    bool
+	metrics     bool
+	keepAlive   bool
+	idleTimeout time.Duration
+	timeout     time.Duration
+	logLevel    string
+	logFormat   string
+	rev         string
+	compareBase string
+	resume      bool
+	fileArgs    []string
+}
+
+func parseServerFlags(args []string) serverFlagSet {
+	fs := flag.NewFlagSet("crit", flag.ExitOnError)
+	port := fs.Int("port", 0, "Port to listen on (default: random available port)")
+	fs.IntVar(port, "p", 0, "Port to listen on (shorthand)")
+	noOpen := fs.Bool("no-open", false, "Don't auto-open browser")
+	showVersion := fs.Bool("version", false, "Print version and exit")
+	fs.BoolVar(showVersion, "v", false, "Print version and exit (shorthand)")
+	shareURL := fs.String("share-url", "", "Base URL of hosted Crit service for sharing reviews (overrides CRIT_SHARE_URL env var)")
+	outputDir := fs.String("output", "", "Output directory for review file (default: repo root or file directory)")
+	fs.StringVar(outputDir, "o", "", "Output directory for review file (shorthand)")
+	quiet := fs.Bool("quiet", false, "Suppress status output")
+	fs.BoolVar(quiet, "q", false, "Suppress status output (shorthand)")
+	noIgnore := fs.Bool("no-ignore", false, "Disable all ignore patterns from config files")
+	baseBranch := fs.String("base-branch", "", "Base branch to diff against (overrides auto-detection)")
+	vcsFlag := fs.String("vcs", "", "VCS backend to use: git, sl/sapling (default: auto-detect)")
+	planDir := fs.String("plan-dir", "", "")
+	planName := fs.String("name", "", "")
+	reviewTmpl := fs.String("review-template", "", "Go text/template file for the prompt sent to the agent")
+	reviewStyle := fs.String("review-style", "", "Prompt sent to the agent: verbose (default) or compact")
+	notify := fs.Bool("notify", false, "Show a desktop notification when the agent completes a round")
+	bell := fs.Bool("bell", false, "Ring the terminal bell (or bell_command) on round-complete and finish")
+	plain := fs.Bool("plain", false, "Print one status line per change instead of redrawing a live line")
+	printURL := fs.Bool("print-url", true, "With --quiet, still print the review URL")
+	metrics := fs.Bool("metrics", false, "Serve Prometheus metrics at /metrics")
+	keepAlive := fs.Bool("keep-alive", false, "Keep the daemon running after an approved review")
+	idleTimeout := fs.Duration("idle-timeout", time.Hour, "Shut down after this long without requests or open browser tabs (0 = never)")
+	timeout := fs.Duration("timeout", 0, "Finish the review with the comments so far and exit after this long (0 = no limit)")
+	logLevel := fs.String("log-level", "info", "Minimum level of daemon log messages: debug, info, warn or error")
+	logFormat := fs.String("log-format", logFormatText, "Daemon log format: text or json")
+	rev := fs.String("rev", "", "Review the file arguments as they were at this git revision")
+	compareBase := fs.String("compare-base", "", "")
+	resume := fs.Bool("resume", false, "")
+	fs.Usage = func() {
+		printHelp()
+	}
+	fs.Parse(args)
+
+	return serverFlagSet{
+		port:        *port,
+		noOpen:      *noOpen,
+		showVersion: *showVersion,
+		shareURL:    *shareURL,
+		outputDir:   *outputDir,
+		quiet:       *quiet,
+		noIgnore:    *noIgnore,
+		baseBranch:  *baseBranch,
+		vcsOverride: *vcsFlag,
+		planDir:     *planDir,
+		planName:    *planName,
+		reviewTmpl:  *reviewTmpl,
+		reviewStyle: *reviewStyle,
+		notify:      *notify,
+		bell:        *bell,
+		plain:       *plain,
+		printURL:    *printURL,
+		metrics:     *metrics,
+		keepAlive:   *keepAlive,
+		idleTimeout: *idleTimeout,
+		timeout:     *timeout,
+		logLevel:    *logLevel,
+		logFormat:   *logFormat,
+		rev:         *rev,
+		compareBase: *compareBase,
+		resume:      *resume,
+		fileArgs:    fs.Args(),
+	}
+}
+
+// applyConfigDefaults fills flags that weren't given on the command line from
+// cfg, which LoadConfig has already resolved from env and config files.
+func applyConfigDefaults(sf *serverFlagSet, cfg Config) {
+	if sf.port == 0 {
+		sf.port = cfg.Port
+	}
+	if !sf.noOpen && cfg.NoOpen {
+		sf.noOpen = true
+	}
+	sf.shareURL = resolveShareURL(sf.shareURL, cfg, "")
+	if !sf.quiet && cfg.Quiet {
+		sf.quiet = true
+	}
+	if sf.outputDir == "" && cfg.Output != "" {
+		sf.outputDir = cfg.Output
+	}
+	if sf.baseBranch == "" && cfg.BaseBranch != "" {
+		sf.baseBranch = cfg.BaseBranch
+	}
+	if sf.reviewStyle == "" {
+		sf.reviewStyle = cfg.ReviewStyle
+	}
+	if !sf.bell && cfg.Bell {
+		sf.bell = true
+	}
+	if sf.baseBranch != "" {
+		setDefaultBranchOverride(sf.baseBranch)
+	}
+}
+
+// validateIntegrations checks the config of everything crit sends review
+// content to: webhooks, notifiers, issue trackers, shares and the mail server.
+func validateIntegrations(cfg Config) error {
+	if err := validateWebhooks(cfg.Webhooks); err != nil {
+		return err
+	}
+	if err := validateNotifiers(cfg.Notifiers); err != nil {
+		return err
+	}
+	if err := validateJira(cfg.Jira); err != nil {
+		return err
+	}
+	if err := validateLinear(cfg.Linear); err != nil {
+		return err
+	}
+	if _, err := parseShareExpiry(cfg.ShareExpires); err != nil {
+		return err
+	}
+	return validateSMTP(cfg.SMTP)
+}
+
+// validateDaemonConfig checks the config keys the daemon acts on: the
+// integrations, linters, CORS origins, UI language and timezone.
+func validateDaemonConfig(cfg Config) error {
+	if err := validateIntegrations(cfg); err != nil {
+		return err
+	}
+	if err := validateLinters(cfg.Linters); err != nil {
+		return err
+	}
+	if err := validateCORSOrigins(cfg.CORSOrigins); err != nil {
+		return err
+	}
+	if err := validateLanguage(cfg.Language); err != nil {
+		return err
+	}
+	if err := validateDuplicateComments(cfg.DuplicateComments); err != nil {
+		return err
+	}
+	_, err := loadTimezone(cfg.Timezone)
+	return err
+}
+
+// serverConfigDir returns the directory project config discovery starts
+// from: the repo root or the working directory, or for file arguments the
+// first file's directory, so a plan in a subdirectory picks up the config
+// next to it.
+func serverConfigDir(sf serverFlagSet) string {
+	configDir := ""
+	if vcs := DetectVCS(sf.vcsOverride); vcs != nil {
+		configDir, _ = vcs.RepoRoot()
+	}
+	if configDir == "" {
+		configDir, _ = os.Getwd()
+	}
+	if len(sf.fileArgs) > 0 {
+		configDir = configSearchDir(sf.fileArgs[0], configDir)
+	}
+	return configDir
+}
+
+// resolveServerConfig parses flags, loads config files, and resolves the
+// final server configuration from all sources (CLI > env > config > defaults).
+// Returns nil when the command should exit early (e.g. --version).
+func resolveServerConfig(args []string) (*serverConfig, error) {
+	sf := parseServerFlags(args)
+
+	if sf.showVersion {
+		printVersion()
+		return nil, nil
+	}
+
+	configDir := serverConfigDir(sf)
+	useStorageConfigDir(configDir)
+	cfg := LoadConfig(configDir)
+
+	applyConfigDefaults(&sf, cfg)
+	files, err := applyRevisionFlag(sf.fileArgs, sf.rev)
+	if err != nil {
+		return nil, err
+	}
+	sf.fileArgs = files
+	if err := validateCompareArgs(sf.compareBase, sf.fileArgs); err != nil {
+		return nil, err
+	}
+
+	// Catch `crit binary.pdf` before a daemon and browser are started for it.
+	for _, arg := range sf.fileArgs {
+		if info, err := os.Stat(arg); err == nil && !info.IsDir() && !isTextFile(arg) {
+			return nil, notTextError(arg)
+		}
+	}
+
+	templateBase := filepath.Dir(findProjectConfig(configDir))
+	reviewTemplate, err := loadReviewTemplate(resolveReviewTemplatePath(sf.reviewTmpl, cfg.ReviewTemplate, templateBase))
+	if err != nil {
+		return nil, err
+	}
+	reviewStyle, err := validReviewStyle(sf.reviewStyle)
+	if err != nil {
+		return nil, err
+	}
+	checklist, err := loadChecklist(cfg.Checklist, cfg.ChecklistFile, templateBase)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := newLogger(os.Stderr, sf.logLevel, sf.logFormat)
+	if err != nil {
+		return nil, err
+	}
+	updatePolicy, err := resolveUpdatePolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDaemonConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	var ignorePatterns []string
+	if !sf.noIgnore {
+		ignorePatterns = cfg.IgnorePatterns
+	}
+
+	return &serverConfig{
+		port:               sf.port,
+		noOpen:             sf.noOpen,
+		quiet:              sf.quiet,
+		shareURL:           sf.shareURL,
+		authToken:          cfg.AuthToken,
+		outputDir:          sf.outputDir,
+		author:             cfg.Author,
+		baseBranch:         sf.baseBranch,
+		ignorePatterns:     ignorePatterns,
+		noIntegrationCheck: cfg.NoIntegrationCheck,
+		noUpdateCheck:      cfg.NoUpdateCheck,
+		agentCmd:           cfg.AgentCmd,
+		files:              sf.fileArgs,
+		compareBase:        sf.compareBase,
+		resume:             sf.resume,
+		planDir:            sf.planDir,
+		planName:           sf.planName,
+		vcsOverride:        resolveVCSOverride(sf.vcsOverride, cfg.VCS),
+		reviewTemplate:     reviewTemplate,
+		reviewStyle:        reviewStyle,
+		checklist:          checklist,
+		notify:             sf.notify,
+		bell:               sf.bell,
+		bellCommand:        cfg.BellCommand,
+		hooks:              cfg.Hooks,
+		webhooks:           cfg.Webhooks,
+		notifiers:          cfg.Notifiers,
+		jira:               cfg.Jira,
+		linear:             cfg.Linear,
+		linters:            cfg.Linters,
+		proseCheck:         cfg.ProseCheck,
+		plain:              sf.plain,
+		printURL:           sf.printURL,
+		metrics:            sf.metrics,
+		keepAlive:          sf.keepAlive,
+		idleTimeout:        sf.idleTimeout,
+		timeout:            sf.timeout,
+		logger:             logger,
+		updatePolicy:       updatePolicy,
+		cfg:                cfg,
+	}, nil
+}
+
+// configSearchDir returns the directory project config discovery starts from
+// for a file argument: the argument itself if it is a directory, otherwise
+// its parent. Falls back to fallback when the argument doesn't exist.
+func configSearchDir(arg, fallback string) string {
+	abs, err := filepath.Abs(arg)
+	if err != nil {
+		return fallback
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return fallback
+	}
+	if info.IsDir() {
+		return abs
+	}
+	return filepath.Dir(abs)
+}
+
+// resolveVCSOverride returns the effective VCS override.
+// --vcs flag takes precedence over config "vcs" field.
+func resolveVCSOverride(flag, config string) string {
+	if flag != "" {
+		return flag
+	}
+	return config
+}
+
+func createSession(sc *serverConfig) (*Session, error) {
+	var session *Session
+	var err error
+	if len(sc.files) == 0 {
+		vcs := DetectVCS(sc.vcsOverride)
+		if vcs == nil {
+			return nil, fmt.Errorf("not in a version-controlled repository and no files specified")
+		}
+		if sc.baseBranch != "" {
+			vcs.SetDefaultBranchOverride(sc.baseBranch)
+		}
+		session, err = NewSessionFromVCS(vcs, sc.ignorePatterns)
+	} else {
+		outputDir := ""
+		if sc.outputDir != "" {
+			outputDir, _ = filepath.Abs(sc.outputDir)
+		}
+		files, remotes, remoteErr := resolveRemoteArgs(sc.files, outputDir)
+		if remoteErr != nil {
+			return nil, remoteErr
+		}
+		files, snaps, revErr := resolveRevisionArgs(files, outputDir)
+		if revErr != nil {
+			return nil, revErr
+		}
+		session, err = NewSessionFromFiles(files, sc.ignorePatterns)
+		if err == nil {
+			session.adoptRemoteDocuments(remotes)
+			session.adoptRevisionSnapshots(snaps)
+		}
+		if err == nil && sc.compareBase != "" {
+			err = session.setCompareBase(sc.compareBase)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Apply --base-branch override to the session's VCS instance. This covers
+	// files mode where resolveGitContext creates a fresh VCS that doesn't have
+	// the override yet. For Sapling, the instance-level field must be set.
+	if sc.baseBranch != "" && session.VCS != nil {
+		session.VCS.SetDefaultBranchOverride(sc.baseBranch)
+	}
+	// Set ReviewFilePath before loadCritJSON so it reads from the centralized
+	// review file.
+	if sc.reviewPath != "" {
+		session.ReviewFilePath = sc.reviewPath
+		session.enableNotesBackend(sc.cfg.Backend)
+		session.exportFormats = validExportFormats(sc.cfg.OutputFormats)
+		session.backups = sc.cfg.BackupCount()
+		session.displayLoc, _ = loadTimezone(sc.cfg.Timezone) // checked by validateDaemonConfig
+		session.loadCritJSON()
+	}
+	return session, nil
+}
+
+func applySessionOverrides(session *Session, sc *serverConfig) {
+	if sc.planDir != "" {
+		applyPlanOverrides(session, sc.planDir, sc.planName)
+		for _, f := range session.Files {
+			f.Comments = []Comment{}
+		}
+		session.reviewComments = nil
+		session.loadCritJSON()
+	}
+	if sc.outputDir != "" {
+		abs, _ := filepath.Abs(sc.outputDir)
+		session.OutputDir = abs
+		session.reviewFileName = sc.reviewFileName
+	}
+	session.setChecklist(sc.checklist)
+	session.linters = sc.linters
+	session.proseCheck = sc.proseCheck
+}
+
+// daemonBellStatus returns the status writer the daemon rings bell_command
+// through on round-complete and finish, or nil. The daemon has no terminal,
+// so the plain terminal bell is rung by the waiting crit client instead, when
+// the review finishes.
+func daemonBellStatus(sc *serverConfig) *Status {
+	if !sc.bell || sc.bellCommand == "" {
+		return nil
+	}
+	return &Status{w: io.Discard, bell: true, bellCmd: sc.bellCommand}
+}
+
+func bindListener(port int) (net.Listener, error) {
+	var listener net.Listener
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		listener, err = net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+		if err == nil {
+			return listener, nil
+		}
+		if port == 0 {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return nil, err
+}
+
+func serveSessionKey(sc *serverConfig) string {
+	cwd, _ := resolvedCWD()
+	if sc.planDir != "" {
+		return planSessionKey(cwd, sc.planName)
+	}
+	branch := ""
+	if vcs := DetectVCS(sc.vcsOverride); vcs != nil {
+		branch = vcs.CurrentBranch()
+	}
+	return sessionKey(cwd, branch, sessionArgs(sc))
+}
+
+func checkStaleIntegrations(sc *serverConfig, srv *Server, cwd string) {
+	if sc.noIntegrationCheck {
+		return
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		stale := checkInstalledIntegrations(cwd, home)
+		srv.staleIntegrations = stale
+		if len(stale) > 0 {
+			go printStaleWarnings(stale)
+		}
+	}
+}
+
+// runIdleTimeoutChecker stops the daemon (which then writes its files and
+// exits) once it has served no HTTP request and had no browser tab connected
+// for timeout. A timeout of zero or less never stops it.
+func runIdleTimeoutChecker(ctx context.Context, stop context.CancelFunc, timeout time.Duration, idleMu *sync.Mutex, lastActivity *time.Time, connected func() bool) {
+	if timeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(min(5*time.Minute, timeout/2))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			idleMu.Lock()
+			if connected() {
+				*lastActivity = time.Now()
+			}
+			idle := time.Since(*lastActivity)
+			idleMu.Unlock()
+			if idle >= timeout {
+				slog.Info("idle timeout reached, shutting down", "idle", idle.Round(time.Second))
+				stop()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runSessionTimeout finishes the review with whatever comments exist once
+// timeout has passed since the daemon started, then stops the daemon. The
+// waiting crit client receives the feedback as if Finish had been clicked.
+// A timeout of zero or less never finishes it.
+func runSessionTimeout(ctx context.Context, stop context.CancelFunc, started time.Time, timeout time.Duration, srv *Server, sess *Session) {
+	if timeout <= 0 {
+		return
+	}
+	timer := time.NewTimer(time.Until(started.Add(timeout)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		slog.Info("session timeout reached, finishing the review")
+		srv.finish(sess)
+		stop()
+	case <-ctx.Done():
+	}
+}
+
+// configureServer sets the daemon's config-dependent Server fields: the
+// settings panel, notifications and integrations, the request guards,
+// update checks and the optional metrics endpoint.
+func configureServer(srv *Server, sc *serverConfig) {
+	srv.cfg = sc.cfg
+	srv.reviewTemplate = sc.reviewTemplate
+	srv.reviewStyle = sc.reviewStyle
+	srv.notify = sc.notify
+	srv.hooks = sc.hooks
+	srv.webhooks = sc.webhooks
+	srv.notifiers = sc.notifiers
+	srv.jira = sc.jira
+	srv.linear = sc.linear
+	srv.status = daemonBellStatus(sc)
+	srv.updatePolicy = sc.updatePolicy
+	srv.limiter = newRateLimiter(sc.cfg.RateLimit)
+	srv.checkHost = true
+	srv.accessToken = rand.Text()
+	srv.corsOrigins = sc.cfg.CORSOrigins
+	if root, err := globalStorageRoot(); err == nil {
+		srv.updateCachePath = filepath.Join(root, updateCheckCacheFile)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		srv.homeDir = home
+	}
+	if sc.metrics {
+		srv.enableMetrics()
+	}
+}
+
+func runServe(args []string) {
+	pipe := openReadyPipe()
+	started := time.Now()
+
+	sc, err := resolveServerConfig(args)
+	if err != nil {
+		daemonFatal(pipe, "Error: %v", err)
+	}
+	if sc == nil {
+		return
+	}
+	sc.quiet = true
+	slog.SetDefault(sc.logger)
+
+	listener, err := bindListener(sc.port)
+	if err != nil {
+		daemonFatal(pipe, "Error starting server: %v", err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+
+	srv, err := NewServer(nil, frontendFS, sc.shareURL, sc.authToken, sc.author, version, addr.Port, sc.agentCmd)
+	if err != nil {
+		daemonFatal(pipe, "Error creating server: %v", err)
+	}
+
+	configureServer(srv, sc)
+	cwd, _ := resolvedCWD()
+	srv.projectDir = cwd
+	key := serveSessionKey(sc)
+	branch := ""
+	if vcs := DetectVCS(sc.vcsOverride); vcs != nil {
+		branch = vcs.CurrentBranch()
+	}
+	if sc.outputDir != "" {
+		abs, _ := filepath.Abs(sc.outputDir)
+		sc.reviewFileName, err = renderReviewFileName(sc.cfg.ReviewFileName, reviewNameData{
+			Base:   reviewNameBase(sc.files, cwd),
+			Key:    key,
+			Branch: branch,
+		})
+		if err != nil {
+			daemonFatal(pipe, "Error: %v", err)
+		}
+		sc.reviewPath = filepath.Join(abs, sc.reviewFileName)
+	} else {
+		sc.reviewPath, _ = reviewFilePath(key)
+	}
+	srv.reviewPath = sc.reviewPath
+	lock := mustLockReviewFile(pipe, sc.reviewPath, addr.Port)
+	entry := sessionEntry{
+		PID:        os.Getpid(),
+		Port:       addr.Port,
+		CWD:        cwd,
+		Args:       sessionArgs(sc),
+		Branch:     branch,
+		ReviewPath: sc.reviewPath,
+		StartedAt:  time.Now().UTC().Format(time.RFC3339),
+		Token:      srv.accessToken,
+	}
+	if err := writeSessionFile(key, entry); err != nil {
+		daemonFatal(pipe, "Error writing session file: %v", err)
+	}
+	rekeyer := &sessionRekeyer{key: key, entry: entry, keepReviewPath: sc.outputDir != "", lock: lock}
+
+	var idleMu sync.Mutex
+	lastActivity := time.Now()
+	resetActivity := func() {
+		idleMu.Lock()
+		lastActivity = time.Now()
+		idleMu.Unlock()
+	}
+
+	httpServer := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			resetActivity()
+			srv.ServeHTTP(w, r)
+		}),
+		ReadTimeout: 15 * time.Second,
+		IdleTimeout: 60 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+
+	go func() {
+		if err := httpServer.Serve(listener); err != http.ErrServerClosed {
+			slog.Error("server error", "err", err)
+			stop()
+		}
+	}()
+
+	signalReadiness(pipe, addr.Port)
+
+	if !sc.noOpen {
+		go openBrowser(entry.url(""))
+	}
+
+	go runIdleTimeoutChecker(ctx, stop, sc.idleTimeout, &idleMu, &lastActivity, srv.hasBrowserClients)
+
+	type sessionResult struct {
+		session *Session
+		err     error
+	}
+	ch := make(chan sessionResult, 1)
+	// NOTE: On timeout, the createSession goroutine will leak until its git
+	// operations finish (no context is threaded into the git calls). This is
+	// acceptable because the timeout path sets initErr, which triggers a full
+	// server shutdown and process exit shortly after, cleaning up all goroutines.
+	go func() {
+		s, err := createSession(sc)
+		ch <- sessionResult{s, err}
+	}()
+
+	var session *Session
+	var initErr error
+	select {
+	case res := <-ch:
+		session, initErr = res.session, res.err
+	case <-time.After(2 * time.Minute):
+		initErr = fmt.Errorf("session initialization timed out after 2 minutes")
+	}
+	if initErr != nil {
+		slog.Error("session init failed", "err", initErr)
+		srv.SetInitErr(initErr)
+		<-ctx.Done()
+		removeSessionFile(key)
+		shutCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutCtx)
+		return
+	}
+	applySessionOverrides(session, sc)
+	session.CLIArgs = sessionArgs(sc)
+	session.onRename = rekeyer.rekey
+	session.archiveDir, _ = reviewHistoryDir(session.critJSONPath())
+	session.enableWAL()
+	session.enableResume(key, sc.resume)
+
+	checkStaleIntegrations(sc, srv, cwd)
+	applyGitignorePolicy(sc.cfg.Gitignore, session)
+
+	if !sc.noUpdateCheck {
+		go srv.CheckForUpdates()
+	}
+	session.status = srv.status
+	srv.SetSession(session)
+
+	if session.Mode == "git" {
+		go func() {
+			if prInfo := detectPRInfo(); prInfo != nil {
+				srv.SetPRInfo(prInfo)
+			}
+		}()
+	}
+
+	watchStop := make(chan struct{})
+	go session.Watch(watchStop)
+	go session.runLinters()
+	go runSessionTimeout(ctx, stop, started, sc.timeout, srv, session)
+
+	<-ctx.Done()
+	close(watchStop)
+
+	removeSessionFile(rekeyer.currentKey())
+	session.Shutdown()
+	session.WriteFiles()
+	session.saveResumeState()
+	session.syncReviewNote()
+	lock.release()
+
+	if session.ReviewFilePath != "" {
+		fmt.Fprintf(os.Stderr, "Review file: %s\n", session.ReviewFilePath)
+	}
+
+	shutCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = httpServer.Shutdown(shutCtx)
+}
+
+func runStatus(args []string) {
+	jsonOutput := false
+	for _, arg := range args {
+		if arg == "--json" {
+			jsonOutput = true
+		}
+	}
+
+	cwd, err := resolvedCWD()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	vcsName := ""
+	branch := ""
+	if vcs := DetectVCS(""); vcs != nil {
+		vcsName = vcs.Name()
+		branch = vcs.CurrentBranch()
+	}
+
+	sessions, keys := listSessionsForCWD(cwd)
+	var matchedSession *sessionEntry
+	for i, s := range sessions {
+		if s.Branch == branch || (branch == "" && len(sessions) == 1) {
+			matchedSession = &sessions[i]
+			_ = keys[i]
+			break
+		}
+	}
+
+	var revPath string
+	if matchedSession != nil && matchedSession.ReviewPath != "" {
+		revPath = matchedSession.ReviewPath
+	} else {
+		key := sessionKey(cwd, branch, nil)
+		revPath, _ = reviewFilePath(key)
+	}
+
+	revExists := false
+	if _, statErr := os.Stat(revPath); statErr == nil {
+		revExists = true
+	}
+
+	if jsonOutput {
+		printStatusJSON(vcsName, branch, revPath, revExists, matchedSession)
+		return
+	}
+
+	printStatusHuman(vcsName, branch, revPath, revExists, matchedSession)
+}
+
+func printStatusJSON(vcsName, branch, revPath string, revExists bool, session *sessionEntry) {
+	result := map[string]interface{}{
+		"vcs":                vcsName,
+		"branch":             branch,
+		"review_file":        revPath,
+		"review_file_exists": revExists,
+	}
+	daemon := map[string]interface{}{"running": false}
+	if session != nil {
+		daemon["running"] = true
+		daemon["pid"] = session.PID
+		daemon["port"] = session.Port
+		daemon["token"] = session.Token
+		daemon["url"] = session.url("")
+	}
+	result["daemon"] = daemon
+
+	if revExists {
+		addReviewStats(result, revPath)
+	}
+
+	data, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(data))
+}
+
+func addReviewStats(result map[string]interface{}, revPath string) {
+	data, err := os.ReadFile(revPath)
+	if err != nil {
+		return
+	}
+	var cj CritJSON
+	if unmarshalCritJSON(data, &cj) != nil {
+		return
+	}
+	result["round"] = cj.ReviewRound
+	unresolved, resolved := countComments(cj)
+	result["comments"] = map[string]int{
+		"unresolved": unresolved,
+		"resolved":   resolved,
+	}
+	if sh, ok := currentShare(cj, time.Now()); ok {
+		result["share"] = map[string]string{"id": sh.ID, "url": sh.URL, "expires_at": sh.ExpiresAt}
+	}
+}
+
+func printStatusHuman(vcsName, branch, revPath string, revExists bool, session *sessionEntry) {
+	if vcsName != "" {
+		fmt.Printf("VCS:         %s\n", vcsName)
+	}
+	if branch != "" {
+		fmt.Printf("Branch:      %s\n", branch)
+	}
+	fmt.Printf("Review file: %s\n", revPath)
+	if session != nil {
+		fmt.Printf("Daemon:      running (PID %d, port %d)\n", session.PID, session.Port)
+		fmt.Printf("URL:         %s\n", session.url(""))
+	} else {
+		fmt.Println("Daemon:      not running")
+	}
+	if !revExists {
+		return
+	}
+	data, err := os.ReadFile(revPath)
+	if err != nil {
+		return
+	}
+	var cj CritJSON
+	if unmarshalCritJSON(data, &cj) != nil {
+		return
+	}
+	fmt.Printf("Round:       %d\n", cj.ReviewRound)
+	unresolved, resolved := countComments(cj)
+	fmt.Printf("Comments:    %d unresolved, %d resolved\n", unresolved, resolved)
+	if sh, ok := currentShare(cj, time.Now()); ok {
+		fmt.Printf("Share:       %s (%s)\n", sh.ID, sh.URL)
+	}
+}
+
+func countComments(cj CritJSON) (unresolved, resolved int) {
+	for _, f := range cj.Files {
+		for _, c := range f.Comments {
+			if c.Resolved {
+				resolved++
+			} else {
+				unresolved++
+			}
+		}
+	}
+	for _, c := range cj.ReviewComments {
+		if c.Resolved {
+			resolved++
+		} else {
+			unresolved++
+		}
+	}
+	return
+}
+
+func runCleanup(args []string) {
+	days := 7
+	force := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--days":
+			if i+1 < len(args) {
+				i++
+				d, err := strconv.Atoi(args[i])
+				if err != nil || d < 0 {
+					fmt.Fprintf(os.Stderr, "Error: invalid --days value\n")
+					os.Exit(1)
+				}
+				days = d
+			}
+		case "--force":
+			force = true
+		default:
+			fmt.Fprintf(os.Stderr, "Usage: crit cleanup [--days N] [--force]\n")
+			os.Exit(1)
+		}
+	}
+
+	revDir, err := reviewsDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	stale := findStaleReviews(revDir, days)
+	if len(stale) == 0 {
+		fmt.Println("No stale review files found.")
+		return
+	}
+
+	fmt.Printf("Found %d stale review file%s:\n", len(stale), plural(len(stale)))
+	for _, s := range stale {
+		ageDays := int(s.age.Hours() / 24)
+		branchInfo := ""
+		if s.branch != "" {
+			branchInfo = s.branch + ", "
+		}
+		fmt.Printf("  %s  (%s%d days old, %d comment%s)\n", s.path, branchInfo, ageDays, s.comments, plural(s.comments))
+	}
+
+	if !force {
+		fmt.Print("Delete all? [y/N] ")
+		var answer string
+		fmt.Scanln(&answer)
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			fmt.Println("Aborted.")
+			return
+		}
+	}
+
+	deleted := deleteStaleReviews(stale)
+	fmt.Printf("Deleted %d review file%s.\n", deleted, plural(deleted))
+}
+
+type staleReview struct {
+	key      string
+	path     string
+	branch   string
+	age      time.Duration
+	comments int
+}
+
+func findStaleReviews(revDir string, days int) []staleReview {
+	entries, err := os.ReadDir(revDir)
+	if err != nil {
+		return nil
+	}
+
+	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	activeSessions := buildActiveSessionSet()
+
+	var stale []staleReview
+	for _, de := range entries {
+		if !strings.HasSuffix(de.Name(), ".json") || isReviewExportName(de.Name()) {
+			continue
+		}
+		key := strings.TrimSuffix(de.Name(), ".json")
+		if activeSessions[key] {
+			continue
+		}
+		if sr, ok := checkStaleReview(revDir, de, key, cutoff); ok {
+			stale = append(stale, sr)
+		}
+	}
+	return stale
+}
+
+func buildActiveSessionSet() map[string]bool {
+	active := make(map[string]bool)
+	entries, keys := readAllSessionEntries()
+	for i, entry := range entries {
+		if isDaemonAlive(entry) {
+			active[keys[i]] = true
+		}
+	}
+	return active
+}
+
+func checkStaleReview(revDir string, de os.DirEntry, key string, cutoff time.Time) (staleReview, bool) {
+	path := filepath.Join(revDir, de.Name())
+	info, err := de.Info()
+	if err != nil {
+		return staleReview{}, false
+	}
+
+	var updatedAt time.Time
+	var branch string
+	var commentCount int
+	if data, readErr := os.ReadFile(path); readErr == nil {
+		var cj CritJSON
+		if unmarshalCritJSON(data, &cj) == nil {
+			branch = cj.Branch
+			if t, parseErr := time.Parse(time.RFC3339, cj.UpdatedAt); parseErr == nil {
+				updatedAt = t
+			}
+			for _, f := range cj.Files {
+				commentCount += len(f.Comments)
+			}
+			commentCount += len(cj.ReviewComments)
+		}
+	}
+	if updatedAt.IsZero() {
+		updatedAt = info.ModTime()
+	}
+
+	if !updatedAt.Before(cutoff) {
+		return staleReview{}, false
+	}
+	return staleReview{
+		key:      key,
+		path:     path,
+		branch:   branch,
+		age:      time.Since(updatedAt),
+		comments: commentCount,
+	}, true
+}
+
+func deleteStaleReviews(stale []staleReview) int {
+	sessDir, _ := sessionsDir()
+	deleted := 0
+	for _, s := range stale {
+		if err := os.Remove(s.path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error deleting %s: %v\n", s.path, err)
+			continue
+		}
+		removeReviewExports(s.path)
+		removeReviewJournal(s.path)
+		removeReviewBackups(s.path)
+		removeReviewHistory(s.path)
+		removeResumeState(s.key)
+		deleted++
+		if sessDir != "" {
+			os.Remove(filepath.Join(sessDir, s.key+".json"))
+			os.Remove(filepath.Join(sessDir, s.key+".lock"))
+			os.Remove(filepath.Join(sessDir, s.key+".log"))
+		}
+	}
+	return deleted
+}
+
+func printConfigHelp() {
+	fmt.Fprintf(os.Stderr, `crit config — show resolved configuration
+
+Prints the merged configuration from global and project config files as JSON.
+CLI flags and environment variables are not reflected in this output.
+
+Config files:
+  ~/.crit.config.json          Global config (applies to all projects)
+  .crit.config.json            Project config (in repo root)
+
+Precedence (highest to lowest):
+  1. CLI flags / env vars
+  2. Project config
+  3. Global config
+  4. Built-in defaults
+
+Available keys:
+  port              int       Port to listen on (default: random)
+  no_open           bool      Don't auto-open browser (default: false)
+  share_url         string    Share service URL
+  share_encrypt     bool      Encrypt new shares; the key stays in the link's #fragment (default: false)
+  quiet             bool      Suppress status output (default: false)
+  output            string    Output directory for review file
+  author            string    Your name for comments (default: git config user.name)
+  base_branch       string    Base branch to diff against (overrides auto-detection)
+  ignore_patterns        []string  Gitignore-style patterns to exclude files from review
+  no_integration_check   bool      Skip integration staleness check (default: false)
+  agent_cmd              string    Shell command to send comments to an AI agent (e.g. "claude -p")
+  auth_token             string    Authentication token for crit-web share service
+  storage                string    Where reviews, sessions and plans live: "global" (~/.crit, default)
+                                   or "project" (.crit/ in the repo root; existing ~/.crit reviews
+                                   are still picked up)
+  gitignore              string    "ignore" adds .crit/ and .crit.json to the repo's .gitignore;
+                                   "track" removes them and adds negations so reviews can be
+                                   committed (default: leave .gitignore alone)
+  backend                string    "file" (default) or "git-notes": also mirror the review into
+                                   git notes (refs/notes/crit) on HEAD, and restore it from there
+                                   when the review file is missing
+  review_template        string    Go text/template file rendered as the agent prompt when a round
+                                   finishes with unresolved comments (relative to the repo root)
+  output_formats         []string  Also write the review as flat structured data next to the review
+                                   file: "json" (<review>.review.json), "yaml" (<review>.review.yaml),
+                                   "inline" (copies of commented files with <!-- crit ... --> annotations
+                                   in <review>.review.annotated/), "history" (cumulative markdown
+                                   of every round, resolved comments struck through)
+  review_style           string    "verbose" (default) points the agent at the review file;
+                                   "compact" inlines one line per open comment instead
+  browser                string    Command used to open the review UI, e.g. "firefox --new-window"
+                                   (the URL is appended); falls back to the system default
+  install_agents         []string  Integrations 'crit install' sets up when no agent is named
+  review_filename        string    Review file name inside the output directory (default ".crit.json");
+                                   a text/template with .Base, .Key and .Branch, e.g.
+                                   "{{.Base}}.crit-review.json" or "review.json"
+
+Global config is ~/.crit.config.json, or ~/.config/crit/config.json ($XDG_CONFIG_HOME)
+when the former doesn't exist.
+
+Note: agent_cmd, auth_token and browser are global-only.
+Project-level .crit.config.json cannot override them for security reasons.
+
+Ignore pattern syntax:
+  *.lock            Match files by extension (anywhere in tree)
+  vendor/           Match all files under a directory
+  package-lock.json Match exact filename (anywhere in tree)
+  generated/*.pb.go Match with path prefix (filepath.Match syntax)
+
+Example config:
+  {
+    "port": 3456,
+    "share_url": "https://crit.md",
+    "ignore_patterns": ["*.lock", "*.min.js", "vendor/", "generated/"]
+  }
+`)
+}
+
+func printVersion() {
+	line := "crit " + version
+	var details []string
+	if date != "unknown" {
+		details = append(details, date)
+	}
+	if commit != "unknown" {
+		short := commit
+		if len(short) > 7 {
+			short = short[:7]
+		}
+		details = append(details, short)
+	}
+	if len(details) > 0 {
+		line += " (" + strings.Join(details, ", ") + ")"
+	}
+	fmt.Println(line)
+	fmt.Println("Inline code review for AI agent workflows")
+}
+
+type integration struct {
+	source string // path inside integrations/ embed
+	dest   string // destination relative to cwd
+	hint   string // usage hint printed after install
+}
+
+var integrationMap = map[string][]integration{
+	"claude-code": {
+		{source: "integrations/claude-code/skills/crit/SKILL.md", dest: ".claude/skills/crit/SKILL.md", hint: "Run /crit in Claude Code to start a review loop"},
+		{source: "integrations/claude-code/skills/crit-cli/SKILL.md", dest: ".claude/skills/crit-cli/SKILL.md", hint: "The crit-cli skill is available to Claude Code agents when needed"},
+	},
+	"cursor": {
+		{source: "integrations/cursor/skills/crit/SKILL.md", dest: ".cursor/skills/crit/SKILL.md", hint: "Run /crit in Cursor to start a review loop"},
+		{source: "integrations/cursor/skills/crit-cli/SKILL.md", dest: ".cursor/skills/crit-cli/SKILL.md", hint: "The crit-cli skill is available to Cursor agents when needed"},
+	},
+	"opencode": {
+		{source: "integrations/opencode/crit.md", dest: ".opencode/commands/crit.md", hint: "Run /crit in OpenCode to start a review loop"},
+		{source: "integrations/opencode/SKILL.md", dest: ".opencode/skills/crit/SKILL.md", hint: "The crit skill is available to OpenCode agents when needed"},
+	},
+	"windsurf": {
+		{source: "integrations/windsurf/crit.md", dest: ".windsurf/rules/crit.md", hint: "Windsurf will suggest Crit when writing plans"},
+	},
+	"github-copilot": {
+		{source: "integrations/github-copilot/skills/crit/SKILL.md", dest: ".github/skills/crit/SKILL.md", hint: "Run /crit in GitHub Copilot to start a review loop"},
+		{source: "integrations/github-copilot/skills/crit-cli/SKILL.md", dest: ".github/skills/crit-cli/SKILL.md", hint: "The crit-cli skill is available to GitHub Copilot agents when needed"},
+	},
+	"cline": {
+		{source: "integrations/cline/crit.md", dest: ".clinerules/crit.md", hint: "Cline will suggest Crit when writing plans"},
+	},
+	"codex": {
+		{source: "integrations/codex/skills/crit/SKILL.md", dest: ".agents/skills/crit/SKILL.md", hint: "Use $crit in Codex to start a review loop"},
+		{source: "integrations/codex/skills/crit-cli/SKILL.md", dest: ".agents/skills/crit-cli/SKILL.md", hint: "The crit-cli skill is available to Codex agents when needed"},
+	},
+}
+
+func availableIntegrations() []string {
+	return []string{"claude-code", "codex", "cursor", "opencode", "windsurf", "github-copilot", "cline"}
+}
+
+func resolveInstallDest(dest string, baseDir string) string {
+	if baseDir != "" {
+		return filepath.Join(baseDir, dest)
+	}
+	return dest
+}
+
+func installIntegration(name string, force bool, global bool) {
+	files, ok := integrationMap[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown agent: %s\n\nAvailable agents:\n", name)
+		for _, a := range availableIntegrations() {
+			fmt.Fprintf(os.Stderr, "  %s\n", a)
+		}
+		os.Exit(1)
+	}
+
+	var baseDir string
+	if global {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting home directory: %v\n", err)
+			os.Exit(1)
+		}
+		baseDir = home
+	}
+
+	var hints []string
+	for _, f := range files {
+		dest := resolveInstallDest(f.dest, baseDir)
+
+		if !force {
+			if _, err := os.Stat(dest); err == nil {
+				fmt.Printf("  Skipped:   %s (already exists, use --force to overwrite)\n", dest)
+				if f.hint != "" {
+					hints = append(hints, f.hint)
+				}
+				continue
+			}
+		}
+
+		data, err := fs.ReadFile(integrationsFS, f.source)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading embedded file %s: %v\n", f.source, err)
+			os.Exit(1)
+		}
+
+		dir := filepath.Dir(dest)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating directory %s: %v\n", dir, err)
+			os.Exit(1)
+		}
+
+		if err := os.WriteFile(dest, data, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", dest, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("  Installed: %s\n", dest)
+		if f.hint != "" {
+			hints = append(hints, f.hint)
+		}
+	}
+	seenHints := make(map[string]bool)
+	for _, hint := range hints {
+		if seenHints[hint] {
+			continue
+		}
+		seenHints[hint] = true
+		fmt.Printf("  %s\n", hint)
+	}
+	fmt.Println()
+}
+
+func openBrowser(url string) {
+	time.Sleep(200 * time.Millisecond)
+	specs := append(configuredBrowserSpecs(url), browserCommandSpecs(runtime.GOOS, url, systemIsWSL(), commandExists)...)
+	if tryOpenBrowser(specs, runBrowserCommand) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: could not open browser automatically; open %s manually\n", url)
+}
+
+// configuredBrowserSpecs returns the global config's browser command, if set,
+// with url appended. It is tried before the platform defaults.
+func configuredBrowserSpecs(url string) []browserCommandSpec {
+	cfg, _, err := loadConfigFile(globalConfigPath())
+	if err != nil {
+		return nil
+	}
+	return browserSpecFromConfig(cfg.Browser, url)
+}
+
+func browserSpecFromConfig(browser, url string) []browserCommandSpec {
+	fields := strings.Fields(browser)
+	if len(fields) == 0 {
+		return nil
+	}
+	return []browserCommandSpec{{name: fields[0], args: append(fields[1:], url)}}
+}
+
+type browserCommandSpec struct {
+	name string
+	args []string
+}
+
+func tryOpenBrowser(specs []browserCommandSpec, run func(browserCommandSpec) error) bool {
+	for _, spec := range specs {
+		if err := run(spec); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func runBrowserCommand(spec browserCommandSpec) error {
+	return exec.Command(spec.name, spec.args...).Run()
+}
+
+func browserCommandSpecs(goos, url string, isWSL bool, hasCommand func(string) bool) []browserCommandSpec {
+	switch goos {
+	case "darwin":
+		return []browserCommandSpec{{name: "open", args: []string{url}}}
+	case "linux":
+		var specs []browserCommandSpec
+		if isWSL {
+			if hasCommand("wslview") {
+				specs = append(specs, browserCommandSpec{name: "wslview", args: []string{url}})
+			}
+			if powershell, ok := wslWindowsCommand("powershell.exe", hasCommand); ok {
+				specs = append(specs, browserCommandSpec{
+					name: powershell,
+					args: []string{
+						"-NoProfile",
+						"-NonInteractive",
+						"-Command",
+						"Start-Process " + powershellSingleQuote(url),
+					},
+				})
+			}
+			if cmd, ok := wslWindowsCommand("cmd.exe", hasCommand); ok {
+				specs = append(specs, browserCommandSpec{
+					name: cmd,
+					args: []string{"/c", `start "" ` + cmdDoubleQuote(url)},
+				})
+			}
+		}
+		if hasCommand("xdg-open") {
+			specs = append(specs, browserCommandSpec{name: "xdg-open", args: []string{url}})
+		}
+		return specs
+	default:
+		return nil
+	}
+}
+
+// wslWindowsPaths locates Windows launchers on the default C: mount, for
+// distros that don't append the Windows PATH (appendWindowsPath=false in
+// /etc/wsl.conf).
+var wslWindowsPaths = map[string]string{
+	"powershell.exe": "/mnt/c/Windows/System32/WindowsPowerShell/v1.0/powershell.exe",
+	"cmd.exe":        "/mnt/c/Windows/System32/cmd.exe",
+}
+
+// wslWindowsCommand returns name if it is on PATH, otherwise its absolute
+// path under /mnt/c when that exists.
+func wslWindowsCommand(name string, hasCommand func(string) bool) (string, bool) {
+	if hasCommand(name) {
+		return name, true
+	}
+	if path := wslWindowsPaths[name]; path != "" && hasCommand(path) {
+		return path, true
+	}
+	return "", false
+}
+
+func powershellSingleQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func cmdDoubleQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func systemIsWSL() bool {
+	versionData, err := os.ReadFile("/proc/version")
+	if err != nil {
+		versionData = nil
+	}
+	return looksLikeWSL(runtime.GOOS, os.Getenv("WSL_DISTRO_NAME"), os.Getenv("WSL_INTEROP"), string(versionData))
+}
+
+func looksLikeWSL(goos, distroName, interop, procVersion string) bool {
+	if goos != "linux" {
+		return false
+	}
+	if distroName != "" || interop != "" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(procVersion), "microsoft")
+}
+
+func commandExists(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
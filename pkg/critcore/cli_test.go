@@ -1,6 +1,7 @@
-package main
+package critcore
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -790,6 +791,44 @@ func TestResolvePlanConfig_NameOnly(t *testing.T) {
 	}
 }
 
+func TestResolvePlanConfig_PrintURL(t *testing.T) {
+	if pc := resolvePlanConfig([]string{"--quiet"}); !pc.quiet || !pc.printURL {
+		t.Errorf("--quiet: quiet=%v printURL=%v, want both true", pc.quiet, pc.printURL)
+	}
+	if pc := resolvePlanConfig([]string{"-q", "--print-url=false"}); pc.printURL {
+		t.Error("--print-url=false should clear printURL")
+	}
+}
+
+func TestParseServerFlags_KeepAlive(t *testing.T) {
+	if parseServerFlags(nil).keepAlive {
+		t.Error("keep-alive should be off by default")
+	}
+	if sf := parseServerFlags([]string{"--keep-alive", "plan.md"}); !sf.keepAlive || len(sf.fileArgs) != 1 {
+		t.Errorf("--keep-alive: %+v", sf)
+	}
+}
+
+func TestAnnounceDaemon(t *testing.T) {
+	entry := sessionEntry{PID: 42, Port: 3100}
+	tests := []struct {
+		started, quiet, printURL bool
+		want                     string
+	}{
+		{true, false, true, "Started crit daemon on port 3100 (PID 42)\n"},
+		{false, false, true, "Connected to crit daemon on port 3100\n"},
+		{true, true, true, "http://localhost:3100\n"},
+		{false, true, false, ""},
+	}
+	for _, tt := range tests {
+		var buf strings.Builder
+		announceDaemon(&buf, entry, tt.started, tt.quiet, tt.printURL)
+		if buf.String() != tt.want {
+			t.Errorf("announceDaemon(started=%v, quiet=%v, printURL=%v) = %q, want %q", tt.started, tt.quiet, tt.printURL, buf.String(), tt.want)
+		}
+	}
+}
+
 func TestCountComments(t *testing.T) {
 	cj := CritJSON{
 		Files: map[string]CritJSONFile{
@@ -1087,3 +1126,79 @@ func TestHelperProcess_Fetch(t *testing.T) {
 	outputDir := os.Getenv("GO_TEST_FETCH_OUTPUT_DIR")
 	runFetch([]string{"--output", outputDir})
 }
+
+func TestConfigSearchDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "plan.md")
+	os.WriteFile(file, []byte("# plan"), 0644)
+
+	if got := configSearchDir(file, "/fallback"); got != dir {
+		t.Errorf("file: got %q, want %q", got, dir)
+	}
+	if got := configSearchDir(dir, "/fallback"); got != dir {
+		t.Errorf("dir: got %q, want %q", got, dir)
+	}
+	if got := configSearchDir(filepath.Join(dir, "missing.md"), "/fallback"); got != "/fallback" {
+		t.Errorf("missing: got %q, want fallback", got)
+	}
+}
+
+func TestRunIdleTimeoutChecker(t *testing.T) {
+	run := func(connected bool) bool {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		var mu sync.Mutex
+		last := time.Now()
+		done := make(chan struct{})
+		go func() {
+			runIdleTimeoutChecker(ctx, stop, 20*time.Millisecond, &mu, &last, func() bool { return connected })
+			close(done)
+		}()
+		select {
+		case <-ctx.Done():
+			<-done
+			return true
+		case <-time.After(200 * time.Millisecond):
+			return false
+		}
+	}
+	if !run(false) {
+		t.Error("an idle daemon with no tabs should shut down")
+	}
+	if run(true) {
+		t.Error("a connected browser tab should keep the daemon alive")
+	}
+}
+
+func TestParseServerFlags_IdleTimeout(t *testing.T) {
+	if got := parseServerFlags(nil).idleTimeout; got != time.Hour {
+		t.Errorf("default idle timeout = %v, want 1h", got)
+	}
+	if got := parseServerFlags([]string{"--idle-timeout", "30m"}).idleTimeout; got != 30*time.Minute {
+		t.Errorf("idle timeout = %v, want 30m", got)
+	}
+}
+
+func TestRunSessionTimeout_FinishesReview(t *testing.T) {
+	srv, session := newTestServer(t)
+	session.AddComment("test.md", 1, 1, "", "fix this", "", "")
+	ch := session.Subscribe()
+	defer session.Unsubscribe(ch)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go runSessionTimeout(ctx, stop, time.Now(), 10*time.Millisecond, srv, session)
+
+	select {
+	case event := <-ch:
+		if event.Type != "finish" || !strings.Contains(event.Content, `"approved":false`) {
+			t.Errorf("event = %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout did not finish the review")
+	}
+	<-ctx.Done()
+	if _, err := os.Stat(session.critJSONPath()); err != nil {
+		t.Errorf("review file not written: %v", err)
+	}
+}
@@ -0,0 +1,182 @@
+package critcore
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Reviews that run for several rounds collect hundreds of comments, so the
+// comment list endpoints filter and order server-side instead of making the
+// frontend and agent CLIs fetch everything:
+//
+//	GET /api/comments?status=open&severity=blocker,major&sort=created
+//	GET /api/file/comments?path=X&status=resolved&sort=position
+//
+// Lists are also paged: at most defaultCommentPageSize comments per response
+// (?limit= up to maxCommentPageSize), with an X-Next-Cursor header to pass
+// back as ?cursor= while more remain. ?all=true returns everything in one
+// response, for the frontend and exports that need the full review.
+
+const (
+	defaultCommentPageSize = 500
+	maxCommentPageSize     = 1000
+)
+
+// severityLevels are the values a comment's severity may take, most severe
+// first. Unclassified comments have none.
+var severityLevels = []string{"blocker", "major", "minor", "nit"}
+
+// checkSeverity accepts "" and the severityLevels.
+func checkSeverity(severity string) error {
+	if severity == "" || slices.Contains(severityLevels, severity) {
+		return nil
+	}
+	return fmt.Errorf("unknown severity %q (want one of %s)", severity, strings.Join(severityLevels, ", "))
+}
+
+// commentFilter is the parsed status/severity/sort query of a comment list
+// request. The zero value keeps every comment in stored order.
+type commentFilter struct {
+	status     string          // "open", "resolved" or "" for both
+	severities map[string]bool // nil for any severity
+	sort       string          // "position", "created" or "" for stored order
+}
+
+func parseCommentFilter(q url.Values) (commentFilter, error) {
+	var f commentFilter
+	switch status := q.Get("status"); status {
+	case "", "all":
+	case "open", "resolved":
+		f.status = status
+	default:
+		return f, fmt.Errorf("unknown status %q (want open, resolved or all)", status)
+	}
+	switch order := q.Get("sort"); order {
+	case "", "position", "created":
+		f.sort = order
+	default:
+		return f, fmt.Errorf("unknown sort %q (want position or created)", order)
+	}
+	if v := q.Get("severity"); v != "" {
+		f.severities = map[string]bool{}
+		for _, sev := range strings.Split(v, ",") {
+			sev = strings.TrimSpace(sev)
+			if err := checkSeverity(sev); err != nil || sev == "" {
+				return f, fmt.Errorf("unknown severity %q (want one of %s)", sev, strings.Join(severityLevels, ", "))
+			}
+			f.severities[sev] = true
+		}
+	}
+	return f, nil
+}
+
+// apply returns the comments f selects, in f's order. Position order is by
+// start line, then end line; review comments have no lines, so it leaves them
+// in stored order.
+func (f commentFilter) apply(comments []Comment) []Comment {
+	out := comments[:0:0]
+	for _, c := range comments {
+		if (f.status == "open" && c.Resolved) || (f.status == "resolved" && !c.Resolved) {
+			continue
+		}
+		if f.severities != nil && !f.severities[c.Severity] {
+			continue
+		}
+		out = append(out, c)
+	}
+	switch f.sort {
+	case "position":
+		sort.SliceStable(out, func(i, j int) bool {
+			if out[i].StartLine != out[j].StartLine {
+				return out[i].StartLine < out[j].StartLine
+			}
+			return out[i].EndLine < out[j].EndLine
+		})
+	case "created":
+		// RFC 3339 UTC timestamps sort lexically.
+		sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt < out[j].CreatedAt })
+	}
+	return out
+}
+
+// commentPage is the parsed limit/cursor/all query of a comment list request.
+type commentPage struct {
+	limit  int    // 0 with all
+	after  string // from the cursor: the previous page's last comment...
+	offset int    // ...and its index, where its successor moves up to if it was deleted
+}
+
+func parseCommentPage(q url.Values) (commentPage, error) {
+	if q.Get("all") == "true" {
+		return commentPage{}, nil
+	}
+	p := commentPage{limit: defaultCommentPageSize}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxCommentPageSize {
+			return p, fmt.Errorf("limit must be between 1 and %d", maxCommentPageSize)
+		}
+		p.limit = n
+	}
+	if v := q.Get("cursor"); v != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(v)
+		offset, id, ok := strings.Cut(string(raw), ":")
+		n, convErr := strconv.Atoi(offset)
+		if err != nil || !ok || convErr != nil || n < 0 {
+			return p, fmt.Errorf("invalid cursor")
+		}
+		p.offset, p.after = n, id
+	}
+	return p, nil
+}
+
+// slice returns one page of comments and the cursor for the next page, or
+// "" on the last one. The cursor names the page's last comment, so comments
+// added or deleted in between don't shift the next page; its index is only
+// the fallback when that comment itself is gone.
+func (p commentPage) slice(comments []Comment) ([]Comment, string) {
+	if p.limit == 0 {
+		return comments, ""
+	}
+	start := min(p.offset, len(comments))
+	if p.after != "" {
+		if i := slices.IndexFunc(comments, func(c Comment) bool { return c.ID == p.after }); i >= 0 {
+			start = i + 1
+		}
+	}
+	end := min(start+p.limit, len(comments))
+	page := comments[start:end]
+	if end == len(comments) {
+		return page, ""
+	}
+	return page, base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(end-1) + ":" + page[len(page)-1].ID))
+}
+
+// writeCommentList answers a GET on a comment list endpoint with the
+// filtered, ordered page of comments requested. X-Total-Count is the number
+// of comments the filter selected across all pages.
+func writeCommentList(w http.ResponseWriter, r *http.Request, comments []Comment) {
+	filter, err := parseCommentFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := parseCommentPage(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	selected := filter.apply(comments)
+	list, next := page.slice(selected)
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(selected)))
+	if next != "" {
+		w.Header().Set("X-Next-Cursor", next)
+	}
+	writeJSONWithETag(w, r, list)
+}
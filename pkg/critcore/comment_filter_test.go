@@ -0,0 +1,159 @@
+package critcore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseCommentFilter(t *testing.T) {
+	for _, q := range []string{"status=closed", "sort=line", "severity=urgent", "severity=blocker,"} {
+		values, _ := url.ParseQuery(q)
+		if _, err := parseCommentFilter(values); err == nil {
+			t.Errorf("%s: expected an error", q)
+		}
+	}
+	values, _ := url.ParseQuery("status=all&severity=nit,+major&sort=created")
+	f, err := parseCommentFilter(values)
+	if err != nil || f.status != "" || !f.severities["nit"] || !f.severities["major"] || f.sort != "created" {
+		t.Errorf("filter = %+v, %v", f, err)
+	}
+}
+
+func TestCommentFilterAPI(t *testing.T) {
+	s, session := newTestServer(t)
+	post := func(body string) Comment {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/api/file/comments?path=test.md", strings.NewReader(body)))
+		if w.Code != 201 {
+			t.Fatalf("POST %s: status %d: %s", body, w.Code, w.Body.String())
+		}
+		var c Comment
+		json.Unmarshal(w.Body.Bytes(), &c)
+		return c
+	}
+	late := post(`{"start_line": 3, "end_line": 3, "body": "late", "severity": "blocker"}`)
+	early := post(`{"start_line": 1, "end_line": 2, "body": "early", "severity": "nit"}`)
+	file := post(`{"scope": "file", "body": "whole file", "severity": "blocker"}`)
+	if span := post(`{"start_line": 2, "end_line": 2, "start_col": 1, "end_col": 2, "body": "span", "severity": "minor"}`); span.Severity != "minor" {
+		t.Errorf("span comment severity = %q", span.Severity)
+	}
+	session.DeleteComment("test.md", session.Files[0].Comments[3].ID)
+	session.SetCommentResolved("test.md", file.ID, true)
+	session.Files[0].Comments[0].CreatedAt = "2026-01-02T00:00:00Z"
+	session.Files[0].Comments[1].CreatedAt = "2026-01-01T00:00:00Z"
+	session.Files[0].Comments[2].CreatedAt = "2026-01-03T00:00:00Z"
+
+	get := func(query string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/api/file/comments?path=test.md&"+query, nil))
+		if w.Code != 200 {
+			t.Fatalf("GET %s: status %d", query, w.Code)
+		}
+		var comments []Comment
+		json.Unmarshal(w.Body.Bytes(), &comments)
+		var ids []string
+		for _, c := range comments {
+			ids = append(ids, c.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+	cases := map[string]string{
+		"":                                   late.ID + "," + early.ID + "," + file.ID,
+		"status=open":                        late.ID + "," + early.ID,
+		"status=resolved":                    file.ID,
+		"severity=blocker":                   late.ID + "," + file.ID,
+		"status=open&severity=blocker":       late.ID,
+		"sort=position":                      file.ID + "," + early.ID + "," + late.ID,
+		"sort=created":                       early.ID + "," + late.ID + "," + file.ID,
+		"severity=nit,blocker&sort=position": file.ID + "," + early.ID + "," + late.ID,
+		"status=resolved&severity=minor,nit": "",
+	}
+	for query, want := range cases {
+		if got := get(query); got != want {
+			t.Errorf("%q = %s, want %s", query, got, want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/file/comments?path=test.md", strings.NewReader(`{"start_line": 1, "end_line": 1, "body": "x", "severity": "urgent"}`)))
+	if w.Code != 400 {
+		t.Errorf("unknown severity: status %d, want 400", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/comments?sort=line", nil))
+	if w.Code != 400 {
+		t.Errorf("unknown sort: status %d, want 400", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/comments", strings.NewReader(`{"body": "ship it?", "severity": "major"}`)))
+	var r Comment
+	json.Unmarshal(w.Body.Bytes(), &r)
+	if w.Code != 201 || r.Severity != "major" {
+		t.Fatalf("review comment: status %d, %+v", w.Code, r)
+	}
+	session.AddReviewComment("unclassified", "")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/comments?severity=major", nil))
+	var review []Comment
+	json.Unmarshal(w.Body.Bytes(), &review)
+	if len(review) != 1 || review[0].ID != r.ID {
+		t.Errorf("filtered review comments = %+v", review)
+	}
+}
+
+func TestCommentListPaging(t *testing.T) {
+	s, session := newTestServer(t)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		c, _ := session.AddComment("test.md", 1, 1, "", "c", "", "")
+		ids = append(ids, c.ID)
+	}
+	get := func(query string) ([]string, *httptest.ResponseRecorder) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/api/file/comments?path=test.md&"+query, nil))
+		var comments []Comment
+		json.Unmarshal(w.Body.Bytes(), &comments)
+		var got []string
+		for _, c := range comments {
+			got = append(got, c.ID)
+		}
+		return got, w
+	}
+
+	page, w := get("limit=2")
+	cursor := w.Header().Get("X-Next-Cursor")
+	if strings.Join(page, ",") != strings.Join(ids[:2], ",") || cursor == "" || w.Header().Get("X-Total-Count") != "5" {
+		t.Fatalf("page 1 = %v, cursor %q, headers %v", page, cursor, w.Header())
+	}
+	// A comment added mid-walk doesn't shift the next page.
+	session.AddComment("test.md", 1, 1, "", "new", "", "")
+	page, w = get("limit=2&cursor=" + cursor)
+	if strings.Join(page, ",") != strings.Join(ids[2:4], ",") {
+		t.Errorf("page 2 = %v, want %v", page, ids[2:4])
+	}
+	// Deleting the comment the cursor names falls back to its offset.
+	session.DeleteComment("test.md", ids[3])
+	page, w = get("limit=10&cursor=" + w.Header().Get("X-Next-Cursor"))
+	if len(page) != 2 || page[0] != ids[4] || w.Header().Get("X-Next-Cursor") != "" {
+		t.Errorf("last page = %v, cursor %q", page, w.Header().Get("X-Next-Cursor"))
+	}
+
+	if page, w := get("all=true&limit=1"); len(page) != 5 || w.Header().Get("X-Next-Cursor") != "" {
+		t.Errorf("all=true returned %d comments, cursor %q", len(page), w.Header().Get("X-Next-Cursor"))
+	}
+	for _, q := range []string{"limit=0", "limit=5000", "cursor=!!", "cursor=" + base64URL("nope")} {
+		if _, w := get(q); w.Code != 400 {
+			t.Errorf("%s: status %d, want 400", q, w.Code)
+		}
+	}
+}
+
+func base64URL(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
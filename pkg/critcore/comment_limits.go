@@ -0,0 +1,104 @@
+package critcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The comment API caps body size (max_comment_body) and the number of
+// comments in a session (max_comments), so an agent posting in a loop gets a
+// clear error instead of burying the review in comments the UI can't render.
+// Over a limit, the answer is JSON with a machine-readable code:
+//
+//	413 {"error": "...", "code": "body_too_large", "limit": 65536}
+//	409 {"error": "...", "code": "too_many_comments", "limit": 2000}
+
+const (
+	defaultMaxCommentBody = 64 << 10 // bytes
+	defaultMaxComments    = 2000
+)
+
+// commentLimitError is the body of an answer to a comment over a limit.
+type commentLimitError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"` // "body_too_large" or "too_many_comments"
+	Limit int    `json:"limit"`
+}
+
+// maxCommentBody is the configured max_comment_body, or the default.
+func (s *Server) maxCommentBody() int {
+	if s.cfg.MaxCommentBody > 0 {
+		return s.cfg.MaxCommentBody
+	}
+	return defaultMaxCommentBody
+}
+
+// maxComments is the configured max_comments, or the default.
+func (s *Server) maxComments() int {
+	if s.cfg.MaxComments > 0 {
+		return s.cfg.MaxComments
+	}
+	return defaultMaxComments
+}
+
+// sanitizeCommentBody normalizes line endings and drops control characters
+// and the bidirectional overrides that make text display differently from
+// what it says. Comments render with raw HTML disabled, so markup is shown as
+// text and needs no escaping here.
+func sanitizeCommentBody(body string) string {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	body = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return r
+		case r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0):
+			return -1
+		case (r >= 0x202a && r <= 0x202e) || (r >= 0x2066 && r <= 0x2069):
+			return -1
+		}
+		return r
+	}, body)
+	return strings.TrimSpace(body)
+}
+
+// acceptCommentBody sanitizes a comment or reply body (what names it in the
+// error) and checks it against max_comment_body. An empty or oversized body
+// is answered on w and reported as !ok.
+func (s *Server) acceptCommentBody(w http.ResponseWriter, body, what string) (string, bool) {
+	body = sanitizeCommentBody(body)
+	if body == "" {
+		http.Error(w, what+" body is required", http.StatusBadRequest)
+		return "", false
+	}
+	if limit := s.maxCommentBody(); len(body) > limit {
+		writeCommentLimitError(w, http.StatusRequestEntityTooLarge, commentLimitError{
+			Error: fmt.Sprintf("%s body is %d bytes, over the %d-byte limit (max_comment_body)", what, len(body), limit),
+			Code:  "body_too_large",
+			Limit: limit,
+		})
+		return "", false
+	}
+	return body, true
+}
+
+// acceptNewComment checks that sess has room for another comment under
+// max_comments, answering on w when it hasn't.
+func (s *Server) acceptNewComment(w http.ResponseWriter, sess *Session) bool {
+	if limit := s.maxComments(); sess.TotalCommentCount() >= limit {
+		writeCommentLimitError(w, http.StatusConflict, commentLimitError{
+			Error: fmt.Sprintf("the review has reached its limit of %d comments (max_comments)", limit),
+			Code:  "too_many_comments",
+			Limit: limit,
+		})
+		return false
+	}
+	return true
+}
+
+func writeCommentLimitError(w http.ResponseWriter, status int, e commentLimitError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(e)
+}
@@ -0,0 +1,105 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCommentLimits_BodyTooLarge(t *testing.T) {
+	s, session := newTestServer(t)
+	s.cfg.MaxCommentBody = 10
+	c, _ := session.AddComment("test.md", 1, 1, "", "short", "", "")
+	requests := []struct{ method, target, body string }{
+		{"POST", "/api/file/comments?path=test.md", `{"start_line":1,"end_line":1,"body":"far too long a body"}`},
+		{"POST", "/api/comments", `{"body":"far too long a body"}`},
+		{"PUT", "/api/comment/" + c.ID + "?path=test.md", `{"body":"far too long a body"}`},
+		{"POST", "/api/comment/" + c.ID + "/replies?path=test.md", `{"body":"far too long a body"}`},
+	}
+	for _, tc := range requests {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body)))
+		var resp commentLimitError
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != 413 || resp.Code != "body_too_large" || resp.Limit != 10 {
+			t.Errorf("%s %s: status = %d, body = %s", tc.method, tc.target, w.Code, w.Body.String())
+		}
+	}
+	if got := session.GetComments("test.md"); len(got) != 1 || got[0].Body != "short" || len(got[0].Replies) != 0 {
+		t.Errorf("comments = %+v", got)
+	}
+}
+
+func TestCommentLimits_TooManyComments(t *testing.T) {
+	s, session := newTestServer(t)
+	s.cfg.MaxComments = 2
+	session.AddComment("test.md", 1, 1, "", "one", "", "")
+	session.AddReviewComment("two", "")
+
+	for _, tc := range []struct{ target, body string }{
+		{"/api/file/comments?path=test.md", `{"start_line":2,"end_line":2,"body":"three"}`},
+		{"/api/comments", `{"body":"three"}`},
+	} {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", tc.target, strings.NewReader(tc.body)))
+		var resp commentLimitError
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != 409 || resp.Code != "too_many_comments" || resp.Limit != 2 {
+			t.Errorf("POST %s: status = %d, body = %s", tc.target, w.Code, w.Body.String())
+		}
+	}
+	if n := session.TotalCommentCount(); n != 2 {
+		t.Errorf("comment count = %d, want 2", n)
+	}
+}
+
+func TestCommentLimits_Defaults(t *testing.T) {
+	s, _ := newTestServer(t)
+	if s.maxCommentBody() != defaultMaxCommentBody || s.maxComments() != defaultMaxComments {
+		t.Errorf("limits = %d, %d", s.maxCommentBody(), s.maxComments())
+	}
+}
+
+func TestSanitizeCommentBody(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Fix this\r\nand that\r\n", "Fix this\nand that"},
+		{"tab\tkept", "tab\tkept"},
+		{"bell\a and nul\x00", "bell and nul"},
+		{"admin\u202e\u2066gnp.exe", "admingnp.exe"},
+		{"<script>alert(1)</script> shown as text", "<script>alert(1)</script> shown as text"},
+		{" \n\x00 ", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeCommentBody(tt.in); got != tt.want {
+			t.Errorf("sanitizeCommentBody(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPostFileComment_SanitizesBody(t *testing.T) {
+	s, session := newTestServer(t)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/file/comments?path=test.md", strings.NewReader(`{"start_line":1,"end_line":1,"body":"Fix\r\nthis\u0000"}`)))
+	if w.Code != 201 {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if got := session.GetComments("test.md")[0].Body; got != "Fix\nthis" {
+		t.Errorf("body = %q", got)
+	}
+
+	// A body that is only control characters is empty once sanitized.
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/file/comments?path=test.md", strings.NewReader(`{"start_line":1,"end_line":1,"body":"\u0007"}`)))
+	if w.Code != 400 {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+}
+
+func TestMergeConfigs_CommentLimits(t *testing.T) {
+	global := Config{MaxCommentBody: 1 << 20, MaxComments: 500}
+	merged := mergeConfigs(global, Config{MaxComments: 100}, configPresence{})
+	if merged.MaxCommentBody != 1<<20 || merged.MaxComments != 100 {
+		t.Errorf("limits = %d, %d", merged.MaxCommentBody, merged.MaxComments)
+	}
+}
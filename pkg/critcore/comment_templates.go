@@ -0,0 +1,114 @@
+package critcore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Comment templates are canned feedback ("add error handling here") the
+// reviewer inserts with one click. They live in templates.json under the
+// storage root rather than in a browser cookie, so they are the same on every
+// port and in every review.
+
+const maxCommentTemplates = 100
+
+// commentTemplatesMu serializes read-modify-write of the templates file
+// between concurrent requests in this process.
+var commentTemplatesMu sync.Mutex
+
+func commentTemplatesFile() (string, error) {
+	root, err := storageRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "templates.json"), nil
+}
+
+// readCommentTemplates returns the saved templates, or none when the file
+// doesn't exist yet.
+func readCommentTemplates(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading comment templates: %w", err)
+	}
+	var templates []string
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("parsing comment templates %s: %w", path, err)
+	}
+	return normalizeCommentTemplates(templates), nil
+}
+
+func writeCommentTemplates(path string, templates []string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating templates directory: %w", err)
+	}
+	data, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicWriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing comment templates: %w", err)
+	}
+	return nil
+}
+
+// normalizeCommentTemplates trims templates and drops empty and repeated
+// ones, keeping the first maxCommentTemplates.
+func normalizeCommentTemplates(templates []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, t := range templates {
+		if t = strings.TrimSpace(t); t != "" && !seen[t] && len(out) < maxCommentTemplates {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// handleCommentTemplates serves the saved comment templates.
+// GET /api/templates
+// PUT /api/templates ["template", ...] replaces the list
+func (s *Server) handleCommentTemplates(w http.ResponseWriter, r *http.Request) {
+	path, err := commentTemplatesFile()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	commentTemplatesMu.Lock()
+	defer commentTemplatesMu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		templates, err := readCommentTemplates(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, templates)
+	case http.MethodPut:
+		r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
+		var templates []string
+		if err := json.NewDecoder(r.Body).Decode(&templates); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		templates = normalizeCommentTemplates(templates)
+		if err := writeCommentTemplates(path, templates); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, templates)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
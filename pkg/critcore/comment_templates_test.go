@@ -0,0 +1,66 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCommentTemplatesAPI(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	s, _ := newTestServer(t)
+
+	get := func() []string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/api/templates", nil))
+		if w.Code != 200 {
+			t.Fatalf("GET: status %d", w.Code)
+		}
+		var templates []string
+		json.Unmarshal(w.Body.Bytes(), &templates)
+		return templates
+	}
+	if got := get(); len(got) != 0 {
+		t.Errorf("fresh templates = %q, want none", got)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("PUT", "/api/templates", strings.NewReader(`["Add error handling here", "  ", "Needs a test", "Add error handling here"]`)))
+	if w.Code != 200 {
+		t.Fatalf("PUT: status %d: %s", w.Code, w.Body.String())
+	}
+	if got := strings.Join(get(), "|"); got != "Add error handling here|Needs a test" {
+		t.Errorf("templates = %s", got)
+	}
+	path := filepath.Join(home, ".crit", "templates.json")
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("templates file not written: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("PUT", "/api/templates", strings.NewReader(`{"not": "a list"}`)))
+	if w.Code != 400 {
+		t.Errorf("invalid body: status %d, want 400", w.Code)
+	}
+	os.WriteFile(path, []byte("{"), 0644)
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/templates", nil))
+	if w.Code != 500 {
+		t.Errorf("corrupt file: status %d, want 500", w.Code)
+	}
+}
+
+func TestNormalizeCommentTemplates(t *testing.T) {
+	var many []string
+	for i := 0; i < maxCommentTemplates+5; i++ {
+		many = append(many, strings.Repeat("x", i+1))
+	}
+	if got := normalizeCommentTemplates(many); len(got) != maxCommentTemplates {
+		t.Errorf("kept %d templates, want %d", len(got), maxCommentTemplates)
+	}
+}
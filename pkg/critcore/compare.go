@@ -0,0 +1,117 @@
+package critcore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// `crit compare old.md new.md` reviews new.md against old.md instead of
+// against git: the file's diff hunks come from the two files' contents, so
+// the review UI opens in the diff view, and old-side comments ("side":
+// "old") anchor to old.md's lines. old.md is read again whenever the diff
+// is asked for, so edits to either file show up. The daemon is started with
+// the hidden --compare-base flag and keyed by "compare old new", which is
+// also the command that starts the next round.
+
+// runCompare implements `crit compare [flags] <old> <new>`.
+func runCompare(args []string) {
+	if slices.Contains(args, "--help") || slices.Contains(args, "-h") {
+		printCompareUsage()
+		return
+	}
+	reviewArgs, ok := compareReviewArgs(args)
+	if !ok {
+		printCompareUsage()
+		os.Exit(1)
+	}
+	runReview(reviewArgs)
+}
+
+// compareReviewArgs turns `crit compare` arguments into those of the review
+// it starts: the flags, then --compare-base <old> <new>.
+func compareReviewArgs(args []string) ([]string, bool) {
+	files := parseServerFlags(args).fileArgs
+	if len(files) != 2 {
+		return nil, false
+	}
+	flags := slices.Clone(args[:len(args)-len(files)])
+	return append(flags, "--compare-base", files[0], files[1]), true
+}
+
+func printCompareUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit compare [flags] <old> <new>")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Reviews <new> against <old>: the diff between the two files is shown,")
+	fmt.Fprintln(os.Stderr, "and comments can be left on either side. Takes the flags of crit <file>.")
+}
+
+// validateCompareArgs checks --compare-base and the file argument it is
+// compared with.
+func validateCompareArgs(base string, files []string) error {
+	if base == "" {
+		return nil
+	}
+	if len(files) != 1 {
+		return errors.New("compare takes exactly two files: <old> <new>")
+	}
+	for _, p := range []string{base, files[0]} {
+		info, err := os.Stat(p)
+		if err != nil {
+			return fmt.Errorf("file not found: %s", p)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("compare takes files, not directories: %s", p)
+		}
+		if !isTextFile(p) {
+			return notTextError(p)
+		}
+	}
+	return nil
+}
+
+// sessionArgs returns the arguments a review is keyed by and re-invoked
+// with: the file arguments, or "compare <old> <new>".
+func sessionArgs(sc *serverConfig) []string {
+	if sc.compareBase == "" {
+		return sc.files
+	}
+	return append([]string{"compare", sc.compareBase}, sc.files...)
+}
+
+// setCompareBase makes the session's single file compared with the file at
+// base.
+func (s *Session) setCompareBase(base string) error {
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Files) != 1 {
+		return errors.New("compare takes exactly two files: <old> <new>")
+	}
+	s.compareBase = base
+	f := s.Files[0]
+	f.CompareBase = abs
+	f.DiffHunks = compareHunks(f)
+	return nil
+}
+
+// compareBaseContent returns the content of f's compare base, as setContent
+// would keep it.
+func compareBaseContent(f *FileEntry) string {
+	data, err := os.ReadFile(f.CompareBase)
+	if err != nil {
+		return ""
+	}
+	return textContent(data)
+}
+
+// compareHunks returns the diff from f's compare base to its content.
+// Callers hold s.mu.
+func compareHunks(f *FileEntry) []DiffHunk {
+	return DiffEntriesToHunks(ComputeLineDiff(compareBaseContent(f), f.Content))
+}
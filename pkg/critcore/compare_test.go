@@ -0,0 +1,87 @@
+package critcore
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestCompareReviewArgs(t *testing.T) {
+	got, ok := compareReviewArgs([]string{"--no-open", "-p", "3000", "old.md", "new.md"})
+	want := []string{"--no-open", "-p", "3000", "--compare-base", "old.md", "new.md"}
+	if !ok || !slices.Equal(got, want) {
+		t.Errorf("compareReviewArgs = %q, %v", got, ok)
+	}
+	if _, ok := compareReviewArgs([]string{"new.md"}); ok {
+		t.Error("one file accepted")
+	}
+}
+
+func TestValidateCompareArgs(t *testing.T) {
+	dir := t.TempDir()
+	old, cur := filepath.Join(dir, "old.md"), filepath.Join(dir, "new.md")
+	writeFile(t, old, "a\n")
+	writeFile(t, cur, "b\n")
+	if err := validateCompareArgs(old, []string{cur}); err != nil {
+		t.Errorf("valid pair: %v", err)
+	}
+	if err := validateCompareArgs("", []string{cur, old}); err != nil {
+		t.Errorf("no compare: %v", err)
+	}
+	for name, files := range map[string][]string{
+		"two new files": {cur, old},
+		"missing":       {filepath.Join(dir, "gone.md")},
+		"directory":     {dir},
+	} {
+		if err := validateCompareArgs(old, files); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestSessionArgs_Compare(t *testing.T) {
+	sc := &serverConfig{files: []string{"new.md"}}
+	if got := sessionArgs(sc); !slices.Equal(got, []string{"new.md"}) {
+		t.Errorf("review: %q", got)
+	}
+	sc.compareBase = "old.md"
+	if got := sessionArgs(sc); !slices.Equal(got, []string{"compare", "old.md", "new.md"}) {
+		t.Errorf("compare: %q", got)
+	}
+}
+
+func TestSetCompareBase(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeFile(t, filepath.Join(dir, "spec.md"), "# Spec\n\nKeep this.\nDrop this.\n")
+	writeFile(t, filepath.Join(dir, "rewrite.md"), "# Spec\n\nKeep this.\nAdd this.\nAnd this.\n")
+	session, err := NewSessionFromFiles([]string{"rewrite.md"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.setCompareBase("spec.md"); err != nil {
+		t.Fatal(err)
+	}
+	path := session.Files[0].Path
+
+	snap, _ := session.GetFileDiffSnapshot(path)
+	if snap["previous_content"] != "# Spec\n\nKeep this.\nDrop this.\n" || len(snap["hunks"].([]DiffHunk)) == 0 {
+		t.Errorf("diff snapshot = %+v", snap)
+	}
+	if info := session.GetSessionInfo(); info.CompareBase != "spec.md" || info.Files[0].Additions != 2 || info.Files[0].Deletions != 1 {
+		t.Errorf("session info: %+v", info)
+	}
+
+	c, ok := session.AddComment(path, 4, 4, "old", "why drop it?", "", "")
+	if !ok || c.Anchor != "Drop this." {
+		t.Errorf("old-side comment anchor = %q", c.Anchor)
+	}
+	var lre *lineRangeError
+	if err := session.checkLineRange(path, "old", 5, 5); !errors.As(err, &lre) {
+		t.Errorf("old side line 5 of 4: %v", err)
+	}
+	if err := session.checkLineRange(path, "", 5, 5); err != nil {
+		t.Errorf("new side line 5 of 5: %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package critcore
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// crit completion prints a shell script that asks the binary itself for
+// candidates: the script runs `crit __complete <words before the cursor>
+// <current word>` and falls back to file names when nothing is printed.
+// Candidates come from the tables below, so they have to be kept in sync
+// with the subcommands and their flag parsing.
+
+// reviewFlags are the flags of `crit [files...]` and `crit review`
+// (parseServerFlags), without the single-letter aliases.
+var reviewFlags = []string{
+	"--port", "--no-open", "--version", "--share-url", "--output", "--quiet", "--no-ignore",
+	"--base-branch", "--vcs", "--review-template", "--review-style", "--notify", "--bell",
+	"--plain", "--print-url", "--metrics", "--keep-alive", "--idle-timeout", "--timeout",
+	"--log-level", "--log-format", "--rev",
+}
+
+// completionFlags maps each subcommand to its flags. "" is the bare review
+// command. Every commandDispatch entry that isn't hidden must be listed.
+var completionFlags = map[string][]string{
+	"":            reviewFlags,
+	"review":      reviewFlags,
+	"share":       {"--output", "--share-url", "--qr", "--encrypt", "--expires"},
+	"fetch":       {"--output", "--share-url", "--no-review"},
+	"unpublish":   {"--output", "--share-url"},
+	"install":     {"--global", "--force"},
+	"config":      {"--generate"},
+	"check":       nil,
+	"pull":        {"--output"},
+	"push":        {"--dry-run", "--event", "--message", "--output"},
+	"comment":     {"--plan", "--output", "--author", "--reply-to", "--resolve", "--path", "--json", "--clear"},
+	"plan":        {"--name", "--port", "--no-open", "--quiet", "--print-url", "--keep-alive"},
+	"plan-hook":   nil,
+	"auth":        nil,
+	"stop":        {"--all"},
+	"add":         nil,
+	"status":      {"--json"},
+	"cleanup":     {"--days", "--force"},
+	"history":     {"--round", "--json"},
+	"update":      {"--check", "--force"},
+	"completion":  nil,
+	"man":         nil,
+	"doctor":      {"--fix"},
+	"init":        {"--yes", "--agents", "--gitignore", "--checklist"},
+	"import":      {"--format", "--output", "--plan"},
+	"triage":      {"--output", "--plan"},
+	"compare":     reviewFlags,
+	"export":      {"--format", "--browser", "--email", "--output", "--plan"},
+	"serve-share": {"--listen", "--storage", "--public-url", "--max-age", "--auth-token"},
+	"resume":      reviewFlags,
+	"restore":     {"--backup", "--output", "--plan"},
+	"help":        nil,
+}
+
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// completionFlagValues completes the value of a flag given as the previous
+// word; a "<subcommand> <flag>" key takes precedence for that subcommand.
+// Flags taking paths or free text are left to file completion.
+var completionFlagValues = map[string]func() []string{
+	"--event":        func() []string { return []string{"comment", "approve", "request-changes"} },
+	"--review-style": func() []string { return []string{reviewStyleVerbose, reviewStyleCompact} },
+	"--log-level":    func() []string { return []string{"debug", "info", "warn", "error"} },
+	"--log-format":   func() []string { return []string{logFormatText, logFormatJSON} },
+	"--vcs":          func() []string { return []string{"git", "sl"} },
+	"--gitignore":    func() []string { return []string{gitignoreIgnore, gitignoreTrack, "skip"} },
+	"--format":       func() []string { return []string{importSARIF, importRDJSON} },
+	"export --format": func() []string {
+		return []string{exportHTML, exportPDF, exportJSON, exportYAML, exportHistory}
+	},
+}
+
+// completionArgs completes the first positional argument of subcommands that
+// take a fixed set of names.
+var completionArgs = map[string]func() []string{
+	"install":    func() []string { return append(availableIntegrations(), "all") },
+	"auth":       func() []string { return []string{"login", "logout", "whoami"} },
+	"completion": func() []string { return completionShells },
+}
+
+// completeWords returns the candidates for the last of words (the word under
+// the cursor), given the words before it on the command line after "crit".
+func completeWords(words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	prev, cur := words[:len(words)-1], words[len(words)-1]
+	if cur == `""` { // Windows PowerShell passes an empty word this way
+		cur = ""
+	}
+	if len(prev) > 0 {
+		flag := prev[len(prev)-1]
+		if values, ok := completionFlagValues[prev[0]+" "+flag]; ok {
+			return filterPrefix(values(), cur)
+		}
+		if values, ok := completionFlagValues[flag]; ok {
+			return filterPrefix(values(), cur)
+		}
+	}
+	cmd := ""
+	if len(prev) > 0 {
+		if _, ok := completionFlags[prev[0]]; ok {
+			cmd = prev[0]
+		}
+	}
+	switch {
+	case strings.HasPrefix(cur, "-"):
+		return filterPrefix(completionFlags[cmd], cur)
+	case len(prev) == 0:
+		return filterPrefix(completionSubcommands(), cur)
+	case len(prev) == 1 && completionArgs[cmd] != nil:
+		return filterPrefix(completionArgs[cmd](), cur)
+	}
+	return nil
+}
+
+func completionSubcommands() []string {
+	var names []string
+	for name := range completionFlags {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func filterPrefix(candidates []string, prefix string) []string {
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// runComplete serves the generated scripts: crit __complete <words...>
+func runComplete(args []string) {
+	for _, c := range completeWords(args) {
+		fmt.Println(c)
+	}
+}
+
+func runCompletion(args []string) {
+	if len(args) != 1 || completionScripts[args[0]] == "" {
+		fmt.Fprintf(os.Stderr, "Usage: crit completion <%s>\n", strings.Join(completionShells, "|"))
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Prints a completion script. For example:")
+		fmt.Fprintln(os.Stderr, "  bash:        source <(crit completion bash)          (in ~/.bashrc)")
+		fmt.Fprintln(os.Stderr, "  zsh:         source <(crit completion zsh)           (in ~/.zshrc, after compinit)")
+		fmt.Fprintln(os.Stderr, "  fish:        crit completion fish > ~/.config/fish/completions/crit.fish")
+		fmt.Fprintln(os.Stderr, "  powershell:  crit completion powershell | Out-String | Invoke-Expression   (in $PROFILE)")
+		os.Exit(1)
+	}
+	fmt.Print(completionScripts[args[0]])
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for crit
+_crit() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local IFS=$'\n'
+    COMPREPLY=($(crit __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    if [ ${#COMPREPLY[@]} -eq 0 ]; then
+        COMPREPLY=($(compgen -f -- "$cur"))
+    fi
+}
+complete -o filenames -F _crit crit
+`,
+	"zsh": `#compdef crit
+# zsh completion for crit
+_crit() {
+    local -a candidates
+    candidates=("${(@f)$(crit __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if [[ -n "${candidates[1]}" ]]; then
+        compadd -a candidates
+    else
+        _files
+    fi
+}
+compdef _crit crit
+`,
+	"fish": `# fish completion for crit
+function __crit_complete
+    set -l prev (commandline -opc)
+    set -l cur (commandline -ct)
+    set -l candidates (crit __complete $prev[2..-1] "$cur" 2>/dev/null)
+    if test (count $candidates) -gt 0
+        printf '%s\n' $candidates
+    else
+        __fish_complete_path "$cur"
+    end
+end
+complete -c crit -f -a '(__crit_complete)'
+`,
+	"powershell": `# PowerShell completion for crit
+Register-ArgumentCompleter -Native -CommandName crit -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 |
+        Where-Object { $_.Extent.EndOffset -le $cursorPosition } | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') { $words += '""' }
+    crit __complete @words 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`,
+}
@@ -0,0 +1,58 @@
+package critcore
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompletionFlags_CoverSubcommands(t *testing.T) {
+	for name := range commandDispatch {
+		if strings.HasPrefix(name, "-") || strings.HasPrefix(name, "_") {
+			continue
+		}
+		if _, ok := completionFlags[name]; !ok {
+			t.Errorf("subcommand %q is missing from completionFlags", name)
+		}
+	}
+	for name := range completionFlags {
+		if _, ok := commandDispatch[name]; name != "" && !ok {
+			t.Errorf("completionFlags lists unknown subcommand %q", name)
+		}
+	}
+}
+
+func TestCompleteWords(t *testing.T) {
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"st"}, []string{"status", "stop"}},
+		{[]string{"--no-"}, []string{"--no-open", "--no-ignore"}},
+		{[]string{"push", "--d"}, []string{"--dry-run"}},
+		{[]string{"push", "--event", ""}, []string{"comment", "approve", "request-changes"}},
+		{[]string{"--review-style", "c"}, []string{"compact"}},
+		{[]string{"import", "--format", ""}, []string{"sarif", "rdjson"}},
+		{[]string{"export", "--format", "h"}, []string{"html", "history"}},
+		{[]string{"install", "cl"}, []string{"claude-code", "cline"}},
+		{[]string{"auth", `""`}, []string{"login", "logout", "whoami"}},
+		{[]string{"completion", "f"}, []string{"fish"}},
+		// Paths are left to the shell's file completion.
+		{[]string{"src/"}, nil},
+		{[]string{"comment", "--output", ""}, nil},
+		{[]string{"install", "claude-code", ""}, nil},
+	}
+	for _, tt := range tests {
+		if got := completeWords(tt.words); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("completeWords(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	for _, shell := range completionShells {
+		if !strings.Contains(completionScripts[shell], "crit __complete") {
+			t.Errorf("%s script doesn't call crit __complete", shell)
+		}
+	}
+}
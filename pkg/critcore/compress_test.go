@@ -0,0 +1,79 @@
+package critcore
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                        "",
+		"gzip, deflate, br":       "gzip",
+		"deflate":                 "deflate",
+		"gzip;q=0, deflate":       "deflate",
+		"GZIP ; q=0.5":            "gzip",
+		"*":                       "gzip",
+		"*, gzip;q=0":             "deflate",
+		"br, identity":            "",
+		"gzip;q=0.0, deflate;q=0": "",
+	}
+	for header, want := range tests {
+		if got := acceptedEncoding(header); got != want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	s, session := newTestServer(t)
+	session.Files[0].FileHash = fileHash([]byte(session.Files[0].Content))
+	get := func(url string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	plain := get("/api/file?path=test.md", nil)
+	w := get("/api/file?path=test.md", map[string]string{"Accept-Encoding": "gzip"})
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("headers = %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != plain.Body.String() {
+		t.Errorf("decompressed body = %q, want %q", body, plain.Body.String())
+	}
+
+	notModified := get("/api/file?path=test.md", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": w.Header().Get("ETag")})
+	if notModified.Code != http.StatusNotModified || notModified.Header().Get("Content-Encoding") != "" || notModified.Body.Len() != 0 {
+		t.Errorf("304 = %d, headers %v, %d body bytes", notModified.Code, notModified.Header(), notModified.Body.Len())
+	}
+	deflated := get("/", map[string]string{"Accept-Encoding": "deflate"})
+	if deflated.Code != 200 || deflated.Header().Get("Content-Encoding") != "deflate" || deflated.Header().Get("Content-Length") != "" {
+		t.Errorf("static asset = %d, headers %v", deflated.Code, deflated.Header())
+	}
+	zlr, err := zlib.NewReader(deflated.Body)
+	if err != nil {
+		t.Fatalf("deflate body is not zlib: %v", err)
+	}
+	if body, err := io.ReadAll(zlr); err != nil || string(body) != get("/", nil).Body.String() {
+		t.Errorf("inflated static asset: %d bytes, err %v", len(body), err)
+	}
+	if w := get("/api/file/comments?path=test.md", map[string]string{"Accept-Encoding": "gzip"}); w.Header().Get("Content-Encoding") != "" {
+		t.Error("unwrapped endpoint should not be compressed")
+	}
+}
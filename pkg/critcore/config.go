@@ -0,0 +1,713 @@
+package critcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config holds all configuration values from config files.
+type Config struct {
+	Port               int              `json:"port,omitempty"`
+	NoOpen             bool             `json:"no_open,omitempty"`
+	ShareURL           string           `json:"share_url,omitempty"`
+	ShareEncrypt       bool             `json:"share_encrypt,omitempty"` // encrypt new shares, keeping the key in the link's fragment
+	ShareExpires       string           `json:"share_expires,omitempty"` // new shares expire after this long, e.g. "7d"
+	Quiet              bool             `json:"quiet,omitempty"`
+	Output             string           `json:"output,omitempty"`
+	Author             string           `json:"author,omitempty"`
+	BaseBranch         string           `json:"base_branch,omitempty"`
+	IgnorePatterns     []string         `json:"ignore_patterns,omitempty"`
+	NoIntegrationCheck bool             `json:"no_integration_check,omitempty"`
+	NoUpdateCheck      bool             `json:"no_update_check,omitempty"`
+	AgentCmd           string           `json:"agent_cmd,omitempty"`
+	AuthToken          string           `json:"auth_token,omitempty"`
+	AuthUserName       string           `json:"auth_user_name,omitempty"`
+	AuthUserEmail      string           `json:"auth_user_email,omitempty"`
+	CleanupOnApprove   *bool            `json:"cleanup_on_approve,omitempty"`
+	VCS                string           `json:"vcs,omitempty"`             // preferred VCS backend: "git", "sl"
+	Storage            string           `json:"storage,omitempty"`         // data layout: "global" (~/.crit) or "project" (<repo>/.crit)
+	Gitignore          string           `json:"gitignore,omitempty"`       // "ignore" or "track" crit artifacts in .gitignore; empty leaves it alone
+	Backend            string           `json:"backend,omitempty"`         // comment persistence: "file" (default) or "git-notes"
+	ReviewTemplate     string           `json:"review_template,omitempty"` // text/template file replacing the agent prompt
+	OutputFormats      []string         `json:"output_formats,omitempty"`  // structured exports written next to the review file: "json", "yaml"
+	ReviewStyle        string           `json:"review_style,omitempty"`    // agent prompt: "verbose" (default) or "compact"
+	Browser            string           `json:"browser,omitempty"`         // command used to open the review UI (global only)
+	InstallAgents      []string         `json:"install_agents,omitempty"`  // integrations `crit install` sets up when given no agent
+	ReviewFileName     string           `json:"review_filename,omitempty"` // review file name in the output dir (text/template)
+	Backups            int              `json:"backups,omitempty"`         // review file backups kept (default 10); negative turns them off
+	Checklist          []string         `json:"checklist,omitempty"`       // review gates the reviewer ticks off in the UI
+	ChecklistFile      string           `json:"checklist_file,omitempty"`  // file with one checklist item per line, replacing checklist
+	Bell               bool             `json:"bell,omitempty"`            // ring on round-complete and finish
+	BellCommand        string           `json:"bell_command,omitempty"`    // sound command run instead of the terminal bell (global only)
+	Hooks              hookCommands     `json:"hooks,omitzero"`            // lifecycle hook commands (global only)
+	Webhooks           []webhookConfig  `json:"webhooks,omitempty"`        // URLs receiving review events (global only)
+	Notifiers          []notifierConfig `json:"notifiers,omitempty"`       // Slack/Discord review summaries (global only)
+	Jira               jiraConfig       `json:"jira,omitzero"`             // creating Jira issues from comments (global only)
+	Linear             linearConfig     `json:"linear,omitzero"`           // creating Linear issues from comments (global only)
+	SMTP               smtpConfig       `json:"smtp,omitzero"`             // mail server for crit export --email (global only)
+	Linters            []linterConfig   `json:"linters,omitempty"`         // run on the reviewed files on load and after each round (global only)
+	ProseCheck         bool             `json:"prose_check,omitempty"`     // built-in markdown checks: broken links, TODO markers, empty sections, duplicate headings
+	RateLimit          rateLimitConfig  `json:"rate_limit,omitzero"`       // per-client token bucket on mutating API requests
+	CORSOrigins        []string         `json:"cors_origins,omitempty"`    // other origins allowed to call the API (global only)
+
+	// Update check settings, global only.
+	UpdateChannel       string `json:"update_channel,omitempty"`        // "stable" (default) or "prerelease"
+	UpdateCheckInterval string `json:"update_check_interval,omitempty"` // duration between checks, e.g. "24h"; "0" checks on every start
+	UpdateProxy         string `json:"update_proxy,omitempty"`          // proxy URL for release requests
+
+	// Comment API limits (comment_limits.go); 0 keeps the default.
+	MaxCommentBody int `json:"max_comment_body,omitempty"` // bytes per comment or reply body (default 65536)
+	MaxComments    int `json:"max_comments,omitempty"`     // comments per review (default 2000)
+
+	// Repeated comment POSTs (duplicates.go): "merge" (default), "reject" or "off".
+	DuplicateComments string `json:"duplicate_comments,omitempty"`
+
+	// Display settings served to the review UI by /api/settings.
+	TabWidth               int    `json:"tab_width,omitempty"`                // columns per tab (default 8)
+	ShowTrailingWhitespace bool   `json:"show_trailing_whitespace,omitempty"` // highlight trailing spaces and tabs in code
+	Wrap                   string `json:"wrap,omitempty"`                     // long code lines: "on" or "off"; empty keeps each view's default
+	Language               string `json:"language,omitempty"`                 // review UI language, e.g. "de"; empty follows the browser (i18n.go)
+	Timezone               string `json:"timezone,omitempty"`                 // IANA zone times are shown in, e.g. "Europe/Berlin"; empty for the machine's (timezone.go)
+}
+
+// CleanupOnApproveEnabled returns whether review files should be cleaned up
+// when a review is approved. Defaults to true if not explicitly set.
+func (c Config) CleanupOnApproveEnabled() bool {
+	if c.CleanupOnApprove != nil {
+		return *c.CleanupOnApprove
+	}
+	return true
+}
+
+// BackupCount returns how many backups of the review file to keep; 0
+// means none.
+func (c Config) BackupCount() int {
+	switch {
+	case c.Backups < 0:
+		return 0
+	case c.Backups == 0:
+		return defaultBackups
+	}
+	return c.Backups
+}
+
+// String returns a human-readable JSON representation of the resolved config.
+func (c Config) String() string {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "{}"
+	}
+	return string(data) + "\n"
+}
+
+// defaultConfig returns a config template with all keys present,
+// suitable for generating a starter config file.
+// Uses a map to avoid omitempty suppressing zero-value fields.
+func defaultConfig() generatedConfig {
+	return generatedConfig{
+		Port:       0,
+		NoOpen:     false,
+		ShareURL:   "",
+		Quiet:      false,
+		Output:     "",
+		Author:     "",
+		BaseBranch: "",
+		IgnorePatterns: []string{
+			"*.lock",
+			"*.min.js",
+			"*.min.css",
+			".crit/",
+		},
+		AgentCmd:         "",
+		CleanupOnApprove: true,
+		VCS:              "",
+		Storage:          storageGlobal,
+		Gitignore:        "",
+		Backend:          backendFile,
+		ReviewTemplate:   "",
+		OutputFormats:    []string{},
+		ReviewStyle:      reviewStyleVerbose,
+		Browser:          "",
+		InstallAgents:    []string{},
+		ReviewFileName:   "",
+		Backups:          defaultBackups,
+		Checklist:        []string{},
+		ChecklistFile:    "",
+		Bell:             false,
+		BellCommand:      "",
+		Webhooks:         []webhookConfig{},
+		Notifiers:        []notifierConfig{},
+		TabWidth:         defaultTabWidth,
+		RateLimit:        rateLimitConfig{Rate: defaultRateLimit, Burst: defaultRateBurst},
+		CORSOrigins:      []string{},
+
+		UpdateChannel:       updateChannelStable,
+		UpdateCheckInterval: defaultUpdateCheckInterval.String(),
+		UpdateProxy:         "",
+
+		MaxCommentBody: defaultMaxCommentBody,
+		MaxComments:    defaultMaxComments,
+
+		DuplicateComments: duplicateMerge,
+	}
+}
+
+// generatedConfig is like Config but without omitempty, so all keys appear in output.
+// auth_token is intentionally excluded — it is global-only and should not appear
+// in project config files where it could be accidentally committed.
+type generatedConfig struct {
+	Port               int              `json:"port"`
+	NoOpen             bool             `json:"no_open"`
+	ShareURL           string           `json:"share_url"`
+	ShareEncrypt       bool             `json:"share_encrypt"`
+	ShareExpires       string           `json:"share_expires"`
+	Quiet              bool             `json:"quiet"`
+	Output             string           `json:"output"`
+	Author             string           `json:"author"`
+	BaseBranch         string           `json:"base_branch"`
+	IgnorePatterns     []string         `json:"ignore_patterns"`
+	NoIntegrationCheck bool             `json:"no_integration_check"`
+	NoUpdateCheck      bool             `json:"no_update_check"`
+	AgentCmd           string           `json:"agent_cmd"`
+	CleanupOnApprove   bool             `json:"cleanup_on_approve"`
+	VCS                string           `json:"vcs"`
+	Storage            string           `json:"storage"`
+	Gitignore          string           `json:"gitignore"`
+	Backend            string           `json:"backend"`
+	ReviewTemplate     string           `json:"review_template"`
+	OutputFormats      []string         `json:"output_formats"`
+	ReviewStyle        string           `json:"review_style"`
+	Browser            string           `json:"browser"`
+	InstallAgents      []string         `json:"install_agents"`
+	ReviewFileName     string           `json:"review_filename"`
+	Backups            int              `json:"backups"`
+	Checklist          []string         `json:"checklist"`
+	ChecklistFile      string           `json:"checklist_file"`
+	Bell               bool             `json:"bell"`
+	BellCommand        string           `json:"bell_command"`
+	Hooks              hookCommands     `json:"hooks"`
+	Webhooks           []webhookConfig  `json:"webhooks"`
+	Notifiers          []notifierConfig `json:"notifiers"`
+	Jira               jiraConfig       `json:"jira"`
+	Linear             linearConfig     `json:"linear"`
+	SMTP               smtpConfig       `json:"smtp"`
+	Linters            []linterConfig   `json:"linters"`
+	ProseCheck         bool             `json:"prose_check"`
+	RateLimit          rateLimitConfig  `json:"rate_limit"`
+	CORSOrigins        []string         `json:"cors_origins"`
+
+	UpdateChannel       string `json:"update_channel"`
+	UpdateCheckInterval string `json:"update_check_interval"`
+	UpdateProxy         string `json:"update_proxy"`
+
+	MaxCommentBody int `json:"max_comment_body"`
+	MaxComments    int `json:"max_comments"`
+
+	DuplicateComments string `json:"duplicate_comments"`
+
+	TabWidth               int    `json:"tab_width"`
+	ShowTrailingWhitespace bool   `json:"show_trailing_whitespace"`
+	Wrap                   string `json:"wrap"`
+	Language               string `json:"language"`
+	Timezone               string `json:"timezone"`
+}
+
+func (c generatedConfig) String() string {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "{}"
+	}
+	return string(data) + "\n"
+}
+
+// configPresence tracks which fields were explicitly present in a JSON config file.
+// This allows distinguishing "not set" from "explicitly set to empty/zero".
+type configPresence struct {
+	ShareURL               bool
+	IgnorePatterns         bool
+	NoOpen                 bool
+	Quiet                  bool
+	NoIntegrationCheck     bool
+	NoUpdateCheck          bool
+	CleanupOnApprove       bool
+	ShowTrailingWhitespace bool
+	Bell                   bool
+	ProseCheck             bool
+}
+
+// loadConfigFile reads and parses a single JSON config file.
+// Returns a zero Config and empty presence if the file doesn't exist.
+func loadConfigFile(path string) (Config, configPresence, error) {
+	var cfg Config
+	var presence configPresence
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, presence, nil
+		}
+		return cfg, presence, err
+	}
+
+	// Detect which keys are explicitly present in the JSON
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return cfg, presence, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	_, presence.ShareURL = raw["share_url"]
+	_, presence.IgnorePatterns = raw["ignore_patterns"]
+	_, presence.NoOpen = raw["no_open"]
+	_, presence.Quiet = raw["quiet"]
+	_, presence.NoIntegrationCheck = raw["no_integration_check"]
+	_, presence.NoUpdateCheck = raw["no_update_check"]
+	_, presence.CleanupOnApprove = raw["cleanup_on_approve"]
+	_, presence.ShowTrailingWhitespace = raw["show_trailing_whitespace"]
+	_, presence.Bell = raw["bell"]
+	_, presence.ProseCheck = raw["prose_check"]
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, presence, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return cfg, presence, nil
+}
+
+// mergeConfigs merges project config on top of global config.
+// Non-zero project values override global. Bool fields use presence tracking
+// so that project config `no_open: false` can override global `no_open: true`.
+// Ignore patterns are unioned.
+func mergeConfigs(global, project Config, projectPresence configPresence) Config {
+	merged := global
+	if project.Port != 0 {
+		merged.Port = project.Port
+	}
+	if projectPresence.NoOpen {
+		merged.NoOpen = project.NoOpen
+	}
+	if project.ShareURL != "" {
+		merged.ShareURL = project.ShareURL
+	}
+	// A project can ask for encrypted shares but not turn them off.
+	if project.ShareEncrypt {
+		merged.ShareEncrypt = true
+	}
+	merged.ShareExpires = shorterShareExpiry(merged.ShareExpires, project.ShareExpires)
+	if projectPresence.Quiet {
+		merged.Quiet = project.Quiet
+	}
+	if project.Output != "" {
+		merged.Output = project.Output
+	}
+	if project.Author != "" {
+		merged.Author = project.Author
+	}
+	if project.BaseBranch != "" {
+		merged.BaseBranch = project.BaseBranch
+	}
+	if project.VCS != "" {
+		merged.VCS = project.VCS
+	}
+	if projectPresence.NoIntegrationCheck {
+		merged.NoIntegrationCheck = project.NoIntegrationCheck
+	}
+	if projectPresence.NoUpdateCheck {
+		merged.NoUpdateCheck = project.NoUpdateCheck
+	}
+	if projectPresence.CleanupOnApprove {
+		merged.CleanupOnApprove = project.CleanupOnApprove
+	}
+	mergeReviewConfig(&merged, project, projectPresence)
+	mergeUIConfig(&merged, project, projectPresence)
+	// Security: agent_cmd is intentionally NOT merged from project config.
+	// It must remain global-only to prevent untrusted project configs from
+	// overriding the agent command.
+	// auth_token is global-only (like agent_cmd) — project config cannot override
+	// browser, bell_command, hooks and linters are global-only for the same reason:
+	// they name commands to run.
+	// update_* are global-only: a repo shouldn't pick the release channel or
+	// route requests through its own proxy.
+	// webhooks, notifiers, jira, linear and smtp are global-only: a repo must not be
+	// able to send review content to places the user didn't choose.
+	// cors_origins is global-only: a repo must not let another site call the API.
+	// Union ignore patterns
+	merged.IgnorePatterns = append(merged.IgnorePatterns, project.IgnorePatterns...)
+	return merged
+}
+
+// mergeReviewConfig applies the project's storage, review file and agent
+// prompt settings for mergeConfigs.
+func mergeReviewConfig(merged *Config, project Config, projectPresence configPresence) {
+	if project.Storage != "" {
+		merged.Storage = project.Storage
+	}
+	if project.Gitignore != "" {
+		merged.Gitignore = project.Gitignore
+	}
+	if project.Backend != "" {
+		merged.Backend = project.Backend
+	}
+	if project.ReviewTemplate != "" {
+		merged.ReviewTemplate = project.ReviewTemplate
+	}
+	if project.OutputFormats != nil {
+		merged.OutputFormats = project.OutputFormats
+	}
+	if project.ReviewStyle != "" {
+		merged.ReviewStyle = project.ReviewStyle
+	}
+	if project.InstallAgents != nil {
+		merged.InstallAgents = project.InstallAgents
+	}
+	if project.ReviewFileName != "" {
+		merged.ReviewFileName = project.ReviewFileName
+	}
+	if project.Backups != 0 {
+		merged.Backups = project.Backups
+	}
+	if project.Checklist != nil {
+		merged.Checklist = project.Checklist
+	}
+	if project.ChecklistFile != "" {
+		merged.ChecklistFile = project.ChecklistFile
+	}
+	if projectPresence.ProseCheck {
+		merged.ProseCheck = project.ProseCheck
+	}
+	mergeCommentAPIConfig(merged, project)
+}
+
+// mergeCommentAPIConfig applies the project's comment API limits for
+// mergeReviewConfig.
+func mergeCommentAPIConfig(merged *Config, project Config) {
+	if project.MaxCommentBody != 0 {
+		merged.MaxCommentBody = project.MaxCommentBody
+	}
+	if project.MaxComments != 0 {
+		merged.MaxComments = project.MaxComments
+	}
+	if project.DuplicateComments != "" {
+		merged.DuplicateComments = project.DuplicateComments
+	}
+	if project.RateLimit != (rateLimitConfig{}) {
+		merged.RateLimit = project.RateLimit
+	}
+}
+
+// mergeUIConfig applies the project's display and bell settings for
+// mergeConfigs.
+func mergeUIConfig(merged *Config, project Config, projectPresence configPresence) {
+	if project.TabWidth != 0 {
+		merged.TabWidth = project.TabWidth
+	}
+	if projectPresence.ShowTrailingWhitespace {
+		merged.ShowTrailingWhitespace = project.ShowTrailingWhitespace
+	}
+	if project.Wrap != "" {
+		merged.Wrap = project.Wrap
+	}
+	if project.Language != "" {
+		merged.Language = project.Language
+	}
+	if project.Timezone != "" {
+		merged.Timezone = project.Timezone
+	}
+	if projectPresence.Bell {
+		merged.Bell = project.Bell
+	}
+}
+
+// LoadConfig loads and merges configuration from all sources.
+// projectDir is the repo root (or cwd if not in a git repo).
+// Runtime defaults (share_url, ignore_patterns) are applied when no config
+// file explicitly sets those fields. To disable defaults, set them to
+// empty values in a config file (e.g. "share_url": "", "ignore_patterns": []).
+func LoadConfig(projectDir string) Config {
+	// 1. Global config
+	global, globalPresence, err := loadConfigFile(globalConfigPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: reading global config: %v\n", err)
+	}
+
+	// 2. Project config (skip if same file as global config, e.g. when CWD is home dir)
+	var project Config
+	var projectPresence configPresence
+	projectConfigPath := findProjectConfig(projectDir)
+	globalAbs, _ := filepath.Abs(globalConfigPath())
+	projectAbs, _ := filepath.Abs(projectConfigPath)
+	if globalAbs != projectAbs {
+		project, projectPresence, err = loadConfigFile(projectConfigPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: reading project config: %v\n", err)
+		}
+	}
+
+	// 3. Merge global + project
+	merged := mergeConfigs(global, project, projectPresence)
+
+	// 4. Apply runtime defaults for fields not explicitly set in any config file
+	if !globalPresence.IgnorePatterns && !projectPresence.IgnorePatterns {
+		merged.IgnorePatterns = []string{".crit/"}
+	}
+
+	// 5. Environment variables override both config files
+	applyEnvOverrides(&merged)
+
+	// 6. Fall back to VCS user name if no author configured.
+	// Try the configured VCS first, then fall back to the other.
+	if merged.Author == "" {
+		switch merged.VCS {
+		case "sl", "sapling":
+			merged.Author = slUserName()
+			if merged.Author == "" {
+				merged.Author = gitUserName()
+			}
+		default:
+			merged.Author = gitUserName()
+			if merged.Author == "" {
+				merged.Author = slUserName()
+			}
+		}
+	}
+
+	return merged
+}
+
+// findProjectConfig returns the project config that applies to startDir: the
+// nearest .crit.config.json in startDir or one of its parents. The search
+// stops at the repository root (a directory holding .git, .sl or .hg) and
+// never reaches the home directory, whose config is the global one. When
+// neither is an ancestor of startDir, only startDir is searched. When nothing
+// is found the path in startDir is returned; loadConfigFile treats a missing
+// file as empty.
+func findProjectConfig(startDir string) string {
+	start, err := filepath.Abs(startDir)
+	if err != nil {
+		return filepath.Join(startDir, ".crit.config.json")
+	}
+	home, _ := os.UserHomeDir()
+	stop := configSearchStop(start, home)
+	for dir := start; dir != home; dir = filepath.Dir(dir) {
+		path := filepath.Join(dir, ".crit.config.json")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		if dir == stop || stop == "" {
+			break
+		}
+	}
+	return filepath.Join(start, ".crit.config.json")
+}
+
+// configSearchStop returns the nearest of start and its parents that is a
+// repository root or home, or "" when there is none.
+func configSearchStop(start, home string) string {
+	for dir := start; ; dir = filepath.Dir(dir) {
+		if dir == home || isRepoRootDir(dir) {
+			return dir
+		}
+		if filepath.Dir(dir) == dir {
+			return ""
+		}
+	}
+}
+
+func isRepoRootDir(dir string) bool {
+	for _, marker := range []string{".git", ".sl", ".hg"} {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Environment variables that override config file values.
+const (
+	envPort               = "CRIT_PORT"
+	envShareURL           = "CRIT_SHARE_URL"
+	envNoUpdateCheck      = "CRIT_NO_UPDATE_CHECK"
+	envNoIntegrationCheck = "CRIT_NO_INTEGRATION_CHECK"
+	envAuthToken          = "CRIT_AUTH_TOKEN"
+)
+
+// applyEnvOverrides applies environment variables on top of the merged config
+// files. Together with LoadConfig this defines precedence for every setting:
+// CLI flag > environment > project config > global config > defaults. Flags are
+// applied by the caller (applyConfigDefaults). CRIT_AUTH_TOKEN is handled by
+// resolveAuthToken so the token never appears in `crit config` output.
+func applyEnvOverrides(cfg *Config) {
+	if v := os.Getenv(envPort); v != "" {
+		if p, err := strconv.Atoi(v); err == nil {
+			cfg.Port = p
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid %s=%q\n", envPort, v)
+		}
+	}
+	// Set-but-empty CRIT_SHARE_URL disables sharing, see resolveShareURL.
+	if v, ok := os.LookupEnv(envShareURL); ok {
+		cfg.ShareURL = v
+	}
+	if os.Getenv(envNoUpdateCheck) != "" {
+		cfg.NoUpdateCheck = true
+	}
+	if os.Getenv(envNoIntegrationCheck) != "" {
+		cfg.NoIntegrationCheck = true
+	}
+}
+
+// globalConfigPath returns the path to the global config file:
+// ~/.crit.config.json if it exists, otherwise $XDG_CONFIG_HOME/crit/config.json
+// (~/.config/crit/config.json) if that exists. With neither present it returns
+// ~/.crit.config.json, which is where new global settings are written.
+func globalConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	legacy := filepath.Join(home, ".crit.config.json")
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy
+	}
+	xdg := xdgConfigPath(home)
+	if _, err := os.Stat(xdg); err == nil {
+		return xdg
+	}
+	return legacy
+}
+
+// xdgConfigPath returns crit's config file under the XDG config directory.
+func xdgConfigPath(home string) string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" || !filepath.IsAbs(dir) {
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "crit", "config.json")
+}
+
+// saveGlobalConfig performs a read-modify-write on ~/.crit.config.json.
+// The file is written with 0600 permissions since it may contain auth_token.
+func saveGlobalConfig(apply func(m map[string]json.RawMessage) error) error {
+	path := globalConfigPath()
+	if path == "" {
+		return fmt.Errorf("cannot determine home directory")
+	}
+	return saveConfigFile(path, 0o600, apply)
+}
+
+// saveConfigFile performs a read-modify-write on the config file at path.
+// It uses map[string]json.RawMessage to preserve unknown keys.
+// The apply function receives the raw map and should set or delete keys as needed.
+func saveConfigFile(path string, perm os.FileMode, apply func(m map[string]json.RawMessage) error) error {
+	raw := make(map[string]json.RawMessage)
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	if err := apply(raw); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling config: %w", err)
+	}
+	data = append(data, '\n')
+	return atomicWriteFile(path, data, perm)
+}
+
+// gitUserName returns the git-configured user name, or empty string on error.
+func gitUserName() string {
+	out, err := exec.Command("git", "config", "user.name").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// slUserName returns the Sapling-configured user name, or empty string on error.
+// Strips the email suffix ("Name <email>" -> "Name").
+func slUserName() string {
+	out, err := exec.Command("sl", "config", "ui.username").Output()
+	if err != nil {
+		return ""
+	}
+	name := strings.TrimSpace(string(out))
+	if idx := strings.Index(name, " <"); idx >= 0 {
+		name = name[:idx]
+	}
+	return name
+}
+
+// matchPattern checks if a file path matches an ignore pattern.
+// Pattern types:
+//   - "*.ext"         → matches files ending in .ext anywhere
+//   - "dir/"          → matches all files under dir/
+//   - "exact.file"    → matches filename anywhere in tree
+//   - "path/*.ext"    → filepath.Match against full path
+func matchPattern(pattern, path string) bool {
+	// Directory prefix match
+	if strings.HasSuffix(pattern, "/") {
+		prefix := pattern // includes trailing /
+		return strings.HasPrefix(path, prefix) || strings.Contains(path, "/"+prefix)
+	}
+
+	// If pattern contains /, match against full path
+	if strings.Contains(pattern, "/") {
+		matched, _ := filepath.Match(pattern, path)
+		return matched
+	}
+
+	// Match against filename only
+	filename := filepath.Base(path)
+	matched, _ := filepath.Match(pattern, filename)
+	return matched
+}
+
+// filterIgnored removes FileChange entries matching any ignore pattern.
+func filterIgnored(files []FileChange, patterns []string) []FileChange {
+	if len(patterns) == 0 {
+		return files
+	}
+	var result []FileChange
+	for _, f := range files {
+		ignored := false
+		for _, p := range patterns {
+			if matchPattern(p, f.Path) {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// filterPathsIgnored removes string paths matching any ignore pattern.
+// Used by handleFilesList to filter the file picker results.
+func filterPathsIgnored(paths []string, patterns []string) []string {
+	if len(patterns) == 0 {
+		return paths
+	}
+	var result []string
+	for _, p := range paths {
+		ignored := false
+		for _, pat := range patterns {
+			if matchPattern(pat, p) {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			result = append(result, p)
+		}
+	}
+	return result
+}
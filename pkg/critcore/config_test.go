@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"encoding/json"
@@ -199,6 +199,33 @@ func TestMergeConfigsBoolOverride(t *testing.T) {
 	}
 }
 
+func TestMergeConfigs_DisplaySettings(t *testing.T) {
+	global := Config{TabWidth: 2, ShowTrailingWhitespace: true, Wrap: "on"}
+	project := Config{TabWidth: 4, ShowTrailingWhitespace: false}
+	merged := mergeConfigs(global, project, configPresence{ShowTrailingWhitespace: true})
+	if merged.TabWidth != 4 || merged.ShowTrailingWhitespace || merged.Wrap != "on" {
+		t.Errorf("merged display settings = %d, %v, %q", merged.TabWidth, merged.ShowTrailingWhitespace, merged.Wrap)
+	}
+}
+
+func TestMergeConfigs_Checklist(t *testing.T) {
+	global := Config{Checklist: []string{"Has tests"}, ChecklistFile: "global.md"}
+	project := Config{Checklist: []string{"Covers rollback"}}
+	merged := mergeConfigs(global, project, configPresence{})
+	if len(merged.Checklist) != 1 || merged.Checklist[0] != "Covers rollback" || merged.ChecklistFile != "global.md" {
+		t.Errorf("merged checklist = %q, %q", merged.Checklist, merged.ChecklistFile)
+	}
+}
+
+func TestMergeConfigs_Bell(t *testing.T) {
+	global := Config{Bell: true, BellCommand: "afplay glass.aiff"}
+	project := Config{Bell: false, BellCommand: "rm -rf ~"}
+	merged := mergeConfigs(global, project, configPresence{Bell: true})
+	if merged.Bell || merged.BellCommand != "afplay glass.aiff" {
+		t.Errorf("bell = %v, bell_command = %q (bell_command is global-only)", merged.Bell, merged.BellCommand)
+	}
+}
+
 func TestLoadConfig(t *testing.T) {
 	// Set up global config
 	homeDir := t.TempDir()
@@ -666,3 +693,184 @@ func TestLoadConfig_OutputField(t *testing.T) {
 		t.Errorf("Output = %q, want /tmp/output", cfg.Output)
 	}
 }
+
+func TestFindProjectConfig_WalksUpToRepoRoot(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+	nested := filepath.Join(root, "docs", "plans")
+	os.MkdirAll(nested, 0755)
+
+	// Nothing anywhere: the candidate in the start dir is returned.
+	if got, want := findProjectConfig(nested), filepath.Join(nested, ".crit.config.json"); got != want {
+		t.Errorf("no config: got %q, want %q", got, want)
+	}
+
+	rootCfg := filepath.Join(root, ".crit.config.json")
+	os.WriteFile(rootCfg, []byte(`{}`), 0644)
+	if got := findProjectConfig(nested); got != rootCfg {
+		t.Errorf("got %q, want repo root config %q", got, rootCfg)
+	}
+
+	// The nearest config wins.
+	docsCfg := filepath.Join(root, "docs", ".crit.config.json")
+	os.WriteFile(docsCfg, []byte(`{}`), 0644)
+	if got := findProjectConfig(nested); got != docsCfg {
+		t.Errorf("got %q, want nearest config %q", got, docsCfg)
+	}
+}
+
+func TestFindProjectConfig_StopsAtRepoRoot(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	outer := t.TempDir()
+	os.WriteFile(filepath.Join(outer, ".crit.config.json"), []byte(`{}`), 0644)
+	repo := filepath.Join(outer, "repo")
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+
+	if got, want := findProjectConfig(repo), filepath.Join(repo, ".crit.config.json"); got != want {
+		t.Errorf("config above the repo root leaked in: got %q", got)
+	}
+}
+
+func TestFindProjectConfig_OutsideRepoAndHome(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	outer := t.TempDir()
+	os.WriteFile(filepath.Join(outer, ".crit.config.json"), []byte(`{}`), 0644)
+	nested := filepath.Join(outer, "a")
+	os.MkdirAll(nested, 0755)
+
+	// No repository root or home above nested: only nested itself is searched.
+	if got, want := findProjectConfig(nested), filepath.Join(nested, ".crit.config.json"); got != want {
+		t.Errorf("walked outside repo and home: got %q, want %q", got, want)
+	}
+	if got, want := findProjectConfig(outer), filepath.Join(outer, ".crit.config.json"); got != want {
+		t.Errorf("start dir config: got %q, want %q", got, want)
+	}
+}
+
+func TestFindProjectConfig_WalksUpToHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	projects := filepath.Join(home, "projects")
+	nested := filepath.Join(projects, "notes")
+	os.MkdirAll(nested, 0755)
+	cfg := filepath.Join(projects, ".crit.config.json")
+	os.WriteFile(cfg, []byte(`{}`), 0644)
+
+	if got := findProjectConfig(nested); got != cfg {
+		t.Errorf("got %q, want %q", got, cfg)
+	}
+}
+
+func TestLoadConfig_FromNestedDir(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+	os.WriteFile(filepath.Join(root, ".crit.config.json"), []byte(`{"port": 4321}`), 0644)
+	nested := filepath.Join(root, "a", "b")
+	os.MkdirAll(nested, 0755)
+
+	if cfg := LoadConfig(nested); cfg.Port != 4321 {
+		t.Errorf("port = %d, want 4321 from repo root config", cfg.Port)
+	}
+}
+
+func TestLoadConfig_EnvOverridesConfigFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	projectDir := t.TempDir()
+	os.WriteFile(filepath.Join(projectDir, ".crit.config.json"),
+		[]byte(`{"port": 4000, "share_url": "https://config.example.com"}`), 0644)
+	t.Setenv("CRIT_PORT", "5000")
+	t.Setenv("CRIT_SHARE_URL", "")
+	t.Setenv("CRIT_NO_UPDATE_CHECK", "1")
+	t.Setenv("CRIT_NO_INTEGRATION_CHECK", "1")
+
+	cfg := LoadConfig(projectDir)
+	if cfg.Port != 5000 {
+		t.Errorf("port = %d, want 5000 from env", cfg.Port)
+	}
+	if cfg.ShareURL != "" {
+		t.Errorf("share_url = %q, want empty (set-but-empty env disables sharing)", cfg.ShareURL)
+	}
+	if !cfg.NoUpdateCheck || !cfg.NoIntegrationCheck {
+		t.Errorf("no_update_check=%v no_integration_check=%v, want both true", cfg.NoUpdateCheck, cfg.NoIntegrationCheck)
+	}
+
+	t.Setenv("CRIT_PORT", "not-a-port")
+	if cfg := LoadConfig(projectDir); cfg.Port != 4000 {
+		t.Errorf("invalid CRIT_PORT: port = %d, want config value 4000", cfg.Port)
+	}
+}
+
+func TestGlobalConfigPath_XDG(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	legacy := filepath.Join(homeDir, ".crit.config.json")
+	xdg := filepath.Join(homeDir, ".config", "crit", "config.json")
+
+	// Neither exists: writes go to the legacy path.
+	if got := globalConfigPath(); got != legacy {
+		t.Errorf("no config: got %q, want %q", got, legacy)
+	}
+
+	writeFile(t, xdg, `{"author": "xdg"}`)
+	if got := globalConfigPath(); got != xdg {
+		t.Errorf("xdg only: got %q, want %q", got, xdg)
+	}
+	if cfg := LoadConfig(t.TempDir()); cfg.Author != "xdg" {
+		t.Errorf("Author = %q, want xdg", cfg.Author)
+	}
+
+	// The legacy file wins when both exist.
+	writeFile(t, legacy, `{"author": "legacy"}`)
+	if got := globalConfigPath(); got != legacy {
+		t.Errorf("both: got %q, want %q", got, legacy)
+	}
+
+	custom := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", custom)
+	if got := xdgConfigPath(homeDir); got != filepath.Join(custom, "crit", "config.json") {
+		t.Errorf("XDG_CONFIG_HOME ignored: %q", got)
+	}
+}
+
+func TestMergeConfigs_BrowserGlobalOnly(t *testing.T) {
+	global := Config{Browser: "firefox", InstallAgents: []string{"codex"}}
+	project := Config{Browser: "evil-cmd", InstallAgents: []string{"cursor", "cline"}}
+	merged := mergeConfigs(global, project, configPresence{})
+	if merged.Browser != "firefox" {
+		t.Errorf("Browser = %q, project config must not override it", merged.Browser)
+	}
+	if len(merged.InstallAgents) != 2 || merged.InstallAgents[0] != "cursor" {
+		t.Errorf("InstallAgents = %v, want project value", merged.InstallAgents)
+	}
+}
+
+func TestMergeConfigs_UpdateSettingsGlobalOnly(t *testing.T) {
+	global := Config{UpdateChannel: "stable", UpdateCheckInterval: "24h"}
+	project := Config{UpdateChannel: "prerelease", UpdateCheckInterval: "0", UpdateProxy: "http://evil:8080"}
+	merged := mergeConfigs(global, project, configPresence{})
+	if merged.UpdateChannel != "stable" || merged.UpdateCheckInterval != "24h" || merged.UpdateProxy != "" {
+		t.Errorf("update settings = %q %q %q, project config must not override them", merged.UpdateChannel, merged.UpdateCheckInterval, merged.UpdateProxy)
+	}
+}
+
+func TestMergeConfigs_CORSOriginsGlobalOnly(t *testing.T) {
+	global := Config{CORSOrigins: []string{"http://localhost:5173"}}
+	project := Config{CORSOrigins: []string{"https://evil.example.com"}}
+	merged := mergeConfigs(global, project, configPresence{})
+	if len(merged.CORSOrigins) != 1 || merged.CORSOrigins[0] != "http://localhost:5173" {
+		t.Errorf("CORSOrigins = %v, project config must not override it", merged.CORSOrigins)
+	}
+}
+
+func TestBrowserSpecFromConfig(t *testing.T) {
+	specs := browserSpecFromConfig("firefox --new-window", "http://localhost:1")
+	if len(specs) != 1 || specs[0].name != "firefox" || len(specs[0].args) != 2 || specs[0].args[1] != "http://localhost:1" {
+		t.Errorf("specs = %+v", specs)
+	}
+	if got := browserSpecFromConfig("  ", "u"); got != nil {
+		t.Errorf("blank browser: got %+v", got)
+	}
+}
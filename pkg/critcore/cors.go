@@ -0,0 +1,63 @@
+package critcore
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// By default the daemon only answers browser requests from its own review
+// page (hostcheck.go). cors_origins names other origins, such as an IDE
+// webview or a local dashboard, that may call the API too: they get CORS
+// headers, skip the CSRF check, and authenticate with the access token as a
+// bearer token (they can't have the cookie). It's global only, so a repo
+// can't open the API to a site of its choosing.
+
+// corsAllowHeaders are the request headers other origins may send.
+const corsAllowHeaders = "Authorization, Content-Type, " + csrfHeader
+
+// validateCORSOrigins checks the cors_origins config list: each entry is a
+// bare http or https origin, without a path.
+func validateCORSOrigins(origins []string) error {
+	for i, origin := range origins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" {
+			return fmt.Errorf("cors_origins[%d]: invalid origin %q (want scheme://host[:port], e.g. http://localhost:5173)", i, origin)
+		}
+	}
+	return nil
+}
+
+// corsAllowed reports whether origin (an Origin header) is in origins.
+func corsAllowed(origins []string, origin string) bool {
+	return origin != "" && slices.ContainsFunc(origins, func(o string) bool {
+		return strings.EqualFold(strings.TrimSuffix(o, "/"), origin)
+	})
+}
+
+// handleCORS adds CORS headers for allowed origins and answers their
+// preflight requests, which carry no token. It reports whether r was
+// answered.
+func (s *Server) handleCORS(w http.ResponseWriter, r *http.Request) bool {
+	if len(s.corsOrigins) == 0 {
+		return false
+	}
+	w.Header().Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	if !corsAllowed(s.corsOrigins, origin) {
+		return false
+	}
+	h := w.Header()
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Set("Access-Control-Expose-Headers", "Retry-After")
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+	h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+	h.Set("Access-Control-Max-Age", "600")
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
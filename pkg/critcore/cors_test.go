@@ -0,0 +1,65 @@
+package critcore
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateCORSOrigins(t *testing.T) {
+	if err := validateCORSOrigins([]string{"http://localhost:5173", "https://dash.internal/", "vscode-webview://x"}); err == nil {
+		t.Error("accepted a non-http origin")
+	}
+	if err := validateCORSOrigins([]string{"http://localhost:5173", "https://dash.internal/"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, bad := range []string{"localhost:5173", "http://localhost:5173/app", "*", ""} {
+		if err := validateCORSOrigins([]string{bad}); err == nil {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+func TestServer_CORS(t *testing.T) {
+	s, session := newTestServer(t)
+	s.checkHost = true
+	s.accessToken = "secret"
+	s.corsOrigins = []string{"http://localhost:5173"}
+	do := func(method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/comments", strings.NewReader(`{"body":"hi"}`))
+		req.Host = "localhost:4000"
+		req.Header.Set("Origin", origin)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	// The preflight carries no token and is answered before the token check.
+	w := do("OPTIONS", "http://localhost:5173", map[string]string{"Access-Control-Request-Method": "POST"})
+	if w.Code != 204 || w.Header().Get("Access-Control-Allow-Origin") != "http://localhost:5173" || !strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Fatalf("preflight: status %d, headers %v", w.Code, w.Header())
+	}
+
+	// An allowed origin needs the access token but not the CSRF token.
+	if w := do("POST", "http://localhost:5173", nil); w.Code != 401 {
+		t.Errorf("without token: status = %d, want 401", w.Code)
+	}
+	w = do("POST", "http://localhost:5173", map[string]string{"Authorization": "Bearer secret"})
+	if w.Code != 201 || w.Header().Get("Access-Control-Allow-Origin") != "http://localhost:5173" {
+		t.Errorf("with token: status %d, headers %v", w.Code, w.Header())
+	}
+	if n := session.TotalCommentCount(); n != 1 {
+		t.Errorf("comments = %d, want 1", n)
+	}
+
+	// Other origins are still refused, preflight or not.
+	if w := do("OPTIONS", "http://localhost:3000", map[string]string{"Access-Control-Request-Method": "POST"}); w.Code != 403 {
+		t.Errorf("other origin preflight: status = %d, want 403", w.Code)
+	}
+	if w := do("POST", "http://localhost:3000", map[string]string{"Authorization": "Bearer secret"}); w.Code != 403 || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("other origin: status %d, headers %v", w.Code, w.Header())
+	}
+}
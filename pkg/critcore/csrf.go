@@ -0,0 +1,40 @@
+package critcore
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Mutating API requests sent by a browser must carry the server's CSRF
+// token in csrfHeader. The review UI gets it from GET /api/config, which
+// other sites can't read, so a cross-site form or fetch can't add or delete
+// comments. Agents and the CLI don't run in a browser, send no Origin or
+// Sec-Fetch-Site, and need no token.
+
+// csrfHeader is the request header the review UI puts the token in.
+const csrfHeader = "X-Crit-CSRF"
+
+// fromBrowser reports whether r was sent by a web page: browsers add Origin
+// to cross-origin and POST requests and Sec-Fetch-Site to all of them, and
+// pages can't leave either out.
+func fromBrowser(r *http.Request) bool {
+	return r.Header.Get("Origin") != "" || r.Header.Get("Sec-Fetch-Site") != ""
+}
+
+// admitCSRF answers 403 on w for a browser's mutating API request without
+// the right token. Origins in cors_origins are trusted and need none.
+func (s *Server) admitCSRF(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if !strings.HasPrefix(r.URL.Path, "/api/") || !fromBrowser(r) || corsAllowed(s.corsOrigins, r.Header.Get("Origin")) {
+		return true
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(s.csrfToken)) == 1 {
+		return true
+	}
+	http.Error(w, "Forbidden: missing or invalid "+csrfHeader+" token", http.StatusForbidden)
+	return false
+}
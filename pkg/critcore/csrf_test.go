@@ -0,0 +1,53 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_CSRF(t *testing.T) {
+	s, session := newTestServer(t)
+	s.cfg.DuplicateComments = duplicateOff // every request posts the same comment
+	post := func(headers map[string]string) int {
+		req := httptest.NewRequest("POST", "/api/comments", strings.NewReader(`{"body":"hi"}`))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// The review UI learns the token from /api/config.
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/config", nil))
+	var cfg struct {
+		CSRFToken string `json:"csrf_token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &cfg)
+	if cfg.CSRFToken == "" || cfg.CSRFToken != s.csrfToken {
+		t.Fatalf("csrf_token = %q", cfg.CSRFToken)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"agent or CLI", nil, 201},
+		{"browser with token", map[string]string{"Origin": "http://localhost:4000", csrfHeader: cfg.CSRFToken}, 201},
+		{"cross-site form", map[string]string{"Origin": "https://evil.example.com"}, 403},
+		{"browser without Origin", map[string]string{"Sec-Fetch-Site": "cross-site"}, 403},
+		{"wrong token", map[string]string{"Sec-Fetch-Site": "same-origin", csrfHeader: "guess"}, 403},
+	}
+	for _, tt := range tests {
+		if got := post(tt.headers); got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if n := session.TotalCommentCount(); n != 2 {
+		t.Errorf("comments = %d, want 2", n)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"bufio"
@@ -6,13 +6,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -34,6 +37,16 @@ type sessionEntry struct {
 	Branch     string   `json:"branch"`
 	ReviewPath string   `json:"review_path"`
 	StartedAt  string   `json:"started_at"`
+	Token      string   `json:"token,omitempty"` // the daemon's access token (accesstoken.go)
+}
+
+// url is the daemon's address for path, carrying the access token.
+func (s sessionEntry) url(path string) string {
+	u := fmt.Sprintf("http://localhost:%d%s", s.Port, path)
+	if s.Token != "" {
+		u += "?token=" + s.Token
+	}
+	return u
 }
 
 // resolvedCWD returns the current working directory with symlinks resolved.
@@ -72,13 +85,13 @@ func sessionKey(cwd string, branch string, args []string) string {
 	return fmt.Sprintf("%x", h.Sum(nil))[:12]
 }
 
-// sessionsDir returns the path to ~/.crit/sessions/.
+// sessionsDir returns the path to <storage root>/sessions/ (~/.crit/sessions/ by default).
 func sessionsDir() (string, error) {
-	home, err := os.UserHomeDir()
+	root, err := storageRoot()
 	if err != nil {
-		return "", fmt.Errorf("finding home directory: %w", err)
+		return "", err
 	}
-	return filepath.Join(home, ".crit", "sessions"), nil
+	return filepath.Join(root, "sessions"), nil
 }
 
 // sessionFilePath returns the full path for a session file.
@@ -90,26 +103,35 @@ func sessionFilePath(key string) (string, error) {
 	return filepath.Join(dir, key+".json"), nil
 }
 
-// reviewsDir returns the path to ~/.crit/reviews/.
+// reviewsDir returns the path to <storage root>/reviews/ (~/.crit/reviews/ by default).
 func reviewsDir() (string, error) {
-	home, err := os.UserHomeDir()
+	root, err := storageRoot()
 	if err != nil {
-		return "", fmt.Errorf("finding home directory: %w", err)
+		return "", err
 	}
-	return filepath.Join(home, ".crit", "reviews"), nil
+	return filepath.Join(root, "reviews"), nil
 }
 
-// reviewFilePath returns the full path for a review data file.
+// reviewFilePath returns the full path for a review data file. A review that
+// only exists in the legacy ~/.crit layout is used in place.
 func reviewFilePath(key string) (string, error) {
 	dir, err := reviewsDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(dir, key+".json"), nil
+	return existsOrLegacy(filepath.Join(dir, key+".json"), "reviews", key+".json"), nil
 }
 
 // atomicWriteFile writes data to the target path atomically using temp file + fsync + rename.
+// The temp file lives in the target's directory so the rename never crosses
+// filesystems; concurrent readers see either the old or the new content, never
+// a truncated file.
 func atomicWriteFile(target string, data []byte, perm os.FileMode) error {
+	// Write through symlinks (e.g. a dotfiles-managed config) rather than
+	// replacing the link with a regular file.
+	if resolved, err := filepath.EvalSymlinks(target); err == nil {
+		target = resolved
+	}
 	dir := filepath.Dir(target)
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return fmt.Errorf("creating directory %s: %w", dir, err)
@@ -143,9 +165,21 @@ func atomicWriteFile(target string, data []byte, perm os.FileMode) error {
 		os.Remove(tmpName)
 		return fmt.Errorf("rename temp file to target: %w", err)
 	}
+	syncDir(dir)
 	return nil
 }
 
+// syncDir fsyncs a directory so a rename into it survives a crash. Best-effort:
+// some platforms and filesystems don't support syncing directories.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
 // writeSessionFile writes a session entry to ~/.crit/sessions/<key>.json.
 func writeSessionFile(key string, entry sessionEntry) error {
 	path, err := sessionFilePath(key)
@@ -159,12 +193,62 @@ func writeSessionFile(key string, entry sessionEntry) error {
 	return atomicWriteFile(path, data, 0600)
 }
 
-// readSessionFile reads a session entry from ~/.crit/sessions/<key>.json.
+// sessionRekeyer moves a running daemon's session file to a new key when its
+// file arguments change (a reviewed file was renamed), so `crit <new-name>`
+// finds the existing daemon instead of starting a fresh one.
+type sessionRekeyer struct {
+	mu             sync.Mutex
+	key            string
+	entry          sessionEntry
+	keepReviewPath bool // --output was given; the review file lives there, not under the key
+	lock           *reviewLock
+}
+
+// rekey rewrites the session file under the key for args and returns the
+// review file path for the new key ("" when keepReviewPath is set).
+func (r *sessionRekeyer) rekey(args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	newKey := sessionKey(r.entry.CWD, r.entry.Branch, args)
+	reviewPath := ""
+	if !r.keepReviewPath {
+		reviewPath, _ = reviewFilePath(newKey)
+		r.entry.ReviewPath = reviewPath
+		r.lock.move(reviewPath)
+	}
+	r.entry.Args = args
+	if newKey == r.key {
+		return reviewPath
+	}
+	if err := writeSessionFile(newKey, r.entry); err != nil {
+		slog.Warn("moving session file", "err", err)
+		return ""
+	}
+	if oldLog, err := sessionLogPath(r.key); err == nil {
+		if newLog, err := sessionLogPath(newKey); err == nil {
+			os.Rename(oldLog, newLog)
+		}
+	}
+	removeSessionFile(r.key)
+	r.key = newKey
+	return reviewPath
+}
+
+// currentKey returns the key the session file is currently stored under.
+func (r *sessionRekeyer) currentKey() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.key
+}
+
+// readSessionFile reads a session entry from <sessions dir>/<key>.json,
+// falling back to the legacy layout for daemons started before a switch.
 func readSessionFile(key string) (sessionEntry, error) {
 	path, err := sessionFilePath(key)
 	if err != nil {
 		return sessionEntry{}, err
 	}
+	path = existsOrLegacy(path, "sessions", key+".json")
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return sessionEntry{}, err
@@ -178,15 +262,12 @@ func readSessionFile(key string) (sessionEntry, error) {
 
 // removeSessionFile deletes a session file and its associated log and lock files.
 func removeSessionFile(key string) {
-	path, err := sessionFilePath(key)
-	if err != nil {
-		return
+	for _, dir := range sessionDirsForRead() {
+		os.Remove(filepath.Join(dir, key+".json"))
+		// Clean up associated log and lock files
+		os.Remove(filepath.Join(dir, key+".log"))
+		os.Remove(filepath.Join(dir, key+".lock"))
 	}
-	os.Remove(path)
-	// Clean up associated log and lock files
-	dir := filepath.Dir(path)
-	os.Remove(filepath.Join(dir, key+".log"))
-	os.Remove(filepath.Join(dir, key+".lock"))
 }
 
 // findAliveSession looks up a session by key and returns it if alive.
@@ -203,45 +284,101 @@ func findAliveSession(key string) (sessionEntry, bool) {
 	return entry, true
 }
 
-// listSessionsForCWD returns all alive sessions whose CWD matches.
-// Cleans up stale session files as a side effect.
-func listSessionsForCWD(cwd string) ([]sessionEntry, []string) {
-	dir, err := sessionsDir()
-	if err != nil {
-		return nil, nil
-	}
-	dirEntries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, nil
+// reviewedFiles returns the absolute paths (URLs as given) of the files a
+// files-mode review of args in cwd serves, or nil for git mode and compare
+// sessions, which files don't identify.
+func reviewedFiles(cwd string, args []string) []string {
+	if len(args) == 0 || args[0] == "compare" {
+		return nil
 	}
-	var alive []sessionEntry
-	var keys []string
-	for _, de := range dirEntries {
-		if !strings.HasSuffix(de.Name(), ".json") {
-			continue
+	files := make([]string, len(args))
+	for i, arg := range args {
+		if !isRemoteArg(arg) {
+			arg = filepath.Clean(arg)
+			if !filepath.IsAbs(arg) {
+				arg = filepath.Join(cwd, arg)
+			}
 		}
-		key := strings.TrimSuffix(de.Name(), ".json")
-		data, err := os.ReadFile(filepath.Join(dir, de.Name()))
-		if err != nil {
+		files[i] = arg
+	}
+	return files
+}
+
+// findSessionServingFiles returns an alive session whose review includes
+// every file in files. A directory argument only matches the same directory.
+func findSessionServingFiles(files []string) (sessionEntry, bool) {
+	if len(files) == 0 {
+		return sessionEntry{}, false
+	}
+	entries, _ := readAllSessionEntries()
+	for _, entry := range entries {
+		served := reviewedFiles(entry.CWD, entry.Args)
+		if len(served) == 0 || slices.ContainsFunc(files, func(f string) bool { return !slices.Contains(served, f) }) {
 			continue
 		}
-		var entry sessionEntry
-		if err := json.Unmarshal(data, &entry); err != nil {
-			continue
+		if isDaemonAlive(entry) {
+			return entry, true
 		}
+	}
+	return sessionEntry{}, false
+}
+
+// listSessionsForCWD returns all alive sessions whose CWD matches.
+// Cleans up stale session files as a side effect.
+func listSessionsForCWD(cwd string) ([]sessionEntry, []string) {
+	var alive []sessionEntry
+	var keys []string
+	entries, allKeys := readAllSessionEntries()
+	for i, entry := range entries {
 		if entry.CWD != cwd {
 			continue
 		}
 		if isDaemonAlive(entry) {
 			alive = append(alive, entry)
-			keys = append(keys, key)
+			keys = append(keys, allKeys[i])
 		} else {
-			removeSessionFile(key)
+			removeSessionFile(allKeys[i])
 		}
 	}
 	return alive, keys
 }
 
+// readAllSessionEntries parses every session file in the active and legacy
+// sessions directories, skipping unreadable ones. The first directory wins
+// when a key appears in both.
+func readAllSessionEntries() ([]sessionEntry, []string) {
+	var entries []sessionEntry
+	var keys []string
+	seen := make(map[string]bool)
+	for _, dir := range sessionDirsForRead() {
+		dirEntries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, de := range dirEntries {
+			if !strings.HasSuffix(de.Name(), ".json") {
+				continue
+			}
+			key := strings.TrimSuffix(de.Name(), ".json")
+			if seen[key] {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, de.Name()))
+			if err != nil {
+				continue
+			}
+			var entry sessionEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				continue
+			}
+			seen[key] = true
+			entries = append(entries, entry)
+			keys = append(keys, key)
+		}
+	}
+	return entries, keys
+}
+
 // findSessionForCWDBranch scans all alive sessions for the given cwd and branch.
 // Returns the session, its key, and the number of branch matches found.
 // The session and key are only valid when matchCount == 1.
@@ -264,36 +401,17 @@ func findSessionForCWDBranch(cwd, branch string) (entry sessionEntry, key string
 // subdirectory (e.g. repo/api) but `crit comment` is run from a different
 // subdirectory or the repo root itself.
 func listSessionsForRepoRoot(repoRoot string) ([]sessionEntry, []string) {
-	dir, err := sessionsDir()
-	if err != nil {
-		return nil, nil
-	}
-	dirEntries, err := os.ReadDir(dir)
-	if err != nil {
-		return nil, nil
-	}
 	prefix := repoRoot + string(filepath.Separator)
 	var alive []sessionEntry
 	var keys []string
-	for _, de := range dirEntries {
-		if !strings.HasSuffix(de.Name(), ".json") {
-			continue
-		}
-		key := strings.TrimSuffix(de.Name(), ".json")
-		data, err := os.ReadFile(filepath.Join(dir, de.Name()))
-		if err != nil {
-			continue
-		}
-		var entry sessionEntry
-		if err := json.Unmarshal(data, &entry); err != nil {
-			continue
-		}
+	entries, allKeys := readAllSessionEntries()
+	for i, entry := range entries {
 		if entry.CWD != repoRoot && !strings.HasPrefix(entry.CWD, prefix) {
 			continue
 		}
 		if isDaemonAlive(entry) {
 			alive = append(alive, entry)
-			keys = append(keys, key)
+			keys = append(keys, allKeys[i])
 		}
 	}
 	return alive, keys
@@ -316,7 +434,7 @@ func isDaemonAlive(s sessionEntry) bool {
 	}
 	// HTTP health probe — ensures the port belongs to our daemon, not a reused PID.
 	// We validate the response body to guard against a non-crit process on the same port.
-	resp, err := aliveClient.Get(fmt.Sprintf("http://localhost:%d/api/health", s.Port))
+	resp, err := aliveClient.Get(s.url("/api/health"))
 	if err != nil {
 		return false
 	}
@@ -337,7 +455,7 @@ func isDaemonAlive(s sessionEntry) bool {
 // Uses a pointer to distinguish "field missing" (older daemon) from "false".
 // When the field is missing, assumes a browser is connected (safe default).
 func daemonHasBrowser(s sessionEntry) bool {
-	resp, err := browserClient.Get(fmt.Sprintf("http://localhost:%d/api/health", s.Port))
+	resp, err := browserClient.Get(s.url("/api/health"))
 	if err != nil {
 		return true // can't reach daemon, assume browser exists
 	}
@@ -470,7 +588,7 @@ func handleDaemonReady(key string, port, pid int, readEnd *os.File, cmd *exec.Cm
 
 	entry, err := readSessionFile(key)
 	if err != nil {
-		log.Printf("Warning: failed to read session file for key %s: %v (using partial entry)", key, err)
+		slog.Warn("reading session file failed, using partial entry", "key", key, "err", err)
 		cwd, _ := resolvedCWD()
 		entry = sessionEntry{
 			PID:       pid,
@@ -674,32 +792,10 @@ func stopAllDaemonsForCWD(cwd string) {
 // cleanOrphanedSessions removes session files whose daemon PID is dead.
 // It silently ignores all errors — intended for best-effort background use.
 func cleanOrphanedSessions() {
-	sessDir, err := sessionsDir()
-	if err != nil {
-		return
-	}
-	entries, err := os.ReadDir(sessDir)
-	if err != nil {
-		return
-	}
-	for _, de := range entries {
-		if !strings.HasSuffix(de.Name(), ".json") {
-			continue
-		}
-		path := filepath.Join(sessDir, de.Name())
-		data, err := os.ReadFile(path)
-		if err != nil {
-			continue
-		}
-		var entry sessionEntry
-		if json.Unmarshal(data, &entry) != nil {
-			continue
-		}
+	entries, keys := readAllSessionEntries()
+	for i, entry := range entries {
 		if !isDaemonAlive(entry) {
-			os.Remove(path)
-			key := strings.TrimSuffix(de.Name(), ".json")
-			os.Remove(filepath.Join(sessDir, key+".log"))
-			os.Remove(filepath.Join(sessDir, key+".lock"))
+			removeSessionFile(keys[i])
 		}
 	}
 }
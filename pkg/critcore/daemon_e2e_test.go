@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"bytes"
@@ -22,7 +22,7 @@ func TestDaemonLifecycle(t *testing.T) {
 	// Build crit binary
 	dir := t.TempDir()
 	binary := filepath.Join(dir, "crit")
-	build := exec.Command("go", "build", "-o", binary, ".")
+	build := exec.Command("go", "build", "-o", binary, "../..")
 	if out, err := build.CombinedOutput(); err != nil {
 		t.Fatalf("build failed: %v\n%s", err, out)
 	}
@@ -100,7 +100,7 @@ func TestDaemonLifecycle(t *testing.T) {
 	}
 
 	// Verify health endpoint
-	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/api/health", entry.Port))
+	resp, err := http.Get(entry.url("/api/health"))
 	if err != nil {
 		t.Fatalf("health check failed: %v", err)
 	}
@@ -113,7 +113,7 @@ func TestDaemonLifecycle(t *testing.T) {
 	readyDeadline := time.Now().Add(10 * time.Second)
 	sessionReady := false
 	for time.Now().Before(readyDeadline) {
-		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/api/session", entry.Port))
+		resp, err := http.Get(entry.url("/api/session"))
 		if err == nil {
 			resp.Body.Close()
 			if resp.StatusCode == 200 {
@@ -132,7 +132,7 @@ func TestDaemonLifecycle(t *testing.T) {
 	go func() {
 		client := &http.Client{Timeout: 10 * time.Second}
 		r, err := client.Post(
-			fmt.Sprintf("http://localhost:%d/api/review-cycle", entry.Port),
+			entry.url("/api/review-cycle"),
 			"application/json", nil,
 		)
 		if err != nil {
@@ -147,7 +147,7 @@ func TestDaemonLifecycle(t *testing.T) {
 	// Simulate user finishing review
 	time.Sleep(200 * time.Millisecond)
 	finishResp, err := http.Post(
-		fmt.Sprintf("http://localhost:%d/api/finish", entry.Port),
+		entry.url("/api/finish"),
 		"application/json", nil,
 	)
 	if err != nil {
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"encoding/json"
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -609,3 +610,122 @@ func TestCleanOrphanedSessions(t *testing.T) {
 		t.Error("expected live PID session file to still exist")
 	}
 }
+
+func TestAtomicWriteFile_ConcurrentReadersNeverSeePartial(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "review.json")
+	big := strings.Repeat("x", 1<<16)
+	if err := atomicWriteFile(target, []byte(`{"v":"`+big+`"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	errCh := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-done:
+				errCh <- nil
+				return
+			default:
+			}
+			data, err := os.ReadFile(target)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			var v map[string]string
+			if err := json.Unmarshal(data, &v); err != nil {
+				errCh <- fmt.Errorf("reader saw partial file (%d bytes): %w", len(data), err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		body := fmt.Sprintf(`{"v":"%d%s"}`, i, big)
+		if err := atomicWriteFile(target, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the target file, got %d entries", len(entries))
+	}
+}
+
+func TestAtomicWriteFile_Permissions(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "nested", "cfg.json")
+	if err := atomicWriteFile(target, []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("perm = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestAtomicWriteFile_FollowsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	realPath := filepath.Join(dir, "real.json")
+	link := filepath.Join(dir, "link.json")
+	writeFile(t, realPath, "{}")
+	if err := os.Symlink(realPath, link); err != nil {
+		t.Skip("symlinks unsupported")
+	}
+	if err := atomicWriteFile(link, []byte(`{"a":1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Lstat(link); fi.Mode()&os.ModeSymlink == 0 {
+		t.Error("symlink was replaced by a regular file")
+	}
+	if data, _ := os.ReadFile(realPath); string(data) != `{"a":1}` {
+		t.Errorf("real file = %q", data)
+	}
+}
+
+func TestReviewedFiles(t *testing.T) {
+	got := reviewedFiles("/repo/docs", []string{"plan.md", "./sub/../api.md", "/abs/x.md", "https://example.com/spec.md"})
+	want := []string{"/repo/docs/plan.md", "/repo/docs/api.md", "/abs/x.md", "https://example.com/spec.md"}
+	if !slices.Equal(got, want) {
+		t.Errorf("reviewedFiles = %v, want %v", got, want)
+	}
+	if reviewedFiles("/repo", nil) != nil || reviewedFiles("/repo", []string{"compare", "a.md", "b.md"}) != nil {
+		t.Error("git mode and compare sessions shouldn't be identified by files")
+	}
+}
+
+func TestFindSessionServingFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	}))
+	defer ts.Close()
+	port, _ := strconv.Atoi(ts.URL[strings.LastIndex(ts.URL, ":")+1:])
+	writeSessionFile("served123456", sessionEntry{
+		PID:  os.Getpid(),
+		Port: port,
+		CWD:  "/repo",
+		Args: []string{"docs/plan.md", "docs/notes.md"},
+	})
+
+	// The same file from the docs directory, under a different session key.
+	entry, ok := findSessionServingFiles(reviewedFiles("/repo/docs", []string{"plan.md"}))
+	if !ok || entry.Port != port {
+		t.Errorf("expected to attach to the session on port %d, got %+v, %v", port, entry, ok)
+	}
+	if _, ok := findSessionServingFiles(reviewedFiles("/repo/docs", []string{"plan.md", "other.md"})); ok {
+		t.Error("a session missing one of the files shouldn't match")
+	}
+	if _, ok := findSessionServingFiles(nil); ok {
+		t.Error("git mode shouldn't attach by files")
+	}
+}
@@ -0,0 +1,327 @@
+package critcore
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Comments on JSON and YAML files also record the path of the value they
+// start on (data_path, e.g. $.server.tls[0].cert). When the file changes, the
+// path is looked up before falling back to line mapping, so a comment follows
+// its key through reformatting and reordering.
+
+// dataNode is one value in a JSON/YAML document and the lines it spans.
+type dataNode struct {
+	path       string
+	start, end int // 1-based, inclusive
+}
+
+func isDataFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// dataNodes indexes content by path. Unparseable content yields nil.
+func dataNodes(path, content string) []dataNode {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return jsonNodes(content)
+	}
+	return yamlNodes(content)
+}
+
+// dataPathAt returns the path of the innermost value that starts on line,
+// or of the innermost value containing it.
+func dataPathAt(nodes []dataNode, line int) string {
+	best := -1
+	for i, n := range nodes {
+		if n.start > line || n.end < line {
+			continue
+		}
+		if best < 0 || betterDataNode(n, nodes[best], line) {
+			best = i
+		}
+	}
+	if best < 0 || nodes[best].path == "$" {
+		return ""
+	}
+	return nodes[best].path
+}
+
+func betterDataNode(n, cur dataNode, line int) bool {
+	if (n.start == line) != (cur.start == line) {
+		return n.start == line
+	}
+	return n.end-n.start < cur.end-cur.start || (n.end-n.start == cur.end-cur.start && len(n.path) > len(cur.path))
+}
+
+// findDataPath returns the lines spanned by path.
+func findDataPath(nodes []dataNode, path string) (start, end int, ok bool) {
+	for _, n := range nodes {
+		if n.path == path {
+			return n.start, n.end, true
+		}
+	}
+	return 0, 0, false
+}
+
+// relocateByDataPath returns the new start line for a comment with a
+// data_path that still exists in the reindexed file.
+func relocateByDataPath(nodes []dataNode, c Comment, maxLine int) (int, bool) {
+	if c.DataPath == "" || nodes == nil {
+		return 0, false
+	}
+	start, _, ok := findDataPath(nodes, c.DataPath)
+	if !ok || start > maxLine {
+		return 0, false
+	}
+	return start, true
+}
+
+var plainDataKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// dataPathKey appends a key segment: .key, or ['key'] when it needs quoting.
+func dataPathKey(parent, key string) string {
+	if plainDataKey.MatchString(key) {
+		return parent + "." + key
+	}
+	return parent + "['" + strings.ReplaceAll(strings.ReplaceAll(key, `\`, `\\`), "'", `\'`) + "']"
+}
+
+func dataPathIndex(parent string, i int) string {
+	return parent + "[" + strconv.Itoa(i) + "]"
+}
+
+// lineIndex maps byte offsets to 1-based line numbers.
+type lineIndex []int
+
+func newLineIndex(content string) lineIndex {
+	starts := lineIndex{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+func (l lineIndex) line(offset int) int {
+	return sort.Search(len(l), func(i int) bool { return l[i] > offset })
+}
+
+type jsonIndexer struct {
+	dec     *json.Decoder
+	content string
+	lines   lineIndex
+	nodes   []dataNode
+}
+
+func jsonNodes(content string) []dataNode {
+	j := &jsonIndexer{dec: json.NewDecoder(strings.NewReader(content)), content: content, lines: newLineIndex(content)}
+	if err := j.value("$"); err != nil {
+		return nil
+	}
+	if _, err := j.dec.Token(); err != io.EOF {
+		return nil // trailing data: not a single JSON document
+	}
+	return j.nodes
+}
+
+// nextLine is the line of the next token: the decoder's offset sits after the
+// previous token, before any whitespace or separator.
+func (j *jsonIndexer) nextLine() int {
+	off := int(j.dec.InputOffset())
+	for off < len(j.content) && strings.IndexByte(" \t\r\n,:", j.content[off]) >= 0 {
+		off++
+	}
+	return j.lines.line(off)
+}
+
+func (j *jsonIndexer) endLine() int {
+	return j.lines.line(max(int(j.dec.InputOffset())-1, 0))
+}
+
+func (j *jsonIndexer) value(path string) error {
+	start := j.nextLine()
+	tok, err := j.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		for j.dec.More() {
+			keyTok, err := j.dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyTok.(string)
+			if err := j.value(dataPathKey(path, key)); err != nil {
+				return err
+			}
+		}
+		if _, err := j.dec.Token(); err != nil {
+			return err
+		}
+	case json.Delim('['):
+		for i := 0; j.dec.More(); i++ {
+			if err := j.value(dataPathIndex(path, i)); err != nil {
+				return err
+			}
+		}
+		if _, err := j.dec.Token(); err != nil {
+			return err
+		}
+	}
+	j.nodes = append(j.nodes, dataNode{path: path, start: start, end: j.endLine()})
+	return nil
+}
+
+// yamlFrame is an open YAML node while scanning: a mapping key or sequence
+// item whose children are indented deeper than indent.
+type yamlFrame struct {
+	indent  int
+	node    int // index into nodes; -1 for the document root
+	path    string
+	nextIdx int  // next sequence index for "- " children
+	open    bool // key with no inline value: "- " items at its own indent belong to it
+}
+
+// yamlNodes indexes block-style YAML by indentation: mapping keys and "- "
+// sequence items. Flow collections and multi-line scalars are treated as a
+// single value; anchors, tags and multiple documents are not interpreted.
+func yamlNodes(content string) []dataNode {
+	y := &yamlIndexer{stack: []yamlFrame{{indent: -1, node: -1, path: "$", open: true}}, blockIndent: -1}
+	for i, raw := range strings.Split(content, "\n") {
+		lineNo := i + 1
+		text := strings.TrimRight(raw, " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		indent := len(text) - len(trimmed)
+		if y.skip(lineNo, trimmed, indent) {
+			continue
+		}
+		y.addLine(lineNo, trimmed, indent)
+		y.lastContent = lineNo
+	}
+	y.closeTo(func(yamlFrame) bool { return false })
+	if len(y.nodes) == 0 {
+		return nil
+	}
+	return append(y.nodes, dataNode{path: "$", start: 1, end: max(y.lastContent, 1)})
+}
+
+// yamlIndexer is the state of yamlNodes between lines.
+type yamlIndexer struct {
+	nodes       []dataNode
+	stack       []yamlFrame
+	lastContent int
+	blockIndent int // inside a | or > block scalar owned by a key at this indent; -1 outside
+}
+
+// closeTo pops frames until keep accepts the top one, ending their nodes at
+// the last content line.
+func (y *yamlIndexer) closeTo(keep func(yamlFrame) bool) {
+	for len(y.stack) > 1 && !keep(y.stack[len(y.stack)-1]) {
+		top := y.stack[len(y.stack)-1]
+		y.nodes[top.node].end = max(y.lastContent, y.nodes[top.node].start)
+		y.stack = y.stack[:len(y.stack)-1]
+	}
+}
+
+// skip reports whether a line adds no node: blanks, comments, document
+// markers and the body of a block scalar (which still extends its key).
+func (y *yamlIndexer) skip(lineNo int, trimmed string, indent int) bool {
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return true
+	}
+	if y.blockIndent >= 0 {
+		if indent > y.blockIndent {
+			y.lastContent = lineNo
+			return true
+		}
+		y.blockIndent = -1
+	}
+	return indent == 0 && (trimmed == "---" || trimmed == "...")
+}
+
+// addLine indexes the keys and "- " items on one line; "- key: v" opens an
+// item and a key inside it.
+func (y *yamlIndexer) addLine(lineNo int, trimmed string, indent int) {
+	for {
+		isItem := trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+		y.closeTo(func(f yamlFrame) bool {
+			return f.indent < indent || (isItem && f.indent == indent && f.open)
+		})
+		parent := &y.stack[len(y.stack)-1]
+		if !isItem {
+			y.addKey(lineNo, trimmed, indent, parent.path)
+			return
+		}
+		path := dataPathIndex(parent.path, parent.nextIdx)
+		parent.nextIdx++
+		y.nodes = append(y.nodes, dataNode{path: path, start: lineNo, end: lineNo})
+		y.stack = append(y.stack, yamlFrame{indent: indent, node: len(y.nodes) - 1, path: path})
+		rest := strings.TrimLeft(strings.TrimPrefix(trimmed, "-"), " ")
+		if rest == "" {
+			return
+		}
+		indent += len(trimmed) - len(rest)
+		trimmed = rest
+	}
+}
+
+func (y *yamlIndexer) addKey(lineNo int, trimmed string, indent int, parentPath string) {
+	key, value, ok := yamlKeyValue(trimmed)
+	if !ok {
+		return // continuation of a multi-line scalar
+	}
+	path := dataPathKey(parentPath, key)
+	y.nodes = append(y.nodes, dataNode{path: path, start: lineNo, end: lineNo})
+	y.stack = append(y.stack, yamlFrame{indent: indent, node: len(y.nodes) - 1, path: path, open: value == ""})
+	if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+		y.blockIndent = indent
+	}
+}
+
+// yamlKeyValue splits "key: value" (or "key:"), honouring quoted keys.
+func yamlKeyValue(s string) (key, value string, ok bool) {
+	rest := s
+	if s[0] == '"' || s[0] == '\'' {
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 {
+			return "", "", false
+		}
+		key, rest = s[1:end+1], s[end+2:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(stripYAMLComment(rest[1:])), true
+	}
+	if strings.ContainsAny(s[:1], "{[") {
+		return "", "", false
+	}
+	idx := strings.Index(rest, ": ")
+	if idx < 0 {
+		if !strings.HasSuffix(rest, ":") {
+			return "", "", false
+		}
+		idx = len(rest) - 1
+	}
+	return strings.TrimSpace(rest[:idx]), strings.TrimSpace(stripYAMLComment(rest[idx+1:])), true
+}
+
+func stripYAMLComment(s string) string {
+	if i := strings.Index(s, " #"); i >= 0 {
+		return s[:i]
+	}
+	if strings.HasPrefix(strings.TrimSpace(s), "#") {
+		return ""
+	}
+	return s
+}
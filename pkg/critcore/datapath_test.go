@@ -0,0 +1,116 @@
+package critcore
+
+import (
+	"testing"
+)
+
+func TestJSONNodes(t *testing.T) {
+	src := `{
+  "server": {
+    "port": 8080,
+    "tls": [
+      {"cert": "a.pem"},
+      {
+        "cert": "b.pem"
+      }
+    ]
+  },
+  "name.with.dots": true
+}
+`
+	nodes := jsonNodes(src)
+	cases := []struct {
+		path       string
+		start, end int
+	}{
+		{"$.server", 2, 10},
+		{"$.server.port", 3, 3},
+		{"$.server.tls", 4, 9},
+		{"$.server.tls[0].cert", 5, 5},
+		{"$.server.tls[1]", 6, 8},
+		{"$['name.with.dots']", 11, 11},
+	}
+	for _, tc := range cases {
+		start, end, ok := findDataPath(nodes, tc.path)
+		if !ok || start != tc.start || end != tc.end {
+			t.Errorf("%s: got %d-%d (%v), want %d-%d", tc.path, start, end, ok, tc.start, tc.end)
+		}
+	}
+	if got := dataPathAt(nodes, 7); got != "$.server.tls[1].cert" {
+		t.Errorf("dataPathAt(7) = %q", got)
+	}
+	if jsonNodes("{") != nil || jsonNodes("{} {}") != nil {
+		t.Error("invalid JSON should not be indexed")
+	}
+}
+
+func TestYAMLNodes(t *testing.T) {
+	src := `# deploy
+services:
+  - name: api
+    image: api:1.2   # pinned
+    ports:
+      - 80
+      - 443
+  - name: worker
+script: |
+  echo key: not a key
+"quoted key": 1
+env:
+- A
+- B
+`
+	nodes := yamlNodes(src)
+	cases := []struct {
+		path       string
+		start, end int
+	}{
+		{"$.services", 2, 8},
+		{"$.services[0]", 3, 7},
+		{"$.services[0].image", 4, 4},
+		{"$.services[0].ports[1]", 7, 7},
+		{"$.services[1].name", 8, 8},
+		{"$.script", 9, 10},
+		{"$['quoted key']", 11, 11},
+		{"$.env[1]", 14, 14},
+	}
+	for _, tc := range cases {
+		start, end, ok := findDataPath(nodes, tc.path)
+		if !ok || start != tc.start || end != tc.end {
+			t.Errorf("%s: got %d-%d (%v), want %d-%d", tc.path, start, end, ok, tc.start, tc.end)
+		}
+	}
+	if _, _, ok := findDataPath(nodes, "$.script.echo key"); ok {
+		t.Error("block scalar content should not be indexed")
+	}
+	if got := dataPathAt(nodes, 3); got != "$.services[0].name" {
+		t.Errorf("dataPathAt(3) = %q", got)
+	}
+}
+
+func TestCarryForward_DataPath(t *testing.T) {
+	_, session := newTestServer(t)
+	f := session.Files[0]
+	f.Path = "config.json"
+	f.Content = "{\n  \"a\": 1,\n  \"b\": {\n    \"c\": 2\n  }\n}\n"
+	c, _ := session.AddComment("config.json", 4, 4, "", "why 2?", "", "")
+	if c.DataPath != "$.b.c" {
+		t.Fatalf("DataPath = %q", c.DataPath)
+	}
+
+	// Reformat and reorder: b moves up and becomes single-line.
+	session.mu.Lock()
+	f.PreviousContent = f.Content
+	f.PreviousComments = append([]Comment(nil), f.Comments...)
+	f.Content = "{\n  \"b\": {\"c\": 2},\n  \"z\": 0,\n  \"a\": 1\n}\n"
+	session.mu.Unlock()
+	session.carryForwardComments()
+
+	got := session.GetComments("config.json")
+	if len(got) != 1 || got[0].StartLine != 2 || got[0].Drifted || got[0].DataPath != "$.b.c" {
+		t.Errorf("carried = %+v", got)
+	}
+	if got[0].Anchor != c.Anchor {
+		t.Errorf("Anchor = %q, want the original %q", got[0].Anchor, c.Anchor)
+	}
+}
@@ -0,0 +1,99 @@
+package critcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A disposition is the agent's structured answer to a comment: whether it
+// fixed it, needs the reviewer to clarify, or disagrees, and why. Agents set
+// it with PATCH /api/comment/{id}?path=X (or /api/review-comment/{id}), and
+// the reviewer sees it on the comment next to the replies.
+
+// dispositionStatuses are the values a disposition's status may take.
+var dispositionStatuses = []string{"fixed", "needs-clarification", "disagree"}
+
+// disposition is what the agent did about a comment.
+type disposition struct {
+	Status      string `json:"status"` // one of dispositionStatuses
+	Explanation string `json:"explanation,omitempty"`
+	Author      string `json:"author,omitempty"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// checkDisposition accepts "" (which clears a disposition) and the
+// dispositionStatuses.
+func checkDisposition(status string) error {
+	if status == "" || slices.Contains(dispositionStatuses, status) {
+		return nil
+	}
+	return fmt.Errorf("unknown disposition %q (want one of %s)", status, strings.Join(dispositionStatuses, ", "))
+}
+
+// SetCommentDisposition sets the disposition of a file comment, or of a
+// review comment when filePath is "". A nil d clears it.
+func (s *Session) SetCommentDisposition(filePath, id string, d *disposition) (Comment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comments := s.reviewComments
+	if filePath != "" {
+		f := s.fileByPathLocked(filePath)
+		if f == nil {
+			return Comment{}, false
+		}
+		comments = f.Comments
+	}
+	for i, c := range comments {
+		if c.ID == id {
+			comments[i].Disposition = d
+			comments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			s.walPutLocked(filePath, comments[i])
+			s.scheduleWrite()
+			return comments[i], true
+		}
+	}
+	return Comment{}, false
+}
+
+// handleCommentDisposition handles PATCH on a comment (on path, or a review
+// comment when path is ""): {"disposition": "fixed", "explanation": "...",
+// "author": "..."}. An empty disposition clears it.
+func (s *Server) handleCommentDisposition(w http.ResponseWriter, r *http.Request, path, id string) {
+	var req struct {
+		Disposition string `json:"disposition"`
+		Explanation string `json:"explanation"`
+		Author      string `json:"author"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := checkDisposition(req.Disposition); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var d *disposition
+	if req.Disposition != "" {
+		d = &disposition{
+			Status:      req.Disposition,
+			Explanation: strings.TrimSpace(req.Explanation),
+			Author:      req.Author,
+			UpdatedAt:   time.Now().UTC().Format(time.RFC3339),
+		}
+	}
+	sess := s.session.Load()
+	c, ok := sess.SetCommentDisposition(path, id, d)
+	if !ok {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	// The agent sets dispositions, so the reviewer's browser hasn't seen it.
+	sess.notify(SSEEvent{Type: "comments-changed"})
+	s.emitEvent(sess, hookEvent{Event: hookCommentUpdated, Path: path, Comment: &c})
+	writeJSON(w, c)
+}
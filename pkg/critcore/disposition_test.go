@@ -0,0 +1,63 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCommentDisposition(t *testing.T) {
+	s, session := newTestServer(t)
+	c, _ := session.AddComment("test.md", 1, 1, "", "Split this step", "", "ana")
+	ch := session.Subscribe()
+	defer session.Unsubscribe(ch)
+
+	patch := func(url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, url, strings.NewReader(body)))
+		return w
+	}
+	w := patch("/api/comment/"+c.ID+"?path=test.md", `{"disposition":"disagree","explanation":" Both halves share state. ","author":"claude"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var got Comment
+	json.NewDecoder(w.Body).Decode(&got)
+	if d := got.Disposition; d == nil || d.Status != "disagree" || d.Explanation != "Both halves share state." || d.Author != "claude" || d.UpdatedAt == "" {
+		t.Errorf("disposition = %+v", d)
+	}
+	if ev := <-ch; ev.Type != "comments-changed" {
+		t.Errorf("browser not told: %+v", ev)
+	}
+	if stored, _, _ := session.FindCommentByID(c.ID, "test.md"); stored.Disposition == nil {
+		t.Error("disposition not stored")
+	}
+
+	if w := patch("/api/comment/"+c.ID+"?path=test.md", `{"disposition":"wontfix"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown disposition: got %d", w.Code)
+	}
+	if w := patch("/api/comment/missing?path=test.md", `{"disposition":"fixed"}`); w.Code != http.StatusNotFound {
+		t.Errorf("missing comment: got %d", w.Code)
+	}
+	patch("/api/comment/"+c.ID+"?path=test.md", `{"disposition":""}`)
+	if stored, _, _ := session.FindCommentByID(c.ID, "test.md"); stored.Disposition != nil {
+		t.Errorf("empty disposition should clear it: %+v", stored.Disposition)
+	}
+
+	rc := session.AddReviewComment("Add a rollback plan", "ana")
+	if w := patch("/api/review-comment/"+rc.ID, `{"disposition":"needs-clarification","explanation":"Rollback of what?"}`); w.Code != http.StatusOK {
+		t.Fatalf("review comment: got %d: %s", w.Code, w.Body.String())
+	}
+	if d := session.GetReviewComments()[0].Disposition; d == nil || d.Status != "needs-clarification" {
+		t.Errorf("review comment disposition = %+v", d)
+	}
+}
+
+func TestCarryForwardComment_KeepsDisposition(t *testing.T) {
+	old := Comment{ID: "c1", Body: "Fix", Disposition: &disposition{Status: "fixed", Explanation: "Done"}}
+	if c := carryForwardComment(old, "c2", "2026-01-01T00:00:00Z"); c.Disposition == nil || c.Disposition.Status != "fixed" {
+		t.Errorf("disposition lost in carry-forward: %+v", c.Disposition)
+	}
+}
@@ -0,0 +1,24 @@
+// Package critcore is crit's review loop as a library: the Session that holds
+// the reviewed files and their comments, the Server that serves the review UI
+// and HTTP API for one, and the review file and exports they write. The crit
+// binary is a thin main package around Main.
+//
+// The stable API, kept compatible across minor releases, is:
+//
+//   - Main and Build, to run the crit command line.
+//   - NewSessionFromFiles, NewSessionFromGit and NewSessionFromVCS; the
+//     Session methods that add, reply to, resolve, delete and list comments
+//     (AddComment, AddFileComment, AddReviewComment, AddReply,
+//     SetCommentResolved, DeleteComment, GetComments, GetAllComments,
+//     GetReviewComments), and WriteFiles, ReviewPath, Watch,
+//     SignalRoundComplete, Subscribe, Unsubscribe and Shutdown.
+//   - NewServer, Server.ServeHTTP and Server.SetSession. NewServer takes the
+//     review UI as an fs.FS holding frontend/, the way the crit binary embeds
+//     it.
+//   - Comment, Reply, FileEntry, CritJSON and CritJSONFile, the review file's
+//     types (versioned by SchemaVersion), with LoadReview and
+//     WriteReviewExports.
+//
+// Other exported identifiers are exported for the package's own CLI and may
+// change between releases.
+package critcore
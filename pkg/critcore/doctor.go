@@ -0,0 +1,328 @@
+package critcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// crit doctor runs the environment checks teammates otherwise debug by hand:
+// config files, agent integrations, the browser opener, the VCS and leftover
+// daemon session files. It only reads, unless --fix is given, which removes
+// orphaned session files and unheld lock files.
+
+type doctorStatus int
+
+const (
+	doctorOK doctorStatus = iota
+	doctorWarn
+	doctorFail
+)
+
+type doctorResult struct {
+	status doctorStatus
+	check  string
+	detail string
+	hint   string // what to do about a warning or failure
+}
+
+// doctorEnv is what the checks inspect, so tests can point them elsewhere.
+type doctorEnv struct {
+	projectDir string
+	homeDir    string
+	goos       string
+	wsl        bool
+	hasCommand func(string) bool
+}
+
+// agentMarkers are signs that an agent is used here: a command on PATH, or a
+// config directory in the repo or home directory.
+var agentMarkers = map[string]struct{ commands, dirs []string }{
+	"claude-code":    {[]string{"claude"}, []string{".claude"}},
+	"codex":          {[]string{"codex"}, []string{".codex"}},
+	"cursor":         {[]string{"cursor", "cursor-agent"}, []string{".cursor"}},
+	"opencode":       {[]string{"opencode"}, []string{".opencode", ".config/opencode"}},
+	"windsurf":       {[]string{"windsurf"}, []string{".windsurf", ".codeium/windsurf"}},
+	"github-copilot": {[]string{"copilot"}, []string{".copilot"}},
+	"cline":          {[]string{"cline"}, []string{".clinerules", ".cline"}},
+}
+
+// detectAgents returns the agents with a marker in env, in
+// availableIntegrations order.
+func detectAgents(env doctorEnv) []string {
+	var found []string
+	for _, agent := range availableIntegrations() {
+		m := agentMarkers[agent]
+		detected := slices.ContainsFunc(m.commands, env.hasCommand)
+		for _, dir := range m.dirs {
+			for _, base := range []string{env.projectDir, env.homeDir} {
+				if info, err := os.Stat(filepath.Join(base, dir)); err == nil && info.IsDir() {
+					detected = true
+				}
+			}
+		}
+		if detected {
+			found = append(found, agent)
+		}
+	}
+	return found
+}
+
+// knownConfigKeys returns the JSON names of Config's fields.
+func knownConfigKeys() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeFor[Config]()
+	for i := range t.NumField() {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
+
+// unknownConfigKeys returns the top-level keys of a config file that crit
+// doesn't read, usually typos.
+func unknownConfigKeys(data []byte) []string {
+	var raw map[string]json.RawMessage
+	if json.Unmarshal(data, &raw) != nil {
+		return nil
+	}
+	known := knownConfigKeys()
+	var unknown []string
+	for k := range raw {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
+}
+
+// doctorConfigFile checks that the config file at path parses and only uses
+// known keys. Missing files are fine and yield no result.
+func doctorConfigFile(path string) (doctorResult, bool) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return doctorResult{}, false
+	}
+	if err == nil {
+		_, _, err = loadConfigFile(path)
+	}
+	if err != nil {
+		return doctorResult{doctorFail, "config", err.Error(), "Fix the JSON in " + path}, true
+	}
+	if unknown := unknownConfigKeys(data); len(unknown) > 0 {
+		return doctorResult{doctorWarn, "config", fmt.Sprintf("%s: unknown keys %s", path, strings.Join(unknown, ", ")),
+			"Check for typos; run 'crit config --help' for the available keys"}, true
+	}
+	return doctorResult{doctorOK, "config", path, ""}, true
+}
+
+func doctorConfig(env doctorEnv) []doctorResult {
+	var results []doctorResult
+	paths := []string{globalConfigPath()}
+	if project := findProjectConfig(env.projectDir); project != paths[0] {
+		paths = append(paths, project)
+	}
+	for _, path := range paths {
+		if r, ok := doctorConfigFile(path); ok {
+			results = append(results, r)
+		}
+	}
+	if len(results) == 0 {
+		return []doctorResult{{doctorOK, "config", "no config files, using defaults", ""}}
+	}
+	if slices.ContainsFunc(results, func(r doctorResult) bool { return r.status == doctorFail }) {
+		return results
+	}
+	// The values are checked the way a review would start.
+	if _, err := resolveServerConfig(nil); err != nil {
+		results = append(results, doctorResult{doctorFail, "config", err.Error(), "Fix the value in your config file"})
+	}
+	return results
+}
+
+func doctorIntegrations(env doctorEnv) []doctorResult {
+	installed := detectInstalledIntegrations(env.projectDir, env.homeDir)
+	var results []doctorResult
+	seen := map[string]bool{}
+	for _, st := range installed {
+		seen[st.Agent] = true
+		if st.Status == "stale" {
+			results = append(results, doctorResult{doctorWarn, "integrations",
+				fmt.Sprintf("%s is outdated (%s)", st.Agent, st.Location), strings.ReplaceAll(st.Hint, "|", ": ")})
+			continue
+		}
+		results = append(results, doctorResult{doctorOK, "integrations", fmt.Sprintf("%s is up to date (%s)", st.Agent, st.Location), ""})
+	}
+	for _, agent := range detectAgents(env) {
+		if !seen[agent] {
+			results = append(results, doctorResult{doctorWarn, "integrations",
+				agent + " is in use here but has no crit integration", "Run: crit install " + agent})
+		}
+	}
+	if len(results) == 0 {
+		return []doctorResult{{doctorWarn, "integrations", "no agents or integrations found",
+			"Run: crit install <agent> (one of " + strings.Join(availableIntegrations(), ", ") + ")"}}
+	}
+	return results
+}
+
+// doctorBrowser finds the command openBrowser would use. It doesn't open
+// anything, so it can only tell whether a launcher is installed.
+func doctorBrowser(env doctorEnv, configured []browserCommandSpec) doctorResult {
+	if len(configured) > 0 && !env.hasCommand(configured[0].name) {
+		return doctorResult{doctorWarn, "browser", fmt.Sprintf("browser command %q not found", configured[0].name),
+			"Fix \"browser\" in ~/.crit.config.json or install it"}
+	}
+	specs := append(configured, browserCommandSpecs(env.goos, "", env.wsl, env.hasCommand)...)
+	for _, spec := range specs {
+		if env.hasCommand(spec.name) {
+			return doctorResult{doctorOK, "browser", "opens reviews with " + spec.name, ""}
+		}
+	}
+	hint := "Open the printed URL yourself, or set \"browser\" in ~/.crit.config.json"
+	if env.goos == "linux" {
+		hint = "Install xdg-utils (xdg-open), or set \"browser\" in ~/.crit.config.json"
+		if env.wsl {
+			hint = "Install wslu (wslview), or enable Windows interop so powershell.exe is reachable"
+		}
+	}
+	return doctorResult{doctorWarn, "browser", "no way to open a browser automatically", hint}
+}
+
+func doctorVCS() doctorResult {
+	vcs := DetectVCS("")
+	if vcs == nil {
+		return doctorResult{doctorWarn, "vcs", "not in a git or Sapling repository",
+			"Plain `crit` needs a repository to find changed files; `crit <file>` still works"}
+	}
+	return doctorResult{doctorOK, "vcs", vcs.Name(), ""}
+}
+
+// staleLockFiles returns the session lock files in dirs that no process
+// holds. They are left behind when a daemon start is interrupted.
+func staleLockFiles(dirs []string) []string {
+	var stale []string
+	for _, dir := range dirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.lock"))
+		for _, path := range matches {
+			f, err := os.OpenFile(path, os.O_WRONLY, 0)
+			if err != nil {
+				continue
+			}
+			if syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB) == nil {
+				stale = append(stale, path)
+				syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+			}
+			f.Close()
+		}
+	}
+	return stale
+}
+
+func doctorSessions(fix bool) []doctorResult {
+	entries, keys := readAllSessionEntries()
+	var orphaned []string
+	alive := 0
+	for i, entry := range entries {
+		if isDaemonAlive(entry) {
+			alive++
+		} else {
+			orphaned = append(orphaned, keys[i])
+		}
+	}
+	dirs := sessionDirsForRead()
+	if dir, err := reviewLockDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	locks := staleLockFiles(dirs)
+	results := []doctorResult{{doctorOK, "sessions", fmt.Sprintf("%d running daemon(s)", alive), ""}}
+	if len(orphaned) == 0 && len(locks) == 0 {
+		return results
+	}
+	if fix {
+		for _, key := range orphaned {
+			removeSessionFile(key)
+		}
+		for _, path := range locks {
+			os.Remove(path)
+		}
+		return append(results, doctorResult{doctorOK, "sessions",
+			fmt.Sprintf("removed %d orphaned session file(s) and %d stale lock file(s)", len(orphaned), len(locks)), ""})
+	}
+	return append(results, doctorResult{doctorWarn, "sessions",
+		fmt.Sprintf("%d orphaned session file(s) and %d stale lock file(s)", len(orphaned), len(locks)), "Run: crit doctor --fix"})
+}
+
+// printDoctorResults writes one line per result, with hints under problems,
+// and returns how many warnings and failures there were.
+func printDoctorResults(w io.Writer, results []doctorResult) (warnings, failures int) {
+	labels := map[doctorStatus]string{doctorOK: "ok", doctorWarn: "warn", doctorFail: "FAIL"}
+	for _, r := range results {
+		fmt.Fprintf(w, "  %-4s  %-12s  %s\n", labels[r.status], r.check, r.detail)
+		if r.hint != "" {
+			for _, line := range strings.Split(r.hint, "\n") {
+				fmt.Fprintf(w, "%22s→ %s\n", "", line)
+			}
+		}
+		switch r.status {
+		case doctorWarn:
+			warnings++
+		case doctorFail:
+			failures++
+		}
+	}
+	return warnings, failures
+}
+
+func runDoctor(args []string) {
+	fix := false
+	for _, arg := range args {
+		switch arg {
+		case "--fix":
+			fix = true
+		default:
+			fmt.Fprintln(os.Stderr, "Usage: crit doctor [--fix]")
+			fmt.Fprintln(os.Stderr, "")
+			fmt.Fprintln(os.Stderr, "Checks config files, agent integrations, the browser opener, the VCS and")
+			fmt.Fprintln(os.Stderr, "leftover daemon files. --fix removes orphaned session and lock files.")
+			os.Exit(1)
+		}
+	}
+	env := doctorEnv{goos: runtime.GOOS, wsl: systemIsWSL(), hasCommand: commandExists}
+	env.projectDir, _ = os.Getwd()
+	if vcs := DetectVCS(""); vcs != nil {
+		if root, err := vcs.RepoRoot(); err == nil {
+			env.projectDir = root
+		}
+	}
+	env.homeDir, _ = os.UserHomeDir()
+
+	fmt.Printf("crit %s — checking your environment...\n\n", version)
+	var results []doctorResult
+	results = append(results, doctorConfig(env)...)
+	results = append(results, doctorIntegrations(env)...)
+	results = append(results, doctorBrowser(env, configuredBrowserSpecs("")))
+	results = append(results, doctorVCS())
+	results = append(results, doctorSessions(fix)...)
+	warnings, failures := printDoctorResults(os.Stdout, results)
+
+	fmt.Println()
+	switch {
+	case failures > 0:
+		fmt.Printf("%d problem(s) and %d warning(s) found.\n", failures, warnings)
+		os.Exit(1)
+	case warnings > 0:
+		fmt.Printf("No problems, %d warning(s).\n", warnings)
+	default:
+		fmt.Println("Everything looks good.")
+	}
+}
@@ -0,0 +1,118 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestAgentMarkers_CoverIntegrations(t *testing.T) {
+	for _, agent := range availableIntegrations() {
+		if _, ok := agentMarkers[agent]; !ok {
+			t.Errorf("agentMarkers has no entry for %s", agent)
+		}
+	}
+}
+
+func TestDetectAgents(t *testing.T) {
+	project, home := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(project, ".cursor"), 0755)
+	os.MkdirAll(filepath.Join(home, ".config", "opencode"), 0755)
+	env := doctorEnv{projectDir: project, homeDir: home, hasCommand: func(name string) bool { return name == "codex" }}
+
+	want := []string{"codex", "cursor", "opencode"}
+	if got := detectAgents(env); !reflect.DeepEqual(got, want) {
+		t.Errorf("detectAgents() = %v, want %v", got, want)
+	}
+}
+
+func TestUnknownConfigKeys(t *testing.T) {
+	got := unknownConfigKeys([]byte(`{"port": 3000, "no_opne": true, "review_style": "compact", "agentcmd": "x"}`))
+	if want := []string{"agentcmd", "no_opne"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unknownConfigKeys() = %v, want %v", got, want)
+	}
+}
+
+func TestDoctorConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	if _, ok := doctorConfigFile(filepath.Join(dir, "missing.json")); ok {
+		t.Error("a missing config file should not be reported")
+	}
+	for body, want := range map[string]doctorStatus{
+		`{"port": 3000}`: doctorOK,
+		`{"prot": 3000}`: doctorWarn,
+		`{"port": `:      doctorFail,
+	} {
+		path := filepath.Join(dir, "config.json")
+		writeFile(t, path, body)
+		if r, ok := doctorConfigFile(path); !ok || r.status != want {
+			t.Errorf("%s: got %+v, want status %d", body, r, want)
+		}
+	}
+}
+
+func TestDoctorBrowser(t *testing.T) {
+	has := func(names ...string) func(string) bool {
+		return func(name string) bool { return strings.Contains(strings.Join(names, " "), name) }
+	}
+	r := doctorBrowser(doctorEnv{goos: "linux", hasCommand: has("xdg-open")}, nil)
+	if r.status != doctorOK || !strings.Contains(r.detail, "xdg-open") {
+		t.Errorf("xdg-open: %+v", r)
+	}
+	r = doctorBrowser(doctorEnv{goos: "linux", hasCommand: has("xdg-open")}, browserSpecFromConfig("firefox --new-window", ""))
+	if r.status != doctorWarn || !strings.Contains(r.detail, "firefox") {
+		t.Errorf("missing configured browser: %+v", r)
+	}
+	r = doctorBrowser(doctorEnv{goos: "linux", wsl: true, hasCommand: has()}, nil)
+	if r.status != doctorWarn || !strings.Contains(r.hint, "wslview") {
+		t.Errorf("no launcher under WSL: %+v", r)
+	}
+}
+
+func TestStaleLockFiles(t *testing.T) {
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "stale.lock")
+	held := filepath.Join(dir, "held.lock")
+	writeFile(t, stale, "")
+	writeFile(t, held, "")
+	f, err := os.OpenFile(held, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := staleLockFiles([]string{dir}); !reflect.DeepEqual(got, []string{stale}) {
+		t.Errorf("staleLockFiles() = %v, want [%s]", got, stale)
+	}
+}
+
+func TestDoctorSessions_Fix(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := writeSessionFile("deadpid", sessionEntry{PID: 999999999, Port: 12345, CWD: "/tmp/repo"}); err != nil {
+		t.Fatal(err)
+	}
+	sessDir := filepath.Join(home, ".crit", "sessions")
+	writeFile(t, filepath.Join(sessDir, "old.lock"), "")
+
+	results := doctorSessions(false)
+	if last := results[len(results)-1]; last.status != doctorWarn || !strings.Contains(last.detail, "1 orphaned session file(s) and 1 stale lock file(s)") {
+		t.Fatalf("without --fix: %+v", last)
+	}
+	if _, err := os.Stat(filepath.Join(sessDir, "deadpid.json")); err != nil {
+		t.Fatal("doctor without --fix must not remove files")
+	}
+
+	doctorSessions(true)
+	for _, name := range []string{"deadpid.json", "old.lock"} {
+		if _, err := os.Stat(filepath.Join(sessDir, name)); !os.IsNotExist(err) {
+			t.Errorf("--fix should remove %s", name)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package critcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A design doc the agent writes mid-review belongs in the same loop, so
+// files and directories can be added to a running files-mode session:
+// POST /api/documents {"paths": [...]} or `crit add <file>...`, which finds
+// the daemon for the current directory and branch. The new files join the
+// watcher like the rest, and their arguments are appended to the session's,
+// so running crit again with the longer list finds the same daemon. Paths
+// must lie under the session's root (its repository, or the directory of its
+// first file), so the API can't be used to read arbitrary files.
+
+// AddDocuments adds the files at paths (files or directories, relative to
+// the directory crit was started in) to a files-mode session and returns the
+// session paths of those it added. Files already in the session are skipped.
+func (s *Session) AddDocuments(paths []string) ([]string, error) {
+	if s.Mode != "files" {
+		return nil, errors.New("files can only be added to a session started with file or directory arguments")
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("no files provided")
+	}
+	expanded, err := expandAndDedupPaths(paths, s.IgnorePatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	root, baseRef, vcs := s.RepoRoot, s.BaseRef, s.VCS
+	known := make(map[string]bool, len(s.Files))
+	for _, f := range s.Files {
+		known[f.AbsPath] = true
+	}
+	s.mu.RUnlock()
+
+	var entries []*FileEntry
+	for _, absPath := range expanded {
+		if known[absPath] {
+			continue
+		}
+		if rel, err := filepath.Rel(root, absPath); err != nil || !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("%s is outside %s", absPath, root)
+		}
+		fe, err := newFilesModeEntry(absPath, root, baseRef, vcs)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fe)
+	}
+
+	var added []string
+	s.mu.Lock()
+	for _, fe := range entries {
+		if s.fileByPathLocked(fe.Path) == nil {
+			s.Files = append(s.Files, fe)
+			added = append(added, fe.Path)
+		}
+	}
+	s.mu.Unlock()
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	cwd, _ := resolvedCWD()
+	s.rewriteCLIArgs(func(args []string) []string { return appendDocumentArgs(args, paths, cwd) }, "", "")
+	s.notify(SSEEvent{Type: "file-changed", Content: "session"})
+	return added, nil
+}
+
+// appendDocumentArgs returns args with each of paths not already there
+// appended, relative to cwd when they lie under it, as crit would have been
+// given them.
+func appendDocumentArgs(args, paths []string, cwd string) []string {
+	out := slices.Clone(args)
+	for _, p := range paths {
+		arg := filepath.Clean(p)
+		if filepath.IsAbs(arg) && cwd != "" {
+			if rel, err := filepath.Rel(cwd, arg); err == nil && filepath.IsLocal(rel) {
+				arg = rel
+			}
+		}
+		if !slices.Contains(out, arg) {
+			out = append(out, arg)
+		}
+	}
+	return out
+}
+
+// documentsRequest is the body of POST /api/documents.
+type documentsRequest struct {
+	Paths []string `json:"paths"`
+}
+
+// handleDocuments serves POST /api/documents.
+func (s *Server) handleDocuments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
+	var req documentsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	sess := s.session.Load()
+	added, err := sess.AddDocuments(req.Paths)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(added) > 0 {
+		go sess.runLinters()
+	}
+	writeJSON(w, map[string][]string{"added": added})
+}
+
+// runAdd implements `crit add <file|dir>...`.
+func runAdd(args []string) {
+	if len(args) == 0 || slices.Contains(args, "--help") || slices.Contains(args, "-h") {
+		printAddUsage()
+		if len(args) == 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	added, err := addToRunningSession(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(added) == 0 {
+		fmt.Println("Already in the review.")
+		return
+	}
+	for _, p := range added {
+		fmt.Println("Added " + p)
+	}
+}
+
+func printAddUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit add <file|dir> [...]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Adds files to the review running for the current directory and branch,")
+	fmt.Fprintln(os.Stderr, "which must have been started with file or directory arguments.")
+}
+
+// addToRunningSession posts paths, made absolute, to the daemon for the
+// current directory and branch and returns the files it added.
+func addToRunningSession(paths []string) ([]string, error) {
+	cwd, err := resolvedCWD()
+	if err != nil {
+		return nil, err
+	}
+	branch := ""
+	if vcs := DetectVCS(""); vcs != nil {
+		branch = vcs.CurrentBranch()
+	}
+	entry, _, matchCount := findSessionForCWDBranch(cwd, branch)
+	switch {
+	case matchCount == 0:
+		return nil, errors.New("no crit review is running for this directory; start one with 'crit <file>'")
+	case matchCount > 1:
+		return nil, fmt.Errorf("multiple daemons running on branch %q; stop the others with 'crit stop <files>'", branch)
+	}
+
+	req := documentsRequest{Paths: make([]string, len(paths))}
+	for i, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(cwd, p)
+		}
+		req.Paths[i] = p
+	}
+	body, _ := json.Marshal(req)
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(entry.url("/api/documents"), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("could not reach daemon on port %d: %w", entry.Port, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(strings.TrimSpace(string(respBody)))
+	}
+	var result struct {
+		Added []string `json:"added"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("parsing daemon response: %w", err)
+	}
+	return result.Added, nil
+}
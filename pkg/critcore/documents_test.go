@@ -0,0 +1,99 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAddDocuments(t *testing.T) {
+	_, session := newTestServer(t)
+	dir := session.RepoRoot
+	design := filepath.Join(dir, "design.md")
+	os.WriteFile(design, []byte("# Design\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "notes"), 0755)
+	os.WriteFile(filepath.Join(dir, "notes", "a.md"), []byte("a\n"), 0644)
+	session.CLIArgs = []string{"test.md"}
+	var rekeyed []string
+	session.onRename = func(args []string) string { rekeyed = args; return "" }
+
+	added, err := session.AddDocuments([]string{design, filepath.Join(dir, "notes"), filepath.Join(dir, "test.md")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"design.md", filepath.Join("notes", "a.md")}; !slices.Equal(added, want) {
+		t.Errorf("added = %q, want %q", added, want)
+	}
+	session.mu.RLock()
+	f := session.fileByPathLocked("design.md")
+	session.mu.RUnlock()
+	if f == nil || f.Content != "# Design\n" || f.FileType != "markdown" {
+		t.Errorf("design.md entry = %+v", f)
+	}
+	if len(session.CLIArgs) != 4 || session.CLIArgs[0] != "test.md" || session.CLIArgs[1] != design {
+		t.Errorf("CLIArgs = %q", session.CLIArgs)
+	}
+	if rekeyed != nil {
+		t.Errorf("re-keyed without a review file: %q", rekeyed)
+	}
+
+	if added, err := session.AddDocuments([]string{design}); err != nil || added != nil {
+		t.Errorf("adding again: %q, %v", added, err)
+	}
+	if _, err := session.AddDocuments([]string{os.Args[0]}); err == nil {
+		t.Error("added a file outside the session root")
+	}
+	if _, err := session.AddDocuments([]string{filepath.Join(dir, "missing.md")}); err == nil {
+		t.Error("added a missing file")
+	}
+}
+
+func TestAddDocuments_GitMode(t *testing.T) {
+	_, session := newTestServer(t)
+	session.Mode = "git"
+	if _, err := session.AddDocuments([]string{filepath.Join(session.RepoRoot, "test.md")}); err == nil {
+		t.Error("git-mode session accepted documents")
+	}
+}
+
+func TestAppendDocumentArgs(t *testing.T) {
+	got := appendDocumentArgs([]string{"plan.md"}, []string{"/work/docs/design.md", "/elsewhere/x.md", "plan.md", "notes/", "/work/..notes.md"}, "/work")
+	want := []string{"plan.md", filepath.Join("docs", "design.md"), "/elsewhere/x.md", "notes", "..notes.md"}
+	if !slices.Equal(got, want) {
+		t.Errorf("appendDocumentArgs = %q, want %q", got, want)
+	}
+}
+
+func TestHandleDocuments(t *testing.T) {
+	s, session := newTestServer(t)
+	design := filepath.Join(session.RepoRoot, "design.md")
+	os.WriteFile(design, []byte("# Design\n"), 0644)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/api/documents", strings.NewReader(body)))
+		return w
+	}
+
+	body, _ := json.Marshal(documentsRequest{Paths: []string{design}})
+	w := post(string(body))
+	var resp struct {
+		Added []string `json:"added"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != 200 || !slices.Equal(resp.Added, []string{"design.md"}) {
+		t.Errorf("add: status %d, body %s", w.Code, w.Body.String())
+	}
+	if n := len(session.GetSessionInfo().Files); n != 2 {
+		t.Errorf("session files = %d, want 2", n)
+	}
+	if w := post(`{"paths":[]}`); w.Code != 400 {
+		t.Errorf("no paths: status = %d, want 400", w.Code)
+	}
+	if w := post(`not json`); w.Code != 400 {
+		t.Errorf("bad body: status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,160 @@
+package critcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// An agent that retries a comment POST after a timeout can post the same
+// feedback twice. A new comment is a duplicate of an open one by the same
+// author on the same target (file, side, lines, columns, quote or image
+// region; all review-level comments share one) whose body reads nearly the
+// same. duplicate_comments picks what happens: "merge" (the default) answers
+// 200 with the existing comment instead of adding one, "reject" answers
+//
+//	409 {"error": "...", "code": "duplicate_comment", "comment": {...}}
+//
+// and "off" adds it anyway.
+
+const (
+	duplicateMerge  = "merge"
+	duplicateReject = "reject"
+	duplicateOff    = "off"
+)
+
+// duplicateSimilarity is how alike two bodies' words must be, as
+// 2*LCS/(len(a)+len(b)), for the later one to count as a duplicate.
+const duplicateSimilarity = 0.9
+
+// duplicateCommentError is the body of a 409 for a rejected duplicate.
+type duplicateCommentError struct {
+	Error   string  `json:"error"`
+	Code    string  `json:"code"` // "duplicate_comment"
+	Comment Comment `json:"comment"`
+}
+
+// validateDuplicateComments checks the duplicate_comments config key.
+func validateDuplicateComments(mode string) error {
+	switch mode {
+	case "", duplicateMerge, duplicateReject, duplicateOff:
+		return nil
+	}
+	return fmt.Errorf("duplicate_comments: unknown mode %q (want %q, %q or %q)", mode, duplicateMerge, duplicateReject, duplicateOff)
+}
+
+// findDuplicateComment returns the open comment that c, not yet added,
+// would duplicate: on path, or among the review comments when path is empty.
+func (s *Session) findDuplicateComment(path string, c Comment) (Comment, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	comments := s.reviewComments
+	if path != "" {
+		f := s.fileByPathLocked(path)
+		if f == nil {
+			return Comment{}, false
+		}
+		comments = f.Comments
+	}
+	for _, existing := range comments {
+		if !existing.Resolved && sameCommentTarget(existing, c) && similarBodies(existing.Body, c.Body) {
+			return existing, true
+		}
+	}
+	return Comment{}, false
+}
+
+// sameCommentTarget reports whether a and b are by the same author on the
+// same thing.
+func sameCommentTarget(a, b Comment) bool {
+	if a.Author != b.Author || (a.Region == nil) != (b.Region == nil) {
+		return false
+	}
+	if a.Region != nil {
+		return *a.Region == *b.Region
+	}
+	return commentScope(a) == commentScope(b) && a.Side == b.Side &&
+		a.StartLine == b.StartLine && a.EndLine == b.EndLine &&
+		a.StartCol == b.StartCol && a.EndCol == b.EndCol &&
+		(a.StartCol != 0 || a.Quote == b.Quote) // a span's quote is its text
+}
+
+// commentScope is c.Scope, with "line" for comments saved before scopes.
+func commentScope(c Comment) string {
+	if c.Scope == "" {
+		return "line"
+	}
+	return c.Scope
+}
+
+// similarBodies reports whether a and b say nearly the same thing: the same
+// words, ignoring case, punctuation and spacing, give or take
+// duplicateSimilarity.
+func similarBodies(a, b string) bool {
+	wa, wb := bodyWords(a), bodyWords(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+	}
+	total := float64(len(wa) + len(wb))
+	if 2*float64(min(len(wa), len(wb)))/total < duplicateSimilarity {
+		return false
+	}
+	return 2*float64(lcsForwardRow(wa, wb)[len(wb)])/total >= duplicateSimilarity
+}
+
+// bodyWords splits body into lowercase words.
+func bodyWords(body string) []string {
+	return strings.FieldsFunc(strings.ToLower(body), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// fileCommentTarget returns a Comment holding what req would say and where,
+// for acceptUniqueComment.
+func (s *Server) fileCommentTarget(path string, req fileCommentRequest) Comment {
+	c := Comment{Body: req.Body, Author: req.Author}
+	switch {
+	case req.Region != nil:
+		c.Scope, c.Region = "file", req.Region
+	case req.Scope == "file":
+		c.Scope = "file"
+	default:
+		c.Scope, c.Side, c.Quote = "line", req.Side, req.Quote
+		c.StartLine, c.EndLine, c.StartCol, c.EndCol = req.StartLine, req.EndLine, req.StartCol, req.EndCol
+		if req.DataPath != "" && req.StartLine == 0 {
+			c.StartLine, c.EndLine, _ = s.session.Load().DataPathLines(path, req.DataPath)
+		}
+		if req.TableCell != nil {
+			c.StartLine, c.EndLine, _ = s.session.Load().TableCellLines(path, *req.TableCell)
+		}
+	}
+	return c
+}
+
+// acceptUniqueComment checks c, a comment about to be added on path (empty
+// for a review comment), against duplicate_comments. For a duplicate it
+// answers on w, with the existing comment or a 409, and reports !ok.
+func (s *Server) acceptUniqueComment(w http.ResponseWriter, sess *Session, path string, c Comment) bool {
+	mode := s.cfg.DuplicateComments
+	if mode == duplicateOff {
+		return true
+	}
+	existing, found := sess.findDuplicateComment(path, c)
+	if !found {
+		return true
+	}
+	if mode == duplicateReject {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(duplicateCommentError{
+			Error:   fmt.Sprintf("comment duplicates %s (duplicate_comments)", existing.ID),
+			Code:    "duplicate_comment",
+			Comment: existing,
+		})
+		return false
+	}
+	writeJSON(w, existing)
+	return false
+}
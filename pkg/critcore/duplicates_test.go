@@ -0,0 +1,107 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSimilarBodies(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"Fix the error handling here.", "fix the error handling here", true},
+		{"Check the  return value\nof Close", "Check the return value of Close!", true},
+		{"This function leaks the file handle whenever the read fails halfway through the loop, so close it in a defer", "This function leaks the file handle whenever a read fails halfway through the loop, so close it in a defer", true},
+		{"rename to foo", "rename to bar", false},
+		{"not the cache but the store", "not the store but the cache", false},
+		{"+1", "+1", true},
+		{"+1", "?", false},
+	}
+	for _, tt := range tests {
+		if got := similarBodies(tt.a, tt.b); got != tt.want {
+			t.Errorf("similarBodies(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPostFileComment_Duplicate(t *testing.T) {
+	s, session := newTestServer(t)
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/file/comments?path=test.md", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post(`{"start_line":1,"end_line":2,"body":"Split this.","author":"agent"}`)
+	if first.Code != 201 {
+		t.Fatalf("first: status = %d", first.Code)
+	}
+	var created Comment
+	json.Unmarshal(first.Body.Bytes(), &created)
+
+	// merge (the default) answers with the existing comment.
+	w := post(`{"start_line":1,"end_line":2,"body":"split this","author":"agent"}`)
+	var got Comment
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if w.Code != 200 || got.ID != created.ID {
+		t.Errorf("merge: status %d, id %q, want 200 and %q", w.Code, got.ID, created.ID)
+	}
+
+	for name, body := range map[string]string{
+		"other lines":  `{"start_line":2,"end_line":2,"body":"split this","author":"agent"}`,
+		"other author": `{"start_line":1,"end_line":2,"body":"split this","author":"bob"}`,
+		"file level":   `{"scope":"file","body":"split this","author":"agent"}`,
+		"other body":   `{"start_line":1,"end_line":2,"body":"merge this","author":"agent"}`,
+	} {
+		if w := post(body); w.Code != 201 {
+			t.Errorf("%s: status = %d, want 201", name, w.Code)
+		}
+	}
+
+	s.cfg.DuplicateComments = duplicateReject
+	w = post(`{"start_line":1,"end_line":2,"body":"Split this.","author":"agent"}`)
+	var rejected duplicateCommentError
+	json.Unmarshal(w.Body.Bytes(), &rejected)
+	if w.Code != 409 || rejected.Code != "duplicate_comment" || rejected.Comment.ID != created.ID {
+		t.Errorf("reject: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	session.SetCommentResolved("test.md", created.ID, true)
+	if w := post(`{"start_line":1,"end_line":2,"body":"Split this.","author":"agent"}`); w.Code != 201 {
+		t.Errorf("after resolving: status = %d, want 201", w.Code)
+	}
+
+	s.cfg.DuplicateComments = duplicateOff
+	if w := post(`{"scope":"file","body":"split this","author":"agent"}`); w.Code != 201 {
+		t.Errorf("off: status = %d, want 201", w.Code)
+	}
+	if n := len(session.GetComments("test.md")); n != 7 {
+		t.Errorf("comments = %d, want 7", n)
+	}
+}
+
+func TestPostReviewComment_Duplicate(t *testing.T) {
+	s, session := newTestServer(t)
+	for range 2 {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/api/comments", strings.NewReader(`{"body":"Looks good overall"}`)))
+	}
+	if n := len(session.GetReviewComments()); n != 1 {
+		t.Errorf("review comments = %d, want 1", n)
+	}
+}
+
+func TestValidateDuplicateComments(t *testing.T) {
+	for _, ok := range []string{"", "merge", "reject", "off"} {
+		if err := validateDuplicateComments(ok); err != nil {
+			t.Errorf("validateDuplicateComments(%q): %v", ok, err)
+		}
+	}
+	if err := validateDuplicateComments("ignore"); err == nil {
+		t.Error("validateDuplicateComments(ignore) = nil")
+	}
+}
@@ -0,0 +1,245 @@
+package critcore
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// `crit export --email reviewer@corp.com` mails the review through the SMTP
+// server in the smtp config key, for approval workflows that run over email:
+// the history export as the message text and the export itself (HTML unless
+// --format says otherwise) attached. A hooks.finish command can run it to
+// send every finished review.
+
+// envSMTPPassword overrides smtp.password, so the password can stay out of
+// the config file.
+const envSMTPPassword = "CRIT_SMTP_PASSWORD"
+
+// smtpTimeout bounds connecting to the SMTP server.
+const smtpTimeout = 30 * time.Second
+
+// smtpConfig is the smtp config object (global-only: it holds a password and
+// decides where review content goes).
+type smtpConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`     // default 587 (STARTTLS); 465 is TLS from the start
+	Username string `json:"username,omitempty"` // "" sends without authenticating
+	Password string `json:"password,omitempty"` // CRIT_SMTP_PASSWORD overrides it
+	From     string `json:"from"`               // sender address, e.g. "Crit <crit@corp.com>"
+}
+
+func (c smtpConfig) port() int {
+	if c.Port == 0 {
+		return 587
+	}
+	return c.Port
+}
+
+func (c smtpConfig) password() string {
+	if v := os.Getenv(envSMTPPassword); v != "" {
+		return v
+	}
+	return c.Password
+}
+
+// validateSMTP checks an smtp config that has any field set.
+func validateSMTP(c smtpConfig) error {
+	if c == (smtpConfig{}) {
+		return nil
+	}
+	if c.Host == "" {
+		return errors.New("smtp.host is required")
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid smtp.port %d", c.Port)
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("invalid smtp.from %q (want an address, e.g. \"Crit <crit@corp.com>\")", c.From)
+	}
+	return nil
+}
+
+// parseEmailRecipients splits --email values, which may each hold a
+// comma-separated list, into addresses.
+func parseEmailRecipients(values []string) ([]string, error) {
+	var to []string
+	for _, v := range values {
+		list, err := mail.ParseAddressList(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --email %q: %w", v, err)
+		}
+		for _, a := range list {
+			to = append(to, a.Address)
+		}
+	}
+	return to, nil
+}
+
+// reviewEmailSubject summarizes cj for the subject line.
+func reviewEmailSubject(cj CritJSON) string {
+	open := 0
+	for _, lc := range orderedComments(cj) {
+		if !lc.c.Resolved {
+			open++
+		}
+	}
+	subject := "Review"
+	if cj.Branch != "" {
+		subject += " of " + cj.Branch
+	}
+	return fmt.Sprintf("%s, round %d: %d open comment%s", subject, max(cj.ReviewRound, 1), open, plural(open))
+}
+
+// buildReviewEmail returns the message mailing text with name attached.
+func buildReviewEmail(from string, to []string, subject, text, name string, attachment []byte, now time.Time) []byte {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	writeBase64Lines(part, []byte(text))
+	if attachment != nil {
+		ctype := mime.TypeByExtension(filepath.Ext(name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		part, _ = mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {ctype},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		writeBase64Lines(part, attachment)
+	}
+	mw.Close()
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed;\r\n boundary=%q\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes()
+}
+
+// writeBase64Lines writes data base64-encoded in 76-character lines, as MIME
+// requires.
+func writeBase64Lines(w io.Writer, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		w.Write([]byte(enc[:76] + "\r\n"))
+		enc = enc[76:]
+	}
+	w.Write([]byte(enc + "\r\n"))
+}
+
+// emailExport mails out, the review cj exported in f.format, to f.emails
+// through the server in c.
+func emailExport(f exportFlags, cj CritJSON, out []byte, c smtpConfig, loc *time.Location) error {
+	if c.Host == "" {
+		return errors.New("--email needs an smtp server (the smtp key of the global config)")
+	}
+	to, err := parseEmailRecipients(f.emails)
+	if err != nil {
+		return err
+	}
+	msg := buildReviewEmail(c.From, to, reviewEmailSubject(cj), renderReviewHistory(cj, "", loc),
+		filepath.Base(f.dest), out, time.Now())
+	if err := sendEmail(c, to, msg); err != nil {
+		return err
+	}
+	fmt.Printf("Sent the review to %s\n", strings.Join(to, ", "))
+	return nil
+}
+
+// sendEmail delivers msg to the recipients through the configured server.
+func sendEmail(c smtpConfig, to []string, msg []byte) error {
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.port()))
+	client, err := dialSMTP(c, addr)
+	if err != nil {
+		return fmt.Errorf("smtp %s: %w", addr, err)
+	}
+	defer client.Close()
+	if err := deliverEmail(client, c, to, msg); err != nil {
+		return fmt.Errorf("smtp %s: %w", addr, err)
+	}
+	return nil
+}
+
+// dialSMTP connects to the server at addr, over TLS on port 465 and with
+// STARTTLS where the server offers it, and authenticates when a username is
+// set.
+func dialSMTP(c smtpConfig, addr string) (*smtp.Client, error) {
+	var conn net.Conn
+	var err error
+	if c.port() == 465 {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: smtpTimeout}, "tcp", addr, &tls.Config{ServerName: c.Host})
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, smtpTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: c.Host}); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS: %w", err)
+		}
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.password(), c.Host)); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// deliverEmail sends msg from c.From to the recipients over client.
+func deliverEmail(client *smtp.Client, c smtpConfig, to []string, msg []byte) error {
+	from, err := mail.ParseAddress(c.From)
+	if err != nil {
+		return err
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("%s: %w", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
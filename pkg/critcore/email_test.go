@@ -0,0 +1,194 @@
+package critcore
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP accepts one SMTP session on a local port, requiring AUTH PLAIN,
+// and records the commands and message it was sent.
+func fakeSMTP(t *testing.T) (port int, session chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	session = make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var got []string
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			line = strings.TrimRight(line, "\r\n")
+			got = append(got, line)
+			switch verb := strings.ToUpper(strings.Fields(line + " x")[0]); verb {
+			case "EHLO":
+				reply("250-fake\r\n250 AUTH PLAIN")
+			case "AUTH":
+				reply("235 ok")
+			case "DATA":
+				reply("354 go on")
+				var msg strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					msg.WriteString(l)
+				}
+				got = append(got, msg.String())
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				session <- got
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+		session <- got
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, session
+}
+
+func TestValidateSMTP(t *testing.T) {
+	if err := validateSMTP(smtpConfig{}); err != nil {
+		t.Errorf("unset smtp: %v", err)
+	}
+	if err := validateSMTP(smtpConfig{Host: "smtp.corp.com", From: "Crit <crit@corp.com>"}); err != nil {
+		t.Errorf("valid smtp: %v", err)
+	}
+	for _, bad := range []smtpConfig{{From: "crit@corp.com"}, {Host: "smtp.corp.com"}, {Host: "smtp.corp.com", From: "crit@corp.com", Port: 70000}} {
+		if err := validateSMTP(bad); err == nil {
+			t.Errorf("validateSMTP(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestParseEmailRecipients(t *testing.T) {
+	to, err := parseEmailRecipients([]string{"a@corp.com", "Bo <b@corp.com>, c@corp.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a@corp.com", "b@corp.com", "c@corp.com"}; !slices.Equal(to, want) {
+		t.Errorf("recipients = %v, want %v", to, want)
+	}
+	if _, err := parseEmailRecipients([]string{"not an address"}); err == nil {
+		t.Error("an invalid address should fail")
+	}
+}
+
+func TestReviewEmailSubject(t *testing.T) {
+	cj := CritJSON{Branch: "feature", ReviewRound: 2, Files: map[string]CritJSONFile{
+		"a.md": {Comments: []Comment{{ID: "c1"}, {ID: "c2", Resolved: true}}},
+	}}
+	if got, want := reviewEmailSubject(cj), "Review of feature, round 2: 1 open comment"; got != want {
+		t.Errorf("subject = %q, want %q", got, want)
+	}
+}
+
+func TestBuildReviewEmail(t *testing.T) {
+	attachment := []byte(strings.Repeat("<p>review</p>", 20))
+	raw := buildReviewEmail("Crit <crit@corp.com>", []string{"a@corp.com"}, "Review round 1", "Two comments.",
+		"crit-review.html", attachment, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Get("To") != "a@corp.com" || msg.Header.Get("Subject") != "Review round 1" {
+		t.Errorf("headers = %v", msg.Header)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q", msg.Header.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var bodies []string
+	var names []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		bodies = append(bodies, string(data))
+		names = append(names, p.FileName())
+	}
+	if len(bodies) != 2 || bodies[0] != "Two comments." || bodies[1] != string(attachment) {
+		t.Fatalf("parts = %q", bodies)
+	}
+	if names[1] != "crit-review.html" {
+		t.Errorf("attachment name = %q", names[1])
+	}
+	for _, line := range strings.Split(string(raw), "\r\n") {
+		if len(line) > 78 {
+			t.Errorf("line longer than 78 characters: %q", line)
+		}
+	}
+}
+
+func TestSendEmail(t *testing.T) {
+	port, session := fakeSMTP(t)
+	t.Setenv(envSMTPPassword, "secret")
+	c := smtpConfig{Host: "127.0.0.1", Port: port, Username: "crit", Password: "ignored", From: "Crit <crit@corp.com>"}
+	if err := sendEmail(c, []string{"a@corp.com", "b@corp.com"}, []byte("Subject: hi\r\n\r\nbody\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	got := <-session
+	auth := base64.StdEncoding.EncodeToString([]byte("\x00crit\x00secret"))
+	for _, want := range []string{"AUTH PLAIN " + auth, "MAIL FROM:<crit@corp.com>", "RCPT TO:<a@corp.com>", "RCPT TO:<b@corp.com>", "Subject: hi\r\n\r\nbody\r\n"} {
+		if !slices.ContainsFunc(got, func(l string) bool { return strings.HasPrefix(l, want) }) {
+			t.Errorf("session %q is missing %q", got, want)
+		}
+	}
+}
+
+func TestSendEmail_Unreachable(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	err := sendEmail(smtpConfig{Host: "127.0.0.1", Port: port, From: "crit@corp.com"}, []string{"a@corp.com"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "127.0.0.1:"+strconv.Itoa(port)) {
+		t.Errorf("err = %v, want one naming the server", err)
+	}
+}
+
+func TestParseExportFlags_Email(t *testing.T) {
+	f, err := parseExportFlags([]string{"--email", "a@corp.com", "--email", "b@corp.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(f.emails, []string{"a@corp.com", "b@corp.com"}) || !f.defaultDest || f.dest != "crit-review.html" {
+		t.Errorf("flags = %+v", f)
+	}
+	if f, _ := parseExportFlags([]string{"--email", "a@corp.com", "out.html"}); f.defaultDest {
+		t.Error("an explicit file should not be the default")
+	}
+	if _, err := parseExportFlags([]string{"--email", "a@corp.com", "-"}); err == nil {
+		t.Error("--email with stdout should fail")
+	}
+}
@@ -0,0 +1,503 @@
+package critcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Values for the "output_formats" config key. Each enabled format is written
+// next to the review file as <review>.review.<format> whenever it is saved
+// (see also exportInline).
+const (
+	exportJSON = "json"
+	exportYAML = "yaml"
+)
+
+// reviewExport is a flat, harness-friendly view of a review: one entry per
+// comment with its location and status, instead of the per-file layout of the
+// review file (which also carries crit's bookkeeping).
+type reviewExport struct {
+	SchemaVersion int             `json:"schema_version"`
+	Branch        string          `json:"branch,omitempty"`
+	BaseRef       string          `json:"base_ref,omitempty"`
+	ReviewRound   int             `json:"review_round"`
+	UpdatedAt     string          `json:"updated_at"`
+	Checklist     []checklistItem `json:"checklist,omitempty"`
+	Comments      []exportComment `json:"comments"`
+}
+
+type exportComment struct {
+	ID          string        `json:"id"`
+	Scope       string        `json:"scope"`
+	File        string        `json:"file,omitempty"`
+	StartLine   int           `json:"start_line,omitempty"`
+	EndLine     int           `json:"end_line,omitempty"`
+	StartCol    int           `json:"start_col,omitempty"`
+	EndCol      int           `json:"end_col,omitempty"`
+	Side        string        `json:"side,omitempty"`
+	Anchor      string        `json:"anchor,omitempty"`
+	Quote       string        `json:"quote,omitempty"`
+	Drifted     bool          `json:"drifted,omitempty"`
+	Status      string        `json:"status"` // "open" or "resolved"
+	Pinned      bool          `json:"pinned,omitempty"`
+	Severity    string        `json:"severity,omitempty"`
+	Disposition *disposition  `json:"disposition,omitempty"`
+	Author      string        `json:"author,omitempty"`
+	Body        string        `json:"body"`
+	Round       int           `json:"review_round,omitempty"`
+	CreatedAt   string        `json:"created_at"`
+	Replies     []Reply       `json:"replies,omitempty"`
+	Refs        []commentLink `json:"refs,omitempty"`
+	Backlinks   []commentLink `json:"backlinks,omitempty"`
+	Cells       []tableCell   `json:"table_cells,omitempty"`
+	Region      *imageRegion  `json:"region,omitempty"`
+	Link        string        `json:"link,omitempty"` // the lines on the share page, when shared (lineLink)
+}
+
+// validExportFormats filters formats down to the known ones, warning about
+// the rest.
+func validExportFormats(formats []string) []string {
+	var valid []string
+	for _, f := range formats {
+		switch f {
+		case exportJSON, exportYAML, exportInline, exportHistory:
+			valid = append(valid, f)
+		default:
+			slog.Warn("unknown output format, skipping", "format", f, "want", []string{exportJSON, exportYAML, exportInline, exportHistory})
+		}
+	}
+	return valid
+}
+
+// reviewExportPath returns where the export in format is written for the
+// review file at critPath: foo.json -> foo.review.<format>.
+func reviewExportPath(critPath, format string) string {
+	return strings.TrimSuffix(critPath, ".json") + ".review." + format
+}
+
+// isReviewExportName reports whether a file name is a review export rather
+// than a review file, so scans of the reviews directory can skip it.
+func isReviewExportName(name string) bool {
+	return strings.HasSuffix(name, ".review."+exportJSON) || strings.HasSuffix(name, ".review."+exportYAML) ||
+		strings.HasSuffix(name, ".review."+exportHistory+".md")
+}
+
+// removeReviewExports deletes every export belonging to the review file at critPath.
+func removeReviewExports(critPath string) {
+	for _, f := range []string{exportJSON, exportYAML} {
+		os.Remove(reviewExportPath(critPath, f))
+	}
+	os.Remove(reviewHistoryPath(critPath))
+	os.RemoveAll(annotatedExportDir(critPath))
+	os.RemoveAll(regionCropsDir(critPath))
+}
+
+// locatedComment is a comment together with the file it belongs to.
+type locatedComment struct {
+	file string // "" for review-level comments
+	c    Comment
+}
+
+// orderedComments returns every comment in cj: pinned file comments first,
+// then review-level comments, then the other file comments, each group
+// ordered by path and line.
+func orderedComments(cj CritJSON) []locatedComment {
+	var pinned, all []locatedComment
+	for _, c := range cj.ReviewComments {
+		all = append(all, locatedComment{c: c})
+	}
+	paths := make([]string, 0, len(cj.Files))
+	for p := range cj.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		comments := append([]Comment(nil), cj.Files[p].Comments...)
+		sort.SliceStable(comments, func(i, j int) bool { return comments[i].StartLine < comments[j].StartLine })
+		for _, c := range comments {
+			if c.Pinned {
+				pinned = append(pinned, locatedComment{file: p, c: c})
+			} else {
+				all = append(all, locatedComment{file: p, c: c})
+			}
+		}
+	}
+	return append(pinned, all...)
+}
+
+// buildReviewExport flattens cj in orderedComments order.
+func buildReviewExport(cj CritJSON) reviewExport {
+	ex := reviewExport{
+		SchemaVersion: critJSONSchemaVersion,
+		Branch:        cj.Branch,
+		BaseRef:       cj.BaseRef,
+		ReviewRound:   cj.ReviewRound,
+		UpdatedAt:     cj.UpdatedAt,
+		Checklist:     cj.Checklist,
+		Comments:      []exportComment{},
+	}
+	linkBase := reviewLinkBase(cj)
+	for _, lc := range orderedComments(cj) {
+		ec := newExportComment(lc.c, lc.file)
+		ec.Link = lineLink(linkBase, lc.file, lc.c)
+		ex.Comments = append(ex.Comments, ec)
+	}
+	return ex
+}
+
+func newExportComment(c Comment, file string) exportComment {
+	scope := c.Scope
+	if scope == "" {
+		scope = "line"
+		if file == "" {
+			scope = "review"
+		}
+	}
+	status := "open"
+	if c.Resolved {
+		status = "resolved"
+	}
+	return exportComment{
+		ID:          c.ID,
+		Scope:       scope,
+		File:        file,
+		StartLine:   c.StartLine,
+		EndLine:     c.EndLine,
+		StartCol:    c.StartCol,
+		EndCol:      c.EndCol,
+		Side:        c.Side,
+		Anchor:      c.Anchor,
+		Quote:       c.Quote,
+		Drifted:     c.Drifted,
+		Status:      status,
+		Pinned:      c.Pinned,
+		Severity:    c.Severity,
+		Disposition: c.Disposition,
+		Author:      c.Author,
+		Body:        c.Body,
+		Round:       c.ReviewRound,
+		CreatedAt:   c.CreatedAt,
+		Replies:     c.Replies,
+		Refs:        c.Refs,
+		Backlinks:   c.Backlinks,
+		Cells:       c.TableCells,
+		Region:      c.Region,
+	}
+}
+
+// writeReviewExports writes cj in each of formats next to critPath. Failures
+// are logged: exports are derived data and never block a save.
+func writeReviewExports(critPath string, cj CritJSON, formats []string, loc *time.Location) {
+	if len(formats) == 0 {
+		return
+	}
+	data, err := json.MarshalIndent(buildReviewExport(cj), "", "  ")
+	if err != nil {
+		slog.Warn("building review export", "err", err)
+		return
+	}
+	for _, f := range formats {
+		path, out := reviewExportPath(critPath, f), data
+		switch f {
+		case exportInline:
+			continue // written by Session.writeAnnotatedExport, which needs file contents
+		case exportHistory:
+			path, out = reviewHistoryPath(critPath), []byte(renderReviewHistory(cj, filepath.Base(regionCropsDir(critPath)), loc))
+		case exportYAML:
+			if out, err = jsonToYAML(data); err != nil {
+				slog.Warn("building YAML review export", "err", err)
+				continue
+			}
+		}
+		if err := atomicWriteFile(path, out, 0644); err != nil {
+			slog.Warn("writing review export", "err", err)
+		}
+	}
+}
+
+// yamlNode is a JSON value with object key order preserved.
+type yamlNode struct {
+	scalar string // encoded scalar; empty for objects and arrays
+	keys   []string
+	vals   []yamlNode
+	isList bool
+}
+
+func (n yamlNode) inline() (string, bool) {
+	switch {
+	case n.scalar != "":
+		return n.scalar, true
+	case len(n.vals) > 0:
+		return "", false
+	case n.isList:
+		return "[]", true
+	default:
+		return "{}", true
+	}
+}
+
+// jsonToYAML converts JSON to block-style YAML, keeping key order. Strings
+// stay JSON-quoted, which YAML reads as double-quoted scalars, so no value
+// can be misread as another type. Avoids a YAML dependency for one format.
+func jsonToYAML(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := decodeYAMLNode(dec)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if s, ok := root.inline(); ok {
+		buf.WriteString(s + "\n")
+	} else {
+		writeYAMLNode(&buf, root, 0)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeYAMLNode(dec *json.Decoder) (yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return yamlNode{}, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		n := yamlNode{isList: t == '['}
+		for dec.More() {
+			if !n.isList {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return yamlNode{}, err
+				}
+				n.keys = append(n.keys, yamlKey(keyTok.(string)))
+			}
+			v, err := decodeYAMLNode(dec)
+			if err != nil {
+				return yamlNode{}, err
+			}
+			n.vals = append(n.vals, v)
+		}
+		if _, err := dec.Token(); err != nil { // closing delimiter
+			return yamlNode{}, err
+		}
+		return n, nil
+	case nil:
+		return yamlNode{scalar: "null"}, nil
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return yamlNode{}, err
+		}
+		return yamlNode{scalar: string(b)}, nil
+	}
+}
+
+// yamlKey leaves identifier-like keys bare and quotes anything else.
+func yamlKey(k string) string {
+	for _, r := range k {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' {
+			b, _ := json.Marshal(k)
+			return string(b)
+		}
+	}
+	if k == "" {
+		return `""`
+	}
+	return k
+}
+
+func writeYAMLNode(buf *bytes.Buffer, n yamlNode, indent int) {
+	pad := strings.Repeat(" ", indent)
+	for i, v := range n.vals {
+		prefix := pad + "- "
+		if !n.isList {
+			prefix = pad + n.keys[i] + ":"
+		}
+		if s, ok := v.inline(); ok {
+			if !n.isList {
+				prefix += " "
+			}
+			buf.WriteString(prefix + s + "\n")
+			continue
+		}
+		if !n.isList {
+			buf.WriteString(prefix + "\n")
+			writeYAMLNode(buf, v, indent+2)
+			continue
+		}
+		// List item holding a container: its first line shares the "- ".
+		var item bytes.Buffer
+		writeYAMLNode(&item, v, indent+2)
+		buf.WriteString(prefix + strings.TrimPrefix(item.String(), pad+"  "))
+	}
+}
+
+// exportCommandFormats are the formats `crit export` writes, with the file
+// extension of each.
+var exportCommandFormats = map[string]string{
+	exportHTML:    "html",
+	exportPDF:     "pdf",
+	exportJSON:    "json",
+	exportYAML:    "yaml",
+	exportHistory: "md",
+}
+
+// exportFlags are the parsed `crit export` arguments.
+type exportFlags struct {
+	commentFlags          // --output, --plan
+	format       string   // html by default
+	dest         string   // crit-review.<ext> by default; "-" is stdout
+	browser      string   // --browser, for pdf
+	emails       []string // --email recipients
+	defaultDest  bool     // dest wasn't given
+}
+
+func printExportUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit export [--format html|pdf|json|yaml|history] [--browser <path>] [--email <addr>]... [--output <dir>] [--plan <slug>] [<file>|-]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Writes the review to <file> (crit-review.<ext> by default, - for stdout).")
+	fmt.Fprintln(os.Stderr, "html, the default, is a single self-contained page with each commented file,")
+	fmt.Fprintln(os.Stderr, "the commented lines highlighted and the threads; it needs no server to view.")
+	fmt.Fprintln(os.Stderr, "pdf prints that page with a headless Chrome, Chromium or Edge, found on the")
+	fmt.Fprintln(os.Stderr, "PATH unless --browser names one.")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "--email mails the review through the smtp config's server instead: the")
+	fmt.Fprintln(os.Stderr, "history as the message and the export attached. It is also written to <file>")
+	fmt.Fprintln(os.Stderr, "when one is given. --email can be repeated or take a comma-separated list.")
+}
+
+// parseExportFlags reads the `crit export` arguments.
+func parseExportFlags(args []string) (exportFlags, error) {
+	f := exportFlags{format: exportHTML}
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--format", "--browser", "--email", "--output", "-o", "--plan":
+			if i+1 >= len(args) {
+				return f, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			switch arg {
+			case "--format":
+				f.format = args[i]
+			case "--browser":
+				f.browser = args[i]
+			case "--email":
+				f.emails = append(f.emails, args[i])
+			case "--plan":
+				f.plan = args[i]
+			default:
+				f.outputDir = args[i]
+			}
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return f, fmt.Errorf("unknown flag %q", arg)
+			}
+			if f.dest != "" {
+				return f, fmt.Errorf("unexpected argument %q", arg)
+			}
+			f.dest = arg
+		}
+	}
+	ext, ok := exportCommandFormats[f.format]
+	if !ok {
+		return f, fmt.Errorf("--format must be %s, %s, %s, %s or %s", exportHTML, exportPDF, exportJSON, exportYAML, exportHistory)
+	}
+	if f.dest == "" {
+		f.dest, f.defaultDest = "crit-review."+ext, true
+	}
+	if len(f.emails) > 0 && f.dest == "-" {
+		return f, errors.New("--email can't be combined with writing to stdout")
+	}
+	return f, nil
+}
+
+// renderExport renders cj in f.format. dir is where the reviewed files are,
+// relative to which the review file names them.
+func renderExport(f exportFlags, cj CritJSON, dir string, loc *time.Location) ([]byte, error) {
+	switch f.format {
+	case exportHTML:
+		return renderHTMLExport(cj, dir, loc)
+	case exportPDF:
+		html, err := renderHTMLExport(cj, dir, loc)
+		if err != nil {
+			return nil, err
+		}
+		return renderPDFExport(html, f.browser)
+	case exportHistory:
+		return []byte(renderReviewHistory(cj, "", loc)), nil
+	}
+	data, err := json.MarshalIndent(buildReviewExport(cj), "", "  ")
+	if err != nil || f.format == exportJSON {
+		return data, err
+	}
+	return jsonToYAML(data)
+}
+
+func runExport(args []string) {
+	if slices.Contains(args, "--help") || slices.Contains(args, "-h") {
+		printExportUsage()
+		return
+	}
+	f, err := parseExportFlags(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		printExportUsage()
+		os.Exit(1)
+	}
+	resolveCommentFlags(&f.commentFlags)
+	critPath, err := resolveReviewPath(f.outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	cj, err := loadCritJSON(critPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Review file paths are relative to the repo root; a plan's file lives in
+	// its storage directory.
+	dir, _ := os.Getwd()
+	if vcs := DetectVCS(""); vcs != nil {
+		if root, err := vcs.RepoRoot(); err == nil {
+			dir = root
+		}
+	}
+	if f.plan != "" {
+		dir = f.outputDir
+	}
+	loc, _ := loadTimezone(LoadConfig(dir).Timezone)
+	out, err := renderExport(f, cj, dir, loc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(f.emails) > 0 {
+		if err := emailExport(f, cj, out, LoadConfig(dir).SMTP, loc); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if f.defaultDest {
+			return
+		}
+	}
+	if f.dest == "-" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := atomicWriteFile(f.dest, out, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %s\n", f.dest)
+}
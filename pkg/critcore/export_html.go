@@ -0,0 +1,208 @@
+package critcore
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exportHTML is the `crit export` format that writes the review as one
+// self-contained HTML page: each commented file with numbered lines, the
+// commented ranges highlighted and every thread shown after the lines it is
+// on, plus the review-level comments. Styles are inlined and there is no
+// script, so it can be attached to a ticket or mailed and opened anywhere.
+const exportHTML = "html"
+
+// htmlExportPage is the data for htmlExportTemplate.
+type htmlExportPage struct {
+	Branch         string
+	Round          int
+	Updated        string
+	ReviewComments []htmlExportComment
+	Files          []htmlExportFile
+}
+
+// htmlExportFile is one commented file. Comments holds those not placed on
+// its lines: file comments, old-side comments and any past the end.
+type htmlExportFile struct {
+	Path     string
+	Note     string
+	Comments []htmlExportComment
+	Lines    []htmlExportLine
+}
+
+// htmlExportLine is a numbered line and the threads ending on it.
+type htmlExportLine struct {
+	plainLine
+	Threads []htmlExportComment
+}
+
+// htmlExportComment is a comment thread with its bodies rendered.
+type htmlExportComment struct {
+	ID       string
+	Location string
+	Author   string
+	When     string
+	Severity string
+	Resolved bool
+	Original string // the text a drifted comment was made on
+	Body     template.HTML
+	Replies  []htmlExportReply
+}
+
+type htmlExportReply struct {
+	Author string
+	When   string
+	Body   template.HTML
+}
+
+var htmlExportTemplate = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>crit review{{with .Branch}} - {{.}}{{end}}</title>
+<style>
+:root { color-scheme: light dark; --fg: #1f2328; --muted: #656d76; --border: #d0d7de; --hl: #fff3c4; --thread: #f6f8fa; }
+@media (prefers-color-scheme: dark) { :root { --fg: #e6edf3; --muted: #8d96a0; --border: #30363d; --hl: #3d3413; --thread: #161b22; } }
+body { font-family: -apple-system, "Segoe UI", sans-serif; color: var(--fg); max-width: 64rem; margin: 1.5rem auto; padding: 0 1rem; }
+header p, .meta { color: var(--muted); font-size: .875rem; }
+h2 { border-bottom: 1px solid var(--border); padding-bottom: .25rem; margin-top: 2rem; font-family: monospace; }
+table.code { border-collapse: collapse; font-family: monospace; font-size: .8125rem; width: 100%; }
+table.code td { padding: 0 .5rem; white-space: pre-wrap; word-break: break-word; vertical-align: top; }
+table.code td.n { color: var(--muted); text-align: right; user-select: none; width: 1%; }
+tr.commented td { background: var(--hl); }
+td.threads { padding: .25rem .5rem .5rem 3rem !important; white-space: normal !important; font-family: -apple-system, "Segoe UI", sans-serif; }
+.thread { border: 1px solid var(--border); border-radius: 6px; background: var(--thread); padding: .5rem .75rem; margin: .5rem 0; }
+.thread.resolved { opacity: .6; }
+.reply { border-top: 1px solid var(--border); margin-top: .5rem; padding-top: .5rem; }
+.body > :first-child { margin-top: .25rem; }
+.body > :last-child { margin-bottom: 0; }
+pre { overflow-x: auto; }
+@media print { body { max-width: none; margin: 0; } .thread { break-inside: avoid; } h2 { break-after: avoid; } }
+</style>
+</head>
+<body>
+{{define "thread"}}<div class="thread{{if .Resolved}} resolved{{end}}" id="{{.ID}}">
+<div class="meta">{{.Location}}{{with .Author}} · {{.}}{{end}}{{with .When}} · {{.}}{{end}}{{with .Severity}} · {{.}}{{end}}{{if .Resolved}} · resolved{{end}}</div>
+{{with .Original}}<div class="meta">The lines have changed since. Originally on:</div><pre>{{.}}</pre>{{end}}
+<div class="body">{{.Body}}</div>
+{{range .Replies}}<div class="reply"><div class="meta">{{with .Author}}{{.}}{{else}}Reply{{end}}{{with .When}} · {{.}}{{end}}</div><div class="body">{{.Body}}</div></div>
+{{end}}</div>
+{{end}}
+<header>
+<h1>crit review</h1>
+<p>{{with .Branch}}Branch {{.}} · {{end}}Round {{.Round}}{{with .Updated}} · updated {{.}}{{end}}</p>
+</header>
+{{with .ReviewComments}}<section>
+<h2>Review</h2>
+{{range .}}{{template "thread" .}}{{end}}
+</section>{{end}}
+{{range $f := .Files}}<section>
+<h2 id="{{.Path}}">{{.Path}}</h2>
+{{with .Note}}<p class="meta">{{.}}</p>{{end}}
+{{range .Comments}}{{template "thread" .}}{{end}}
+{{with .Lines}}<table class="code">
+{{range .}}<tr id="{{$f.Path}}:L{{.N}}"{{if .Commented}} class="commented"{{end}}><td class="n">{{.N}}</td><td>{{.Text}}</td></tr>
+{{with .Threads}}<tr><td></td><td class="threads">{{range .}}{{template "thread" .}}{{end}}</td></tr>
+{{end}}{{end}}</table>{{end}}
+</section>
+{{else}}{{if not .ReviewComments}}<p>No comments.</p>{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// renderHTMLExport builds the HTML export of cj, reading each commented file
+// from dir (the directory review file paths are relative to). Times are
+// shown in loc.
+func renderHTMLExport(cj CritJSON, dir string, loc *time.Location) ([]byte, error) {
+	page := htmlExportPage{Branch: cj.Branch, Round: max(cj.ReviewRound, 1), Updated: displayTime(cj.UpdatedAt, loc)}
+	for _, c := range cj.ReviewComments {
+		page.ReviewComments = append(page.ReviewComments, newHTMLExportComment(c, "Review", loc))
+	}
+	paths := make([]string, 0, len(cj.Files))
+	for path, f := range cj.Files {
+		if len(f.Comments) > 0 {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		page.Files = append(page.Files, htmlExportFileFor(path, cj.Files[path], dir, loc))
+	}
+	var buf bytes.Buffer
+	if err := htmlExportTemplate.Execute(&buf, page); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// htmlExportFileFor lays out one file's comments: threads on its lines go
+// under the line they end on, the rest above the file.
+func htmlExportFileFor(path string, cf CritJSONFile, dir string, loc *time.Location) htmlExportFile {
+	comments := append([]Comment(nil), cf.Comments...)
+	sort.SliceStable(comments, func(i, j int) bool { return comments[i].StartLine < comments[j].StartLine })
+	out := htmlExportFile{Path: path}
+	var placed []Comment
+	if data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path))); err != nil {
+		out.Note = "The file isn't on disk any more; only its comments are shown."
+	} else if isImagePath(path) || !looksLikeText(data[:min(len(data), binarySniffLen)]) {
+		out.Note = "This file has no text to show."
+	} else {
+		text := textContent(data)
+		lines := len(plainLines(text, nil))
+		for _, c := range comments {
+			if c.Region == nil && c.Scope != "file" && c.Side != "old" && c.StartLine >= 1 && max(c.StartLine, c.EndLine) <= lines {
+				placed = append(placed, c)
+			}
+		}
+		for _, l := range plainLines(text, placed) {
+			out.Lines = append(out.Lines, htmlExportLine{plainLine: l})
+		}
+	}
+	for _, c := range comments {
+		hc := newHTMLExportComment(c, compactLocation(c), loc)
+		if slices.ContainsFunc(placed, func(p Comment) bool { return p.ID == c.ID }) {
+			end := max(c.EndLine, c.StartLine)
+			out.Lines[end-1].Threads = append(out.Lines[end-1].Threads, hc)
+			continue
+		}
+		out.Comments = append(out.Comments, hc)
+	}
+	return out
+}
+
+func newHTMLExportComment(c Comment, location string, loc *time.Location) htmlExportComment {
+	hc := htmlExportComment{
+		ID:       c.ID,
+		Location: location,
+		Author:   c.Author,
+		When:     displayTime(c.CreatedAt, loc),
+		Severity: c.Severity,
+		Resolved: c.Resolved,
+		Body:     exportMarkdown(expandCommentRefs(c.Body, c.Refs)),
+	}
+	if c.Drifted {
+		hc.Original = c.Anchor
+	}
+	for _, r := range c.Replies {
+		hc.Replies = append(hc.Replies, htmlExportReply{Author: r.Author, When: displayTime(r.CreatedAt, loc), Body: exportMarkdown(r.Body)})
+	}
+	return hc
+}
+
+// exportMarkdown renders a comment body, which is markdown; raw HTML in it
+// is dropped by renderMarkdownHTML.
+func exportMarkdown(body string) template.HTML {
+	out, err := renderMarkdownHTML(body)
+	if err != nil {
+		return template.HTML("<p>" + template.HTMLEscapeString(strings.TrimSpace(body)) + "</p>")
+	}
+	return template.HTML(out)
+}
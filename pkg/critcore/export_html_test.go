@@ -0,0 +1,87 @@
+package critcore
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderHTMLExport(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "docs", "plan.md"), "# Plan\n<b>Step one</b>\nStep two\nDone\n")
+	cj := CritJSON{
+		Branch:         "feature",
+		ReviewRound:    2,
+		ReviewComments: []Comment{{ID: "r1", Body: "Looks close"}},
+		Files: map[string]CritJSONFile{
+			"docs/plan.md": {Comments: []Comment{
+				{ID: "c1", StartLine: 2, EndLine: 3, Body: "**Why** <script>alert(1)</script>", Author: "ada",
+					Replies: []Reply{{ID: "c1-r1", Body: "Fixed", Author: "agent"}}},
+				{ID: "c2", Scope: "file", Body: "Needs a summary"},
+				{ID: "c3", StartLine: 9, EndLine: 9, Body: "Past the end"},
+			}},
+			"gone.md":  {Comments: []Comment{{ID: "c4", StartLine: 1, EndLine: 1, Body: "Deleted since"}}},
+			"quiet.md": {},
+		},
+	}
+	out, err := renderHTMLExport(cj, dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(out)
+	for _, want := range []string{
+		"Branch feature · Round 2",
+		`<tr id="docs/plan.md:L2" class="commented"><td class="n">2</td><td>&lt;b&gt;Step one&lt;/b&gt;</td></tr>`,
+		`<tr id="docs/plan.md:L3" class="commented"><td class="n">3</td><td>Step two</td></tr>
+<tr><td></td><td class="threads"><div class="thread" id="c1">`,
+		`<tr id="docs/plan.md:L4"><td class="n">4</td>`,
+		"<strong>Why</strong>",
+		"L2-L3 · ada",
+		">Fixed</p>",
+		`<div class="meta">file</div>`,
+		`<div class="meta">L9</div>`,
+		"The file isn&#39;t on disk any more",
+		`<div class="thread" id="r1">`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("export is missing %q", want)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Error("raw HTML from a comment body was kept")
+	}
+	if strings.Contains(html, "quiet.md") {
+		t.Error("a file without comments was exported")
+	}
+	if strings.Index(html, `id="c3"`) > strings.Index(html, `<table class="code">`) {
+		t.Error("a comment past the end of the file wasn't listed above it")
+	}
+}
+
+func TestParseExportFlags(t *testing.T) {
+	f, err := parseExportFlags([]string{"--output", "out", "review.html"})
+	if err != nil || f.format != exportHTML || f.dest != "review.html" || f.outputDir != "out" {
+		t.Errorf("got %+v, %v", f, err)
+	}
+	if f, _ := parseExportFlags([]string{"--format", "history"}); f.dest != "crit-review.md" {
+		t.Errorf("default history destination = %q", f.dest)
+	}
+	if f, _ := parseExportFlags([]string{"--format", "pdf", "--browser", "/opt/chrome"}); f.dest != "crit-review.pdf" || f.browser != "/opt/chrome" {
+		t.Errorf("pdf: %+v", f)
+	}
+	for _, args := range [][]string{{"--format", "docx"}, {"a.html", "b.html"}, {"--bogus"}, {"--format"}} {
+		if _, err := parseExportFlags(args); err == nil {
+			t.Errorf("%q accepted", args)
+		}
+	}
+}
+
+func TestRenderExport_JSONAndYAML(t *testing.T) {
+	cj := CritJSON{Files: map[string]CritJSONFile{"a.go": {Comments: []Comment{{ID: "c1", StartLine: 1, EndLine: 1, Body: "hi"}}}}}
+	for format, want := range map[string]string{exportJSON: `"id": "c1"`, exportYAML: `id: "c1"`, exportHistory: "`a.go` L1 (c1): hi"} {
+		out, err := renderExport(exportFlags{format: format}, cj, t.TempDir(), nil)
+		if err != nil || !strings.Contains(string(out), want) {
+			t.Errorf("%s: %v\n%s", format, err, out)
+		}
+	}
+}
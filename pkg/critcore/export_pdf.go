@@ -0,0 +1,95 @@
+package critcore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// exportPDF is the `crit export` format that prints the HTML export to a PDF,
+// for sign-off processes that archive a printable review. Go has no HTML
+// layout engine, so the page is printed by a Chromium-family browser in
+// headless mode (--print-to-pdf) with a throwaway profile; none is bundled.
+const exportPDF = "pdf"
+
+// pdfPrintTimeout bounds one headless print.
+const pdfPrintTimeout = time.Minute
+
+// pdfBrowserCommands are the browsers looked up on the PATH, in order.
+var pdfBrowserCommands = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable",
+	"microsoft-edge", "brave-browser", "chrome", "msedge",
+}
+
+// pdfBrowserApps are where macOS keeps the same browsers, which aren't on
+// the PATH.
+var pdfBrowserApps = []string{
+	"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+	"/Applications/Chromium.app/Contents/MacOS/Chromium",
+	"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+	"/Applications/Brave Browser.app/Contents/MacOS/Brave Browser",
+}
+
+// findPDFBrowser returns the browser that prints PDF exports.
+func findPDFBrowser() (string, error) {
+	for _, name := range pdfBrowserCommands {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	if runtime.GOOS == "darwin" {
+		for _, app := range pdfBrowserApps {
+			if _, err := os.Stat(app); err == nil {
+				return app, nil
+			}
+		}
+	}
+	return "", errors.New("PDF export needs Chrome, Chromium or Edge; install one or pass --browser <path>")
+}
+
+// renderPDFExport prints html, the HTML export, to a PDF with browser (found
+// by findPDFBrowser when empty).
+func renderPDFExport(html []byte, browser string) ([]byte, error) {
+	if browser == "" {
+		var err error
+		if browser, err = findPDFBrowser(); err != nil {
+			return nil, err
+		}
+	}
+	dir, err := os.MkdirTemp("", "crit-pdf-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	page, pdf := filepath.Join(dir, "review.html"), filepath.Join(dir, "review.pdf")
+	if err := os.WriteFile(page, html, 0600); err != nil {
+		return nil, err
+	}
+	pageURL := filepath.ToSlash(page)
+	if !strings.HasPrefix(pageURL, "/") {
+		pageURL = "/" + pageURL // C:/... on Windows
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pdfPrintTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, browser,
+		"--headless", "--disable-gpu", "--no-first-run", "--no-pdf-header-footer",
+		"--user-data-dir="+filepath.Join(dir, "profile"),
+		"--print-to-pdf="+pdf,
+		(&url.URL{Scheme: "file", Path: pageURL}).String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("printing the PDF with %s: %w: %s", browser, err, strings.TrimSpace(string(out)))
+	}
+	data, err := os.ReadFile(pdf)
+	if err != nil {
+		return nil, fmt.Errorf("%s wrote no PDF", browser)
+	}
+	return data, nil
+}
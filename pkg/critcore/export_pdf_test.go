@@ -0,0 +1,57 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakePDFBrowser writes a browser that "prints" by copying the page it is
+// given to the --print-to-pdf path, under name in a directory of its own.
+func fakePDFBrowser(t *testing.T, name string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script browser")
+	}
+	script := filepath.Join(t.TempDir(), name)
+	body := "#!/bin/sh\nfor a; do case $a in --print-to-pdf=*) out=${a#--print-to-pdf=};; file://*) page=${a#file://};; esac; done\n" +
+		"printf '%%PDF-' > \"$out\"; cat \"$page\" >> \"$out\"\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+func TestRenderPDFExport(t *testing.T) {
+	browser := fakePDFBrowser(t, "chromium")
+	out, err := renderPDFExport([]byte("<p>review</p>"), browser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "%PDF-<p>review</p>" {
+		t.Errorf("pdf = %q", out)
+	}
+
+	t.Setenv("PATH", filepath.Dir(browser)+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if found, err := findPDFBrowser(); err != nil || found != browser {
+		t.Errorf("findPDFBrowser = %q, %v", found, err)
+	}
+	if _, err := renderPDFExport(nil, ""); err != nil {
+		t.Errorf("with the browser on the PATH: %v", err)
+	}
+}
+
+func TestRenderPDFExport_BrowserFails(t *testing.T) {
+	_, err := renderPDFExport([]byte("<p>review</p>"), "false")
+	if err == nil || !strings.Contains(err.Error(), "printing the PDF") {
+		t.Errorf("err = %v", err)
+	}
+	t.Setenv("PATH", t.TempDir())
+	if runtime.GOOS != "darwin" {
+		if _, err := renderPDFExport(nil, ""); err == nil || !strings.Contains(err.Error(), "--browser") {
+			t.Errorf("no browser: %v", err)
+		}
+	}
+}
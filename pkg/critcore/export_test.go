@@ -0,0 +1,136 @@
+package critcore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildReviewExport_FlattensAndOrders(t *testing.T) {
+	cj := CritJSON{
+		ReviewRound:    2,
+		ReviewComments: []Comment{{ID: "r1", Body: "overall"}},
+		Files: map[string]CritJSONFile{
+			"b.go": {Comments: []Comment{{ID: "b2", StartLine: 9, EndLine: 9, Body: "later"}, {ID: "b1", StartLine: 3, EndLine: 4, Body: "earlier", Resolved: true}}},
+			"a.go": {Comments: []Comment{{ID: "a1", Scope: "file", Body: "whole file"}}},
+		},
+	}
+	ex := buildReviewExport(cj)
+	var ids []string
+	for _, c := range ex.Comments {
+		ids = append(ids, c.ID)
+	}
+	if got := strings.Join(ids, ","); got != "r1,a1,b1,b2" {
+		t.Errorf("order = %s, want r1,a1,b1,b2", got)
+	}
+	if c := ex.Comments[0]; c.Scope != "review" || c.File != "" || c.Status != "open" {
+		t.Errorf("review comment = %+v", c)
+	}
+	if c := ex.Comments[2]; c.Scope != "line" || c.File != "b.go" || c.Status != "resolved" || c.Link != "" {
+		t.Errorf("line comment = %+v", c)
+	}
+
+	cj.ShareURL = "https://crit.md/r/abc"
+	ex = buildReviewExport(cj)
+	if c := ex.Comments[2]; c.Link != "https://crit.md/r/abc#lines=b.go:L3-L4" {
+		t.Errorf("shared review: link = %q", c.Link)
+	}
+	if c := ex.Comments[1]; c.Link != "" {
+		t.Errorf("file comment: link = %q, want none", c.Link)
+	}
+}
+
+func TestBuildReviewExport_PinnedFirst(t *testing.T) {
+	cj := CritJSON{
+		ReviewComments: []Comment{{ID: "r1", Body: "overall"}},
+		Files: map[string]CritJSONFile{
+			"a.go": {Comments: []Comment{{ID: "a1", StartLine: 1, EndLine: 1}}},
+			"b.go": {Comments: []Comment{{ID: "b1", StartLine: 2, EndLine: 2}, {ID: "b2", StartLine: 40, EndLine: 40, Pinned: true}}},
+		},
+	}
+	ex := buildReviewExport(cj)
+	var ids []string
+	for _, c := range ex.Comments {
+		ids = append(ids, c.ID)
+	}
+	if got := strings.Join(ids, ","); got != "b2,r1,a1,b1" {
+		t.Errorf("order = %s, want b2,r1,a1,b1", got)
+	}
+	if !ex.Comments[0].Pinned {
+		t.Error("export should mark the pinned comment")
+	}
+}
+
+func TestWriteReviewExports(t *testing.T) {
+	dir := t.TempDir()
+	critPath := filepath.Join(dir, ".crit.json")
+	cj := CritJSON{Files: map[string]CritJSONFile{
+		"main.go": {Comments: []Comment{{ID: "c1", StartLine: 1, EndLine: 2, Body: "line one\n\"quoted\": yes"}}},
+	}}
+	writeReviewExports(critPath, cj, []string{exportJSON, exportYAML}, nil)
+
+	data, err := os.ReadFile(filepath.Join(dir, ".crit.review.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ex reviewExport
+	if err := json.Unmarshal(data, &ex); err != nil {
+		t.Fatal(err)
+	}
+	if len(ex.Comments) != 1 || ex.Comments[0].File != "main.go" {
+		t.Errorf("json export = %+v", ex)
+	}
+
+	yml, err := os.ReadFile(filepath.Join(dir, ".crit.review.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"schema_version: 1\n",
+		"comments:\n  - id: \"c1\"\n    scope: \"line\"\n    file: \"main.go\"\n",
+		`    body: "line one\n\"quoted\": yes"` + "\n",
+	} {
+		if !strings.Contains(string(yml), want) {
+			t.Errorf("yaml missing %q:\n%s", want, yml)
+		}
+	}
+
+	removeReviewExports(critPath)
+	for _, f := range []string{".crit.review.json", ".crit.review.yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); !os.IsNotExist(err) {
+			t.Errorf("%s not removed", f)
+		}
+	}
+}
+
+func TestJSONToYAML_Shapes(t *testing.T) {
+	got, err := jsonToYAML([]byte(`{"a":[],"b":{},"c":[1,[2,3],{"d":null}],"e f":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a: []\nb: {}\nc:\n  - 1\n  - - 2\n    - 3\n  - d: null\n\"e f\": true\n"
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSession_WriteFilesWritesExports(t *testing.T) {
+	s, _ := newTestServer(t)
+	sess := s.session.Load()
+	sess.exportFormats = []string{exportYAML}
+	sess.AddComment("test.md", 1, 1, "", "fix", "", "")
+	sess.WriteFiles()
+
+	critPath := sess.critJSONPath()
+	if _, err := os.Stat(reviewExportPath(critPath, exportYAML)); err != nil {
+		t.Fatalf("yaml export not written: %v", err)
+	}
+	if _, err := os.Stat(reviewExportPath(critPath, exportJSON)); !os.IsNotExist(err) {
+		t.Error("json export written without being enabled")
+	}
+	if !isReviewExportName(filepath.Base(reviewExportPath(critPath, exportYAML))) {
+		t.Error("export name not recognised")
+	}
+}
@@ -0,0 +1,105 @@
+package critcore
+
+import (
+	"strings"
+)
+
+// Markdown documents (agent plans in particular) often open with a YAML
+// frontmatter block holding status, owner and similar metadata. It is parsed
+// into top-level fields for /api/file, the frontend renders one commentable
+// block per field, and comments on a field record its key in the review file.
+
+// frontmatter is the leading ---...--- block of a markdown document.
+type frontmatter struct {
+	StartLine int                `json:"start_line"` // the opening ---
+	EndLine   int                `json:"end_line"`   // the closing --- (or ...)
+	Fields    []frontmatterField `json:"fields"`
+}
+
+// frontmatterField is one top-level key. Value is the scalar with quotes
+// removed, or the raw YAML below the key for nested and multi-line values.
+type frontmatterField struct {
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+// parseFrontmatter returns the document's frontmatter, or nil when it doesn't
+// open with a terminated --- block.
+func parseFrontmatter(content string) *frontmatter {
+	lines := strings.Split(content, "\n")
+	end := frontmatterEnd(lines)
+	if end < 0 {
+		return nil
+	}
+	fm := &frontmatter{StartLine: 1, EndLine: end + 1, Fields: []frontmatterField{}}
+	body := lines[1:end]
+	for _, n := range yamlNodes(strings.Join(body, "\n")) {
+		first := strings.TrimRight(body[n.start-1], " \t\r")
+		if first == "" || first[0] == ' ' || first == "-" || strings.HasPrefix(first, "- ") {
+			continue // not a top-level key
+		}
+		key, value, ok := yamlKeyValue(first)
+		if !ok || n.path != dataPathKey("$", key) {
+			continue
+		}
+		if value == "" || strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			value = strings.Join(body[n.start:n.end], "\n")
+		} else {
+			value = unquoteYAMLScalar(value)
+		}
+		fm.Fields = append(fm.Fields, frontmatterField{Key: key, Value: value, StartLine: n.start + 1, EndLine: n.end + 1})
+	}
+	return fm
+}
+
+// frontmatterEnd returns the index of the line closing the --- block that
+// opens lines, or -1.
+func frontmatterEnd(lines []string) int {
+	if strings.TrimRight(lines[0], " \t\r") != "---" {
+		return -1
+	}
+	for i := 1; i < len(lines); i++ {
+		if l := strings.TrimRight(lines[i], " \t\r"); l == "---" || l == "..." {
+			return i
+		}
+	}
+	return -1
+}
+
+func unquoteYAMLScalar(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// addFrontmatterSnapshot adds the parsed frontmatter to a /api/file response
+// for markdown files that have one.
+func addFrontmatterSnapshot(snap map[string]any, fileType, content string) {
+	if fileType != "markdown" {
+		return
+	}
+	if fm := parseFrontmatter(content); fm != nil {
+		snap["frontmatter"] = fm
+	}
+}
+
+// anchorFrontmatterComments sets FrontmatterKey on line comments that start
+// on a frontmatter field.
+func anchorFrontmatterComments(comments []Comment, fm *frontmatter) {
+	for i := range comments {
+		c := &comments[i]
+		c.FrontmatterKey = ""
+		if c.Scope == "file" || c.Side == "old" {
+			continue
+		}
+		for _, f := range fm.Fields {
+			if c.StartLine >= f.StartLine && c.StartLine <= f.EndLine {
+				c.FrontmatterKey = f.Key
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const testPlan = `---
+title: "Migrate auth"
+status: draft
+owners:
+  - alice
+  - bob
+notes: |
+  Two phases.
+---
+
+# Plan
+`
+
+func TestParseFrontmatter(t *testing.T) {
+	fm := parseFrontmatter(testPlan)
+	if fm == nil {
+		t.Fatal("parseFrontmatter returned nil")
+	}
+	if fm.StartLine != 1 || fm.EndLine != 9 {
+		t.Errorf("fences at %d-%d, want 1-9", fm.StartLine, fm.EndLine)
+	}
+	want := []frontmatterField{
+		{Key: "title", Value: "Migrate auth", StartLine: 2, EndLine: 2},
+		{Key: "status", Value: "draft", StartLine: 3, EndLine: 3},
+		{Key: "owners", Value: "  - alice\n  - bob", StartLine: 4, EndLine: 6},
+		{Key: "notes", Value: "  Two phases.", StartLine: 7, EndLine: 8},
+	}
+	if len(fm.Fields) != len(want) {
+		t.Fatalf("fields = %+v", fm.Fields)
+	}
+	for i, w := range want {
+		if fm.Fields[i] != w {
+			t.Errorf("field %d = %+v, want %+v", i, fm.Fields[i], w)
+		}
+	}
+
+	for _, content := range []string{"# No frontmatter\n", "---\nunterminated: yes\n", "text\n---\nkey: v\n---\n"} {
+		if parseFrontmatter(content) != nil {
+			t.Errorf("parseFrontmatter(%q) should be nil", content)
+		}
+	}
+}
+
+func TestFrontmatterSnapshotAndComments(t *testing.T) {
+	s, session := newTestServer(t)
+	session.Files[0].Content = testPlan
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/file?path=test.md", nil))
+	var resp struct {
+		Frontmatter *frontmatter `json:"frontmatter"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Frontmatter == nil || len(resp.Frontmatter.Fields) != 4 || resp.Frontmatter.Fields[1].Value != "draft" {
+		t.Fatalf("frontmatter = %+v", resp.Frontmatter)
+	}
+
+	session.AddComment("test.md", 5, 5, "", "bob is on leave", "", "")
+	session.AddComment("test.md", 11, 11, "", "body comment", "", "")
+	session.WriteFiles()
+	data, err := os.ReadFile(session.critJSONPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cj CritJSON
+	if err := json.Unmarshal(data, &cj); err != nil {
+		t.Fatal(err)
+	}
+	comments := cj.Files["test.md"].Comments
+	if comments[0].FrontmatterKey != "owners" || comments[1].FrontmatterKey != "" {
+		t.Errorf("frontmatter keys = %q, %q", comments[0].FrontmatterKey, comments[1].FrontmatterKey)
+	}
+}
@@ -0,0 +1,136 @@
+package critcore
+
+import (
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fsEventDebounce coalesces bursts of filesystem events (an editor writing a
+// temp file, renaming it over the original, then chmod-ing it) into a single
+// re-check of the watched files.
+const fsEventDebounce = 50 * time.Millisecond
+
+// fsPollFallback is the safety-net poll interval used while fsnotify is
+// active. It catches changes on filesystems that don't deliver events
+// (network mounts, some container bind mounts). Without fsnotify the watch
+// loops fall back to polling every second.
+const fsPollFallback = 5 * time.Second
+
+// fsWatcher wakes the watch loops as soon as a file of interest changes.
+//
+// Parent directories are watched rather than the files themselves: editors
+// that save via a temp file + rename (vim, JetBrains, most atomic writers)
+// replace the original inode, which would silently drop a per-file watch.
+// Directory watches keep firing and events are filtered by path.
+type fsWatcher struct {
+	w     *fsnotify.Watcher
+	paths map[string]bool
+	dirs  map[string]bool
+}
+
+// newFSWatcher returns a watcher, or nil if fsnotify is unavailable on this
+// platform. A nil *fsWatcher is valid and makes callers poll instead.
+func newFSWatcher() *fsWatcher {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("file events unavailable, falling back to polling", "err", err)
+		return nil
+	}
+	return &fsWatcher{w: w, paths: make(map[string]bool), dirs: make(map[string]bool)}
+}
+
+// add registers interest in path and watches its parent directory.
+// Safe to call repeatedly; already-watched directories are skipped.
+func (fw *fsWatcher) add(path string) {
+	if fw == nil || path == "" {
+		return
+	}
+	path = filepath.Clean(path)
+	fw.paths[path] = true
+	dir := filepath.Dir(path)
+	if fw.dirs[dir] {
+		return
+	}
+	if err := fw.w.Add(dir); err != nil {
+		// Directory may not exist yet (e.g. a deleted file); retry on next add.
+		return
+	}
+	fw.dirs[dir] = true
+}
+
+// addDir watches a directory for any change, not just specific files in it.
+func (fw *fsWatcher) addDir(dir string) {
+	if fw == nil || dir == "" {
+		return
+	}
+	dir = filepath.Clean(dir)
+	if fw.dirs[dir] {
+		return
+	}
+	if err := fw.w.Add(dir); err != nil {
+		return
+	}
+	fw.dirs[dir] = true
+	fw.paths[dir] = true
+}
+
+// relevant reports whether an event touches a registered path. Rename and
+// remove events on the original name count, since the replacement file's
+// create event follows under the same name.
+func (fw *fsWatcher) relevant(ev fsnotify.Event) bool {
+	name := filepath.Clean(ev.Name)
+	return fw.paths[name] || fw.paths[filepath.Dir(name)]
+}
+
+// events returns the event channel, or nil (which blocks forever in a select)
+// when fsnotify is not in use.
+func (fw *fsWatcher) events() <-chan fsnotify.Event {
+	if fw == nil {
+		return nil
+	}
+	return fw.w.Events
+}
+
+// errors returns the error channel, or nil when fsnotify is not in use.
+func (fw *fsWatcher) errors() <-chan error {
+	if fw == nil {
+		return nil
+	}
+	return fw.w.Errors
+}
+
+// pollInterval is how often the watch loop should poll given whether
+// fsnotify events are being delivered.
+func (fw *fsWatcher) pollInterval() time.Duration {
+	if fw == nil {
+		return 1 * time.Second
+	}
+	return fsPollFallback
+}
+
+func (fw *fsWatcher) close() {
+	if fw == nil {
+		return
+	}
+	fw.w.Close()
+}
+
+// watchSessionFiles registers every session file plus the review file with fw.
+func (s *Session) watchSessionFiles(fw *fsWatcher) {
+	if fw == nil {
+		return
+	}
+	s.mu.RLock()
+	paths := make([]string, 0, len(s.Files))
+	for _, f := range s.Files {
+		paths = append(paths, f.AbsPath)
+	}
+	s.mu.RUnlock()
+	for _, p := range paths {
+		fw.add(p)
+	}
+	fw.add(s.critJSONPath())
+}
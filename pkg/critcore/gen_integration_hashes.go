@@ -13,8 +13,11 @@ import (
 )
 
 func main() {
+	// go generate runs this in pkg/critcore; integrations/ is at the repo root,
+	// and the keys are its paths inside the binary's embed.
+	root := filepath.Join("..", "..")
 	hashes := map[string]string{}
-	err := filepath.Walk("integrations", func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(filepath.Join(root, "integrations"), func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -29,8 +32,12 @@ func main() {
 		if err != nil {
 			return err
 		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
 		h := sha256.Sum256(data)
-		hashes[path] = fmt.Sprintf("%x", h)
+		hashes[filepath.ToSlash(rel)] = fmt.Sprintf("%x", h)
 		return nil
 	})
 	if err != nil {
@@ -40,7 +47,7 @@ func main() {
 
 	var b strings.Builder
 	b.WriteString("// Code generated by gen_integration_hashes.go; DO NOT EDIT.\n\n")
-	b.WriteString("package main\n\n")
+	b.WriteString("package critcore\n\n")
 	b.WriteString("var integrationHashes = map[string]string{\n")
 
 	keys := make([]string, 0, len(hashes))
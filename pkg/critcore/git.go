@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"context"
@@ -865,19 +865,51 @@ func ParseUnifiedDiff(diff string) []DiffHunk {
 
 // WorkingTreeFingerprint returns a string representing the current working tree state.
 // Compare consecutive calls to detect changes.
-// .crit.json is excluded because it is crit's own output file, not a user edit.
-func WorkingTreeFingerprint() string {
+// Paths for which skip reports true (crit's own output, see isReviewArtifact)
+// are excluded because they are not user edits; skip may be nil.
+func WorkingTreeFingerprint(skip func(path string) bool) string {
 	cmd := exec.Command("git", "--no-optional-locks", "status", "--porcelain")
 	out, err := cmd.Output()
 	if err != nil {
 		return ""
 	}
-	lines := strings.Split(string(out), "\n")
+	return filterStatusLines(string(out), skip)
+}
+
+// filterStatusLines drops the lines of `git status --porcelain` or `sl
+// status` output whose path skip reports true for.
+func filterStatusLines(out string, skip func(path string) bool) string {
+	if skip == nil {
+		return out
+	}
+	lines := strings.Split(out, "\n")
 	filtered := lines[:0]
 	for _, line := range lines {
-		if !strings.HasSuffix(strings.TrimSpace(line), ".crit.json") {
+		_, path, _ := strings.Cut(strings.TrimSpace(line), " ")
+		path = strings.TrimSpace(path)
+		if _, to, ok := strings.Cut(path, " -> "); ok {
+			path = to
+		}
+		if path == "" || !skip(path) {
 			filtered = append(filtered, line)
 		}
 	}
 	return strings.Join(filtered, "\n")
 }
+
+// isReviewArtifact reports whether path is a review file named
+// reviewFileName or .crit.json, one of its exports (<name>.review.*), or an
+// interrupted atomic write of it.
+func isReviewArtifact(path, reviewFileName string) bool {
+	base := filepath.Base(strings.Trim(path, `"/`))
+	for _, name := range []string{defaultReviewFileName, reviewFileName} {
+		if name == "" {
+			continue
+		}
+		if base == name || strings.HasPrefix(base, strings.TrimSuffix(name, ".json")+".review.") ||
+			(strings.HasPrefix(base, name) && strings.HasSuffix(base, ".tmp")) {
+			return true
+		}
+	}
+	return false
+}
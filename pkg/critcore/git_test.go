@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"os"
@@ -288,16 +288,34 @@ func TestWorkingTreeFingerprint_RealRepo(t *testing.T) {
 	os.Chdir(dir)
 	defer os.Chdir(origDir)
 
-	fp1 := WorkingTreeFingerprint()
+	fp1 := WorkingTreeFingerprint(nil)
 
 	writeFile(t, filepath.Join(dir, "new.txt"), "hello")
-	fp2 := WorkingTreeFingerprint()
+	fp2 := WorkingTreeFingerprint(nil)
 
 	if fp1 == fp2 {
 		t.Error("fingerprint should change after adding a file")
 	}
 }
 
+func TestWorkingTreeFingerprint_SkipsCustomReviewFile(t *testing.T) {
+	dir := initTestRepo(t)
+	t.Chdir(dir)
+	s := &Session{RepoRoot: dir, OutputDir: dir, reviewFileName: "REVIEW.md"}
+
+	before := WorkingTreeFingerprint(s.isReviewArtifact)
+	for _, name := range []string{"REVIEW.md", "REVIEW.md.review.json", "REVIEW.md123.tmp", ".crit.json"} {
+		writeFile(t, filepath.Join(dir, name), "{}")
+	}
+	if after := WorkingTreeFingerprint(s.isReviewArtifact); after != before {
+		t.Errorf("writing the review file and its exports changed the fingerprint:\n%s", after)
+	}
+	writeFile(t, filepath.Join(dir, "REVIEW-notes.md"), "edit")
+	if WorkingTreeFingerprint(s.isReviewArtifact) == before {
+		t.Error("an edit next to the review file should change the fingerprint")
+	}
+}
+
 func TestCurrentBranch_RealRepo(t *testing.T) {
 	dir := initTestRepo(t)
 	origDir, _ := os.Getwd()
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"context"
@@ -69,7 +69,9 @@ func (g *GitVCS) CommitLog(baseRef, dir string) ([]CommitInfo, error) {
 	return CommitLog(baseRef, dir)
 }
 
-func (g *GitVCS) WorkingTreeFingerprint() string { return WorkingTreeFingerprint() }
+func (g *GitVCS) WorkingTreeFingerprint(skip func(path string) bool) string {
+	return WorkingTreeFingerprint(skip)
+}
 
 func (g *GitVCS) UntrackedFiles(dir string) ([]FileChange, error) {
 	return untrackedFilesInDir(dir)
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"bytes"
@@ -346,6 +346,7 @@ func mergeRootComment(cj *CritJSON, gc ghComment, replyMap map[int64][]ghComment
 		ID: commentID, StartLine: startLine, EndLine: gc.Line,
 		Body: gc.Body, Author: gc.User.Login, CreatedAt: gc.CreatedAt,
 		UpdatedAt: now, GitHubID: gc.ID,
+		Anchor: readAnchorFromDisk(gc.Path, startLine, gc.Line),
 	}
 
 	added := 0
@@ -411,7 +412,7 @@ func mergeGHComments(cj *CritJSON, ghComments []ghComment) int {
 
 // resolveReviewPath returns the full path to the review file for the current context.
 // Resolution order:
-//  1. If outputDir is set, return outputDir/.crit.json (explicit override)
+//  1. If outputDir is set, return the review file in it (explicit override, see outputReviewPath)
 //  2. Check daemon registry for running sessions matching this cwd
 //  3. If one daemon matches, use its ReviewPath
 //  4. If multiple daemons match, use the one matching current branch
@@ -422,7 +423,7 @@ func resolveReviewPath(outputDir string) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		return filepath.Join(abs, ".crit.json"), nil
+		return outputReviewPath(abs), nil
 	}
 
 	cwd, err := resolvedCWD()
@@ -697,7 +698,7 @@ func updateCritJSONWithGitHubIDs(critPath string, commentIDs map[string]int64, r
 		return err
 	}
 	var cj CritJSON
-	if err := json.Unmarshal(data, &cj); err != nil {
+	if err := unmarshalCritJSON(data, &cj); err != nil {
 		return err
 	}
 
@@ -741,7 +742,7 @@ func truncateStr(s string, n int) string {
 func loadCritJSON(critPath string) (CritJSON, error) {
 	var cj CritJSON
 	if data, err := os.ReadFile(critPath); err == nil {
-		if err := json.Unmarshal(data, &cj); err != nil {
+		if err := unmarshalCritJSON(data, &cj); err != nil {
 			return cj, fmt.Errorf("invalid existing review file: %w", err)
 		}
 	} else if os.IsNotExist(err) {
@@ -791,8 +792,9 @@ func appendComment(cj *CritJSON, filePath string, startLine, endLine int, body,
 		}
 	}
 
-	// Populate anchor from the file on disk.
+	// Populate anchor (and data path for JSON/YAML) from the file on disk.
 	anchor := readAnchorFromDisk(filePath, startLine, endLine)
+	dataPath := readDataPathFromDisk(filePath, startLine)
 
 	cf.Comments = append(cf.Comments, Comment{
 		ID:        randomCommentID(),
@@ -800,6 +802,7 @@ func appendComment(cj *CritJSON, filePath string, startLine, endLine int, body,
 		EndLine:   endLine,
 		Body:      body,
 		Anchor:    anchor,
+		DataPath:  dataPath,
 		Author:    author,
 		CreatedAt: now,
 		UpdatedAt: now,
@@ -817,6 +820,19 @@ func readAnchorFromDisk(filePath string, startLine, endLine int) string {
 	return extractAnchor(string(data), startLine, endLine)
 }
 
+// readDataPathFromDisk returns the data_path for a comment starting on line of
+// a JSON/YAML file, or "" for other files.
+func readDataPathFromDisk(filePath string, line int) string {
+	if !isDataFile(filePath) {
+		return ""
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return ""
+	}
+	return dataPathAt(dataNodes(filePath, string(data)), line)
+}
+
 // appendReply adds a reply to an existing comment in the CritJSON struct in memory.
 // Returns an error if the comment ID is not found or is ambiguous across files.
 // Searches both file comments and review_comments.
@@ -956,7 +972,7 @@ func findReviewFileByCommentID(commentID string, excludePath string) (string, er
 
 	var matchPath string
 	for _, de := range entries {
-		if !strings.HasSuffix(de.Name(), ".json") {
+		if !strings.HasSuffix(de.Name(), ".json") || isReviewExportName(de.Name()) {
 			continue
 		}
 		path := filepath.Join(dir, de.Name())
@@ -989,7 +1005,7 @@ func reviewFileContainsComment(data []byte, commentID string) bool {
 		return false
 	}
 	var cj CritJSON
-	if err := json.Unmarshal(data, &cj); err != nil {
+	if err := unmarshalCritJSON(data, &cj); err != nil {
 		return false
 	}
 	for _, c := range cj.ReviewComments {
@@ -1013,9 +1029,14 @@ func clearCritJSON(outputDir string) error {
 	if err != nil {
 		return err
 	}
+	if _, err := backupReviewFile(critPath, nil, loadShareConfig().BackupCount(), true); err != nil {
+		return fmt.Errorf("backing up review file: %w", err)
+	}
 	if err := os.Remove(critPath); err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	removeReviewJournal(critPath)
+	removeReviewHistory(critPath)
 	return nil
 }
 
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"encoding/json"
@@ -2267,6 +2267,18 @@ func TestAddCommentToCritJSON_PopulatesAnchor(t *testing.T) {
 	}
 }
 
+func TestMergeGHComments_PopulatesAnchor(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFile(t, "main.go", "package main\n\nfunc main() {\n}\n")
+	comments := []ghComment{{ID: 1, Path: "main.go", StartLine: 3, Line: 4, Side: "RIGHT", Body: "empty"}}
+
+	cj := CritJSON{Files: make(map[string]CritJSONFile)}
+	mergeGHComments(&cj, comments)
+	if got := cj.Files["main.go"].Comments[0].Anchor; got != "func main() {\n}" {
+		t.Errorf("Anchor = %q", got)
+	}
+}
+
 func TestBulkAddCommentsToCritJSON_PopulatesAnchor(t *testing.T) {
 	dir := initTestRepo(t)
 	oldDir, _ := os.Getwd()
@@ -0,0 +1,174 @@
+package critcore
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Values for the "gitignore" config key.
+const (
+	gitignoreIgnore = "ignore" // keep crit's artifacts out of the repository
+	gitignoreTrack  = "track"  // make sure they can be committed
+)
+
+// critArtifactPatterns are the repository paths crit may write to: the
+// project storage directory, the per-directory review file used by --output,
+// its structured exports (output_formats), and the temp files atomicWriteFile
+// leaves next to the review file when a write is interrupted.
+var critArtifactPatterns = []string{".crit/", ".crit.json", ".crit.review.*", critTempPattern}
+
+// critTempPattern matches the review file's interrupted atomic writes.
+const critTempPattern = ".crit.json*.tmp"
+
+const gitignoreHeader = "# crit review artifacts"
+
+// reviewArtifactPatterns returns critArtifactPatterns plus, when the session
+// writes its review file under a review_filename other than .crit.json, the
+// same patterns for that name.
+func reviewArtifactPatterns(reviewFileName string) []string {
+	patterns := slices.Clone(critArtifactPatterns)
+	if reviewFileName == "" || reviewFileName == defaultReviewFileName {
+		return patterns
+	}
+	return append(patterns, reviewFileName, strings.TrimSuffix(reviewFileName, ".json")+".review.*", reviewFileName+"*.tmp")
+}
+
+// isTempPattern reports whether p matches interrupted atomic writes, which
+// stay ignored under the "track" policy.
+func isTempPattern(p string) bool {
+	return strings.HasSuffix(p, "*.tmp")
+}
+
+// updateGitignore rewrites <repoRoot>/.gitignore to match policy. With
+// "ignore", missing artifact patterns are appended (and any negations we
+// added earlier are dropped). With "track", ignore lines for the artifacts are
+// removed and negations are added so a global excludes file can't hide them.
+// Any other policy is a no-op. reviewFileName is the session's review file
+// name, covered alongside .crit.json (empty for just the default). Returns
+// whether the file changed.
+func updateGitignore(repoRoot, policy, reviewFileName string) (bool, error) {
+	if policy != gitignoreIgnore && policy != gitignoreTrack {
+		return false, nil
+	}
+	path := filepath.Join(repoRoot, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	original := string(data)
+
+	patterns := reviewArtifactPatterns(reviewFileName)
+	var updated string
+	if policy == gitignoreIgnore {
+		updated = gitignoreWithPatterns(original, patterns, "")
+	} else {
+		temps := slices.DeleteFunc(slices.Clone(patterns), func(p string) bool { return !isTempPattern(p) })
+		tracked := slices.DeleteFunc(patterns, isTempPattern)
+		updated = gitignoreWithPatterns(original, temps, "")
+		updated = gitignoreWithPatterns(updated, tracked, "!")
+	}
+	if updated == original {
+		return false, nil
+	}
+	if err := atomicWriteFile(path, []byte(updated), 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// gitignoreWithPatterns returns content with each pattern present as
+// prefix+pattern, and the opposite form (negated vs. plain) of patterns
+// removed.
+// Patterns match with or without a leading slash.
+func gitignoreWithPatterns(content string, patterns []string, prefix string) string {
+	// Ignoring drops negations; tracking drops plain ignore lines.
+	dropNegated := prefix == ""
+
+	lines := strings.Split(content, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	present := make(map[string]bool)
+	dropped := false
+	kept := lines[:0]
+	for _, line := range lines {
+		p, neg := normalizeGitignoreLine(line)
+		if slices.Contains(patterns, p) {
+			if neg == dropNegated {
+				dropped = true
+				continue
+			}
+			present[p] = true
+		}
+		kept = append(kept, line)
+	}
+
+	var missing []string
+	for _, p := range patterns {
+		if !present[p] {
+			missing = append(missing, prefix+p)
+		}
+	}
+	if len(missing) == 0 {
+		if !dropped {
+			return content
+		}
+		return joinGitignoreLines(kept)
+	}
+	hasHeader := false
+	for _, line := range kept {
+		if strings.TrimSpace(line) == gitignoreHeader {
+			hasHeader = true
+		}
+	}
+	if !hasHeader {
+		if len(kept) > 0 && strings.TrimSpace(kept[len(kept)-1]) != "" {
+			kept = append(kept, "")
+		}
+		kept = append(kept, gitignoreHeader)
+	}
+	kept = append(kept, missing...)
+	return joinGitignoreLines(kept)
+}
+
+// normalizeGitignoreLine strips whitespace, a leading "!" (reported via neg)
+// and a leading "/" so "/.crit/" and ".crit/" compare equal.
+func normalizeGitignoreLine(line string) (pattern string, neg bool) {
+	p := strings.TrimSpace(line)
+	if strings.HasPrefix(p, "!") {
+		neg = true
+		p = p[1:]
+	}
+	return strings.TrimPrefix(p, "/"), neg
+}
+
+func joinGitignoreLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// applyGitignorePolicy updates the session repository's .gitignore at review
+// start. Failures are reported but never block the review.
+func applyGitignorePolicy(policy string, session *Session) {
+	if policy != "" && policy != gitignoreIgnore && policy != gitignoreTrack {
+		slog.Warn("unknown gitignore policy, leaving .gitignore alone", "gitignore", policy, "want", []string{gitignoreIgnore, gitignoreTrack})
+		return
+	}
+	if session.VCS == nil || session.RepoRoot == "" {
+		return
+	}
+	changed, err := updateGitignore(session.RepoRoot, policy, session.reviewFileName)
+	if err != nil {
+		slog.Warn("updating .gitignore", "err", err)
+		return
+	}
+	if changed {
+		slog.Info("updated .gitignore", "path", filepath.Join(session.RepoRoot, ".gitignore"), "gitignore", policy)
+	}
+}
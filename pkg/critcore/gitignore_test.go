@@ -0,0 +1,108 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateGitignore_IgnoreCreatesFile(t *testing.T) {
+	dir := t.TempDir()
+	changed, err := updateGitignore(dir, gitignoreIgnore, "")
+	if err != nil || !changed {
+		t.Fatalf("changed=%v err=%v", changed, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	want := "# crit review artifacts\n.crit/\n.crit.json\n.crit.review.*\n.crit.json*.tmp\n"
+	if string(data) != want {
+		t.Errorf(".gitignore = %q, want %q", data, want)
+	}
+}
+
+func TestUpdateGitignore_IgnoreAppendsMissingOnly(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".gitignore")
+	writeFile(t, path, "node_modules/\n/.crit/\n")
+
+	if _, err := updateGitignore(dir, gitignoreIgnore, ""); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "node_modules/\n/.crit/\n\n# crit review artifacts\n.crit.json\n.crit.review.*\n.crit.json*.tmp\n"
+	if string(data) != want {
+		t.Errorf(".gitignore = %q, want %q", data, want)
+	}
+
+	// Second run is a no-op.
+	changed, err := updateGitignore(dir, gitignoreIgnore, "")
+	if err != nil || changed {
+		t.Errorf("second run: changed=%v err=%v", changed, err)
+	}
+}
+
+func TestUpdateGitignore_TrackRemovesIgnoresAndNegates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".gitignore")
+	writeFile(t, path, "dist/\n# crit review artifacts\n.crit/\n.crit.json\n.crit.review.*\n")
+
+	if _, err := updateGitignore(dir, gitignoreTrack, ""); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	want := "dist/\n# crit review artifacts\n.crit.json*.tmp\n!.crit/\n!.crit.json\n!.crit.review.*\n"
+	if string(data) != want {
+		t.Errorf(".gitignore = %q, want %q", data, want)
+	}
+
+	// Switching back to ignore drops the negations.
+	if _, err := updateGitignore(dir, gitignoreIgnore, ""); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	want = "dist/\n# crit review artifacts\n.crit.json*.tmp\n.crit/\n.crit.json\n.crit.review.*\n"
+	if string(data) != want {
+		t.Errorf("after ignore: .gitignore = %q, want %q", data, want)
+	}
+}
+
+func TestUpdateGitignore_EmptyPolicyNoop(t *testing.T) {
+	dir := t.TempDir()
+	changed, err := updateGitignore(dir, "", "")
+	if err != nil || changed {
+		t.Errorf("changed=%v err=%v", changed, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gitignore")); !os.IsNotExist(err) {
+		t.Error("empty policy should not create .gitignore")
+	}
+}
+
+func TestUpdateGitignore_PreservesMissingTrailingNewlineWhenUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".gitignore")
+	writeFile(t, path, ".crit/\n.crit.json\n.crit.review.*\n.crit.json*.tmp")
+	changed, err := updateGitignore(dir, gitignoreIgnore, "")
+	if err != nil || changed {
+		t.Errorf("changed=%v err=%v", changed, err)
+	}
+}
+
+func TestUpdateGitignore_CustomReviewFileName(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := updateGitignore(dir, gitignoreIgnore, "REVIEW.md"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	want := "# crit review artifacts\n.crit/\n.crit.json\n.crit.review.*\n.crit.json*.tmp\nREVIEW.md\nREVIEW.md.review.*\nREVIEW.md*.tmp\n"
+	if string(data) != want {
+		t.Errorf(".gitignore = %q, want %q", data, want)
+	}
+
+	if _, err := updateGitignore(dir, gitignoreTrack, "REVIEW.md"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, ".gitignore"))
+	want = "# crit review artifacts\n.crit.json*.tmp\nREVIEW.md*.tmp\n!.crit/\n!.crit.json\n!.crit.review.*\n!REVIEW.md\n!REVIEW.md.review.*\n"
+	if string(data) != want {
+		t.Errorf("track: .gitignore = %q, want %q", data, want)
+	}
+}
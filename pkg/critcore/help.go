@@ -0,0 +1,187 @@
+package critcore
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The CLI reference lives in the tables below. printHelp renders them for
+// the terminal and `crit man` renders the same tables as a roff man page for
+// packagers, so a new command or flag only needs adding here.
+
+const helpSummary = "inline code review for AI agent workflows"
+
+type helpEntry struct {
+	usage, desc string
+}
+
+var helpCommands = []helpEntry{
+	{"crit", "Auto-detect changed files via git"},
+	{"crit <file|dir|url> [...]", "Review specific files, directories or http(s) URLs"},
+	{"crit compare <old> <new>", "Review <new> against <old> with comments on either side"},
+	{"crit resume [files...]", "Restart an interrupted review: round, verdict, edits and drafts"},
+	{"crit restore [--backup <n>]", "List the review file's backups, or put backup n back"},
+	{"crit stop [files...]", "Stop the daemon for current directory (and args)"},
+	{"crit stop --all", "Stop all daemons for current directory"},
+	{"crit add <file|dir> [...]", "Add files to the running review for this directory"},
+	{"crit comment <path>:<line[-end]> <body>", "Add a review comment"},
+	{"crit comment --reply-to <id> [--resolve] [--author <name>] <body>", "Reply to a comment"},
+	{"crit comment --json [--author <name>] [--output <dir>]", "Read comments from stdin as JSON"},
+	{"crit comment --clear", "Remove all comments from the review file"},
+	{"crit share [--encrypt] [--expires <dur>] <file> [file...]", "Share files to crit-web and print the URL"},
+	{"crit share revoke [<id>]", "Delete the share so its link stops working"},
+	{"crit fetch [--output <dir>]", "Fetch comments from crit-web into the review file"},
+	{"crit fetch <share> [<dir>]", "Download someone's shared review and review it locally"},
+	{"crit unpublish", "Remove a shared review from crit-web"},
+	{"crit serve-share [--listen <addr>] [--storage <dir>|s3://<bucket>]", "Run a self-hosted share service for CRIT_SHARE_URL"},
+	{"crit pull [--output <dir>] [pr-number]", "Fetch GitHub PR comments into the review file"},
+	{"crit push [--dry-run] [--event <type>] [-m <msg>] [-o <dir>] [pr-number]", "Post review comments to a GitHub PR"},
+	{"crit import --format sarif|rdjson <report> [path...]", "Add static-analysis findings to the review file as comments"},
+	{"crit triage [--output <dir>] [--plan <slug>]", "Step through open comments: resolve, change severity, edit or defer"},
+	{"crit export [--format html|pdf|json|yaml|history] [<file>|-]", "Write the review to a file, by default a self-contained HTML page"},
+	{"crit plan --name <slug> <file>", "Review a plan file (manages versioned copies)"},
+	{"crit plan --name <slug>", "Read plan from stdin"},
+	{"crit auth login", "Log in to crit-web via browser"},
+	{"crit auth logout", "Log out and revoke token"},
+	{"crit auth whoami", "Show current user info"},
+	{"crit install <agent> [--global]", "Install integration files for an AI coding tool (--global: user-wide)"},
+	{"crit status [--json]", "Print session info (review file, daemon, comments)"},
+	{"crit cleanup [--days N] [--force]", "Delete stale review files (default: 7 days)"},
+	{"crit history [--round N] [--json] <file>", "List past review rounds of a file, or print one"},
+	{"crit check", "Check if installed integrations are up to date"},
+	{"crit doctor [--fix]", "Diagnose config, integrations, browser opener and leftover daemon files"},
+	{"crit init [--yes]", "Set up this repo: agent integrations, .gitignore, review checklist"},
+	{"crit config [--generate]", "Show resolved configuration"},
+	{"crit update [--check] [--force]", "Update crit to the latest release (checksum-verified)"},
+	{"crit completion <bash|zsh|fish|powershell>", "Print a shell completion script"},
+	{"crit man", "Print this reference as a man page (crit.1)"},
+	{"crit help", "Show this help message"},
+}
+
+var helpOptions = []helpEntry{
+	{"-p, --port <port>", "Port to listen on (default: random)"},
+	{"-o, --output <dir>", "Output directory for review file"},
+	{"--no-open", "Don't auto-open browser"},
+	{"--no-ignore", "Disable all file ignore patterns"},
+	{"-q, --quiet", "Print only the review URL (nothing with --print-url=false)"},
+	{"--print-url=false", "With --quiet, print nothing at all"},
+	{"--share-url <url>", "Share service URL (e.g. https://crit.md or self-hosted)"},
+	{"--base-branch <branch>", "Base branch to diff against (overrides auto-detection)"},
+	{"--rev <revision>", "Review the files as they were at a git revision (same as <file>@<revision>)"},
+	{"--review-template <file>", "Go text/template for the prompt sent to the agent"},
+	{"--review-style <style>", "Agent prompt: verbose (default) or compact (one line per comment)"},
+	{"--notify", "Desktop notification when the agent completes a round"},
+	{"--bell", "Ring the terminal bell when the review finishes (bell_command: sound on every round)"},
+	{"--plain", "One status line per change instead of the live status line"},
+	{"--metrics", "Serve Prometheus metrics at /metrics"},
+	{"--keep-alive", "Keep the daemon running after an approved review, for the next round"},
+	{"--timeout <dur>", "Finish the review with the comments so far and exit after <dur>, e.g. 15m"},
+	{"--idle-timeout <dur>", "Shut down after this long without requests or open tabs (default 1h, 0 = never)"},
+	{"--log-level <level>", "Daemon log level: debug, info (default), warn or error"},
+	{"--log-format <format>", "Daemon log format: text (default) or json"},
+	{"--qr", "Print QR code of share URL (with crit share)"},
+	{"-v, --version", "Print version"},
+}
+
+var helpEnvironment = []helpEntry{
+	{"CRIT_SHARE_URL", "Override the share service URL"},
+	{"CRIT_PORT", "Override the default port"},
+	{"CRIT_NO_UPDATE_CHECK", "Disable update check on startup"},
+	{"CRIT_AUTH_TOKEN", "Override the auth token (skip login)"},
+	{"CRIT_NO_INTEGRATION_CHECK", "Disable integration staleness check"},
+}
+
+var helpFiles = []helpEntry{
+	{"~/.crit.config.json", "Global config"},
+	{".crit.config.json (in repo root)", "Project config"},
+}
+
+// helpAgents lists the names crit install accepts.
+func helpAgents() string {
+	return strings.Join(append(availableIntegrations(), "all"), ", ")
+}
+
+func printHelp() {
+	writeHelp(os.Stderr)
+}
+
+func writeHelp(w io.Writer) {
+	fmt.Fprintf(w, "crit — %s\n\nUsage:\n", helpSummary)
+	writeHelpTable(w, helpCommands, 42)
+	fmt.Fprintf(w, "\n  Agents:\n    %s\n\nOptions:\n", helpAgents())
+	options := make([]helpEntry, len(helpOptions))
+	for i, e := range helpOptions {
+		if strings.HasPrefix(e.usage, "--") {
+			e.usage = "    " + e.usage // line long flags up with the ones after a shorthand
+		}
+		options[i] = e
+	}
+	writeHelpTable(w, options, 27)
+	fmt.Fprintln(w, "\nEnvironment:")
+	writeHelpTable(w, helpEnvironment, 27)
+	fmt.Fprintln(w, "\nConfiguration:")
+	for _, f := range helpFiles {
+		fmt.Fprintf(w, "  %-16s %s\n", f.desc+":", f.usage)
+	}
+	fmt.Fprintln(w, `  agent_cmd        Shell command to send comments to an AI agent (e.g. "claude -p")`)
+	fmt.Fprintln(w, "  Run 'crit config' to see resolved configuration.")
+	fmt.Fprintln(w, "\nLearn more: https://crit.md")
+}
+
+// writeHelpTable prints entries in two columns, the first width wide.
+func writeHelpTable(w io.Writer, entries []helpEntry, width int) {
+	for _, e := range entries {
+		if len(e.usage) >= width {
+			fmt.Fprintf(w, "  %s  %s\n", e.usage, e.desc)
+			continue
+		}
+		fmt.Fprintf(w, "  %-*s %s\n", width, e.usage, e.desc)
+	}
+}
+
+// writeManPage renders the help tables as a crit(1) man page.
+func writeManPage(w io.Writer, version, date string) {
+	if date == "unknown" {
+		date = ""
+	}
+	fmt.Fprintf(w, ".TH CRIT 1 \"%s\" \"crit %s\" \"User Commands\"\n", date, roffEscape(version))
+	fmt.Fprintf(w, ".SH NAME\ncrit \\- %s\n", roffEscape(helpSummary))
+	fmt.Fprint(w, ".SH SYNOPSIS\n.B crit\n[\\fIoptions\\fR] [\\fIfile|dir\\fR ...]\n.br\n.B crit\n\\fIcommand\\fR [\\fIargs\\fR ...]\n")
+	fmt.Fprintf(w, ".SH DESCRIPTION\n%s\n", roffEscape("crit opens changed files (or the given files) in the browser for inline review, "+
+		"and writes the comments to a review file the agent reads in its next round."))
+	writeManSection(w, "COMMANDS", helpCommands)
+	writeManSection(w, "OPTIONS", helpOptions)
+	fmt.Fprintf(w, ".SH AGENTS\n%s\n", roffEscape("crit install accepts: "+helpAgents()))
+	writeManSection(w, "ENVIRONMENT", helpEnvironment)
+	writeManSection(w, "FILES", helpFiles)
+	fmt.Fprintf(w, ".SH SEE ALSO\n%s\n", roffEscape("crit config --help, https://crit.md"))
+}
+
+func writeManSection(w io.Writer, title string, entries []helpEntry) {
+	fmt.Fprintf(w, ".SH %s\n", title)
+	for _, e := range entries {
+		fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roffEscape(e.usage), roffEscape(e.desc))
+	}
+}
+
+// roffEscape makes s safe as roff text: backslashes and hyphens are escaped,
+// and a leading dot or quote can't be read as a request.
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+func runMan(args []string) {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: crit man > crit.1")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Prints the crit(1) man page, generated from the same tables as crit help.")
+		os.Exit(1)
+	}
+	writeManPage(os.Stdout, version, date)
+}
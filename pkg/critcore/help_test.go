@@ -0,0 +1,64 @@
+package critcore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteHelp_ListsEveryEntry(t *testing.T) {
+	var b strings.Builder
+	writeHelp(&b)
+	out := b.String()
+	for _, table := range [][]helpEntry{helpCommands, helpOptions, helpEnvironment} {
+		for _, e := range table {
+			if !strings.Contains(out, e.usage) || !strings.Contains(out, e.desc) {
+				t.Errorf("help is missing %q", e.usage)
+			}
+		}
+	}
+	if !strings.Contains(out, "  crit stop --all                            Stop all daemons") {
+		t.Errorf("commands should be aligned in one column:\n%s", out)
+	}
+	if !strings.Contains(out, "      --no-open               Don't auto-open browser") {
+		t.Errorf("long-only flags should line up after the shorthands:\n%s", out)
+	}
+}
+
+func TestWriteManPage(t *testing.T) {
+	var b strings.Builder
+	writeManPage(&b, "v1.2.3", "2026-01-02")
+	out := b.String()
+	if !strings.HasPrefix(out, `.TH CRIT 1 "2026-01-02" "crit v1.2.3" "User Commands"`) {
+		t.Errorf("unexpected header: %s", strings.SplitN(out, "\n", 2)[0])
+	}
+	for _, want := range []string{
+		".SH COMMANDS\n",
+		".B crit stop \\-\\-all\nStop all daemons for current directory\n",
+		".B \\-p, \\-\\-port <port>\n",
+		".B CRIT_PORT\n",
+		".B \\&.crit.config.json (in repo root)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("man page is missing %q", want)
+		}
+	}
+
+	b.Reset()
+	writeManPage(&b, "dev", "unknown")
+	if !strings.HasPrefix(b.String(), `.TH CRIT 1 "" "crit dev"`) {
+		t.Errorf("unknown build date should be left empty: %s", strings.SplitN(b.String(), "\n", 2)[0])
+	}
+}
+
+func TestRoffEscape(t *testing.T) {
+	for in, want := range map[string]string{
+		`--all`:   `\-\-all`,
+		`a\b`:     `a\eb`,
+		`.hidden`: `\&.hidden`,
+		`'quoted`: `\&'quoted`,
+	} {
+		if got := roffEscape(in); got != want {
+			t.Errorf("roffEscape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
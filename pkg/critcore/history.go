@@ -0,0 +1,156 @@
+package critcore
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// exportHistory is the output_formats value that writes a cumulative
+// markdown summary of every round to <review>.review.history.md: open comments
+// first, then what was resolved in each earlier round, struck through and
+// collapsed, so an agent can see what it already fixed and not regress it.
+const exportHistory = "history"
+
+// reviewHistoryPath returns where the history export for critPath is written.
+func reviewHistoryPath(critPath string) string {
+	return reviewExportPath(critPath, exportHistory) + ".md"
+}
+
+// renderReviewHistory builds the history document from the review file.
+// Comments keep the round they were raised in across carry-forward, so the
+// review file alone is enough to rebuild every round's section. Open region
+// comments on images embed their crop from cropsDir, relative to the
+// document; an empty cropsDir leaves them out. Open comments whose lines
+// drifted quote the text they were made on. The update time is shown in loc
+// (displayTime). When the review is shared, line comments link to their
+// lines on the share page (lineLink).
+func renderReviewHistory(cj CritJSON, cropsDir string, loc *time.Location) string {
+	current := max(cj.ReviewRound, 1)
+	linkBase := reviewLinkBase(cj)
+	var open []locatedComment
+	resolvedByRound := make(map[int][]locatedComment)
+	for _, e := range orderedComments(cj) {
+		if !e.c.Resolved {
+			open = append(open, e)
+			continue
+		}
+		round := min(max(e.c.ReviewRound, 1), current)
+		resolvedByRound[round] = append(resolvedByRound[round], e)
+	}
+
+	var b strings.Builder
+	b.WriteString("# Review history\n\n")
+	if updated := displayTime(cj.UpdatedAt, loc); updated != "" {
+		fmt.Fprintf(&b, "_Updated %s_\n\n", updated)
+	}
+	fmt.Fprintf(&b, "## Round %d (current)\n\n", current)
+	if len(open) == 0 && len(resolvedByRound[current]) == 0 {
+		b.WriteString("No comments.\n")
+	}
+	for _, e := range open {
+		b.WriteString(historyLine(e, current, linkBase) + "\n")
+		if e.c.Drifted && e.c.Anchor != "" {
+			writeHistoryQuote(&b, e.c.Anchor)
+		}
+		if name := regionCropName(e.file, e.c); name != "" && cropsDir != "" {
+			fmt.Fprintf(&b, "  ![%s %s](<%s/%s>)\n", e.file, compactLocation(e.c), cropsDir, name)
+		}
+	}
+	for _, e := range resolvedByRound[current] {
+		b.WriteString(historyLine(e, current, linkBase) + "\n")
+	}
+
+	for round := current - 1; round >= 1; round-- {
+		entries := resolvedByRound[round]
+		if len(entries) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n<details>\n<summary>Round %d — %d resolved comment%s</summary>\n\n", round, len(entries), plural(len(entries)))
+		for _, e := range entries {
+			b.WriteString(historyLine(e, current, linkBase) + "\n")
+		}
+		b.WriteString("\n</details>\n")
+	}
+	writeChecklistHistory(&b, cj.Checklist)
+	return b.String()
+}
+
+// writeChecklistHistory appends the review checklist as a task list.
+func writeChecklistHistory(b *strings.Builder, items []checklistItem) {
+	if len(items) == 0 {
+		return
+	}
+	b.WriteString("\n## Checklist\n\n")
+	for _, it := range items {
+		mark := " "
+		if it.Checked {
+			mark = "x"
+		}
+		fmt.Fprintf(b, "- [%s] %s\n", mark, it.Text)
+	}
+}
+
+// writeHistoryQuote appends a drifted comment's original text as a
+// blockquote nested under its list item.
+func writeHistoryQuote(b *strings.Builder, text string) {
+	b.WriteString("  Originally on:\n")
+	for line := range strings.SplitSeq(text, "\n") {
+		fmt.Fprintf(b, "  > %s\n", line)
+	}
+}
+
+// historyLine renders one comment as a task list item; resolved comments are
+// checked and struck through.
+func historyLine(e locatedComment, current int, linkBase string) string {
+	where := "review"
+	if e.file != "" {
+		where = fmt.Sprintf("`%s` %s", e.file, compactLocation(e.c))
+		if link := lineLink(linkBase, e.file, e.c); link != "" {
+			where = fmt.Sprintf("`%s` [%s](%s)", e.file, compactLocation(e.c), link)
+		}
+	}
+	text := fmt.Sprintf("%s (%s): %s", where, e.c.ID, oneLine(expandCommentRefs(e.c.Body, e.c.Refs)))
+	if e.c.Pinned && !e.c.Resolved {
+		text = "**Pinned** " + text
+	}
+	if e.c.Resolved {
+		return "- [x] ~~" + text + "~~"
+	}
+	if r := e.c.ReviewRound; r > 0 && r < current {
+		text += fmt.Sprintf(" _(open since round %d)_", r)
+	}
+	return "- [ ] " + text
+}
+
+// reviewLinkBase returns the URL comment links in the review's exports point
+// at: its live share, or "" when it isn't shared. The daemon's address
+// changes from run to run, so it isn't linked to.
+func reviewLinkBase(cj CritJSON) string {
+	sh, _ := currentShare(cj, time.Now())
+	return sh.URL
+}
+
+// lineLinkEscaper keeps the separators of a path:L12 value readable; they are
+// valid in a fragment as they are.
+var lineLinkEscaper = strings.NewReplacer("%2F", "/", "%3A", ":")
+
+// lineLink returns the share link base with lines=path:L12-L14 added to its
+// fragment, beside an encrypted share's key, or "" without a base and for
+// comments that aren't on new-side lines. The share page reads the parameter
+// and scrolls to the lines.
+func lineLink(base, path string, c Comment) string {
+	if base == "" || c.Region != nil || c.Scope == "file" || c.StartLine < 1 || c.Side == "old" {
+		return ""
+	}
+	lines := fmt.Sprintf("%s:L%d", path, c.StartLine)
+	if c.EndLine > c.StartLine {
+		lines += fmt.Sprintf("-L%d", c.EndLine)
+	}
+	link, frag, _ := strings.Cut(base, "#")
+	if frag != "" {
+		frag += "&"
+	}
+	return link + "#" + frag + "lines=" + lineLinkEscaper.Replace(url.QueryEscape(lines))
+}
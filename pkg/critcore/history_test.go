@@ -0,0 +1,116 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderReviewHistory(t *testing.T) {
+	cj := CritJSON{
+		ReviewRound: 3,
+		ReviewComments: []Comment{
+			{ID: "r1", Body: "split this PR", ReviewRound: 1, Resolved: true},
+		},
+		Files: map[string]CritJSONFile{
+			"auth.go": {Comments: []Comment{
+				{ID: "c1", StartLine: 12, EndLine: 14, Body: "check the\nerror", ReviewRound: 1},
+				{ID: "c2", StartLine: 3, EndLine: 3, Body: "rename", ReviewRound: 2, Resolved: true},
+				{ID: "c3", StartLine: 20, EndLine: 20, Body: "typo", ReviewRound: 3},
+			}},
+		},
+	}
+	want := "# Review history\n\n" +
+		"## Round 3 (current)\n\n" +
+		"- [ ] `auth.go` L12-L14 (c1): check the error _(open since round 1)_\n" +
+		"- [ ] `auth.go` L20 (c3): typo\n" +
+		"\n<details>\n<summary>Round 2 — 1 resolved comment</summary>\n\n" +
+		"- [x] ~~`auth.go` L3 (c2): rename~~\n" +
+		"\n</details>\n" +
+		"\n<details>\n<summary>Round 1 — 1 resolved comment</summary>\n\n" +
+		"- [x] ~~review (r1): split this PR~~\n" +
+		"\n</details>\n"
+	if got := renderReviewHistory(cj, "", nil); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRenderReviewHistory_DriftedQuote(t *testing.T) {
+	cj := CritJSON{Files: map[string]CritJSONFile{
+		"plan.md": {Comments: []Comment{
+			{ID: "c1", StartLine: 4, EndLine: 5, Body: "too vague", Anchor: "Ship it\nsoon", Drifted: true},
+			{ID: "c2", StartLine: 8, EndLine: 8, Body: "fixed", Anchor: "old", Drifted: true, Resolved: true},
+		}},
+	}}
+	got := renderReviewHistory(cj, "", nil)
+	want := "- [ ] `plan.md` L4-L5 (c1): too vague\n  Originally on:\n  > Ship it\n  > soon\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("got:\n%s\nwant it to contain:\n%s", got, want)
+	}
+	if strings.Contains(got, "> old") {
+		t.Error("resolved comment quoted")
+	}
+}
+
+func TestRenderReviewHistory_LineLinks(t *testing.T) {
+	cj := CritJSON{ShareURL: "https://crit.md/r/abc", Files: map[string]CritJSONFile{
+		"docs/my plan.md": {Comments: []Comment{
+			{ID: "c1", StartLine: 42, EndLine: 50, Body: "range"},
+			{ID: "c2", StartLine: 7, EndLine: 7, Body: "line"},
+			{ID: "c3", Scope: "file", Body: "whole file"},
+			{ID: "c4", StartLine: 3, EndLine: 3, Side: "old", Body: "removed"},
+		}},
+	}}
+	got := renderReviewHistory(cj, "", nil)
+	for _, want := range []string{
+		"- [ ] `docs/my plan.md` [L42-L50](https://crit.md/r/abc#lines=docs/my+plan.md:L42-L50) (c1): range\n",
+		"- [ ] `docs/my plan.md` [L7](https://crit.md/r/abc#lines=docs/my+plan.md:L7) (c2): line\n",
+		"- [ ] `docs/my plan.md` file (c3): whole file\n",
+		"- [ ] `docs/my plan.md` old L3 (c4): removed\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got:\n%s\nwant it to contain:\n%s", got, want)
+		}
+	}
+}
+
+func TestLineLink(t *testing.T) {
+	c := Comment{StartLine: 3, EndLine: 4}
+	if got, want := lineLink("https://crit.md/r/abc#key=k1", "a&b.md", c), "https://crit.md/r/abc#key=k1&lines=a%26b.md:L3-L4"; got != want {
+		t.Errorf("encrypted share: %q, want %q", got, want)
+	}
+	if got := lineLink("", "plan.md", c); got != "" {
+		t.Errorf("unshared review: %q, want no link", got)
+	}
+	expired := CritJSON{ShareURL: "https://crit.md/r/abc", ShareExpiresAt: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)}
+	if got := reviewLinkBase(expired); got != "" {
+		t.Errorf("expired share: link base %q, want none", got)
+	}
+}
+
+func TestRenderReviewHistory_Empty(t *testing.T) {
+	got := renderReviewHistory(CritJSON{}, "", nil)
+	if !strings.Contains(got, "## Round 1 (current)\n\nNo comments.\n") {
+		t.Errorf("got:\n%s", got)
+	}
+}
+
+func TestWriteReviewExports_History(t *testing.T) {
+	dir := t.TempDir()
+	critPath := filepath.Join(dir, "abc.json")
+	writeReviewExports(critPath, CritJSON{ReviewRound: 1}, []string{exportHistory}, nil)
+
+	path := filepath.Join(dir, "abc.review.history.md")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("history not written: %v", err)
+	}
+	if !isReviewExportName(filepath.Base(path)) {
+		t.Error("history export name not recognised")
+	}
+	removeReviewExports(critPath)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("history not removed")
+	}
+}
@@ -0,0 +1,119 @@
+package critcore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Lifecycle hooks run user commands when the daemon's review changes, so crit
+// can notify, archive or kick off builds without patching the binary. Each
+// command gets a hookEvent as JSON on stdin and CRIT_HOOK_EVENT in its
+// environment. Hooks run in the background: their exit status and output are
+// only logged, and they can't hold up the review.
+
+// hookCommands is the hooks config object. Like agent_cmd, it is global-only.
+type hookCommands struct {
+	OnCommentCreated string `json:"on_comment_created"` // a comment is added in the review UI or over the API
+	OnRoundComplete  string `json:"on_round_complete"`  // the agent signals round-complete
+	OnFinish         string `json:"on_finish"`          // the reviewer finishes a round
+}
+
+// Review events, shared by hooks and webhooks (webhooks.go). Hooks only
+// exist for the first three.
+const (
+	hookCommentCreated  = "comment_created"
+	hookRoundComplete   = "round_complete"
+	hookFinish          = "finish"
+	hookCommentUpdated  = "comment_updated"
+	hookCommentResolved = "comment_resolved"
+)
+
+// command returns the hook command configured for event, or "".
+func (h hookCommands) command(event string) string {
+	switch event {
+	case hookCommentCreated:
+		return h.OnCommentCreated
+	case hookRoundComplete:
+		return h.OnRoundComplete
+	case hookFinish:
+		return h.OnFinish
+	}
+	return ""
+}
+
+// hookTimeout bounds a hook run; a hung script is killed rather than leaked.
+const hookTimeout = 5 * time.Minute
+
+// hookEvent is the JSON a hook reads on stdin and a webhook receives.
+type hookEvent struct {
+	Event      string   `json:"event"` // one of the hook* event names
+	Time       string   `json:"time"`  // RFC 3339, when the event happened
+	ReviewFile string   `json:"review_file"`
+	RepoRoot   string   `json:"repo_root"`
+	Round      int      `json:"round"`
+	Path       string   `json:"path,omitempty"`    // comment events: the comment's file; "" for review comments
+	Comment    *Comment `json:"comment,omitempty"` // comment events
+	Approved   *bool    `json:"approved,omitempty"`
+	Unresolved *int     `json:"unresolved,omitempty"` // finish: open comments handed to the agent
+	Prompt     string   `json:"prompt,omitempty"`     // finish: the prompt the agent receives
+}
+
+// emitEvent fills in ev from sess and hands it to the GET /api/review/next
+// long-polls waiting on the session and the hook, webhooks and notifiers
+// (notifiers.go) configured for it.
+func (s *Server) emitEvent(sess *Session, ev hookEvent) {
+	if sess == nil {
+		return
+	}
+	ev.Time = time.Now().UTC().Format(time.RFC3339)
+	ev.ReviewFile = sess.critJSONPath()
+	ev.RepoRoot = sess.RepoRoot
+	ev.Round = sess.GetReviewRound()
+	if payload, err := json.Marshal(ev); err == nil {
+		sess.notify(SSEEvent{Type: reviewEventType, Content: string(payload)})
+	}
+	command := s.hooks.command(ev.Event)
+	if command == "" && len(s.webhooks) == 0 && len(s.notifiers) == 0 {
+		return
+	}
+	runHook(command, ev)
+	s.sendWebhooks(ev)
+	s.sendNotifications(sess, ev)
+}
+
+// runHook starts command with ev on stdin. An empty command does nothing.
+// Like agent_cmd and bell_command, the command is split on spaces, not run
+// through a shell.
+func runHook(command string, ev hookEvent) {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		slog.Error("hook: encoding event", "event", ev.Event, "err", err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+		cmd.Dir = ev.RepoRoot
+		cmd.Env = append(os.Environ(), "CRIT_HOOK_EVENT="+ev.Event)
+		cmd.Stdin = bytes.NewReader(payload)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			slog.Warn("hook failed", "event", ev.Event, "cmd", command, "err", err, "output", strings.TrimSpace(output.String()))
+			return
+		}
+		slog.Debug("hook ran", "event", ev.Event, "cmd", command, "output", strings.TrimSpace(output.String()))
+	}()
+}
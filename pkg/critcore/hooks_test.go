@@ -0,0 +1,111 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hookRecorder returns a hook command that writes its stdin and
+// CRIT_HOOK_EVENT to out, for tests to read back with readHookEvent.
+func hookRecorder(t *testing.T, out string) string {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$CRIT_HOOK_EVENT\" > \"$1.tmp\"\ncat >> \"$1.tmp\"\nmv \"$1.tmp\" \"$1\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return script + " " + out
+}
+
+// readHookEvent waits for the hook to have run and returns the event name
+// from its environment and the decoded stdin.
+func readHookEvent(t *testing.T, out string) (string, hookEvent) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(out)
+		if err == nil {
+			env, payload, _ := strings.Cut(string(data), "\n")
+			var ev hookEvent
+			if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+				t.Fatalf("hook stdin %q: %v", payload, err)
+			}
+			return env, ev
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("hook did not run")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestHooks_CommentCreated(t *testing.T) {
+	s, session := newTestServer(t)
+	out := filepath.Join(t.TempDir(), "event")
+	s.hooks.OnCommentCreated = hookRecorder(t, out)
+
+	req := httptest.NewRequest("POST", "/api/file/comments?path=test.md", strings.NewReader(`{"start_line":1,"end_line":2,"body":"Rename this"}`))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	env, ev := readHookEvent(t, out)
+	if env != hookCommentCreated || ev.Event != hookCommentCreated {
+		t.Errorf("event = %q (env %q)", ev.Event, env)
+	}
+	if ev.Path != "test.md" || ev.Comment == nil || ev.Comment.Body != "Rename this" || ev.Comment.StartLine != 1 {
+		t.Errorf("comment = %q %+v", ev.Path, ev.Comment)
+	}
+	if ev.RepoRoot != session.RepoRoot || ev.ReviewFile != session.critJSONPath() || ev.Round != 1 {
+		t.Errorf("session fields = %+v", ev)
+	}
+}
+
+func TestHooks_ReviewCommentAndFinish(t *testing.T) {
+	s, _ := newTestServer(t)
+	commentOut := filepath.Join(t.TempDir(), "comment")
+	finishOut := filepath.Join(t.TempDir(), "finish")
+	s.hooks = hookCommands{OnCommentCreated: hookRecorder(t, commentOut), OnFinish: hookRecorder(t, finishOut)}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/comments", strings.NewReader(`{"body":"Needs tests"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if _, ev := readHookEvent(t, commentOut); ev.Path != "" || ev.Comment == nil || ev.Comment.Body != "Needs tests" {
+		t.Errorf("review comment event = %+v", ev)
+	}
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/finish", nil))
+	_, ev := readHookEvent(t, finishOut)
+	if ev.Event != hookFinish || ev.Approved == nil || *ev.Approved || ev.Unresolved == nil || *ev.Unresolved != 1 || ev.Prompt == "" {
+		t.Errorf("finish event = %+v", ev)
+	}
+}
+
+func TestHooks_RoundComplete(t *testing.T) {
+	s, _ := newTestServer(t)
+	out := filepath.Join(t.TempDir(), "event")
+	s.hooks.OnRoundComplete = hookRecorder(t, out)
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/round-complete", nil))
+	if _, ev := readHookEvent(t, out); ev.Event != hookRoundComplete || ev.Comment != nil {
+		t.Errorf("event = %+v", ev)
+	}
+}
+
+func TestMergeConfigs_HooksGlobalOnly(t *testing.T) {
+	global := Config{Hooks: hookCommands{OnFinish: "notify-team"}}
+	project := Config{Hooks: hookCommands{OnFinish: "curl evil", OnCommentCreated: "curl evil"}}
+	merged := mergeConfigs(global, project, configPresence{})
+	if merged.Hooks != global.Hooks {
+		t.Errorf("hooks = %+v, project config must not override them", merged.Hooks)
+	}
+}
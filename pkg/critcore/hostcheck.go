@@ -0,0 +1,54 @@
+package critcore
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// A web page can point a DNS name it controls at 127.0.0.1 (DNS rebinding)
+// and then read the daemon's API as its own origin. The daemon only listens
+// on loopback, so it only answers requests addressed to a loopback name, and
+// browser requests only when their Origin is the page's own, which keeps
+// other local sites (a dev server on another port) out as well.
+
+// loopbackHosts are the Host names the daemon answers to.
+var loopbackHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// loopbackHost reports whether hostport (a Host header, with or without a
+// port) names this machine.
+func loopbackHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return slices.Contains(loopbackHosts, strings.ToLower(host))
+}
+
+// sameOrigin reports whether origin (an Origin header) is the review UI at
+// host, the request's Host. Requests without an Origin don't come from a
+// web page's scripts and pass.
+func sameOrigin(origin, host string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Scheme == "http" && strings.EqualFold(u.Host, host)
+}
+
+// admitHost answers 403 on w for requests that aren't addressed to the
+// daemon on loopback or come from another origin than the page's own and
+// corsOrigins.
+func admitHost(w http.ResponseWriter, r *http.Request, corsOrigins []string) bool {
+	origin := r.Header.Get("Origin")
+	if loopbackHost(r.Host) && (sameOrigin(origin, r.Host) || corsAllowed(corsOrigins, origin)) {
+		return true
+	}
+	slog.Warn("rejected request from another host", "host", r.Host, "origin", r.Header.Get("Origin"), "path", r.URL.Path)
+	http.Error(w, "Forbidden: crit only answers requests to localhost", http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,67 @@
+package critcore
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoopbackHost(t *testing.T) {
+	for _, ok := range []string{"localhost:4000", "LOCALHOST", "127.0.0.1:4000", "[::1]:4000", "::1"} {
+		if !loopbackHost(ok) {
+			t.Errorf("loopbackHost(%q) = false", ok)
+		}
+	}
+	for _, bad := range []string{"evil.example.com:4000", "127.0.0.1.nip.io:4000", "localhost.evil.com", "10.0.0.5:4000", ""} {
+		if loopbackHost(bad) {
+			t.Errorf("loopbackHost(%q) = true", bad)
+		}
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	tests := []struct {
+		origin, host string
+		want         bool
+	}{
+		{"", "localhost:4000", true},
+		{"http://localhost:4000", "localhost:4000", true},
+		{"http://127.0.0.1:4000", "127.0.0.1:4000", true},
+		{"http://localhost:3000", "localhost:4000", false}, // another local site
+		{"http://evil.example.com:4000", "localhost:4000", false},
+		{"https://localhost:4000", "localhost:4000", false},
+		{"null", "localhost:4000", false},
+	}
+	for _, tt := range tests {
+		if got := sameOrigin(tt.origin, tt.host); got != tt.want {
+			t.Errorf("sameOrigin(%q, %q) = %v, want %v", tt.origin, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestServer_RejectsOtherHosts(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.checkHost = true
+	get := func(host, origin string) int {
+		req := httptest.NewRequest("GET", "/api/session", nil)
+		req.Host = host
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := get("localhost:4000", ""); code != 200 {
+		t.Errorf("localhost: status = %d", code)
+	}
+	if code := get("127.0.0.1:4000", "http://127.0.0.1:4000"); code != 200 {
+		t.Errorf("same origin: status = %d", code)
+	}
+	// A rebinding page reaches the daemon under its own name.
+	if code := get("attacker.example.com:4000", "http://attacker.example.com:4000"); code != 403 {
+		t.Errorf("rebound host: status = %d, want 403", code)
+	}
+	if code := get("localhost:4000", "http://localhost:3000"); code != 403 {
+		t.Errorf("other origin: status = %d, want 403", code)
+	}
+}
@@ -0,0 +1,148 @@
+package critcore
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The review UI's strings live in locale bundles, locales/<lang>.json, served
+// by GET /api/i18n/<lang>. Without a language the config's `language` is
+// used, then the browser's Accept-Language, then English. Bundles are
+// served merged over English, so a translation can lag behind new strings.
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// defaultLang is the bundle every other one falls back to.
+const defaultLang = "en"
+
+// localeBundle is a locales/<lang>.json file and the /api/i18n response.
+type localeBundle struct {
+	Lang    string            `json:"lang"`
+	Locale  string            `json:"locale"`  // BCP 47 tag the UI formats dates and numbers with
+	Strings map[string]string `json:"strings"` // UI strings by key; {name} marks a placeholder
+}
+
+// locales returns the embedded bundles by language.
+var locales = sync.OnceValue(func() map[string]localeBundle {
+	entries, _ := localeFS.ReadDir("locales")
+	bundles := make(map[string]localeBundle, len(entries))
+	for _, e := range entries {
+		data, err := localeFS.ReadFile("locales/" + e.Name())
+		var b localeBundle
+		if err != nil || json.Unmarshal(data, &b) != nil {
+			continue
+		}
+		b.Lang = strings.TrimSuffix(e.Name(), path.Ext(e.Name()))
+		bundles[b.Lang] = b
+	}
+	return bundles
+})
+
+// availableLangs lists the languages with a bundle, sorted.
+func availableLangs() []string {
+	return slices.Sorted(maps.Keys(locales()))
+}
+
+// matchLang returns the bundle language for tag ("de", "de-AT", "pt_BR"): the
+// tag itself, or its primary language.
+func matchLang(tag string) (string, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	bundles := locales()
+	if _, ok := bundles[tag]; ok {
+		return tag, true
+	}
+	base, _, _ := strings.Cut(tag, "-")
+	if _, ok := bundles[base]; ok {
+		return base, true
+	}
+	return "", false
+}
+
+// negotiateLang picks the bundle language for an Accept-Language header,
+// highest quality first, or defaultLang when none match.
+func negotiateLang(acceptLanguage string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for part := range strings.SplitSeq(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if tag = strings.TrimSpace(tag); tag != "" && tag != "*" && q > 0 {
+			candidates = append(candidates, candidate{tag, q})
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	for _, c := range candidates {
+		if lang, ok := matchLang(c.tag); ok {
+			return lang
+		}
+	}
+	return defaultLang
+}
+
+// localeFor returns lang's bundle with English filling in missing strings.
+func localeFor(lang string) localeBundle {
+	bundles := locales()
+	b := bundles[lang]
+	merged := maps.Clone(bundles[defaultLang].Strings)
+	maps.Copy(merged, b.Strings)
+	b.Strings = merged
+	return b
+}
+
+// validateLanguage checks the language config key.
+func validateLanguage(lang string) error {
+	if lang == "" {
+		return nil
+	}
+	if _, ok := matchLang(lang); !ok {
+		return fmt.Errorf("language: no translation for %q (available: %s)", lang, strings.Join(availableLangs(), ", "))
+	}
+	return nil
+}
+
+// handleI18n serves GET /api/i18n[/<lang>].
+func (s *Server) handleI18n(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	lang := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/i18n"), "/")
+	if lang == "" {
+		lang = s.cfg.Language
+	}
+	if lang == "" {
+		lang = negotiateLang(r.Header.Get("Accept-Language"))
+	}
+	matched, ok := matchLang(lang)
+	if !ok {
+		http.Error(w, fmt.Sprintf("No translation for %q", lang), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Vary", "Accept-Language")
+	writeJSON(w, localeFor(matched))
+}
@@ -0,0 +1,96 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLocales_MatchEnglish(t *testing.T) {
+	bundles := locales()
+	en, ok := bundles[defaultLang]
+	if !ok || len(en.Strings) == 0 {
+		t.Fatal("no English bundle")
+	}
+	for lang, b := range bundles {
+		if b.Locale == "" {
+			t.Errorf("%s: no locale", lang)
+		}
+		for key := range b.Strings {
+			if _, ok := en.Strings[key]; !ok {
+				t.Errorf("%s: %q isn't an English string", lang, key)
+			}
+		}
+	}
+	if !slices.Contains(availableLangs(), "de") {
+		t.Errorf("availableLangs() = %v", availableLangs())
+	}
+}
+
+func TestNegotiateLang(t *testing.T) {
+	tests := []struct{ header, want string }{
+		{"", "en"},
+		{"de-AT,de;q=0.9,en;q=0.8", "de"},
+		{"fr-CH, fr;q=0.9, es;q=0.8", "es"},
+		{"en;q=0.5, es", "es"},
+		{"es_MX", "es"},
+		{"zz, *", "en"},
+		{"de;q=0", "en"},
+	}
+	for _, tt := range tests {
+		if got := negotiateLang(tt.header); got != tt.want {
+			t.Errorf("negotiateLang(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestLocaleFor_FallsBackToEnglish(t *testing.T) {
+	en := localeFor(defaultLang)
+	de := localeFor("de")
+	if len(de.Strings) != len(en.Strings) {
+		t.Errorf("de has %d strings, en %d", len(de.Strings), len(en.Strings))
+	}
+	if de.Strings["cancel"] == en.Strings["cancel"] {
+		t.Errorf("cancel not translated: %q", de.Strings["cancel"])
+	}
+}
+
+func TestValidateLanguage(t *testing.T) {
+	for _, ok := range []string{"", "de", "DE-at", "es"} {
+		if err := validateLanguage(ok); err != nil {
+			t.Errorf("validateLanguage(%q): %v", ok, err)
+		}
+	}
+	if err := validateLanguage("klingon"); err == nil || !strings.Contains(err.Error(), "en") {
+		t.Errorf("validateLanguage(klingon) = %v", err)
+	}
+}
+
+func TestHandleI18n(t *testing.T) {
+	s, _ := newTestServer(t)
+	get := func(path, acceptLanguage string) (int, localeBundle) {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		var b localeBundle
+		json.Unmarshal(w.Body.Bytes(), &b)
+		return w.Code, b
+	}
+
+	if code, b := get("/api/i18n", "de-DE,de;q=0.9"); code != 200 || b.Lang != "de" || b.Strings["approve"] != "Freigeben" {
+		t.Errorf("negotiated: %d %s %q", code, b.Lang, b.Strings["approve"])
+	}
+	if code, b := get("/api/i18n/es-MX", "de"); code != 200 || b.Lang != "es" {
+		t.Errorf("explicit: %d %s", code, b.Lang)
+	}
+	if code, _ := get("/api/i18n/zz", ""); code != 404 {
+		t.Errorf("unknown language: status = %d, want 404", code)
+	}
+	s.cfg.Language = "es"
+	if _, b := get("/api/i18n", "de"); b.Lang != "es" {
+		t.Errorf("configured: lang = %s, want es", b.Lang)
+	}
+}
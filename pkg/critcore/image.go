@@ -0,0 +1,223 @@
+package critcore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for image.DecodeConfig / image.Decode
+	_ "image/jpeg"
+	"image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Images passed in files mode (diagrams, screenshots) are reviewed as
+// pictures. A comment on one is a file-level comment with a region: a
+// rectangle in the image's own pixel coordinates. The history export
+// references the region and embeds a crop of it, written next to the review
+// file by writeRegionCrops.
+
+// imageRegion is a rectangle in image pixels, origin top left.
+type imageRegion struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (r imageRegion) String() string {
+	return fmt.Sprintf("%d,%d %dx%d", r.X, r.Y, r.Width, r.Height)
+}
+
+func isImagePath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg":
+		return true
+	}
+	return false
+}
+
+func isSVGPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".svg")
+}
+
+// imageSize returns an image's natural size, or 0, 0 when it can't be read
+// (WebP, which the standard library doesn't decode, or an SVG without a size).
+func imageSize(path string, data []byte) (int, int) {
+	if isSVGPath(path) {
+		return svgSize(data)
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
+
+// svgSize reads width/height from the root <svg> element, falling back to
+// its viewBox. Percentages and other relative units count as unknown.
+func svgSize(data []byte) (int, int) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		var w, h, viewBox string
+		for _, a := range el.Attr {
+			switch a.Name.Local {
+			case "width":
+				w = a.Value
+			case "height":
+				h = a.Value
+			case "viewBox":
+				viewBox = a.Value
+			}
+		}
+		if width, height := svgLength(w), svgLength(h); width > 0 && height > 0 {
+			return width, height
+		}
+		f := strings.Fields(strings.ReplaceAll(viewBox, ",", " "))
+		if len(f) == 4 {
+			width, _ := strconv.ParseFloat(f[2], 64)
+			height, _ := strconv.ParseFloat(f[3], 64)
+			return int(width + 0.5), int(height + 0.5)
+		}
+		return 0, 0
+	}
+}
+
+func svgLength(s string) int {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "px"), 64)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	return int(v + 0.5)
+}
+
+// validRegion reports whether r is a non-empty rectangle inside an image of
+// the given size. An unknown size (0) only checks the rectangle itself.
+func validRegion(r imageRegion, width, height int) error {
+	if r.X < 0 || r.Y < 0 || r.Width <= 0 || r.Height <= 0 {
+		return errors.New("region must have a non-negative origin and a positive size")
+	}
+	if width > 0 && height > 0 && (r.X+r.Width > width || r.Y+r.Height > height) {
+		return fmt.Errorf("region %s is outside the %dx%d image", r, width, height)
+	}
+	return nil
+}
+
+// regionCropsDir returns the directory crops for the review file at critPath
+// are written to.
+func regionCropsDir(critPath string) string {
+	return strings.TrimSuffix(critPath, ".json") + ".review.crops"
+}
+
+// regionCropName is the file name of c's crop, or "" when the image format
+// can't be cropped.
+func regionCropName(path string, c Comment) string {
+	if c.Region == nil {
+		return ""
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".svg":
+		return c.ID + ".svg"
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return c.ID + ".png"
+	}
+	return ""
+}
+
+// cropImage returns the region r of an image: a PNG for raster images, and
+// for SVGs a wrapper SVG that shows the original through a viewBox.
+func cropImage(path string, data []byte, r imageRegion) ([]byte, error) {
+	if isSVGPath(path) {
+		width, height := svgSize(data)
+		if width == 0 || height == 0 {
+			return nil, errors.New("SVG has no size")
+		}
+		svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="%d %d %d %d">`+
+			`<image width="%d" height="%d" href="data:image/svg+xml;base64,%s"/></svg>`+"\n",
+			r.Width, r.Height, r.X, r.Y, r.Width, r.Height, width, height, base64.StdEncoding.EncodeToString(data))
+		return []byte(svg), nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, errors.New("image can't be cropped")
+	}
+	b := img.Bounds()
+	rect := image.Rect(b.Min.X+r.X, b.Min.Y+r.Y, b.Min.X+r.X+r.Width, b.Min.Y+r.Y+r.Height).Intersect(b)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sub.SubImage(rect)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeRegionCrops rewrites the crops embedded by the history export: one
+// per open region comment, cut from the image as it is now. Like the
+// annotated export, the directory is rebuilt from scratch on each save.
+func (s *Session) writeRegionCrops(critPath string, cj CritJSON) {
+	if !slices.Contains(s.exportFormats, exportHistory) {
+		return
+	}
+
+	s.mu.RLock()
+	var targets []annotationTarget
+	for _, f := range s.Files {
+		if f.FileType == "image" && !f.Orphaned {
+			targets = append(targets, annotationTarget{path: f.Path, absPath: f.AbsPath})
+		}
+	}
+	s.mu.RUnlock()
+
+	dir := regionCropsDir(critPath)
+	if err := os.RemoveAll(dir); err != nil {
+		slog.Warn("clearing region crops", "err", err)
+		return
+	}
+	for _, t := range targets {
+		var data []byte
+		for _, c := range openComments(cj.Files[t.path].Comments) {
+			name := regionCropName(t.path, c)
+			if name == "" {
+				continue
+			}
+			if data == nil {
+				var err error
+				if data, err = os.ReadFile(t.absPath); err != nil {
+					break
+				}
+			}
+			crop, err := cropImage(t.path, data, *c.Region)
+			if err != nil {
+				slog.Warn("cropping image region", "path", t.path, "comment", c.ID, "err", err)
+				continue
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				slog.Warn("writing region crops", "err", err)
+				return
+			}
+			if err := atomicWriteFile(filepath.Join(dir, name), crop, 0644); err != nil {
+				slog.Warn("writing region crop", "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package critcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	img.Set(5, 5, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const testSVG = `<?xml version="1.0"?>
+<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 400 300"><rect width="10" height="10"/></svg>`
+
+func TestImageSize(t *testing.T) {
+	if w, h := imageSize("a.png", testPNG(t, 40, 30)); w != 40 || h != 30 {
+		t.Errorf("png size = %dx%d", w, h)
+	}
+	if w, h := imageSize("a.svg", []byte(testSVG)); w != 400 || h != 300 {
+		t.Errorf("svg viewBox size = %dx%d", w, h)
+	}
+	if w, h := imageSize("a.svg", []byte(`<svg width="120px" height="80" viewBox="0 0 1 1"/>`)); w != 120 || h != 80 {
+		t.Errorf("svg width/height = %dx%d", w, h)
+	}
+	if w, h := imageSize("a.svg", []byte(`<svg width="100%"/>`)); w != 0 || h != 0 {
+		t.Errorf("relative svg size = %dx%d, want unknown", w, h)
+	}
+}
+
+func TestValidRegion(t *testing.T) {
+	if err := validRegion(imageRegion{X: 10, Y: 10, Width: 30, Height: 20}, 40, 30); err != nil {
+		t.Errorf("in-bounds region rejected: %v", err)
+	}
+	if err := validRegion(imageRegion{X: 10, Y: 10, Width: 31, Height: 20}, 40, 30); err == nil {
+		t.Error("region past the right edge accepted")
+	}
+	if err := validRegion(imageRegion{Width: 0, Height: 5}, 0, 0); err == nil {
+		t.Error("empty region accepted")
+	}
+	if err := validRegion(imageRegion{X: 5000, Width: 5, Height: 5}, 0, 0); err != nil {
+		t.Errorf("unknown image size should only check the rectangle: %v", err)
+	}
+}
+
+func TestCropImage(t *testing.T) {
+	out, err := cropImage("a.png", testPNG(t, 40, 30), imageRegion{X: 4, Y: 4, Width: 10, Height: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 10 || b.Dy() != 6 {
+		t.Errorf("crop bounds = %v", b)
+	}
+	if r, _, _, _ := img.At(img.Bounds().Min.X+1, img.Bounds().Min.Y+1).RGBA(); r == 0 {
+		t.Error("crop should contain the red pixel at (5,5)")
+	}
+
+	svg, err := cropImage("a.svg", []byte(testSVG), imageRegion{X: 100, Y: 50, Width: 200, Height: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(svg); !strings.Contains(s, `viewBox="100 50 200 100"`) || !strings.Contains(s, `<image width="400" height="300"`) {
+		t.Errorf("svg crop = %s", s)
+	}
+}
+
+func TestRegionComment(t *testing.T) {
+	s, session := newTestServer(t)
+	session.exportFormats = []string{exportHistory}
+	dir := filepath.Dir(session.Files[0].AbsPath)
+	abs := filepath.Join(dir, "arch.png")
+	writeFile(t, abs, string(testPNG(t, 40, 30)))
+	f := &FileEntry{Path: "arch.png", AbsPath: abs, Status: "modified", FileType: "image", Comments: []Comment{}}
+	data, _ := os.ReadFile(abs)
+	f.setContent(data)
+	session.Files = append(session.Files, f)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/file?path=arch.png", nil))
+	if !strings.Contains(w.Body.String(), `"image":{"height":30,"width":40}`) {
+		t.Errorf("snapshot = %s", w.Body.String())
+	}
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/api/file/comments?path=arch.png", strings.NewReader(body)))
+		return w
+	}
+	if w := post(`{"body":"too big","region":{"x":0,"y":0,"width":50,"height":5}}`); w.Code != 400 {
+		t.Errorf("out-of-bounds region: status %d", w.Code)
+	}
+	w = post(`{"body":"label this box","region":{"x":4,"y":4,"width":10,"height":6}}`)
+	if w.Code != 201 {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var c Comment
+	if err := json.Unmarshal(w.Body.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Scope != "file" || c.Region == nil || *c.Region != (imageRegion{X: 4, Y: 4, Width: 10, Height: 6}) {
+		t.Errorf("comment = %+v", c)
+	}
+
+	session.WriteFiles()
+	crop := filepath.Join(regionCropsDir(session.critJSONPath()), c.ID+".png")
+	if _, err := os.Stat(crop); err != nil {
+		t.Errorf("crop not written: %v", err)
+	}
+	history, err := os.ReadFile(reviewHistoryPath(session.critJSONPath()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "![arch.png region 4,4 10x6](<" + filepath.Base(regionCropsDir(session.critJSONPath())) + "/" + c.ID + ".png>)"
+	if !strings.Contains(string(history), "`arch.png` region 4,4 10x6") || !strings.Contains(string(history), want) {
+		t.Errorf("history:\n%s", history)
+	}
+}
+
+func TestSessionFromImage(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "arch.png")
+	writeFile(t, path, string(testPNG(t, 40, 30)))
+
+	session, err := NewSessionFromFiles([]string{path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := session.Files[0]
+	if f.FileType != "image" || f.Content != "" || f.imageWidth != 40 || f.imageHeight != 30 {
+		t.Errorf("file = type %q, content %d bytes, size %dx%d", f.FileType, len(f.Content), f.imageWidth, f.imageHeight)
+	}
+}
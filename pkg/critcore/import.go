@@ -0,0 +1,414 @@
+package critcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// crit import turns static-analysis findings (SARIF 2.1.0, or reviewdog's
+// rdjson) into comments in the review file, so tool findings and human
+// comments reach the agent in one review. Imported comments carry the tool
+// name in Comment.Tool; re-importing the same report adds nothing.
+
+const (
+	importSARIF  = "sarif"
+	importRDJSON = "rdjson"
+)
+
+// finding is one static-analysis result, whatever format it came from.
+type finding struct {
+	tool      string
+	rule      string
+	path      string // as reported; resolved by importFindings
+	startLine int
+	endLine   int
+	message   string
+	severity  string // one of severityLevels, or ""
+}
+
+// body is the comment text for f.
+func (f finding) body() string {
+	if f.rule == "" {
+		return f.message
+	}
+	return fmt.Sprintf("`%s`: %s", f.rule, f.message)
+}
+
+// matches reports whether c is the comment tool made for f.
+func (f finding) matches(c Comment, tool string) bool {
+	return c.Tool == tool && c.StartLine == f.startLine && c.EndLine == f.endLine && c.Body == f.body()
+}
+
+// sarifLog is the part of a SARIF 2.1.0 log crit reads.
+type sarifLog struct {
+	Runs []struct {
+		Tool struct {
+			Driver struct {
+				Name  string      `json:"name"`
+				Rules []sarifRule `json:"rules"`
+			} `json:"driver"`
+		} `json:"tool"`
+		Results []struct {
+			RuleID    string       `json:"ruleId"`
+			RuleIndex *int         `json:"ruleIndex"`
+			Level     string       `json:"level"`
+			Message   sarifMessage `json:"message"`
+			Locations []struct {
+				Physical struct {
+					Artifact struct {
+						URI string `json:"uri"`
+					} `json:"artifactLocation"`
+					Region struct {
+						StartLine int `json:"startLine"`
+						EndLine   int `json:"endLine"`
+					} `json:"region"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	Default          struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown"`
+}
+
+// sarifSeverities maps SARIF result levels to crit severities.
+var sarifSeverities = map[string]string{"error": "major", "warning": "minor", "note": "nit"}
+
+// parseSARIF returns the findings in a SARIF log. Results without a file
+// location are skipped.
+func parseSARIF(data []byte) ([]finding, error) {
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, fmt.Errorf("parsing SARIF: %w", err)
+	}
+	var findings []finding
+	for _, run := range log.Runs {
+		rules := run.Tool.Driver.Rules
+		for _, r := range run.Results {
+			if len(r.Locations) == 0 || r.Locations[0].Physical.Artifact.URI == "" {
+				continue
+			}
+			msg, level := r.Message, r.Level
+			ruleIdx := slices.IndexFunc(rules, func(rule sarifRule) bool { return rule.ID == r.RuleID })
+			if r.RuleIndex != nil && *r.RuleIndex >= 0 && *r.RuleIndex < len(rules) {
+				ruleIdx = *r.RuleIndex
+			}
+			if ruleIdx >= 0 {
+				if msg.Text == "" && msg.Markdown == "" {
+					msg = rules[ruleIdx].ShortDescription
+				}
+				if level == "" {
+					level = rules[ruleIdx].Default.Level
+				}
+			}
+			if level == "" {
+				level = "warning" // SARIF's default
+			}
+			text := msg.Markdown
+			if text == "" {
+				text = msg.Text
+			}
+			loc := r.Locations[0].Physical
+			findings = append(findings, finding{
+				tool:      run.Tool.Driver.Name,
+				rule:      r.RuleID,
+				path:      loc.Artifact.URI,
+				startLine: loc.Region.StartLine,
+				endLine:   loc.Region.EndLine,
+				message:   strings.TrimSpace(text),
+				severity:  sarifSeverities[level],
+			})
+		}
+	}
+	return findings, nil
+}
+
+// rdjsonResult is reviewdog's Diagnostic Result JSON.
+type rdjsonResult struct {
+	Source      rdjsonSource `json:"source"`
+	Severity    string       `json:"severity"`
+	Diagnostics []struct {
+		Message  string `json:"message"`
+		Location struct {
+			Path  string `json:"path"`
+			Range struct {
+				Start struct {
+					Line int `json:"line"`
+				} `json:"start"`
+				End struct {
+					Line int `json:"line"`
+				} `json:"end"`
+			} `json:"range"`
+		} `json:"location"`
+		Severity string       `json:"severity"`
+		Source   rdjsonSource `json:"source"`
+		Code     struct {
+			Value string `json:"value"`
+		} `json:"code"`
+	} `json:"diagnostics"`
+}
+
+type rdjsonSource struct {
+	Name string `json:"name"`
+}
+
+// rdjsonSeverities maps rdjson severities to crit severities.
+var rdjsonSeverities = map[string]string{"ERROR": "major", "WARNING": "minor", "INFO": "nit"}
+
+// parseRDJSON returns the findings in an rdjson result.
+func parseRDJSON(data []byte) ([]finding, error) {
+	var result rdjsonResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("parsing rdjson: %w", err)
+	}
+	var findings []finding
+	for _, d := range result.Diagnostics {
+		if d.Location.Path == "" {
+			continue
+		}
+		tool, severity := d.Source.Name, d.Severity
+		if tool == "" {
+			tool = result.Source.Name
+		}
+		if severity == "" {
+			severity = result.Severity
+		}
+		findings = append(findings, finding{
+			tool:      tool,
+			rule:      d.Code.Value,
+			path:      d.Location.Path,
+			startLine: d.Location.Range.Start.Line,
+			endLine:   d.Location.Range.End.Line,
+			message:   strings.TrimSpace(d.Message),
+			severity:  rdjsonSeverities[severity],
+		})
+	}
+	return findings, nil
+}
+
+// importResult counts what importFindings did.
+type importResult struct {
+	added      int
+	duplicates int // already in the review file from an earlier import
+	skipped    int // outside the repo, or not on one of the requested paths
+}
+
+func (r importResult) summary() string {
+	s := "Imported " + pluralize(r.added, "finding")
+	if r.duplicates > 0 {
+		s += fmt.Sprintf(", %d already imported", r.duplicates)
+	}
+	if r.skipped > 0 {
+		s += fmt.Sprintf(", skipped %d on other files", r.skipped)
+	}
+	return s
+}
+
+// importFindings adds findings to cj as comments. Paths are resolved
+// against dir (file:// URIs and absolute paths must be inside it); when only
+// is non-empty, findings on other paths are skipped.
+func importFindings(cj *CritJSON, findings []finding, dir string, only []string) importResult {
+	var res importResult
+	for _, f := range findings {
+		path, ok := importPath(f.path, dir)
+		if !ok || (len(only) > 0 && !slices.Contains(only, path)) {
+			res.skipped++
+			continue
+		}
+		f.endLine = max(f.endLine, f.startLine)
+		if slices.ContainsFunc(cj.Files[path].Comments, func(c Comment) bool { return f.matches(c, f.tool) }) {
+			res.duplicates++
+			continue
+		}
+		if f.startLine <= 0 {
+			appendFileComment(cj, path, f.body(), f.tool)
+		} else {
+			appendComment(cj, path, f.startLine, f.endLine, f.body(), f.tool)
+		}
+		comments := cj.Files[path].Comments
+		c := &comments[len(comments)-1]
+		c.Tool = f.tool
+		c.Severity = f.severity
+		res.added++
+	}
+	return res
+}
+
+// importPath makes a reported path relative to dir, or reports false when it
+// points outside dir.
+func importPath(p, dir string) (string, bool) {
+	if u, err := url.Parse(p); err == nil && u.Scheme == "file" {
+		p = u.Path
+	} else if err == nil && u.Scheme == "" {
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			p = unescaped
+		}
+	}
+	p = filepath.FromSlash(p)
+	if filepath.IsAbs(p) {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return "", false
+		}
+		p = rel
+	}
+	p = filepath.Clean(p)
+	if p == "." || p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) || filepath.IsAbs(p) {
+		return "", false
+	}
+	return filepath.ToSlash(p), true
+}
+
+func printImportUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit import --format sarif|rdjson [--output <dir>] [--plan <slug>] <report|-> [path...]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Adds static-analysis findings to the review file as comments, tagged with the")
+	fmt.Fprintln(os.Stderr, "tool that reported them. With paths, only findings on those files are added.")
+	fmt.Fprintln(os.Stderr, "A .sarif report needs no --format. Findings already imported are skipped.")
+}
+
+// parseImportFlags splits args into the format, comment flags (--output,
+// --plan) and the report followed by path filters.
+func parseImportFlags(args []string) (string, commentFlags, error) {
+	var format string
+	var f commentFlags
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--format", "--output", "-o", "--plan":
+			if i+1 >= len(args) {
+				return "", f, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			switch arg {
+			case "--format":
+				format = args[i]
+			case "--plan":
+				f.plan = args[i]
+			default:
+				f.outputDir = args[i]
+			}
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return "", f, fmt.Errorf("unknown flag %q", arg)
+			}
+			f.args = append(f.args, arg)
+		}
+	}
+	if len(f.args) == 0 {
+		return "", f, fmt.Errorf("a report file is required")
+	}
+	if format == "" && strings.EqualFold(filepath.Ext(f.args[0]), ".sarif") {
+		format = importSARIF
+	}
+	if format != importSARIF && format != importRDJSON {
+		return "", f, fmt.Errorf("--format must be %s or %s", importSARIF, importRDJSON)
+	}
+	return format, f, nil
+}
+
+// readFindings parses the report at src ("-" for stdin) as format.
+func readFindings(format, src string) ([]finding, error) {
+	var data []byte
+	var err error
+	if src == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(src)
+	}
+	if err != nil {
+		return nil, err
+	}
+	findings, err := parseFindings(format, data)
+	for i := range findings {
+		if findings[i].tool == "" {
+			findings[i].tool = format // still tagged, so it isn't mistaken for a person
+		}
+	}
+	return findings, err
+}
+
+// parseFindings parses data as format: importSARIF, importRDJSON or
+// linterLineFormat.
+func parseFindings(format string, data []byte) ([]finding, error) {
+	switch format {
+	case importSARIF:
+		return parseSARIF(data)
+	case importRDJSON:
+		return parseRDJSON(data)
+	}
+	return parseLineFindings(data), nil
+}
+
+func runImport(args []string) {
+	if slices.Contains(args, "--help") || slices.Contains(args, "-h") {
+		printImportUsage()
+		return
+	}
+	format, f, err := parseImportFlags(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		printImportUsage()
+		os.Exit(1)
+	}
+	resolveCommentFlags(&f)
+	findings, err := readFindings(format, f.args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	critPath, err := resolveReviewPath(f.outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Reported paths are relative to the repo root, and appendComment
+	// reads anchors relative to the working directory.
+	dir, _ := os.Getwd()
+	if vcs := DetectVCS(""); vcs != nil {
+		if root, err := vcs.RepoRoot(); err == nil {
+			dir = root
+		}
+	}
+	var only []string
+	for _, p := range f.args[1:] {
+		abs, _ := filepath.Abs(p)
+		if rel, ok := importPath(abs, dir); ok {
+			only = append(only, rel)
+		}
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	cj, err := loadCritJSON(critPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	res := importFindings(&cj, findings, dir, only)
+	if res.added > 0 {
+		if err := saveCritJSON(critPath, cj); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Println(res.summary())
+}
@@ -0,0 +1,133 @@
+package critcore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const testSARIF = `{
+  "version": "2.1.0",
+  "runs": [{
+    "tool": {"driver": {"name": "semgrep", "rules": [
+      {"id": "sql-injection", "shortDescription": {"text": "Untrusted input in SQL"}, "defaultConfiguration": {"level": "error"}},
+      {"id": "unused-var"}
+    ]}},
+    "results": [
+      {"ruleId": "sql-injection", "message": {}, "locations": [{"physicalLocation": {"artifactLocation": {"uri": "src/db.go", "uriBaseId": "%SRCROOT%"}, "region": {"startLine": 12, "endLine": 14}}}]},
+      {"ruleId": "unused-var", "ruleIndex": 1, "level": "note", "message": {"text": "x is never used"}, "locations": [{"physicalLocation": {"artifactLocation": {"uri": "file:///repo/src/my%20file.go"}, "region": {"startLine": 3}}}]},
+      {"ruleId": "unused-var", "message": {"text": "no location"}}
+    ]
+  }]
+}`
+
+const testRDJSON = `{
+  "source": {"name": "golangci-lint"},
+  "severity": "WARNING",
+  "diagnostics": [
+    {"message": "error return value not checked", "location": {"path": "main.go", "range": {"start": {"line": 7}}}, "code": {"value": "errcheck"}},
+    {"message": "file is not gofmt-ed", "location": {"path": "util.go"}, "severity": "ERROR", "source": {"name": "gofmt"}}
+  ]
+}`
+
+func TestParseSARIF(t *testing.T) {
+	findings, err := parseSARIF([]byte(testSARIF))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []finding{
+		{tool: "semgrep", rule: "sql-injection", path: "src/db.go", startLine: 12, endLine: 14, message: "Untrusted input in SQL", severity: "major"},
+		{tool: "semgrep", rule: "unused-var", path: "file:///repo/src/my%20file.go", startLine: 3, message: "x is never used", severity: "nit"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings: %+v", len(findings), findings)
+	}
+	for i := range want {
+		if findings[i] != want[i] {
+			t.Errorf("finding %d = %+v, want %+v", i, findings[i], want[i])
+		}
+	}
+	if _, err := parseSARIF([]byte("not json")); err == nil {
+		t.Error("expected an error for invalid SARIF")
+	}
+}
+
+func TestParseRDJSON(t *testing.T) {
+	findings, err := parseRDJSON([]byte(testRDJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 {
+		t.Fatalf("got %d findings: %+v", len(findings), findings)
+	}
+	if f := findings[0]; f.tool != "golangci-lint" || f.rule != "errcheck" || f.startLine != 7 || f.severity != "minor" {
+		t.Errorf("first finding = %+v (source and severity should fall back to the result's)", f)
+	}
+	if f := findings[1]; f.tool != "gofmt" || f.startLine != 0 || f.severity != "major" {
+		t.Errorf("second finding = %+v", f)
+	}
+}
+
+func TestImportPath(t *testing.T) {
+	dir := filepath.FromSlash("/repo")
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"src/db.go", "src/db.go", true},
+		{"./src/../main.go", "main.go", true},
+		{"file:///repo/src/my%20file.go", "src/my file.go", true},
+		{"/repo/docs/plan.md", "docs/plan.md", true},
+		{"/elsewhere/x.go", "", false},
+		{"../x.go", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := importPath(tt.in, dir); got != tt.want || ok != tt.ok {
+			t.Errorf("importPath(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestImportFindings(t *testing.T) {
+	t.Chdir(t.TempDir()) // appendComment reads anchors from the working directory
+	findings, _ := parseRDJSON([]byte(testRDJSON))
+	findings = append(findings, finding{tool: "gosec", path: "/elsewhere/x.go", startLine: 1, message: "outside"})
+	cj := CritJSON{Files: map[string]CritJSONFile{}}
+
+	res := importFindings(&cj, findings, "/repo", nil)
+	if res != (importResult{added: 2, skipped: 1}) {
+		t.Errorf("first import = %+v", res)
+	}
+	c := cj.Files["main.go"].Comments[0]
+	if c.Body != "`errcheck`: error return value not checked" || c.Author != "golangci-lint" || c.Tool != "golangci-lint" || c.Severity != "minor" || c.StartLine != 7 || c.EndLine != 7 {
+		t.Errorf("line comment = %+v", c)
+	}
+	if c := cj.Files["util.go"].Comments[0]; c.Scope != "file" || c.Tool != "gofmt" {
+		t.Errorf("finding without a line should be a file comment: %+v", c)
+	}
+
+	if res := importFindings(&cj, findings, "/repo", nil); res != (importResult{duplicates: 2, skipped: 1}) {
+		t.Errorf("re-import = %+v", res)
+	}
+	if res := importFindings(&cj, findings, "/repo", []string{"util.go"}); res != (importResult{duplicates: 1, skipped: 2}) {
+		t.Errorf("filtered import = %+v", res)
+	}
+	if got := (importResult{added: 1, duplicates: 2, skipped: 3}).summary(); got != "Imported 1 finding, 2 already imported, skipped 3 on other files" {
+		t.Errorf("summary = %q", got)
+	}
+}
+
+func TestParseImportFlags(t *testing.T) {
+	format, f, err := parseImportFlags([]string{"results.SARIF", "-o", "out", "src/a.go"})
+	if err != nil || format != importSARIF || f.outputDir != "out" || len(f.args) != 2 {
+		t.Errorf("got %q, %+v, %v", format, f, err)
+	}
+	if format, _, err := parseImportFlags([]string{"--format", "rdjson", "-"}); err != nil || format != importRDJSON {
+		t.Errorf("rdjson from stdin: %q, %v", format, err)
+	}
+	for _, bad := range [][]string{{}, {"lint.json"}, {"--format", "checkstyle", "x.xml"}, {"--format"}, {"--verbose", "x.sarif"}} {
+		if _, _, err := parseImportFlags(bad); err == nil {
+			t.Errorf("parseImportFlags(%q) should fail", bad)
+		}
+	}
+}
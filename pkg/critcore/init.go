@@ -0,0 +1,213 @@
+package critcore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// crit init sets a repository up in one go: integrations for the agents
+// found on this machine, crit's .gitignore entries and optionally a review
+// checklist, all recorded in the project's .crit.config.json so teammates
+// get the same setup from `crit install` and the daemon.
+
+// initChecklistFile is the checklist init writes, relative to the repo root.
+// It is deliberately not one of critArtifactPatterns, so it gets committed.
+const initChecklistFile = ".crit.checklist.md"
+
+const initChecklistTemplate = `# Review checklist: one item per line, shown as checkboxes in every review.
+- Tests cover the change
+- Errors are handled and reported
+- Docs and help text are updated
+- No secrets, debug output or commented-out code left behind
+`
+
+type initOptions struct {
+	agents    []string
+	gitignore string // gitignoreIgnore, gitignoreTrack or "" to leave .gitignore alone
+	checklist bool
+}
+
+// askInitOptions asks about each step on out, reading answers from in.
+// An empty answer keeps the value from defaults.
+func askInitOptions(in *bufio.Reader, out io.Writer, defaults initOptions) initOptions {
+	opts := defaults
+	if len(opts.agents) > 0 && !askYesNo(in, out, fmt.Sprintf("Install crit for %s?", strings.Join(opts.agents, ", ")), true) {
+		opts.agents = nil
+	}
+	switch askLine(in, out, "Review files in git: ignore them, track them, or leave .gitignore alone? [ignore/track/skip]", defaults.gitignore) {
+	case gitignoreTrack:
+		opts.gitignore = gitignoreTrack
+	case "skip", "none", "no":
+		opts.gitignore = ""
+	default:
+		opts.gitignore = gitignoreIgnore
+	}
+	opts.checklist = askYesNo(in, out, "Add a review checklist template ("+initChecklistFile+")?", defaults.checklist)
+	return opts
+}
+
+func askLine(in *bufio.Reader, out io.Writer, question, def string) string {
+	fmt.Fprintf(out, "%s ", question)
+	line, _ := in.ReadString('\n')
+	if answer := strings.ToLower(strings.TrimSpace(line)); answer != "" {
+		return answer
+	}
+	return def
+}
+
+func askYesNo(in *bufio.Reader, out io.Writer, question string, def bool) bool {
+	prompt := " [y/N]"
+	if def {
+		prompt = " [Y/n]"
+	}
+	switch askLine(in, out, question+prompt, "") {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// initProject applies opts to the repository at repoRoot: writes the
+// checklist and .gitignore entries, records the choices in the project
+// config, and installs each agent with install. It reports progress on out.
+func initProject(repoRoot string, opts initOptions, install func(agent string), out io.Writer) error {
+	configPath := filepath.Join(repoRoot, ".crit.config.json")
+	if opts.checklist {
+		path := filepath.Join(repoRoot, initChecklistFile)
+		if _, err := os.Stat(path); err == nil {
+			fmt.Fprintf(out, "  Kept:      %s (already exists)\n", path)
+		} else if err := os.WriteFile(path, []byte(initChecklistTemplate), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		} else {
+			fmt.Fprintf(out, "  Created:   %s\n", path)
+		}
+	}
+	err := saveConfigFile(configPath, 0644, func(m map[string]json.RawMessage) error {
+		set := func(key string, value any) {
+			m[key], _ = json.Marshal(value)
+		}
+		if len(opts.agents) > 0 {
+			set("install_agents", opts.agents)
+		}
+		if opts.gitignore != "" {
+			set("gitignore", opts.gitignore)
+		}
+		if opts.checklist {
+			set("checklist_file", initChecklistFile)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "  Wrote:     %s\n", configPath)
+	if changed, err := updateGitignore(repoRoot, opts.gitignore, ""); err != nil {
+		return err
+	} else if changed {
+		fmt.Fprintf(out, "  Updated:   %s\n", filepath.Join(repoRoot, ".gitignore"))
+	}
+	for _, agent := range opts.agents {
+		install(agent)
+	}
+	return nil
+}
+
+func printInitUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit init [--yes] [--agents <a,b>] [--gitignore ignore|track|skip] [--checklist]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Sets up crit in this repository: installs integrations for the agents found")
+	fmt.Fprintln(os.Stderr, "here, adds crit's .gitignore entries and optionally a review checklist, and")
+	fmt.Fprintln(os.Stderr, "records the choices in .crit.config.json. Asks before each step unless --yes.")
+}
+
+// parseInitFlags returns the options given on the command line, and whether
+// to skip the questions.
+func parseInitFlags(args []string, detected []string) (initOptions, bool, error) {
+	opts := initOptions{agents: detected, gitignore: gitignoreIgnore}
+	yes := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--yes", "-y":
+			yes = true
+		case "--checklist":
+			opts.checklist = true
+		case "--agents", "--gitignore":
+			if i+1 >= len(args) {
+				return opts, false, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			if arg == "--agents" {
+				opts.agents = strings.Split(args[i], ",")
+				continue
+			}
+			switch args[i] {
+			case gitignoreIgnore, gitignoreTrack:
+				opts.gitignore = args[i]
+			case "skip":
+				opts.gitignore = ""
+			default:
+				return opts, false, fmt.Errorf("--gitignore must be ignore, track or skip")
+			}
+		default:
+			return opts, false, fmt.Errorf("unknown argument %q", arg)
+		}
+	}
+	for _, agent := range opts.agents {
+		if !slices.Contains(availableIntegrations(), agent) {
+			return opts, false, fmt.Errorf("unknown agent %q", agent)
+		}
+	}
+	return opts, yes, nil
+}
+
+func runInit(args []string) {
+	if slices.Contains(args, "--help") || slices.Contains(args, "-h") {
+		printInitUsage()
+		return
+	}
+	repoRoot, _ := os.Getwd()
+	if vcs := DetectVCS(""); vcs != nil {
+		if root, err := vcs.RepoRoot(); err == nil {
+			repoRoot = root
+		}
+	}
+	// crit install writes relative to the working directory.
+	if err := os.Chdir(repoRoot); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	home, _ := os.UserHomeDir()
+	detected := detectAgents(doctorEnv{projectDir: repoRoot, homeDir: home, goos: runtime.GOOS, hasCommand: commandExists})
+
+	opts, yes, err := parseInitFlags(args, detected)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		printInitUsage()
+		os.Exit(1)
+	}
+	if !yes && term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Printf("Setting up crit in %s\n\n", repoRoot)
+		opts = askInitOptions(bufio.NewReader(os.Stdin), os.Stdout, opts)
+		fmt.Println()
+	}
+	if len(opts.agents) == 0 {
+		fmt.Println("No agents found; run `crit install <agent>` later to add one.")
+	}
+	install := func(agent string) { installIntegration(agent, false, false) }
+	if err := initProject(repoRoot, opts, install, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("\nDone. Run `crit doctor` to check the rest of your setup.")
+}
@@ -0,0 +1,100 @@
+package critcore
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseInitFlags(t *testing.T) {
+	opts, yes, err := parseInitFlags(nil, []string{"claude-code"})
+	if err != nil || yes || !slices.Equal(opts.agents, []string{"claude-code"}) || opts.gitignore != gitignoreIgnore || opts.checklist {
+		t.Fatalf("defaults = %+v, %v, %v", opts, yes, err)
+	}
+	opts, yes, err = parseInitFlags([]string{"--yes", "--agents", "codex,cursor", "--gitignore", "skip", "--checklist"}, nil)
+	if err != nil || !yes || !slices.Equal(opts.agents, []string{"codex", "cursor"}) || opts.gitignore != "" || !opts.checklist {
+		t.Fatalf("flags = %+v, %v, %v", opts, yes, err)
+	}
+	for _, args := range [][]string{{"--agents", "vim"}, {"--gitignore", "maybe"}, {"--agents"}, {"--bogus"}} {
+		if _, _, err := parseInitFlags(args, nil); err == nil {
+			t.Errorf("parseInitFlags(%q) should fail", args)
+		}
+	}
+}
+
+func TestAskInitOptions(t *testing.T) {
+	defaults := initOptions{agents: []string{"claude-code"}, gitignore: gitignoreIgnore}
+	ask := func(answers string) initOptions {
+		return askInitOptions(bufio.NewReader(strings.NewReader(answers)), io.Discard, defaults)
+	}
+	if got := ask("\n\n\n"); !slices.Equal(got.agents, defaults.agents) || got.gitignore != gitignoreIgnore || got.checklist {
+		t.Errorf("empty answers = %+v, want defaults", got)
+	}
+	if got := ask("n\ntrack\ny\n"); got.agents != nil || got.gitignore != gitignoreTrack || !got.checklist {
+		t.Errorf("answers = %+v", got)
+	}
+	if got := ask("y\nskip\n"); got.gitignore != "" {
+		t.Errorf("skip = %+v", got)
+	}
+}
+
+func TestInitProject(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, ".crit.config.json")
+	if err := os.WriteFile(configPath, []byte(`{"author": "Team Bot"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var installed []string
+	opts := initOptions{agents: []string{"claude-code", "codex"}, gitignore: gitignoreIgnore, checklist: true}
+	if err := initProject(root, opts, func(agent string) { installed = append(installed, agent) }, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(installed, opts.agents) {
+		t.Errorf("installed %v, want %v", installed, opts.agents)
+	}
+	cfg, _, err := loadConfigFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Author != "Team Bot" || !slices.Equal(cfg.InstallAgents, opts.agents) || cfg.Gitignore != gitignoreIgnore || cfg.ChecklistFile != initChecklistFile {
+		t.Errorf("config = %+v", cfg)
+	}
+	items, err := loadChecklist(nil, cfg.ChecklistFile, root)
+	if err != nil || len(items) != 4 {
+		t.Errorf("checklist = %v, %v", items, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(root, ".gitignore"))
+	for _, pattern := range critArtifactPatterns {
+		if !strings.Contains(string(data), pattern) {
+			t.Errorf(".gitignore missing %q:\n%s", pattern, data)
+		}
+	}
+
+	// A second run keeps an edited checklist.
+	checklist := filepath.Join(root, initChecklistFile)
+	os.WriteFile(checklist, []byte("- Our own item\n"), 0644)
+	if err := initProject(root, opts, func(string) {}, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(checklist); string(data) != "- Our own item\n" {
+		t.Errorf("checklist overwritten: %q", data)
+	}
+}
+
+func TestInitProject_SkipLeavesGitignoreAlone(t *testing.T) {
+	root := t.TempDir()
+	if err := initProject(root, initOptions{}, func(string) {}, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".gitignore")); !os.IsNotExist(err) {
+		t.Errorf(".gitignore should not be created, stat err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, initChecklistFile)); !os.IsNotExist(err) {
+		t.Errorf("checklist should not be created, stat err = %v", err)
+	}
+}
@@ -1,6 +1,6 @@
 // Code generated by gen_integration_hashes.go; DO NOT EDIT.
 
-package main
+package critcore
 
 var integrationHashes = map[string]string{
 	"integrations/aider/CONVENTIONS.md":                    "36720ccab909115b21eded85ede5115ff6144c86fdc7abf0b7425b8b7249c473",
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 //go:generate go run gen_integration_hashes.go
 
@@ -1,6 +1,7 @@
-package main
+package critcore
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -57,7 +58,7 @@ func TestCheckInstalledIntegrations_UpToDate(t *testing.T) {
 
 	// Read the actual embedded content and write it to the destination
 	// so it matches the precomputed hash
-	embedded, err := integrationsFS.ReadFile("integrations/claude-code/skills/crit/SKILL.md")
+	embedded, err := fs.ReadFile(integrationsFS, "integrations/claude-code/skills/crit/SKILL.md")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -219,8 +220,7 @@ func TestCheckInstalledIntegrations_CacheSkipsOldVersions(t *testing.T) {
 			os.WriteFile(filepath.Join(cachePath, "SKILL.md"), []byte("old stale"), 0o644)
 		} else {
 			// Current content — use the real source file to get the correct hash
-			src := filepath.Join("integrations", "claude-code", "skills", "crit", "SKILL.md")
-			data, err := os.ReadFile(src)
+			data, err := fs.ReadFile(integrationsFS, "integrations/claude-code/skills/crit/SKILL.md")
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -271,7 +271,7 @@ func TestDetectInstalledIntegrations(t *testing.T) {
 	if len(sourceFiles) == 0 {
 		t.Fatal("no claude-code integration files defined")
 	}
-	sourceContent, err := integrationsFS.ReadFile(sourceFiles[0].source)
+	sourceContent, err := fs.ReadFile(integrationsFS, sourceFiles[0].source)
 	if err != nil {
 		t.Fatalf("reading embedded source: %v", err)
 	}
@@ -319,7 +319,7 @@ func TestDetectInstalledIntegrations_DedupsPerAgent(t *testing.T) {
 	if len(sourceFiles) == 0 {
 		t.Fatal("no claude-code integration files defined")
 	}
-	sourceContent, err := integrationsFS.ReadFile(sourceFiles[0].source)
+	sourceContent, err := fs.ReadFile(integrationsFS, sourceFiles[0].source)
 	if err != nil {
 		t.Fatalf("reading embedded source: %v", err)
 	}
@@ -0,0 +1,206 @@
+package critcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Comments can be turned into Jira issues for feedback that's "not now, but
+// track it": POST /api/comment/{id}/jira (or /api/review-comment/{id}/jira)
+// creates the issue through the Jira REST API and records its key on the
+// comment, so the review file and later rounds link to it.
+
+// envJiraToken overrides jira.token, so the token can stay out of the
+// config file.
+const envJiraToken = "CRIT_JIRA_TOKEN"
+
+// jiraConfig is the jira config object (global-only: it holds a token).
+type jiraConfig struct {
+	URL       string `json:"url"`             // site URL, e.g. "https://acme.atlassian.net"
+	Email     string `json:"email"`           // Jira Cloud account email; "" sends token as a bearer token (Server/Data Center)
+	Token     string `json:"token,omitempty"` // API token or personal access token; CRIT_JIRA_TOKEN overrides it
+	Project   string `json:"project"`         // project key new issues go to, e.g. "PLAT"
+	IssueType string `json:"issue_type"`      // default "Task"
+}
+
+// enabled reports whether issues can be created.
+func (j jiraConfig) enabled() bool {
+	return j.URL != "" && j.Project != "" && j.token() != ""
+}
+
+func (j jiraConfig) token() string {
+	if v := os.Getenv(envJiraToken); v != "" {
+		return v
+	}
+	return j.Token
+}
+
+// validateJira checks a jira config that has any field set.
+func validateJira(j jiraConfig) error {
+	if j == (jiraConfig{}) {
+		return nil
+	}
+	if u, err := url.Parse(j.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid jira.url %q (want your site URL, e.g. \"https://acme.atlassian.net\")", j.URL)
+	}
+	if j.Project == "" {
+		return fmt.Errorf("jira.project is required")
+	}
+	return nil
+}
+
+// issueTitle is the issue title for a comment: its first line, cut to
+// Jira's 255-character limit (Linear has none, but a title that long helps
+// nobody).
+func issueTitle(body string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(body), "\n")
+	line = strings.TrimSpace(strings.TrimLeft(line, "#>*- "))
+	if r := []rune(line); len(r) > 250 {
+		line = string(r[:250]) + "…"
+	}
+	return line
+}
+
+// jiraIssueDescription is the issue text for comment c on path ("" for a
+// review comment), in Jira wiki markup.
+func jiraIssueDescription(c Comment, path string) string {
+	var b strings.Builder
+	b.WriteString(c.Body)
+	if c.Quote != "" {
+		fmt.Fprintf(&b, "\n\nQuoted:\n{quote}%s{quote}", c.Quote)
+	}
+	b.WriteString("\n\n----\n" + issueOrigin(c, path))
+	return b.String()
+}
+
+// issueOrigin is the line closing an issue description, pointing back at
+// where comment c was made.
+func issueOrigin(c Comment, path string) string {
+	var b strings.Builder
+	b.WriteString("From a crit review")
+	if path != "" {
+		fmt.Fprintf(&b, " of %s", path)
+		switch {
+		case c.EndLine > c.StartLine:
+			fmt.Fprintf(&b, ", lines %d-%d", c.StartLine, c.EndLine)
+		case c.StartLine > 0:
+			fmt.Fprintf(&b, ", line %d", c.StartLine)
+		}
+	}
+	if c.Author != "" {
+		fmt.Fprintf(&b, ", by %s", c.Author)
+	}
+	b.WriteString(".")
+	return b.String()
+}
+
+// createJiraIssue creates an issue and returns its key and browse URL.
+// It uses REST API v2, which takes a plain-text description on both Jira
+// Cloud and Server/Data Center.
+func createJiraIssue(client *http.Client, j jiraConfig, summary, description string) (key, browseURL string, err error) {
+	issueType := j.IssueType
+	if issueType == "" {
+		issueType = "Task"
+	}
+	payload, err := json.Marshal(map[string]any{"fields": map[string]any{
+		"project":     map[string]string{"key": j.Project},
+		"issuetype":   map[string]string{"name": issueType},
+		"summary":     summary,
+		"description": description,
+	}})
+	if err != nil {
+		return "", "", err
+	}
+	base := strings.TrimRight(j.URL, "/")
+	req, err := http.NewRequest(http.MethodPost, base+"/rest/api/2/issue", bytes.NewReader(payload))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if j.Email != "" {
+		req.SetBasicAuth(j.Email, j.token())
+	} else {
+		req.Header.Set("Authorization", "Bearer "+j.token())
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("creating Jira issue: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusCreated {
+		return "", "", fmt.Errorf("creating Jira issue: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil || created.Key == "" {
+		return "", "", fmt.Errorf("creating Jira issue: unexpected response %q", body)
+	}
+	return created.Key, base + "/browse/" + created.Key, nil
+}
+
+// handleCommentJira creates a Jira issue from the comment with id (on path,
+// or a review comment when path is "").
+func (s *Server) handleCommentJira(w http.ResponseWriter, r *http.Request, path, id string) {
+	if !s.jira.enabled() {
+		http.Error(w, "Jira is not configured (set jira.url, jira.project and a token)", http.StatusNotImplemented)
+		return
+	}
+	s.handleCommentIssue(w, r, path, id, func(client *http.Client, c Comment, path string) (string, string, error) {
+		return createJiraIssue(client, s.jira, issueTitle(c.Body), jiraIssueDescription(c, path))
+	})
+}
+
+// handleCommentIssue creates an issue from the comment with id using create,
+// stores its key and URL on the comment (so a comment gets at most one issue,
+// whichever tracker made it) and answers with the updated comment. A comment
+// that already has an issue answers 409; a tracker error answers 502.
+func (s *Server) handleCommentIssue(w http.ResponseWriter, r *http.Request, path, id string, create func(client *http.Client, c Comment, path string) (key, issueURL string, err error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess := s.session.Load()
+	var c Comment
+	ok := false
+	if path == "" {
+		for _, rc := range sess.GetReviewComments() {
+			if rc.ID == id {
+				c, ok = rc, true
+			}
+		}
+	} else {
+		c, path, ok = sess.FindCommentByID(id, path)
+	}
+	if !ok {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	if c.IssueKey != "" {
+		http.Error(w, "Comment already has issue "+c.IssueKey, http.StatusConflict)
+		return
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	key, issueURL, err := create(client, c, path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	c, ok = sess.SetCommentIssue(path, id, key, issueURL)
+	if !ok {
+		// Deleted while the issue was being created; the issue stays.
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	s.emitEvent(sess, hookEvent{Event: hookCommentUpdated, Path: path, Comment: &c})
+	writeJSON(w, c)
+}
@@ -0,0 +1,128 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeJira answers issue creation with key, recording the request fields.
+func fakeJira(t *testing.T, key string, fields *map[string]any) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/2/issue" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		if user, pass, ok := r.BasicAuth(); !ok || user != "me@example.com" || pass != "tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Fields map[string]any `json:"fields"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		*fields = body.Fields
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": "10001", "key": key})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestValidateJira(t *testing.T) {
+	if err := validateJira(jiraConfig{}); err != nil {
+		t.Errorf("unset jira: %v", err)
+	}
+	if err := validateJira(jiraConfig{URL: "https://acme.atlassian.net", Project: "PLAT"}); err != nil {
+		t.Errorf("valid jira: %v", err)
+	}
+	for _, bad := range []jiraConfig{{URL: "acme.atlassian.net", Project: "PLAT"}, {URL: "https://acme.atlassian.net"}} {
+		if err := validateJira(bad); err == nil {
+			t.Errorf("validateJira(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestJiraIssueText(t *testing.T) {
+	if got := issueTitle("## Cache this lookup\n\nIt runs per request."); got != "Cache this lookup" {
+		t.Errorf("summary = %q", got)
+	}
+	if got := issueTitle(strings.Repeat("x", 300)); len([]rune(got)) != 251 {
+		t.Errorf("long summary has %d runes", len([]rune(got)))
+	}
+	desc := jiraIssueDescription(Comment{Body: "Cache this", StartLine: 3, EndLine: 5, Author: "ana"}, "api.go")
+	if !strings.HasPrefix(desc, "Cache this\n") || !strings.Contains(desc, "From a crit review of api.go, lines 3-5, by ana.") {
+		t.Errorf("description = %q", desc)
+	}
+}
+
+func TestCommentJira_CreatesIssueOnce(t *testing.T) {
+	var fields map[string]any
+	jira := fakeJira(t, "PLAT-42", &fields)
+	t.Setenv(envJiraToken, "")
+
+	s, session := newTestServer(t)
+	s.jira = jiraConfig{URL: jira.URL, Email: "me@example.com", Token: "tok", Project: "PLAT", IssueType: "Bug"}
+	c, _ := session.AddComment("test.md", 2, 2, "", "Track this later", "", "")
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/comment/"+c.ID+"/jira?path=test.md", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var got Comment
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if got.IssueKey != "PLAT-42" || got.IssueURL != jira.URL+"/browse/PLAT-42" {
+		t.Errorf("comment = %+v", got)
+	}
+	if fields["summary"] != "Track this later" || fields["issuetype"].(map[string]any)["name"] != "Bug" || fields["project"].(map[string]any)["key"] != "PLAT" {
+		t.Errorf("issue fields = %v", fields)
+	}
+	if stored, _, _ := session.FindCommentByID(c.ID, "test.md"); stored.IssueKey != "PLAT-42" {
+		t.Errorf("issue key not stored on the comment: %+v", stored)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/comment/"+c.ID+"/jira?path=test.md", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("second create: status = %d, want 409", w.Code)
+	}
+}
+
+func TestCommentJira_ReviewCommentAndErrors(t *testing.T) {
+	var fields map[string]any
+	jira := fakeJira(t, "PLAT-7", &fields)
+	t.Setenv(envJiraToken, "")
+
+	s, session := newTestServer(t)
+	c := session.AddReviewComment("Write a migration guide", "")
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/review-comment/"+c.ID+"/jira", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("unconfigured: status = %d, want 501", w.Code)
+	}
+
+	s.jira = jiraConfig{URL: jira.URL, Email: "me@example.com", Token: "wrong", Project: "PLAT"}
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/review-comment/"+c.ID+"/jira", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("rejected by Jira: status = %d, want 502", w.Code)
+	}
+
+	t.Setenv(envJiraToken, "tok")
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/review-comment/"+c.ID+"/jira", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if rc := session.GetReviewComments()[0]; rc.IssueKey != "PLAT-7" {
+		t.Errorf("review comment = %+v", rc)
+	}
+	if fields["issuetype"].(map[string]any)["name"] != "Task" {
+		t.Errorf("default issue type = %v", fields["issuetype"])
+	}
+}
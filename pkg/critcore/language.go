@@ -0,0 +1,89 @@
+package critcore
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// languageByExt maps file extensions to highlight.js language names. The
+// frontend highlights code files itself; the server's part is picking the
+// language, which it can do better than the browser for files whose name
+// doesn't say (Makefile, scripts with a shebang).
+var languageByExt = map[string]string{
+	".js": "javascript", ".jsx": "javascript", ".mjs": "javascript", ".cjs": "javascript",
+	".ts": "typescript", ".tsx": "typescript", ".mts": "typescript", ".cts": "typescript",
+	".go": "go", ".py": "python", ".pyi": "python", ".rb": "ruby", ".rs": "rust",
+	".sql": "sql", ".sh": "bash", ".bash": "bash", ".zsh": "bash",
+	".json": "json", ".yaml": "yaml", ".yml": "yaml",
+	".html": "xml", ".htm": "xml", ".xml": "xml", ".svg": "xml",
+	".css": "css", ".scss": "scss", ".less": "less",
+	".ex": "elixir", ".exs": "elixir", ".heex": "elixir",
+	".java": "java", ".kt": "kotlin", ".kts": "kotlin", ".scala": "scala",
+	".c": "c", ".h": "c", ".cpp": "cpp", ".cc": "cpp", ".hpp": "cpp", ".hh": "cpp",
+	".cs": "csharp", ".swift": "swift", ".m": "objectivec", ".php": "php",
+	".r": "r", ".lua": "lua", ".zig": "zig", ".nim": "nim", ".pl": "perl",
+	".toml": "ini", ".ini": "ini", ".tf": "hcl", ".proto": "protobuf",
+	".dart": "dart", ".erl": "erlang", ".hs": "haskell", ".clj": "clojure",
+	".vue": "xml", ".graphql": "graphql", ".nix": "nix", ".diff": "diff", ".patch": "diff",
+}
+
+// languageByName maps well-known extensionless (or oddly named) files.
+var languageByName = map[string]string{
+	"makefile": "makefile", "gnumakefile": "makefile",
+	"dockerfile": "dockerfile", "containerfile": "dockerfile",
+	"gemfile": "ruby", "rakefile": "ruby", "vagrantfile": "ruby",
+	"jenkinsfile": "groovy", "justfile": "makefile",
+	"cmakelists.txt": "cmake", "go.mod": "go", ".bashrc": "bash", ".zshrc": "bash",
+}
+
+// languageByInterpreter maps shebang interpreters.
+var languageByInterpreter = map[string]string{
+	"sh": "bash", "bash": "bash", "zsh": "bash", "dash": "bash",
+	"python": "python", "python3": "python", "node": "javascript", "deno": "typescript",
+	"ruby": "ruby", "perl": "perl", "php": "php", "lua": "lua", "elixir": "elixir",
+}
+
+// detectLanguage returns the highlight.js language for a code file, or "" when
+// it can't tell. The file name wins; the shebang line is the fallback.
+func detectLanguage(path, content string) string {
+	base := strings.ToLower(filepath.Base(path))
+	if lang, ok := languageByName[base]; ok {
+		return lang
+	}
+	if strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile") {
+		return "dockerfile"
+	}
+	if lang, ok := languageByExt[filepath.Ext(base)]; ok {
+		return lang
+	}
+	return languageFromShebang(content)
+}
+
+// languageFromShebang reads "#!/usr/bin/env python3" style first lines.
+func languageFromShebang(content string) string {
+	if !strings.HasPrefix(content, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(content[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		fields = fields[1:]
+		// env -S "python3 -u" and similar flags precede the interpreter.
+		for len(fields) > 0 && strings.HasPrefix(fields[0], "-") {
+			fields = fields[1:]
+		}
+		if len(fields) == 0 {
+			return ""
+		}
+		interp = filepath.Base(fields[0])
+	}
+	if lang, ok := languageByInterpreter[interp]; ok {
+		return lang
+	}
+	// python3.12, ruby3.2, ...
+	return languageByInterpreter[strings.TrimRight(interp, "0123456789.")]
+}
@@ -0,0 +1,44 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	cases := []struct {
+		path, content, want string
+	}{
+		{"server.go", "", "go"},
+		{"web/App.TSX", "", "typescript"},
+		{"Makefile", "", "makefile"},
+		{"deploy/Dockerfile.prod", "", "dockerfile"},
+		{"bin/release", "#!/usr/bin/env bash\nset -e\n", "bash"},
+		{"scripts/gen", "#!/usr/bin/env -S python3.12 -u\n", "python"},
+		{"tool", "#!/usr/local/bin/node\n", "javascript"},
+		{"notes.txt", "plain text", ""},
+		{"README.md", "# hi", ""},
+	}
+	for _, tc := range cases {
+		if got := detectLanguage(tc.path, tc.content); got != tc.want {
+			t.Errorf("detectLanguage(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestHandleFile_IncludesLanguage(t *testing.T) {
+	s, session := newTestServer(t)
+	session.Files[0].Path = "main.py"
+	session.Files[0].FileType = "code"
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/file?path=main.py", nil))
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["language"] != "python" {
+		t.Errorf("language = %v, want python", resp["language"])
+	}
+}
@@ -0,0 +1,230 @@
+package critcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Linear is the other issue tracker comments can be turned into:
+// POST /api/comment/{id}/linear (or /api/review-comment/{id}/linear) creates
+// the issue through Linear's GraphQL API and records its identifier
+// ("ENG-123") on the comment, like the Jira action in jira.go does.
+
+const (
+	defaultLinearAPIURL = "https://api.linear.app/graphql"
+	// envLinearAPIKey overrides linear.api_key, so the key can stay out of
+	// the config file.
+	envLinearAPIKey = "CRIT_LINEAR_API_KEY"
+)
+
+// linearConfig is the linear config object (global-only: it holds a key).
+type linearConfig struct {
+	APIKey  string        `json:"api_key,omitempty"` // personal API key; CRIT_LINEAR_API_KEY overrides it
+	Team    string        `json:"team"`              // team key ("ENG") or ID new issues go to
+	Project string        `json:"project"`           // optional project name or ID
+	Routes  []linearRoute `json:"routes,omitempty"`  // per-path team/project, first match wins
+}
+
+// linearRoute sends issues from comments on matching files to another team
+// or project. Review comments aren't on a file and always use the defaults.
+type linearRoute struct {
+	Match   string `json:"match"`   // ignore_patterns syntax, e.g. "frontend/" or "*.sql"
+	Team    string `json:"team"`    // "" keeps linear.team
+	Project string `json:"project"` // "" keeps linear.project
+}
+
+// enabled reports whether issues can be created.
+func (l linearConfig) enabled() bool {
+	return l.Team != "" && l.apiKey() != ""
+}
+
+func (l linearConfig) apiKey() string {
+	if v := os.Getenv(envLinearAPIKey); v != "" {
+		return v
+	}
+	return l.APIKey
+}
+
+// target is the team and project for an issue from a comment on path.
+func (l linearConfig) target(path string) (team, project string) {
+	team, project = l.Team, l.Project
+	if path == "" {
+		return team, project
+	}
+	for _, r := range l.Routes {
+		if !matchPattern(r.Match, path) {
+			continue
+		}
+		if r.Team != "" {
+			team = r.Team
+		}
+		if r.Project != "" {
+			project = r.Project
+		}
+		break
+	}
+	return team, project
+}
+
+// validateLinear checks a linear config that has any field set.
+func validateLinear(l linearConfig) error {
+	if l.APIKey == "" && l.Team == "" && l.Project == "" && len(l.Routes) == 0 {
+		return nil
+	}
+	if l.Team == "" {
+		return fmt.Errorf("linear.team is required")
+	}
+	for i, r := range l.Routes {
+		if r.Match == "" {
+			return fmt.Errorf("linear.routes[%d]: match is required", i)
+		}
+		if r.Team == "" && r.Project == "" {
+			return fmt.Errorf("linear.routes[%d]: set team or project", i)
+		}
+	}
+	return nil
+}
+
+// linearIssueDescription is the issue text for comment c on path ("" for a
+// review comment), in Markdown.
+func linearIssueDescription(c Comment, path string) string {
+	var b strings.Builder
+	b.WriteString(c.Body)
+	if c.Quote != "" {
+		b.WriteString("\n\n> " + strings.ReplaceAll(c.Quote, "\n", "\n> "))
+	}
+	b.WriteString("\n\n---\n\n" + issueOrigin(c, path))
+	return b.String()
+}
+
+// linearIDPattern matches the UUIDs Linear uses as IDs, to tell them from
+// team keys and project names.
+var linearIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// linearClient runs GraphQL operations against endpoint.
+type linearClient struct {
+	http     *http.Client
+	endpoint string
+	apiKey   string
+}
+
+// do runs query and decodes its data into out. GraphQL errors are returned
+// as errors, whatever the HTTP status.
+func (lc linearClient) do(query string, vars map[string]any, out any) error {
+	payload, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, lc.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Personal API keys go in as-is; only OAuth tokens take "Bearer ".
+	req.Header.Set("Authorization", lc.apiKey)
+	resp, err := lc.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%s", result.Errors[0].Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+// lookupID resolves a team key or project name to its ID with query, which
+// takes $value and returns nodes under field. IDs are returned unchanged.
+func (lc linearClient) lookupID(what, field, query, value string) (string, error) {
+	if linearIDPattern.MatchString(value) {
+		return value, nil
+	}
+	var data map[string]struct {
+		Nodes []struct {
+			ID string `json:"id"`
+		} `json:"nodes"`
+	}
+	if err := lc.do(query, map[string]any{"value": value}, &data); err != nil {
+		return "", err
+	}
+	if nodes := data[field].Nodes; len(nodes) > 0 {
+		return nodes[0].ID, nil
+	}
+	return "", fmt.Errorf("no Linear %s %q", what, value)
+}
+
+const (
+	linearTeamQuery    = `query($value: String!) { teams(filter: {key: {eq: $value}}) { nodes { id } } }`
+	linearProjectQuery = `query($value: String!) { projects(filter: {name: {eq: $value}}) { nodes { id } } }`
+	linearCreateIssue  = `mutation($input: IssueCreateInput!) { issueCreate(input: $input) { success issue { identifier url } } }`
+)
+
+// createLinearIssue creates an issue in team (and project, if set) and
+// returns its identifier and URL.
+func createLinearIssue(lc linearClient, team, project, title, description string) (key, issueURL string, err error) {
+	teamID, err := lc.lookupID("team", "teams", linearTeamQuery, team)
+	if err != nil {
+		return "", "", fmt.Errorf("creating Linear issue: %w", err)
+	}
+	input := map[string]any{"teamId": teamID, "title": title, "description": description}
+	if project != "" {
+		projectID, err := lc.lookupID("project", "projects", linearProjectQuery, project)
+		if err != nil {
+			return "", "", fmt.Errorf("creating Linear issue: %w", err)
+		}
+		input["projectId"] = projectID
+	}
+	var data struct {
+		IssueCreate struct {
+			Success bool `json:"success"`
+			Issue   struct {
+				Identifier string `json:"identifier"`
+				URL        string `json:"url"`
+			} `json:"issue"`
+		} `json:"issueCreate"`
+	}
+	if err := lc.do(linearCreateIssue, map[string]any{"input": input}, &data); err != nil {
+		return "", "", fmt.Errorf("creating Linear issue: %w", err)
+	}
+	if !data.IssueCreate.Success || data.IssueCreate.Issue.Identifier == "" {
+		return "", "", fmt.Errorf("creating Linear issue: not created")
+	}
+	return data.IssueCreate.Issue.Identifier, data.IssueCreate.Issue.URL, nil
+}
+
+// handleCommentLinear creates a Linear issue from the comment with id (on
+// path, or a review comment when path is "").
+func (s *Server) handleCommentLinear(w http.ResponseWriter, r *http.Request, path, id string) {
+	if !s.linear.enabled() {
+		http.Error(w, "Linear is not configured (set linear.team and an API key)", http.StatusNotImplemented)
+		return
+	}
+	endpoint := s.linearAPIURL
+	if endpoint == "" {
+		endpoint = defaultLinearAPIURL
+	}
+	s.handleCommentIssue(w, r, path, id, func(client *http.Client, c Comment, path string) (string, string, error) {
+		team, project := s.linear.target(path)
+		lc := linearClient{http: client, endpoint: endpoint, apiKey: s.linear.apiKey()}
+		return createLinearIssue(lc, team, project, issueTitle(c.Body), linearIssueDescription(c, path))
+	})
+}
@@ -0,0 +1,159 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeLinear answers Linear GraphQL with team ENG, project "Q3 Cleanup" and
+// issue ENG-12, recording the issueCreate input.
+func fakeLinear(t *testing.T, input *map[string]any) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "lin_api_key" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":[{"message":"Authentication required, not authenticated"}]}`))
+			return
+		}
+		var req struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case strings.Contains(req.Query, "teams("):
+			if req.Variables["value"] == "ENG" {
+				w.Write([]byte(`{"data":{"teams":{"nodes":[{"id":"team-eng"}]}}}`))
+				return
+			}
+			w.Write([]byte(`{"data":{"teams":{"nodes":[]}}}`))
+		case strings.Contains(req.Query, "projects("):
+			w.Write([]byte(`{"data":{"projects":{"nodes":[{"id":"proj-q3"}]}}}`))
+		case strings.Contains(req.Query, "issueCreate"):
+			*input = req.Variables["input"].(map[string]any)
+			w.Write([]byte(`{"data":{"issueCreate":{"success":true,"issue":{"identifier":"ENG-12","url":"https://linear.app/acme/issue/ENG-12"}}}}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestValidateLinear(t *testing.T) {
+	if err := validateLinear(linearConfig{}); err != nil {
+		t.Errorf("unset linear: %v", err)
+	}
+	if err := validateLinear(linearConfig{Team: "ENG", Routes: []linearRoute{{Match: "frontend/", Team: "WEB"}}}); err != nil {
+		t.Errorf("valid linear: %v", err)
+	}
+	for _, bad := range []linearConfig{{Project: "Q3"}, {Team: "ENG", Routes: []linearRoute{{Team: "WEB"}}}, {Team: "ENG", Routes: []linearRoute{{Match: "*.sql"}}}} {
+		if err := validateLinear(bad); err == nil {
+			t.Errorf("validateLinear(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestLinearTarget(t *testing.T) {
+	l := linearConfig{Team: "ENG", Project: "Backlog", Routes: []linearRoute{
+		{Match: "frontend/", Team: "WEB"},
+		{Match: "*.sql", Project: "Migrations"},
+		{Match: "*.sql", Team: "DATA"},
+	}}
+	tests := []struct{ path, team, project string }{
+		{"frontend/app.js", "WEB", "Backlog"},
+		{"db/001_init.sql", "ENG", "Migrations"},
+		{"main.go", "ENG", "Backlog"},
+		{"", "ENG", "Backlog"},
+	}
+	for _, tt := range tests {
+		if team, project := l.target(tt.path); team != tt.team || project != tt.project {
+			t.Errorf("target(%q) = %q, %q; want %q, %q", tt.path, team, project, tt.team, tt.project)
+		}
+	}
+}
+
+func TestCommentLinear_CreatesIssue(t *testing.T) {
+	var input map[string]any
+	linear := fakeLinear(t, &input)
+	t.Setenv(envLinearAPIKey, "")
+
+	s, session := newTestServer(t)
+	s.linearAPIURL = linear.URL
+	s.linear = linearConfig{APIKey: "lin_api_key", Team: "ENG", Project: "Q3 Cleanup"}
+	c, _ := session.AddComment("test.md", 1, 1, "", "Split this section", "", "")
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/comment/"+c.ID+"/linear?path=test.md", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var got Comment
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if got.IssueKey != "ENG-12" || got.IssueURL != "https://linear.app/acme/issue/ENG-12" {
+		t.Errorf("comment = %+v", got)
+	}
+	if input["teamId"] != "team-eng" || input["projectId"] != "proj-q3" || input["title"] != "Split this section" {
+		t.Errorf("issue input = %v", input)
+	}
+	if !strings.Contains(input["description"].(string), "From a crit review of test.md, line 1.") {
+		t.Errorf("description = %q", input["description"])
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/comment/"+c.ID+"/jira?path=test.md", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("jira without config: status = %d, want 501", w.Code)
+	}
+	s.jira = jiraConfig{URL: "http://127.0.0.1:1", Token: "tok", Project: "PLAT"}
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/comment/"+c.ID+"/jira?path=test.md", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("jira after linear: status = %d, want 409", w.Code)
+	}
+}
+
+func TestCommentLinear_Errors(t *testing.T) {
+	var input map[string]any
+	linear := fakeLinear(t, &input)
+	t.Setenv(envLinearAPIKey, "")
+
+	s, session := newTestServer(t)
+	s.linearAPIURL = linear.URL
+	c := session.AddReviewComment("Document the rollout", "")
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/review-comment/"+c.ID+"/linear", nil))
+	if w.Code != http.StatusNotImplemented {
+		t.Errorf("unconfigured: status = %d, want 501", w.Code)
+	}
+
+	s.linear = linearConfig{APIKey: "wrong", Team: "ENG"}
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/review-comment/"+c.ID+"/linear", nil))
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "not authenticated") {
+		t.Errorf("bad key: status = %d: %s", w.Code, w.Body)
+	}
+
+	t.Setenv(envLinearAPIKey, "lin_api_key")
+	s.linear = linearConfig{APIKey: "wrong", Team: "NOPE"}
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/review-comment/"+c.ID+"/linear", nil))
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), `no Linear team "NOPE"`) {
+		t.Errorf("unknown team: status = %d: %s", w.Code, w.Body)
+	}
+
+	s.linear.Team = "ENG"
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/review-comment/"+c.ID+"/linear", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if _, ok := input["projectId"]; ok {
+		t.Errorf("projectId sent without a project: %v", input)
+	}
+	if rc := session.GetReviewComments()[0]; rc.IssueKey != "ENG-12" {
+		t.Errorf("review comment = %+v", rc)
+	}
+}
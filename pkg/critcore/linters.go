@@ -0,0 +1,237 @@
+package critcore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Linters are commands (vale, markdownlint, golangci-lint, ...) the daemon
+// runs on the reviewed files when the session loads and after each round.
+// Their findings become comments tagged with Comment.Tool, like crit import's,
+// so the agent gets the mechanical feedback along with the reviewer's. Each
+// run replaces the linter's previous open comments: fixed findings go away,
+// and ones someone replied to or resolved are kept.
+
+// linterLineFormat is the default linter output format: one finding per line
+// as "path:line[:col]: message", which most linters print or can be told to.
+const linterLineFormat = "line"
+
+// linterTimeout bounds one linter run.
+const linterTimeout = 2 * time.Minute
+
+// linterConfig is one entry of the linters config list (global-only: it
+// names a command to run).
+type linterConfig struct {
+	Command string `json:"command"` // run from the repo root with the file paths appended
+	Format  string `json:"format"`  // linterLineFormat (default), "sarif" or "rdjson"
+	Match   string `json:"match"`   // only lint files matching this ignore_patterns-style pattern, e.g. "*.md"
+	Name    string `json:"name"`    // tag on its comments; defaults to the command's name
+}
+
+// name is the tool name the linter's comments carry.
+func (l linterConfig) name() string {
+	if l.Name != "" {
+		return l.Name
+	}
+	fields := strings.Fields(l.Command)
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(fields[0])
+}
+
+// validateLinters checks the linters config list.
+func validateLinters(linters []linterConfig) error {
+	for i, l := range linters {
+		if strings.TrimSpace(l.Command) == "" {
+			return fmt.Errorf("linters[%d]: command is required", i)
+		}
+		switch l.Format {
+		case "", linterLineFormat, importSARIF, importRDJSON:
+		default:
+			return fmt.Errorf("linters[%d]: unknown format %q (want %q, %q or %q)", i, l.Format, linterLineFormat, importSARIF, importRDJSON)
+		}
+	}
+	return nil
+}
+
+// lineFindingPattern matches "path:line[:col]: message" and
+// "path:line:col message" (markdownlint).
+var lineFindingPattern = regexp.MustCompile(`^(.+?):(\d+)(?::\d+)?(?::\s*|\s+)(\S.*)$`)
+
+// parseLineFindings returns the findings in line-format output, skipping
+// lines that aren't findings (summaries, blank lines).
+func parseLineFindings(data []byte) []finding {
+	var findings []finding
+	for line := range strings.SplitSeq(string(data), "\n") {
+		m := lineFindingPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[2])
+		findings = append(findings, finding{path: m[1], startLine: n, endLine: n, message: m[3]})
+	}
+	return findings
+}
+
+// runLinters runs the prose check (when enabled) and every configured
+// linter on the session's files, and replaces each one's comments with its
+// findings. Runs are serialized, so a slow run on load can't interleave with
+// the one after a round.
+func (s *Session) runLinters() {
+	if len(s.linters) == 0 && !s.proseCheck {
+		return
+	}
+	s.lintMu.Lock()
+	defer s.lintMu.Unlock()
+
+	changed := s.proseCheck && s.replaceToolComments(proseCheckTool, s.proseCheckFindings())
+
+	s.mu.RLock()
+	dir := s.RepoRoot
+	var files []*FileEntry
+	for _, f := range s.Files {
+		if f.Status != "deleted" && f.AbsPath != "" {
+			files = append(files, f)
+		}
+	}
+	s.mu.RUnlock()
+	if dir == "" {
+		dir, _ = os.Getwd()
+	}
+
+	for _, l := range s.linters {
+		byAbs := lintTargets(l, files)
+		if len(byAbs) == 0 {
+			continue
+		}
+		findings, err := runLinter(l, dir, slices.Sorted(maps.Keys(byAbs)))
+		if err != nil {
+			slog.Warn("linter failed", "linter", l.name(), "err", err)
+			continue
+		}
+		for i := range findings {
+			p := filepath.FromSlash(findings[i].path)
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			findings[i].path = byAbs[filepath.Clean(p)] // "" for files outside the review
+		}
+		if s.replaceToolComments(l.name(), findings) {
+			changed = true
+		}
+	}
+	if changed {
+		s.notify(SSEEvent{Type: "comments-changed"})
+	}
+}
+
+// lintTargets maps the absolute paths of the files l should lint to their
+// session paths.
+func lintTargets(l linterConfig, files []*FileEntry) map[string]string {
+	byAbs := map[string]string{}
+	for _, f := range files {
+		if l.Match != "" && !matchPattern(l.Match, f.Path) {
+			continue
+		}
+		if _, err := os.Stat(f.AbsPath); err == nil {
+			byAbs[f.AbsPath] = f.Path
+		}
+	}
+	return byAbs
+}
+
+// runLinter runs l on paths from dir and parses what it prints. Linters exit
+// non-zero when they find something, so that only counts as a failure when
+// nothing was printed.
+func runLinter(l linterConfig, dir string, paths []string) ([]finding, error) {
+	parts := strings.Fields(l.Command)
+	ctx, cancel := context.WithTimeout(context.Background(), linterTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, parts[0], append(parts[1:], paths...)...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if err != nil && (!errors.As(err, &exitErr) || len(bytes.TrimSpace(out)) == 0) {
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	format := l.Format
+	if format == "" {
+		format = linterLineFormat
+	}
+	return parseFindings(format, out)
+}
+
+// replaceToolComments removes the open comments tool left on the session's
+// files and adds findings (whose paths are session file paths) in their
+// place. Comments with replies, resolved ones and ones matching a finding
+// are kept. It reports whether anything changed.
+func (s *Session) replaceToolComments(tool string, findings []finding) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	for _, f := range s.Files {
+		var mine []finding
+		for _, fd := range findings {
+			if fd.path == f.Path {
+				fd.endLine = max(fd.endLine, fd.startLine)
+				mine = append(mine, fd)
+			}
+		}
+		if s.replaceFileToolComments(f, tool, mine) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// replaceFileToolComments does replaceToolComments for one file. Caller must
+// hold s.mu.
+func (s *Session) replaceFileToolComments(f *FileEntry, tool string, findings []finding) bool {
+	changed := false
+	reported := func(c Comment) bool {
+		return slices.ContainsFunc(findings, func(fd finding) bool { return fd.matches(c, tool) })
+	}
+	kept := make([]Comment, 0, len(f.Comments))
+	for _, c := range f.Comments {
+		if c.Tool == tool && !c.Resolved && len(c.Replies) == 0 && !reported(c) {
+			s.trackDeletedComment(f.Path, c.ID)
+			s.walDeleteLocked(f.Path, c.ID)
+			changed = true
+			continue
+		}
+		kept = append(kept, c)
+	}
+	f.Comments = kept
+	for _, fd := range findings {
+		if slices.ContainsFunc(f.Comments, func(c Comment) bool { return fd.matches(c, tool) }) {
+			continue
+		}
+		c := s.addCommentLocked(f, fd.startLine, fd.endLine, "", fd.body(), "", tool)
+		c.Tool, c.Severity = tool, fd.severity
+		if fd.startLine <= 0 {
+			c.Scope, c.Anchor = "file", ""
+		}
+		f.Comments[len(f.Comments)-1] = c
+		s.walPutLocked(f.Path, c)
+		changed = true
+	}
+	if changed {
+		s.scheduleWrite()
+	}
+	return changed
+}
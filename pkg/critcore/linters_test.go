@@ -0,0 +1,139 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeLinter returns a linter command that prints the contents of out (as a
+// linter would print findings) and exits 1, like most linters that found
+// something.
+func fakeLinter(t *testing.T, out string) string {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "lint.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat \"$1\"\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return script + " " + out
+}
+
+func TestParseLineFindings(t *testing.T) {
+	out := "main.go:7:2: Error return value is not checked (errcheck)\n" +
+		"README.md:3:81 MD013/line-length Line length [Expected: 80; Actual: 95]\n" +
+		"docs/a.md:12:1:Vale.Spelling:Did you really mean 'crit'?\n" +
+		"\n2 issues found.\n"
+	findings := parseLineFindings([]byte(out))
+	want := []finding{
+		{path: "main.go", startLine: 7, endLine: 7, message: "Error return value is not checked (errcheck)"},
+		{path: "README.md", startLine: 3, endLine: 3, message: "MD013/line-length Line length [Expected: 80; Actual: 95]"},
+		{path: "docs/a.md", startLine: 12, endLine: 12, message: "Vale.Spelling:Did you really mean 'crit'?"},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings: %+v", len(findings), findings)
+	}
+	for i := range want {
+		if findings[i] != want[i] {
+			t.Errorf("finding %d = %+v, want %+v", i, findings[i], want[i])
+		}
+	}
+}
+
+func TestValidateLinters(t *testing.T) {
+	if err := validateLinters([]linterConfig{{Command: "vale --output=line"}, {Command: "semgrep scan --sarif", Format: importSARIF}}); err != nil {
+		t.Errorf("valid linters: %v", err)
+	}
+	for _, bad := range []linterConfig{{Command: " "}, {Command: "eslint", Format: "json"}} {
+		if err := validateLinters([]linterConfig{bad}); err == nil {
+			t.Errorf("validateLinters(%+v) should fail", bad)
+		}
+	}
+	if got := (linterConfig{Command: "/usr/local/bin/markdownlint --dot"}).name(); got != "markdownlint" {
+		t.Errorf("name = %q", got)
+	}
+}
+
+func TestRunLinters_ReplacesOpenFindings(t *testing.T) {
+	_, session := newTestServer(t)
+	out := filepath.Join(t.TempDir(), "findings.txt")
+	write := func(s string) {
+		if err := os.WriteFile(out, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session.linters = []linterConfig{{Command: fakeLinter(t, out), Name: "vale", Match: "*.md"}}
+	human, _ := session.AddComment("test.md", 3, 3, "", "Reword this", "", "ana")
+
+	write("test.md:1:1: Avoid 'line1'\n" + session.Files[0].AbsPath + ":2: Passive voice\nother.md:1: not under review\n")
+	session.runLinters()
+	comments := session.GetComments("test.md")
+	if len(comments) != 3 {
+		t.Fatalf("comments = %+v", comments)
+	}
+	var passive Comment
+	for _, c := range comments {
+		if c.StartLine == 2 {
+			passive = c
+		}
+	}
+	if passive.Tool != "vale" || passive.Author != "vale" || passive.Body != "Passive voice" || passive.Anchor != "line2" {
+		t.Errorf("linter comment = %+v", passive)
+	}
+	if _, ok := session.AddReply("test.md", passive.ID, "This one is on purpose", "ana"); !ok {
+		t.Fatal("reply not added")
+	}
+
+	// The next run drops fixed findings, keeps discussed ones and adds new ones.
+	write("test.md:3: Weasel word\n")
+	session.runLinters()
+	got := map[int]string{}
+	for _, c := range session.GetComments("test.md") {
+		got[c.StartLine] += c.Author + ":" + c.Body + ";"
+	}
+	want := map[int]string{2: "vale:Passive voice;", 3: "ana:Reword this;vale:Weasel word;"}
+	if len(got) != len(want) || got[2] != want[2] || got[3] != want[3] {
+		t.Errorf("after second run = %v, want %v", got, want)
+	}
+	if c, _, _ := session.FindCommentByID(human.ID, "test.md"); c.Body != "Reword this" {
+		t.Errorf("human comment lost: %+v", c)
+	}
+
+	// Re-running with the same output changes nothing.
+	before := len(session.GetComments("test.md"))
+	session.runLinters()
+	if after := len(session.GetComments("test.md")); after != before {
+		t.Errorf("identical run changed comment count from %d to %d", before, after)
+	}
+}
+
+func TestRunLinters_MatchAndFailure(t *testing.T) {
+	_, session := newTestServer(t)
+	out := filepath.Join(t.TempDir(), "findings.txt")
+	os.WriteFile(out, []byte("test.md:1: should not be reported\n"), 0644)
+	session.linters = []linterConfig{
+		{Command: fakeLinter(t, out), Match: "*.go"},
+		{Command: fakeLinter(t, filepath.Join(t.TempDir(), "missing.txt"))}, // prints nothing, exits 1
+	}
+	session.runLinters()
+	if comments := session.GetComments("test.md"); len(comments) != 0 {
+		t.Errorf("comments = %+v", comments)
+	}
+}
+
+// Carried-forward linter comments must stay tagged, or the next run can't
+// replace them and reports the same finding twice.
+func TestCarryForwardComment_KeepsToolAndIssue(t *testing.T) {
+	old := Comment{ID: "c1", Body: "Passive voice", Tool: "vale", IssueKey: "ENG-12", IssueURL: "https://linear.app/x/issue/ENG-12"}
+	c := carryForwardComment(old, "c2", "2026-01-01T00:00:00Z")
+	if c.Tool != "vale" || c.IssueKey != "ENG-12" || c.IssueURL != old.IssueURL {
+		t.Errorf("carried = %+v", c)
+	}
+}
+
+func TestMergeConfigs_LintersGlobalOnly(t *testing.T) {
+	global := Config{Linters: []linterConfig{{Command: "vale --output=line"}}}
+	project := Config{Linters: []linterConfig{{Command: "curl evil.example.com"}}}
+	if merged := mergeConfigs(global, project, configPresence{}); len(merged.Linters) != 1 || merged.Linters[0].Command != "vale --output=line" {
+		t.Errorf("linters = %+v, project config must not override them", merged.Linters)
+	}
+}
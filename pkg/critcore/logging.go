@@ -0,0 +1,38 @@
+package critcore
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Diagnostics (failed review writes, watcher errors, agent requests) go
+// through log/slog, so the daemon log can be filtered with --log-level and
+// parsed with --log-format json.
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// newLogger returns a logger writing to w at the given level ("debug",
+// "info", "warn" or "error"; "" is info) and format ("text", the default,
+// or "json").
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "", logFormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case logFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want %q or %q)", format, logFormatText, logFormatJSON)
+	}
+}
@@ -0,0 +1,46 @@
+package critcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "warn", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("writing review export", "err", "disk full")
+	if got := buf.String(); strings.Contains(got, "hidden") || !strings.Contains(got, `level=WARN msg="writing review export" err="disk full"`) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNewLogger_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "DEBUG", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("wrote review file", "bytes", 42)
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("not JSON: %q", buf.String())
+	}
+	if rec["level"] != "DEBUG" || rec["msg"] != "wrote review file" || rec["bytes"] != float64(42) {
+		t.Errorf("record = %v", rec)
+	}
+}
+
+func TestNewLogger_Invalid(t *testing.T) {
+	if _, err := newLogger(&bytes.Buffer{}, "loud", ""); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if _, err := newLogger(&bytes.Buffer{}, "", "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
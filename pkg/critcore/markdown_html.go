@@ -0,0 +1,155 @@
+package critcore
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// renderMarkdownHTML renders a markdown document to HTML for the rendered
+// view. Every block element carries data-source-line / data-source-end-line
+// (1-based, inclusive) so the frontend can map a selection in the rendered
+// view back to the source lines comments are anchored to. Raw HTML in the
+// document is omitted, as goldmark does by default.
+func renderMarkdownHTML(content string) (string, error) {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(util.Prioritized(sourceLineTransformer{}, 1000)),
+		),
+		goldmark.WithRendererOptions(
+			renderer.WithNodeRenderers(util.Prioritized(codeBlockRenderer{}, 100)),
+		),
+	)
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(content), &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// sourceLineTransformer annotates block nodes with the source lines they span.
+type sourceLineTransformer struct{}
+
+func (sourceLineTransformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+	source := reader.Source()
+	lineStarts := []int{0}
+	for i, b := range source {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineOf := func(offset int) int {
+		return sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset })
+	}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n.Type() != ast.TypeBlock || n.Kind() == ast.KindDocument {
+			return ast.WalkContinue, nil
+		}
+		start, end, ok := nodeSpan(n)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		first, last := lineOf(start), lineOf(max(end-1, start))
+		if fenced, isFenced := n.(*ast.FencedCodeBlock); isFenced {
+			first, last = fencedCodeLines(fenced, source, lineStarts, first, last)
+		}
+		n.SetAttributeString("data-source-line", []byte(strconv.Itoa(first)))
+		n.SetAttributeString("data-source-end-line", []byte(strconv.Itoa(last)))
+		return ast.WalkContinue, nil
+	})
+}
+
+// nodeSpan returns the byte range covered by n and its descendants.
+func nodeSpan(n ast.Node) (start, end int, ok bool) {
+	start = -1
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		var segs []text.Segment
+		switch {
+		case c.Type() == ast.TypeBlock:
+			lines := c.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				segs = append(segs, lines.At(i))
+			}
+		case c.Kind() == ast.KindText:
+			segs = append(segs, c.(*ast.Text).Segment)
+		}
+		for _, s := range segs {
+			if start < 0 || s.Start < start {
+				start = s.Start
+			}
+			end = max(end, s.Stop)
+		}
+		return ast.WalkContinue, nil
+	})
+	return start, end, start >= 0
+}
+
+// fencedCodeLines widens a fenced block's content lines to include its fences.
+// first/last are the content lines (or 0 when the block is empty).
+func fencedCodeLines(n *ast.FencedCodeBlock, source []byte, lineStarts []int, first, last int) (int, int) {
+	if n.Lines().Len() == 0 {
+		return first, last
+	}
+	first--
+	if last < len(lineStarts) {
+		closing := bytes.TrimSpace(source[lineStarts[last]:lineEnd(source, lineStarts, last)])
+		if bytes.HasPrefix(closing, []byte("```")) || bytes.HasPrefix(closing, []byte("~~~")) {
+			last++
+		}
+	}
+	return first, last
+}
+
+// lineEnd returns the byte offset where the 0-based line idx ends.
+func lineEnd(source []byte, lineStarts []int, idx int) int {
+	if idx+1 < len(lineStarts) {
+		return lineStarts[idx+1]
+	}
+	return len(source)
+}
+
+// codeBlockRenderer renders code blocks like goldmark does, plus the node's
+// attributes on <pre>, which the stock renderer drops.
+type codeBlockRenderer struct{}
+
+func (codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, renderCodeBlockWithAttrs)
+	reg.Register(ast.KindCodeBlock, renderCodeBlockWithAttrs)
+}
+
+func renderCodeBlockWithAttrs(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString("<pre")
+	html.RenderAttributes(w, n, nil)
+	_, _ = w.WriteString("><code")
+	if fenced, ok := n.(*ast.FencedCodeBlock); ok {
+		if lang := fenced.Language(source); lang != nil {
+			_, _ = w.WriteString(` class="language-`)
+			_, _ = w.Write(util.EscapeHTML(lang))
+			_, _ = w.WriteString(`"`)
+		}
+	}
+	_, _ = w.WriteString(">")
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		_, _ = w.Write(util.EscapeHTML(line.Value(source)))
+	}
+	_, _ = w.WriteString("</code></pre>\n")
+	return ast.WalkSkipChildren, nil
+}
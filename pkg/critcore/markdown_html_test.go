@@ -0,0 +1,62 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdownHTML_SourceLines(t *testing.T) {
+	src := "# Plan\n\nFirst paragraph\nwraps here.\n\n- one\n- two\n\n```go\nfmt.Println(\"<hi>\")\n```\n\n| a | b |\n|---|---|\n| 1 | 2 |\n"
+	got, err := renderMarkdownHTML(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<h1 data-source-line="1" data-source-end-line="1">Plan</h1>`,
+		`<p data-source-line="3" data-source-end-line="4">First paragraph`,
+		`<ul data-source-line="6" data-source-end-line="7">`,
+		`<li data-source-line="7" data-source-end-line="7">two</li>`,
+		`<pre data-source-line="9" data-source-end-line="11"><code class="language-go">fmt.Println(&quot;&lt;hi&gt;&quot;)`,
+		`<table data-source-line="13" data-source-end-line="15">`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderMarkdownHTML_OmitsRawHTML(t *testing.T) {
+	got, err := renderMarkdownHTML("<script>alert(1)</script>\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "<script>") {
+		t.Errorf("raw HTML should be omitted: %s", got)
+	}
+}
+
+func TestHandleFile_RenderHTML(t *testing.T) {
+	s, session := newTestServer(t)
+	session.Files[0].FileType = "markdown"
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/file?path=test.md&render=html", nil))
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	html, _ := resp["html"].(string)
+	if !strings.Contains(html, `data-source-line="1" data-source-end-line="3"`) {
+		t.Errorf("html = %q", html)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/file?path=test.md", nil))
+	resp = nil
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if _, ok := resp["html"]; ok {
+		t.Error("html should only be included with render=html")
+	}
+}
@@ -0,0 +1,144 @@
+package critcore
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// With --metrics, the daemon serves GET /metrics in the Prometheus text
+// format, for teams running crit as a shared daemon. The exposition is
+// written in-tree; there is no client library dependency.
+
+// commentRoutes are the mux patterns whose non-GET requests change comments.
+var commentRoutes = []string{"/api/comment/", "/api/comments", "/api/file/comments", "/api/review-comment/"}
+
+type requestKey struct {
+	route, method string
+	code          int
+}
+
+// serverMetrics counts served requests. Gauges (SSE connections, comments)
+// and write errors are read from the session when /metrics is scraped.
+type serverMetrics struct {
+	mu               sync.Mutex
+	requests         map[requestKey]int64
+	commentMutations map[string]int64 // by method
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{requests: map[requestKey]int64{}, commentMutations: map[string]int64{}}
+}
+
+// enableMetrics starts counting requests and registers /metrics.
+func (s *Server) enableMetrics() {
+	s.metrics = newServerMetrics()
+	s.mux.HandleFunc("/metrics", s.handleMetrics)
+}
+
+// observe counts a finished request under the mux pattern that served it, so
+// comment IDs in paths don't create a series each.
+func (m *serverMetrics) observe(r *http.Request, code int) {
+	route := r.Pattern
+	if route == "" {
+		route = "unmatched"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[requestKey{route, r.Method, code}]++
+	if r.Method != http.MethodGet && code < 400 && slices.Contains(commentRoutes, route) {
+		m.commentMutations[r.Method]++
+	}
+}
+
+// statusRecorder remembers the response status for serverMetrics. It keeps
+// Flush so SSE handlers still stream through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if sr.code == 0 {
+		sr.code = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(p []byte) (int, error) {
+	if sr.code == 0 {
+		sr.code = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(p)
+}
+
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+func (sr *statusRecorder) status() int {
+	if sr.code == 0 {
+		return http.StatusOK
+	}
+	return sr.code
+}
+
+// handleMetrics serves the counters in the Prometheus text format.
+// GET /metrics
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, s.metrics.render(s.session.Load()))
+}
+
+func (m *serverMetrics) render(sess *Session) string {
+	var b strings.Builder
+	m.mu.Lock()
+	keys := make([]requestKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b requestKey) int {
+		return cmp.Or(strings.Compare(a.route, b.route), strings.Compare(a.method, b.method), cmp.Compare(a.code, b.code))
+	})
+	writeMetricHeader(&b, "crit_http_requests_total", "counter", "HTTP requests served, by mux route, method and status code.")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "crit_http_requests_total{route=%q,method=%q,code=\"%d\"} %d\n", k.route, k.method, k.code, m.requests[k])
+	}
+	writeMetricHeader(&b, "crit_comment_mutations_total", "counter", "Successful requests that added, changed or deleted comments, by method.")
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if n, ok := m.commentMutations[method]; ok {
+			fmt.Fprintf(&b, "crit_comment_mutations_total{method=%q} %d\n", method, n)
+		}
+	}
+	m.mu.Unlock()
+
+	if sess == nil {
+		return b.String()
+	}
+	st := sess.LiveStatus()
+	writeMetricHeader(&b, "crit_sse_connections", "gauge", "Connected browser tabs (SSE streams).")
+	fmt.Fprintf(&b, "crit_sse_connections %d\n", st.Browsers)
+	writeMetricHeader(&b, "crit_comments", "gauge", "Comments in the current review, by state.")
+	fmt.Fprintf(&b, "crit_comments{state=\"open\"} %d\n", st.Open)
+	fmt.Fprintf(&b, "crit_comments{state=\"resolved\"} %d\n", st.Resolved)
+	writeMetricHeader(&b, "crit_review_write_errors_total", "counter", "Failed writes of the review file.")
+	fmt.Fprintf(&b, "crit_review_write_errors_total %d\n", sess.writeErrors.Load())
+	return b.String()
+}
+
+func writeMetricHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
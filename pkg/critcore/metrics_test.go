@@ -0,0 +1,49 @@
+package critcore
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics_OptIn(t *testing.T) {
+	s, _ := newTestServer(t)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(w.Body.String(), "crit_http_requests_total") {
+		t.Error("/metrics should not be served without --metrics")
+	}
+}
+
+func TestMetrics_Counts(t *testing.T) {
+	s, session := newTestServer(t)
+	s.enableMetrics()
+	session.AddComment("test.md", 1, 1, "", "done", "", "")
+
+	for range 2 {
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/health", nil))
+	}
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/comments", strings.NewReader(`{"body": "overall"}`)))
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/api/review-comment/nope", nil))
+	session.writeErrors.Add(1)
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE crit_http_requests_total counter\n",
+		`crit_http_requests_total{route="/api/health",method="GET",code="200"} 2` + "\n",
+		`crit_http_requests_total{route="/api/comments",method="POST",code="201"} 1` + "\n",
+		`crit_comment_mutations_total{method="POST"} 1` + "\n",
+		"crit_comments{state=\"open\"} 2\n",
+		"crit_sse_connections 0\n",
+		"crit_review_write_errors_total 1\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `crit_comment_mutations_total{method="DELETE"}`) {
+		t.Errorf("a failed delete should not count as a mutation:\n%s", body)
+	}
+}
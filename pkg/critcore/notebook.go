@@ -0,0 +1,215 @@
+package critcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Jupyter notebooks are reviewed as a markdown rendering of their cells rather
+// than as raw JSON: each cell gets a heading, code cells are fenced in the
+// kernel's language, and text outputs follow their cell. Comments are placed
+// on lines of that rendering, and the review file additionally records the
+// cell and the line within the cell's source (see anchorNotebookComments).
+// This applies in files mode only; git mode diffs notebooks as JSON.
+
+type notebook struct {
+	Cells    []notebookCell `json:"cells"`
+	Metadata struct {
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+	} `json:"metadata"`
+}
+
+type notebookCell struct {
+	CellType       string           `json:"cell_type"`
+	Source         notebookText     `json:"source"`
+	Outputs        []notebookOutput `json:"outputs"`
+	ExecutionCount *int             `json:"execution_count"`
+}
+
+type notebookOutput struct {
+	OutputType string                     `json:"output_type"`
+	Text       notebookText               `json:"text"`
+	Data       map[string]json.RawMessage `json:"data"`
+	Ename      string                     `json:"ename"`
+	Evalue     string                     `json:"evalue"`
+	Traceback  []string                   `json:"traceback"`
+}
+
+// notebookText is nbformat's multiline string: a string or a list of lines.
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = notebookText(s)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+	*t = notebookText(strings.Join(lines, ""))
+	return nil
+}
+
+// notebookCellSpan locates a cell's source in the rendered notebook.
+type notebookCellSpan struct {
+	sourceStart int // 1-based rendered line of the cell's first source line
+	sourceLines int
+	end         int // last rendered line belonging to the cell (outputs included)
+}
+
+func isNotebookPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ipynb")
+}
+
+// reviewContent returns the text the session shows for a file's bytes. For
+// notebooks that is the rendering plus where each cell landed; a notebook
+// that doesn't parse is shown as-is.
+func reviewContent(data []byte, notebook bool) (string, []notebookCellSpan) {
+	if !notebook {
+		return textContent(data), nil
+	}
+	rendered, cells, err := renderNotebook(bytes.TrimPrefix(data, utf8BOM))
+	if err != nil {
+		return textContent(data), nil
+	}
+	return rendered, cells
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+func renderNotebook(data []byte) (string, []notebookCellSpan, error) {
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return "", nil, fmt.Errorf("parsing notebook: %w", err)
+	}
+	lang := nb.Metadata.LanguageInfo.Name
+	if lang == "" {
+		lang = nb.Metadata.Kernelspec.Language
+	}
+
+	var lines []string
+	add := func(text string) {
+		lines = append(lines, strings.Split(strings.TrimSuffix(text, "\n"), "\n")...)
+	}
+	cells := make([]notebookCellSpan, 0, len(nb.Cells))
+	for i, cell := range nb.Cells {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		header := fmt.Sprintf("#### Cell %d · %s", i+1, cell.CellType)
+		if cell.ExecutionCount != nil {
+			header += fmt.Sprintf(" [%d]", *cell.ExecutionCount)
+		}
+		lines = append(lines, header, "")
+
+		source := strings.TrimSuffix(string(cell.Source), "\n")
+		fence := ""
+		if cell.CellType != "markdown" {
+			fence = codeFence(source)
+			info := ""
+			if cell.CellType == "code" {
+				info = lang
+			}
+			lines = append(lines, fence+info)
+		}
+		span := notebookCellSpan{sourceStart: len(lines) + 1}
+		if source != "" {
+			add(source)
+		}
+		span.sourceLines = len(lines) + 1 - span.sourceStart
+		if fence != "" {
+			lines = append(lines, fence)
+		}
+		for _, out := range cell.Outputs {
+			if text := outputText(out); text != "" {
+				f := codeFence(text)
+				lines = append(lines, "", f+"text")
+				add(text)
+				lines = append(lines, f)
+			}
+		}
+		span.end = len(lines)
+		cells = append(cells, span)
+	}
+	return strings.Join(lines, "\n") + "\n", cells, nil
+}
+
+// outputText flattens a cell output to plain text. Rich outputs without a
+// text/plain representation are summarised by MIME type.
+func outputText(out notebookOutput) string {
+	switch out.OutputType {
+	case "stream":
+		return string(out.Text)
+	case "error":
+		text := out.Ename + ": " + out.Evalue
+		if len(out.Traceback) > 0 {
+			text = ansiEscape.ReplaceAllString(strings.Join(out.Traceback, "\n"), "")
+		}
+		return text
+	case "execute_result", "display_data":
+		if raw, ok := out.Data["text/plain"]; ok {
+			var t notebookText
+			if json.Unmarshal(raw, &t) == nil {
+				return string(t)
+			}
+		}
+		if len(out.Data) > 0 {
+			mimes := make([]string, 0, len(out.Data))
+			for mime := range out.Data {
+				mimes = append(mimes, mime)
+			}
+			sort.Strings(mimes)
+			return "[" + mimes[0] + " output]"
+		}
+	}
+	return ""
+}
+
+// codeFence returns a backtick fence longer than any run inside text.
+func codeFence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
+
+// anchorNotebookComments sets Cell (1-based) and CellLine (1-based line in the
+// cell's source, 0 when the comment starts on the heading or an output) on
+// line comments of a rendered notebook.
+func anchorNotebookComments(comments []Comment, cells []notebookCellSpan) {
+	for i := range comments {
+		c := &comments[i]
+		c.Cell, c.CellLine = 0, 0
+		if c.Scope == "file" || c.StartLine <= 0 {
+			continue
+		}
+		for idx, span := range cells {
+			if c.StartLine > span.end {
+				continue
+			}
+			c.Cell = idx + 1
+			if off := c.StartLine - span.sourceStart; off >= 0 && off < span.sourceLines {
+				c.CellLine = off + 1
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package critcore
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testNotebook = `{
+ "metadata": {"language_info": {"name": "python"}},
+ "cells": [
+  {"cell_type": "markdown", "source": ["# Analysis\n", "Load the data."]},
+  {"cell_type": "code", "execution_count": 2, "source": "import pandas as pd\ndf = pd.read_csv('x.csv')\ndf.head()",
+   "outputs": [
+    {"output_type": "stream", "name": "stdout", "text": ["loaded\n"]},
+    {"output_type": "execute_result", "data": {"text/plain": ["   a  b\n", "0  1  2"]}}
+   ]},
+  {"cell_type": "code", "source": "1/0",
+   "outputs": [{"output_type": "error", "ename": "ZeroDivisionError", "evalue": "division by zero",
+     "traceback": ["\u001b[0;31mZeroDivisionError\u001b[0m: division by zero"]}]}
+ ]
+}`
+
+func TestRenderNotebook(t *testing.T) {
+	got, cells, err := renderNotebook([]byte(testNotebook))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "#### Cell 1 · markdown\n\n" +
+		"# Analysis\nLoad the data.\n\n" +
+		"#### Cell 2 · code [2]\n\n" +
+		"```python\nimport pandas as pd\ndf = pd.read_csv('x.csv')\ndf.head()\n```\n\n" +
+		"```text\nloaded\n```\n\n" +
+		"```text\n   a  b\n0  1  2\n```\n\n" +
+		"#### Cell 3 · code\n\n" +
+		"```python\n1/0\n```\n\n" +
+		"```text\nZeroDivisionError: division by zero\n```\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if len(cells) != 3 || cells[1].sourceStart != 9 || cells[1].sourceLines != 3 {
+		t.Errorf("cells = %+v", cells)
+	}
+}
+
+func TestRenderNotebook_Invalid(t *testing.T) {
+	if content, cells := reviewContent([]byte("not json"), true); content != "not json" || cells != nil {
+		t.Errorf("invalid notebook should be shown raw, got %q", content)
+	}
+}
+
+func TestCodeFence(t *testing.T) {
+	if got := codeFence("has ``` inside"); got != "````" {
+		t.Errorf("codeFence = %q", got)
+	}
+}
+
+func TestAnchorNotebookComments(t *testing.T) {
+	_, cells, _ := renderNotebook([]byte(testNotebook))
+	comments := []Comment{
+		{StartLine: 3, EndLine: 3},   // cell 1, source line 1
+		{StartLine: 10, EndLine: 11}, // cell 2, source line 2
+		{StartLine: 14, EndLine: 14}, // cell 2 output
+		{StartLine: 26, EndLine: 26}, // cell 3, source line 1
+		{Scope: "file"},
+	}
+	anchorNotebookComments(comments, cells)
+	want := [][2]int{{1, 1}, {2, 2}, {2, 0}, {3, 1}, {0, 0}}
+	for i, w := range want {
+		if comments[i].Cell != w[0] || comments[i].CellLine != w[1] {
+			t.Errorf("comment %d: cell=%d line=%d, want %v", i, comments[i].Cell, comments[i].CellLine, w)
+		}
+	}
+}
+
+func TestSessionFromNotebook(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "analysis.ipynb")
+	writeFile(t, path, testNotebook)
+
+	session, err := NewSessionFromFiles([]string{path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.RepoRoot = dir
+	f := session.Files[0]
+	if f.FileType != "markdown" || !strings.HasPrefix(f.Content, "#### Cell 1 · markdown") {
+		t.Fatalf("file type %q, content:\n%s", f.FileType, f.Content)
+	}
+	raw, _ := os.ReadFile(path)
+	if f.FileHash != fileHash(raw) {
+		t.Error("FileHash should hash the raw notebook")
+	}
+
+	if _, ok := session.AddComment(f.Path, 10, 10, "", "use a context manager", "", ""); !ok {
+		t.Fatal("AddComment failed")
+	}
+	session.WriteFiles()
+	data, err := os.ReadFile(session.critJSONPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cj CritJSON
+	if err := json.Unmarshal(data, &cj); err != nil {
+		t.Fatal(err)
+	}
+	c := cj.Files[f.Path].Comments[0]
+	if c.Cell != 2 || c.CellLine != 2 {
+		t.Errorf("cell=%d cell_line=%d, want 2/2", c.Cell, c.CellLine)
+	}
+}
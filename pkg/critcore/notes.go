@@ -0,0 +1,152 @@
+package critcore
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Values for the "backend" config key.
+const (
+	backendFile     = "file"      // review file only (default)
+	backendGitNotes = "git-notes" // additionally mirror the review into git notes
+)
+
+// critNotesRef is the notes ref reviews are stored under. Share it with
+// `git push origin refs/notes/crit` and fetch with
+// `git fetch origin refs/notes/crit:refs/notes/crit`.
+const critNotesRef = "refs/notes/crit"
+
+// readReviewNote returns the review stored in the crit note on commit,
+// or nil if there is none.
+func readReviewNote(repoRoot, commit string) ([]byte, error) {
+	cmd := exec.Command("git", "notes", "--ref", critNotesRef, "show", commit)
+	cmd.Dir = repoRoot
+	// A missing note is told apart by git's message, so keep it untranslated.
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if strings.Contains(stderr.String(), "no note found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("git notes show: %s", strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// writeReviewNote replaces the crit note on commit with data. A nil data
+// removes the note.
+func writeReviewNote(repoRoot, commit string, data []byte) error {
+	var cmd *exec.Cmd
+	if data == nil {
+		cmd = exec.Command("git", "notes", "--ref", critNotesRef, "remove", "--ignore-missing", commit)
+	} else {
+		cmd = exec.Command("git", "notes", "--ref", critNotesRef, "add", "--force", "--file", "-", commit)
+		cmd.Stdin = bytes.NewReader(data)
+	}
+	cmd.Dir = repoRoot
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git notes: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// headCommit returns the full SHA of HEAD in repoRoot.
+func headCommit(repoRoot string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = repoRoot
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("resolving HEAD: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// enableNotesBackend turns on git-notes mirroring for the session when the
+// backend config asks for it. If the review file doesn't exist yet, it is
+// seeded from the note on HEAD so a review survives `crit cleanup`, a fresh
+// clone (after fetching the notes ref), or switching machines.
+// Must be called before loadCritJSON.
+func (s *Session) enableNotesBackend(backend string) {
+	switch backend {
+	case "", backendFile:
+		return
+	case backendGitNotes:
+	default:
+		slog.Warn("unknown backend, using the review file", "backend", backend, "want", []string{backendFile, backendGitNotes})
+		return
+	}
+	if s.VCS == nil || s.VCS.Name() != "git" || s.RepoRoot == "" {
+		slog.Warn("backend needs a git repository, using the review file", "backend", backendGitNotes)
+		return
+	}
+	s.notesRepo = s.RepoRoot
+
+	critPath := s.critJSONPath()
+	if _, err := os.Stat(critPath); err == nil {
+		return
+	}
+	commit, err := headCommit(s.RepoRoot)
+	if err != nil {
+		return
+	}
+	data, err := readReviewNote(s.RepoRoot, commit)
+	if err != nil {
+		slog.Warn("reading review note", "err", err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+	var cj CritJSON
+	if err := unmarshalCritJSON(data, &cj); err != nil {
+		slog.Warn("ignoring invalid review note", "commit", commit[:min(7, len(commit))], "err", err)
+		return
+	}
+	if err := atomicWriteFile(critPath, data, 0644); err != nil {
+		slog.Warn("restoring review from note", "err", err)
+		return
+	}
+	s.notesSynced = computeFileHash(data)
+}
+
+// syncReviewNote mirrors the review file on disk into the crit note on the
+// current HEAD, removing the note when there is no review file. It runs when
+// a round finishes or completes and at shutdown rather than on every write,
+// since each note update is a commit on refs/notes/crit, and skips content
+// it already mirrored. No-op unless the git-notes backend is enabled. Errors
+// are logged: the review file remains the source of truth.
+func (s *Session) syncReviewNote() {
+	if s.notesRepo == "" {
+		return
+	}
+	data, err := os.ReadFile(s.critJSONPath())
+	if err != nil && !os.IsNotExist(err) {
+		slog.Warn("syncing review note", "err", err)
+		return
+	}
+	hash := ""
+	if data != nil {
+		hash = computeFileHash(data)
+	}
+	s.notesMu.Lock()
+	defer s.notesMu.Unlock()
+	if hash == s.notesSynced {
+		return
+	}
+	commit, err := headCommit(s.notesRepo)
+	if err != nil {
+		slog.Warn("syncing review note", "err", err)
+		return
+	}
+	if err := writeReviewNote(s.notesRepo, commit, data); err != nil {
+		slog.Warn("syncing review note", "err", err)
+		return
+	}
+	s.notesSynced = hash
+}
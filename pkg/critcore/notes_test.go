@@ -0,0 +1,121 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newNotesTestSession(t *testing.T, dir string) *Session {
+	t.Helper()
+	return &Session{
+		VCS:            &GitVCS{},
+		Mode:           "git",
+		RepoRoot:       dir,
+		ReviewFilePath: filepath.Join(t.TempDir(), "review.json"),
+		Files: []*FileEntry{
+			{Path: "README.md", AbsPath: filepath.Join(dir, "README.md"), Comments: []Comment{}},
+		},
+		subscribers: make(map[chan SSEEvent]struct{}),
+	}
+}
+
+func TestReviewNote_RoundTrip(t *testing.T) {
+	dir := initTestRepo(t)
+	head := runGit(t, dir, "rev-parse", "HEAD")
+
+	if data, err := readReviewNote(dir, head); err != nil || data != nil {
+		t.Fatalf("missing note: data=%q err=%v", data, err)
+	}
+	if err := writeReviewNote(dir, head, []byte(`{"files":{}}`)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := readReviewNote(dir, head)
+	if err != nil || string(data) != "{\"files\":{}}\n" {
+		t.Errorf("note = %q err=%v", data, err)
+	}
+	if err := writeReviewNote(dir, head, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := readReviewNote(dir, head); data != nil {
+		t.Errorf("note not removed: %q", data)
+	}
+}
+
+func TestReadReviewNote_MissingUnderOtherLocale(t *testing.T) {
+	dir := initTestRepo(t)
+	head := runGit(t, dir, "rev-parse", "HEAD")
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	t.Setenv("LANGUAGE", "de")
+	if data, err := readReviewNote(dir, head); err != nil || data != nil {
+		t.Fatalf("missing note: data=%q err=%v", data, err)
+	}
+}
+
+func TestNotesBackend_SyncMirrorsToNote(t *testing.T) {
+	dir := initTestRepo(t)
+	s := newNotesTestSession(t, dir)
+	s.enableNotesBackend(backendGitNotes)
+	if s.notesRepo != dir {
+		t.Fatalf("notesRepo = %q, want %q", s.notesRepo, dir)
+	}
+
+	s.AddComment("README.md", 1, 1, "", "from notes", "", "tester")
+	flushWrites(s)
+	s.WriteFiles()
+
+	head := runGit(t, dir, "rev-parse", "HEAD")
+	if data, _ := readReviewNote(dir, head); data != nil {
+		t.Fatalf("a plain write should not touch the note, got %q", data)
+	}
+	s.syncReviewNote()
+	s.syncReviewNote()
+	if commits := runGit(t, dir, "rev-list", "--count", critNotesRef); commits != "1" {
+		t.Errorf("notes ref has %s commits, want 1 for unchanged content", commits)
+	}
+	data, err := readReviewNote(dir, head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cj CritJSON
+	if err := unmarshalCritJSON(data, &cj); err != nil {
+		t.Fatalf("note is not a review file: %v\n%s", err, data)
+	}
+	if c := cj.Files["README.md"].Comments; len(c) != 1 || c[0].Body != "from notes" {
+		t.Errorf("note comments = %+v", c)
+	}
+}
+
+func TestNotesBackend_RestoresMissingReviewFile(t *testing.T) {
+	dir := initTestRepo(t)
+	head := runGit(t, dir, "rev-parse", "HEAD")
+	note := `{"schema_version":1,"files":{"README.md":{"status":"modified","file_hash":"","comments":[{"id":"c_aaaaaa","start_line":1,"end_line":1,"body":"restored"}]}}}`
+	if err := writeReviewNote(dir, head, []byte(note)); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newNotesTestSession(t, dir)
+	s.enableNotesBackend(backendGitNotes)
+	s.loadCritJSON()
+
+	if _, err := os.Stat(s.critJSONPath()); err != nil {
+		t.Fatalf("review file not restored: %v", err)
+	}
+	if c := s.GetComments("README.md"); len(c) != 1 || c[0].Body != "restored" {
+		t.Errorf("comments = %+v", c)
+	}
+}
+
+func TestNotesBackend_FileBackendIsNoop(t *testing.T) {
+	dir := initTestRepo(t)
+	s := newNotesTestSession(t, dir)
+	s.enableNotesBackend(backendFile)
+	if s.notesRepo != "" {
+		t.Error("file backend should not enable notes")
+	}
+	s.VCS = nil
+	s.enableNotesBackend(backendGitNotes)
+	if s.notesRepo != "" {
+		t.Error("git-notes backend should require a git repository")
+	}
+}
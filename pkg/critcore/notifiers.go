@@ -0,0 +1,154 @@
+package critcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Notifiers post a one-line summary to Slack or Discord when a round is
+// ready or a review finishes ("Changes requested on plan.md: 3 blockers,
+// 5 nits"), for when the reviewer isn't the person who launched the agent.
+// They go through deliverWebhook, so they get the same retries as webhooks.
+
+const (
+	notifierSlack   = "slack"
+	notifierDiscord = "discord"
+)
+
+// notifierConfig is one entry of the notifiers config list (global-only).
+type notifierConfig struct {
+	Service string `json:"service"` // notifierSlack or notifierDiscord
+	URL     string `json:"url"`     // the service's incoming webhook URL
+	Channel string `json:"channel"` // Slack: channel override, e.g. "#reviews"; Discord posts where the URL points
+}
+
+// validateNotifiers checks the notifiers config list.
+func validateNotifiers(notifiers []notifierConfig) error {
+	for i, n := range notifiers {
+		if n.Service != notifierSlack && n.Service != notifierDiscord {
+			return fmt.Errorf("notifiers[%d]: unknown service %q (want %q or %q)", i, n.Service, notifierSlack, notifierDiscord)
+		}
+		if u, err := url.Parse(n.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("notifiers[%d]: invalid url %q (want the https webhook URL from %s)", i, n.URL, n.Service)
+		}
+	}
+	return nil
+}
+
+// severityLabels name comments of each severity in summaries; unclassified
+// comments are just "comments".
+var severityLabels = map[string]string{"blocker": "blocker", "major": "major issue", "minor": "minor issue", "nit": "nit", "": "comment"}
+
+// openCommentSummary counts unresolved comments by severity, most severe
+// first ("3 blockers, 5 nits"), and returns the paths of the files they are
+// on. It returns "" when nothing is open.
+func (s *Session) openCommentSummary() (string, []string) {
+	counts := map[string]int{}
+	var files []string
+	for _, c := range s.GetReviewComments() {
+		if !c.Resolved {
+			counts[c.Severity]++
+		}
+	}
+	for path, comments := range s.GetAllComments() {
+		open := 0
+		for _, c := range comments {
+			if !c.Resolved {
+				counts[c.Severity]++
+				open++
+			}
+		}
+		if open > 0 {
+			files = append(files, path)
+		}
+	}
+	var parts []string
+	for _, severity := range slices.Concat(severityLevels, []string{""}) {
+		if n := counts[severity]; n > 0 {
+			parts = append(parts, pluralize(n, severityLabels[severity]))
+		}
+	}
+	return strings.Join(parts, ", "), files
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// reviewSubject names what sess reviews: its only file, or its branch.
+func reviewSubject(sess *Session, fileCount int) string {
+	switch {
+	case fileCount == 1:
+		return sess.Files[0].Path
+	case sess.Mode == "git" && sess.Branch != "":
+		return "branch " + sess.Branch
+	}
+	return pluralize(fileCount, "file")
+}
+
+// notificationText is the message posted for ev, or "" if the event isn't
+// announced.
+func notificationText(sess *Session, ev hookEvent) string {
+	sess.mu.RLock()
+	fileCount := len(sess.Files)
+	subject := reviewSubject(sess, fileCount)
+	sess.mu.RUnlock()
+
+	switch {
+	case ev.Event == hookRoundComplete:
+		return fmt.Sprintf("Round %d of the review of %s is ready.", ev.Round, subject)
+	case ev.Event != hookFinish:
+		return ""
+	case ev.Approved != nil && *ev.Approved:
+		return fmt.Sprintf("Approved %s in round %d.", subject, ev.Round)
+	}
+	summary, files := sess.openCommentSummary()
+	if len(files) == 1 {
+		subject = files[0]
+	}
+	return fmt.Sprintf("Changes requested on %s: %s.", subject, summary)
+}
+
+// notifierPayload is the JSON body the service expects for text.
+func notifierPayload(n notifierConfig, text string) ([]byte, error) {
+	if n.Service == notifierDiscord {
+		return json.Marshal(map[string]string{"content": text})
+	}
+	body := map[string]string{"text": text}
+	if n.Channel != "" {
+		body["channel"] = n.Channel
+	}
+	return json.Marshal(body)
+}
+
+// sendNotifications posts ev's summary to every notifier in the background.
+func (s *Server) sendNotifications(sess *Session, ev hookEvent) {
+	if len(s.notifiers) == 0 {
+		return
+	}
+	text := notificationText(sess, ev)
+	if text == "" {
+		return
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	for _, n := range s.notifiers {
+		body, err := notifierPayload(n, text)
+		if err != nil {
+			slog.Error("notifier: encoding message", "service", n.Service, "err", err)
+			continue
+		}
+		go func() {
+			if err := deliverWebhook(client, webhookConfig{URL: n.URL}, ev.Event, body, webhookRetryDelays); err != nil {
+				slog.Warn("notifier failed", "service", n.Service, "event", ev.Event, "err", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,92 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestValidateNotifiers(t *testing.T) {
+	ok := []notifierConfig{{Service: "slack", URL: "https://hooks.slack.com/services/T/B/X", Channel: "#reviews"}, {Service: "discord", URL: "https://discord.com/api/webhooks/1/abc"}}
+	if err := validateNotifiers(ok); err != nil {
+		t.Errorf("valid notifiers: %v", err)
+	}
+	for _, bad := range []notifierConfig{{Service: "teams", URL: "https://example.com"}, {Service: "slack", URL: "http://hooks.slack.com/x"}, {Service: "discord"}} {
+		if err := validateNotifiers([]notifierConfig{bad}); err == nil {
+			t.Errorf("validateNotifiers(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestNotificationText(t *testing.T) {
+	_, session := newTestServer(t)
+	for _, severity := range []string{"blocker", "blocker", "blocker", "nit", ""} {
+		c, _ := session.AddComment("test.md", 1, 1, "", "fix", "", "")
+		if severity != "" {
+			session.SetCommentSeverity("test.md", c.ID, severity)
+		}
+	}
+	resolved, _ := session.AddComment("test.md", 2, 2, "", "done", "", "")
+	session.SetCommentResolved("test.md", resolved.ID, true)
+
+	approved, rejected := true, false
+	tests := []struct {
+		ev   hookEvent
+		want string
+	}{
+		{hookEvent{Event: hookRoundComplete, Round: 2}, "Round 2 of the review of test.md is ready."},
+		{hookEvent{Event: hookFinish, Round: 1, Approved: &approved}, "Approved test.md in round 1."},
+		{hookEvent{Event: hookFinish, Round: 1, Approved: &rejected}, "Changes requested on test.md: 3 blockers, 1 nit, 1 comment."},
+		{hookEvent{Event: hookCommentCreated}, ""},
+	}
+	for _, tt := range tests {
+		if got := notificationText(session, tt.ev); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.ev.Event, got, tt.want)
+		}
+	}
+}
+
+func TestNotifierPayload(t *testing.T) {
+	body, _ := notifierPayload(notifierConfig{Service: notifierSlack, Channel: "#reviews"}, "hi")
+	if string(body) != `{"channel":"#reviews","text":"hi"}` {
+		t.Errorf("slack = %s", body)
+	}
+	body, _ = notifierPayload(notifierConfig{Service: notifierDiscord, Channel: "ignored"}, "hi")
+	if string(body) != `{"content":"hi"}` {
+		t.Errorf("discord = %s", body)
+	}
+}
+
+func TestSendNotifications_OnFinish(t *testing.T) {
+	posts := make(chan map[string]string, 2)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		posts <- body
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	s, _ := newTestServer(t) // s.notifiers skips validateNotifiers, so plain http is fine here
+	s.notifiers = []notifierConfig{{Service: notifierDiscord, URL: receiver.URL}}
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/finish", nil))
+
+	select {
+	case body := <-posts:
+		if body["content"] != "Approved test.md in round 1." {
+			t.Errorf("posted %v", body)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notifier was not called")
+	}
+}
+
+func TestMergeConfigs_NotifiersGlobalOnly(t *testing.T) {
+	global := Config{Notifiers: []notifierConfig{{Service: notifierSlack, URL: "https://hooks.slack.com/mine"}}}
+	project := Config{Notifiers: []notifierConfig{{Service: notifierSlack, URL: "https://evil.example.com"}}}
+	if merged := mergeConfigs(global, project, configPresence{}); len(merged.Notifiers) != 1 || merged.Notifiers[0].URL != global.Notifiers[0].URL {
+		t.Errorf("notifiers = %+v, project config must not override them", merged.Notifiers)
+	}
+}
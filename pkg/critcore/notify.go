@@ -0,0 +1,48 @@
+package critcore
+
+import (
+	"log/slog"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// With --notify, the daemon raises a native desktop notification when the
+// agent signals round-complete, so a reviewer who tabbed away knows the next
+// round is ready.
+
+const roundReadyNotification = "The agent finished. The next review round is ready."
+
+// notificationCommand returns the command that shows a desktop notification
+// on goos: osascript on macOS, notify-send on Linux when installed.
+func notificationCommand(goos, title, body string, hasCommand func(string) bool) (browserCommandSpec, bool) {
+	switch goos {
+	case "darwin":
+		script := "display notification " + appleScriptQuote(body) + " with title " + appleScriptQuote(title)
+		return browserCommandSpec{name: "osascript", args: []string{"-e", script}}, true
+	case "linux":
+		if hasCommand("notify-send") {
+			return browserCommandSpec{name: "notify-send", args: []string{title, body}}, true
+		}
+	}
+	return browserCommandSpec{}, false
+}
+
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// sendDesktopNotification shows a notification, logging instead when the
+// platform has no notifier or it fails. Never blocks the caller.
+func sendDesktopNotification(title, body string) {
+	spec, ok := notificationCommand(runtime.GOOS, title, body, commandExists)
+	if !ok {
+		slog.Warn("--notify: no desktop notifier found (needs osascript or notify-send)")
+		return
+	}
+	go func() {
+		if err := exec.Command(spec.name, spec.args...).Run(); err != nil {
+			slog.Warn("--notify: notifier failed", "cmd", spec.name, "err", err)
+		}
+	}()
+}
@@ -0,0 +1,33 @@
+package critcore
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNotificationCommand(t *testing.T) {
+	has := func(string) bool { return true }
+	none := func(string) bool { return false }
+
+	spec, ok := notificationCommand("darwin", "Crit", `say "hi" \ bye`, none)
+	want := browserCommandSpec{name: "osascript", args: []string{"-e", `display notification "say \"hi\" \\ bye" with title "Crit"`}}
+	if !ok || !reflect.DeepEqual(spec, want) {
+		t.Errorf("darwin = %+v, %v", spec, ok)
+	}
+	spec, ok = notificationCommand("linux", "Crit", "ready", has)
+	if !ok || !reflect.DeepEqual(spec, browserCommandSpec{name: "notify-send", args: []string{"Crit", "ready"}}) {
+		t.Errorf("linux = %+v, %v", spec, ok)
+	}
+	if _, ok := notificationCommand("linux", "Crit", "ready", none); ok {
+		t.Error("linux without notify-send should have no notifier")
+	}
+	if _, ok := notificationCommand("windows", "Crit", "ready", has); ok {
+		t.Error("windows should have no notifier")
+	}
+}
+
+func TestParseServerFlags_Notify(t *testing.T) {
+	if sf := parseServerFlags([]string{"--notify", "plan.md"}); !sf.notify || len(sf.fileArgs) != 1 {
+		t.Errorf("flags = %+v", sf)
+	}
+}
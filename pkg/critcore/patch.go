@@ -0,0 +1,264 @@
+package critcore
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// .diff and .patch files are parsed into per-file hunks for the /api/file
+// patch view, so the frontend can colour additions and removals instead of
+// showing raw +/- text. Comments are still placed on lines of the patch file;
+// the review file additionally records the file, side and line in that file
+// the comment starts on (see anchorPatchComments).
+
+// patchFile is one file's section of a patch.
+type patchFile struct {
+	OldPath    string      `json:"old_path,omitempty"` // empty for added files
+	NewPath    string      `json:"new_path,omitempty"` // empty for deleted files
+	Status     string      `json:"status"`             // "added", "deleted", "renamed" or "modified"
+	Binary     bool        `json:"binary,omitempty"`
+	HeaderLine int         `json:"header_line"` // 1-based source line the section starts on
+	Hunks      []patchHunk `json:"hunks"`
+
+	end     int  // last source line of the section
+	headers bool // seen the ---/+++ pair
+}
+
+type patchHunk struct {
+	Header     string      `json:"header"`
+	HeaderLine int         `json:"header_line"`
+	OldStart   int         `json:"old_start"`
+	NewStart   int         `json:"new_start"`
+	Lines      []patchLine `json:"lines"`
+}
+
+// patchLine is one line of a hunk. OldNum/NewNum are 0 on the side the line
+// isn't on.
+type patchLine struct {
+	Type       string `json:"type"` // "context", "add" or "del"
+	Content    string `json:"content"`
+	OldNum     int    `json:"old_num,omitempty"`
+	NewNum     int    `json:"new_num,omitempty"`
+	SourceLine int    `json:"source_line"`
+}
+
+func isPatchPath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".diff", ".patch":
+		return true
+	}
+	return false
+}
+
+// path is the name the file goes by after the patch.
+func (f *patchFile) path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// parsePatch parses a unified diff: git diffs, format-patch mails (the
+// message and signature are skipped) and plain diff -u output. Content with
+// no file sections returns nil.
+func parsePatch(content string) []patchFile {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	var files []patchFile
+	start := func(lineNo int) *patchFile {
+		if len(files) > 0 {
+			files[len(files)-1].end = lineNo - 1
+		}
+		files = append(files, patchFile{Status: "modified", HeaderLine: lineNo})
+		return &files[len(files)-1]
+	}
+	var cur *patchFile
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			cur = start(i + 1)
+			cur.OldPath, cur.NewPath = gitDiffPaths(strings.TrimPrefix(line, "diff --git "))
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if cur == nil || cur.headers || len(cur.Hunks) > 0 {
+				cur = start(i + 1)
+			}
+			cur.headers = true
+			cur.setHeaderPaths(line[4:], lines[i+1][4:])
+			i++
+		case hunkHeaderRe.MatchString(line):
+			if cur == nil {
+				cur = start(i + 1)
+			}
+			var h patchHunk
+			h, i = parsePatchHunk(lines, i)
+			cur.Hunks = append(cur.Hunks, h)
+		case cur != nil:
+			cur.setGitHeader(line)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	files[len(files)-1].end = len(lines)
+	return files
+}
+
+// parsePatchHunk parses the hunk whose header is lines[i], consuming as many
+// lines as the header's counts call for. It returns the index of the hunk's
+// last line.
+func parsePatchHunk(lines []string, i int) (patchHunk, int) {
+	m := hunkHeaderRe.FindStringSubmatch(lines[i])
+	h := patchHunk{Header: lines[i], HeaderLine: i + 1, Lines: []patchLine{}}
+	h.OldStart, _ = strconv.Atoi(m[1])
+	h.NewStart, _ = strconv.Atoi(m[3])
+	oldLeft, newLeft := hunkCount(m[2]), hunkCount(m[4])
+	oldNum, newNum := h.OldStart, h.NewStart
+	for (oldLeft > 0 || newLeft > 0) && i+1 < len(lines) {
+		line := lines[i+1]
+		pl := patchLine{SourceLine: i + 2}
+		switch {
+		case strings.HasPrefix(line, "+") && newLeft > 0:
+			pl.Type, pl.NewNum = "add", newNum
+			newNum++
+			newLeft--
+		case strings.HasPrefix(line, "-") && oldLeft > 0:
+			pl.Type, pl.OldNum = "del", oldNum
+			oldNum++
+			oldLeft--
+		case (line == "" || strings.HasPrefix(line, " ")) && oldLeft > 0 && newLeft > 0:
+			// A bare empty line is a blank context line with its space stripped.
+			pl.Type, pl.OldNum, pl.NewNum = "context", oldNum, newNum
+			oldNum++
+			newNum++
+			oldLeft--
+			newLeft--
+		case strings.HasPrefix(line, `\`):
+			i++ // "\ No newline at end of file"
+			continue
+		default:
+			return h, i // truncated hunk
+		}
+		if line != "" {
+			pl.Content = line[1:]
+		}
+		h.Lines = append(h.Lines, pl)
+		i++
+	}
+	return h, i
+}
+
+// hunkCount parses a hunk header's line count, which defaults to 1 when
+// omitted ("@@ -3 +3 @@").
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// gitDiffPaths splits the "a/old b/new" of a diff --git line. Paths with
+// spaces are ambiguous here; the ---/+++ or rename lines that follow win.
+func gitDiffPaths(rest string) (string, string) {
+	if !strings.HasPrefix(rest, "a/") {
+		return "", ""
+	}
+	idx := strings.Index(rest, " b/")
+	if idx < 0 {
+		return "", ""
+	}
+	return rest[2:idx], rest[idx+3:]
+}
+
+// setHeaderPaths applies the paths of a ---/+++ pair, dropping timestamps and
+// git's a/ b/ prefixes. /dev/null marks an added or deleted file.
+func (f *patchFile) setHeaderPaths(oldSpec, newSpec string) {
+	clean := func(spec, prefix string) string {
+		if tab := strings.IndexByte(spec, '\t'); tab >= 0 {
+			spec = spec[:tab]
+		}
+		if spec == "/dev/null" {
+			return ""
+		}
+		return strings.TrimPrefix(spec, prefix)
+	}
+	f.OldPath, f.NewPath = clean(oldSpec, "a/"), clean(newSpec, "b/")
+	switch {
+	case f.OldPath == "":
+		f.Status = "added"
+	case f.NewPath == "":
+		f.Status = "deleted"
+	case f.OldPath != f.NewPath:
+		f.Status = "renamed"
+	}
+}
+
+// setGitHeader applies git's extended header lines between diff --git and the
+// first hunk; anything else (commit messages, signatures) is ignored.
+func (f *patchFile) setGitHeader(line string) {
+	switch {
+	case strings.HasPrefix(line, "new file mode "):
+		f.Status, f.OldPath = "added", ""
+	case strings.HasPrefix(line, "deleted file mode "):
+		f.Status, f.NewPath = "deleted", ""
+	case strings.HasPrefix(line, "rename from "):
+		f.Status, f.OldPath = "renamed", strings.TrimPrefix(line, "rename from ")
+	case strings.HasPrefix(line, "rename to "):
+		f.Status, f.NewPath = "renamed", strings.TrimPrefix(line, "rename to ")
+	case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+		f.Binary = true
+	}
+}
+
+// addPatchSnapshot adds the parsed patch to a /api/file response for
+// .diff/.patch files that parse.
+func addPatchSnapshot(snap map[string]any, path, content string) {
+	if !isPatchPath(path) {
+		return
+	}
+	if files := parsePatch(content); files != nil {
+		snap["patch"] = files
+	}
+}
+
+// anchorPatchComments fills PatchFile, PatchSide and PatchLine on line
+// comments with where in the patched file their first line is. Context lines
+// count as the new side. A comment starting on a file or hunk header gets
+// only PatchFile.
+func anchorPatchComments(comments []Comment, files []patchFile) {
+	for i := range comments {
+		c := &comments[i]
+		c.PatchFile, c.PatchSide, c.PatchLine = "", "", 0
+		if c.Scope == "file" || c.StartLine <= 0 {
+			continue
+		}
+		for fi := range files {
+			f := &files[fi]
+			if c.StartLine < f.HeaderLine || c.StartLine > f.end {
+				continue
+			}
+			c.PatchFile = f.path()
+			c.PatchSide, c.PatchLine = patchLineAt(f, c.StartLine)
+			break
+		}
+	}
+}
+
+func patchLineAt(f *patchFile, sourceLine int) (string, int) {
+	for _, h := range f.Hunks {
+		for _, l := range h.Lines {
+			if l.SourceLine != sourceLine {
+				continue
+			}
+			if l.Type == "del" {
+				return "old", l.OldNum
+			}
+			return "new", l.NewNum
+		}
+	}
+	return "", 0
+}
@@ -0,0 +1,145 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+const testPatch = `From 1234 Mon Sep 17 00:00:00 2001
+Subject: [PATCH] Tweak things
+
+---
+ a.go | 3 ++-
+ 2 files changed
+
+diff --git a/a.go b/a.go
+index 111..222 100644
+--- a/a.go
++++ b/a.go
+@@ -1,3 +1,3 @@
+ package main
+-var x = 1
++var x = 2
+
+diff --git a/old.txt b/new.txt
+similarity index 90%
+rename from old.txt
+rename to new.txt
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+\ No newline at end of file
+--
+2.40.0
+`
+
+func TestParsePatch(t *testing.T) {
+	files := parsePatch(testPatch)
+	if len(files) != 3 {
+		t.Fatalf("got %d files, want 3: %+v", len(files), files)
+	}
+	a := files[0]
+	if a.Status != "modified" || a.NewPath != "a.go" || a.HeaderLine != 8 || len(a.Hunks) != 1 {
+		t.Fatalf("a.go = %+v", a)
+	}
+	lines := a.Hunks[0].Lines
+	if len(lines) != 4 {
+		t.Fatalf("hunk lines = %+v", lines)
+	}
+	if lines[1] != (patchLine{Type: "del", Content: "var x = 1", OldNum: 2, SourceLine: 14}) {
+		t.Errorf("del = %+v", lines[1])
+	}
+	if lines[2] != (patchLine{Type: "add", Content: "var x = 2", NewNum: 2, SourceLine: 15}) {
+		t.Errorf("add = %+v", lines[2])
+	}
+	if lines[3].Type != "context" || lines[3].OldNum != 3 || lines[3].NewNum != 3 {
+		t.Errorf("blank context = %+v", lines[3])
+	}
+	if r := files[1]; r.Status != "renamed" || r.OldPath != "old.txt" || r.NewPath != "new.txt" || len(r.Hunks) != 0 {
+		t.Errorf("rename = %+v", r)
+	}
+	d := files[2]
+	if d.Status != "deleted" || d.path() != "gone.txt" || len(d.Hunks) != 1 || len(d.Hunks[0].Lines) != 1 {
+		t.Errorf("delete = %+v", d)
+	}
+
+	plain := parsePatch("--- x.c\t2024-01-01\n+++ x.c\t2024-01-02\n@@ -1 +1 @@\n-a\n+b\n--- y.c\n+++ y.c\n@@ -1 +1,2 @@\n a\n+b\n")
+	if len(plain) != 2 || plain[0].NewPath != "x.c" || plain[1].NewPath != "y.c" || len(plain[1].Hunks[0].Lines) != 2 {
+		t.Errorf("plain diff = %+v", plain)
+	}
+	if parsePatch("just some text\n") != nil {
+		t.Error("text without file sections should not parse")
+	}
+}
+
+func TestAnchorPatchComments(t *testing.T) {
+	files := parsePatch(testPatch)
+	comments := []Comment{
+		{StartLine: 14, EndLine: 15}, // removed line
+		{StartLine: 15, EndLine: 15}, // added line
+		{StartLine: 13, EndLine: 13}, // context
+		{StartLine: 12, EndLine: 12}, // hunk header
+		{StartLine: 2, EndLine: 2},   // commit message
+		{Scope: "file"},
+	}
+	anchorPatchComments(comments, files)
+
+	want := []struct {
+		file, side string
+		line       int
+	}{
+		{"a.go", "old", 2},
+		{"a.go", "new", 2},
+		{"a.go", "new", 1},
+		{"a.go", "", 0},
+		{"", "", 0},
+		{"", "", 0},
+	}
+	for i, w := range want {
+		c := comments[i]
+		if c.PatchFile != w.file || c.PatchSide != w.side || c.PatchLine != w.line {
+			t.Errorf("comment %d = (%q, %q, %d), want (%q, %q, %d)", i, c.PatchFile, c.PatchSide, c.PatchLine, w.file, w.side, w.line)
+		}
+	}
+}
+
+func TestHandleFile_Patch(t *testing.T) {
+	s, session := newTestServer(t)
+	f := session.Files[0]
+	f.Path = "fix.patch"
+	f.FileType = "code"
+	f.Content = testPatch
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/file?path=fix.patch", nil))
+	var resp struct {
+		Language string      `json:"language"`
+		Patch    []patchFile `json:"patch"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Language != "diff" || len(resp.Patch) != 3 || resp.Patch[0].Hunks[0].Lines[2].Type != "add" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	session.AddComment("fix.patch", 15, 15, "", "why 2?", "", "")
+	session.WriteFiles()
+	data, err := os.ReadFile(session.critJSONPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cj CritJSON
+	if err := json.Unmarshal(data, &cj); err != nil {
+		t.Fatal(err)
+	}
+	c := cj.Files["fix.patch"].Comments[0]
+	if c.PatchFile != "a.go" || c.PatchSide != "new" || c.PatchLine != 2 {
+		t.Errorf("patch anchor = (%q, %q, %d)", c.PatchFile, c.PatchSide, c.PatchLine)
+	}
+}
@@ -0,0 +1,229 @@
+package critcore
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// /plain is the review without JavaScript: static HTML with numbered lines,
+// the comments, and a form for adding one, for locked-down browsers, screen
+// readers that struggle with the app, and lynx. GET /plain lists the files
+// and the review-level comments; GET /plain?path=<file> shows one file. Forms
+// post back to /plain with the CSRF token in a hidden field, since they
+// can't set csrfHeader.
+
+// plainPage is the data for plainTemplate.
+type plainPage struct {
+	CSRF           string
+	Round          int
+	Files          []SessionFileInfo // index only
+	ReviewComments []Comment         // index only
+	Path           string            // file view only
+	Lines          []plainLine
+	Comments       []Comment
+	Note           string // shown instead of lines for files without text
+}
+
+// plainLine is one numbered line of the file view.
+type plainLine struct {
+	N         int
+	Text      string
+	Commented bool
+}
+
+var plainTemplate = template.Must(template.New("plain").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Path}}{{.Path}} - {{end}}crit review</title>
+<style>
+body { font-family: sans-serif; max-width: 60rem; margin: 1rem auto; padding: 0 1rem; }
+table.code { border-collapse: collapse; font-family: monospace; width: 100%; }
+table.code td { padding: 0 .5rem; white-space: pre-wrap; vertical-align: top; }
+table.code td.n { color: #777; text-align: right; user-select: none; }
+tr.commented td { background: #fff3c4; }
+.body { white-space: pre-wrap; }
+textarea { width: 100%; }
+</style>
+</head>
+<body>
+{{define "comments"}}
+<ul>
+{{range .}}<li id="{{.ID}}">
+<p>{{if .StartLine}}<a href="#L{{.StartLine}}">Lines {{.StartLine}}–{{.EndLine}}</a>{{else}}File{{end}}{{with .Author}} · {{.}}{{end}}{{if .Severity}} · {{.Severity}}{{end}}{{if .Resolved}} · resolved{{end}}</p>
+<div class="body">{{.Body}}</div>
+{{if and .Drifted .Anchor}}<p>The lines have changed since. Originally on:</p><pre>{{.Anchor}}</pre>{{end}}
+{{if .Replies}}<ul>{{range .Replies}}<li><p>{{with .Author}}{{.}}{{else}}Reply{{end}}</p><div class="body">{{.Body}}</div></li>{{end}}</ul>{{end}}
+</li>
+{{else}}<li>No comments yet.</li>
+{{end}}
+</ul>
+{{end}}
+{{if .Path}}
+<p><a href="/plain">All files</a></p>
+<h1>{{.Path}}</h1>
+{{with .Note}}<p>{{.}}</p>{{else}}
+<table class="code">
+{{range .Lines}}<tr id="L{{.N}}"{{if .Commented}} class="commented"{{end}}><td class="n">{{.N}}</td><td>{{.Text}}</td></tr>
+{{end}}
+</table>
+{{end}}
+<h2>Comments</h2>
+{{template "comments" .Comments}}
+<h2>Add a comment</h2>
+<form method="post" action="/plain">
+<input type="hidden" name="csrf" value="{{.CSRF}}">
+<input type="hidden" name="path" value="{{.Path}}">
+<p><label>From line <input type="number" name="start_line" min="1"></label>
+<label>to line <input type="number" name="end_line" min="1"></label>
+(leave empty to comment on the whole file)</p>
+<p><label>Comment<br><textarea name="body" rows="5" required></textarea></label></p>
+<p><button type="submit">Add comment</button></p>
+</form>
+{{else}}
+<h1>crit review{{if .Round}}, round {{.Round}}{{end}}</h1>
+<h2>Files</h2>
+<ul>
+{{range .Files}}<li><a href="/plain?path={{.Path}}">{{.Path}}</a>{{with .Status}} ({{.}}){{end}}{{with .CommentCount}}, {{.}} comments{{end}}</li>
+{{end}}
+</ul>
+<h2>Review comments</h2>
+{{template "comments" .ReviewComments}}
+<h2>Add a review comment</h2>
+<form method="post" action="/plain">
+<input type="hidden" name="csrf" value="{{.CSRF}}">
+<p><label>Comment<br><textarea name="body" rows="5" required></textarea></label></p>
+<p><button type="submit">Add comment</button></p>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// handlePlain serves GET and POST /plain.
+func (s *Server) handlePlain(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.getPlain(w, r)
+	case http.MethodPost:
+		s.postPlain(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getPlain renders the index, or the file named by ?path=.
+func (s *Server) getPlain(w http.ResponseWriter, r *http.Request) {
+	session := s.session.Load()
+	page := plainPage{CSRF: s.csrfToken, Round: session.GetReviewRound()}
+	if page.Path = r.URL.Query().Get("path"); page.Path == "" {
+		info := session.GetSessionInfo()
+		page.Files, page.ReviewComments = info.Files, info.ReviewComments
+	} else {
+		snapshot, ok := session.GetFileSnapshot(page.Path)
+		if !ok {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		page.Comments = session.GetComments(page.Path)
+		content, _ := snapshot["content"].(string)
+		page.Lines = plainLines(content, page.Comments)
+		if len(page.Lines) == 0 {
+			page.Note = "This file has no text to show."
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := plainTemplate.Execute(w, page); err != nil {
+		http.Error(w, "Failed to render page", http.StatusInternalServerError)
+	}
+}
+
+// plainLines numbers content's lines (as countLines counts them), marking
+// those comments cover.
+func plainLines(content string, comments []Comment) []plainLine {
+	if content == "" {
+		return nil
+	}
+	texts := splitLines(strings.TrimSuffix(content, "\n"))
+	lines := make([]plainLine, len(texts))
+	for i, text := range texts {
+		lines[i] = plainLine{N: i + 1, Text: text}
+	}
+	for _, c := range comments {
+		for n := max(c.StartLine, 1); n <= min(c.EndLine, len(lines)); n++ {
+			lines[n-1].Commented = true
+		}
+	}
+	return lines
+}
+
+// postPlain adds the comment from a /plain form and redirects back to it.
+func (s *Server) postPlain(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.PostFormValue("csrf")), []byte(s.csrfToken)) != 1 {
+		http.Error(w, "Forbidden: missing or invalid form token, reload the page", http.StatusForbidden)
+		return
+	}
+	body, ok := s.acceptCommentBody(w, r.PostFormValue("body"), "Comment")
+	if !ok {
+		return
+	}
+	session := s.session.Load()
+	if !s.acceptNewComment(w, session) {
+		return
+	}
+	path := r.PostFormValue("path")
+	c, ok := s.addPlainComment(w, session, path, r.PostFormValue("start_line"), r.PostFormValue("end_line"), body)
+	if !ok {
+		return
+	}
+	s.emitEvent(session, hookEvent{Event: hookCommentCreated, Path: path, Comment: &c})
+	target := "/plain"
+	if path != "" {
+		target += "?path=" + url.QueryEscape(path)
+	}
+	http.Redirect(w, r, target+"#"+c.ID, http.StatusSeeOther)
+}
+
+// addPlainComment adds a review comment when path is empty, a file comment
+// when start is, and a comment on lines start to end (end defaults to start)
+// otherwise. Errors are written to w and reported as !ok.
+func (s *Server) addPlainComment(w http.ResponseWriter, session *Session, path, start, end, body string) (Comment, bool) {
+	if path == "" {
+		return session.AddReviewComment(body, s.author), true
+	}
+	if start == "" {
+		c, ok := session.AddFileComment(path, body, s.author)
+		if !ok {
+			http.Error(w, "File not found", http.StatusNotFound)
+		}
+		return c, ok
+	}
+	startLine, err := strconv.Atoi(start)
+	endLine := startLine
+	if err == nil && end != "" {
+		endLine, err = strconv.Atoi(end)
+	}
+	if err != nil {
+		http.Error(w, "Lines must be numbers", http.StatusBadRequest)
+		return Comment{}, false
+	}
+	if err := session.checkLineRange(path, "", startLine, endLine); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return Comment{}, false
+	}
+	c, ok := session.AddComment(path, startLine, endLine, "", body, "", s.author)
+	if !ok {
+		http.Error(w, "File not found", http.StatusNotFound)
+	}
+	return c, ok
+}
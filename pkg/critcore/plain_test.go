@@ -0,0 +1,107 @@
+package critcore
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestPlain_FileView(t *testing.T) {
+	s, session := newTestServer(t)
+	session.AddComment("test.md", 2, 2, "", "<b>fix</b> this", "", "alice")
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/plain?path=test.md", nil))
+	if w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	page := w.Body.String()
+	for _, want := range []string{
+		`<tr id="L1"><td class="n">1</td><td>line1</td></tr>`,
+		`<tr id="L2" class="commented">`,
+		`&lt;b&gt;fix&lt;/b&gt; this`,
+		`name="csrf" value="` + s.csrfToken + `"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page lacks %q", want)
+		}
+	}
+	if strings.Contains(page, `id="L4"`) {
+		t.Error("trailing newline rendered as a line")
+	}
+
+	session.mu.Lock()
+	session.Files[0].Comments[0].Drifted = true
+	session.Files[0].Comments[0].Anchor = "old <line>"
+	session.mu.Unlock()
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/plain?path=test.md", nil))
+	if !strings.Contains(w.Body.String(), "<pre>old &lt;line&gt;</pre>") {
+		t.Error("drifted comment doesn't show its original text")
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/plain?path=missing.md", nil))
+	if w.Code != 404 {
+		t.Errorf("missing file: status = %d, want 404", w.Code)
+	}
+}
+
+func TestPlain_Index(t *testing.T) {
+	s, session := newTestServer(t)
+	session.AddReviewComment("overall fine", "bob")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/plain", nil))
+	page := w.Body.String()
+	if !strings.Contains(page, `<a href="/plain?path=test.md">test.md</a>`) || !strings.Contains(page, "overall fine") {
+		t.Errorf("index = %s", page)
+	}
+}
+
+func TestPlain_PostComment(t *testing.T) {
+	s, session := newTestServer(t)
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/plain", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", "http://example.com")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(url.Values{"csrf": {s.csrfToken}, "path": {"test.md"}, "start_line": {"1"}, "end_line": {"2"}, "body": {"line comment"}})
+	if w.Code != 303 || !strings.HasPrefix(w.Header().Get("Location"), "/plain?path=test.md#") {
+		t.Fatalf("line comment: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	comments := session.GetComments("test.md")
+	if len(comments) != 1 || comments[0].StartLine != 1 || comments[0].EndLine != 2 || comments[0].Body != "line comment" {
+		t.Fatalf("comments = %+v", comments)
+	}
+
+	if w := post(url.Values{"csrf": {s.csrfToken}, "path": {"test.md"}, "body": {"file comment"}}); w.Code != 303 {
+		t.Errorf("file comment: status = %d", w.Code)
+	}
+	if w := post(url.Values{"csrf": {s.csrfToken}, "body": {"review comment"}}); w.Code != 303 || w.Header().Get("Location") != "/plain#"+session.GetReviewComments()[0].ID {
+		t.Errorf("review comment: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	tests := []struct {
+		name string
+		form url.Values
+		want int
+	}{
+		{"no form token", url.Values{"path": {"test.md"}, "body": {"x"}}, 403},
+		{"empty body", url.Values{"csrf": {s.csrfToken}, "path": {"test.md"}, "body": {"  "}}, 400},
+		{"line out of range", url.Values{"csrf": {s.csrfToken}, "path": {"test.md"}, "start_line": {"9"}, "body": {"x"}}, 400},
+		{"line not a number", url.Values{"csrf": {s.csrfToken}, "path": {"test.md"}, "start_line": {"one"}, "body": {"x"}}, 400},
+	}
+	for _, tt := range tests {
+		if got := post(tt.form).Code; got != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+	if n := session.TotalCommentCount(); n != 3 {
+		t.Errorf("comments = %d, want 3", n)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"crypto/sha256"
@@ -16,11 +16,11 @@ import (
 // planStorageDir returns the managed storage directory for a plan session.
 // Uses the slug directly as the directory name (not a hash) for human readability.
 func planStorageDir(slug string) (string, error) {
-	home, err := os.UserHomeDir()
+	root, err := storageRoot()
 	if err != nil {
-		return "", fmt.Errorf("determining home directory: %w", err)
+		return "", err
 	}
-	return filepath.Join(home, ".crit", "plans", slug), nil
+	return existsOrLegacy(filepath.Join(root, "plans", slug), "plans", slug), nil
 }
 
 // planSessionKey computes a session key for plan mode.
@@ -58,10 +58,11 @@ func savePlanVersion(dir string, content []byte) (int, error) {
 	versionPath := filepath.Join(dir, fmt.Sprintf("v%03d.md", ver))
 	currentPath := filepath.Join(dir, "current.md")
 
-	if err := os.WriteFile(versionPath, content, 0644); err != nil {
+	if err := atomicWriteFile(versionPath, content, 0644); err != nil {
 		return 0, fmt.Errorf("writing version %d: %w", ver, err)
 	}
-	if err := os.WriteFile(currentPath, content, 0644); err != nil {
+	// current.md is read by the running daemon; never expose a partial write.
+	if err := atomicWriteFile(currentPath, content, 0644); err != nil {
 		return 0, fmt.Errorf("writing current.md: %w", err)
 	}
 
@@ -141,11 +142,11 @@ func resolveSlug(content []byte) string {
 
 // planSessionsFile returns the path to the plan sessions mapping file.
 func planSessionsFile() (string, error) {
-	home, err := os.UserHomeDir()
+	root, err := storageRoot()
 	if err != nil {
-		return "", fmt.Errorf("determining home directory: %w", err)
+		return "", err
 	}
-	return filepath.Join(home, ".crit", "plan-sessions.json"), nil
+	return filepath.Join(root, "plan-sessions.json"), nil
 }
 
 type planSessionMapping struct {
@@ -259,14 +260,8 @@ func savePlanSlug(sessionID, slug string) error {
 		return err
 	}
 
-	// Atomic write via temp file + rename to prevent partial writes.
-	tmpPath := path + ".tmp"
-	if err := os.WriteFile(tmpPath, out, 0644); err != nil {
-		return fmt.Errorf("writing plan sessions temp file: %w", err)
-	}
-	if err := os.Rename(tmpPath, path); err != nil {
-		os.Remove(tmpPath)
-		return fmt.Errorf("renaming plan sessions temp file: %w", err)
+	if err := atomicWriteFile(path, out, 0644); err != nil {
+		return fmt.Errorf("writing plan sessions: %w", err)
 	}
 	return nil
 }
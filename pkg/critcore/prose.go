@@ -0,0 +1,231 @@
+package critcore
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// The prose check is a built-in linter for markdown files (prose_check
+// config): broken relative links and #anchors, TODO/TBD markers, empty
+// sections and duplicate headings. It runs with the configured linters and
+// its comments carry Comment.Tool proseCheckTool, so the mechanical nits are
+// out of the way before a human reads the plan.
+
+// proseCheckTool tags the prose check's comments.
+const proseCheckTool = "auto"
+
+// proseMarkerPattern matches the placeholder markers a finished plan
+// shouldn't have.
+var proseMarkerPattern = regexp.MustCompile(`\b(TODO|TBD|FIXME|XXX)\b`)
+
+// proseHeading is a heading the prose check has seen.
+type proseHeading struct {
+	level int
+	text  string
+	line  int
+}
+
+// proseFindings checks a markdown document. absPath locates relative link
+// targets; with "" they aren't checked.
+func proseFindings(content, absPath string) []finding {
+	// Blank out frontmatter, keeping its lines, so "---" isn't read as a
+	// setext heading.
+	lines := strings.Split(content, "\n")
+	if end := frontmatterEnd(lines); end > 0 {
+		for i := 0; i <= end; i++ {
+			lines[i] = ""
+		}
+	}
+	source := []byte(strings.Join(lines, "\n"))
+	doc := goldmark.New(goldmark.WithExtensions(extension.GFM)).Parser().Parse(text.NewReader(source))
+
+	lineStarts := []int{0}
+	for i, b := range source {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	lineOf := func(offset int) int {
+		return sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset })
+	}
+
+	var headings []proseHeading
+	var links []*ast.Link
+	var findings []finding
+	markerLines := map[int]bool{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			headings = append(headings, proseHeading{level: n.Level, text: nodeText(n, source), line: lineOf(n.Lines().At(0).Start)})
+		case *ast.Link:
+			links = append(links, n)
+		case *ast.CodeSpan, *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			line := lineOf(n.Segment.Start)
+			if m := proseMarkerPattern.FindString(string(n.Segment.Value(source))); m != "" && !markerLines[line] {
+				markerLines[line] = true
+				findings = append(findings, finding{startLine: line, endLine: line, message: fmt.Sprintf("`%s` left in the text.", m), severity: "nit"})
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	findings = append(findings, headingFindings(doc, headings, lineOf)...)
+	findings = append(findings, linkFindings(links, headings, absPath, lineOf)...)
+	for i := range findings {
+		findings[i].tool = proseCheckTool
+	}
+	return findings
+}
+
+// headingFindings reports duplicate headings and top-level headings with
+// nothing under them before the next heading of the same or a higher level.
+func headingFindings(doc ast.Node, headings []proseHeading, lineOf func(int) int) []finding {
+	var findings []finding
+	first := map[string]int{}
+	for _, h := range headings {
+		slug := headingSlug(h.text)
+		if line, ok := first[slug]; ok {
+			findings = append(findings, finding{startLine: h.line, endLine: h.line, message: fmt.Sprintf("Duplicate heading (also on line %d).", line), severity: "nit"})
+			continue
+		}
+		first[slug] = h.line
+	}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		h, ok := n.(*ast.Heading)
+		if !ok {
+			continue
+		}
+		next, isHeading := n.NextSibling().(*ast.Heading)
+		if n.NextSibling() == nil || (isHeading && next.Level <= h.Level) {
+			line := lineOf(h.Lines().At(0).Start)
+			findings = append(findings, finding{startLine: line, endLine: line, message: "Empty section: nothing under this heading.", severity: "nit"})
+		}
+	}
+	return findings
+}
+
+// linkFindings reports the broken links among links.
+func linkFindings(links []*ast.Link, headings []proseHeading, absPath string, lineOf func(int) int) []finding {
+	// Repeated headings get -1, -2, ... anchors, as on GitHub.
+	slugs := map[string]bool{}
+	seen := map[string]int{}
+	for _, h := range headings {
+		slug := headingSlug(h.text)
+		if n := seen[slug]; n > 0 {
+			slugs[fmt.Sprintf("%s-%d", slug, n)] = true
+		}
+		slugs[slug] = true
+		seen[slug]++
+	}
+	var findings []finding
+	for _, l := range links {
+		if msg := brokenLink(string(l.Destination), absPath, slugs); msg != "" {
+			line := inlineLine(l, lineOf)
+			findings = append(findings, finding{startLine: line, endLine: line, message: msg, severity: "minor"})
+		}
+	}
+	return findings
+}
+
+// brokenLink describes what's wrong with a link to dest from the document at
+// absPath, or returns "". Only #anchors and relative paths are checked;
+// crit doesn't fetch URLs.
+func brokenLink(dest, absPath string, slugs map[string]bool) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || dest == "" {
+		return ""
+	}
+	if u.Path == "" {
+		if frag := strings.ToLower(u.Fragment); frag != "" && !slugs[frag] {
+			return fmt.Sprintf("Broken link: no heading matches `#%s`.", u.Fragment)
+		}
+		return ""
+	}
+	if absPath == "" || strings.HasPrefix(u.Path, "/") {
+		return "" // site-rooted paths depend on where the document is published
+	}
+	target := filepath.Join(filepath.Dir(absPath), filepath.FromSlash(u.Path))
+	if _, err := os.Stat(target); err != nil {
+		return fmt.Sprintf("Broken link: `%s` does not exist.", u.Path)
+	}
+	return ""
+}
+
+// headingSlug is the anchor GitHub gives a heading: lowercased, punctuation
+// dropped, spaces turned into hyphens.
+func headingSlug(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case r == ' ':
+			b.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// nodeText is the plain text of an inline tree.
+func nodeText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := c.(*ast.Text); ok && entering {
+			b.Write(t.Segment.Value(source))
+		}
+		return ast.WalkContinue, nil
+	})
+	return b.String()
+}
+
+// inlineLine is the line an inline node starts on: its first text's, or
+// failing that its block's.
+func inlineLine(n ast.Node, lineOf func(int) int) int {
+	line := 0
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := c.(*ast.Text); ok && entering {
+			line = lineOf(t.Segment.Start)
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	for p := n; line == 0 && p != nil; p = p.Parent() {
+		if p.Type() == ast.TypeBlock && p.Lines().Len() > 0 {
+			line = lineOf(p.Lines().At(0).Start)
+		}
+	}
+	return line
+}
+
+// proseCheckFindings runs the prose check on the session's markdown files.
+func (s *Session) proseCheckFindings() []finding {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var findings []finding
+	for _, f := range s.Files {
+		if f.FileType != "markdown" || f.Status == "deleted" || f.Content == "" {
+			continue
+		}
+		for _, fd := range proseFindings(f.Content, f.AbsPath) {
+			fd.path = f.Path
+			findings = append(findings, fd)
+		}
+	}
+	return findings
+}
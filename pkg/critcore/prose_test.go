@@ -0,0 +1,103 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProseFindings(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "api.md"), []byte("# API\n"), 0644)
+	doc := strings.Join([]string{
+		"---",         // 1
+		"title: Plan", // 2
+		"---",         // 3
+		"# Plan",      // 4
+		"",            // 5
+		"See [the API](api.md), [setup](#setup) and [usage](#usage-1).", // 6
+		"Also [gone](docs/missing.md) and [site](https://example.com).", // 7
+		"",                    // 8
+		"## Setup",            // 9
+		"",                    // 10
+		"## Usage",            // 11
+		"",                    // 12
+		"Rollout is TBD.",     // 13
+		"",                    // 14
+		"```",                 // 15
+		"TODO: not prose",     // 16
+		"```",                 // 17
+		"",                    // 18
+		"## Usage",            // 19
+		"",                    // 20
+		"Keep `XXX` in code.", // 21
+		"",                    // 22
+		"## Open questions",   // 23
+	}, "\n")
+
+	got := map[int]string{}
+	for _, f := range proseFindings(doc, filepath.Join(dir, "plan.md")) {
+		if f.tool != proseCheckTool {
+			t.Errorf("finding not tagged: %+v", f)
+		}
+		got[f.startLine] += f.message
+	}
+	want := map[int]string{
+		7:  "Broken link: `docs/missing.md` does not exist.",
+		9:  "Empty section: nothing under this heading.",
+		13: "`TBD` left in the text.",
+		19: "Duplicate heading (also on line 11).",
+		23: "Empty section: nothing under this heading.",
+	}
+	if len(got) != len(want) {
+		t.Errorf("findings = %v", got)
+	}
+	for line, msg := range want {
+		if got[line] != msg {
+			t.Errorf("line %d: got %q, want %q", line, got[line], msg)
+		}
+	}
+}
+
+func TestBrokenLink_Anchors(t *testing.T) {
+	slugs := map[string]bool{"setup": true}
+	if msg := brokenLink("#nope", "", slugs); msg != "Broken link: no heading matches `#nope`." {
+		t.Errorf("missing anchor: %q", msg)
+	}
+	for _, ok := range []string{"#Setup", "mailto:me@example.com", "/docs/site-rooted.md", "other.md"} {
+		if msg := brokenLink(ok, "", slugs); msg != "" {
+			t.Errorf("brokenLink(%q) = %q, want none", ok, msg)
+		}
+	}
+	if got := headingSlug("Step 2: Roll out (v1.2)"); got != "step-2-roll-out-v12" {
+		t.Errorf("slug = %q", got)
+	}
+}
+
+func TestRunLinters_ProseCheck(t *testing.T) {
+	_, session := newTestServer(t)
+	session.proseCheck = true
+	session.Files[0].Content = "# Plan\n\nTODO: write this\n"
+	session.runLinters()
+	comments := session.GetComments("test.md")
+	if len(comments) != 1 || comments[0].Tool != proseCheckTool || comments[0].StartLine != 3 || comments[0].Severity != "nit" {
+		t.Fatalf("comments = %+v", comments)
+	}
+
+	session.Files[0].Content = "# Plan\n\nWritten.\n"
+	session.runLinters()
+	if comments := session.GetComments("test.md"); len(comments) != 0 {
+		t.Errorf("fixed finding should be gone: %+v", comments)
+	}
+}
+
+func TestMergeConfigs_ProseCheck(t *testing.T) {
+	global := Config{ProseCheck: true}
+	if merged := mergeConfigs(global, Config{}, configPresence{}); !merged.ProseCheck {
+		t.Error("absent project key should keep the global prose_check")
+	}
+	if merged := mergeConfigs(global, Config{}, configPresence{ProseCheck: true}); merged.ProseCheck {
+		t.Error("project prose_check: false should turn it off")
+	}
+}
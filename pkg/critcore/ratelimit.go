@@ -0,0 +1,132 @@
+package critcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mutating API requests (anything but GET, HEAD and OPTIONS under /api/) are
+// rate limited per client with a token bucket (rate_limit config), so an
+// agent stuck in a loop can't hammer /api/comments. A client is its address
+// plus User-Agent: everything talks to the daemon from localhost, and this
+// keeps the reviewer's browser out of a looping agent's bucket. Over the
+// limit, requests get a 429 with Retry-After.
+
+const (
+	defaultRateLimit = 10 // requests per second
+	defaultRateBurst = 60
+
+	// maxRateBuckets bounds the clients tracked; full buckets are dropped
+	// past it, as they're the same as new ones.
+	maxRateBuckets = 1000
+)
+
+// rateLimitConfig is the rate_limit config object.
+type rateLimitConfig struct {
+	Rate     float64 `json:"rate,omitempty"`     // sustained requests per second (default 10)
+	Burst    int     `json:"burst,omitempty"`    // requests allowed at once (default 60)
+	Disabled bool    `json:"disabled,omitempty"` // turn rate limiting off
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter holds one token bucket per client.
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// newRateLimiter returns the limiter cfg describes, or nil when it is
+// disabled.
+func newRateLimiter(cfg rateLimitConfig) *rateLimiter {
+	if cfg.Disabled {
+		return nil
+	}
+	l := &rateLimiter{rate: cfg.Rate, burst: float64(cfg.Burst), buckets: map[string]*tokenBucket{}, now: time.Now}
+	if l.rate <= 0 {
+		l.rate = defaultRateLimit
+	}
+	if l.burst < 1 {
+		l.burst = defaultRateBurst
+	}
+	return l
+}
+
+// take spends a token from key's bucket. When none is left it returns false
+// and how long until one is.
+func (l *rateLimiter) take(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxRateBuckets {
+			l.pruneLocked(now)
+		}
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// pruneLocked drops the buckets that have refilled. Caller holds l.mu.
+func (l *rateLimiter) pruneLocked(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// admit checks r against the limit, answering 429 on w when it is over.
+func (l *rateLimiter) admit(w http.ResponseWriter, r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		return true
+	}
+	ok, wait := l.take(rateClientKey(r))
+	if ok {
+		return true
+	}
+	seconds := max(1, int(math.Ceil(wait.Seconds())))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":       fmt.Sprintf("too many requests: over %g per second (rate_limit)", l.rate),
+		"code":        "rate_limited",
+		"retry_after": seconds,
+	})
+	return false
+}
+
+// rateClientKey identifies the client that sent r.
+func rateClientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host + " " + r.UserAgent()
+}
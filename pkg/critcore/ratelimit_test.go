@@ -0,0 +1,106 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_TokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(rateLimitConfig{Rate: 2, Burst: 3})
+	l.now = func() time.Time { return now }
+
+	for i := range 3 {
+		if ok, _ := l.take("agent"); !ok {
+			t.Fatalf("request %d within the burst was refused", i+1)
+		}
+	}
+	ok, wait := l.take("agent")
+	if ok || wait != 500*time.Millisecond {
+		t.Errorf("over the burst: ok = %v, wait = %v", ok, wait)
+	}
+	if ok, _ := l.take("browser"); !ok {
+		t.Error("another client shares the agent's bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := l.take("agent"); !ok {
+		t.Error("a token should have refilled")
+	}
+	if ok, _ := l.take("agent"); ok {
+		t.Error("only one token should have refilled")
+	}
+}
+
+func TestRateLimiter_Config(t *testing.T) {
+	if newRateLimiter(rateLimitConfig{Disabled: true}) != nil {
+		t.Error("disabled rate limit should give no limiter")
+	}
+	if l := newRateLimiter(rateLimitConfig{}); l.rate != defaultRateLimit || l.burst != defaultRateBurst {
+		t.Errorf("defaults = %g/s, burst %g", l.rate, l.burst)
+	}
+}
+
+func TestRateLimiter_PrunesFullBuckets(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(rateLimitConfig{Rate: 1, Burst: 1})
+	l.now = func() time.Time { return now }
+	for i := range maxRateBuckets {
+		l.take(strings.Repeat("x", i+1))
+	}
+	now = now.Add(time.Second)
+	l.take("new client")
+	if len(l.buckets) != 1 {
+		t.Errorf("buckets = %d, want only the new client's", len(l.buckets))
+	}
+}
+
+func TestServer_RateLimitsMutatingRequests(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.limiter = newRateLimiter(rateLimitConfig{Rate: 1, Burst: 2})
+	s.cfg.DuplicateComments = duplicateOff // every request posts the same comment
+	post := func(agent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/comments", strings.NewReader(`{"body":"again"}`))
+		req.Header.Set("User-Agent", agent)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+	for range 2 {
+		if w := post("curl/8.0"); w.Code != 201 {
+			t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+		}
+	}
+	w := post("curl/8.0")
+	var resp struct {
+		Code       string `json:"code"`
+		RetryAfter int    `json:"retry_after"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != 429 || w.Header().Get("Retry-After") != "1" || resp.Code != "rate_limited" || resp.RetryAfter != 1 {
+		t.Errorf("over the limit: status = %d, Retry-After = %q, body = %s", w.Code, w.Header().Get("Retry-After"), w.Body.String())
+	}
+
+	// Reads and other clients are not limited.
+	get := httptest.NewRecorder()
+	s.ServeHTTP(get, httptest.NewRequest("GET", "/api/comments", nil))
+	if get.Code != 200 {
+		t.Errorf("GET status = %d", get.Code)
+	}
+	if w := post("Mozilla/5.0"); w.Code != 201 {
+		t.Errorf("browser status = %d", w.Code)
+	}
+}
+
+func TestMergeConfigs_RateLimit(t *testing.T) {
+	global := Config{RateLimit: rateLimitConfig{Rate: 5}}
+	if merged := mergeConfigs(global, Config{}, configPresence{}); merged.RateLimit.Rate != 5 {
+		t.Errorf("rate_limit = %+v, want the global one", merged.RateLimit)
+	}
+	if merged := mergeConfigs(global, Config{RateLimit: rateLimitConfig{Disabled: true}}, configPresence{}); !merged.RateLimit.Disabled {
+		t.Errorf("rate_limit = %+v, want the project one", merged.RateLimit)
+	}
+}
@@ -0,0 +1,204 @@
+package critcore
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// `crit https://example.com/spec.md` reviews a document fetched over HTTP.
+// The daemon downloads it to remote/<hash>/<name> under the --output
+// directory (the storage root without one) and reviews that copy, filed under
+// its URL: comments in the review file, the file list and `crit comment` all
+// use the URL as the path. The copy's ETag and Last-Modified are kept next to
+// it, and the document is revalidated with a conditional request when the
+// daemon starts and at every round-complete, so an updated gist or RFC
+// arrives as an edit between rounds; a failed revalidation keeps the copy.
+
+// maxRemoteDocument caps how much of a remote document is read.
+const maxRemoteDocument = 10 << 20 // 10MB
+
+var remoteClient = &http.Client{Timeout: 30 * time.Second}
+
+// remoteDocument is a file argument given as a URL and its local copy.
+type remoteDocument struct {
+	URL  string
+	Path string
+}
+
+// remoteMeta is what a local copy keeps for revalidation, in .remote.json
+// beside it.
+type remoteMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// isRemoteArg reports whether a file argument is an http(s) URL.
+func isRemoteArg(arg string) bool {
+	return strings.HasPrefix(arg, "https://") || strings.HasPrefix(arg, "http://")
+}
+
+// remoteCopyPath returns where the copy of rawURL lives under dir: a folder
+// per URL, keeping the URL's file name so the file type is detected from it.
+func remoteCopyPath(rawURL, dir string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid URL %q", rawURL)
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		name = "index"
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(dir, "remote", fmt.Sprintf("%x", sum[:6]), name), nil
+}
+
+// resolveRemoteArgs replaces each URL in files with the path of its local
+// copy under outputDir (the storage root when empty), fetching or
+// revalidating it. A copy that can't be revalidated is used as it is.
+func resolveRemoteArgs(files []string, outputDir string) ([]string, []remoteDocument, error) {
+	var docs []remoteDocument
+	local := make([]string, len(files))
+	for i, arg := range files {
+		local[i] = arg
+		if !isRemoteArg(arg) {
+			continue
+		}
+		dir, err := localCopyRoot(outputDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		copyPath, err := remoteCopyPath(arg, dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := fetchRemoteDocument(arg, copyPath); err != nil {
+			if _, statErr := os.Stat(copyPath); statErr != nil {
+				return nil, nil, err
+			}
+			slog.Warn("revalidating remote document, using the local copy", "url", arg, "err", err)
+		}
+		local[i] = copyPath
+		docs = append(docs, remoteDocument{URL: arg, Path: copyPath})
+	}
+	return local, docs, nil
+}
+
+// localCopyRoot returns the directory local copies of remote documents and
+// revision snapshots are kept under: outputDir, or the storage root.
+func localCopyRoot(outputDir string) (string, error) {
+	if outputDir != "" {
+		return outputDir, nil
+	}
+	return storageRoot()
+}
+
+// fetchRemoteDocument downloads rawURL to copyPath, sending the validators
+// saved with an existing copy, and reports whether the copy changed.
+func fetchRemoteDocument(rawURL, copyPath string) (bool, error) {
+	metaPath := filepath.Join(filepath.Dir(copyPath), ".remote.json")
+	var meta remoteMeta
+	if _, err := os.Stat(copyPath); err == nil {
+		if data, err := os.ReadFile(metaPath); err == nil {
+			json.Unmarshal(data, &meta)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", "crit/"+version)
+	if meta.ETag != "" {
+		req.Header.Set("If-None-Match", meta.ETag)
+	}
+	if meta.LastModified != "" {
+		req.Header.Set("If-Modified-Since", meta.LastModified)
+	}
+	resp, err := remoteClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("fetching %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("fetching %s: %s", rawURL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteDocument+1))
+	if err != nil {
+		return false, fmt.Errorf("fetching %s: %w", rawURL, err)
+	}
+	if len(data) > maxRemoteDocument {
+		return false, fmt.Errorf("fetching %s: larger than %d MB", rawURL, maxRemoteDocument>>20)
+	}
+	meta = remoteMeta{URL: rawURL, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	return saveRemoteCopy(copyPath, metaPath, data, meta)
+}
+
+// saveRemoteCopy writes data to copyPath, unless it is already there, and
+// meta to metaPath, reporting whether the copy changed.
+func saveRemoteCopy(copyPath, metaPath string, data []byte, meta remoteMeta) (bool, error) {
+	if err := os.MkdirAll(filepath.Dir(copyPath), 0755); err != nil {
+		return false, err
+	}
+	old, _ := os.ReadFile(copyPath)
+	changed := old == nil || fileHash(old) != fileHash(data)
+	if changed {
+		if err := atomicWriteFile(copyPath, data, 0644); err != nil {
+			return false, err
+		}
+	}
+	out, _ := json.MarshalIndent(meta, "", "  ")
+	if err := atomicWriteFile(metaPath, out, 0644); err != nil {
+		return changed, err
+	}
+	return changed, nil
+}
+
+// adoptRemoteDocuments files the entries of remote copies under their URLs.
+// They have no diff: the copy isn't in the repository.
+func (s *Session) adoptRemoteDocuments(docs []remoteDocument) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remoteDocs = docs
+	for _, d := range docs {
+		for _, f := range s.Files {
+			if f.AbsPath == d.Path {
+				f.Path = d.URL
+				f.DiffHunks = nil
+			}
+		}
+	}
+}
+
+// refreshRemoteDocuments revalidates every remote document and reports
+// whether any local copy changed.
+func (s *Session) refreshRemoteDocuments() bool {
+	s.mu.RLock()
+	docs := s.remoteDocs
+	s.mu.RUnlock()
+	var errs []error
+	changed := false
+	for _, d := range docs {
+		c, err := fetchRemoteDocument(d.URL, d.Path)
+		errs = append(errs, err)
+		changed = changed || c
+	}
+	if err := errors.Join(errs...); err != nil {
+		slog.Warn("revalidating remote documents", "err", err)
+	}
+	return changed
+}
@@ -0,0 +1,120 @@
+package critcore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// remoteServer serves body with an ETag derived from it, counting the
+// requests answered 304.
+func remoteServer(t *testing.T, body *string, notModified *int) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + fileHash([]byte(*body))[7:19] + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			*notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(*body))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestFetchRemoteDocument(t *testing.T) {
+	body, notModified := "# Spec\n", 0
+	ts := remoteServer(t, &body, &notModified)
+	copyPath, err := remoteCopyPath(ts.URL+"/docs/spec.md", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(copyPath) != "spec.md" {
+		t.Errorf("copy path = %s", copyPath)
+	}
+
+	if changed, err := fetchRemoteDocument(ts.URL+"/docs/spec.md", copyPath); err != nil || !changed {
+		t.Fatalf("first fetch: %v, %v", changed, err)
+	}
+	if data, _ := os.ReadFile(copyPath); string(data) != body {
+		t.Errorf("copy = %q", data)
+	}
+	if changed, err := fetchRemoteDocument(ts.URL+"/docs/spec.md", copyPath); err != nil || changed || notModified != 1 {
+		t.Errorf("revalidation: changed %v, err %v, 304s %d", changed, err, notModified)
+	}
+	body = "# Spec\n\nUpdated.\n"
+	if changed, err := fetchRemoteDocument(ts.URL+"/docs/spec.md", copyPath); err != nil || !changed {
+		t.Errorf("after an update: %v, %v", changed, err)
+	}
+	if data, _ := os.ReadFile(copyPath); string(data) != body {
+		t.Errorf("updated copy = %q", data)
+	}
+}
+
+func TestFetchRemoteDocument_NotFound(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+	_, err := fetchRemoteDocument(ts.URL+"/gone.md", filepath.Join(t.TempDir(), "gone.md"))
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestResolveRemoteArgs(t *testing.T) {
+	body, notModified := "# Spec\n", 0
+	ts := remoteServer(t, &body, &notModified)
+	out := t.TempDir()
+	url := ts.URL + "/spec.md"
+
+	files, docs, err := resolveRemoteArgs([]string{"plan.md", url}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files[0] != "plan.md" || !strings.HasPrefix(files[1], filepath.Join(out, "remote")) {
+		t.Errorf("files = %q", files)
+	}
+	if len(docs) != 1 || docs[0].URL != url || docs[0].Path != files[1] {
+		t.Errorf("docs = %+v", docs)
+	}
+
+	// Offline, an existing copy is reviewed as it is.
+	ts.Close()
+	if again, _, err := resolveRemoteArgs([]string{url}, out); err != nil || again[0] != files[1] {
+		t.Errorf("offline with a copy: %q, %v", again, err)
+	}
+	if _, _, err := resolveRemoteArgs([]string{ts.URL + "/other.md"}, out); err == nil {
+		t.Error("offline without a copy: no error")
+	}
+}
+
+func TestAdoptRemoteDocuments(t *testing.T) {
+	body, notModified := "# Spec\n\nline\n", 0
+	ts := remoteServer(t, &body, &notModified)
+	url := ts.URL + "/rfc/spec.md"
+	files, docs, err := resolveRemoteArgs([]string{url}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := NewSessionFromFiles(files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.adoptRemoteDocuments(docs)
+	f := session.Files[0]
+	if f.Path != url || f.FileType != "markdown" || f.Content != body {
+		t.Errorf("entry = %s (%s), %q", f.Path, f.FileType, f.Content)
+	}
+
+	if session.refreshRemoteDocuments() {
+		t.Error("unchanged document reported as changed")
+	}
+	body = "# Spec\n\nnew line\n"
+	if !session.refreshRemoteDocuments() {
+		t.Error("updated document not reported")
+	}
+}
@@ -0,0 +1,280 @@
+package critcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// renameWindow is how long a created path stays a rename candidate. A copy
+// followed by a delete reports the new file before the old one goes away.
+const renameWindow = 5 * time.Second
+
+// detectRenames looks for session files that vanished from disk and reappeared
+// under a new name (e.g. the agent ran `mv plan.md plan-v2.md`). A rename is
+// recognized when a file the watcher saw appear in the same directory has
+// identical content, or — when exactly one such file appeared next to
+// exactly one missing file — when the new file has the same extension.
+// created holds the paths reported by fsnotify Create and Rename events, with
+// when they were seen; entries older than renameWindow are dropped. Files
+// that were already there are never candidates, so an identical sibling
+// can't be mistaken for the renamed file. Only files mode follows renames.
+func (s *Session) detectRenames(created map[string]time.Time) bool {
+	maps.DeleteFunc(created, func(_ string, seen time.Time) bool { return time.Since(seen) > renameWindow })
+	if s.Mode != "files" || len(created) == 0 {
+		return false
+	}
+
+	s.mu.RLock()
+	known := make(map[string]bool, len(s.Files))
+	type missingFile struct {
+		absPath string
+		hash    string
+	}
+	var missing []missingFile
+	for _, f := range s.Files {
+		known[f.AbsPath] = true
+		if f.Status == "deleted" || f.AbsPath == "" {
+			continue
+		}
+		if _, err := os.Stat(f.AbsPath); os.IsNotExist(err) {
+			missing = append(missing, missingFile{f.AbsPath, f.FileHash})
+		}
+	}
+	s.mu.RUnlock()
+
+	if len(missing) == 0 {
+		return false
+	}
+
+	renamed := false
+	for _, m := range missing {
+		newPath := findRenamedFile(m.absPath, m.hash, created, known)
+		if newPath == "" && len(missing) == 1 {
+			newPath = singleCreatedCandidate(m.absPath, created, known)
+		}
+		if newPath == "" {
+			continue
+		}
+		known[newPath] = true
+		delete(created, newPath)
+		if s.followRename(m.absPath, newPath) {
+			renamed = true
+		}
+	}
+	return renamed
+}
+
+// findRenamedFile returns the created file in the directory of oldPath that
+// is not part of the session and whose content hash matches, or "".
+func findRenamedFile(oldPath, hash string, created map[string]time.Time, known map[string]bool) string {
+	dir := filepath.Dir(oldPath)
+	for _, p := range slices.Sorted(maps.Keys(created)) {
+		if !isRenameCandidate(p, dir, known) {
+			continue
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		if fileHash(data) == hash {
+			return p
+		}
+	}
+	return ""
+}
+
+// isRenameCandidate reports whether p, a created path, could be a session
+// file's new name in dir: not already in the session, not hidden, and not an
+// editor's backup, swap or temp file.
+func isRenameCandidate(p, dir string, known map[string]bool) bool {
+	name := filepath.Base(p)
+	if known[p] || filepath.Dir(p) != dir || strings.HasPrefix(name, ".") {
+		return false
+	}
+	// 4913 is the file vim creates to probe whether it may write the directory.
+	return name != "4913" && !strings.HasSuffix(name, "~") &&
+		!strings.HasSuffix(name, ".swp") && !strings.HasSuffix(name, ".tmp")
+}
+
+// singleCreatedCandidate returns the only newly created, still-existing file in
+// oldPath's directory that shares its extension, or "" if there are zero or
+// several. Used when the agent renamed and edited the file in one step.
+func singleCreatedCandidate(oldPath string, created map[string]time.Time, known map[string]bool) string {
+	dir := filepath.Dir(oldPath)
+	ext := filepath.Ext(oldPath)
+	found := ""
+	for p := range created {
+		if !isRenameCandidate(p, dir, known) || filepath.Ext(p) != ext {
+			continue
+		}
+		if info, err := os.Stat(p); err != nil || info.IsDir() {
+			continue
+		}
+		if found != "" {
+			return ""
+		}
+		found = p
+	}
+	return found
+}
+
+// followRename points the session at a file's new location: the file entry,
+// its comments in the review file, the CLI arguments used to re-invoke crit,
+// and — via onRename — the review file and daemon session file, which are
+// keyed by those arguments. Returns false if oldAbs is not a session file.
+func (s *Session) followRename(oldAbs, newAbs string) bool {
+	data, err := os.ReadFile(newAbs)
+	if err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	var fe *FileEntry
+	for _, f := range s.Files {
+		if f.AbsPath == oldAbs {
+			fe = f
+			break
+		}
+	}
+	if fe == nil {
+		s.mu.Unlock()
+		return false
+	}
+	oldRel, newRel := s.retargetFileEntry(fe, newAbs, data)
+	s.mu.Unlock()
+
+	s.rewriteCLIArgs(func(args []string) []string { return renameArgs(args, oldAbs, newAbs) }, oldRel, newRel)
+
+	slog.Info("following rename", "from", oldRel, "to", newRel)
+	s.notify(SSEEvent{Type: "file-changed", Content: "session"})
+	return true
+}
+
+// rewriteCLIArgs replaces the CLI arguments with rewrite's result and, via
+// onRename, re-keys the daemon, moving the review file to the new key with
+// the comments of oldRel filed under newRel (pass "" for both to move it
+// unchanged).
+func (s *Session) rewriteCLIArgs(rewrite func([]string) []string, oldRel, newRel string) {
+	// Serialize with debounced writes so none lands on the old review path
+	// after the file has moved.
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.pathMu.Lock()
+	oldCritPath := s.critJSONPathLocked()
+	s.CLIArgs = rewrite(s.CLIArgs)
+	newCritPath := oldCritPath
+	if s.onRename != nil && s.ReviewFilePath != "" {
+		if p := s.onRename(s.CLIArgs); p != "" {
+			s.ReviewFilePath = p
+			newCritPath = p
+		}
+	}
+	s.pathMu.Unlock()
+	if newCritPath == oldCritPath && newRel == oldRel {
+		return
+	}
+	if err := moveReviewFileEntry(oldCritPath, newCritPath, oldRel, newRel); err != nil {
+		slog.Warn("moving review file", "err", err)
+	}
+	if info, err := os.Stat(newCritPath); err == nil {
+		s.mu.Lock()
+		s.lastCritJSONMtime = info.ModTime()
+		s.mu.Unlock()
+	}
+}
+
+// retargetFileEntry moves fe to newAbs with content data, returning its old
+// and new session paths. Caller holds s.mu.
+func (s *Session) retargetFileEntry(fe *FileEntry, newAbs string, data []byte) (oldRel, newRel string) {
+	oldRel = fe.Path
+	newRel = newAbs
+	if s.RepoRoot != "" {
+		if rel, err := filepath.Rel(s.RepoRoot, newAbs); err == nil && filepath.IsLocal(rel) {
+			newRel = rel
+		}
+	}
+	fe.Path = newRel
+	fe.AbsPath = newAbs
+	fe.FileType = detectFileType(newAbs)
+	fe.notebook = s.Mode == "files" && isNotebookPath(newAbs)
+	switch {
+	case fe.notebook:
+		fe.FileType = "markdown"
+	case s.Mode == "files" && isImagePath(newAbs):
+		fe.FileType = "image"
+	}
+	fe.setContent(data)
+	fe.FileHash = fileHash(data)
+	if ids, ok := s.deletedCommentIDs[oldRel]; ok {
+		delete(s.deletedCommentIDs, oldRel)
+		s.deletedCommentIDs[newRel] = ids
+	}
+	return oldRel, newRel
+}
+
+// renameArgs returns a copy of args with the argument that resolves to oldAbs
+// replaced by one for newAbs, keeping the original's relative/absolute form.
+// Directory arguments are left alone since they still contain the file.
+func renameArgs(args []string, oldAbs, newAbs string) []string {
+	out := make([]string, len(args))
+	copy(out, args)
+	for i, a := range out {
+		abs, err := filepath.Abs(a)
+		if err != nil || abs != oldAbs {
+			continue
+		}
+		switch {
+		case filepath.Dir(oldAbs) == filepath.Dir(newAbs):
+			out[i] = filepath.Join(filepath.Dir(a), filepath.Base(newAbs))
+		case filepath.IsAbs(a):
+			out[i] = newAbs
+		default:
+			out[i] = newAbs
+			if cwd, err := os.Getwd(); err == nil {
+				if rel, err := filepath.Rel(cwd, newAbs); err == nil {
+					out[i] = rel
+				}
+			}
+		}
+	}
+	return out
+}
+
+// moveReviewFileEntry renames the per-file section oldRel → newRel in the
+// review file and, if newPath differs from oldPath, moves the review file.
+// A missing review file is not an error: there is nothing to carry over.
+func moveReviewFileEntry(oldPath, newPath, oldRel, newRel string) error {
+	data, err := os.ReadFile(oldPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cj CritJSON
+	if err := unmarshalCritJSON(data, &cj); err != nil {
+		return fmt.Errorf("parsing %s: %w", oldPath, err)
+	}
+	if cf, ok := cj.Files[oldRel]; ok {
+		delete(cj.Files, oldRel)
+		cj.Files[newRel] = cf
+	}
+	out, err := json.MarshalIndent(cj, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicWriteFile(newPath, out, 0644); err != nil {
+		return err
+	}
+	if newPath != oldPath {
+		os.Remove(oldPath)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newRenameTestSession(t *testing.T, dir, name, content string) *Session {
+	t.Helper()
+	absPath := filepath.Join(dir, name)
+	writeFile(t, absPath, content)
+	return &Session{
+		Mode:           "files",
+		RepoRoot:       dir,
+		ReviewRound:    1,
+		ReviewFilePath: filepath.Join(t.TempDir(), "review.json"),
+		CLIArgs:        []string{absPath},
+		Files: []*FileEntry{
+			{
+				Path:     name,
+				AbsPath:  absPath,
+				Status:   "modified",
+				FileType: detectFileType(name),
+				Content:  content,
+				FileHash: fileHash([]byte(content)),
+				Comments: []Comment{},
+			},
+		},
+		subscribers:   make(map[chan SSEEvent]struct{}),
+		roundComplete: make(chan struct{}, 1),
+	}
+}
+
+func TestDetectRenames_SameContent(t *testing.T) {
+	dir := t.TempDir()
+	s := newRenameTestSession(t, dir, "plan.md", "# Plan\n\nStep 1\n")
+	if _, ok := s.AddComment("plan.md", 1, 1, "", "keep me", "", "tester"); !ok {
+		t.Fatal("AddComment failed")
+	}
+	flushWrites(s)
+	s.WriteFiles()
+
+	newPath := filepath.Join(dir, "plan-v2.md")
+	if err := os.Rename(filepath.Join(dir, "plan.md"), newPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.detectRenames(map[string]time.Time{newPath: time.Now()}) {
+		t.Fatal("expected rename to be detected")
+	}
+	f := s.Files[0]
+	if f.Path != "plan-v2.md" || f.AbsPath != newPath {
+		t.Errorf("file entry = %q (%q), want plan-v2.md", f.Path, f.AbsPath)
+	}
+	if len(f.Comments) != 1 {
+		t.Errorf("expected comment to follow the file, got %d", len(f.Comments))
+	}
+	if s.CLIArgs[0] != newPath {
+		t.Errorf("CLIArgs = %v, want [%s]", s.CLIArgs, newPath)
+	}
+
+	cj, err := loadCritJSON(s.critJSONPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cj.Files["plan.md"]; ok {
+		t.Error("review file still has entry for old path")
+	}
+	if len(cj.Files["plan-v2.md"].Comments) != 1 {
+		t.Errorf("review file entry for new path = %+v", cj.Files["plan-v2.md"])
+	}
+}
+
+func TestRetargetFileEntry_DotDotName(t *testing.T) {
+	dir := t.TempDir()
+	s := newRenameTestSession(t, dir, "plan.md", "# Plan\n")
+	newAbs := filepath.Join(dir, "..plan.md")
+	if _, newRel := s.retargetFileEntry(s.Files[0], newAbs, []byte("# Plan\n")); newRel != "..plan.md" {
+		t.Errorf("newRel = %q, want ..plan.md", newRel)
+	}
+}
+
+func TestDetectRenames_RenamedAndEdited(t *testing.T) {
+	dir := t.TempDir()
+	s := newRenameTestSession(t, dir, "plan.md", "# Plan\n")
+	os.Remove(filepath.Join(dir, "plan.md"))
+	newPath := filepath.Join(dir, "plan-v2.md")
+	writeFile(t, newPath, "# Plan v2\n")
+
+	// Without a create event there is no content match to go on.
+	if s.detectRenames(map[string]time.Time{}) {
+		t.Fatal("rename with edited content should need a create event")
+	}
+	if !s.detectRenames(map[string]time.Time{newPath: time.Now()}) {
+		t.Fatal("expected rename to be detected from create event")
+	}
+	if s.Files[0].Content != "# Plan v2\n" {
+		t.Errorf("content = %q, want new file content", s.Files[0].Content)
+	}
+}
+
+func TestDetectRenames_AmbiguousCandidatesIgnored(t *testing.T) {
+	dir := t.TempDir()
+	s := newRenameTestSession(t, dir, "plan.md", "# Plan\n")
+	os.Remove(filepath.Join(dir, "plan.md"))
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "b.md")
+	writeFile(t, a, "a\n")
+	writeFile(t, b, "b\n")
+
+	if s.detectRenames(map[string]time.Time{a: time.Now(), b: time.Now()}) {
+		t.Error("expected no rename with two candidates")
+	}
+	if s.Files[0].Path != "plan.md" {
+		t.Errorf("path changed to %q", s.Files[0].Path)
+	}
+}
+
+func TestDetectRenames_GitModeIgnored(t *testing.T) {
+	dir := t.TempDir()
+	s := newRenameTestSession(t, dir, "plan.md", "# Plan\n")
+	s.Mode = "git"
+	other := filepath.Join(dir, "other.md")
+	os.Rename(filepath.Join(dir, "plan.md"), other)
+	if s.detectRenames(map[string]time.Time{other: time.Now()}) {
+		t.Error("git mode should not follow renames")
+	}
+}
+
+func TestDetectRenames_IgnoresIdenticalSibling(t *testing.T) {
+	dir := t.TempDir()
+	content := "# Plan\n"
+	s := newRenameTestSession(t, dir, "plan.md", content)
+	writeFile(t, filepath.Join(dir, "copy.md"), content)
+	newPath := filepath.Join(dir, "plan-v2.md")
+	os.Rename(filepath.Join(dir, "plan.md"), newPath)
+
+	if s.detectRenames(map[string]time.Time{}) {
+		t.Fatal("a file that was already there isn't a rename")
+	}
+	if !s.detectRenames(map[string]time.Time{newPath: time.Now()}) {
+		t.Fatal("expected rename to be detected")
+	}
+	if s.Files[0].AbsPath != newPath {
+		t.Errorf("followed to %q, want %q", s.Files[0].AbsPath, newPath)
+	}
+}
+
+func TestDetectRenames_SkipsEditorFiles(t *testing.T) {
+	dir := t.TempDir()
+	content := "# Plan\n"
+	s := newRenameTestSession(t, dir, "plan.md", content)
+	os.Remove(filepath.Join(dir, "plan.md"))
+	created := map[string]time.Time{}
+	for _, name := range []string{"plan.md~", "plan.md.swp", "plan.md.tmp", "4913"} {
+		writeFile(t, filepath.Join(dir, name), content)
+		created[filepath.Join(dir, name)] = time.Now()
+	}
+	if s.detectRenames(created) {
+		t.Errorf("followed an editor backup or temp file to %q", s.Files[0].Path)
+	}
+}
+
+func TestDetectRenames_ForgetsOldCreates(t *testing.T) {
+	dir := t.TempDir()
+	s := newRenameTestSession(t, dir, "plan.md", "# Plan\n")
+	newPath := filepath.Join(dir, "plan-v2.md")
+	os.Rename(filepath.Join(dir, "plan.md"), newPath)
+	created := map[string]time.Time{newPath: time.Now().Add(-2 * renameWindow)}
+	if s.detectRenames(created) || len(created) != 0 {
+		t.Errorf("a create older than renameWindow should be dropped, created = %v", created)
+	}
+}
+
+func TestFollowRename_MovesReviewFile(t *testing.T) {
+	dir := t.TempDir()
+	s := newRenameTestSession(t, dir, "plan.md", "# Plan\n")
+	s.AddComment("plan.md", 1, 1, "", "note", "", "tester")
+	flushWrites(s)
+	s.WriteFiles()
+
+	oldReview := s.critJSONPath()
+	newReview := filepath.Join(t.TempDir(), "moved.json")
+	var gotArgs []string
+	s.onRename = func(args []string) string {
+		gotArgs = args
+		return newReview
+	}
+
+	newPath := filepath.Join(dir, "plan-v2.md")
+	os.Rename(filepath.Join(dir, "plan.md"), newPath)
+	if !s.followRename(filepath.Join(dir, "plan.md"), newPath) {
+		t.Fatal("followRename returned false")
+	}
+	if len(gotArgs) != 1 || gotArgs[0] != newPath {
+		t.Errorf("onRename args = %v", gotArgs)
+	}
+	if s.critJSONPath() != newReview {
+		t.Errorf("critJSONPath = %q, want %q", s.critJSONPath(), newReview)
+	}
+	if _, err := os.Stat(oldReview); !os.IsNotExist(err) {
+		t.Error("old review file should be removed")
+	}
+	cj, err := loadCritJSON(newReview)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cj.Files["plan-v2.md"].Comments) != 1 {
+		t.Errorf("moved review file = %+v", cj.Files)
+	}
+}
+
+func TestRenameArgs(t *testing.T) {
+	dir := t.TempDir()
+	oldAbs := filepath.Join(dir, "plan.md")
+	newAbs := filepath.Join(dir, "plan-v2.md")
+
+	got := renameArgs([]string{oldAbs, "other.md"}, oldAbs, newAbs)
+	if got[0] != newAbs || got[1] != "other.md" {
+		t.Errorf("renameArgs = %v", got)
+	}
+
+	// Directory arguments are unaffected.
+	got = renameArgs([]string{dir}, oldAbs, newAbs)
+	if got[0] != dir {
+		t.Errorf("renameArgs changed directory arg: %v", got)
+	}
+}
+
+func TestSessionRekeyer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cwd := "/work"
+	oldArgs := []string{"plan.md"}
+	newArgs := []string{"plan-v2.md"}
+	oldKey := sessionKey(cwd, "", oldArgs)
+	entry := sessionEntry{PID: 1, Port: 1234, CWD: cwd, Args: oldArgs}
+	if err := writeSessionFile(oldKey, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &sessionRekeyer{key: oldKey, entry: entry}
+	reviewPath := r.rekey(newArgs)
+
+	newKey := sessionKey(cwd, "", newArgs)
+	if r.currentKey() != newKey {
+		t.Errorf("currentKey = %q, want %q", r.currentKey(), newKey)
+	}
+	if want, _ := reviewFilePath(newKey); reviewPath != want {
+		t.Errorf("reviewPath = %q, want %q", reviewPath, want)
+	}
+	if _, err := readSessionFile(oldKey); err == nil {
+		t.Error("old session file should be removed")
+	}
+	got, err := readSessionFile(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Args) != 1 || got.Args[0] != "plan-v2.md" || got.ReviewPath != reviewPath {
+		t.Errorf("new session entry = %+v", got)
+	}
+
+	// With --output the review file path is left alone.
+	r.keepReviewPath = true
+	if p := r.rekey(oldArgs); p != "" {
+		t.Errorf("keepReviewPath rekey returned %q", p)
+	}
+}
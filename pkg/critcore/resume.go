@@ -0,0 +1,281 @@
+package critcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// The review file only holds comments, so a daemon that crashed or was
+// stopped used to come back with the round's context gone: the verdict the
+// agent was working through, what the files looked like when the reviewer
+// finished, edits made since, and the browser's unsent drafts. The daemon
+// keeps that state in <storage root>/resume/<session key>.json, saved
+// whenever it changes, and `crit resume` starts the review with the hidden
+// --resume flag so the new daemon restores it. Orphaned comments need
+// nothing extra: they live in the review file and come back as phantom
+// files on load.
+
+const (
+	maxDrafts    = 200
+	maxDraftSize = 64 << 10
+)
+
+// resumeState is what `crit resume` restores on top of the review file.
+type resumeState struct {
+	Round        int                        `json:"round"`
+	Verdict      string                     `json:"verdict,omitempty"` // verdictApproved, verdictChangesRequested or "" mid-review
+	PendingEdits int                        `json:"pending_edits,omitempty"`
+	Drafts       map[string]json.RawMessage `json:"drafts,omitempty"` // the browser's drafts by form key
+	Files        map[string]resumeFile      `json:"files,omitempty"`
+	SavedAt      string                     `json:"saved_at"`
+}
+
+// resumeFile is a file's content as the session last saw it, and the
+// round's snapshot of it from before the first edit, if there was one.
+type resumeFile struct {
+	Content         string `json:"content"`
+	PreviousContent string `json:"previous_content,omitempty"`
+}
+
+// resumeStatePath returns <storage root>/resume/<key>.json.
+func resumeStatePath(key string) (string, error) {
+	root, err := storageRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "resume", key+".json"), nil
+}
+
+// removeResumeState deletes the saved state of the session key, if any.
+func removeResumeState(key string) {
+	if path, err := resumeStatePath(key); err == nil {
+		os.Remove(path)
+	}
+}
+
+func loadResumeState(path string) (resumeState, error) {
+	var state resumeState
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// enableResume makes the session save its state for `crit resume` under
+// key and, when resume is set, restores the state saved there first.
+func (s *Session) enableResume(key string, resume bool) {
+	path, err := resumeStatePath(key)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.resumePath = path
+	s.mu.Unlock()
+	if !resume {
+		return
+	}
+	state, err := loadResumeState(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("resuming session", "err", err)
+		}
+		return
+	}
+	s.applyResumeState(state)
+}
+
+// applyResumeState restores state on a freshly loaded session. A file whose
+// content differs from the saved one was edited while the daemon was down;
+// it is treated as an edit the watcher saw, so the next round diffs against
+// and carries comments forward from the content the reviewer commented on.
+func (s *Session) applyResumeState(state resumeState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ReviewRound = max(s.ReviewRound, state.Round)
+	s.pendingEdits = state.PendingEdits
+	if len(state.Drafts) > 0 {
+		s.drafts = state.Drafts
+	}
+	for _, f := range s.Files {
+		saved, ok := state.Files[f.Path]
+		if !ok || f.Lazy || f.Orphaned {
+			continue
+		}
+		previous := saved.PreviousContent
+		if previous == "" && saved.Content != f.Content {
+			previous = saved.Content
+			s.pendingEdits = max(s.pendingEdits, 1)
+		}
+		if previous != "" {
+			f.PreviousContent = previous
+			f.PreviousComments = slices.Clone(f.Comments)
+		}
+	}
+	switch state.Verdict {
+	case verdictApproved:
+		s.finished = true
+		s.awaitingFirstReview = false
+	case verdictChangesRequested:
+		s.waitingForAgent = true
+		s.awaitingFirstReview = false
+	}
+}
+
+// resumeStateLocked collects the state to save. Files mode keeps every
+// file's content; git mode, like the round archive, only that of the files
+// with comments. Caller holds s.mu.
+func (s *Session) resumeStateLocked() resumeState {
+	state := resumeState{
+		Round:        max(s.ReviewRound, 1),
+		PendingEdits: s.pendingEdits,
+		Drafts:       maps.Clone(s.drafts),
+		Files:        make(map[string]resumeFile),
+		SavedAt:      time.Now().UTC().Format(time.RFC3339),
+	}
+	if s.finished {
+		state.Verdict = verdictApproved
+	} else if s.waitingForAgent {
+		state.Verdict = verdictChangesRequested
+	}
+	for _, f := range s.Files {
+		if f.Lazy || f.Orphaned || f.Status == "deleted" {
+			continue
+		}
+		if s.Mode == "git" && len(f.Comments) == 0 && f.PreviousContent == "" {
+			continue
+		}
+		state.Files[f.Path] = resumeFile{Content: f.Content, PreviousContent: f.PreviousContent}
+	}
+	return state
+}
+
+// saveResumeState writes the session's state for `crit resume`. No-op
+// until enableResume has set where. Must be called without s.mu held.
+func (s *Session) saveResumeState() {
+	s.resumeMu.Lock()
+	defer s.resumeMu.Unlock()
+	s.mu.RLock()
+	path := s.resumePath
+	var state resumeState
+	if path != "" {
+		state = s.resumeStateLocked()
+	}
+	s.mu.RUnlock()
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = atomicWriteFile(path, data, 0600)
+	}
+	if err != nil {
+		slog.Warn("saving session state", "err", err)
+	}
+}
+
+// GetDrafts returns the browser's saved drafts by form key.
+func (s *Session) GetDrafts() map[string]json.RawMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	drafts := make(map[string]json.RawMessage, len(s.drafts))
+	maps.Copy(drafts, s.drafts)
+	return drafts
+}
+
+// setDraft saves the draft of the comment form key, or deletes it when
+// draft is nil. It returns false when the session already has maxDrafts
+// other drafts.
+func (s *Session) setDraft(key string, draft json.RawMessage) bool {
+	s.mu.Lock()
+	if draft == nil {
+		delete(s.drafts, key)
+	} else {
+		if _, ok := s.drafts[key]; !ok && len(s.drafts) >= maxDrafts {
+			s.mu.Unlock()
+			return false
+		}
+		if s.drafts == nil {
+			s.drafts = make(map[string]json.RawMessage)
+		}
+		s.drafts[key] = draft
+	}
+	s.mu.Unlock()
+	s.saveResumeState()
+	return true
+}
+
+// handleDrafts keeps the browser's comment drafts, so they survive a
+// restart of the daemon (which gets a new port, and so a new localStorage).
+// GET /api/drafts
+// PUT /api/drafts/<form key> {draft}
+// DELETE /api/drafts/<form key>
+func (s *Server) handleDrafts(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/drafts"), "/")
+	sess := s.session.Load()
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		writeJSON(w, sess.GetDrafts())
+	case key == "":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDraftSize))
+		if err != nil || !json.Valid(data) {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if !sess.setDraft(key, data) {
+			http.Error(w, "Too many drafts", http.StatusRequestEntityTooLarge)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		sess.setDraft(key, nil)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// runResume implements `crit resume [flags] [files...]`: the review of the
+// same files (or of the branch), restored from the state its daemon saved.
+func runResume(args []string) {
+	if slices.Contains(args, "--help") || slices.Contains(args, "-h") {
+		printResumeUsage()
+		return
+	}
+	sc, err := resolveServerConfig(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if sc == nil {
+		return // --version
+	}
+	if path, err := resumeStatePath(serveSessionKey(sc)); err == nil {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintln(os.Stderr, "No saved session to resume; starting the review from the review file.")
+		}
+	}
+	runReview(append([]string{"--resume"}, args...))
+}
+
+func printResumeUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit resume [flags] [files...]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Restarts an interrupted review where its daemon left off: the round,")
+	fmt.Fprintln(os.Stderr, "the verdict, edits made since, unsent drafts and the comments. Takes")
+	fmt.Fprintln(os.Stderr, "the files and flags of the crit command that started the review.")
+}
@@ -0,0 +1,126 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResumeState_FinishThenEditWhileDown(t *testing.T) {
+	storage := t.TempDir()
+	withProjectStorage(t, storage)
+	s, session := newTestServer(t)
+	session.enableResume("key1", false)
+	session.AddComment("test.md", 2, 2, "", "rename this", "", "")
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/finish", nil))
+
+	path := filepath.Join(storage, "resume", "key1.json")
+	state, err := loadResumeState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Verdict != verdictChangesRequested || state.Round != 1 || state.Files["test.md"].Content != "line1\nline2\nline3\n" {
+		t.Fatalf("state = %+v", state)
+	}
+
+	// The agent edits the file while no daemon is running; the resumed
+	// session sees that as the round's edit, against the reviewed content.
+	_, resumed := newTestServer(t)
+	resumed.Files[0].Content = "line1\nrenamed\nline3\n"
+	resumed.Files[0].Comments = []Comment{{ID: "c1", StartLine: 2, EndLine: 2, Body: "rename this"}}
+	resumed.awaitingFirstReview = true
+	resumed.applyResumeState(state)
+
+	f := resumed.Files[0]
+	if f.PreviousContent != "line1\nline2\nline3\n" || len(f.PreviousComments) != 1 {
+		t.Errorf("previous = %q, %d comments", f.PreviousContent, len(f.PreviousComments))
+	}
+	if resumed.pendingEdits != 1 || !resumed.waitingForAgent || resumed.awaitingFirstReview {
+		t.Errorf("pendingEdits=%d waiting=%v awaitingFirst=%v", resumed.pendingEdits, resumed.waitingForAgent, resumed.awaitingFirstReview)
+	}
+}
+
+func TestResumeState_MidReview(t *testing.T) {
+	_, session := newTestServer(t)
+	session.awaitingFirstReview = true
+	session.ReviewRound = 1
+	session.applyResumeState(resumeState{
+		Round:  3,
+		Files:  map[string]resumeFile{"test.md": {Content: "line1\nline2\nline3\n"}},
+		Drafts: map[string]json.RawMessage{"test.md:1:1:": json.RawMessage(`{"body":"half"}`)},
+	})
+	if session.ReviewRound != 3 || !session.awaitingFirstReview || session.pendingEdits != 0 {
+		t.Errorf("round=%d awaitingFirst=%v pendingEdits=%d", session.ReviewRound, session.awaitingFirstReview, session.pendingEdits)
+	}
+	if session.Files[0].PreviousContent != "" {
+		t.Errorf("an unchanged file should not get a snapshot, got %q", session.Files[0].PreviousContent)
+	}
+	if string(session.GetDrafts()["test.md:1:1:"]) != `{"body":"half"}` {
+		t.Errorf("drafts = %v", session.GetDrafts())
+	}
+}
+
+func TestResumeStateLocked_GitModeOnlyCommentedFiles(t *testing.T) {
+	session := &Session{Mode: "git", ReviewRound: 2, Files: []*FileEntry{
+		{Path: "a.go", Content: "a", Comments: []Comment{{ID: "c1"}}},
+		{Path: "b.go", Content: "b"},
+		{Path: "c.go", Content: "c", Lazy: true, Comments: []Comment{{ID: "c2"}}},
+	}}
+	state := session.resumeStateLocked()
+	if len(state.Files) != 1 || state.Files["a.go"].Content != "a" || state.Round != 2 {
+		t.Errorf("state = %+v", state)
+	}
+}
+
+func TestSaveResumeState_DisabledWithoutPath(t *testing.T) {
+	storage := t.TempDir()
+	withProjectStorage(t, storage)
+	_, session := newTestServer(t)
+	session.saveResumeState()
+	if _, err := os.Stat(filepath.Join(storage, "resume")); err == nil {
+		t.Error("expected no state without enableResume")
+	}
+}
+
+func TestHandleDrafts(t *testing.T) {
+	storage := t.TempDir()
+	withProjectStorage(t, storage)
+	s, session := newTestServer(t)
+	session.enableResume("key1", false)
+
+	put := httptest.NewRequest("PUT", "/api/drafts/test.md%3A1%3A2%3A", strings.NewReader(`{"body":"wip","startLine":1}`))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, put)
+	if w.Code != 204 {
+		t.Fatalf("PUT status = %d: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/drafts", nil))
+	var drafts map[string]map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &drafts); err != nil {
+		t.Fatal(err)
+	}
+	if drafts["test.md:1:2:"]["body"] != "wip" {
+		t.Errorf("drafts = %v", drafts)
+	}
+	state, _ := loadResumeState(filepath.Join(storage, "resume", "key1.json"))
+	if len(state.Drafts) != 1 {
+		t.Errorf("saved drafts = %v", state.Drafts)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("PUT", "/api/drafts/x", strings.NewReader("not json")))
+	if w.Code != 400 {
+		t.Errorf("invalid draft status = %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/drafts/test.md%3A1%3A2%3A", nil))
+	if w.Code != 204 || len(session.GetDrafts()) != 0 {
+		t.Errorf("DELETE status = %d, drafts = %v", w.Code, session.GetDrafts())
+	}
+}
@@ -0,0 +1,113 @@
+package critcore
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Values for --review-style / the "review_style" config key.
+const (
+	reviewStyleVerbose = "verbose" // point the agent at the review file (default)
+	reviewStyleCompact = "compact" // inline one line per open comment, nothing else
+)
+
+// validReviewStyle returns style if it is known, or an error naming the
+// accepted values. Empty means the default.
+func validReviewStyle(style string) (string, error) {
+	switch style {
+	case "":
+		return reviewStyleVerbose, nil
+	case reviewStyleVerbose, reviewStyleCompact:
+		return style, nil
+	default:
+		return "", fmt.Errorf("unknown review style %q (want %q or %q)", style, reviewStyleVerbose, reviewStyleCompact)
+	}
+}
+
+// compactReviewPrompt lists every open comment on one line, grouped by file,
+// without quoted source or review-file instructions, for agents with tight
+// context limits:
+//
+//	auth.go
+//	L12-L14 c_1a2b3c [blocker]: check the error
+func (s *Session) compactReviewPrompt(reviewFile string) string {
+	data := s.reviewTemplateData(reviewFile)
+
+	var b strings.Builder
+	reply := "crit comment --reply-to <id> --author <your-name> \"<explanation>\""
+	if data.Mode == "plan" {
+		reply = fmt.Sprintf("crit comment --plan %s --reply-to <id> --author <your-name> \"<explanation>\"", filepath.Base(s.PlanDir))
+	}
+	fmt.Fprintf(&b, "Review round %d: %d open comment%s. Address each, reply with `%s`", data.Round, data.Unresolved, plural(data.Unresolved), reply)
+	if data.Mode != "plan" {
+		fmt.Fprintf(&b, ", then run `%s`", data.Reinvoke)
+	}
+	b.WriteString(".\n")
+
+	for _, f := range data.Files {
+		for _, c := range f.Comments {
+			if c.Pinned && !c.Resolved {
+				fmt.Fprintf(&b, "pinned %s %s %s%s: %s\n", f.Path, compactLocation(c.Comment), c.ID, compactSeverity(c.Comment), oneLine(expandCommentRefs(c.Body, c.Refs)))
+			}
+		}
+	}
+	for _, c := range data.ReviewComments {
+		if !c.Resolved {
+			fmt.Fprintf(&b, "review %s%s: %s\n", c.ID, compactSeverity(c), oneLine(expandCommentRefs(c.Body, c.Refs)))
+		}
+	}
+	for _, f := range data.Files {
+		header := false
+		for _, c := range f.Comments {
+			if c.Resolved || c.Pinned {
+				continue
+			}
+			if !header {
+				b.WriteString(f.Path + "\n")
+				header = true
+			}
+			fmt.Fprintf(&b, "%s %s%s: %s\n", compactLocation(c.Comment), c.ID, compactSeverity(c.Comment), oneLine(expandCommentRefs(c.Body, c.Refs)))
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// compactLocation renders a comment's position as "L12", "L12-L14",
+// "L12:5-18" for a column span, "old L3" for removed lines,
+// "region 10,20 300x200" for image regions, or "file" for file-level comments.
+func compactLocation(c Comment) string {
+	if c.Region != nil {
+		return "region " + c.Region.String()
+	}
+	if c.Scope == "file" || c.StartLine < 1 {
+		return "file"
+	}
+	loc := fmt.Sprintf("L%d", c.StartLine)
+	switch {
+	case c.StartCol > 0 && c.EndLine > c.StartLine:
+		loc = fmt.Sprintf("L%d:%d-L%d:%d", c.StartLine, c.StartCol, c.EndLine, c.EndCol)
+	case c.StartCol > 0:
+		loc += fmt.Sprintf(":%d-%d", c.StartCol, c.EndCol)
+	case c.EndLine > c.StartLine:
+		loc += fmt.Sprintf("-L%d", c.EndLine)
+	}
+	if c.Side == "old" {
+		loc = "old " + loc
+	}
+	return loc
+}
+
+// compactSeverity renders a comment's severity as " [blocker]", or "" when
+// it has none.
+func compactSeverity(c Comment) string {
+	if c.Severity == "" {
+		return ""
+	}
+	return " [" + c.Severity + "]"
+}
+
+// oneLine collapses whitespace runs, including newlines, to single spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,106 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFinish_CompactReviewStyle(t *testing.T) {
+	s, session := newTestServer(t)
+	s.reviewStyle = reviewStyleCompact
+	c1, _ := session.AddComment("test.md", 2, 3, "", "tighten\nthis  up", "", "")
+	session.SetCommentSeverity("test.md", c1.ID, "blocker")
+	c2, _ := session.AddComment("test.md", 1, 1, "", "done already", "", "")
+	session.SetCommentResolved("test.md", c2.ID, true)
+	r := session.AddReviewComment("overall", "")
+
+	req := httptest.NewRequest("POST", "/api/finish", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	prompt, _ := resp["prompt"].(string)
+	lines := strings.Split(prompt, "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header + 3 lines, got:\n%s", prompt)
+	}
+	if !strings.HasPrefix(lines[0], "Review round 1: 2 open comments.") {
+		t.Errorf("header = %q", lines[0])
+	}
+	if want := "review " + r.ID + ": overall"; lines[1] != want {
+		t.Errorf("review line = %q, want %q", lines[1], want)
+	}
+	if lines[2] != "test.md" {
+		t.Errorf("file line = %q", lines[2])
+	}
+	if want := "L2-L3 " + c1.ID + " [blocker]: tighten this up"; lines[3] != want {
+		t.Errorf("comment line = %q, want %q", lines[3], want)
+	}
+	if strings.Contains(prompt, session.critJSONPath()) {
+		t.Error("compact prompt should not point at the review file")
+	}
+}
+
+func TestFinish_CompactReviewStylePinnedFirst(t *testing.T) {
+	s, session := newTestServer(t)
+	s.reviewStyle = reviewStyleCompact
+	session.AddComment("test.md", 1, 1, "", "early line", "", "")
+	c, _ := session.AddComment("test.md", 3, 3, "", "read this first", "", "")
+	if _, ok := session.SetCommentPinned("test.md", c.ID, true); !ok {
+		t.Fatal("SetCommentPinned: comment not found")
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/finish", nil))
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(resp["prompt"].(string), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected header + 3 lines, got:\n%s", resp["prompt"])
+	}
+	if want := "pinned test.md L3 " + c.ID + ": read this first"; lines[1] != want {
+		t.Errorf("pinned line = %q, want %q", lines[1], want)
+	}
+	if strings.Contains(lines[3], c.ID) {
+		t.Errorf("pinned comment repeated in file group: %q", lines[3])
+	}
+}
+
+func TestCompactLocation(t *testing.T) {
+	cases := []struct {
+		c    Comment
+		want string
+	}{
+		{Comment{StartLine: 12, EndLine: 12}, "L12"},
+		{Comment{StartLine: 12, EndLine: 14}, "L12-L14"},
+		{Comment{StartLine: 3, EndLine: 3, Side: "old"}, "old L3"},
+		{Comment{StartLine: 12, EndLine: 12, StartCol: 5, EndCol: 18}, "L12:5-18"},
+		{Comment{StartLine: 12, EndLine: 14, StartCol: 5, EndCol: 3}, "L12:5-L14:3"},
+		{Comment{Scope: "file"}, "file"},
+		{Comment{Scope: "file", Region: &imageRegion{X: 10, Y: 20, Width: 300, Height: 200}}, "region 10,20 300x200"},
+	}
+	for _, tc := range cases {
+		if got := compactLocation(tc.c); got != tc.want {
+			t.Errorf("compactLocation(%+v) = %q, want %q", tc.c, got, tc.want)
+		}
+	}
+}
+
+func TestValidReviewStyle(t *testing.T) {
+	if got, err := validReviewStyle(""); got != reviewStyleVerbose || err != nil {
+		t.Errorf("empty: got %q, %v", got, err)
+	}
+	if got, err := validReviewStyle(reviewStyleCompact); got != reviewStyleCompact || err != nil {
+		t.Errorf("compact: got %q, %v", got, err)
+	}
+	if _, err := validReviewStyle("terse"); err == nil {
+		t.Error("expected error for unknown style")
+	}
+}
@@ -0,0 +1,132 @@
+package critcore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// reviewTemplateData is what a review_template is executed with when a round
+// finishes with unresolved comments. The rendered text replaces the default
+// prompt handed to the agent.
+type reviewTemplateData struct {
+	ReviewFile     string // path to the review file
+	Reinvoke       string // command that starts the next round
+	Mode           string // "git", "files" or "plan"
+	Round          int
+	Total          int
+	Unresolved     int
+	Files          []reviewTemplateFile // files with at least one comment
+	ReviewComments []Comment            // review-level comments
+	Checklist      []checklistItem      // configured checklist gates
+}
+
+type reviewTemplateFile struct {
+	Path     string
+	Status   string
+	Comments []reviewTemplateComment
+}
+
+// reviewTemplateComment is a Comment plus the source lines it refers to.
+type reviewTemplateComment struct {
+	Comment
+	Context string // lines StartLine..EndLine of the current file content, if available
+}
+
+// loadReviewTemplate parses the template at path. An empty path means the
+// built-in prompt is used and returns nil.
+func loadReviewTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading review template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing review template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// resolveReviewTemplatePath picks --review-template over the review_template
+// config key. Relative config paths are taken relative to configDir (the
+// directory of the project config) so a project config can point at a
+// template checked in next to it.
+func resolveReviewTemplatePath(flagPath, cfgPath, configDir string) string {
+	if flagPath != "" {
+		if abs, err := filepath.Abs(flagPath); err == nil {
+			return abs
+		}
+		return flagPath
+	}
+	if cfgPath == "" || filepath.IsAbs(cfgPath) || configDir == "" {
+		return cfgPath
+	}
+	return filepath.Join(configDir, cfgPath)
+}
+
+// reviewTemplateData snapshots the session's comments for a review template.
+func (s *Session) reviewTemplateData(reviewFile string) reviewTemplateData {
+	data := reviewTemplateData{
+		ReviewFile: reviewFile,
+		Reinvoke:   s.ReinvokeCommand(),
+		Mode:       s.Mode,
+		Round:      s.GetReviewRound(),
+		Total:      s.TotalCommentCount(),
+		Unresolved: s.UnresolvedCommentCount(),
+		Checklist:  s.GetChecklist(),
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	links := s.commentLinksLocked()
+	data.ReviewComments = append([]Comment(nil), s.reviewComments...)
+	links.apply(data.ReviewComments)
+	for _, f := range s.Files {
+		if len(f.Comments) == 0 {
+			continue
+		}
+		tf := reviewTemplateFile{Path: f.Path, Status: f.Status}
+		comments := append([]Comment(nil), f.Comments...)
+		links.apply(comments)
+		for _, c := range comments {
+			tf.Comments = append(tf.Comments, reviewTemplateComment{
+				Comment: c,
+				Context: lineRange(f.Content, c.StartLine, c.EndLine),
+			})
+		}
+		data.Files = append(data.Files, tf)
+	}
+	return data
+}
+
+// lineRange returns lines start..end (1-based, inclusive) of content, or ""
+// when the range is out of bounds (file-level comments, lazy or deleted files).
+func lineRange(content string, start, end int) string {
+	if content == "" || start < 1 {
+		return ""
+	}
+	if end < start {
+		end = start
+	}
+	lines := strings.Split(content, "\n")
+	if start > len(lines) {
+		return ""
+	}
+	end = min(end, len(lines))
+	return strings.Join(lines[start-1:end], "\n")
+}
+
+// renderReviewTemplate executes tmpl with the session's current review.
+func (s *Session) renderReviewTemplate(tmpl *template.Template, reviewFile string) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, s.reviewTemplateData(reviewFile)); err != nil {
+		return "", fmt.Errorf("executing review template: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,104 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestFinish_ReviewTemplateReplacesPrompt(t *testing.T) {
+	s, session := newTestServer(t)
+	session.AddComment("test.md", 2, 3, "", "tighten this", "", "")
+	session.AddReviewComment("overall looks good", "")
+	s.reviewTemplate = template.Must(template.New("t").Parse(
+		`round {{.Round}}, {{.Unresolved}}/{{.Total}} open in {{.ReviewFile}}
+{{range .Files}}{{$f := .Path}}{{range .Comments}}{{$f}}:{{.StartLine}}-{{.EndLine}} {{.Body}}
+> {{.Context}}
+{{end}}{{end}}{{range .ReviewComments}}review: {{.Body}}{{end}}`))
+
+	req := httptest.NewRequest("POST", "/api/finish", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	prompt, _ := resp["prompt"].(string)
+	for _, want := range []string{
+		"round 1, 2/2 open in " + session.critJSONPath(),
+		"test.md:2-3 tighten this\n> line2\nline3\n",
+		"review: overall looks good",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestFinish_ReviewTemplateErrorFallsBack(t *testing.T) {
+	s, session := newTestServer(t)
+	session.AddComment("test.md", 1, 1, "", "fix this", "", "")
+	s.reviewTemplate = template.Must(template.New("t").Parse(`{{.NoSuchField}}`))
+
+	req := httptest.NewRequest("POST", "/api/finish", nil)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if prompt, _ := resp["prompt"].(string); !strings.Contains(prompt, "Review comments are in") {
+		t.Errorf("expected built-in prompt on template error, got %q", prompt)
+	}
+}
+
+func TestLoadReviewTemplate(t *testing.T) {
+	if tmpl, err := loadReviewTemplate(""); tmpl != nil || err != nil {
+		t.Errorf("empty path: got %v, %v", tmpl, err)
+	}
+	dir := t.TempDir()
+	if _, err := loadReviewTemplate(filepath.Join(dir, "missing.tmpl")); err == nil {
+		t.Error("expected error for missing template")
+	}
+	bad := filepath.Join(dir, "bad.tmpl")
+	writeFile(t, bad, "{{.Unclosed")
+	if _, err := loadReviewTemplate(bad); err == nil || !strings.Contains(err.Error(), "parsing review template") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
+
+func TestResolveReviewTemplatePath(t *testing.T) {
+	if got := resolveReviewTemplatePath("", "prompts/review.tmpl", "/repo"); got != filepath.Join("/repo", "prompts", "review.tmpl") {
+		t.Errorf("relative config path: got %q", got)
+	}
+	if got := resolveReviewTemplatePath("", "/abs/review.tmpl", "/repo"); got != "/abs/review.tmpl" {
+		t.Errorf("absolute config path: got %q", got)
+	}
+	if got := resolveReviewTemplatePath("/flag.tmpl", "/abs/review.tmpl", "/repo"); got != "/flag.tmpl" {
+		t.Errorf("flag should win: got %q", got)
+	}
+}
+
+func TestLineRange(t *testing.T) {
+	content := "a\nb\nc"
+	cases := []struct {
+		start, end int
+		want       string
+	}{
+		{1, 1, "a"},
+		{2, 3, "b\nc"},
+		{3, 9, "c"},
+		{0, 0, ""},
+		{4, 4, ""},
+	}
+	for _, c := range cases {
+		if got := lineRange(content, c.start, c.end); got != c.want {
+			t.Errorf("lineRange(%d, %d) = %q, want %q", c.start, c.end, got, c.want)
+		}
+	}
+}
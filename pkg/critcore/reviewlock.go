@@ -0,0 +1,184 @@
+package critcore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Two daemons with the same review file (reviews sharing an `output`
+// directory, or a plan opened twice) each write it from their own debounce
+// timer, silently dropping the other's comments. runServe holds an flock on
+// <global storage root>/locks/<hash of the review file path>.lock for its
+// lifetime, with its PID and port in the file, and a second daemon for the
+// same review file refuses to start, pointing at the first. The lock lives
+// under the global root so that two projects' storage settings can't put it
+// in different places, and the kernel drops it when the daemon dies; the file
+// left behind is stale, which `crit doctor --fix` cleans up.
+
+// reviewLockInfo is what a review file lock records about its daemon.
+type reviewLockInfo struct {
+	PID        int    `json:"pid"`
+	Port       int    `json:"port"`
+	ReviewPath string `json:"review_path"`
+	StartedAt  string `json:"started_at"`
+}
+
+// reviewLock is a daemon's hold on its review file.
+type reviewLock struct {
+	mu   sync.Mutex
+	f    *os.File
+	info reviewLockInfo
+}
+
+// reviewLockedError is returned by acquireReviewLock when another daemon
+// holds the review file. url is that daemon's review page, when its session
+// file was found.
+type reviewLockedError struct {
+	holder reviewLockInfo
+	url    string
+}
+
+func (e *reviewLockedError) Error() string {
+	msg := e.holder.ReviewPath + " is already open in another crit"
+	if e.holder.PID > 0 {
+		msg += fmt.Sprintf(" (PID %d, port %d)", e.holder.PID, e.holder.Port)
+	}
+	if e.url != "" {
+		msg += ". Review there: " + e.url
+	}
+	return msg + ". Stop that daemon first to open the review here"
+}
+
+// reviewLockDir returns <global storage root>/locks.
+func reviewLockDir() (string, error) {
+	root, err := globalStorageRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "locks"), nil
+}
+
+func reviewLockPath(critPath string) (string, error) {
+	dir, err := reviewLockDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, computeFileHash([]byte(critPath))[:16]+".lock"), nil
+}
+
+// acquireReviewLock locks the review file critPath for this daemon, which
+// listens on port. It fails with a *reviewLockedError when another daemon
+// holds it.
+func acquireReviewLock(critPath string, port int) (*reviewLock, error) {
+	info := reviewLockInfo{
+		PID:        os.Getpid(),
+		Port:       port,
+		ReviewPath: critPath,
+		StartedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+	f, err := lockReviewFile(info)
+	if err != nil {
+		return nil, err
+	}
+	return &reviewLock{f: f, info: info}, nil
+}
+
+// mustLockReviewFile takes the review file lock for runServe, exiting
+// through daemonFatal when another daemon holds it.
+func mustLockReviewFile(pipe *os.File, critPath string, port int) *reviewLock {
+	lock, err := acquireReviewLock(critPath, port)
+	if err != nil {
+		daemonFatal(pipe, "Error: %v", err)
+	}
+	return lock
+}
+
+// lockReviewFile takes the lock of info.ReviewPath without waiting and
+// writes info into it.
+func lockReviewFile(info reviewLockInfo) (*os.File, error) {
+	path, err := reviewLockPath(info.ReviewPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("creating lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, lockHolder(path, info.ReviewPath)
+		}
+		return nil, fmt.Errorf("locking review file: %w", err)
+	}
+	data, _ := json.Marshal(info)
+	if err := f.Truncate(0); err == nil {
+		_, err = f.WriteAt(data, 0)
+	}
+	if err != nil {
+		releaseSessionLock(f)
+		return nil, fmt.Errorf("writing review file lock: %w", err)
+	}
+	return f, nil
+}
+
+// lockHolder describes the daemon holding the lock at path. The holder may
+// not have written its details yet, so they can be missing.
+func lockHolder(path, critPath string) *reviewLockedError {
+	var holder reviewLockInfo
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &holder) //nolint:errcheck // best effort
+	}
+	holder.ReviewPath = critPath
+	locked := &reviewLockedError{holder: holder}
+	if holder.PID <= 0 {
+		return locked
+	}
+	entries, _ := readAllSessionEntries()
+	for _, entry := range entries {
+		if entry.PID == holder.PID && entry.Port == holder.Port {
+			locked.url = entry.url("")
+			break
+		}
+	}
+	return locked
+}
+
+// move hands the lock over to newPath after the session's rename moved its
+// review file there. When newPath can't be locked the old lock is kept.
+func (l *reviewLock) move(newPath string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if newPath == "" || newPath == l.info.ReviewPath {
+		return
+	}
+	info := l.info
+	info.ReviewPath = newPath
+	f, err := lockReviewFile(info)
+	if err != nil {
+		slog.Warn("moving review file lock", "path", newPath, "err", err)
+		return
+	}
+	releaseSessionLock(l.f)
+	l.f, l.info = f, info
+}
+
+// release unlocks and removes the lock.
+func (l *reviewLock) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	releaseSessionLock(l.f)
+}
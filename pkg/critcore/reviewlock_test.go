@@ -0,0 +1,96 @@
+package critcore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAcquireReviewLock_SecondDaemonRefused(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	withProjectStorage(t, filepath.Join(home, ".crit"))
+	critPath := filepath.Join(t.TempDir(), ".crit.json")
+
+	first, err := acquireReviewLock(critPath, 4123)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := sessionEntry{PID: os.Getpid(), Port: 4123, Token: "tok", ReviewPath: critPath}
+	if err := writeSessionFile("key1", entry); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = acquireReviewLock(critPath, 4124)
+	var locked *reviewLockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("second lock: err = %v, want a reviewLockedError", err)
+	}
+	if locked.holder.PID != os.Getpid() || locked.holder.Port != 4123 || locked.url != entry.url("") {
+		t.Errorf("holder = %+v, url = %q", locked.holder, locked.url)
+	}
+	if !strings.Contains(err.Error(), critPath) {
+		t.Errorf("error %q doesn't name the review file", err)
+	}
+
+	first.release()
+	second, err := acquireReviewLock(critPath, 4124)
+	if err != nil {
+		t.Fatalf("lock after release: %v", err)
+	}
+	second.release()
+}
+
+func TestReviewLock_OtherReviewFileIsFree(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	a, err := acquireReviewLock(filepath.Join(dir, "a.json"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.release()
+	b, err := acquireReviewLock(filepath.Join(dir, "b.json"), 2)
+	if err != nil {
+		t.Fatalf("a different review file should lock: %v", err)
+	}
+	b.release()
+}
+
+func TestReviewLock_MoveFollowsRename(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old.json"), filepath.Join(dir, "new.json")
+	lock, err := acquireReviewLock(oldPath, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.move(newPath)
+	defer lock.release()
+
+	if _, err := acquireReviewLock(newPath, 2); err == nil {
+		t.Error("the new path should be locked after move")
+	}
+	freed, err := acquireReviewLock(oldPath, 2)
+	if err != nil {
+		t.Fatalf("the old path should be free after move: %v", err)
+	}
+	freed.release()
+}
+
+func TestStaleLockFiles_ReviewLocks(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	held, err := acquireReviewLock(filepath.Join(t.TempDir(), "held.json"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.release()
+	dir, _ := reviewLockDir()
+	stale := filepath.Join(dir, "0123456789abcdef.lock")
+	os.WriteFile(stale, []byte(`{"pid":1}`), 0600)
+
+	if got := staleLockFiles([]string{dir}); len(got) != 1 || got[0] != stale {
+		t.Errorf("staleLockFiles = %v, want [%s]", got, stale)
+	}
+}
@@ -0,0 +1,95 @@
+package critcore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// defaultReviewFileName is the review file's name inside an --output directory
+// when review_filename is not set.
+const defaultReviewFileName = ".crit.json"
+
+// reviewNameData is what a review_filename pattern is executed with.
+type reviewNameData struct {
+	Base   string // first reviewed file without directory or extension; the repo directory in git mode
+	Key    string // session key
+	Branch string // current branch, if any
+}
+
+// reviewNameSeparators turns the path separators in branch names such as
+// "feature/foo" into dashes, so .Branch and .Base render into one file name.
+var reviewNameSeparators = strings.NewReplacer("/", "-", `\`, "-")
+
+// renderReviewFileName expands a review_filename pattern such as
+// "{{.Base}}.crit-review.json" or a fixed "review.json". The result must be a
+// plain file name, since it is joined onto the output directory.
+func renderReviewFileName(pattern string, data reviewNameData) (string, error) {
+	if pattern == "" {
+		return defaultReviewFileName, nil
+	}
+	data.Branch = reviewNameSeparators.Replace(data.Branch)
+	data.Base = reviewNameSeparators.Replace(data.Base)
+	tmpl, err := template.New("review_filename").Option("missingkey=error").Parse(pattern)
+	if err != nil {
+		return "", fmt.Errorf("parsing review_filename: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering review_filename: %w", err)
+	}
+	name := strings.TrimSpace(buf.String())
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("review_filename %q must render to a file name, got %q", pattern, name)
+	}
+	return name, nil
+}
+
+// reviewNameBase returns the .Base value for a review of files (or of the
+// working tree in dir when files is empty).
+func reviewNameBase(files []string, dir string) string {
+	path := dir
+	if len(files) > 0 {
+		path = files[0]
+	}
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// outputReviewPath returns the review file inside dir for CLI commands run
+// with --output. A running session writing into dir is authoritative, since a
+// review_filename pattern may depend on the files it was started with;
+// otherwise the pattern is rendered for a git-mode review of the working
+// directory.
+func outputReviewPath(dir string) string {
+	cwd, err := resolvedCWD()
+	if err != nil {
+		return filepath.Join(dir, defaultReviewFileName)
+	}
+	sessions, _ := listSessionsForCWD(cwd)
+	for _, s := range sessions {
+		if s.ReviewPath != "" && filepath.Dir(s.ReviewPath) == dir {
+			return s.ReviewPath
+		}
+	}
+	configDir, branch := cwd, ""
+	if vcs := DetectVCS(""); vcs != nil {
+		if root, err := vcs.RepoRoot(); err == nil {
+			configDir = root
+		}
+		branch = vcs.CurrentBranch()
+	}
+	name, err := renderReviewFileName(LoadConfig(configDir).ReviewFileName, reviewNameData{
+		Base:   reviewNameBase(nil, cwd),
+		Key:    sessionKey(cwd, branch, nil),
+		Branch: branch,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; using %s\n", err, defaultReviewFileName)
+		name = defaultReviewFileName
+	}
+	return filepath.Join(dir, name)
+}
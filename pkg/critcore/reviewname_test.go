@@ -0,0 +1,54 @@
+package critcore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderReviewFileName(t *testing.T) {
+	data := reviewNameData{Base: "plan", Key: "abc123", Branch: "main"}
+	cases := []struct {
+		pattern string
+		want    string
+	}{
+		{"", ".crit.json"},
+		{"REVIEW.md", "REVIEW.md"},
+		{"{{.Base}}.crit-review.json", "plan.crit-review.json"},
+		{"review-{{.Branch}}-{{.Key}}.json", "review-main-abc123.json"},
+	}
+	for _, tc := range cases {
+		got, err := renderReviewFileName(tc.pattern, data)
+		if err != nil || got != tc.want {
+			t.Errorf("renderReviewFileName(%q) = %q, %v; want %q", tc.pattern, got, err, tc.want)
+		}
+	}
+	if got, err := renderReviewFileName("{{.Branch}}.json", reviewNameData{Branch: "feature/foo"}); err != nil || got != "feature-foo.json" {
+		t.Errorf("branch with a slash: %q, %v", got, err)
+	}
+	for _, bad := range []string{"{{.Nope}}", "{{.Base", "reviews/{{.Base}}.json", "{{.Branch}}"} {
+		if _, err := renderReviewFileName(bad, reviewNameData{}); err == nil {
+			t.Errorf("renderReviewFileName(%q): expected error", bad)
+		}
+	}
+}
+
+func TestReviewNameBase(t *testing.T) {
+	if got := reviewNameBase([]string{"docs/plan.md", "b.go"}, "/repo"); got != "plan" {
+		t.Errorf("files: got %q", got)
+	}
+	if got := reviewNameBase(nil, "/src/myrepo"); got != "myrepo" {
+		t.Errorf("git mode: got %q", got)
+	}
+}
+
+func TestCritJSONPath_CustomReviewFileName(t *testing.T) {
+	dir := t.TempDir()
+	s := &Session{OutputDir: dir, reviewFileName: "REVIEW.md"}
+	if got, want := s.critJSONPath(), filepath.Join(dir, "REVIEW.md"); got != want {
+		t.Errorf("critJSONPath = %q, want %q", got, want)
+	}
+	s.reviewFileName = ""
+	if got, want := s.critJSONPath(), filepath.Join(dir, ".crit.json"); got != want {
+		t.Errorf("default critJSONPath = %q, want %q", got, want)
+	}
+}
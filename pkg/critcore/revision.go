@@ -0,0 +1,156 @@
+package critcore
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// `crit plan.md@HEAD~2`, or `crit --rev HEAD~2 plan.md`, reviews a file as
+// it was at a git revision: what the plan said before the agent's latest
+// rewrite. createSession writes the blob to revisions/<hash>/<name> under
+// the --output directory (the storage root without one) and reviews that
+// snapshot under the argument as written, so comments are filed under
+// "plan.md@HEAD~2". The snapshot never changes, so the session is marked
+// read-only-source: /api/session reports read_only_source, the review UI
+// shows it in the header, and the prompt tells the agent to make its changes
+// in the current file.
+
+// revisionSnapshot is a path@rev file argument and the snapshot of its blob.
+type revisionSnapshot struct {
+	Arg  string // the argument, and the file's session path
+	Rev  string // the revision as given
+	Path string // the snapshot on disk
+}
+
+// splitRevisionArg splits a "path@rev" file argument. Arguments naming an
+// existing file, and URLs, are never split.
+func splitRevisionArg(arg string) (path, rev string, ok bool) {
+	if isRemoteArg(arg) {
+		return "", "", false
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return "", "", false
+	}
+	i := strings.LastIndex(arg, "@")
+	if i <= 0 || i == len(arg)-1 {
+		return "", "", false
+	}
+	return arg[:i], arg[i+1:], true
+}
+
+// applyRevisionFlag rewrites each file argument as path@rev for --rev.
+func applyRevisionFlag(files []string, rev string) ([]string, error) {
+	if rev == "" {
+		return files, nil
+	}
+	if len(files) == 0 {
+		return nil, errors.New("--rev needs file arguments")
+	}
+	out := make([]string, len(files))
+	for i, f := range files {
+		if _, _, ok := splitRevisionArg(f); ok || isRemoteArg(f) {
+			return nil, fmt.Errorf("--rev can't be combined with %s", f)
+		}
+		if info, err := os.Stat(f); err == nil && info.IsDir() {
+			return nil, fmt.Errorf("--rev takes files, not directories: %s", f)
+		}
+		out[i] = f + "@" + rev
+	}
+	return out, nil
+}
+
+// resolveRevisionArgs replaces each path@rev argument in files with the path
+// of its snapshot under outputDir (the storage root when empty).
+func resolveRevisionArgs(files []string, outputDir string) ([]string, []revisionSnapshot, error) {
+	var snaps []revisionSnapshot
+	local := make([]string, len(files))
+	for i, arg := range files {
+		local[i] = arg
+		path, rev, ok := splitRevisionArg(arg)
+		if !ok {
+			continue
+		}
+		dir, err := localCopyRoot(outputDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		commit, data, err := gitBlobAt(path, rev)
+		if err != nil {
+			return nil, nil, err
+		}
+		abs, _ := filepath.Abs(path)
+		sum := sha256.Sum256([]byte(commit + "\x00" + abs))
+		snapPath := filepath.Join(dir, "revisions", fmt.Sprintf("%x", sum[:6]), filepath.Base(path))
+		if _, err := os.Stat(snapPath); err != nil {
+			if err := os.MkdirAll(filepath.Dir(snapPath), 0755); err != nil {
+				return nil, nil, err
+			}
+			if err := atomicWriteFile(snapPath, data, 0644); err != nil {
+				return nil, nil, err
+			}
+		}
+		local[i] = snapPath
+		snaps = append(snaps, revisionSnapshot{Arg: arg, Rev: rev, Path: snapPath})
+	}
+	return local, snaps, nil
+}
+
+// gitBlobAt returns the commit rev resolves to and the content of path, a
+// file argument, in it.
+func gitBlobAt(path, rev string) (commit string, data []byte, err error) {
+	out, err := exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}").Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("%s@%s: not a git revision", path, rev)
+	}
+	commit = strings.TrimSpace(string(out))
+	if filepath.IsAbs(path) {
+		if cwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(cwd, path); err == nil {
+				path = rel
+			}
+		}
+	}
+	data, err = exec.Command("git", "show", commit+":./"+filepath.ToSlash(path)).Output()
+	if err != nil {
+		return "", nil, fmt.Errorf("%s@%s: the file doesn't exist at that revision", path, rev)
+	}
+	return commit, data, nil
+}
+
+// adoptRevisionSnapshots files the entries of snapshots under their
+// arguments. They have no diff: the snapshot isn't in the working tree.
+func (s *Session) adoptRevisionSnapshots(snaps []revisionSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, snap := range snaps {
+		for _, f := range s.Files {
+			if f.AbsPath == snap.Path {
+				f.Path = snap.Arg
+				f.Revision = snap.Rev
+				f.DiffHunks = nil
+			}
+		}
+	}
+}
+
+// readOnlySource reports whether any file is a snapshot of a git revision.
+func (s *Session) readOnlySource() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readOnlySourceLocked()
+}
+
+// readOnlySourceLocked is readOnlySource for callers holding s.mu.
+func (s *Session) readOnlySourceLocked() bool {
+	for _, f := range s.Files {
+		if f.Revision != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitRevisionArg(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeFile(t, filepath.Join(dir, "me@host.md"), "x")
+	tests := []struct {
+		arg       string
+		path, rev string
+		ok        bool
+	}{
+		{"plan.md@HEAD~2", "plan.md", "HEAD~2", true},
+		{"docs/a@b.md@v1.2", "docs/a@b.md", "v1.2", true},
+		{"me@host.md", "", "", false}, // an existing file
+		{"plan.md", "", "", false},
+		{"plan.md@", "", "", false},
+		{"@HEAD", "", "", false},
+		{"https://user@example.com/spec.md", "", "", false},
+	}
+	for _, tt := range tests {
+		path, rev, ok := splitRevisionArg(tt.arg)
+		if path != tt.path || rev != tt.rev || ok != tt.ok {
+			t.Errorf("splitRevisionArg(%q) = %q, %q, %v", tt.arg, path, rev, ok)
+		}
+	}
+}
+
+func TestApplyRevisionFlag(t *testing.T) {
+	t.Chdir(t.TempDir())
+	os.Mkdir("docs", 0755)
+	got, err := applyRevisionFlag([]string{"plan.md", "api.md"}, "HEAD~1")
+	if err != nil || !slices.Equal(got, []string{"plan.md@HEAD~1", "api.md@HEAD~1"}) {
+		t.Errorf("applyRevisionFlag = %q, %v", got, err)
+	}
+	for _, files := range [][]string{nil, {"plan.md@v1"}, {"docs"}, {"https://example.com/a.md"}} {
+		if _, err := applyRevisionFlag(files, "HEAD"); err == nil {
+			t.Errorf("applyRevisionFlag(%q) accepted", files)
+		}
+	}
+	if got, _ := applyRevisionFlag([]string{"plan.md"}, ""); !slices.Equal(got, []string{"plan.md"}) {
+		t.Errorf("without --rev: %q", got)
+	}
+}
+
+func TestResolveRevisionArgs(t *testing.T) {
+	dir := initTestRepo(t)
+	writeFile(t, filepath.Join(dir, "docs", "plan.md"), "# Plan v1\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-m", "v1")
+	writeFile(t, filepath.Join(dir, "docs", "plan.md"), "# Plan v2\n")
+	runGit(t, dir, "commit", "-am", "v2")
+	t.Chdir(filepath.Join(dir, "docs"))
+	out := t.TempDir()
+
+	files, snaps, err := resolveRevisionArgs([]string{"plan.md@HEAD~1", "plan.md"}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files[1] != "plan.md" || len(snaps) != 1 || snaps[0].Path != files[0] || snaps[0].Rev != "HEAD~1" {
+		t.Fatalf("files %q, snapshots %+v", files, snaps)
+	}
+	if data, _ := os.ReadFile(files[0]); string(data) != "# Plan v1\n" {
+		t.Errorf("snapshot = %q", data)
+	}
+
+	session, err := NewSessionFromFiles(files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.adoptRevisionSnapshots(snaps)
+	info := session.GetSessionInfo()
+	if !info.ReadOnlySource || info.Files[0].Path != "plan.md@HEAD~1" || info.Files[0].Revision != "HEAD~1" || info.Files[1].Revision != "" {
+		t.Errorf("session info: read-only %v, files %+v", info.ReadOnlySource, info.Files)
+	}
+
+	for _, arg := range []string{"plan.md@nope", "missing.md@HEAD"} {
+		if _, _, err := resolveRevisionArgs([]string{arg}, out); err == nil {
+			t.Errorf("%s: no error", arg)
+		} else if !strings.Contains(err.Error(), arg) {
+			t.Errorf("%s: error %q doesn't name the argument", arg, err)
+		}
+	}
+}
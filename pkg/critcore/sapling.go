@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"context"
@@ -209,12 +209,12 @@ func (s *SaplingVCS) CommitLog(baseRef, dir string) ([]CommitInfo, error) {
 // Note: `sl status` output may vary with locale settings on some systems.
 // This is acceptable for change-detection (comparing consecutive calls) but
 // should not be used as a stable hash key.
-func (s *SaplingVCS) WorkingTreeFingerprint() string {
+func (s *SaplingVCS) WorkingTreeFingerprint(skip func(path string) bool) string {
 	out, err := exec.Command("sl", "status").Output()
 	if err != nil {
 		return ""
 	}
-	return string(out)
+	return filterStatusLines(string(out), skip)
 }
 
 // UntrackedFiles returns untracked files in the given directory.
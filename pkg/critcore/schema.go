@@ -0,0 +1,79 @@
+package critcore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// critJSONSchemaVersion is the review file format version this build writes.
+// Bump it and append a migration whenever a field is renamed, restructured,
+// or changes meaning. Purely additive fields with omitempty don't need a bump.
+const critJSONSchemaVersion = 1
+
+// errNewerCritSchema is returned when a review file was written by a newer
+// crit. Such files are left untouched: rewriting them with this build's
+// struct would drop whatever fields the newer version added.
+var errNewerCritSchema = errors.New("review file was written by a newer version of crit")
+
+// critJSONMigrations upgrade a decoded review file one version at a time:
+// critJSONMigrations[i] takes a version-i document to version i+1. They
+// operate on the raw top-level object so renamed or reshaped fields can be
+// carried over before the document is decoded into CritJSON.
+var critJSONMigrations = []func(raw map[string]json.RawMessage) error{
+	migrateCritJSONV0,
+}
+
+// migrateCritJSONV0 upgrades pre-versioning files. The shape is unchanged;
+// older writers could omit "files" entirely, which later code assumes exists.
+func migrateCritJSONV0(raw map[string]json.RawMessage) error {
+	if f, ok := raw["files"]; !ok || string(f) == "null" {
+		raw["files"] = json.RawMessage("{}")
+	}
+	return nil
+}
+
+// unmarshalCritJSON decodes a review file, migrating older schema versions to
+// the current one. Use it instead of json.Unmarshal for anything read from disk.
+func unmarshalCritJSON(data []byte, cj *CritJSON) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw == nil {
+		raw = make(map[string]json.RawMessage)
+	}
+	version := 0
+	if v, ok := raw["schema_version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return fmt.Errorf("invalid schema_version: %w", err)
+		}
+	}
+	if version > critJSONSchemaVersion {
+		return fmt.Errorf("%w (schema %d, this build supports %d); upgrade crit", errNewerCritSchema, version, critJSONSchemaVersion)
+	}
+	if version == critJSONSchemaVersion {
+		return json.Unmarshal(data, cj)
+	}
+	for v := version; v < critJSONSchemaVersion; v++ {
+		if err := critJSONMigrations[v](raw); err != nil {
+			return fmt.Errorf("migrating review file from schema %d: %w", v, err)
+		}
+	}
+	raw["schema_version"] = json.RawMessage(strconv.Itoa(critJSONSchemaVersion))
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(migrated, cj)
+}
+
+// MarshalJSON stamps the current schema version on every write so callers
+// can't forget to.
+func (cj CritJSON) MarshalJSON() ([]byte, error) {
+	type plain CritJSON
+	p := plain(cj)
+	p.SchemaVersion = critJSONSchemaVersion
+	return json.Marshal(p)
+}
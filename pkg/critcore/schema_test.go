@@ -0,0 +1,101 @@
+package critcore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalCritJSON_MigratesUnversioned(t *testing.T) {
+	data := []byte(`{
+  "branch": "feat",
+  "review_round": 2,
+  "files": {
+    "plan.md": {"status": "modified", "file_hash": "sha256:x", "comments": [
+      {"id": "c_abc123", "start_line": 1, "end_line": 2, "body": "keep me"}
+    ]}
+  }
+}`)
+	var cj CritJSON
+	if err := unmarshalCritJSON(data, &cj); err != nil {
+		t.Fatalf("unmarshalCritJSON: %v", err)
+	}
+	if cj.SchemaVersion != critJSONSchemaVersion {
+		t.Errorf("SchemaVersion = %d, want %d", cj.SchemaVersion, critJSONSchemaVersion)
+	}
+	c := cj.Files["plan.md"].Comments
+	if len(c) != 1 || c[0].Body != "keep me" || c[0].EndLine != 2 {
+		t.Errorf("comments not preserved: %+v", c)
+	}
+	if cj.Branch != "feat" || cj.ReviewRound != 2 {
+		t.Errorf("metadata not preserved: %+v", cj)
+	}
+}
+
+func TestUnmarshalCritJSON_MissingFilesBecomesEmptyMap(t *testing.T) {
+	var cj CritJSON
+	if err := unmarshalCritJSON([]byte(`{"branch":"main"}`), &cj); err != nil {
+		t.Fatal(err)
+	}
+	if cj.Files == nil {
+		t.Error("expected Files to be initialized by migration")
+	}
+}
+
+func TestUnmarshalCritJSON_RejectsNewerSchema(t *testing.T) {
+	var cj CritJSON
+	err := unmarshalCritJSON([]byte(`{"schema_version": 999, "files": {}}`), &cj)
+	if !errors.Is(err, errNewerCritSchema) {
+		t.Fatalf("err = %v, want errNewerCritSchema", err)
+	}
+}
+
+func TestUnmarshalCritJSON_InvalidJSON(t *testing.T) {
+	var cj CritJSON
+	if err := unmarshalCritJSON([]byte(`{not json`), &cj); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestCritJSON_MarshalStampsSchemaVersion(t *testing.T) {
+	data, err := json.MarshalIndent(CritJSON{Files: map[string]CritJSONFile{}}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"schema_version": 1`) {
+		t.Errorf("marshaled review file missing schema_version:\n%s", data)
+	}
+}
+
+// TestWriteFiles_DoesNotOverwriteNewerSchema verifies comments written by a
+// newer crit are not clobbered by an older build that can't represent them.
+func TestWriteFiles_DoesNotOverwriteNewerSchema(t *testing.T) {
+	dir := t.TempDir()
+	critPath := filepath.Join(dir, "review.json")
+	newer := `{"schema_version": 999, "files": {"a.md": {"comments": [{"id": "c_1", "severity": "high"}]}}}`
+	writeFile(t, critPath, newer)
+
+	s := &Session{
+		Mode:           "files",
+		RepoRoot:       dir,
+		ReviewFilePath: critPath,
+		Files: []*FileEntry{
+			{Path: "a.md", AbsPath: filepath.Join(dir, "a.md"), Comments: []Comment{}},
+		},
+		subscribers: make(map[chan SSEEvent]struct{}),
+	}
+	s.AddComment("a.md", 1, 1, "", "new", "", "tester")
+	flushWrites(s)
+	s.WriteFiles()
+
+	got, err := os.ReadFile(critPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != newer {
+		t.Errorf("newer-schema review file was rewritten:\n%s", got)
+	}
+}
@@ -0,0 +1,134 @@
+package critcore
+
+import (
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Long plan reviews need "jump to where we discussed retries" without
+// scrolling, so /api/search looks for a query in the reviewed files and in
+// comment bodies and replies, returning line numbers and comment IDs the UI
+// (or an agent) can jump to.
+
+const (
+	maxSearchResults = 200 // per kind: lines and comments
+	maxSnippetRunes  = 200
+)
+
+type searchResult struct {
+	Query     string          `json:"query"`
+	Lines     []searchLine    `json:"lines"`
+	Comments  []searchComment `json:"comments"`
+	Truncated bool            `json:"truncated,omitempty"` // a kind hit maxSearchResults
+}
+
+type searchLine struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+type searchComment struct {
+	ID        string `json:"id"`
+	ReplyID   string `json:"reply_id,omitempty"` // set when the match is in a reply
+	Path      string `json:"path,omitempty"`     // "" for review-level comments
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Text      string `json:"text"`
+}
+
+type searchFile struct {
+	path     string
+	absPath  string
+	content  string
+	lazy     bool
+	comments []Comment
+}
+
+// Search finds query, case-insensitively, in every reviewed text file and in
+// every comment and reply. Lazy files are read from disk for the search
+// without loading their diff. Deleted files and images have no text to match.
+func (s *Session) Search(query string) searchResult {
+	s.mu.RLock()
+	review := append([]Comment(nil), s.reviewComments...)
+	var files []searchFile
+	for _, f := range s.Files {
+		sf := searchFile{path: f.Path, absPath: f.AbsPath, content: f.Content, lazy: f.Lazy, comments: append([]Comment(nil), f.Comments...)}
+		if f.Status == "deleted" || f.FileType == "image" {
+			sf.content, sf.lazy = "", false
+		}
+		files = append(files, sf)
+	}
+	s.mu.RUnlock()
+
+	res := searchResult{Query: query, Lines: []searchLine{}, Comments: []searchComment{}}
+	needle := strings.ToLower(query)
+	res.searchComments(needle, "", review)
+	for _, f := range files {
+		content := f.content
+		if f.lazy {
+			if data, err := os.ReadFile(f.absPath); err == nil {
+				content = textContent(data)
+			}
+		}
+		res.searchLines(needle, f.path, content)
+		res.searchComments(needle, f.path, f.comments)
+	}
+	return res
+}
+
+func (res *searchResult) searchLines(needle, path, content string) {
+	if content == "" {
+		return
+	}
+	for i, line := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+		if !strings.Contains(strings.ToLower(line), needle) {
+			continue
+		}
+		if len(res.Lines) == maxSearchResults {
+			res.Truncated = true
+			return
+		}
+		res.Lines = append(res.Lines, searchLine{Path: path, Line: i + 1, Text: truncateStr(line, maxSnippetRunes)})
+	}
+}
+
+func (res *searchResult) searchComments(needle, path string, comments []Comment) {
+	add := func(c Comment, replyID, text string) bool {
+		if len(res.Comments) == maxSearchResults {
+			res.Truncated = true
+			return false
+		}
+		m := searchComment{ID: c.ID, ReplyID: replyID, Path: path, Text: truncateStr(oneLine(text), maxSnippetRunes)}
+		if path != "" && c.Scope != "file" {
+			m.StartLine, m.EndLine = c.StartLine, c.EndLine
+		}
+		res.Comments = append(res.Comments, m)
+		return true
+	}
+	for _, c := range comments {
+		if strings.Contains(strings.ToLower(c.Body), needle) && !add(c, "", c.Body) {
+			return
+		}
+		for _, r := range c.Replies {
+			if strings.Contains(strings.ToLower(r.Body), needle) && !add(c, r.ID, r.Body) {
+				return
+			}
+		}
+	}
+}
+
+// handleSearch serves GET /api/search?q=.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "q query parameter required", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, s.session.Load().Search(query))
+}
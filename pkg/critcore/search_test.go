@@ -0,0 +1,58 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	s, session := newTestServer(t)
+	session.Files[0].Content = "# Plan\nAdd Retries to the client\nno match\nretry budget\n"
+	c, _ := session.AddComment("test.md", 3, 3, "", "what about RETRIES here?", "", "")
+	other, _ := session.AddComment("test.md", 1, 1, "", "unrelated", "", "")
+	reply, _ := session.AddReply("test.md", other.ID, "retries are covered in L2", "agent")
+	r := session.AddReviewComment("Retries need a cap", "")
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/search?q=retries", nil))
+	if w.Code != 200 {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var res searchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Lines) != 1 || res.Lines[0] != (searchLine{Path: "test.md", Line: 2, Text: "Add Retries to the client"}) {
+		t.Errorf("lines = %+v", res.Lines)
+	}
+	want := []searchComment{
+		{ID: r.ID, Text: "Retries need a cap"},
+		{ID: c.ID, Path: "test.md", StartLine: 3, EndLine: 3, Text: "what about RETRIES here?"},
+		{ID: other.ID, ReplyID: reply.ID, Path: "test.md", StartLine: 1, EndLine: 1, Text: "retries are covered in L2"},
+	}
+	if len(res.Comments) != len(want) {
+		t.Fatalf("comments = %+v", res.Comments)
+	}
+	for i := range want {
+		if res.Comments[i] != want[i] {
+			t.Errorf("comment %d = %+v, want %+v", i, res.Comments[i], want[i])
+		}
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/search?q=+", nil))
+	if w.Code != 400 {
+		t.Errorf("blank query: status %d, want 400", w.Code)
+	}
+}
+
+func TestSearch_Truncated(t *testing.T) {
+	_, session := newTestServer(t)
+	session.Files[0].Content = strings.Repeat("needle\n", maxSearchResults+5)
+	res := session.Search("needle")
+	if len(res.Lines) != maxSearchResults || !res.Truncated {
+		t.Errorf("got %d lines, truncated %v", len(res.Lines), res.Truncated)
+	}
+}
@@ -1,21 +1,24 @@
-package main
+package critcore
 
 import (
 	"bytes"
 	"context"
-	"embed"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"text/template"
 	"time"
 
 	"rsc.io/qr"
@@ -37,6 +40,9 @@ type Server struct {
 	versionMu         sync.RWMutex
 	staleIntegrations []staleFile
 	githubAPIURL      string // override for testing; defaults to "https://api.github.com"
+	linearAPIURL      string // override for testing; defaults to defaultLinearAPIURL
+	updatePolicy      updatePolicy
+	updateCachePath   string // ~/.crit/update-check.json; "" disables the cache
 	port              int
 	status            *Status
 	initErr           atomic.Pointer[error]
@@ -44,16 +50,30 @@ type Server struct {
 	homeDir           string
 	cfg               Config
 	reviewPath        string
+	reviewTemplate    *template.Template // replaces the built-in finish prompt when set
+	reviewStyle       string             // "verbose" or "compact" built-in finish prompt
+	notify            bool               // desktop notification on round-complete (--notify)
+	hooks             hookCommands       // lifecycle hook commands (hooks.go)
+	webhooks          []webhookConfig    // review event webhooks (webhooks.go)
+	notifiers         []notifierConfig   // Slack/Discord summaries (notifiers.go)
+	jira              jiraConfig         // creating Jira issues from comments (jira.go)
+	linear            linearConfig       // creating Linear issues from comments (linear.go)
+	metrics           *serverMetrics     // request counters behind /metrics; nil unless --metrics
+	limiter           *rateLimiter       // per-client limit on mutating API requests (ratelimit.go); nil disables
+	checkHost         bool               // reject requests to other hosts or from other origins (hostcheck.go)
+	csrfToken         string             // required on the browser's mutating API requests (csrf.go)
+	accessToken       string             // required on every request (accesstoken.go); "" disables
+	corsOrigins       []string           // other origins allowed to call the API (cors.go)
 }
 
 // NewServer creates a Server with the given session and configuration.
-func NewServer(session *Session, frontendFS embed.FS, shareURL string, authToken string, author string, currentVersion string, port int, agentCmd string) (*Server, error) {
+func NewServer(session *Session, frontendFS fs.FS, shareURL string, authToken string, author string, currentVersion string, port int, agentCmd string) (*Server, error) {
 	assets, err := fs.Sub(frontendFS, "frontend")
 	if err != nil {
 		return nil, fmt.Errorf("loading frontend assets: %w", err)
 	}
 
-	s := &Server{assets: assets, shareURL: shareURL, authToken: authToken, author: author, agentCmd: agentCmd, currentVersion: currentVersion, port: port}
+	s := &Server{assets: assets, shareURL: shareURL, authToken: authToken, author: author, agentCmd: agentCmd, currentVersion: currentVersion, port: port, csrfToken: rand.Text()}
 	if session != nil {
 		s.session.Store(session)
 	}
@@ -63,41 +83,86 @@ func NewServer(session *Session, frontendFS embed.FS, shareURL string, authToken
 	// Endpoints that work without a ready session
 	mux.HandleFunc("/api/health", s.handleHealth)
 	mux.HandleFunc("/api/qr", s.handleQR)
+	mux.HandleFunc("/api/settings", s.handleSettings)
+	mux.HandleFunc("/api/i18n", s.handleI18n)
+	mux.HandleFunc("/api/i18n/", s.handleI18n)
+	mux.HandleFunc("/api/templates", s.handleCommentTemplates)
 
 	// Session-dependent endpoints (guarded by withReady middleware)
 	mux.HandleFunc("/api/review-cycle", s.withReady(s.handleReviewCycle))
 	mux.HandleFunc("/api/config", s.withReady(s.handleConfig))
-	mux.HandleFunc("/api/session", s.withReady(s.handleSession))
+	mux.Handle("/api/session", withCompression(s.withReady(s.handleSession)))
 	mux.HandleFunc("/api/share", s.withReady(s.handleShare))
 	mux.HandleFunc("/api/share-url", s.withReady(s.handleShareURL))
 	mux.HandleFunc("/api/finish", s.withReady(s.handleFinish))
 	mux.HandleFunc("/api/events", s.withReady(s.handleEvents))
 	mux.HandleFunc("/api/wait-for-event", s.withReady(s.handleWaitForEvent))
+	mux.HandleFunc("/api/review/next", s.withReady(s.handleReviewNext))
 	mux.HandleFunc("/api/round-complete", s.withReady(s.handleRoundComplete))
+	mux.HandleFunc("/api/round-complete/ack", s.withReady(s.handleRoundCompleteAck))
 
 	mux.HandleFunc("/api/agent/request", s.withReady(s.handleAgentRequest))
+	mux.HandleFunc("/api/agent/status", s.withReady(s.handleAgentStatus))
 	mux.HandleFunc("/api/branches", s.withReady(s.handleBranches))
 	mux.HandleFunc("/api/base-branch", s.withReady(s.handleBaseBranch))
 	mux.HandleFunc("/api/commits", s.withReady(s.handleCommits))
 	mux.HandleFunc("/api/comments", s.withReady(s.handleReviewComments))
+	mux.HandleFunc("/api/undo", s.withReady(s.handleUndo))
+	mux.HandleFunc("/api/redo", s.withReady(s.handleRedo))
+	mux.HandleFunc("/api/documents", s.withReady(s.handleDocuments))
 	mux.HandleFunc("/api/review-comment/", s.withReady(s.handleReviewCommentByID))
-	mux.HandleFunc("/api/files/list", s.withReady(s.handleFilesList))
+	mux.Handle("/api/files/list", withCompression(s.withReady(s.handleFilesList)))
+	mux.Handle("/api/search", withCompression(s.withReady(s.handleSearch)))
+	mux.HandleFunc("/api/checklist", s.withReady(s.handleChecklist))
+	mux.HandleFunc("/api/drafts", s.withReady(s.handleDrafts))
+	mux.HandleFunc("/api/drafts/", s.withReady(s.handleDrafts))
 
 	// File-scoped endpoints (use ?path= query param)
-	mux.HandleFunc("/api/file", s.withReady(s.handleFile))
-	mux.HandleFunc("/api/file/diff", s.withReady(s.handleFileDiff))
+	mux.Handle("/api/file", withCompression(s.withReady(s.handleFile)))
+	mux.Handle("/api/file/diff", withCompression(s.withReady(s.handleFileDiff)))
+	mux.Handle("/api/file/blame", withCompression(s.withReady(s.handleFileBlame)))
 	mux.HandleFunc("/api/file/comments", s.withReady(s.handleFileComments))
 	mux.HandleFunc("/api/comment/", s.withReady(s.handleCommentByID))
 
-	// Static file serving (repo files need session; embedded assets do not)
+	// Static file serving (repo files need session; embedded assets do not).
+	// Only document-sized responses are compressed (compress.go); never SSE.
 	mux.HandleFunc("/files/", s.withReady(s.handleFiles))
-	mux.Handle("/", http.FileServer(http.FS(assets)))
+	mux.HandleFunc("/plain", s.withReady(s.handlePlain))
+	mux.Handle("/", withCompression(http.FileServer(http.FS(assets))))
 
 	s.mux = mux
 	return s, nil
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.metrics == nil {
+		s.serve(w, r)
+		return
+	}
+	rec := &statusRecorder{ResponseWriter: w}
+	s.serve(rec, r)
+	s.metrics.observe(r, rec.status())
+}
+
+// serve routes r, unless it is addressed to another host, is a CORS
+// preflight, lacks the access token, is a browser request without the CSRF
+// token, or is over the rate limit.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if s.checkHost && !admitHost(w, r, s.corsOrigins) {
+		return
+	}
+	if s.handleCORS(w, r) {
+		return
+	}
+	if !s.admitToken(w, r) {
+		return
+	}
+	if !s.admitCSRF(w, r) {
+		return
+	}
+	if s.limiter != nil && !s.limiter.admit(w, r) {
+		return
+	}
 	s.mux.ServeHTTP(w, r)
 }
 
@@ -143,6 +208,13 @@ func (s *Server) SetSession(session *Session) {
 	s.session.Store(session)
 }
 
+// hasBrowserClients reports whether a browser tab is connected to the
+// session, false before it is created.
+func (s *Server) hasBrowserClients() bool {
+	sess := s.session.Load()
+	return sess != nil && sess.HasBrowserClients()
+}
+
 // SetPRInfo updates the PR metadata after the session is already ready.
 func (s *Server) SetPRInfo(prInfo *PRInfo) {
 	s.prInfoMu.Lock()
@@ -157,38 +229,35 @@ func (s *Server) SetInitErr(err error) {
 	s.initErr.Store(&e)
 }
 
-// CheckForUpdates fetches the latest release tag from GitHub and stores
-// it so the frontend can display an update notification. Safe to call
-// from a goroutine — the result is written under versionMu.
+// CheckForUpdates fetches the latest release tag on the configured channel
+// from GitHub and stores it so the frontend can display an update
+// notification. A tag cached within update_check_interval is used instead
+// of the network. Safe to call from a goroutine — the result is written
+// under versionMu.
 func (s *Server) CheckForUpdates() {
 	if s.currentVersion == "" || s.currentVersion == "dev" {
 		return
 	}
-	base := s.githubAPIURL
-	if base == "" {
-		base = "https://api.github.com"
-	}
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(base + "/repos/tomasz-tomczyk/crit/releases/latest")
-	if err != nil {
-		return
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return
-	}
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
-	if err != nil {
-		return
-	}
-	var release struct {
-		TagName string `json:"tag_name"`
-	}
-	if err := json.Unmarshal(body, &release); err != nil || release.TagName == "" {
-		return
+	latest, ok := cachedLatestVersion(s.updateCachePath, s.updatePolicy, time.Now())
+	if !ok {
+		base := s.githubAPIURL
+		if base == "" {
+			base = defaultGitHubAPIURL
+		}
+		release, err := fetchChannelRelease(s.updatePolicy.httpClient(5*time.Second), base, s.updatePolicy.channel)
+		if err != nil {
+			return
+		}
+		latest = release.TagName
+		if s.updateCachePath != "" {
+			cache := updateCheckCache{CheckedAt: time.Now(), Channel: s.updatePolicy.channel, LatestVersion: latest}
+			if err := writeUpdateCheckCache(s.updateCachePath, cache); err != nil {
+				slog.Debug("writing update check cache", "err", err)
+			}
+		}
 	}
 	s.versionMu.Lock()
-	s.latestVersion = release.TagName
+	s.latestVersion = latest
 	s.versionMu.Unlock()
 }
 
@@ -204,13 +273,17 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 	resp := map[string]interface{}{
 		"share_url":         s.shareURL,
 		"hosted_url":        sess.GetSharedURL(),
+		"share_id":          shareID(sess.GetSharedURL()),
 		"delete_token":      sess.GetDeleteToken(),
+		"share_expires_at":  sess.GetShareExpiry(),
 		"version":           s.currentVersion,
 		"latest_version":    latestVersion,
 		"author":            s.author,
 		"agent_cmd_enabled": s.agentCmd != "",
 		"agent_name":        agentName(s.agentCmd),
 		"agent_cmd":         s.agentCmd,
+		"jira_enabled":      s.jira.enabled(),
+		"linear_enabled":    s.linear.enabled(),
 
 		// Auth status
 		"auth_logged_in":  s.authToken != "",
@@ -220,6 +293,9 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 		// Review file path
 		"review_path": s.reviewPath,
 
+		// Sent back in X-Crit-CSRF on mutating requests (csrf.go)
+		"csrf_token": s.csrfToken,
+
 		// Config pass-throughs for frontend suppression
 		"no_integration_check": s.cfg.NoIntegrationCheck,
 		"no_update_check":      s.cfg.NoUpdateCheck,
@@ -270,6 +346,45 @@ func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, resp)
 }
 
+// defaultTabWidth is the browser's own tab width.
+const defaultTabWidth = 8
+
+// uiSettings are the display settings the frontend applies to code views.
+type uiSettings struct {
+	TabWidth               int    `json:"tab_width"`
+	ShowTrailingWhitespace bool   `json:"show_trailing_whitespace"`
+	Wrap                   string `json:"wrap"`               // "on", "off" or "" for each view's default
+	Timezone               string `json:"timezone,omitempty"` // IANA zone timestamps are shown in; "" for the browser's
+}
+
+// displaySettings reads uiSettings from cfg. Out-of-range tab widths and
+// unknown wrap modes fall back to the defaults; an unknown or "Local"
+// timezone leaves the browser's.
+func displaySettings(cfg Config) uiSettings {
+	settings := uiSettings{TabWidth: cfg.TabWidth, ShowTrailingWhitespace: cfg.ShowTrailingWhitespace, Wrap: cfg.Wrap}
+	if settings.TabWidth < 1 || settings.TabWidth > 16 {
+		settings.TabWidth = defaultTabWidth
+	}
+	if settings.Wrap != "on" && settings.Wrap != "off" {
+		settings.Wrap = ""
+	}
+	if loc, err := loadTimezone(cfg.Timezone); err == nil && loc != time.Local {
+		settings.Timezone = loc.String()
+	}
+	return settings
+}
+
+// handleSettings returns the display settings (tab_width,
+// show_trailing_whitespace, wrap, timezone) from the config files.
+// GET /api/settings
+func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, displaySettings(s.cfg))
+}
+
 // addIntegrationStatus populates integration detection fields in the config response.
 func (s *Server) addIntegrationStatus(resp map[string]interface{}) {
 	if s.cfg.NoIntegrationCheck {
@@ -332,13 +447,21 @@ func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
 	// Idempotent: if already shared, return the existing URL without calling crit-web.
 	// Uses GetShareState() to read both fields under a single lock (avoids TOCTOU race
 	// where a concurrent DELETE /api/share-url could clear the token between two calls).
-	if existingURL, existingToken := s.session.Load().GetShareState(); existingURL != "" {
+	sess := s.session.Load()
+	if existingURL, existingToken := sess.GetShareState(); existingURL != "" && !shareExpired(sess.GetShareExpiry(), time.Now()) {
 		writeJSON(w, map[string]any{
+			"id":           shareID(existingURL),
 			"url":          existingURL,
 			"delete_token": existingToken,
+			"expires_at":   sess.GetShareExpiry(),
 		})
 		return
 	}
+	expiresAt, err := shareExpiryTime(s.cfg.ShareExpires, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	// Read file content from disk and comments from the review file.
 	// This uses the same disk-based path as `crit share` (CLI), ensuring
@@ -358,7 +481,7 @@ func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
 	critPath := s.session.Load().critJSONPath()
 	comments, reviewRound := loadCommentsForShare(critPath, filePaths)
 
-	url, deleteToken, err := shareFilesToWeb(files, comments, s.shareURL, reviewRound, s.authToken)
+	url, deleteToken, err := shareFilesToWeb(files, comments, s.shareURL, reviewRound, s.authToken, shareOptions{encrypt: s.cfg.ShareEncrypt, expiresAt: expiresAt})
 	if err != nil {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadGateway)
@@ -368,11 +491,14 @@ func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
 
 	s.session.Load().SetSharedURLAndToken(url, deleteToken)
 	s.session.Load().SetShareScope(shareScope(filePaths))
-	writeJSON(w, map[string]any{"url": url, "delete_token": deleteToken})
+	s.session.Load().SetShareExpiry(expiresAt)
+	writeJSON(w, map[string]any{"id": shareID(url), "url": url, "delete_token": deleteToken, "expires_at": expiresAt})
 }
 
 // handleFile returns file content + metadata for a single file.
-// GET /api/file?path=server.go
+// GET /api/file?path=server.go (&render=html adds "html" for markdown files)
+// Code files longer than largeFileLines come back as their first chunk unless
+// start/count name another one, or full=1 asks for the whole file.
 func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -393,9 +519,107 @@ func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if notModified(w, r, fileETag(snapshot, r.URL.RawQuery)) {
+		return
+	}
+	if r.URL.Query().Get("render") == "html" && snapshot["file_type"] == "markdown" {
+		content, _ := snapshot["content"].(string)
+		rendered, err := renderMarkdownHTML(content)
+		if err != nil {
+			http.Error(w, "Failed to render markdown", http.StatusInternalServerError)
+			return
+		}
+		snapshot["html"] = rendered
+	}
+	content, _ := snapshot["content"].(string)
+	snapshot["total_lines"] = countLines(content)
+	if q := r.URL.Query(); q.Has("start") || q.Has("count") || defaultChunked(snapshot, q) {
+		if err := applyLineChunk(snapshot, content, q); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 	writeJSON(w, snapshot)
 }
 
+// maxChunkLines caps the count of a /api/file line-range request.
+const maxChunkLines = 5000
+
+// largeFileLines is the length above which /api/file returns a code file's
+// first chunk by default; the frontend fetches the rest as it scrolls.
+const largeFileLines = maxChunkLines
+
+// defaultChunked reports whether a /api/file response without start/count
+// should still be narrowed to the first chunk. Markdown is always whole, since
+// the frontend parses it as one document.
+func defaultChunked(snap map[string]any, q url.Values) bool {
+	if q.Get("full") == "1" || snap["file_type"] != "code" {
+		return false
+	}
+	total, _ := snap["total_lines"].(int)
+	return total > largeFileLines
+}
+
+// applyLineChunk narrows a /api/file response to the lines named by the start
+// (1-based, default 1) and count (default and cap maxChunkLines) query
+// parameters, so very large files can be fetched piecewise. start and count
+// in the response describe the lines actually returned; a start past the end
+// returns no lines.
+func applyLineChunk(snap map[string]any, content string, q url.Values) error {
+	start, count := 1, maxChunkLines
+	var err error
+	if v := q.Get("start"); v != "" {
+		if start, err = strconv.Atoi(v); err != nil || start < 1 {
+			return errors.New("start must be a positive line number")
+		}
+	}
+	if v := q.Get("count"); v != "" {
+		if count, err = strconv.Atoi(v); err != nil || count < 1 {
+			return errors.New("count must be a positive number of lines")
+		}
+	}
+	chunk, n := lineChunk(content, start, min(count, maxChunkLines))
+	snap["content"] = chunk
+	snap["start"] = start
+	snap["count"] = n
+	return nil
+}
+
+// countLines counts lines the way the frontend numbers them: a trailing
+// newline doesn't start another line.
+func countLines(content string) int {
+	n := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		n++
+	}
+	return n
+}
+
+// lineChunk returns up to count lines of content starting at line start,
+// newlines included, and how many lines that is.
+func lineChunk(content string, start, count int) (string, int) {
+	off := 0
+	for i := 1; i < start && off < len(content); i++ {
+		j := strings.IndexByte(content[off:], '\n')
+		if j < 0 {
+			off = len(content)
+			break
+		}
+		off += j + 1
+	}
+	end, n := off, 0
+	for n < count && end < len(content) {
+		j := strings.IndexByte(content[end:], '\n')
+		n++
+		if j < 0 {
+			end = len(content)
+			break
+		}
+		end += j + 1
+	}
+	return content[off:end], n
+}
+
 // handleFileDiff returns diff hunks for a file.
 // For code files: git diff hunks. For markdown files: inter-round LCS diff.
 // GET /api/file/diff?path=server.go
@@ -430,61 +654,169 @@ func (s *Server) handleFileComments(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		comments := s.session.Load().GetComments(path)
-		writeJSON(w, comments)
+		writeCommentList(w, r, s.session.Load().GetComments(path))
 
 	case http.MethodPost:
-		r.Body = http.MaxBytesReader(w, r.Body, 10<<20) // 10MB
-		var req struct {
-			StartLine int    `json:"start_line"`
-			EndLine   int    `json:"end_line"`
-			Side      string `json:"side"`
-			Body      string `json:"body"`
-			Quote     string `json:"quote"`
-			Author    string `json:"author"`
-			Scope     string `json:"scope"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
-		}
-		if req.Body == "" {
-			http.Error(w, "Comment body is required", http.StatusBadRequest)
-			return
-		}
+		s.postFileComment(w, r, path)
 
-		// Ensure the file is registered in the session. Files that appear after
-		// startup (e.g. user creates a new file while reviewing) may be visible in
-		// scoped views but not yet in s.Files.
-		s.session.Load().EnsureFileEntry(path)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
 
-		if req.Scope == "file" {
-			c, ok := s.session.Load().AddFileComment(path, req.Body, req.Author)
-			if !ok {
-				http.Error(w, "File not found", http.StatusNotFound)
-				return
-			}
-			w.WriteHeader(http.StatusCreated)
-			writeJSON(w, c)
-			return
-		}
+// postFileComment creates a region, file-level or line comment from the
+// request body and answers 201 with it.
+func (s *Server) postFileComment(w http.ResponseWriter, r *http.Request, path string) {
+	r.Body = http.MaxBytesReader(w, r.Body, 10<<20) // 10MB
+	var req fileCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var ok bool
+	if req.Body, ok = s.acceptCommentBody(w, req.Body, "Comment"); !ok {
+		return
+	}
+	if err := checkSeverity(req.Severity); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	session := s.session.Load()
+	if !s.acceptNewComment(w, session) || !s.acceptUniqueComment(w, session, path, s.fileCommentTarget(path, req)) {
+		return
+	}
 
-		if req.StartLine < 1 || req.EndLine < req.StartLine {
-			http.Error(w, "Invalid line range", http.StatusBadRequest)
-			return
+	// Ensure the file is registered in the session. Files that appear after
+	// startup (e.g. user creates a new file while reviewing) may be visible in
+	// scoped views but not yet in s.Files.
+	session.EnsureFileEntry(path)
+
+	var c Comment
+	switch {
+	case req.Region != nil:
+		c, ok = s.postRegionComment(w, path, *req.Region, req.Body, req.Author)
+	case req.Scope == "file":
+		if c, ok = session.AddFileComment(path, req.Body, req.Author); !ok {
+			http.Error(w, "File not found", http.StatusNotFound)
 		}
+	default:
+		c, ok = s.postLineComment(w, path, req)
+	}
+	if !ok {
+		return
+	}
+	if req.Severity != "" {
+		c, _ = session.SetCommentSeverity(path, c.ID, req.Severity)
+	}
+	s.emitEvent(session, hookEvent{Event: hookCommentCreated, Path: path, Comment: &c})
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, c)
+}
+
+// fileCommentRequest is the body of POST /api/file/comments.
+type fileCommentRequest struct {
+	StartLine int           `json:"start_line"`
+	EndLine   int           `json:"end_line"`
+	StartCol  int           `json:"start_col"` // optional: 1-based column span within the lines
+	EndCol    int           `json:"end_col"`
+	Side      string        `json:"side"`
+	Body      string        `json:"body"`
+	Quote     string        `json:"quote"`
+	Author    string        `json:"author"`
+	Scope     string        `json:"scope"`
+	DataPath  string        `json:"data_path"`  // JSON/YAML: comment on this value instead of lines
+	TableCell *tableCellRef `json:"table_cell"` // CSV/TSV: comment on this cell instead of lines
+	Region    *imageRegion  `json:"region"`     // images: comment on this area of the file
+	Severity  string        `json:"severity"`   // optional: one of severityLevels, or ""
+}
 
-		c, ok := s.session.Load().AddComment(path, req.StartLine, req.EndLine, req.Side, req.Body, req.Quote, req.Author)
+// postLineComment creates a comment on req's lines (or on its data_path's or
+// table_cell's lines), or on a column span of them when start_col/end_col are given. A
+// range outside the file is rejected with a JSON body (error, start_line,
+// end_line, line_count) so API clients can correct the anchor. Errors are
+// written to w and reported as !ok.
+func (s *Server) postLineComment(w http.ResponseWriter, path string, req fileCommentRequest) (Comment, bool) {
+	if req.DataPath != "" && req.StartLine == 0 {
+		start, end, ok := s.session.Load().DataPathLines(path, req.DataPath)
 		if !ok {
-			http.Error(w, "File not found", http.StatusNotFound)
-			return
+			http.Error(w, "data_path not found", http.StatusBadRequest)
+			return Comment{}, false
 		}
-		w.WriteHeader(http.StatusCreated)
-		writeJSON(w, c)
+		req.StartLine, req.EndLine = start, end
+	}
+	if req.TableCell != nil {
+		return s.postTableCellComment(w, path, req)
+	}
 
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	var rangeErr *lineRangeError
+	switch err := s.session.Load().checkLineRange(path, req.Side, req.StartLine, req.EndLine); {
+	case errors.As(err, &rangeErr):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(struct {
+			Error string `json:"error"`
+			*lineRangeError
+		}{rangeErr.Error(), rangeErr})
+		return Comment{}, false
+	case err != nil:
+		http.Error(w, "File not found", http.StatusNotFound)
+		return Comment{}, false
+	}
+
+	if req.StartCol != 0 || req.EndCol != 0 {
+		return s.postSpanComment(w, path, req)
+	}
+	c, ok := s.session.Load().AddComment(path, req.StartLine, req.EndLine, req.Side, req.Body, req.Quote, req.Author)
+	if !ok {
+		http.Error(w, "File not found", http.StatusNotFound)
+	}
+	return c, ok
+}
+
+// postTableCellComment creates a comment on the row of req's table_cell and
+// anchors it to that cell.
+func (s *Server) postTableCellComment(w http.ResponseWriter, path string, req fileCommentRequest) (Comment, bool) {
+	session := s.session.Load()
+	start, end, ok := session.TableCellLines(path, *req.TableCell)
+	if !ok {
+		http.Error(w, "table_cell not found", http.StatusBadRequest)
+		return Comment{}, false
+	}
+	c, ok := session.AddComment(path, start, end, "", req.Body, req.Quote, req.Author)
+	if !ok {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return Comment{}, false
+	}
+	c, _ = session.SetCommentTableCell(path, c.ID, *req.TableCell)
+	return c, true
+}
+
+// postSpanComment creates a comment on a column span via AddSpanComment.
+func (s *Server) postSpanComment(w http.ResponseWriter, path string, req fileCommentRequest) (Comment, bool) {
+	if req.Side == "old" {
+		http.Error(w, "start_col/end_col are not supported on the old side", http.StatusBadRequest)
+		return Comment{}, false
+	}
+	c, err := s.session.Load().AddSpanComment(path, req.StartLine, req.StartCol, req.EndLine, req.EndCol, req.Body, req.Author)
+	return c, writeAddCommentError(w, err)
+}
+
+// postRegionComment creates a comment on a region of an image file.
+func (s *Server) postRegionComment(w http.ResponseWriter, path string, region imageRegion, body, author string) (Comment, bool) {
+	c, err := s.session.Load().AddRegionComment(path, region, body, author)
+	return c, writeAddCommentError(w, err)
+}
+
+// writeAddCommentError answers 404 for errFileNotFound and 400 for any other
+// error. It reports whether err was nil.
+func writeAddCommentError(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, errFileNotFound):
+		http.Error(w, "File not found", http.StatusNotFound)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
 	}
+	return err == nil
 }
 
 // handleCommentByID handles PUT/DELETE for individual comments and CRUD for replies.
@@ -494,13 +826,13 @@ func (s *Server) handleFileComments(w http.ResponseWriter, r *http.Request) {
 // DELETE     /api/comment/{id}/replies/{rid}?path=server.go
 // commentRoute holds the parsed components of a comment-by-ID URL path.
 type commentRoute struct {
-	kind string // "reply", "resolve", or "comment"
+	kind string // "reply", "resolve", "pin", or "comment"
 	id   string // the comment ID
 	sub  string // for replies: the reply ID (may be empty for POST)
 }
 
 // routeCommentByID parses a URL suffix like "c5", "c5/replies", "c5/replies/r2",
-// or "c5/resolve" and returns the route components. Returns false if the suffix is empty.
+// "c5/resolve" or "c5/pin" and returns the route components. Returns false if the suffix is empty.
 func routeCommentByID(trimmed string) (commentRoute, bool) {
 	if trimmed == "" {
 		return commentRoute{}, false
@@ -515,6 +847,15 @@ func routeCommentByID(trimmed string) (commentRoute, bool) {
 	if parts := strings.SplitN(trimmed, "/resolve", 2); len(parts) == 2 && parts[1] == "" {
 		return commentRoute{kind: "resolve", id: parts[0]}, true
 	}
+	if parts := strings.SplitN(trimmed, "/pin", 2); len(parts) == 2 && parts[1] == "" {
+		return commentRoute{kind: "pin", id: parts[0]}, true
+	}
+	if parts := strings.SplitN(trimmed, "/jira", 2); len(parts) == 2 && parts[1] == "" {
+		return commentRoute{kind: "jira", id: parts[0]}, true
+	}
+	if parts := strings.SplitN(trimmed, "/linear", 2); len(parts) == 2 && parts[1] == "" {
+		return commentRoute{kind: "linear", id: parts[0]}, true
+	}
 	return commentRoute{kind: "comment", id: trimmed}, true
 }
 
@@ -536,6 +877,12 @@ func (s *Server) handleCommentByID(w http.ResponseWriter, r *http.Request) {
 		s.handleReplyRoute(w, r, path, route.id, route.sub)
 	case "resolve":
 		s.handleFileCommentResolve(w, r, path, route.id)
+	case "pin":
+		s.handleFileCommentPin(w, r, path, route.id)
+	case "jira":
+		s.handleCommentJira(w, r, path, route.id)
+	case "linear":
+		s.handleCommentLinear(w, r, path, route.id)
 	case "comment":
 		s.handleFileCommentUpdate(w, r, path, route.id)
 	}
@@ -560,12 +907,39 @@ func (s *Server) handleFileCommentResolve(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Comment not found", http.StatusNotFound)
 		return
 	}
+	s.emitEvent(s.session.Load(), hookEvent{Event: resolveEvent(req.Resolved), Path: path, Comment: &c})
+	writeJSON(w, c)
+}
+
+// handleFileCommentPin handles PUT /api/comment/{id}/pin?path=X.
+func (s *Server) handleFileCommentPin(w http.ResponseWriter, r *http.Request, path, commentID string) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Pinned bool `json:"pinned"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	c, ok := s.session.Load().SetCommentPinned(path, commentID, req.Pinned)
+	if !ok {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
 	writeJSON(w, c)
 }
 
-// handleFileCommentUpdate handles PUT and DELETE on /api/comment/{id}?path=X.
+// handleFileCommentUpdate handles PUT, PATCH and DELETE on
+// /api/comment/{id}?path=X.
 func (s *Server) handleFileCommentUpdate(w http.ResponseWriter, r *http.Request, path, id string) {
 	switch r.Method {
+	case http.MethodPatch:
+		s.handleCommentDisposition(w, r, path, id)
+
 	case http.MethodPut:
 		r.Body = http.MaxBytesReader(w, r.Body, 10<<20) // 10MB
 		var req struct {
@@ -575,15 +949,16 @@ func (s *Server) handleFileCommentUpdate(w http.ResponseWriter, r *http.Request,
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-		if req.Body == "" {
-			http.Error(w, "Comment body is required", http.StatusBadRequest)
+		body, ok := s.acceptCommentBody(w, req.Body, "Comment")
+		if !ok {
 			return
 		}
-		c, ok := s.session.Load().UpdateComment(path, id, req.Body)
+		c, ok := s.session.Load().UpdateComment(path, id, body)
 		if !ok {
 			http.Error(w, "Comment not found", http.StatusNotFound)
 			return
 		}
+		s.emitEvent(s.session.Load(), hookEvent{Event: hookCommentUpdated, Path: path, Comment: &c})
 		writeJSON(w, c)
 
 	case http.MethodDelete:
@@ -658,7 +1033,7 @@ type replyOps struct {
 }
 
 // handleReplyCRUD handles POST/PUT/DELETE for reply routes using the provided operations.
-func handleReplyCRUD(w http.ResponseWriter, r *http.Request, replyID string, ops replyOps) {
+func (s *Server) handleReplyCRUD(w http.ResponseWriter, r *http.Request, replyID string, ops replyOps) {
 	switch {
 	case r.Method == http.MethodPost && replyID == "":
 		r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
@@ -670,11 +1045,11 @@ func handleReplyCRUD(w http.ResponseWriter, r *http.Request, replyID string, ops
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-		if req.Body == "" {
-			http.Error(w, "Reply body is required", http.StatusBadRequest)
+		body, ok := s.acceptCommentBody(w, req.Body, "Reply")
+		if !ok {
 			return
 		}
-		reply, ok := ops.add(req.Body, req.Author)
+		reply, ok := ops.add(body, req.Author)
 		if !ok {
 			http.Error(w, "Comment not found", http.StatusNotFound)
 			return
@@ -691,11 +1066,11 @@ func handleReplyCRUD(w http.ResponseWriter, r *http.Request, replyID string, ops
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-		if req.Body == "" {
-			http.Error(w, "Reply body is required", http.StatusBadRequest)
+		body, ok := s.acceptCommentBody(w, req.Body, "Reply")
+		if !ok {
 			return
 		}
-		reply, ok := ops.update(replyID, req.Body)
+		reply, ok := ops.update(replyID, body)
 		if !ok {
 			http.Error(w, "Reply not found", http.StatusNotFound)
 			return
@@ -715,7 +1090,7 @@ func handleReplyCRUD(w http.ResponseWriter, r *http.Request, replyID string, ops
 }
 
 func (s *Server) handleReplyRoute(w http.ResponseWriter, r *http.Request, filePath, commentID, replyID string) {
-	handleReplyCRUD(w, r, replyID, replyOps{
+	s.handleReplyCRUD(w, r, replyID, replyOps{
 		add: func(body, author string) (Reply, bool) {
 			return s.session.Load().AddReply(filePath, commentID, body, author)
 		},
@@ -729,7 +1104,7 @@ func (s *Server) handleReplyRoute(w http.ResponseWriter, r *http.Request, filePa
 }
 
 func (s *Server) handleReviewCommentReplyRoute(w http.ResponseWriter, r *http.Request, commentID, replyID string) {
-	handleReplyCRUD(w, r, replyID, replyOps{
+	s.handleReplyCRUD(w, r, replyID, replyOps{
 		add: func(body, author string) (Reply, bool) {
 			return s.session.Load().AddReviewCommentReply(commentID, body, author)
 		},
@@ -745,24 +1120,36 @@ func (s *Server) handleReviewCommentReplyRoute(w http.ResponseWriter, r *http.Re
 func (s *Server) handleReviewComments(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		comments := s.session.Load().GetReviewComments()
-		writeJSON(w, comments)
+		writeCommentList(w, r, s.session.Load().GetReviewComments())
 
 	case http.MethodPost:
 		r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
 		var req struct {
-			Body   string `json:"body"`
-			Author string `json:"author"`
+			Body     string `json:"body"`
+			Author   string `json:"author"`
+			Severity string `json:"severity"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-		if req.Body == "" {
-			http.Error(w, "Comment body is required", http.StatusBadRequest)
+		body, ok := s.acceptCommentBody(w, req.Body, "Comment")
+		if !ok {
+			return
+		}
+		if err := checkSeverity(req.Severity); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		c := s.session.Load().AddReviewComment(req.Body, req.Author)
+		target := Comment{Scope: "review", Body: body, Author: req.Author}
+		if !s.acceptNewComment(w, s.session.Load()) || !s.acceptUniqueComment(w, s.session.Load(), "", target) {
+			return
+		}
+		c := s.session.Load().AddReviewComment(body, req.Author)
+		if req.Severity != "" {
+			c, _ = s.session.Load().SetCommentSeverity("", c.ID, req.Severity)
+		}
+		s.emitEvent(s.session.Load(), hookEvent{Event: hookCommentCreated, Comment: &c})
 		w.WriteHeader(http.StatusCreated)
 		writeJSON(w, c)
 
@@ -788,6 +1175,10 @@ func (s *Server) handleReviewCommentByID(w http.ResponseWriter, r *http.Request)
 		s.handleReviewCommentReplyRoute(w, r, route.id, route.sub)
 	case "resolve":
 		s.handleReviewCommentResolve(w, r, route.id)
+	case "jira":
+		s.handleCommentJira(w, r, "", route.id)
+	case "linear":
+		s.handleCommentLinear(w, r, "", route.id)
 	case "comment":
 		s.handleReviewCommentUpdate(w, r, route.id)
 	}
@@ -812,12 +1203,17 @@ func (s *Server) handleReviewCommentResolve(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Comment not found", http.StatusNotFound)
 		return
 	}
+	s.emitEvent(s.session.Load(), hookEvent{Event: resolveEvent(req.Resolved), Comment: &c})
 	writeJSON(w, c)
 }
 
-// handleReviewCommentUpdate handles PUT and DELETE on /api/review-comment/{id}.
+// handleReviewCommentUpdate handles PUT, PATCH and DELETE on
+// /api/review-comment/{id}.
 func (s *Server) handleReviewCommentUpdate(w http.ResponseWriter, r *http.Request, id string) {
 	switch r.Method {
+	case http.MethodPatch:
+		s.handleCommentDisposition(w, r, "", id)
+
 	case http.MethodPut:
 		r.Body = http.MaxBytesReader(w, r.Body, 10<<20)
 		var req struct {
@@ -827,15 +1223,16 @@ func (s *Server) handleReviewCommentUpdate(w http.ResponseWriter, r *http.Reques
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
-		if req.Body == "" {
-			http.Error(w, "Comment body is required", http.StatusBadRequest)
+		body, ok := s.acceptCommentBody(w, req.Body, "Comment")
+		if !ok {
 			return
 		}
-		c, ok := s.session.Load().UpdateReviewComment(id, req.Body)
+		c, ok := s.session.Load().UpdateReviewComment(id, body)
 		if !ok {
 			http.Error(w, "Comment not found", http.StatusNotFound)
 			return
 		}
+		s.emitEvent(s.session.Load(), hookEvent{Event: hookCommentUpdated, Comment: &c})
 		writeJSON(w, c)
 
 	case http.MethodDelete:
@@ -855,8 +1252,60 @@ func (s *Server) handleRoundComplete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	s.session.Load().SignalRoundComplete()
-	writeJSON(w, map[string]string{"status": "ok"})
+	id, started := s.signalRoundComplete(s.session.Load())
+	status := "pending"
+	if started {
+		status = "ok"
+	}
+	writeJSON(w, map[string]string{"status": status, "round_id": id})
+}
+
+// handleRoundCompleteAck starts the pending round once the browser has no
+// comment edits in flight. An ack for any other round answers 409.
+// POST /api/round-complete/ack {"round_id": "..."}
+func (s *Server) handleRoundCompleteAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		RoundID string `json:"round_id"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20) // 1MB
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	sess := s.session.Load()
+	if !sess.ackRoundComplete(req.RoundID) {
+		http.Error(w, "No pending round with that ID", http.StatusConflict)
+		return
+	}
+	s.startRound(sess)
+	writeJSON(w, map[string]string{"status": "ok", "round_id": req.RoundID})
+}
+
+// signalRoundComplete asks for the next round (requestRoundComplete) and
+// starts it unless it has to wait for the browser's ack. It returns the round
+// ID and whether the round started.
+func (s *Server) signalRoundComplete(sess *Session) (string, bool) {
+	id, ready := sess.requestRoundComplete()
+	if ready {
+		s.startRound(sess)
+	}
+	return id, ready
+}
+
+// startRound starts the next round and tells the reviewer it's ready.
+// Comments still waiting for the debounced write are written first: the
+// next round carries comments forward from the review file.
+func (s *Server) startRound(sess *Session) {
+	sess.flushPendingWrite()
+	sess.SignalRoundComplete()
+	if s.notify {
+		sendDesktopNotification("Crit", roundReadyNotification)
+	}
+	s.emitEvent(sess, hookEvent{Event: hookRoundComplete})
 }
 
 func (s *Server) handleFinish(w http.ResponseWriter, r *http.Request) {
@@ -865,45 +1314,34 @@ func (s *Server) handleFinish(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sess := s.session.Load()
+	writeJSON(w, s.finish(s.session.Load()))
+}
+
+// finish ends the review round as the Finish button does: it writes the
+// review file, archives the round and sends the finish event that unblocks
+// the waiting crit client. It returns the /api/finish response.
+func (s *Server) finish(sess *Session) map[string]any {
 	sess.WriteFiles()
 
 	totalComments := sess.TotalCommentCount()
 	newComments := sess.NewCommentCount()
 	unresolvedComments := sess.UnresolvedCommentCount()
 	critJSON := sess.critJSONPath()
-	prompt := ""
-	if totalComments > 0 && unresolvedComments > 0 {
-		if sess.Mode == "plan" {
-			// Plan mode: concise feedback for the hook workflow.
-			// Claude revises the plan text directly — no need for crit comment or review file instructions.
-			prompt = s.buildPlanFeedback(critJSON)
-		} else {
-			prompt = fmt.Sprintf(
-				"Review comments are in %s — comments are grouped per file with start_line/end_line referencing the source. "+
-					"Each comment has a scope field: \"line\" for inline comments, \"file\" for file-level comments, or \"review\" for review-level comments. "+
-					"Review-level comments appear in the top-level review_comments array (not tied to any file). "+
-					"Read the file, address each unresolved comment in the relevant file and location. "+
-					"Before acting, check each comment's replies array — if you have already replied, the reviewer may be following up conversationally rather than requesting a new code change. "+
-					"For each comment, reply explaining what you did using `crit comment --reply-to <comment-id> --author <your-name> \"<explanation>\"`. "+
-					"When done run: `%s`",
-				critJSON, sess.ReinvokeCommand())
-		}
-	} else if totalComments > 0 && unresolvedComments == 0 {
-		prompt = "All comments are resolved — no changes needed, please proceed."
-	}
+	prompt := s.finishPrompt(sess, critJSON, totalComments, unresolvedComments)
 
 	approved := unresolvedComments == 0
-	if !approved {
+	if approved {
+		sess.setFinished(true)
+	} else {
 		sess.setWaitingForAgent(true)
 	}
-
-	writeJSON(w, map[string]any{
-		"status":      "finished",
-		"review_file": critJSON,
-		"prompt":      prompt,
-		"approved":    approved,
-	})
+	verdict := verdictApproved
+	if !approved {
+		verdict = verdictChangesRequested
+	}
+	sess.archiveRound(verdict)
+	sess.saveResumeState()
+	sess.syncReviewNote()
 
 	// Encode approved status into SSE event content as JSON so review-cycle
 	// clients can extract it without string matching on the prompt.
@@ -915,6 +1353,7 @@ func (s *Server) handleFinish(w http.ResponseWriter, r *http.Request) {
 		Type:    "finish",
 		Content: string(eventData),
 	})
+	s.emitEvent(sess, hookEvent{Event: hookFinish, Approved: &approved, Unresolved: &unresolvedComments, Prompt: prompt})
 
 	if s.status != nil {
 		round := sess.GetReviewRound()
@@ -923,7 +1362,66 @@ func (s *Server) handleFinish(w http.ResponseWriter, r *http.Request) {
 			s.status.WaitingForAgent()
 		}
 	}
+	return map[string]any{
+		"status":      "finished",
+		"review_file": critJSON,
+		"prompt":      prompt,
+		"approved":    approved,
+	}
+}
 
+// finishPrompt builds the feedback handed to the agent when a round finishes.
+// A configured review template replaces the built-in prompt for rounds with
+// unresolved comments; if it fails to render, the built-in prompt is used.
+// The compact review style inlines the comments instead of pointing at the
+// review file. The built-in prompts end with the checklist summary, when
+// there is a checklist; templates get it as .Checklist.
+func (s *Server) finishPrompt(sess *Session, critJSON string, totalComments, unresolvedComments int) string {
+	if totalComments > 0 && unresolvedComments > 0 && s.reviewTemplate != nil {
+		prompt, err := sess.renderReviewTemplate(s.reviewTemplate, critJSON)
+		if err == nil {
+			return prompt
+		}
+		slog.Warn("review template failed, using the default prompt", "err", err)
+	}
+	prompt := s.builtinFinishPrompt(sess, critJSON, totalComments, unresolvedComments)
+	if summary := checklistSummary(sess.GetChecklist()); prompt != "" && summary != "" {
+		prompt += "\n\n" + summary
+	}
+	return prompt
+}
+
+func (s *Server) builtinFinishPrompt(sess *Session, critJSON string, totalComments, unresolvedComments int) string {
+	if totalComments == 0 {
+		return ""
+	}
+	if unresolvedComments == 0 {
+		return "All comments are resolved — no changes needed, please proceed."
+	}
+	if s.reviewStyle == reviewStyleCompact {
+		return sess.compactReviewPrompt(critJSON)
+	}
+	if sess.Mode == "plan" {
+		// Plan mode: concise feedback for the hook workflow.
+		// Claude revises the plan text directly — no need for crit comment or review file instructions.
+		return s.buildPlanFeedback(critJSON)
+	}
+	notes := ""
+	if sess.hasPinnedOpenComments() {
+		notes = ". Comments with \"pinned\": true are the reviewer's read-first guidance: read them before the others."
+	}
+	if sess.readOnlySource() {
+		notes += ". Files named path@revision are read-only snapshots of a git revision: make the changes in the current file at path."
+	}
+	return fmt.Sprintf(
+		"Review comments are in %s — comments are grouped per file with start_line/end_line referencing the source. "+
+			"Each comment has a scope field: \"line\" for inline comments, \"file\" for file-level comments, or \"review\" for review-level comments. "+
+			"Review-level comments appear in the top-level review_comments array (not tied to any file). "+
+			"Read the file, address each unresolved comment in the relevant file and location. "+
+			"Before acting, check each comment's replies array — if you have already replied, the reviewer may be following up conversationally rather than requesting a new code change. "+
+			"For each comment, reply explaining what you did using `crit comment --reply-to <comment-id> --author <your-name> \"<explanation>\"`. "+
+			"When done run: `%s`%s",
+		critJSON, sess.ReinvokeCommand(), notes)
 }
 
 // buildPlanFeedback formats review feedback for plan mode.
@@ -945,14 +1443,12 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	browserClients := false
+	resp := map[string]any{"status": "ok", "browser_clients": false}
 	if sess := s.session.Load(); sess != nil {
-		browserClients = sess.HasBrowserClients()
+		resp["browser_clients"] = sess.HasBrowserClients()
+		resp["session"] = sess.LiveStatus()
 	}
-	writeJSON(w, map[string]any{
-		"status":          "ok",
-		"browser_clients": browserClients,
-	})
+	writeJSON(w, resp)
 }
 
 // handleReviewCycle is the unified endpoint for the daemon-client pattern.
@@ -975,7 +1471,7 @@ func (s *Server) handleReviewCycle(w http.ResponseWriter, r *http.Request) {
 
 	if !sess.IsAwaitingFirstReview() {
 		// Agent finished changes — signal round-complete so browser refreshes
-		sess.SignalRoundComplete()
+		s.signalRoundComplete(sess)
 	}
 
 	for {
@@ -1028,6 +1524,80 @@ func (s *Server) handleWaitForEvent(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// reviewEventType is the session event emitEvent sends with a hookEvent as
+// JSON content.
+const reviewEventType = "review-event"
+
+// How long GET /api/review/next waits without ?wait=, and at most.
+const (
+	defaultReviewWait = 5 * time.Minute
+	maxReviewWait     = time.Hour
+)
+
+// handleReviewNext is a long-poll for agents integrating over HTTP: it
+// blocks until the reviewer finishes a round or adds a comment and returns
+// that event in the webhook payload format (hookEvent). With nothing to
+// report within ?wait= (a duration like "300s", or seconds) it returns 204
+// and the agent polls again.
+// GET /api/review/next?wait=300s
+func (s *Server) handleReviewNext(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	wait, err := parseReviewWait(r.URL.Query().Get("wait"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sess := s.session.Load()
+	ch := sess.Subscribe()
+	defer sess.Unsubscribe(ch)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	for {
+		select {
+		case event := <-ch:
+			if event.Type != reviewEventType {
+				continue
+			}
+			var ev hookEvent
+			if json.Unmarshal([]byte(event.Content), &ev) != nil || (ev.Event != hookFinish && ev.Event != hookCommentCreated) {
+				continue
+			}
+			writeJSON(w, json.RawMessage(event.Content))
+			return
+		case <-timer.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// parseReviewWait parses the ?wait= of GET /api/review/next, capped at
+// maxReviewWait.
+func parseReviewWait(v string) (time.Duration, error) {
+	if v == "" {
+		return defaultReviewWait, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		secs, atoiErr := strconv.Atoi(v)
+		if atoiErr != nil {
+			return 0, fmt.Errorf("invalid wait %q: want a duration like 300s", v)
+		}
+		d = time.Duration(secs) * time.Second
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid wait %q: must be positive", v)
+	}
+	return min(d, maxReviewWait), nil
+}
+
 func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -1051,6 +1621,12 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 
 	ch := sess.Subscribe()
 	defer sess.Unsubscribe(ch)
+	// A tab opened while a round waits for its ack gets to acknowledge it.
+	if id := sess.pendingRoundID(); id != "" {
+		data, _ := json.Marshal(SSEEvent{Type: "round-pending", Content: id})
+		fmt.Fprintf(w, "event: round-pending\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
 
 	for {
 		select {
@@ -1323,6 +1899,35 @@ func (s *Server) handleAgentRequest(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// maxAgentStatusLen caps an agent progress message, in runes.
+const maxAgentStatusLen = 200
+
+// handleAgentStatus records a progress message from the agent ("rewriting
+// section 3…") and relays it to the browser and the terminal status line, so
+// the reviewer can tell a long round from a wedged agent. An empty message
+// clears it.
+// POST /api/agent/status {"message": "..."}
+func (s *Server) handleAgentStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 64<<10)
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Bad request: invalid JSON", http.StatusBadRequest)
+		return
+	}
+	msg := strings.Join(strings.Fields(body.Message), " ")
+	if runes := []rune(msg); len(runes) > maxAgentStatusLen {
+		msg = string(runes[:maxAgentStatusLen-1]) + "…"
+	}
+	s.session.Load().SetAgentStatus(msg)
+	writeJSON(w, map[string]string{"status": "ok", "message": msg})
+}
+
 // buildAgentPrompt constructs a prompt string from a comment for the agent.
 func buildAgentPrompt(c Comment, filePath string) string {
 	var b strings.Builder
@@ -1363,7 +1968,7 @@ func (s *Server) runAgentCmd(prompt string, commentID string, filePath string) {
 	if len(parts) == 0 {
 		return
 	}
-	log.Printf("agent-request %s: running %q", commentID, s.agentCmd)
+	slog.Info("agent-request: running", "comment", commentID, "cmd", s.agentCmd)
 
 	// Replace {prompt} placeholder with the actual prompt as a single argument.
 	hasPlaceholder := false
@@ -1387,18 +1992,19 @@ func (s *Server) runAgentCmd(prompt string, commentID string, filePath string) {
 
 	err := cmd.Run()
 	if err != nil {
-		log.Printf("agent-request %s: error: %v\nStderr: %s", commentID, err, stderr.String())
+		slog.Error("agent-request failed", "comment", commentID, "err", err, "stderr", stderr.String())
 		return
 	}
 
 	response := strings.TrimSpace(stdout.String())
 	if response == "" {
-		log.Printf("agent-request %s: completed (no output)", commentID)
+		slog.Info("agent-request: completed with no output", "comment", commentID)
 		return
 	}
 
 	author := agentName(s.agentCmd)
-	log.Printf("agent-request %s: completed, posting reply (%d bytes)\nResponse: %s\nStderr: %s", commentID, len(response), response, stderr.String())
+	slog.Info("agent-request: completed, posting reply", "comment", commentID, "bytes", len(response))
+	slog.Debug("agent-request output", "comment", commentID, "response", response, "stderr", stderr.String())
 	// Try original path first, then search all files (path may have changed during agent run)
 	_, ok := sess.AddReply(filePath, commentID, response, author)
 	if !ok {
@@ -1410,7 +2016,7 @@ func (s *Server) runAgentCmd(prompt string, commentID string, filePath string) {
 		}
 	}
 	if !ok {
-		log.Printf("agent-request %s: failed to add reply (comment not found in file %q)", commentID, filePath)
+		slog.Warn("agent-request: failed to add reply, comment not found", "comment", commentID, "path", filePath)
 	} else {
 		// Re-read content (and file list/diffs in git mode) so next fetch returns updated data
 		sess.RefreshFileContent()
@@ -1425,6 +2031,61 @@ func (s *Server) runAgentCmd(prompt string, commentID string, filePath string) {
 func writeJSON(w http.ResponseWriter, v any) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(v); err != nil {
-		log.Printf("writeJSON: encode error: %v", err)
+		slog.Error("writeJSON: encode failed", "err", err)
 	}
 }
+
+// writeJSONWithETag is writeJSON with an ETag over the encoded body, so a
+// refetch of unchanged comments gets a 304 instead of the whole list.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("writeJSON: encode failed", "err", err)
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+	if notModified(w, r, weakETag(computeFileHash(body)[:32])) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// fileETag returns the ETag of a /api/file response: everything in it is
+// derived from the file's hash, path, status and type plus the query, so
+// a match can be answered without rendering or chunking. Empty when the
+// file has no hash.
+func fileETag(snap map[string]any, rawQuery string) string {
+	hash, _ := snap["file_hash"].(string)
+	if hash == "" {
+		return ""
+	}
+	key := fmt.Sprintf("%s\x00%v\x00%v\x00%v\x00%s", hash, snap["path"], snap["status"], snap["file_type"], rawQuery)
+	return weakETag(computeFileHash([]byte(key))[:32])
+}
+
+// weakETag quotes tag as a weak validator. The tags are weak because
+// withCompression sends the same one for the gzip, deflate and identity
+// bodies, which a strong validator must tell apart.
+func weakETag(tag string) string {
+	return `W/"` + tag + `"`
+}
+
+// notModified sets etag on the response and writes a 304 when the request's
+// If-None-Match already has it, compared weakly. Cache-Control: no-cache makes the browser
+// revalidate every fetch rather than reuse a stale copy.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"encoding/json"
@@ -165,3 +165,40 @@ func TestRunAgentCmd_StdinFallback(t *testing.T) {
 		t.Errorf("reply author = %q, want 'cat'", replies[0].Author)
 	}
 }
+
+func TestHandleAgentStatus(t *testing.T) {
+	s, session := newTestServer(t)
+	ch := session.Subscribe()
+	defer session.Unsubscribe(ch)
+	session.setWaitingForAgent(true)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/agent/status", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+	if w := post(`{"message":"  rewriting\n section 3…  "}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := session.LiveStatus().AgentStatus; got != "rewriting section 3…" {
+		t.Errorf("agent status = %q", got)
+	}
+	if ev := <-ch; ev.Type != "agent-status" || ev.Content != "rewriting section 3…" {
+		t.Errorf("event = %+v", ev)
+	}
+
+	post(`{"message":"` + strings.Repeat("x", 300) + `"}`)
+	if got := []rune(session.LiveStatus().AgentStatus); len(got) != maxAgentStatusLen {
+		t.Errorf("long message kept %d runes, want %d", len(got), maxAgentStatusLen)
+	}
+	if w := post(`not json`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid JSON: got %d", w.Code)
+	}
+
+	// A new round starts without the last round's progress.
+	session.SignalRoundComplete()
+	if got := session.LiveStatus().AgentStatus; got != "" {
+		t.Errorf("status survived round-complete: %q", got)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"context"
@@ -103,6 +103,81 @@ func TestGetFile(t *testing.T) {
 	}
 }
 
+func TestGetFile_LineChunk(t *testing.T) {
+	s, session := newTestServer(t)
+	session.Files[0].Content = "l1\nl2\nl3\nl4\nl5"
+
+	get := func(query string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/api/file?path=test.md"+query, nil))
+		var resp map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp
+	}
+	tests := []struct {
+		query        string
+		content      string
+		start, count float64
+	}{
+		{"&start=2&count=2", "l2\nl3\n", 2, 2},
+		{"&start=4", "l4\nl5", 4, 2},
+		{"&count=1", "l1\n", 1, 1},
+		{"&start=9&count=3", "", 9, 0},
+	}
+	for _, tt := range tests {
+		code, resp := get(tt.query)
+		if code != 200 || resp["content"] != tt.content || resp["start"] != tt.start || resp["count"] != tt.count || resp["total_lines"] != float64(5) {
+			t.Errorf("%s: status %d, resp %v", tt.query, code, resp)
+		}
+	}
+	if _, resp := get(""); resp["content"] != "l1\nl2\nl3\nl4\nl5" || resp["total_lines"] != float64(5) || resp["start"] != nil {
+		t.Errorf("unchunked resp = %v", resp)
+	}
+	for _, q := range []string{"&start=0", "&count=-1", "&start=x"} {
+		if code, _ := get(q); code != 400 {
+			t.Errorf("%s: status %d, want 400", q, code)
+		}
+	}
+}
+
+func TestGetFile_LargeFileDefaultChunk(t *testing.T) {
+	s, session := newTestServer(t)
+	content := strings.Repeat("x\n", largeFileLines+10)
+	session.Files[0].Content = content
+
+	get := func(query string) map[string]any {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/api/file?path=test.md"+query, nil))
+		var resp map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+	// Markdown is never chunked by default.
+	if resp := get(""); resp["content"] != content || resp["start"] != nil {
+		t.Errorf("markdown: start = %v, len(content) = %d", resp["start"], len(resp["content"].(string)))
+	}
+
+	session.Files[0].FileType = "code"
+	resp := get("")
+	if resp["start"] != float64(1) || resp["count"] != float64(largeFileLines) || resp["total_lines"] != float64(largeFileLines+10) {
+		t.Errorf("code: start %v, count %v, total_lines %v", resp["start"], resp["count"], resp["total_lines"])
+	}
+	if resp := get("&start=5001"); resp["count"] != float64(10) {
+		t.Errorf("second chunk count = %v, want 10", resp["count"])
+	}
+	if resp := get("&full=1"); resp["content"] != content || resp["start"] != nil {
+		t.Errorf("full=1: start = %v", resp["start"])
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	for content, want := range map[string]int{"": 0, "a": 1, "a\n": 1, "a\nb": 2, "\n\n": 2} {
+		if got := countLines(content); got != want {
+			t.Errorf("countLines(%q) = %d, want %d", content, got, want)
+		}
+	}
+}
+
 func TestGetFile_NotFound(t *testing.T) {
 	s, _ := newTestServer(t)
 	req := httptest.NewRequest("GET", "/api/file?path=nonexistent.go", nil)
@@ -165,6 +240,7 @@ func TestPostFileComment_InvalidLineRange(t *testing.T) {
 	}{
 		{"zero start", `{"start_line":0,"end_line":1,"body":"x"}`},
 		{"end before start", `{"start_line":3,"end_line":1,"body":"x"}`},
+		{"past end of file", `{"start_line":3,"end_line":4,"body":"x"}`},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -178,6 +254,50 @@ func TestPostFileComment_InvalidLineRange(t *testing.T) {
 	}
 }
 
+func TestPostFileComment_PastEOFError(t *testing.T) {
+	s, session := newTestServer(t)
+	req := httptest.NewRequest("POST", "/api/file/comments?path=test.md", strings.NewReader(`{"start_line":2,"end_line":9,"body":"x"}`))
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	if w.Code != 400 {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("error body is not JSON: %s", w.Body.String())
+	}
+	if resp["start_line"] != float64(2) || resp["end_line"] != float64(9) || resp["line_count"] != float64(3) || resp["error"] == "" {
+		t.Errorf("error body = %v", resp)
+	}
+	if len(session.GetComments("test.md")) != 0 {
+		t.Error("out-of-range comment should not be added")
+	}
+}
+
+func TestPostFileComment_ColumnSpan(t *testing.T) {
+	s, _ := newTestServer(t)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/api/file/comments?path=test.md", strings.NewReader(body)))
+		return w
+	}
+	w := post(`{"start_line":1,"end_line":2,"start_col":5,"end_col":4,"body":"x"}`)
+	if w.Code != 201 {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	var c Comment
+	json.Unmarshal(w.Body.Bytes(), &c)
+	if c.StartCol != 5 || c.EndCol != 4 || c.Quote != "1\nline" {
+		t.Errorf("comment = %+v", c)
+	}
+	if w := post(`{"start_line":1,"end_line":1,"start_col":3,"body":"x"}`); w.Code != 400 {
+		t.Errorf("missing end_col: status %d", w.Code)
+	}
+	if w := post(`{"start_line":1,"end_line":1,"start_col":1,"end_col":2,"side":"old","body":"x"}`); w.Code != 400 {
+		t.Errorf("old side span: status %d", w.Code)
+	}
+}
+
 func TestPostFileComment_InvalidJSON(t *testing.T) {
 	s, _ := newTestServer(t)
 	req := httptest.NewRequest("POST", "/api/file/comments?path=test.md", strings.NewReader("not json"))
@@ -220,6 +340,48 @@ func TestGetFileComments(t *testing.T) {
 	}
 }
 
+func TestETagRevalidation(t *testing.T) {
+	s, session := newTestServer(t)
+	session.Files[0].FileHash = fileHash([]byte(session.Files[0].Content))
+	get := func(url, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", url, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, url := range []string{"/api/file?path=test.md", "/api/file/comments?path=test.md", "/api/comments"} {
+		first := get(url, "")
+		etag := first.Header().Get("ETag")
+		if first.Code != 200 || !strings.HasPrefix(etag, `W/"`) {
+			t.Fatalf("%s: status %d, ETag %q, want a weak one", url, first.Code, etag)
+		}
+		if w := get(url, `"other", `+etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("%s: matching If-None-Match got status %d", url, w.Code)
+		}
+		if w := get(url, strings.TrimPrefix(etag, "W/")); w.Code != http.StatusNotModified {
+			t.Errorf("%s: the strong form of the tag got status %d", url, w.Code)
+		}
+	}
+
+	fileTag := get("/api/file?path=test.md", "").Header().Get("ETag")
+	if tag := get("/api/file?path=test.md&start=2", "").Header().Get("ETag"); tag == fileTag {
+		t.Error("a chunked request should not share the full file's ETag")
+	}
+	commentsTag := get("/api/file/comments?path=test.md", "").Header().Get("ETag")
+	session.AddComment("test.md", 1, 1, "", "new", "", "")
+	if w := get("/api/file/comments?path=test.md", commentsTag); w.Code != 200 {
+		t.Errorf("comments changed but got status %d", w.Code)
+	}
+	session.Files[0].FileHash = fileHash([]byte("edited"))
+	if w := get("/api/file?path=test.md", fileTag); w.Code != 200 {
+		t.Errorf("file changed but got status %d", w.Code)
+	}
+}
+
 func TestAPIUpdateComment(t *testing.T) {
 	s, session := newTestServer(t)
 	c, _ := session.AddComment("test.md", 1, 1, "", "original", "", "")
@@ -349,6 +511,24 @@ func TestFinish_NoComments(t *testing.T) {
 	}
 }
 
+func TestFinish_ApprovedMarksSessionFinished(t *testing.T) {
+	s, session := newTestServer(t)
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/finish", nil))
+	if st := session.LiveStatus(); !st.Finished || st.Waiting {
+		t.Errorf("after an approved finish: %+v", st)
+	}
+	session.SignalRoundComplete()
+	if session.LiveStatus().Finished {
+		t.Error("a new round should clear finished")
+	}
+
+	session.AddComment("test.md", 1, 1, "", "fix", "", "")
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/finish", nil))
+	if st := session.LiveStatus(); st.Finished || !st.Waiting {
+		t.Errorf("after finishing with open comments: %+v", st)
+	}
+}
+
 func TestFinish_PromptIncludesFileArgs(t *testing.T) {
 	s, session := newTestServer(t)
 	session.CLIArgs = []string{"test.md"}
@@ -719,6 +899,9 @@ func TestPostShareURL(t *testing.T) {
 	if resp["hosted_url"] != "https://crit.md/r/abc123" {
 		t.Errorf("hosted_url = %v, want https://crit.md/r/abc123", resp["hosted_url"])
 	}
+	if resp["share_id"] != "abc123" {
+		t.Errorf("share_id = %v, want abc123", resp["share_id"])
+	}
 }
 
 func TestPostShareURL_MethodNotAllowed(t *testing.T) {
@@ -818,8 +1001,56 @@ func TestRoundComplete(t *testing.T) {
 	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
 		t.Fatal(err)
 	}
-	if resp["status"] != "ok" {
-		t.Errorf("status = %q, want ok", resp["status"])
+	if resp["status"] != "ok" || resp["round_id"] == "" {
+		t.Errorf("response = %v, want ok with a round_id (no browser to wait for)", resp)
+	}
+}
+
+func TestRoundComplete_WaitsForBrowserAck(t *testing.T) {
+	s, session := newTestServer(t)
+	session.BrowserConnect()
+	ch := session.Subscribe()
+	defer session.Unsubscribe(ch)
+	post := func(url, body string) (*httptest.ResponseRecorder, map[string]string) {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", url, strings.NewReader(body)))
+		var resp map[string]string
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w, resp
+	}
+
+	_, resp := post("/api/round-complete", "")
+	id := resp["round_id"]
+	if resp["status"] != "pending" || !strings.HasPrefix(id, "round_") {
+		t.Fatalf("round-complete with a browser open = %v", resp)
+	}
+	if ev := <-ch; ev.Type != "round-pending" || ev.Content != id {
+		t.Errorf("event = %+v", ev)
+	}
+	if got := session.LiveStatus().PendingRound; got != id {
+		t.Errorf("health pending_round = %q, want %q", got, id)
+	}
+	if _, again := post("/api/round-complete", ""); again["round_id"] != id {
+		t.Errorf("repeated round-complete got a new round: %v", again)
+	}
+	if w, _ := post("/api/round-complete/ack", `{"round_id":"round_stale"}`); w.Code != http.StatusConflict {
+		t.Errorf("stale ack: got %d", w.Code)
+	}
+
+	// The ack starts the round; comments the debounced write hadn't saved yet
+	// reach the review file first, since the round carries forward from it.
+	c, _ := session.AddComment("test.md", 1, 1, "", "Just typed this", "", "")
+	if w, _ := post("/api/round-complete/ack", `{"round_id":"`+id+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("ack: got %d: %s", w.Code, w.Body.String())
+	}
+	if got := session.LiveStatus().PendingRound; got != "" {
+		t.Errorf("pending_round after ack = %q", got)
+	}
+	if data, err := os.ReadFile(session.critJSONPath()); err != nil || !strings.Contains(string(data), c.ID) {
+		t.Errorf("comment not written before the round started: %v", err)
+	}
+	if w, _ := post("/api/round-complete/ack", `{"round_id":"`+id+`"}`); w.Code != http.StatusConflict {
+		t.Errorf("second ack: got %d", w.Code)
 	}
 }
 
@@ -1269,6 +1500,99 @@ func TestWaitForEvent_MethodNotAllowed(t *testing.T) {
 	}
 }
 
+// reviewNext starts GET /api/review/next in the background; wait for the
+// returned channel to get the response.
+func reviewNext(srv *Server, query string) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/review/next"+query, nil))
+		done <- w
+	}()
+	time.Sleep(50 * time.Millisecond)
+	return done
+}
+
+func TestReviewNextReturnsOnFinish(t *testing.T) {
+	srv, session := newTestServer(t)
+	session.AddComment(session.Files[0].Path, 1, 1, "", "test", "", "")
+
+	done := reviewNext(srv, "?wait=5s")
+	session.SetAgentStatus("working") // not a review event
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/finish", nil))
+
+	select {
+	case w := <-done:
+		var ev hookEvent
+		if err := json.NewDecoder(w.Body).Decode(&ev); err != nil || w.Code != 200 {
+			t.Fatalf("got %d, %v: %s", w.Code, err, w.Body.String())
+		}
+		if ev.Event != hookFinish || ev.Approved == nil || *ev.Approved || ev.Unresolved == nil || *ev.Unresolved != 1 || ev.ReviewFile == "" || ev.Prompt == "" {
+			t.Errorf("event = %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("long-poll did not return after finish")
+	}
+}
+
+func TestReviewNextReturnsOnNewComment(t *testing.T) {
+	srv, _ := newTestServer(t)
+	done := reviewNext(srv, "")
+	req := httptest.NewRequest(http.MethodPost, "/api/file/comments?path=test.md", strings.NewReader(`{"start_line":1,"end_line":1,"body":"Why?"}`))
+	req.Header.Set("Content-Type", "application/json")
+	srv.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case w := <-done:
+		var ev hookEvent
+		json.NewDecoder(w.Body).Decode(&ev)
+		if ev.Event != hookCommentCreated || ev.Path != "test.md" || ev.Comment == nil || ev.Comment.Body != "Why?" {
+			t.Errorf("event = %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("long-poll did not return after a new comment")
+	}
+}
+
+func TestReviewNextTimesOut(t *testing.T) {
+	srv, _ := newTestServer(t)
+	select {
+	case w := <-reviewNext(srv, "?wait=100ms"):
+		if w.Code != http.StatusNoContent {
+			t.Errorf("expected 204, got %d", w.Code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("long-poll ignored ?wait=")
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/review/next?wait=soon", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid wait: got %d", w.Code)
+	}
+}
+
+func TestParseReviewWait(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", defaultReviewWait},
+		{"300s", 300 * time.Second},
+		{"90", 90 * time.Second},
+		{"24h", maxReviewWait},
+	}
+	for _, tt := range tests {
+		if got, err := parseReviewWait(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseReviewWait(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"0", "-5s", "later"} {
+		if _, err := parseReviewWait(bad); err == nil {
+			t.Errorf("parseReviewWait(%q) should fail", bad)
+		}
+	}
+}
+
 // TestGetFile_NotInSession_NotOnDisk verifies that files not in session
 // AND not on disk still return 404.
 func TestGetFile_NotInSession_NotOnDisk(t *testing.T) {
@@ -1439,6 +1763,28 @@ func TestHealthEndpoint(t *testing.T) {
 	}
 }
 
+func TestHealthEndpoint_LiveStatus(t *testing.T) {
+	srv, session := newTestServer(t)
+	session.AddComment("test.md", 1, 1, "", "open one", "", "")
+	c, _ := session.AddComment("test.md", 2, 2, "", "done", "", "")
+	session.SetCommentResolved("test.md", c.ID, true)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/api/health", nil))
+	var resp struct {
+		Session *liveStatus `json:"session"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Session == nil {
+		t.Fatalf("no session in %s", w.Body.String())
+	}
+	if got := *resp.Session; got.Round != 1 || got.Open != 1 || got.Resolved != 1 || got.Waiting || got.LastActivity == "" {
+		t.Errorf("session = %+v", got)
+	}
+}
+
 func TestReviewCycleFirstRound(t *testing.T) {
 	srv, session := newTestServer(t)
 
@@ -1835,6 +2181,35 @@ func TestHandleConfig_AuthNotLoggedIn(t *testing.T) {
 	}
 }
 
+func TestHandleSettings(t *testing.T) {
+	s, _ := newTestServer(t)
+	get := func() uiSettings {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("GET", "/api/settings", nil))
+		var settings uiSettings
+		if err := json.Unmarshal(w.Body.Bytes(), &settings); err != nil {
+			t.Fatal(err)
+		}
+		return settings
+	}
+
+	if got := get(); got != (uiSettings{TabWidth: 8}) {
+		t.Errorf("defaults = %+v", got)
+	}
+	s.cfg = Config{TabWidth: 4, ShowTrailingWhitespace: true, Wrap: "off"}
+	if got := get(); got != (uiSettings{TabWidth: 4, ShowTrailingWhitespace: true, Wrap: "off"}) {
+		t.Errorf("configured = %+v", got)
+	}
+	s.cfg = Config{TabWidth: 99, Wrap: "sometimes", Timezone: "Mars/Olympus"}
+	if got := get(); got != (uiSettings{TabWidth: 8}) {
+		t.Errorf("invalid values should fall back to defaults, got %+v", got)
+	}
+	s.cfg = Config{Timezone: "Europe/Berlin"}
+	if got := get(); got.Timezone != "Europe/Berlin" {
+		t.Errorf("timezone = %q", got.Timezone)
+	}
+}
+
 func TestHandleConfig_NoIntegrationCheck(t *testing.T) {
 	s, _ := newTestServer(t)
 	s.cfg = Config{NoIntegrationCheck: true}
@@ -2235,6 +2610,37 @@ func TestFileCommentResolveAPI(t *testing.T) {
 	}
 }
 
+func TestFileCommentPinAPI(t *testing.T) {
+	srv, session := newTestServer(t)
+	c, _ := session.AddComment("test.md", 1, 1, "", "read first", "", "")
+
+	req := httptest.NewRequest("PUT", "/api/comment/"+c.ID+"/pin?path=test.md", strings.NewReader(`{"pinned": true}`))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("PUT pin: status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var pinned Comment
+	json.Unmarshal(w.Body.Bytes(), &pinned)
+	if !pinned.Pinned || !session.hasPinnedOpenComments() {
+		t.Error("expected comment to be pinned")
+	}
+
+	req = httptest.NewRequest("GET", "/api/comment/"+c.ID+"/pin?path=test.md", nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != 405 {
+		t.Errorf("GET pin: status = %d, want 405", w.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/comment/nonexistent/pin?path=test.md", strings.NewReader(`{"pinned": true}`))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != 404 {
+		t.Errorf("pin nonexistent: status = %d, want 404", w.Code)
+	}
+}
+
 func TestFileCommentReplyAPI(t *testing.T) {
 	srv, session := newTestServer(t)
 	c, _ := session.AddComment("test.md", 1, 1, "", "fix this", "", "")
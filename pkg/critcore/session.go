@@ -1,17 +1,23 @@
-package main
+package critcore
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 )
 
 // lazyFileThreshold is the maximum number of files to eagerly load
@@ -54,25 +60,45 @@ type Reply struct {
 
 // Comment represents a single inline review comment.
 type Comment struct {
-	ID             string  `json:"id"`
-	StartLine      int     `json:"start_line"`
-	EndLine        int     `json:"end_line"`
-	Side           string  `json:"side,omitempty"`
-	Body           string  `json:"body"`
-	Quote          string  `json:"quote,omitempty"`
-	QuoteOffset    *int    `json:"quote_offset,omitempty"`
-	Anchor         string  `json:"anchor,omitempty"`
-	Drifted        bool    `json:"drifted,omitempty"`
-	Author         string  `json:"author,omitempty"`
-	Scope          string  `json:"scope,omitempty"`
-	CreatedAt      string  `json:"created_at"`
-	UpdatedAt      string  `json:"updated_at"`
-	Resolved       bool    `json:"resolved,omitempty"`
-	Live           bool    `json:"live,omitempty"`
-	CarriedForward bool    `json:"carried_forward,omitempty"`
-	ReviewRound    int     `json:"review_round,omitempty"`
-	Replies        []Reply `json:"replies,omitempty"`
-	GitHubID       int64   `json:"github_id,omitempty"`
+	ID             string        `json:"id"`
+	StartLine      int           `json:"start_line"`
+	EndLine        int           `json:"end_line"`
+	Side           string        `json:"side,omitempty"`
+	Body           string        `json:"body"`
+	Quote          string        `json:"quote,omitempty"`
+	QuoteOffset    *int          `json:"quote_offset,omitempty"`
+	Anchor         string        `json:"anchor,omitempty"`
+	Drifted        bool          `json:"drifted,omitempty"`
+	Author         string        `json:"author,omitempty"`
+	Scope          string        `json:"scope,omitempty"`
+	CreatedAt      string        `json:"created_at"`
+	UpdatedAt      string        `json:"updated_at"`
+	Resolved       bool          `json:"resolved,omitempty"`
+	Pinned         bool          `json:"pinned,omitempty"`    // listed before all other comments in review output and the sidebar
+	Severity       string        `json:"severity,omitempty"`  // one of severityLevels; "" when unclassified
+	IssueKey       string        `json:"issue_key,omitempty"` // Jira or Linear issue created from the comment (jira.go, linear.go)
+	IssueURL       string        `json:"issue_url,omitempty"`
+	Tool           string        `json:"tool,omitempty"`        // static-analysis tool that reported it (crit import); "" for people and agents
+	Disposition    *disposition  `json:"disposition,omitempty"` // the agent's answer (disposition.go)
+	Live           bool          `json:"live,omitempty"`
+	CarriedForward bool          `json:"carried_forward,omitempty"`
+	ReviewRound    int           `json:"review_round,omitempty"`
+	Replies        []Reply       `json:"replies,omitempty"`
+	GitHubID       int64         `json:"github_id,omitempty"`
+	Cell           int           `json:"cell,omitempty"`            // notebooks: 1-based cell the comment starts in
+	CellLine       int           `json:"cell_line,omitempty"`       // notebooks: line within that cell's source
+	TableCells     []tableCell   `json:"table_cells,omitempty"`     // CSV/TSV: cells the comment's lines cover
+	TableCell      *tableCellRef `json:"table_cell,omitempty"`      // CSV/TSV: cell the comment was made on
+	DataPath       string        `json:"data_path,omitempty"`       // JSON/YAML: path of the value the comment starts on
+	PatchFile      string        `json:"patch_file,omitempty"`      // .diff/.patch: file the comment starts in
+	PatchSide      string        `json:"patch_side,omitempty"`      // .diff/.patch: "old" or "new" side of that file
+	PatchLine      int           `json:"patch_line,omitempty"`      // .diff/.patch: line on that side
+	Region         *imageRegion  `json:"region,omitempty"`          // images: area a file-level comment refers to
+	FrontmatterKey string        `json:"frontmatter_key,omitempty"` // markdown: frontmatter field the comment starts on
+	StartCol       int           `json:"start_col,omitempty"`       // 1-based column on StartLine where the commented span starts
+	EndCol         int           `json:"end_col,omitempty"`         // 1-based column on EndLine where it ends (inclusive)
+	Refs           []commentLink `json:"refs,omitempty"`            // comments this one's body or replies reference as #<id>
+	Backlinks      []commentLink `json:"backlinks,omitempty"`       // comments that reference this one
 }
 
 // SSEEvent is sent to the browser via server-sent events.
@@ -112,6 +138,47 @@ type FileEntry struct {
 	// Orphaned: file has comments in the review file but is no longer in the session's
 	// file list (e.g., added on branch then deleted). No content or diff available.
 	Orphaned bool `json:"-"`
+
+	// Revision is set for a snapshot of a git revision (revision.go): the
+	// revision as given. Its AbsPath is the snapshot, not the working file.
+	Revision string `json:"-"`
+
+	// CompareBase is set under crit compare (compare.go): the absolute path
+	// of the old file. DiffHunks and old-side comments refer to it.
+	CompareBase string `json:"-"`
+
+	// notebook: a .ipynb in files mode, shown as a rendering of its cells
+	// (notebook.go). Content is the rendering; FileHash still hashes the raw file.
+	notebook      bool
+	notebookCells []notebookCellSpan
+
+	// FileType "image" (files mode, image.go): Content stays empty and only
+	// the natural size is kept, for validating comment regions.
+	imageWidth, imageHeight int
+}
+
+// setContent updates Content (and the derived notebook/image data) from the
+// file's bytes. FileHash is left to the caller.
+func (fe *FileEntry) setContent(data []byte) {
+	if fe.FileType == "image" {
+		fe.Content = ""
+		fe.imageWidth, fe.imageHeight = imageSize(fe.Path, data)
+		return
+	}
+	fe.Content, fe.notebookCells = reviewContent(data, fe.notebook)
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// textContent is a file's bytes as review content: any UTF-8 BOM dropped and
+// CRLF line endings turned into LF, so lines and columns match what the
+// browser shows. FileHash is still computed from the original bytes.
+func textContent(data []byte) string {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if !bytes.Contains(data, []byte("\r\n")) {
+		return string(data)
+	}
+	return string(bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n")))
 }
 
 // ensureLoaded loads content and diff hunks for a lazy file on first access.
@@ -129,7 +196,7 @@ func (fe *FileEntry) ensureLoaded(repoRoot, baseRef string, vcs VCS) error {
 				fe.loadErr = fmt.Errorf("reading %s: %w", fe.Path, err)
 				return
 			}
-			fe.Content = string(data)
+			fe.Content = textContent(data)
 			fe.FileHash = fileHash(data)
 		}
 
@@ -153,7 +220,7 @@ func (fe *FileEntry) loadDiff(ctx context.Context, repoRoot, baseRef string, vcs
 	if vcs != nil {
 		hunks, err := vcs.FileDiffUnifiedCtx(ctx, fe.Path, baseRef, repoRoot)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: diff failed for %s: %v\n", fe.Path, err)
+			slog.Warn("diff failed", "path", fe.Path, "err", err)
 		} else {
 			fe.DiffHunks = hunks
 		}
@@ -161,7 +228,7 @@ func (fe *FileEntry) loadDiff(ctx context.Context, repoRoot, baseRef string, vcs
 	}
 	hunks, err := fileDiffUnifiedCtx(ctx, fe.Path, baseRef, repoRoot)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: git diff failed for %s: %v\n", fe.Path, err)
+		slog.Warn("git diff failed", "path", fe.Path, "err", err)
 	} else {
 		fe.DiffHunks = hunks
 	}
@@ -190,6 +257,10 @@ type Session struct {
 	// prevents mergeFileSnapshotIntoCritJSON from re-adding them from disk.
 	deletedCommentIDs map[string]map[string]struct{}
 
+	// carriedIDs maps the IDs of comments carried forward this round to their
+	// new IDs, for rewriteCarriedRefsLocked.
+	carriedIDs map[string]string
+
 	mu          sync.RWMutex
 	subscribers map[chan SSEEvent]struct{}
 	subMu       sync.Mutex
@@ -205,15 +276,81 @@ type Session struct {
 	sharedURL           string
 	deleteToken         string
 	shareScope          string
+	shareExpiresAt      string        // RFC 3339; "" for a share that doesn't expire
+	shares              []issuedShare // every share made, until revoked
 	status              *Status
 	roundComplete       chan struct{}
 	pendingEdits        int
 	lastRoundEdits      int
-	lastCritJSONMtime   time.Time // mtime after our last WriteFiles(); used to detect external changes
-	awaitingFirstReview bool      // true until first review-cycle completes
-	waitingForAgent     bool      // true between finish (with unresolved comments) and round-complete
-	browserClients      int32     // number of connected SSE browser clients (atomic)
-
+	lastCritJSONMtime   time.Time    // mtime after our last WriteFiles(); used to detect external changes
+	awaitingFirstReview bool         // true until first review-cycle completes
+	waitingForAgent     bool         // true between finish (with unresolved comments) and round-complete
+	finished            bool         // true between an approved finish and the next round (--keep-alive)
+	browserClients      int32        // number of connected SSE browser clients (atomic)
+	writeErrors         atomic.Int64 // failed review file writes, for /metrics
+	lastActivity        time.Time    // last comment change or round transition, for the live status line
+	agentStatus         string       // last progress message from POST /api/agent/status; cleared on finish and round-complete
+	pendingRound        string       // ID of a round-complete waiting for the browser's ack; "" when none
+
+	// pathMu guards ReviewFilePath and CLIArgs, which change at runtime when a
+	// reviewed file is renamed. Separate from mu because critJSONPath is
+	// called both with and without mu held.
+	pathMu sync.RWMutex
+	// onRename re-keys the daemon after CLIArgs change and returns the new
+	// review file path ("" to keep the current one). Set by runServe.
+	onRename func(args []string) string
+	// notesRepo is the repository root when the git-notes backend is enabled;
+	// the review file is mirrored into refs/notes/crit on HEAD when a round
+	// finishes or completes and at shutdown. notesSynced is the hash of what
+	// was last mirrored, under notesMu.
+	notesRepo   string
+	notesMu     sync.Mutex
+	notesSynced string
+	// journal records comment actions for /api/undo and /api/redo (undo.go).
+	journal undoJournal
+	// remoteDocs are the file arguments given as URLs (remote.go).
+	remoteDocs []remoteDocument
+	// compareBase is the old file as given to crit compare (compare.go).
+	compareBase string
+	// checklist holds the configured review gates and their checked state.
+	checklist []checklistItem
+
+	// linters run on the files on load and after each round (linters.go);
+	// lintMu serializes the runs.
+	linters    []linterConfig
+	proseCheck bool // built-in markdown checks (prose.go), run with the linters
+	lintMu     sync.Mutex
+
+	// exportFormats lists the exports ("json", "yaml", "inline") written
+	// next to the review file on every save.
+	exportFormats []string
+	// backups is how many backups of the review file WriteFiles keeps
+	// (backup.go); 0 makes none.
+	backups int
+	// displayLoc is the timezone config key's zone, which exports show
+	// times in; nil is the machine's zone.
+	displayLoc *time.Location
+	// archiveDir is where finished rounds are archived
+	// (<storage root>/history/<key>); empty disables archiving.
+	archiveDir string
+	// reviewFileName is the review file's name inside OutputDir, rendered
+	// from review_filename (empty = .crit.json).
+	reviewFileName string
+	// drafts are the browser's unsent comment drafts by form key.
+	drafts map[string]json.RawMessage
+	// walPath is the write-ahead log of comment changes (wal.go); empty
+	// disables it. walPending holds the records not yet appended to it,
+	// walLogged those appended since its base, walSeq numbers them. walMu
+	// serializes the log's I/O, which runs outside mu.
+	walPath    string
+	walPending []walRecord
+	walLogged  []walRecord
+	walSeq     uint64
+	walMu      sync.Mutex
+	// resumePath is where the state `crit resume` restores is saved
+	// (resume.go); empty disables saving it. resumeMu serializes the saves.
+	resumePath string
+	resumeMu   sync.Mutex
 }
 
 // isSessionFile checks whether an absolute path belongs to a file in this session.
@@ -230,6 +367,7 @@ func (s *Session) isSessionFile(absPath string) bool {
 
 // CritJSON is the on-disk format for review files.
 type CritJSON struct {
+	SchemaVersion  int                     `json:"schema_version"`
 	Branch         string                  `json:"branch"`
 	BaseRef        string                  `json:"base_ref"`
 	UpdatedAt      string                  `json:"updated_at"`
@@ -238,7 +376,10 @@ type CritJSON struct {
 	DeleteToken    string                  `json:"delete_token,omitempty"`
 	ShareScope     string                  `json:"share_scope,omitempty"`
 	LastShareHash  string                  `json:"last_share_hash,omitempty"`
+	ShareExpiresAt string                  `json:"share_expires_at,omitempty"`
+	Shares         []issuedShare           `json:"shares,omitempty"` // every share until revoked (share_expiry.go)
 	ReviewComments []Comment               `json:"review_comments,omitempty"`
+	Checklist      []checklistItem         `json:"checklist,omitempty"`
 	Files          map[string]CritJSONFile `json:"files"`
 }
 
@@ -275,7 +416,7 @@ func populateEagerFile(fe *FileEntry, fc FileChange, baseRef, root string, vcs V
 		if err != nil {
 			return false
 		}
-		fe.Content = string(data)
+		fe.Content = textContent(data)
 		fe.FileHash = fileHash(data)
 	}
 
@@ -296,7 +437,7 @@ func populateEagerFileDiff(fe *FileEntry, fc FileChange, baseRef, root string, v
 	if vcs != nil {
 		hunks, err := vcs.FileDiffUnified(fc.Path, baseRef, root)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: diff failed for %s: %v\n", fc.Path, err)
+			slog.Warn("diff failed", "path", fc.Path, "err", err)
 		} else {
 			fe.DiffHunks = hunks
 		}
@@ -304,7 +445,7 @@ func populateEagerFileDiff(fe *FileEntry, fc FileChange, baseRef, root string, v
 	}
 	hunks, err := fileDiffUnified(fc.Path, baseRef, root)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Warning: git diff failed for %s: %v\n", fc.Path, err)
+		slog.Warn("git diff failed", "path", fc.Path, "err", err)
 	} else {
 		fe.DiffHunks = hunks
 	}
@@ -412,6 +553,9 @@ func expandAndDedupPaths(paths []string, ignorePatterns []string) ([]string, err
 			}
 			expandedPaths = append(expandedPaths, dirFiles...)
 		} else {
+			if !isTextFile(absPath) {
+				return nil, notTextError(p)
+			}
 			expandedPaths = append(expandedPaths, absPath)
 		}
 	}
@@ -480,41 +624,57 @@ func NewSessionFromFiles(paths []string, ignorePatterns []string) (*Session, err
 	}
 
 	for _, absPath := range expandedPaths {
-		relPath := absPath
-		if root != "" {
-			if rel, err := filepath.Rel(root, absPath); err == nil && !strings.HasPrefix(rel, "..") {
-				relPath = rel
-			}
-		}
-
-		data, err := os.ReadFile(absPath)
+		fe, err := newFilesModeEntry(absPath, root, baseRef, vcs)
 		if err != nil {
-			return nil, fmt.Errorf("reading %s: %w", absPath, err)
+			return nil, err
 		}
+		s.Files = append(s.Files, fe)
+	}
 
-		fe := &FileEntry{
-			Path:     relPath,
-			AbsPath:  absPath,
-			Status:   "modified",
-			FileType: detectFileType(absPath),
-			Content:  string(data),
-			FileHash: fileHash(data),
-			Comments: []Comment{},
-		}
+	return s, nil
+}
 
-		if vcs != nil {
-			hunks, diffErr := vcs.FileDiffUnified(relPath, baseRef, root)
-			if diffErr != nil {
-				fmt.Fprintf(os.Stderr, "Warning: diff failed for %s: %v\n", relPath, diffErr)
-			} else {
-				fe.DiffHunks = hunks
-			}
+// newFilesModeEntry reads absPath into a file entry for a files-mode session
+// rooted at root, with its diff against baseRef when vcs is set.
+func newFilesModeEntry(absPath, root, baseRef string, vcs VCS) (*FileEntry, error) {
+	relPath := absPath
+	if root != "" {
+		if rel, err := filepath.Rel(root, absPath); err == nil && filepath.IsLocal(rel) {
+			relPath = rel
 		}
+	}
 
-		s.Files = append(s.Files, fe)
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", absPath, err)
 	}
 
-	return s, nil
+	fe := &FileEntry{
+		Path:     relPath,
+		AbsPath:  absPath,
+		Status:   "modified",
+		FileType: detectFileType(absPath),
+		FileHash: fileHash(data),
+		Comments: []Comment{},
+		notebook: isNotebookPath(absPath),
+	}
+	switch {
+	case fe.notebook:
+		fe.FileType = "markdown"
+	case isImagePath(absPath):
+		fe.FileType = "image"
+	}
+	fe.setContent(data)
+
+	if vcs != nil {
+		hunks, diffErr := vcs.FileDiffUnified(relPath, baseRef, root)
+		if diffErr != nil {
+			slog.Warn("diff failed", "path", relPath, "err", diffErr)
+		} else {
+			fe.DiffHunks = hunks
+		}
+	}
+	return fe, nil
 }
 
 // walkDirectory recursively walks a directory and returns all file paths,
@@ -548,7 +708,7 @@ func walkDirectory(dir string, ignorePatterns []string) ([]string, error) {
 
 		// Skip binary/non-reviewable files by extension
 		ext := strings.ToLower(filepath.Ext(name))
-		if isBinaryExtension(ext) {
+		if isBinaryExtension(ext) || !isTextFile(path) {
 			return nil
 		}
 
@@ -582,6 +742,49 @@ func isBinaryExtension(ext string) bool {
 	return false
 }
 
+// binarySniffLen is how much of a file isTextFile looks at (git uses the same).
+const binarySniffLen = 8000
+
+// isTextFile reports whether the file at path can be reviewed as text: the
+// start of it has no NUL bytes and is valid UTF-8. Images are reviewed as
+// pictures and always pass. Unreadable files pass too; reading them later
+// reports the real error.
+func isTextFile(path string) bool {
+	if isImagePath(path) {
+		return true
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	buf := make([]byte, binarySniffLen)
+	n, _ := io.ReadFull(f, buf)
+	return looksLikeText(buf[:n])
+}
+
+func looksLikeText(sample []byte) bool {
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return false
+	}
+	// Don't count a multi-byte character cut off by the sample's end.
+	for k := 1; k < utf8.UTFMax && k <= len(sample); k++ {
+		if utf8.RuneStart(sample[len(sample)-k]) {
+			if !utf8.FullRune(sample[len(sample)-k:]) {
+				sample = sample[:len(sample)-k]
+			}
+			break
+		}
+	}
+	return utf8.Valid(sample)
+}
+
+// notTextError is reported for a binary or non-UTF-8 file named on the
+// command line.
+func notTextError(arg string) error {
+	return fmt.Errorf("%s is not a text file (binary or not UTF-8); crit reviews text, markdown, notebooks and images", arg)
+}
+
 // detectFileType returns "markdown" for .md files, "code" for everything else.
 func detectFileType(path string) string {
 	ext := strings.ToLower(filepath.Ext(path))
@@ -620,6 +823,84 @@ func extractAnchor(content string, startLine, endLine int) string {
 	return strings.Join(lines[startLine-1:end], "\n")
 }
 
+// DataPathLines returns the lines of the value at dataPath in a JSON/YAML file.
+func (s *Session) DataPathLines(filePath, dataPath string) (int, int, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f := s.fileByPathLocked(filePath)
+	if f == nil || !isDataFile(f.Path) {
+		return 0, 0, false
+	}
+	return findDataPath(dataNodes(f.Path, f.Content), dataPath)
+}
+
+// TableCellLines returns the lines of the row holding ref in a CSV/TSV file,
+// or false when the row or its column doesn't exist.
+func (s *Session) TableCellLines(filePath string, ref tableCellRef) (int, int, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f := s.fileByPathLocked(filePath)
+	if f == nil || !isTablePath(f.Path) {
+		return 0, 0, false
+	}
+	t := parseTable(f.Path, f.Content)
+	if t == nil {
+		return 0, 0, false
+	}
+	if _, ok := t.cell(ref); !ok {
+		return 0, 0, false
+	}
+	return t.lines[ref.Row][0], t.lines[ref.Row][1], true
+}
+
+// lineRangeError reports a comment anchor outside the lines of the file (or,
+// for side "old", of its base version).
+type lineRangeError struct {
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+	LineCount int `json:"line_count"`
+}
+
+func (e *lineRangeError) Error() string {
+	return fmt.Sprintf("lines %d-%d are outside the file, which has %d lines", e.StartLine, e.EndLine, e.LineCount)
+}
+
+// checkLineRange returns a *lineRangeError when startLine..endLine doesn't lie
+// within the file's content, and errFileNotFound for an unknown path.
+// Old-side ranges are checked against the base version only when one exists.
+func (s *Session) checkLineRange(filePath, side string, startLine, endLine int) error {
+	s.mu.RLock()
+	f := s.fileByPathLocked(filePath)
+	repoRoot, baseRef, vcs := s.RepoRoot, s.BaseRef, s.VCS
+	s.mu.RUnlock()
+	if f == nil {
+		return errFileNotFound
+	}
+	if err := f.ensureLoaded(repoRoot, baseRef, vcs); err != nil {
+		return err
+	}
+
+	var content string
+	switch {
+	case side == "old" && f.CompareBase != "":
+		content = compareBaseContent(f)
+	case side == "old" && baseRef == "":
+		return nil
+	case side == "old" && vcs != nil:
+		content, _ = vcs.FileContentAtRef(filePath, baseRef, repoRoot)
+	case side == "old":
+		content = fileContentAtRef(filePath, baseRef, repoRoot)
+	default:
+		s.mu.RLock()
+		content = f.Content
+		s.mu.RUnlock()
+	}
+	if n := countLines(content); startLine < 1 || endLine < startLine || endLine > n {
+		return &lineRangeError{StartLine: startLine, EndLine: endLine, LineCount: n}
+	}
+	return nil
+}
+
 // AddComment adds a comment to a specific file.
 func (s *Session) AddComment(filePath string, startLine, endLine int, side, body, quote, author string) (Comment, bool) {
 	s.mu.Lock()
@@ -628,11 +909,68 @@ func (s *Session) AddComment(filePath string, startLine, endLine int, side, body
 	if f == nil {
 		return Comment{}, false
 	}
+	c := s.addCommentLocked(f, startLine, endLine, side, body, quote, author)
+	s.recordLocked(journalEntry{Op: journalCreate, Path: filePath, ID: c.ID})
+	return c, true
+}
+
+// AddSpanComment adds a comment on the characters from startCol on startLine
+// through endCol on endLine (1-based, inclusive, counted in characters). The
+// span's text becomes the quote. Spans are only supported on the new side.
+func (s *Session) AddSpanComment(filePath string, startLine, startCol, endLine, endCol int, body, author string) (Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.fileByPathLocked(filePath)
+	if f == nil {
+		return Comment{}, errFileNotFound
+	}
+	quote, err := columnSpan(f.Content, startLine, startCol, endLine, endCol)
+	if err != nil {
+		return Comment{}, err
+	}
+	c := s.addCommentLocked(f, startLine, endLine, "", body, quote, author)
+	c.StartCol, c.EndCol = startCol, endCol
+	f.Comments[len(f.Comments)-1] = c
+	s.walPutLocked(filePath, c)
+	s.recordLocked(journalEntry{Op: journalCreate, Path: filePath, ID: c.ID})
+	return c, nil
+}
+
+// columnSpan returns the text from startCol on startLine through endCol on
+// endLine, or an error when the columns fall outside those lines.
+func columnSpan(content string, startLine, startCol, endLine, endCol int) (string, error) {
+	lines := splitLines(content)
+	if startLine < 1 || endLine < startLine || endLine > len(lines) {
+		return "", fmt.Errorf("lines %d-%d are outside the file", startLine, endLine)
+	}
+	first, last := []rune(lines[startLine-1]), []rune(lines[endLine-1])
+	switch {
+	case startCol < 1 || endCol < 1:
+		return "", errors.New("start_col and end_col must both be set (1-based)")
+	case startCol > len(first):
+		return "", fmt.Errorf("start_col %d is past the end of line %d (%d characters)", startCol, startLine, len(first))
+	case endCol > len(last):
+		return "", fmt.Errorf("end_col %d is past the end of line %d (%d characters)", endCol, endLine, len(last))
+	case startLine == endLine && endCol < startCol:
+		return "", errors.New("end_col is before start_col")
+	}
+	if startLine == endLine {
+		return string(first[startCol-1 : endCol]), nil
+	}
+	parts := append([]string{string(first[startCol-1:])}, lines[startLine:endLine-1]...)
+	return strings.Join(append(parts, string(last[:endCol])), "\n"), nil
+}
+
+// addCommentLocked appends a line comment to f. Callers hold s.mu.
+func (s *Session) addCommentLocked(f *FileEntry, startLine, endLine int, side, body, quote, author string) Comment {
+	filePath := f.Path
 
 	// For old-side comments, line numbers reference the base version of the file,
 	// not the working tree. Extract anchor from the base ref content.
 	var anchor string
-	if side == "old" && s.BaseRef != "" {
+	if side == "old" && f.CompareBase != "" {
+		anchor = extractAnchor(compareBaseContent(f), startLine, endLine)
+	} else if side == "old" && s.BaseRef != "" {
 		var baseContent string
 		if s.VCS != nil {
 			baseContent, _ = s.VCS.FileContentAtRef(filePath, s.BaseRef, s.RepoRoot)
@@ -643,6 +981,10 @@ func (s *Session) AddComment(filePath string, startLine, endLine int, side, body
 	} else {
 		anchor = extractAnchor(f.Content, startLine, endLine)
 	}
+	var dataPath string
+	if side != "old" && isDataFile(f.Path) {
+		dataPath = dataPathAt(dataNodes(f.Path, f.Content), startLine)
+	}
 
 	now := time.Now().UTC().Format(time.RFC3339)
 	c := Comment{
@@ -653,6 +995,7 @@ func (s *Session) AddComment(filePath string, startLine, endLine int, side, body
 		Body:        body,
 		Quote:       quote,
 		Anchor:      anchor,
+		DataPath:    dataPath,
 		Author:      author,
 		Scope:       "line",
 		CreatedAt:   now,
@@ -660,8 +1003,44 @@ func (s *Session) AddComment(filePath string, startLine, endLine int, side, body
 		ReviewRound: s.ReviewRound,
 	}
 	f.Comments = append(f.Comments, c)
+	s.walPutLocked(f.Path, c)
 	s.scheduleWrite()
-	return c, true
+	return c
+}
+
+// errFileNotFound is returned by session methods that report why they failed.
+var errFileNotFound = errors.New("file not found")
+
+// AddRegionComment adds a file-level comment on a region of an image file.
+func (s *Session) AddRegionComment(filePath string, region imageRegion, body, author string) (Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.fileByPathLocked(filePath)
+	if f == nil {
+		return Comment{}, errFileNotFound
+	}
+	if f.FileType != "image" {
+		return Comment{}, fmt.Errorf("%s is not an image", filePath)
+	}
+	if err := validRegion(region, f.imageWidth, f.imageHeight); err != nil {
+		return Comment{}, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	c := Comment{
+		ID:          randomCommentID(),
+		Body:        body,
+		Author:      author,
+		Scope:       "file",
+		Region:      &region,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ReviewRound: s.ReviewRound,
+	}
+	f.Comments = append(f.Comments, c)
+	s.recordLocked(journalEntry{Op: journalCreate, Path: filePath, ID: c.ID})
+	s.walPutLocked(filePath, c)
+	s.scheduleWrite()
+	return c, nil
 }
 
 // AddFileComment adds a file-level comment (not tied to specific lines).
@@ -683,6 +1062,8 @@ func (s *Session) AddFileComment(filePath, body, author string) (Comment, bool)
 		ReviewRound: s.ReviewRound,
 	}
 	f.Comments = append(f.Comments, c)
+	s.recordLocked(journalEntry{Op: journalCreate, Path: filePath, ID: c.ID})
+	s.walPutLocked(filePath, c)
 	s.scheduleWrite()
 	return c, true
 }
@@ -702,6 +1083,8 @@ func (s *Session) AddReviewComment(body, author string) Comment {
 		ReviewRound: s.ReviewRound,
 	}
 	s.reviewComments = append(s.reviewComments, c)
+	s.recordLocked(journalEntry{Op: journalCreate, ID: c.ID})
+	s.walPutLocked("", c)
 	s.scheduleWrite()
 	return c
 }
@@ -712,6 +1095,7 @@ func (s *Session) GetReviewComments() []Comment {
 	defer s.mu.RUnlock()
 	comments := make([]Comment, len(s.reviewComments))
 	copy(comments, s.reviewComments)
+	s.commentLinksLocked().apply(comments)
 	return comments
 }
 
@@ -721,8 +1105,10 @@ func (s *Session) UpdateReviewComment(id, body string) (Comment, bool) {
 	defer s.mu.Unlock()
 	for i, c := range s.reviewComments {
 		if c.ID == id {
+			s.recordLocked(journalEntry{Op: journalEdit, ID: id, other: Comment{Body: c.Body}})
 			s.reviewComments[i].Body = body
 			s.reviewComments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			s.walPutLocked("", s.reviewComments[i])
 			s.scheduleWrite()
 			return s.reviewComments[i], true
 		}
@@ -736,7 +1122,9 @@ func (s *Session) DeleteReviewComment(id string) bool {
 	defer s.mu.Unlock()
 	for i, c := range s.reviewComments {
 		if c.ID == id {
+			s.recordLocked(journalEntry{Op: journalDelete, ID: id, other: c, index: i})
 			s.reviewComments = append(s.reviewComments[:i], s.reviewComments[i+1:]...)
+			s.walDeleteLocked("", id)
 			s.scheduleWrite()
 			return true
 		}
@@ -750,8 +1138,12 @@ func (s *Session) ResolveReviewComment(id string, resolved bool) (Comment, bool)
 	defer s.mu.Unlock()
 	for i, c := range s.reviewComments {
 		if c.ID == id {
+			if c.Resolved != resolved {
+				s.recordLocked(journalEntry{Op: journalResolve, ID: id, other: Comment{Resolved: c.Resolved}})
+			}
 			s.reviewComments[i].Resolved = resolved
 			s.reviewComments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			s.walPutLocked("", s.reviewComments[i])
 			s.scheduleWrite()
 			return s.reviewComments[i], true
 		}
@@ -775,6 +1167,7 @@ func (s *Session) AddReviewCommentReply(commentID, body, author string) (Reply,
 			s.reviewComments[i].Replies = append(s.reviewComments[i].Replies, r)
 			s.reviewComments[i].Resolved = false
 			s.reviewComments[i].UpdatedAt = now
+			s.walPutLocked("", s.reviewComments[i])
 			s.scheduleWrite()
 			return r, true
 		}
@@ -792,6 +1185,7 @@ func (s *Session) UpdateReviewCommentReply(commentID, replyID, body string) (Rep
 				if r.ID == replyID {
 					s.reviewComments[i].Replies[j].Body = body
 					s.reviewComments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+					s.walPutLocked("", s.reviewComments[i])
 					s.scheduleWrite()
 					return s.reviewComments[i].Replies[j], true
 				}
@@ -812,6 +1206,7 @@ func (s *Session) DeleteReviewCommentReply(commentID, replyID string) bool {
 				if r.ID == replyID {
 					s.reviewComments[i].Replies = append(s.reviewComments[i].Replies[:j], s.reviewComments[i].Replies[j+1:]...)
 					s.reviewComments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+					s.walPutLocked("", s.reviewComments[i])
 					s.scheduleWrite()
 					return true
 				}
@@ -832,8 +1227,10 @@ func (s *Session) UpdateComment(filePath, id, body string) (Comment, bool) {
 	}
 	for i, c := range f.Comments {
 		if c.ID == id {
+			s.recordLocked(journalEntry{Op: journalEdit, Path: filePath, ID: id, other: Comment{Body: c.Body}})
 			f.Comments[i].Body = body
 			f.Comments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			s.walPutLocked(filePath, f.Comments[i])
 			s.scheduleWrite()
 			return f.Comments[i], true
 		}
@@ -851,8 +1248,76 @@ func (s *Session) SetCommentResolved(filePath, id string, resolved bool) (Commen
 	}
 	for i, c := range f.Comments {
 		if c.ID == id {
+			if c.Resolved != resolved {
+				s.recordLocked(journalEntry{Op: journalResolve, Path: filePath, ID: id, other: Comment{Resolved: c.Resolved}})
+			}
 			f.Comments[i].Resolved = resolved
 			f.Comments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			s.walPutLocked(filePath, f.Comments[i])
+			s.scheduleWrite()
+			return f.Comments[i], true
+		}
+	}
+	return Comment{}, false
+}
+
+// SetCommentPinned pins or unpins a file comment.
+func (s *Session) SetCommentPinned(filePath, id string, pinned bool) (Comment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.fileByPathLocked(filePath)
+	if f == nil {
+		return Comment{}, false
+	}
+	for i, c := range f.Comments {
+		if c.ID == id {
+			f.Comments[i].Pinned = pinned
+			f.Comments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			s.walPutLocked(filePath, f.Comments[i])
+			s.scheduleWrite()
+			return f.Comments[i], true
+		}
+	}
+	return Comment{}, false
+}
+
+// SetCommentSeverity sets the severity of a file comment, or of a review
+// comment when filePath is "". Callers validate severity with checkSeverity.
+func (s *Session) SetCommentSeverity(filePath, id, severity string) (Comment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comments := s.reviewComments
+	if filePath != "" {
+		f := s.fileByPathLocked(filePath)
+		if f == nil {
+			return Comment{}, false
+		}
+		comments = f.Comments
+	}
+	for i, c := range comments {
+		if c.ID == id {
+			comments[i].Severity = severity
+			comments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			s.walPutLocked(filePath, comments[i])
+			s.scheduleWrite()
+			return comments[i], true
+		}
+	}
+	return Comment{}, false
+}
+
+// SetCommentTableCell anchors a file comment to a table cell.
+func (s *Session) SetCommentTableCell(filePath, id string, ref tableCellRef) (Comment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f := s.fileByPathLocked(filePath)
+	if f == nil {
+		return Comment{}, false
+	}
+	for i, c := range f.Comments {
+		if c.ID == id {
+			f.Comments[i].TableCell = &ref
+			s.walPutLocked(filePath, f.Comments[i])
 			s.scheduleWrite()
 			return f.Comments[i], true
 		}
@@ -860,6 +1325,32 @@ func (s *Session) SetCommentResolved(filePath, id string, resolved bool) (Commen
 	return Comment{}, false
 }
 
+// SetCommentIssue records the issue tracker key and URL of a file comment,
+// or of a review comment when filePath is "".
+func (s *Session) SetCommentIssue(filePath, id, key, issueURL string) (Comment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comments := s.reviewComments
+	if filePath != "" {
+		f := s.fileByPathLocked(filePath)
+		if f == nil {
+			return Comment{}, false
+		}
+		comments = f.Comments
+	}
+	for i, c := range comments {
+		if c.ID == id {
+			comments[i].IssueKey = key
+			comments[i].IssueURL = issueURL
+			comments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			s.walPutLocked(filePath, comments[i])
+			s.scheduleWrite()
+			return comments[i], true
+		}
+	}
+	return Comment{}, false
+}
+
 // SetCommentLive marks a comment as live (sent to an agent).
 func (s *Session) SetCommentLive(filePath, id string) bool {
 	s.mu.Lock()
@@ -871,6 +1362,7 @@ func (s *Session) SetCommentLive(filePath, id string) bool {
 	for i, c := range f.Comments {
 		if c.ID == id {
 			f.Comments[i].Live = true
+			s.walPutLocked(filePath, f.Comments[i])
 			s.scheduleWrite()
 			return true
 		}
@@ -888,8 +1380,10 @@ func (s *Session) DeleteComment(filePath, id string) bool {
 	}
 	for i, c := range f.Comments {
 		if c.ID == id {
+			s.recordLocked(journalEntry{Op: journalDelete, Path: filePath, ID: id, other: c, index: i})
 			f.Comments = append(f.Comments[:i], f.Comments[i+1:]...)
 			s.trackDeletedComment(filePath, id)
+			s.walDeleteLocked(filePath, id)
 			s.scheduleWrite()
 			return true
 		}
@@ -923,7 +1417,7 @@ func (s *Session) RefreshFileContent() {
 		}
 		newHash := fileHash(data)
 		if newHash != f.FileHash {
-			f.Content = string(data)
+			f.setContent(data)
 			f.FileHash = newHash
 		}
 	}
@@ -949,6 +1443,7 @@ func (s *Session) AddReply(filePath, commentID, body, author string) (Reply, boo
 			f.Comments[i].Replies = append(f.Comments[i].Replies, r)
 			f.Comments[i].Resolved = false
 			f.Comments[i].UpdatedAt = now
+			s.walPutLocked(filePath, f.Comments[i])
 			s.scheduleWrite()
 			return r, true
 		}
@@ -970,6 +1465,7 @@ func (s *Session) UpdateReply(filePath, commentID, replyID, body string) (Reply,
 				if r.ID == replyID {
 					f.Comments[i].Replies[j].Body = body
 					f.Comments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+					s.walPutLocked(filePath, f.Comments[i])
 					s.scheduleWrite()
 					return f.Comments[i].Replies[j], true
 				}
@@ -994,6 +1490,7 @@ func (s *Session) DeleteReply(filePath, commentID, replyID string) bool {
 				if r.ID == replyID {
 					f.Comments[i].Replies = append(f.Comments[i].Replies[:j], f.Comments[i].Replies[j+1:]...)
 					f.Comments[i].UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+					s.walPutLocked(filePath, f.Comments[i])
 					s.scheduleWrite()
 					return true
 				}
@@ -1020,6 +1517,7 @@ func (s *Session) GetComments(filePath string) []Comment {
 			copy(result[i].Replies, c.Replies)
 		}
 	}
+	s.commentLinksLocked().apply(result)
 	return result
 }
 
@@ -1117,6 +1615,20 @@ func (s *Session) UnresolvedCommentCount() int {
 	return total
 }
 
+// hasPinnedOpenComments reports whether any unresolved file comment is pinned.
+func (s *Session) hasPinnedOpenComments() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, f := range s.Files {
+		for _, c := range f.Comments {
+			if c.Pinned && !c.Resolved {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (s *Session) fileByPathLocked(path string) *FileEntry {
 	for _, f := range s.Files {
 		if f.Path == path {
@@ -1164,7 +1676,7 @@ func (s *Session) EnsureFileEntry(path string) bool {
 		AbsPath:  absPath,
 		Status:   status,
 		FileType: detectFileType(path),
-		Content:  string(data),
+		Content:  textContent(data),
 		FileHash: fileHash(data),
 		Comments: []Comment{},
 	}
@@ -1196,14 +1708,39 @@ func (s *Session) GetSharedURL() string {
 }
 
 // SetSharedURLAndToken atomically updates both the shared URL and delete token.
+// The expiry is reset; SetShareExpiry sets the new share's. A new share is
+// added to the list of shares; clearing (url "") means the current one was
+// revoked, which drops it from the list.
 func (s *Session) SetSharedURLAndToken(url, token string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if url == "" {
+		s.shares = withoutIssuedShare(s.shares, s.sharedURL)
+	} else {
+		s.shares = withIssuedShare(s.shares, url, token, "")
+	}
 	s.sharedURL = url
 	s.deleteToken = token
+	s.shareExpiresAt = ""
+	s.scheduleWrite()
+}
+
+// SetShareExpiry stores when the current share expires.
+func (s *Session) SetShareExpiry(at string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shareExpiresAt = at
+	s.shares = withIssuedShare(s.shares, s.sharedURL, s.deleteToken, at)
 	s.scheduleWrite()
 }
 
+// GetShareExpiry returns when the current share expires, or "".
+func (s *Session) GetShareExpiry() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.shareExpiresAt
+}
+
 // SetShareScope stores the scope hash for the current share.
 func (s *Session) SetShareScope(scope string) {
 	s.mu.Lock()
@@ -1270,7 +1807,7 @@ func (s *Session) LoadShareFilesFromDisk() []shareFile {
 		if err != nil {
 			continue // file may have been removed since session started
 		}
-		files = append(files, shareFile{Path: fi.path, Content: string(data), Status: fi.status})
+		files = append(files, shareFile{Path: fi.path, Content: textContent(data), Status: fi.status})
 	}
 	return files
 }
@@ -1329,6 +1866,28 @@ func (s *Session) setWaitingForAgent(v bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.waitingForAgent = v
+	s.agentStatus = ""
+	s.lastActivity = time.Now()
+}
+
+// SetAgentStatus records the agent's latest progress message and sends it to
+// the browser as an agent-status event.
+func (s *Session) SetAgentStatus(msg string) {
+	s.mu.Lock()
+	s.agentStatus = msg
+	s.lastActivity = time.Now()
+	s.mu.Unlock()
+	s.notify(SSEEvent{Type: "agent-status", Content: msg})
+}
+
+// setFinished marks the review as approved and finished. Without --keep-alive
+// the client stops the daemon right after; with it, the session stays up and
+// the next crit run starts a new round.
+func (s *Session) setFinished(v bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = v
+	s.lastActivity = time.Now()
 }
 
 // isWaitingForAgent returns true if the session is waiting for agent edits.
@@ -1338,6 +1897,61 @@ func (s *Session) isWaitingForAgent() bool {
 	return s.waitingForAgent
 }
 
+// requestRoundComplete asks for the next round and returns its ID. With a
+// browser open, the round is pending until the browser acknowledges it
+// (ackRoundComplete), so loading it can't discard a comment the reviewer is
+// still writing; the browser gets a round-pending event. Without one it can
+// start right away, which the second result reports. Asking again while a
+// round is pending returns the pending ID.
+func (s *Session) requestRoundComplete() (string, bool) {
+	s.mu.Lock()
+	if s.pendingRound != "" {
+		id := s.pendingRound
+		s.mu.Unlock()
+		return id, false
+	}
+	id := randomID("round_")
+	if !s.HasBrowserClients() {
+		s.mu.Unlock()
+		return id, true
+	}
+	s.pendingRound = id
+	s.lastActivity = time.Now()
+	s.mu.Unlock()
+	s.notify(SSEEvent{Type: "round-pending", Content: id})
+	return id, false
+}
+
+// ackRoundComplete clears the pending round if it is id, reporting whether
+// it was, so a stale or repeated ack can't start a second round.
+func (s *Session) ackRoundComplete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id == "" || id != s.pendingRound {
+		return false
+	}
+	s.pendingRound = ""
+	return true
+}
+
+// flushPendingWrite writes the review file now if a debounced write is
+// pending.
+func (s *Session) flushPendingWrite() {
+	s.mu.RLock()
+	pending := s.pendingWrite
+	s.mu.RUnlock()
+	if pending {
+		s.WriteFiles()
+	}
+}
+
+// pendingRoundID returns the ID of the round waiting for an ack, or "".
+func (s *Session) pendingRoundID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pendingRound
+}
+
 // SignalRoundComplete prepares the session for a new round by clearing current
 // comments and sending a signal to the watcher goroutine. The ReviewRound counter
 // is NOT incremented here — it is deferred to the watcher's handleRoundComplete*
@@ -1354,6 +1968,9 @@ func (s *Session) SignalRoundComplete() {
 	s.lastRoundEdits = s.pendingEdits
 	s.pendingEdits = 0
 	s.waitingForAgent = false
+	s.finished = false
+	s.agentStatus = ""
+	s.lastActivity = time.Now()
 	// Clear comments on all files.
 	// ReviewRound is incremented later by the watcher after carry-forward.
 	for _, f := range s.Files {
@@ -1386,7 +2003,7 @@ func (s *Session) ClearAllComments() {
 	// Reset all file state, drop the review file entry and orphaned phantom entries.
 	filtered := make([]*FileEntry, 0, len(s.Files))
 	for _, f := range s.Files {
-		if filepath.Base(f.Path) == ".crit.json" || f.Orphaned {
+		if name := filepath.Base(f.Path); name == defaultReviewFileName || name == s.reviewFileName || f.Orphaned {
 			continue
 		}
 		f.Comments = []Comment{}
@@ -1396,14 +2013,26 @@ func (s *Session) ClearAllComments() {
 	}
 	s.Files = filtered
 	s.reviewComments = nil
+	s.clearJournalLocked()
 	s.ReviewRound = 1
 	s.lastCritJSONMtime = time.Time{}
 	s.pendingWrite = false
 	s.waitingForAgent = false
+	s.finished = false
+	s.drafts = nil
 	critPath := s.critJSONPath()
+	resumePath := s.resumePath
 	s.mu.Unlock()
+	s.resetWAL()
+	// Back it up first so `crit restore` can undo the clear.
+	if _, err := backupReviewFile(critPath, nil, s.backups, true); err != nil {
+		slog.Warn("backing up review file", "path", critPath, "err", err)
+	}
 	// Delete the review file from disk (centralized or legacy path).
 	os.Remove(critPath) //nolint:errcheck
+	if resumePath != "" {
+		os.Remove(resumePath) //nolint:errcheck
+	}
 }
 
 // ChangeBaseBranch changes the diff base to the given branch, recomputes merge-base,
@@ -1488,7 +2117,7 @@ func (s *Session) ChangeBaseBranch(branch string) error { //nolint:gocyclo // in
 		}
 		if fc.Status != "deleted" {
 			if data, readErr := os.ReadFile(absPath); readErr == nil {
-				fe.Content = string(data)
+				fe.Content = textContent(data)
 				fe.FileHash = fileHash(data)
 			}
 		}
@@ -1512,7 +2141,11 @@ func (s *Session) ChangeBaseBranch(branch string) error { //nolint:gocyclo // in
 
 // scheduleWrite debounces writes to disk.
 func (s *Session) scheduleWrite() {
+	if len(s.walPending) > 0 {
+		go s.flushWAL()
+	}
 	s.pendingWrite = true
+	s.lastActivity = time.Now()
 	if s.writeTimer != nil {
 		s.writeTimer.Stop()
 	}
@@ -1536,8 +2169,25 @@ func (s *Session) scheduleWrite() {
 
 // critJSONPath returns the path to the review file.
 func (s *Session) critJSONPath() string {
+	s.pathMu.RLock()
+	defer s.pathMu.RUnlock()
+	return s.critJSONPathLocked()
+}
+
+// isReviewArtifact reports whether the working tree path is the session's
+// review file or one of its exports, which the edit watcher must not count
+// as agent edits.
+func (s *Session) isReviewArtifact(path string) bool {
+	return isReviewArtifact(path, s.reviewFileName)
+}
+
+// critJSONPathLocked is critJSONPath for callers already holding pathMu.
+func (s *Session) critJSONPathLocked() string {
 	if s.OutputDir != "" {
-		return filepath.Join(s.OutputDir, ".crit.json")
+		if s.reviewFileName != "" {
+			return filepath.Join(s.OutputDir, s.reviewFileName)
+		}
+		return filepath.Join(s.OutputDir, defaultReviewFileName)
 	}
 	if s.ReviewFilePath != "" {
 		return s.ReviewFilePath
@@ -1557,10 +2207,16 @@ type writeFilesSnapshot struct {
 	sharedURL      string
 	deleteToken    string
 	shareScope     string
+	shareExpiresAt string
+	shares         []issuedShare
 	reviewComments []Comment
+	checklist      []checklistItem
 	// Per-file data needed for the merge. We copy comments so the snapshot
 	// is independent of later in-memory mutations.
 	files []writeFileSnapshot
+	// walSeq is the last write-ahead log record the snapshot includes, for
+	// the checkpoint after the write (wal.go).
+	walSeq uint64
 }
 
 type writeFileSnapshot struct {
@@ -1608,6 +2264,7 @@ func (s *Session) clearAllCommentData() {
 	s.reviewComments = nil
 	s.deletedCommentIDs = nil
 	s.mu.Unlock()
+	s.resetWAL()
 	if anyComments {
 		s.notify(SSEEvent{Type: "comments-changed"})
 	}
@@ -1615,11 +2272,14 @@ func (s *Session) clearAllCommentData() {
 
 // buildCritJSON loads the existing review file from disk, applies the snapshot metadata,
 // and merges per-file comments.
-func buildCritJSON(snap writeFilesSnapshot) CritJSON {
+// Returns errNewerCritSchema if the file on disk must not be overwritten.
+func buildCritJSON(snap writeFilesSnapshot) (CritJSON, error) {
 	cj := CritJSON{Files: make(map[string]CritJSONFile)}
 	if data, err := os.ReadFile(snap.critPath); err == nil {
-		if unmarshalErr := json.Unmarshal(data, &cj); unmarshalErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: corrupt review file, starting fresh: %v\n", unmarshalErr)
+		if unmarshalErr := unmarshalCritJSON(data, &cj); errors.Is(unmarshalErr, errNewerCritSchema) {
+			return cj, unmarshalErr
+		} else if unmarshalErr != nil {
+			slog.Warn("corrupt review file, starting fresh", "path", snap.critPath, "err", unmarshalErr)
 		}
 		if cj.Files == nil {
 			cj.Files = make(map[string]CritJSONFile)
@@ -1632,12 +2292,15 @@ func buildCritJSON(snap writeFilesSnapshot) CritJSON {
 	cj.ShareURL = snap.sharedURL
 	cj.DeleteToken = snap.deleteToken
 	cj.ShareScope = snap.shareScope
+	cj.ShareExpiresAt = snap.shareExpiresAt
+	cj.Shares = snap.shares
 	cj.ReviewComments = snap.reviewComments
+	cj.Checklist = snap.checklist
 
 	for _, fs := range snap.files {
 		mergeFileSnapshotIntoCritJSON(&cj, fs)
 	}
-	return cj
+	return cj, nil
 }
 
 // mergeFileSnapshotIntoCritJSON merges a single file's comments from the snapshot
@@ -1676,9 +2339,12 @@ func mergeFileSnapshotIntoCritJSON(cj *CritJSON, fs writeFileSnapshot) {
 	}
 }
 
+// critJSONIsEmpty reports whether cj holds nothing worth keeping. An
+// untouched checklist comes from config, so it doesn't count.
 func critJSONIsEmpty(cj CritJSON) bool {
 	return len(cj.Files) == 0 && len(cj.ReviewComments) == 0 &&
-		cj.ShareURL == "" && cj.DeleteToken == "" && cj.ShareScope == ""
+		cj.ShareURL == "" && cj.DeleteToken == "" && cj.ShareScope == "" && len(cj.Shares) == 0 &&
+		!slices.ContainsFunc(cj.Checklist, func(it checklistItem) bool { return it.Checked })
 }
 
 // WriteFiles writes the review file to disk.
@@ -1695,31 +2361,49 @@ func (s *Session) WriteFiles() {
 	critPath := s.critJSONPath()
 
 	if s.handleExternalDeletion(critPath) {
+		slog.Debug("review file deleted externally, not rewriting it", "path", critPath)
 		return
 	}
 
 	snap := s.snapshotForWrite(critPath)
-	cj := buildCritJSON(snap)
+	cj, err := buildCritJSON(snap)
+	if err != nil {
+		slog.Error("building review file failed", "path", critPath, "err", err)
+		s.writeErrors.Add(1)
+		return
+	}
 
 	if critJSONIsEmpty(cj) {
+		s.backupBeforeWrite(snap.critPath, nil)
 		os.Remove(snap.critPath)
 		s.mu.Lock()
 		s.lastCritJSONMtime = time.Time{}
 		s.pendingWrite = false
 		s.deletedCommentIDs = nil
 		s.mu.Unlock()
+		s.checkpointWAL(snap.critPath, cj, nil, snap.walSeq)
+		removeReviewExports(snap.critPath)
+		slog.Debug("no comments left, removed review file", "path", snap.critPath)
 		return
 	}
 
 	data, err := json.MarshalIndent(cj, "", "  ")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error marshaling review file: %v\n", err)
+		slog.Error("marshaling review file failed", "path", snap.critPath, "err", err)
+		s.writeErrors.Add(1)
 		return
 	}
+	s.backupBeforeWrite(snap.critPath, &cj)
 	if err := atomicWriteFile(snap.critPath, data, 0644); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing review file: %v\n", err)
+		slog.Error("writing review file failed", "path", snap.critPath, "err", err)
+		s.writeErrors.Add(1)
 		return
 	}
+	slog.Debug("wrote review file", "path", snap.critPath, "bytes", len(data))
+	s.checkpointWAL(snap.critPath, cj, data, snap.walSeq)
+	s.writeRegionCrops(snap.critPath, cj)
+	writeReviewExports(snap.critPath, cj, s.exportFormats, s.displayLoc)
+	s.writeAnnotatedExport(snap.critPath, cj)
 	if info, err := os.Stat(snap.critPath); err == nil {
 		s.mu.Lock()
 		s.lastCritJSONMtime = info.ModTime()
@@ -1736,8 +2420,10 @@ func (s *Session) snapshotForWrite(critPath string) writeFilesSnapshot {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	links := s.commentLinksLocked()
 	rc := make([]Comment, len(s.reviewComments))
 	copy(rc, s.reviewComments)
+	links.apply(rc)
 	snap := writeFilesSnapshot{
 		critPath:       critPath,
 		lastMtime:      s.lastCritJSONMtime,
@@ -1747,12 +2433,18 @@ func (s *Session) snapshotForWrite(critPath string) writeFilesSnapshot {
 		sharedURL:      s.sharedURL,
 		deleteToken:    s.deleteToken,
 		shareScope:     s.shareScope,
+		shareExpiresAt: s.shareExpiresAt,
+		shares:         slices.Clone(s.shares),
 		reviewComments: rc,
+		checklist:      append([]checklistItem(nil), s.checklist...),
 		files:          make([]writeFileSnapshot, len(s.Files)),
 	}
+	snap.walSeq = s.walSeq
 	for i, f := range s.Files {
 		comments := make([]Comment, len(f.Comments))
 		copy(comments, f.Comments)
+		anchorFormatComments(f, comments)
+		links.apply(comments)
 		var deleted map[string]struct{}
 		if ids := s.deletedCommentIDs[f.Path]; len(ids) > 0 {
 			deleted = make(map[string]struct{}, len(ids))
@@ -1771,6 +2463,32 @@ func (s *Session) snapshotForWrite(critPath string) writeFilesSnapshot {
 	return snap
 }
 
+// anchorFormatComments fills the format-specific locations (notebook cell,
+// table cells, patched file line, frontmatter key) on a copy of f's comments.
+func anchorFormatComments(f *FileEntry, comments []Comment) {
+	if f.notebook {
+		anchorNotebookComments(comments, f.notebookCells)
+	}
+	if f.Content == "" || len(comments) == 0 {
+		return
+	}
+	if isTablePath(f.Path) {
+		if t := parseTable(f.Path, f.Content); t != nil {
+			anchorTableComments(comments, t)
+		}
+	}
+	if isPatchPath(f.Path) {
+		if files := parsePatch(f.Content); files != nil {
+			anchorPatchComments(comments, files)
+		}
+	}
+	if f.FileType == "markdown" && !f.notebook {
+		if fm := parseFrontmatter(f.Content); fm != nil {
+			anchorFrontmatterComments(comments, fm)
+		}
+	}
+}
+
 // handleCritJSONDeleted clears all in-memory comment state when the review file
 // has been deleted. Returns true unconditionally to signal the deletion.
 func (s *Session) handleCritJSONDeleted() bool {
@@ -1823,6 +2541,18 @@ func (s *Session) mergeCommentRepliesAndState(comments []Comment, dc Comment) bo
 			comments[i].Resolved = dc.Resolved
 			changed = true
 		}
+		if dc.Pinned != mc.Pinned {
+			comments[i].Pinned = dc.Pinned
+			changed = true
+		}
+		if dc.Severity != mc.Severity {
+			comments[i].Severity = dc.Severity
+			changed = true
+		}
+		if dc.Body != mc.Body {
+			comments[i].Body = dc.Body
+			changed = true
+		}
 		break
 	}
 	return changed
@@ -1878,6 +2608,14 @@ func (s *Session) mergeReviewCommentRepliesAndState(dc Comment) bool {
 			s.reviewComments[i].Resolved = dc.Resolved
 			changed = true
 		}
+		if dc.Severity != mc.Severity {
+			s.reviewComments[i].Severity = dc.Severity
+			changed = true
+		}
+		if dc.Body != mc.Body {
+			s.reviewComments[i].Body = dc.Body
+			changed = true
+		}
 		memRIDs := make(map[string]struct{}, len(mc.Replies))
 		for _, r := range mc.Replies {
 			memRIDs[r.ID] = struct{}{}
@@ -1943,7 +2681,7 @@ func (s *Session) mergeExternalCritJSON() bool {
 		return false
 	}
 	var cj CritJSON
-	if err := json.Unmarshal(data, &cj); err != nil {
+	if err := unmarshalCritJSON(data, &cj); err != nil {
 		return false
 	}
 
@@ -1967,6 +2705,9 @@ func (s *Session) mergeExternalCritJSON() bool {
 	}
 
 	changed = s.mergeReviewCommentsFromDisk(cj.ReviewComments) || changed
+	changed = s.mergeChecklistFromDisk(cj.Checklist) || changed
+	// Only share commands change the list, and they do so on disk.
+	s.shares = cj.Shares
 	s.mu.Unlock()
 
 	if changed {
@@ -1976,18 +2717,9 @@ func (s *Session) mergeExternalCritJSON() bool {
 	return changed
 }
 
-// loadCritJSON loads comments and share state from an existing review file.
-func (s *Session) loadCritJSON() {
-	data, err := os.ReadFile(s.critJSONPath())
-	if err != nil {
-		return
-	}
-	var cj CritJSON
-	if err := json.Unmarshal(data, &cj); err != nil {
-		return
-	}
-
-	// Only restore share state if the file set matches what was shared.
+// restoreShareState takes the share state from cj, if the file set matches
+// what was shared.
+func (s *Session) restoreShareState(cj CritJSON) {
 	if cj.ShareScope != "" {
 		paths := make([]string, 0, len(s.Files))
 		for _, f := range s.Files {
@@ -1997,12 +2729,33 @@ func (s *Session) loadCritJSON() {
 			s.sharedURL = cj.ShareURL
 			s.deleteToken = cj.DeleteToken
 			s.shareScope = cj.ShareScope
+			s.shareExpiresAt = cj.ShareExpiresAt
 		}
 	} else if cj.ShareURL != "" {
 		// No scope recorded — load unconditionally.
 		s.sharedURL = cj.ShareURL
 		s.deleteToken = cj.DeleteToken
+		s.shareExpiresAt = cj.ShareExpiresAt
 	}
+}
+
+// loadCritJSON loads comments and share state from an existing review file.
+func (s *Session) loadCritJSON() {
+	data, err := os.ReadFile(s.critJSONPath())
+	if err != nil {
+		return
+	}
+	var cj CritJSON
+	if err := unmarshalCritJSON(data, &cj); err != nil {
+		return
+	}
+
+	// An expired share is dropped; the next write clears it from the file.
+	// The list of shares keeps it until it is revoked.
+	if !shareExpired(cj.ShareExpiresAt, time.Now()) {
+		s.restoreShareState(cj)
+	}
+	s.shares = cj.Shares
 
 	// Restore review round so the session continues from where it left off.
 	if cj.ReviewRound > s.ReviewRound {
@@ -2026,6 +2779,7 @@ func (s *Session) loadCritJSON() {
 
 	// Restore review-level comments.
 	s.reviewComments = cj.ReviewComments
+	s.checklist = cj.Checklist
 
 	// Record the mtime so the first ticker tick doesn't re-process our own file.
 	if info, err := os.Stat(s.critJSONPath()); err == nil {
@@ -2043,7 +2797,7 @@ func (s *Session) restoreOrphanedComments() {
 		return
 	}
 	var cj CritJSON
-	if err := json.Unmarshal(data, &cj); err != nil {
+	if err := unmarshalCritJSON(data, &cj); err != nil {
 		return
 	}
 
@@ -2130,6 +2884,8 @@ func (s *Session) HasBrowserClients() bool {
 // ReinvokeCommand returns the crit command the agent should run to trigger the next round.
 // For file-mode sessions it includes the original file arguments; for git-mode it's bare "crit".
 func (s *Session) ReinvokeCommand() string {
+	s.pathMu.RLock()
+	defer s.pathMu.RUnlock()
 	if len(s.CLIArgs) == 0 {
 		return "crit"
 	}
@@ -2161,13 +2917,21 @@ func (s *Session) GetFileSnapshot(path string) (map[string]any, bool) {
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return map[string]any{
+	snap := map[string]any{
 		"path":      f.Path,
 		"status":    f.Status,
 		"file_type": f.FileType,
+		"language":  detectLanguage(f.Path, f.Content),
 		"content":   f.Content,
 		"file_hash": f.FileHash,
-	}, true
+	}
+	addTableSnapshot(snap, f.Path, f.Content)
+	addPatchSnapshot(snap, f.Path, f.Content)
+	addFrontmatterSnapshot(snap, f.FileType, f.Content)
+	if f.FileType == "image" {
+		snap["image"] = map[string]int{"width": f.imageWidth, "height": f.imageHeight}
+	}
+	return snap, true
 }
 
 // GetFileSnapshotFromDisk reads a file directly from the repo root.
@@ -2194,13 +2958,19 @@ func (s *Session) GetFileSnapshotFromDisk(path string) (map[string]any, bool) {
 	if err != nil {
 		return nil, false
 	}
-	return map[string]any{
+	content := textContent(data)
+	snap := map[string]any{
 		"path":      path,
 		"status":    "modified",
 		"file_type": detectFileType(path),
-		"content":   string(data),
+		"language":  detectLanguage(path, content),
+		"content":   content,
 		"file_hash": fileHash(data),
-	}, true
+	}
+	addTableSnapshot(snap, path, content)
+	addPatchSnapshot(snap, path, content)
+	addFrontmatterSnapshot(snap, detectFileType(path), content)
+	return snap, true
 }
 
 // GetFileDiffSnapshot returns diff data for the /api/file/diff endpoint.
@@ -2222,6 +2992,11 @@ func (s *Session) GetFileDiffSnapshot(path string) (map[string]any, bool) {
 	}
 
 	s.mu.RLock()
+	if f.CompareBase != "" {
+		hunks := compareHunks(f)
+		s.mu.RUnlock()
+		return map[string]any{"hunks": hunks, "previous_content": compareBaseContent(f)}, true
+	}
 	if f.FileType == "code" || s.Mode == "git" {
 		hunks := f.DiffHunks
 		s.mu.RUnlock()
@@ -2259,6 +3034,8 @@ type SessionInfo struct {
 	Files           []SessionFileInfo `json:"files"`
 	ReviewComments  []Comment         `json:"review_comments"`
 	Cwd             string            `json:"cwd,omitempty"`
+	ReadOnlySource  bool              `json:"read_only_source,omitempty"` // some files are git revision snapshots
+	CompareBase     string            `json:"compare_base,omitempty"`     // crit compare: the old file
 }
 
 // SessionFileInfo is a summary of a file for the session API response.
@@ -2271,6 +3048,7 @@ type SessionFileInfo struct {
 	Deletions    int    `json:"deletions"`
 	Lazy         bool   `json:"lazy,omitempty"`
 	Orphaned     bool   `json:"orphaned,omitempty"`
+	Revision     string `json:"revision,omitempty"`
 }
 
 // GetSessionInfo returns a snapshot of session metadata.
@@ -2297,6 +3075,8 @@ func (s *Session) GetSessionInfo() SessionInfo {
 		ReviewRound:    s.ReviewRound,
 		ReviewComments: reviewComments,
 		Cwd:            s.RepoRoot,
+		ReadOnlySource: s.readOnlySourceLocked(),
+		CompareBase:    s.compareBase,
 	}
 
 	info.AvailableScopes = cachedAvailableScopes(info.BaseRef, vcs)
@@ -2309,6 +3089,7 @@ func (s *Session) GetSessionInfo() SessionInfo {
 			CommentCount: len(f.Comments),
 			Lazy:         f.Lazy,
 			Orphaned:     f.Orphaned,
+			Revision:     f.Revision,
 		}
 		if f.Lazy {
 			// Use pre-computed stats from git diff --numstat
@@ -2466,7 +3247,7 @@ func scopedHunks(fc FileChange, scope, commit, baseRef, repoRoot string, vcs VCS
 	if fc.Status == "added" || fc.Status == "untracked" {
 		absPath := filepath.Join(repoRoot, fc.Path)
 		if data, err := os.ReadFile(absPath); err == nil {
-			return FileDiffUnifiedNewFile(string(data))
+			return FileDiffUnifiedNewFile(textContent(data))
 		}
 		return nil
 	}
@@ -2579,7 +3360,7 @@ func (s *Session) loadScopedFileState(path, scope string) (status, content, base
 	}
 	absPath := filepath.Join(repoRoot, path)
 	if data, err := os.ReadFile(absPath); err == nil {
-		content = string(data)
+		content = textContent(data)
 		if vcs != nil {
 			if changes, err := vcs.ChangedFilesScoped(scope, baseRef); err == nil {
 				for _, fc := range changes {
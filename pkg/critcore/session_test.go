@@ -1,7 +1,8 @@
-package main
+package critcore
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -1488,6 +1489,34 @@ func TestSession_MergeExternalCritJSON_NewComment(t *testing.T) {
 	}
 }
 
+// crit triage rewords comments in the review file while the daemon runs.
+func TestSession_MergeExternalCritJSON_EditedBody(t *testing.T) {
+	dir := t.TempDir()
+	s := &Session{
+		RepoRoot:       dir,
+		ReviewRound:    1,
+		Files:          []*FileEntry{{Path: "main.go", Status: "modified", Comments: []Comment{{ID: "c1", StartLine: 5, EndLine: 5, Body: "old"}}}},
+		reviewComments: []Comment{{ID: "r1", Body: "old review"}},
+		subscribers:    make(map[chan SSEEvent]struct{}),
+	}
+	cj := CritJSON{
+		ReviewComments: []Comment{{ID: "r1", Body: "new review"}},
+		Files:          map[string]CritJSONFile{"main.go": {Comments: []Comment{{ID: "c1", StartLine: 5, EndLine: 5, Body: "new"}}}},
+	}
+	data, _ := json.MarshalIndent(cj, "", "  ")
+	os.WriteFile(filepath.Join(dir, ".crit.json"), data, 0644)
+
+	if !s.mergeExternalCritJSON() {
+		t.Fatal("expected mergeExternalCritJSON to detect the edit")
+	}
+	if got := s.GetComments("main.go")[0].Body; got != "new" {
+		t.Errorf("file comment body = %q", got)
+	}
+	if got := s.reviewComments[0].Body; got != "new review" {
+		t.Errorf("review comment body = %q", got)
+	}
+}
+
 func TestSession_MergeExternalCritJSON_NoChange(t *testing.T) {
 	dir := t.TempDir()
 	s := &Session{
@@ -1680,6 +1709,7 @@ func TestComment_NoRepliesBackwardCompat(t *testing.T) {
 
 func TestSession_AddReply(t *testing.T) {
 	s := &Session{
+		RepoRoot:    t.TempDir(),
 		ReviewRound: 1,
 
 		Files: []*FileEntry{
@@ -1712,6 +1742,7 @@ func TestSession_AddReply(t *testing.T) {
 
 func TestSession_AddReply_UnresolvesComment(t *testing.T) {
 	s := &Session{
+		RepoRoot:    t.TempDir(),
 		ReviewRound: 1,
 
 		Files: []*FileEntry{
@@ -1742,6 +1773,7 @@ func TestSession_AddReply_UnresolvesComment(t *testing.T) {
 
 func TestSession_UpdateReply(t *testing.T) {
 	s := &Session{
+		RepoRoot:    t.TempDir(),
 		ReviewRound: 1,
 		Files: []*FileEntry{
 			{
@@ -1765,6 +1797,7 @@ func TestSession_UpdateReply(t *testing.T) {
 
 func TestSession_DeleteReply(t *testing.T) {
 	s := &Session{
+		RepoRoot:    t.TempDir(),
 		ReviewRound: 1,
 		Files: []*FileEntry{
 			{
@@ -1795,6 +1828,7 @@ func TestSession_DeleteReply(t *testing.T) {
 
 func TestSession_AddReply_SequentialIDs(t *testing.T) {
 	s := &Session{
+		RepoRoot:    t.TempDir(),
 		ReviewRound: 1,
 		Files: []*FileEntry{
 			{
@@ -3964,3 +3998,129 @@ func TestExtractAnchor(t *testing.T) {
 		})
 	}
 }
+
+func TestLooksLikeText(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample []byte
+		want   bool
+	}{
+		{"ascii", []byte("hello\n"), true},
+		{"utf-8", []byte("naïve café ✓\n"), true},
+		{"empty", nil, true},
+		{"nul byte", []byte("%PDF-1.7\x00\x01"), false},
+		{"latin-1", []byte("caf\xe9\n"), false},
+		{"rune cut off at end", []byte("ok ✓")[:5], true},
+	}
+	for _, tt := range tests {
+		if got := looksLikeText(tt.sample); got != tt.want {
+			t.Errorf("%s: looksLikeText = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewSessionFromFiles_Binary(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "notes.md"), "# Notes\n")
+	bin := filepath.Join(dir, "report")
+	writeFile(t, bin, "\x7fELF\x00\x00\x00")
+
+	_, err := NewSessionFromFiles([]string{bin}, nil)
+	if err == nil || !strings.Contains(err.Error(), "is not a text file") {
+		t.Fatalf("explicit binary file: err = %v", err)
+	}
+
+	// Inside a directory, binary files are skipped rather than fatal.
+	session, err := NewSessionFromFiles([]string{dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Files) != 1 || filepath.Base(session.Files[0].Path) != "notes.md" {
+		t.Errorf("files = %+v", session.Files)
+	}
+}
+
+func TestTextContent(t *testing.T) {
+	tests := map[string]string{
+		"plain\n":               "plain\n",
+		"\xEF\xBB\xBF# Title\n": "# Title\n",
+		"a\r\nb\r\n":            "a\nb\n",
+		"\xEF\xBB\xBFa\r\nb":    "a\nb",
+		"lone\rcr\n":            "lone\rcr\n",
+	}
+	for in, want := range tests {
+		if got := textContent([]byte(in)); got != want {
+			t.Errorf("textContent(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNewSessionFromFiles_CRLFAndBOM(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "plan.md")
+	raw := "\xEF\xBB\xBF# Plan\r\n\r\nStep one\r\n"
+	writeFile(t, path, raw)
+
+	session, err := NewSessionFromFiles([]string{path}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.OutputDir = dir
+	f := session.Files[0]
+	if f.Content != "# Plan\n\nStep one\n" {
+		t.Errorf("Content = %q", f.Content)
+	}
+	if f.FileHash != fileHash([]byte(raw)) {
+		t.Error("FileHash should hash the original bytes")
+	}
+	c, _ := session.AddComment(f.Path, 3, 3, "", "expand", "", "")
+	if c.Anchor != "Step one" {
+		t.Errorf("anchor = %q", c.Anchor)
+	}
+	if data, _ := os.ReadFile(path); string(data) != raw {
+		t.Error("the file on disk should be untouched")
+	}
+}
+
+func TestColumnSpan(t *testing.T) {
+	content := "first line\nseçond line\nthird\n"
+	tests := []struct {
+		startLine, startCol, endLine, endCol int
+		want                                 string
+		wantErr                              bool
+	}{
+		{1, 7, 1, 10, "line", false},
+		{2, 3, 2, 3, "ç", false},
+		{1, 7, 3, 2, "line\nseçond line\nth", false},
+		{1, 11, 1, 11, "", true},
+		{1, 5, 1, 4, "", true},
+		{1, 0, 1, 4, "", true},
+		{3, 1, 3, 6, "", true},
+	}
+	for _, tt := range tests {
+		got, err := columnSpan(content, tt.startLine, tt.startCol, tt.endLine, tt.endCol)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("columnSpan(%d:%d-%d:%d) = %q, %v", tt.startLine, tt.startCol, tt.endLine, tt.endCol, got, err)
+		}
+	}
+}
+
+func TestAddSpanComment(t *testing.T) {
+	_, session := newTestServer(t)
+	c, err := session.AddSpanComment("test.md", 2, 2, 2, 4, "typo?", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.StartCol != 2 || c.EndCol != 4 || c.Quote != "ine" || c.Anchor != "line2" {
+		t.Errorf("comment = %+v", c)
+	}
+	if got := session.GetComments("test.md"); len(got) != 1 || got[0].StartCol != 2 {
+		t.Errorf("stored comments = %+v", got)
+	}
+	if _, err := session.AddSpanComment("test.md", 2, 2, 2, 9, "x", ""); err == nil {
+		t.Error("span past the end of the line should be rejected")
+	}
+	if _, err := session.AddSpanComment("missing.md", 1, 1, 1, 1, "x", ""); !errors.Is(err, errFileNotFound) {
+		t.Errorf("missing file err = %v", err)
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"bytes"
@@ -7,13 +7,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/url"
 	"os"
-	"path"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/JoshEllinger/crit/pkg/sharecrypt"
 )
 
 // defaultShareURL is the production crit-web service URL, used as the fallback
@@ -103,9 +103,26 @@ func buildSharePayload(files []shareFile, comments []shareComment, reviewRound i
 	}
 }
 
+// shareOptions are how a new share is made.
+type shareOptions struct {
+	encrypt   bool   // seal the upload under a new key, which the returned URL carries
+	expiresAt string // RFC 3339 time the service should stop serving it; "" for never
+}
+
 // shareFilesToWeb uploads files to a crit-web instance and returns the share URL and delete token.
-func shareFilesToWeb(files []shareFile, comments []shareComment, shareURL string, reviewRound int, authToken string) (string, string, error) {
+func shareFilesToWeb(files []shareFile, comments []shareComment, shareURL string, reviewRound int, authToken string, opts shareOptions) (string, string, error) {
 	payload := buildSharePayload(files, comments, reviewRound)
+	var key []byte
+	if opts.encrypt {
+		key = sharecrypt.NewKey()
+		var err error
+		if payload, err = sharecrypt.EncryptPayload(key, payload); err != nil {
+			return "", "", err
+		}
+	}
+	if opts.expiresAt != "" {
+		payload["expires_at"] = opts.expiresAt
+	}
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return "", "", fmt.Errorf("marshaling payload: %w", err)
@@ -143,6 +160,9 @@ func shareFilesToWeb(files []shareFile, comments []shareComment, shareURL string
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return "", "", fmt.Errorf("decoding share response: %w", err)
 	}
+	if key != nil {
+		result.URL = sharecrypt.WithKey(result.URL, key)
+	}
 	return result.URL, result.DeleteToken, nil
 }
 
@@ -236,7 +256,7 @@ func loadCommentsFromCritJSON(critPath string, filePaths []string, includeResolv
 		return nil, 1
 	}
 	var cj CritJSON
-	if err := json.Unmarshal(data, &cj); err != nil {
+	if err := unmarshalCritJSON(data, &cj); err != nil {
 		return nil, 1
 	}
 
@@ -284,6 +304,7 @@ type webComment struct {
 	AuthorDisplayName string `json:"author_display_name"`
 	Quote             string `json:"quote"`
 	Scope             string `json:"scope"`
+	Encrypted         string `json:"encrypted,omitempty"` // the other fields, sealed, on encrypted shares
 }
 
 // buildLocalFingerprints returns a set of body+file+line fingerprints for all
@@ -315,12 +336,11 @@ func buildLocalFingerprints(cj CritJSON) map[string]bool {
 //
 // shareURL is the full review URL, e.g. "https://crit.md/r/abc123".
 func fetchNewWebComments(shareURL string, localIDs map[string]bool, localFingerprints map[string]bool, authToken string) ([]webComment, error) {
-	token := path.Base(shareURL)
-	u, err := url.Parse(shareURL)
+	reviewURL, key, err := sharecrypt.ReviewAPI(shareURL)
 	if err != nil {
-		return nil, fmt.Errorf("invalid share URL: %w", err)
+		return nil, err
 	}
-	apiURL := u.Scheme + "://" + u.Host + "/api/reviews/" + token + "/comments"
+	apiURL := reviewURL + "/comments"
 
 	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
 	if err != nil {
@@ -349,6 +369,11 @@ func fetchNewWebComments(shareURL string, localIDs map[string]bool, localFingerp
 
 	var newOnes []webComment
 	for _, wc := range all {
+		if wc.Encrypted != "" && key != nil {
+			if err := sharecrypt.Open(key, wc.Encrypted, &wc); err != nil {
+				return nil, fmt.Errorf("decrypting remote comment: %w", err)
+			}
+		}
 		if wc.ExternalID != "" && localIDs[wc.ExternalID] {
 			continue // already have this locally by ID
 		}
@@ -380,12 +405,10 @@ func upsertShareToWeb(cfg CritJSON, files []shareFile, comments []shareComment,
 		return result, nil // nothing changed
 	}
 
-	token := path.Base(cfg.ShareURL)
-	u, err := url.Parse(cfg.ShareURL)
+	apiURL, key, err := sharecrypt.ReviewAPI(cfg.ShareURL)
 	if err != nil {
-		return result, fmt.Errorf("invalid share URL: %w", err)
+		return result, err
 	}
-	apiURL := u.Scheme + "://" + u.Host + "/api/reviews/" + token
 
 	fileList := make([]map[string]any, len(files))
 	for i, f := range files {
@@ -397,11 +420,16 @@ func upsertShareToWeb(cfg CritJSON, files []shareFile, comments []shareComment,
 	}
 
 	payload := map[string]any{
-		"delete_token": cfg.DeleteToken,
 		"files":        fileList,
 		"comments":     comments,
 		"review_round": cfg.ReviewRound,
 	}
+	if key != nil {
+		if payload, err = sharecrypt.EncryptPayload(key, payload); err != nil {
+			return result, err
+		}
+	}
+	payload["delete_token"] = cfg.DeleteToken
 
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -442,6 +470,9 @@ func upsertShareToWeb(cfg CritJSON, files []shareFile, comments []shareComment,
 	result.ReviewRound = respBody.ReviewRound
 	if respBody.URL != "" {
 		result.URL = respBody.URL
+		if key != nil {
+			result.URL = sharecrypt.WithKey(respBody.URL, key)
+		}
 	}
 	return result, nil
 }
@@ -453,15 +484,20 @@ func loadExistingShareCfg(critPath string, paths []string) (CritJSON, bool) {
 		return CritJSON{}, false
 	}
 	var cj CritJSON
-	if err := json.Unmarshal(data, &cj); err != nil {
+	if err := unmarshalCritJSON(data, &cj); err != nil {
 		return CritJSON{}, false
 	}
-	if cj.ShareURL == "" {
+	// Without a delete token the share was fetched from someone else (crit
+	// fetch <share>) and can't be updated.
+	if cj.ShareURL == "" || cj.DeleteToken == "" {
 		return CritJSON{}, false
 	}
 	if cj.ShareScope != "" && cj.ShareScope != shareScope(paths) {
 		return CritJSON{}, false
 	}
+	if shareExpired(cj.ShareExpiresAt, time.Now()) {
+		return CritJSON{}, false
+	}
 	return cj, true
 }
 
@@ -514,7 +550,7 @@ func mergeWebComments(critPath string, newComments []webComment) error {
 		return err
 	}
 	var cj CritJSON
-	if err := json.Unmarshal(data, &cj); err != nil {
+	if err := unmarshalCritJSON(data, &cj); err != nil {
 		return err
 	}
 	if cj.Files == nil {
@@ -560,7 +596,7 @@ func updateShareState(critPath string, hash string, reviewRound int) error {
 		return err
 	}
 	var cj CritJSON
-	if err := json.Unmarshal(data, &cj); err != nil {
+	if err := unmarshalCritJSON(data, &cj); err != nil {
 		return err
 	}
 	cj.LastShareHash = hash
@@ -569,12 +605,15 @@ func updateShareState(critPath string, hash string, reviewRound int) error {
 	return saveCritJSON(critPath, cj)
 }
 
-// persistShareState writes the share URL, delete token, and scope hash to the review file,
-// preserving any existing content.
-func persistShareState(critPath string, shareURL string, deleteToken string, scope string) error {
+// persistShareState writes the share URL, delete token, scope hash and expiry to the review file,
+// preserving any existing content. A review file that doesn't parse, or was
+// written by a newer crit, is left alone.
+func persistShareState(critPath string, shareURL string, deleteToken string, scope string, expiresAt string) error {
 	var cj CritJSON
 	if data, err := os.ReadFile(critPath); err == nil {
-		_ = json.Unmarshal(data, &cj)
+		if err := unmarshalCritJSON(data, &cj); err != nil {
+			return fmt.Errorf("invalid review file: %w", err)
+		}
 	}
 	if cj.Files == nil {
 		cj.Files = make(map[string]CritJSONFile)
@@ -582,6 +621,8 @@ func persistShareState(critPath string, shareURL string, deleteToken string, sco
 	cj.ShareURL = shareURL
 	cj.DeleteToken = deleteToken
 	cj.ShareScope = scope
+	cj.ShareExpiresAt = expiresAt
+	cj.Shares = withIssuedShare(cj.Shares, shareURL, deleteToken, expiresAt)
 	cj.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
 
 	return saveCritJSON(critPath, cj)
@@ -596,18 +637,36 @@ func clearShareState(critPath string) error {
 		return nil //nolint:nilerr // no review file means nothing to clear
 	}
 	var cj CritJSON
-	if err := json.Unmarshal(data, &cj); err != nil {
+	if err := unmarshalCritJSON(data, &cj); err != nil {
 		return fmt.Errorf("invalid review file: %w", err)
 	}
+	cj.Shares = withoutIssuedShare(cj.Shares, cj.ShareURL)
 	cj.ShareURL = ""
 	cj.DeleteToken = ""
 	cj.ShareScope = ""
 	cj.LastShareHash = ""
+	cj.ShareExpiresAt = ""
 	cj.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
 
 	return saveCritJSON(critPath, cj)
 }
 
+// removeIssuedShare drops the share at shareURL from the review file's list
+// of shares after it was revoked.
+func removeIssuedShare(critPath, shareURL string) error {
+	data, err := os.ReadFile(critPath)
+	if err != nil {
+		return err
+	}
+	var cj CritJSON
+	if err := unmarshalCritJSON(data, &cj); err != nil {
+		return fmt.Errorf("invalid review file: %w", err)
+	}
+	cj.Shares = withoutIssuedShare(cj.Shares, shareURL)
+	cj.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	return saveCritJSON(critPath, cj)
+}
+
 // loadShareConfig loads the merged Config from the current directory context.
 // Used by share/fetch/unpublish commands to avoid redundant config parsing.
 func loadShareConfig() Config {
@@ -630,7 +689,7 @@ func resolveShareURL(flagValue string, cfg Config, fallback string) string {
 	if flagValue != "" {
 		return flagValue
 	}
-	if envShare, ok := os.LookupEnv("CRIT_SHARE_URL"); ok {
+	if envShare, ok := os.LookupEnv(envShareURL); ok {
 		return envShare
 	}
 	if cfg.ShareURL != "" {
@@ -643,7 +702,7 @@ func resolveShareURL(flagValue string, cfg Config, fallback string) string {
 // cfg is the already-loaded Config so callers avoid redundant config parsing.
 // Returns empty string if not configured.
 func resolveAuthToken(cfg Config) string {
-	if token, ok := os.LookupEnv("CRIT_AUTH_TOKEN"); ok {
+	if token, ok := os.LookupEnv(envAuthToken); ok {
 		return token
 	}
 	return cfg.AuthToken
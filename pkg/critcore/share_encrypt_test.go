@@ -0,0 +1,117 @@
+package critcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JoshEllinger/crit/pkg/sharecrypt"
+)
+
+func TestShareFilesToWeb_Encrypted(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(map[string]string{"url": "https://crit.md/r/abc123", "delete_token": "dt"})
+	}))
+	defer server.Close()
+
+	files := []shareFile{{Path: "secret-plan.md", Content: "# Acquire Initech"}}
+	comments := []shareComment{{File: "secret-plan.md", StartLine: 1, Body: "keep this quiet"}}
+	link, _, err := shareFilesToWeb(files, comments, server.URL, 1, "", shareOptions{encrypt: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plain := range []string{"secret-plan", "Initech", "quiet"} {
+		if bytes.Contains(body, []byte(plain)) {
+			t.Errorf("upload contains %q: %s", plain, body)
+		}
+	}
+	key, err := sharecrypt.LinkKey(link)
+	if err != nil || key == nil || !strings.HasPrefix(link, "https://crit.md/r/abc123#key=") {
+		t.Fatalf("link = %q (%v)", link, err)
+	}
+	var payload struct {
+		Encrypted  string `json:"encrypted"`
+		Encryption string `json:"encryption"`
+	}
+	json.Unmarshal(body, &payload)
+	var inner struct {
+		Files    []shareFile    `json:"files"`
+		Comments []shareComment `json:"comments"`
+	}
+	if err := sharecrypt.Open(key, payload.Encrypted, &inner); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Encryption != sharecrypt.Encryption || len(inner.Files) != 1 || inner.Files[0].Content != "# Acquire Initech" || inner.Comments[0].Body != "keep this quiet" {
+		t.Errorf("decrypted payload = %+v", inner)
+	}
+}
+
+func TestUpsertShareToWeb_Encrypted(t *testing.T) {
+	key := sharecrypt.NewKey()
+	var payload map[string]any
+	var reqPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&payload)
+		json.NewEncoder(w).Encode(map[string]any{"url": "https://crit.md/r/tok", "review_round": 2, "changed": true})
+	}))
+	defer srv.Close()
+
+	cfg := CritJSON{ShareURL: sharecrypt.WithKey(srv.URL+"/r/tok", key), DeleteToken: "dt", ReviewRound: 1}
+	result, err := upsertShareToWeb(cfg, []shareFile{{Path: "plan.md", Content: "# v2"}}, []shareComment{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqPath != "/api/reviews/tok" {
+		t.Errorf("PUT path = %q", reqPath)
+	}
+	if payload["delete_token"] != "dt" || payload["files"] != nil {
+		t.Errorf("payload = %v", payload)
+	}
+	var inner struct {
+		Files []shareFile `json:"files"`
+	}
+	if err := sharecrypt.Open(key, payload["encrypted"].(string), &inner); err != nil || inner.Files[0].Content != "# v2" {
+		t.Errorf("decrypted = %+v, %v", inner, err)
+	}
+	if got, _ := sharecrypt.LinkKey(result.URL); !bytes.Equal(got, key) {
+		t.Errorf("result URL %q lost the key", result.URL)
+	}
+}
+
+func TestFetchNewWebComments_Encrypted(t *testing.T) {
+	key := sharecrypt.NewKey()
+	sealed, _ := sharecrypt.Seal(key, webComment{Body: "needs a rollback plan", FilePath: "plan.md", StartLine: 3, EndLine: 3})
+	var reqPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqPath = r.URL.Path
+		json.NewEncoder(w).Encode([]map[string]string{{"encrypted": sealed}})
+	}))
+	defer srv.Close()
+
+	got, err := fetchNewWebComments(sharecrypt.WithKey(srv.URL+"/r/tok", key), map[string]bool{}, map[string]bool{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqPath != "/api/reviews/tok/comments" {
+		t.Errorf("GET path = %q", reqPath)
+	}
+	if len(got) != 1 || got[0].Body != "needs a rollback plan" || got[0].FilePath != "plan.md" || got[0].StartLine != 3 {
+		t.Errorf("comments = %+v", got)
+	}
+}
+
+func TestMergeConfigs_ShareEncrypt(t *testing.T) {
+	if !mergeConfigs(Config{}, Config{ShareEncrypt: true}, configPresence{}).ShareEncrypt {
+		t.Error("the project should be able to turn encryption on")
+	}
+	if !mergeConfigs(Config{ShareEncrypt: true}, Config{}, configPresence{}).ShareEncrypt {
+		t.Error("the project should not be able to turn encryption off")
+	}
+}
@@ -0,0 +1,201 @@
+package critcore
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Shares can expire: `crit share --expires 7d` (or share_expires in config,
+// which also covers the Share button) sends "expires_at" with the upload,
+// for the share service to stop serving the review after, and records it as
+// share_expires_at in the review file. crit stops using an expired share on
+// its own too: the review UI drops it on load and the next `crit share`
+// starts a new one. `crit share revoke` deletes a share before then, like
+// `crit unpublish`. Every share made of the review is also kept in the review
+// file's "shares" list, expired or not, until it is revoked, so `crit share
+// revoke <id>` still reaches a share that a newer one replaced.
+
+// issuedShare is an entry of the review file's "shares" list.
+type issuedShare struct {
+	ID          string `json:"id"`
+	URL         string `json:"url"`
+	DeleteToken string `json:"delete_token"`
+	ExpiresAt   string `json:"expires_at,omitempty"`
+}
+
+// shareID returns the ID of the share at shareURL: the last path element,
+// without the key fragment. It is "" when there is no share.
+func shareID(shareURL string) string {
+	if shareURL == "" {
+		return ""
+	}
+	base, _, _ := strings.Cut(shareURL, "#")
+	return path.Base(base)
+}
+
+// withIssuedShare returns shares with the share at shareURL added, or its
+// token and expiry updated. Shares without a delete token can't be revoked,
+// so they aren't listed.
+func withIssuedShare(shares []issuedShare, shareURL, deleteToken, expiresAt string) []issuedShare {
+	if shareURL == "" || deleteToken == "" {
+		return shares
+	}
+	entry := issuedShare{ID: shareID(shareURL), URL: shareURL, DeleteToken: deleteToken, ExpiresAt: expiresAt}
+	if i := slices.IndexFunc(shares, func(sh issuedShare) bool { return sh.ID == entry.ID }); i >= 0 {
+		shares = slices.Clone(shares)
+		shares[i] = entry
+		return shares
+	}
+	return append(slices.Clone(shares), entry)
+}
+
+// withoutIssuedShare returns shares without the share at shareURL.
+func withoutIssuedShare(shares []issuedShare, shareURL string) []issuedShare {
+	if shareURL == "" {
+		return shares
+	}
+	id := shareID(shareURL)
+	return slices.DeleteFunc(slices.Clone(shares), func(sh issuedShare) bool { return sh.ID == id })
+}
+
+// currentShare returns the review's live share: the one recorded in the
+// review file, unless it has expired.
+func currentShare(cj CritJSON, now time.Time) (issuedShare, bool) {
+	if cj.ShareURL == "" || shareExpired(cj.ShareExpiresAt, now) {
+		return issuedShare{}, false
+	}
+	return issuedShare{ID: shareID(cj.ShareURL), URL: cj.ShareURL, DeleteToken: cj.DeleteToken, ExpiresAt: cj.ShareExpiresAt}, true
+}
+
+// findIssuedShare returns the listed share id names (see shareMatchesID).
+func findIssuedShare(shares []issuedShare, id string) (issuedShare, bool) {
+	for _, sh := range shares {
+		if id != "" && shareMatchesID(sh.URL, id) {
+			return sh, true
+		}
+	}
+	return issuedShare{}, false
+}
+
+// parseShareExpiry parses an expiry duration: a number of days ("7d") or a
+// Go duration ("12h"). "" means the share doesn't expire.
+func parseShareExpiry(v string) (time.Duration, error) {
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid share expiry %q (want e.g. 7d or 12h)", v)
+	}
+	return d, nil
+}
+
+// shareExpiryTime returns when a share made at now with expiry v expires,
+// in RFC 3339, or "" when it doesn't.
+func shareExpiryTime(v string, now time.Time) (string, error) {
+	d, err := parseShareExpiry(v)
+	if err != nil || d == 0 {
+		return "", err
+	}
+	return now.Add(d).UTC().Format(time.RFC3339), nil
+}
+
+// shareExpired reports whether a share expiring at (RFC 3339, "" for never)
+// has expired by now.
+func shareExpired(at string, now time.Time) bool {
+	t, err := time.Parse(time.RFC3339, at)
+	return err == nil && !now.Before(t)
+}
+
+// shorterShareExpiry picks the share_expires of the merged config: a project
+// can shorten the global expiry but not lengthen or remove it. An invalid
+// project value is kept so validation reports it.
+func shorterShareExpiry(global, project string) string {
+	if project == "" {
+		return global
+	}
+	pd, perr := parseShareExpiry(project)
+	gd, gerr := parseShareExpiry(global)
+	if perr != nil || global == "" || gerr != nil || pd < gd {
+		return project
+	}
+	return global
+}
+
+// shareMatchesID reports whether id names the share at shareURL: its token,
+// e.g. "abc123", or the URL itself (with or without the key fragment).
+func shareMatchesID(shareURL, id string) bool {
+	if id == "" || shareURL == "" {
+		return id == ""
+	}
+	base, _, _ := strings.Cut(shareURL, "#")
+	idBase, _, _ := strings.Cut(id, "#")
+	return idBase == base || id == path.Base(base)
+}
+
+func printShareRevokeUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit share revoke [--output <dir>] [--share-url <url>] [<id>]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Deletes the review's share from the share service, so its link stops working.")
+	fmt.Fprintln(os.Stderr, "<id> is the share's token or URL. Without it the current share is revoked;")
+	fmt.Fprintln(os.Stderr, "with it, the current share or an earlier one of this review, expired or")
+	fmt.Fprintln(os.Stderr, "replaced. Same as crit unpublish.")
+}
+
+// revokeEarlierShare deletes id, a share of the review at critPath other
+// than the current one, and drops it from the review file's list of shares.
+func revokeEarlierShare(critPath string, cj CritJSON, id, svcURL, authToken string) {
+	sh, ok := findIssuedShare(cj.Shares, id)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: %s is not one of this review's shares\n", id)
+		os.Exit(1)
+	}
+	if err := unpublishFromWeb(svcURL, sh.DeleteToken, authToken); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := removeIssuedShare(critPath, sh.URL); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not update the review file: %v\n", err)
+	}
+	fmt.Printf("Share %s revoked.\n", sh.ID)
+}
+
+// runShareRevoke implements `crit share revoke`.
+func runShareRevoke(args []string) {
+	var outputDir, svcURL, id string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--output", "-o", "--share-url":
+			if i+1 >= len(args) {
+				fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", arg)
+				os.Exit(1)
+			}
+			i++
+			if arg == "--share-url" {
+				svcURL = args[i]
+			} else {
+				outputDir = args[i]
+			}
+		case "--help", "-h":
+			printShareRevokeUsage()
+			return
+		default:
+			if strings.HasPrefix(arg, "-") || id != "" {
+				printShareRevokeUsage()
+				os.Exit(1)
+			}
+			id = arg
+		}
+	}
+	unpublishShare(outputDir, svcURL, id)
+}
@@ -0,0 +1,230 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseShareExpiry(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"", 0},
+		{"7d", 7 * 24 * time.Hour},
+		{"12h", 12 * time.Hour},
+		{"90m", 90 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got, err := parseShareExpiry(tt.in); err != nil || got != tt.want {
+			t.Errorf("parseShareExpiry(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"7", "1.5d", "-1d", "0d", "0h", "week"} {
+		if _, err := parseShareExpiry(bad); err == nil {
+			t.Errorf("parseShareExpiry(%q) should fail", bad)
+		}
+	}
+}
+
+func TestShareExpired(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at, _ := shareExpiryTime("1d", now)
+	if at != "2026-03-02T12:00:00Z" {
+		t.Fatalf("shareExpiryTime = %q", at)
+	}
+	if shareExpired(at, now) || !shareExpired(at, now.Add(24*time.Hour)) {
+		t.Error("a 1d share should expire a day later")
+	}
+	if shareExpired("", now.Add(1000*time.Hour)) {
+		t.Error("a share without an expiry never expires")
+	}
+}
+
+func TestShorterShareExpiry(t *testing.T) {
+	tests := []struct{ global, project, want string }{
+		{"", "", ""},
+		{"7d", "", "7d"},
+		{"", "7d", "7d"},
+		{"7d", "1d", "1d"},
+		{"1d", "30d", "1d"},
+		{"7d", "soon", "soon"},
+	}
+	for _, tt := range tests {
+		if got := shorterShareExpiry(tt.global, tt.project); got != tt.want {
+			t.Errorf("shorterShareExpiry(%q, %q) = %q, want %q", tt.global, tt.project, got, tt.want)
+		}
+	}
+}
+
+func TestShareMatchesID(t *testing.T) {
+	link := "https://crit.md/r/abc123#key=xyz"
+	for _, id := range []string{"", "abc123", "https://crit.md/r/abc123", link} {
+		if !shareMatchesID(link, id) {
+			t.Errorf("shareMatchesID(%q) = false", id)
+		}
+	}
+	for _, id := range []string{"def456", "https://crit.md/r/def456"} {
+		if shareMatchesID(link, id) {
+			t.Errorf("shareMatchesID(%q) = true", id)
+		}
+	}
+}
+
+func TestShareFilesToWeb_SendsExpiry(t *testing.T) {
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		json.NewEncoder(w).Encode(map[string]string{"url": "https://crit.md/r/abc123", "delete_token": "dt"})
+	}))
+	defer server.Close()
+
+	files := []shareFile{{Path: "plan.md", Content: "# Plan"}}
+	if _, _, err := shareFilesToWeb(files, nil, server.URL, 1, "", shareOptions{encrypt: true, expiresAt: "2026-03-02T12:00:00Z"}); err != nil {
+		t.Fatal(err)
+	}
+	if payload["expires_at"] != "2026-03-02T12:00:00Z" {
+		t.Errorf("expires_at = %v, want it readable beside the encrypted payload", payload["expires_at"])
+	}
+}
+
+func TestLoadExistingShareCfg_Expired(t *testing.T) {
+	critPath := filepath.Join(t.TempDir(), ".crit.json")
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	if err := persistShareState(critPath, "https://crit.md/r/abc", "dt", shareScope([]string{"plan.md"}), past); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loadExistingShareCfg(critPath, []string{"plan.md"}); ok {
+		t.Error("an expired share should not be reused")
+	}
+	if err := clearShareState(critPath); err != nil {
+		t.Fatal(err)
+	}
+	cj, _ := loadCritJSON(critPath)
+	if cj.ShareExpiresAt != "" {
+		t.Errorf("clearShareState left share_expires_at = %q", cj.ShareExpiresAt)
+	}
+}
+
+func TestLoadCritJSON_DropsExpiredShare(t *testing.T) {
+	s := newTestSession(t)
+	reviewPath := filepath.Join(s.RepoRoot, "review.json")
+	data, _ := json.Marshal(CritJSON{
+		ShareURL:       "https://crit.example.com/review/abc",
+		DeleteToken:    "tok",
+		ShareExpiresAt: time.Now().Add(-time.Minute).UTC().Format(time.RFC3339),
+		Files:          map[string]CritJSONFile{},
+	})
+	writeFile(t, reviewPath, string(data))
+	s.ReviewFilePath = reviewPath
+	s.loadCritJSON()
+	if s.sharedURL != "" {
+		t.Errorf("sharedURL = %q, want the expired share dropped", s.sharedURL)
+	}
+}
+
+func TestHandleShare_ReplacesExpiredShare(t *testing.T) {
+	var payload map[string]any
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"url": "https://crit.md/r/new-token", "delete_token": "new-del-token"})
+	}))
+	defer mockServer.Close()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "plan.md"), "# Plan")
+	sess := &Session{
+		OutputDir:   dir,
+		RepoRoot:    dir,
+		Files:       []*FileEntry{{Path: "plan.md", AbsPath: filepath.Join(dir, "plan.md"), Content: "# Plan"}},
+		subscribers: make(map[chan SSEEvent]struct{}),
+	}
+	sess.SetSharedURLAndToken("https://crit.md/r/existing", "existing-del-token")
+	sess.SetShareExpiry(time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+
+	srv := &Server{shareURL: mockServer.URL, cfg: Config{ShareExpires: "2d"}}
+	srv.session.Store(sess)
+	w := httptest.NewRecorder()
+	srv.handleShare(w, httptest.NewRequest(http.MethodPost, "/api/share", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var result map[string]any
+	json.NewDecoder(w.Body).Decode(&result)
+	if result["url"] != "https://crit.md/r/new-token" || result["expires_at"] == "" {
+		t.Errorf("result = %v, want a new share with an expiry", result)
+	}
+	if payload["expires_at"] != result["expires_at"] || sess.GetShareExpiry() != result["expires_at"] {
+		t.Errorf("expires_at sent %v, stored %q, returned %v", payload["expires_at"], sess.GetShareExpiry(), result["expires_at"])
+	}
+}
+
+func TestIssuedShares(t *testing.T) {
+	shares := withIssuedShare(nil, "https://crit.md/r/aaa#key=k", "tokA", "")
+	shares = withIssuedShare(shares, "https://crit.md/r/bbb", "tokB", "")
+	shares = withIssuedShare(shares, "https://crit.md/r/aaa#key=k", "tokA", "2026-03-02T12:00:00Z")
+	shares = withIssuedShare(shares, "https://crit.md/r/ccc", "", "")
+	if len(shares) != 2 || shares[0].ID != "aaa" || shares[0].ExpiresAt == "" || shares[1].ID != "bbb" {
+		t.Fatalf("shares = %+v, want aaa (with its expiry) and bbb", shares)
+	}
+	if sh, ok := findIssuedShare(shares, "https://crit.md/r/bbb"); !ok || sh.DeleteToken != "tokB" {
+		t.Errorf("findIssuedShare(bbb) = %+v, %v", sh, ok)
+	}
+	if rest := withoutIssuedShare(shares, "https://crit.md/r/aaa"); len(rest) != 1 || rest[0].ID != "bbb" || len(shares) != 2 {
+		t.Errorf("withoutIssuedShare = %+v, shares = %+v", rest, shares)
+	}
+}
+
+func TestUnpublishShare_RevokesReplacedShare(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, r.Method+" "+body["delete_token"])
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	t.Setenv(envAuthToken, "")
+
+	dir := t.TempDir()
+	critPath := filepath.Join(dir, ".crit.json")
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	if err := persistShareState(critPath, "https://crit.md/r/aaa", "tokA", "", past); err != nil {
+		t.Fatal(err)
+	}
+	if err := persistShareState(critPath, "https://crit.md/r/bbb", "tokB", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	unpublishShare(dir, server.URL, "aaa")
+	if len(sent) != 1 || sent[0] != "DELETE tokA" {
+		t.Errorf("requests = %v, want the expired share's token deleted", sent)
+	}
+	cj, _ := loadCritJSON(critPath)
+	if cj.ShareURL != "https://crit.md/r/bbb" || cj.DeleteToken != "tokB" {
+		t.Errorf("current share = %q/%q, want bbb kept", cj.ShareURL, cj.DeleteToken)
+	}
+	if len(cj.Shares) != 1 || cj.Shares[0].ID != "bbb" {
+		t.Errorf("shares = %+v, want only bbb left", cj.Shares)
+	}
+}
+
+func TestCurrentShare(t *testing.T) {
+	now := time.Now()
+	cj := CritJSON{ShareURL: "https://crit.md/r/abc123#key=k", DeleteToken: "dt"}
+	if sh, ok := currentShare(cj, now); !ok || sh.ID != "abc123" || sh.DeleteToken != "dt" {
+		t.Errorf("currentShare = %+v, %v", sh, ok)
+	}
+	cj.ShareExpiresAt = now.Add(-time.Minute).UTC().Format(time.RFC3339)
+	if _, ok := currentShare(cj, now); ok {
+		t.Error("an expired share is not the current one")
+	}
+	if _, ok := currentShare(CritJSON{}, now); ok || shareID("") != "" {
+		t.Error("a review without a share has no current share")
+	}
+}
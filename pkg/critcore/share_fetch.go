@@ -0,0 +1,197 @@
+package critcore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/JoshEllinger/crit/pkg/sharecrypt"
+)
+
+// `crit fetch <share>` pulls someone else's shared review into a local copy:
+// it downloads the files and comments of the share (its token, e.g.
+// "abc123", or its link, key fragment included for encrypted shares) into
+// a new directory and starts a review of them there, so the colleague
+// reviews it in their own crit. The copy's review file keeps the link, so a
+// plain `crit fetch` in it pulls comments added on the share later; the
+// share itself can't be updated from the copy (there is no delete token),
+// and `crit share` there makes a new one.
+
+// sharedReview is a share downloaded from the share service.
+type sharedReview struct {
+	Files       []shareFile  `json:"files"`
+	ReviewRound int          `json:"review_round"`
+	Encrypted   string       `json:"encrypted,omitempty"`
+	Comments    []webComment `json:"-"`
+}
+
+// sharedReviewLink returns the link of the share named by arg, a link or a
+// token on the share service at svcURL.
+func sharedReviewLink(arg, svcURL string) string {
+	if strings.Contains(arg, "://") {
+		return arg
+	}
+	return strings.TrimSuffix(svcURL, "/") + "/r/" + arg
+}
+
+// downloadSharedReview fetches the share at link: its files from
+// GET /api/reviews/<token>/document and all its comments.
+func downloadSharedReview(link, authToken string) (sharedReview, error) {
+	var r sharedReview
+	apiURL, key, err := sharecrypt.ReviewAPI(link)
+	if err != nil {
+		return r, err
+	}
+	req, err := http.NewRequest(http.MethodGet, apiURL+"/document", nil)
+	if err != nil {
+		return r, err
+	}
+	setBearer(req, authToken)
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if err != nil {
+		return r, fmt.Errorf("fetching shared review: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return r, errors.New("shared review not found (it may have been unpublished or expired)")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return r, fmt.Errorf("shared review returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return r, fmt.Errorf("decoding shared review: %w", err)
+	}
+	if r.Encrypted != "" {
+		if key == nil {
+			return r, errors.New("the share is encrypted; use the full link, including its #key=...")
+		}
+		if err := sharecrypt.Open(key, r.Encrypted, &r); err != nil {
+			return r, fmt.Errorf("decrypting shared review: %w", err)
+		}
+	}
+	if r.Comments, err = fetchNewWebComments(link, map[string]bool{}, map[string]bool{}, authToken); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// writeSharedReview writes r's files into dir, which must not exist yet or
+// be empty, and returns their paths. Files the share lists as removed or
+// deleted have no content and are skipped.
+func writeSharedReview(dir string, r sharedReview) ([]string, error) {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("%s already exists and isn't empty", dir)
+	}
+	var paths []string
+	for _, f := range r.Files {
+		if f.Status == "removed" || f.Status == "deleted" {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(f.Path)) {
+			return nil, fmt.Errorf("shared review has an unsafe file path %q", f.Path)
+		}
+		dest := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(dest, []byte(f.Content), 0644); err != nil {
+			return nil, err
+		}
+		paths = append(paths, f.Path)
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("shared review has no files")
+	}
+	return paths, nil
+}
+
+// saveSharedReviewFile writes the copy's review file at critPath: the
+// share's round and link, then its comments.
+func saveSharedReviewFile(critPath, link string, r sharedReview) error {
+	cj := CritJSON{
+		UpdatedAt:   time.Now().UTC().Format(time.RFC3339),
+		ReviewRound: max(r.ReviewRound, 1),
+		ShareURL:    link,
+		Files:       map[string]CritJSONFile{},
+	}
+	if err := saveCritJSON(critPath, cj); err != nil {
+		return err
+	}
+	return mergeWebComments(critPath, r.Comments)
+}
+
+func printFetchShareUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit fetch [--share-url <url>] [--no-review] <share> [<dir>]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Downloads a shared review (its link, or its token on the share service) into")
+	fmt.Fprintln(os.Stderr, "<dir> (crit-<token> by default) and starts a review of it there. Encrypted")
+	fmt.Fprintln(os.Stderr, "shares need the full link, #key=... included. --no-review only downloads.")
+}
+
+// runFetchShare implements `crit fetch <share> [<dir>]`.
+func runFetchShare(args []string) {
+	var svcURL string
+	var positional []string
+	noReview := false
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--share-url":
+			if i+1 >= len(args) {
+				fmt.Fprintln(os.Stderr, "Error: --share-url requires a value")
+				os.Exit(1)
+			}
+			i++
+			svcURL = args[i]
+		case "--no-review":
+			noReview = true
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	if len(positional) == 0 || len(positional) > 2 || strings.HasPrefix(positional[0], "-") {
+		printFetchShareUsage()
+		os.Exit(1)
+	}
+
+	cfg := loadShareConfig()
+	link := sharedReviewLink(positional[0], resolveShareURL(svcURL, cfg, defaultShareURL))
+	base, _, _ := strings.Cut(link, "#")
+	dir := "crit-" + path.Base(base)
+	if len(positional) == 2 {
+		dir = positional[1]
+	}
+
+	r, err := downloadSharedReview(link, resolveAuthToken(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	paths, err := writeSharedReview(dir, r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	critPath, err := resolveReviewPath(".")
+	if err == nil {
+		err = saveSharedReviewFile(critPath, link, r)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error saving review file: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Fetched %d file(s) and %d comment(s) into %s\n", len(paths), len(r.Comments), dir)
+	if noReview {
+		return
+	}
+	runReview(append([]string{"--output", "."}, paths...))
+}
@@ -0,0 +1,129 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/JoshEllinger/crit/pkg/sharecrypt"
+)
+
+// fakeShareService serves one share, token "tok", with document as its
+// /document response and comments as its comments.
+func fakeShareService(t *testing.T, document any, comments any) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/reviews/tok/document":
+			json.NewEncoder(w).Encode(document)
+		case "/api/reviews/tok/comments":
+			json.NewEncoder(w).Encode(comments)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSharedReviewLink(t *testing.T) {
+	if got := sharedReviewLink("abc123", "https://crit.md/"); got != "https://crit.md/r/abc123" {
+		t.Errorf("token: %q", got)
+	}
+	link := "https://crit.example.com/r/abc123#key=xyz"
+	if got := sharedReviewLink(link, "https://crit.md"); got != link {
+		t.Errorf("link: %q", got)
+	}
+}
+
+func TestDownloadSharedReview(t *testing.T) {
+	srv := fakeShareService(t,
+		map[string]any{"review_round": 2, "files": []shareFile{{Path: "plan.md", Content: "# Plan\n"}}},
+		[]webComment{{Body: "Why?", FilePath: "plan.md", StartLine: 1, EndLine: 1}})
+	r, err := downloadSharedReview(srv.URL+"/r/tok", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ReviewRound != 2 || len(r.Files) != 1 || r.Files[0].Content != "# Plan\n" || len(r.Comments) != 1 {
+		t.Errorf("review = %+v", r)
+	}
+	if _, err := downloadSharedReview(srv.URL+"/r/gone", ""); err == nil {
+		t.Error("a missing share should fail")
+	}
+}
+
+func TestDownloadSharedReview_Encrypted(t *testing.T) {
+	key := sharecrypt.NewKey()
+	payload, _ := sharecrypt.EncryptPayload(key, buildSharePayload([]shareFile{{Path: "plan.md", Content: "# Secret"}}, nil, 1))
+	sealed, _ := sharecrypt.Seal(key, webComment{Body: "hush", FilePath: "plan.md", StartLine: 1})
+	srv := fakeShareService(t, payload, []map[string]string{{"encrypted": sealed}})
+
+	r, err := downloadSharedReview(sharecrypt.WithKey(srv.URL+"/r/tok", key), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Files) != 1 || r.Files[0].Content != "# Secret" || len(r.Comments) != 1 || r.Comments[0].Body != "hush" {
+		t.Errorf("review = %+v", r)
+	}
+	if _, err := downloadSharedReview(srv.URL+"/r/tok", ""); err == nil {
+		t.Error("an encrypted share without its key should fail")
+	}
+}
+
+func TestWriteSharedReview(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "copy")
+	r := sharedReview{Files: []shareFile{
+		{Path: "plan.md", Content: "# Plan"},
+		{Path: "docs/api.md", Content: "# API"},
+		{Path: "old.md", Status: "removed"},
+	}}
+	paths, err := writeSharedReview(dir, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("paths = %v", paths)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "docs", "api.md")); string(data) != "# API" {
+		t.Errorf("docs/api.md = %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.md")); err == nil {
+		t.Error("removed files should not be written")
+	}
+	if _, err := writeSharedReview(dir, r); err == nil {
+		t.Error("writing into a non-empty directory should fail")
+	}
+	if _, err := writeSharedReview(t.TempDir(), sharedReview{Files: []shareFile{{Path: "../escape.md"}}}); err == nil {
+		t.Error("a path outside the directory should fail")
+	}
+}
+
+func TestSaveSharedReviewFile(t *testing.T) {
+	critPath := filepath.Join(t.TempDir(), ".crit.json")
+	r := sharedReview{ReviewRound: 3, Comments: []webComment{
+		{Body: "Why?", FilePath: "plan.md", StartLine: 2, EndLine: 2, AuthorDisplayName: "Ana"},
+		{Body: "Looks good", Scope: "review"},
+	}}
+	if err := saveSharedReviewFile(critPath, "https://crit.md/r/tok", r); err != nil {
+		t.Fatal(err)
+	}
+	cj, err := loadCritJSON(critPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cj.ShareURL != "https://crit.md/r/tok" || cj.DeleteToken != "" || cj.ReviewRound != 3 {
+		t.Errorf("share state = %q %q %d", cj.ShareURL, cj.DeleteToken, cj.ReviewRound)
+	}
+	if c := cj.Files["plan.md"].Comments; len(c) != 1 || c[0].Author != "Ana" || c[0].StartLine != 2 {
+		t.Errorf("plan.md comments = %+v", c)
+	}
+	if len(cj.ReviewComments) != 1 {
+		t.Errorf("review comments = %+v", cj.ReviewComments)
+	}
+	if _, ok := loadExistingShareCfg(critPath, []string{"plan.md"}); ok {
+		t.Error("a fetched share has no delete token and should not be updated by crit share")
+	}
+}
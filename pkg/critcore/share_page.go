@@ -0,0 +1,250 @@
+package critcore
+
+import "html/template"
+
+// sharePageTemplate is the page `crit serve-share` shows at /r/<token>. It
+// fetches the share from the API, decrypting it and its comments with the
+// link's #key=... when it is encrypted, and renders the files with their
+// comments. Clicking a line number (shift-click to extend to a range) opens
+// a comment form; comments are posted sealed on encrypted shares. A
+// lines=path:L12-L14 parameter in the fragment, which the review's exports
+// link to (lineLink), scrolls to and highlights those lines.
+var sharePageTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>crit review</title>
+<style>
+:root { color-scheme: light dark; --fg: #1f2328; --muted: #656d76; --border: #d0d7de; --hl: #fff3c4; --link: #ddf4ff; --thread: #f6f8fa; }
+@media (prefers-color-scheme: dark) { :root { --fg: #e6edf3; --muted: #8d96a0; --border: #30363d; --hl: #3d3413; --link: #12263a; --thread: #161b22; } }
+body { font-family: -apple-system, "Segoe UI", sans-serif; color: var(--fg); max-width: 64rem; margin: 1.5rem auto; padding: 0 1rem; }
+header p, .meta { color: var(--muted); font-size: .875rem; }
+h2 { border-bottom: 1px solid var(--border); padding-bottom: .25rem; margin-top: 2rem; font-family: monospace; }
+table.code { border-collapse: collapse; font-family: monospace; font-size: .8125rem; width: 100%; }
+table.code td { padding: 0 .5rem; white-space: pre-wrap; word-break: break-word; vertical-align: top; }
+table.code td.n { color: var(--muted); text-align: right; user-select: none; width: 1%; cursor: pointer; }
+table.code td.n:hover { color: var(--fg); }
+tr.commented td { background: var(--hl); }
+tr.linked td { background: var(--link); }
+td.threads { padding: .25rem .5rem .5rem 3rem !important; white-space: normal !important; font-family: -apple-system, "Segoe UI", sans-serif; }
+.thread { border: 1px solid var(--border); border-radius: 6px; background: var(--thread); padding: .5rem .75rem; margin: .5rem 0; }
+.thread.resolved { opacity: .6; }
+.body { white-space: pre-wrap; margin-top: .25rem; }
+.reply { border-top: 1px solid var(--border); margin-top: .5rem; padding-top: .5rem; }
+form.comment { display: flex; flex-wrap: wrap; gap: .5rem; margin: .5rem 0; font-family: -apple-system, "Segoe UI", sans-serif; }
+form.comment textarea { flex: 1 1 100%; min-height: 4rem; font: inherit; }
+</style>
+</head>
+<body data-token="{{.}}">
+<header>
+<h1>crit review</h1>
+<p id="round"></p>
+</header>
+<main id="review"><p class="meta">Loading…</p></main>
+<script>
+(function () {
+  "use strict";
+  var api = "/api/reviews/" + document.body.dataset.token;
+  var main = document.getElementById("review");
+  var key = null, encrypted = false, round = 1, anchor = null, revealed = false;
+
+  function el(tag, cls, text) {
+    var e = document.createElement(tag);
+    if (cls) e.className = cls;
+    if (text != null) e.textContent = text;
+    return e;
+  }
+  function bytes(b64) {
+    return Uint8Array.from(atob(b64.replace(/-/g, "+").replace(/_/g, "/")), function (c) { return c.charCodeAt(0); });
+  }
+  async function open(sealed) {
+    var data = bytes(sealed);
+    var plain = await crypto.subtle.decrypt({ name: "AES-GCM", iv: data.slice(0, 12) }, key, data.slice(12));
+    return JSON.parse(new TextDecoder().decode(plain));
+  }
+  async function seal(v) {
+    var iv = crypto.getRandomValues(new Uint8Array(12));
+    var ct = new Uint8Array(await crypto.subtle.encrypt({ name: "AES-GCM", iv: iv }, key, new TextEncoder().encode(JSON.stringify(v))));
+    var s = "";
+    iv.forEach(function (b) { s += String.fromCharCode(b); });
+    ct.forEach(function (b) { s += String.fromCharCode(b); });
+    return btoa(s);
+  }
+  async function getJSON(url) {
+    var resp = await fetch(url);
+    if (resp.status === 404) throw new Error("This review doesn't exist, or it has expired or been unpublished.");
+    if (!resp.ok) throw new Error("Could not load the review (status " + resp.status + ").");
+    return resp.json();
+  }
+
+  // Uploaded comments name their file "file", page comments "file_path".
+  function norm(c) {
+    var start = c.start_line || 0;
+    return { file: c.file || c.file_path || "", start: start, end: c.end_line || start, body: c.body || "",
+      author: c.author_display_name, round: c.review_round, resolved: c.resolved, replies: c.replies || [] };
+  }
+  function lines(start, end) {
+    return end > start ? "lines " + start + "–" + end : "line " + start;
+  }
+  function thread(c) {
+    var t = el("div", "thread" + (c.resolved ? " resolved" : ""));
+    var meta = [c.start ? lines(c.start, c.end) : "", c.author, c.round ? "round " + c.round : "", c.resolved ? "resolved" : ""];
+    t.appendChild(el("div", "meta", meta.filter(Boolean).join(" · ")));
+    t.appendChild(el("div", "body", c.body));
+    c.replies.forEach(function (r) {
+      var d = el("div", "reply");
+      d.appendChild(el("div", "meta", r.author_display_name || "Reply"));
+      d.appendChild(el("div", "body", r.body));
+      t.appendChild(d);
+    });
+    return t;
+  }
+
+  function commentForm(target) {
+    var form = el("form", "comment");
+    var body = el("textarea");
+    body.required = true;
+    body.placeholder = target.start_line ? "Comment on " + lines(target.start_line, target.end_line) : "Comment on the review";
+    var name = el("input");
+    name.placeholder = "Your name";
+    name.value = localStorage.getItem("crit-share-name") || "";
+    var submit = el("button", null, "Comment");
+    form.append(body, name, submit);
+    form.onsubmit = async function (e) {
+      e.preventDefault();
+      localStorage.setItem("crit-share-name", name.value);
+      var c = Object.assign({ body: body.value, author_display_name: name.value, review_round: round }, target);
+      var payload = encrypted ? { encrypted: await seal(c) } : c;
+      var resp = await fetch(api + "/comments", { method: "POST", headers: { "Content-Type": "application/json" }, body: JSON.stringify(payload) });
+      if (!resp.ok) {
+        alert("Could not save the comment (status " + resp.status + ").");
+        return;
+      }
+      load().catch(fail);
+    };
+    return form;
+  }
+  function openForm(row, target) {
+    document.querySelectorAll("tr.form").forEach(function (r) { r.remove(); });
+    var tr = row.parentNode.insertRow(row.rowIndex + 1);
+    tr.className = "form";
+    tr.insertCell();
+    var td = tr.insertCell();
+    td.className = "threads";
+    td.appendChild(commentForm(target));
+    td.querySelector("textarea").focus();
+  }
+
+  function renderFile(f, comments) {
+    var section = el("section");
+    section.dataset.path = f.path;
+    section.appendChild(el("h2", null, f.path));
+    var mine = comments.filter(function (c) { return c.file === f.path; });
+    mine.filter(function (c) { return !c.start; }).forEach(function (c) { section.appendChild(thread(c)); });
+    if (f.status === "removed" || f.status === "deleted") {
+      section.appendChild(el("p", "meta", "Removed in this round."));
+      return section;
+    }
+    var table = el("table", "code");
+    var rows = [];
+    (f.content || "").replace(/\n$/, "").split("\n").forEach(function (text, i) {
+      var n = i + 1;
+      var row = table.insertRow();
+      row.dataset.line = n;
+      rows[n] = row;
+      var num = row.insertCell();
+      num.className = "n";
+      num.textContent = n;
+      row.insertCell().textContent = text;
+      if (mine.some(function (c) { return c.start && c.start <= n && n <= c.end; })) row.className = "commented";
+      num.onclick = function (e) {
+        var start = n, end = n;
+        if (e.shiftKey && anchor && anchor.file === f.path) {
+          start = Math.min(anchor.line, n);
+          end = Math.max(anchor.line, n);
+        } else {
+          anchor = { file: f.path, line: n };
+        }
+        openForm(rows[end], { file_path: f.path, start_line: start, end_line: end });
+      };
+      var here = mine.filter(function (c) { return c.start && c.end === n; });
+      if (here.length) {
+        var tr = table.insertRow();
+        tr.insertCell();
+        var td = tr.insertCell();
+        td.className = "threads";
+        here.forEach(function (c) { td.appendChild(thread(c)); });
+      }
+    });
+    section.appendChild(table);
+    return section;
+  }
+  function render(files, comments) {
+    main.textContent = "";
+    document.getElementById("round").textContent = "Round " + round;
+    var review = el("section");
+    review.appendChild(el("h2", null, "Review"));
+    comments.filter(function (c) { return !c.file; }).forEach(function (c) { review.appendChild(thread(c)); });
+    review.appendChild(commentForm({ scope: "review" }));
+    main.appendChild(review);
+    files.forEach(function (f) { main.appendChild(renderFile(f, comments)); });
+  }
+
+  function revealLines(value) {
+    var m = /^(.*):L(\d+)(?:-L(\d+))?$/.exec(value || "");
+    if (!m) return;
+    var start = +m[2], end = +(m[3] || m[2]), first = null;
+    var section = Array.from(main.querySelectorAll("section")).find(function (s) { return s.dataset.path === m[1]; });
+    if (!section) return;
+    section.querySelectorAll("tr[data-line]").forEach(function (row) {
+      var n = +row.dataset.line;
+      if (n < start || n > end) return;
+      row.classList.add("linked");
+      first = first || row;
+    });
+    if (first) first.scrollIntoView({ block: "center" });
+  }
+
+  async function load() {
+    var params = new URLSearchParams(location.hash.slice(1));
+    if (params.get("key")) {
+      if (!window.crypto || !crypto.subtle) throw new Error("Encrypted reviews can only be opened over HTTPS.");
+      key = await crypto.subtle.importKey("raw", bytes(params.get("key")), "AES-GCM", false, ["encrypt", "decrypt"]);
+    }
+    var doc = await getJSON(api + "/document");
+    round = doc.review_round || 1;
+    if (doc.encrypted) {
+      if (!key) throw new Error("This review is encrypted: open it with the full link, including its #key=...");
+      encrypted = true;
+      doc = await open(doc.encrypted).catch(function () { throw new Error("Could not decrypt the review: the link's key is wrong."); });
+    }
+    // The comments list repeats plain shares' uploaded comments; skip those.
+    var comments = (doc.comments || []).map(norm);
+    var seen = {};
+    comments.forEach(function (c) { seen[[c.body, c.file, c.start, c.end].join("|")] = true; });
+    var listed = await getJSON(api + "/comments");
+    for (var wc of listed) {
+      if (wc.encrypted) {
+        if (!key) continue;
+        try { wc = await open(wc.encrypted); } catch (e) { continue; }
+      }
+      var c = norm(wc);
+      if (!seen[[c.body, c.file, c.start, c.end].join("|")]) comments.push(c);
+    }
+    render(doc.files || [], comments);
+    if (!revealed) {
+      revealed = true;
+      revealLines(params.get("lines"));
+    }
+  }
+  function fail(err) {
+    main.textContent = "";
+    main.appendChild(el("p", null, err.message));
+  }
+  load().catch(fail);
+})();
+</script>
+</body>
+</html>
+`))
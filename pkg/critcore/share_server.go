@@ -0,0 +1,657 @@
+package critcore
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// `crit serve-share` runs a share service of its own, for teams that want
+// their shares to stay in house: point share_url (or CRIT_SHARE_URL) at it
+// and `crit share`, `crit fetch`, `crit unpublish` and the Share button work
+// against it as they do against crit.md. It serves the same API under
+// /api/reviews and, at /r/<token>, a page that shows a share and takes
+// comments, which come back to the author on their next `crit share` or
+// `crit fetch`. Shares are kept in a directory or an S3-compatible bucket.
+//
+// Encrypted shares are stored as they arrive; the page decrypts them in the
+// browser with the key in the link's fragment, which never reaches the
+// server, and seals the comments it posts the same way. Expired shares stop
+// being served and are deleted when next requested. With auth tokens set,
+// creating, updating and deleting shares needs one of them as a bearer token
+// (crit sends auth_token); reading and commenting only need the link, as on
+// crit.md, so comments are rate limited per client address and kept small.
+// Links are built from --public-url, never from the request's headers, which
+// the client controls; without it they point at the loopback address the
+// service listens on. Behind a proxy, --trust-proxy takes the client address
+// from X-Forwarded-For.
+
+// envShareAuthTokens lists the auth tokens `crit serve-share` accepts,
+// comma-separated, for keeping them off the command line.
+const envShareAuthTokens = "CRIT_SHARE_AUTH_TOKENS"
+
+// maxShareUpload caps the size of an upload request body.
+const maxShareUpload = 32 << 20
+
+// maxShareComment caps the size of a comment request body, sealed or not.
+const maxShareComment = 32 << 10
+
+// Comments left on share pages, per client address.
+const (
+	shareCommentRate  = 0.2 // comments per second
+	shareCommentBurst = 20
+)
+
+// maxShareWebComments caps the comments left on one share's page.
+const maxShareWebComments = 1000
+
+// shareDocument is what an upload carries and /document returns: the files,
+// comments and round, or all three sealed in Encrypted.
+type shareDocument struct {
+	ReviewRound int            `json:"review_round"`
+	Files       []shareFile    `json:"files,omitempty"`
+	Comments    []shareComment `json:"comments,omitempty"`
+	Encrypted   string         `json:"encrypted,omitempty"`
+	Encryption  string         `json:"encryption,omitempty"`
+	ExpiresAt   string         `json:"expires_at,omitempty"`
+}
+
+// hostedShare is a share as stored.
+type hostedShare struct {
+	shareDocument
+	DeleteTokenHash string       `json:"delete_token_hash"`
+	CreatedAt       string       `json:"created_at"`
+	WebComments     []webComment `json:"web_comments,omitempty"` // left on the share page
+}
+
+// shareServer serves the share API from store.
+type shareServer struct {
+	store      shareStore
+	publicURL  string        // base of the links handed out
+	authTokens []string      // bearer tokens accepted for writes; none means anyone may share
+	maxAge     time.Duration // longest a share may live; 0 for no limit
+	trustProxy bool          // take client addresses from X-Forwarded-For
+	comments   *rateLimiter  // per-client limit on comments left on share pages
+	now        func() time.Time
+	mu         sync.Mutex // serializes read-modify-write of shares
+}
+
+// newShareServer returns a share server over store, its links starting
+// with publicURL.
+func newShareServer(store shareStore, publicURL string) *shareServer {
+	return &shareServer{
+		store:     store,
+		publicURL: publicURL,
+		comments:  newRateLimiter(rateLimitConfig{Rate: shareCommentRate, Burst: shareCommentBurst}),
+		now:       time.Now,
+	}
+}
+
+func (ss *shareServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/api/reviews", ss.handleReviews)
+	mux.HandleFunc("/api/reviews/", ss.handleReview)
+	mux.HandleFunc("/r/", ss.handlePage)
+	return mux
+}
+
+// writeShareError writes {"error": msg}, the error shape crit reads from a
+// share service.
+func writeShareError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// authorized reports whether r may create, update or delete shares.
+func (ss *shareServer) authorized(r *http.Request) bool {
+	if len(ss.authTokens) == 0 {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	for _, t := range ss.authTokens {
+		if ok && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// link returns the viewer URL of the share token.
+func (ss *shareServer) link(token string) string {
+	return strings.TrimSuffix(ss.publicURL, "/") + "/r/" + token
+}
+
+// clientAddr returns the address of the client that sent r: the last hop of
+// X-Forwarded-For, which the proxy appended, when trustProxy is set.
+func (ss *shareServer) clientAddr(r *http.Request) string {
+	if ss.trustProxy {
+		if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+			hops := strings.Split(fwd[len(fwd)-1], ",")
+			if addr := strings.TrimSpace(hops[len(hops)-1]); addr != "" {
+				return addr
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// admitComment checks r against the comment rate limit, answering 429 on w
+// when it is over.
+func (ss *shareServer) admitComment(w http.ResponseWriter, r *http.Request) bool {
+	if ss.comments == nil {
+		return true
+	}
+	ok, wait := ss.comments.take(ss.clientAddr(r))
+	if !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(wait.Seconds())))))
+		writeShareError(w, http.StatusTooManyRequests, "too many comments, try again later")
+	}
+	return ok
+}
+
+// defaultPublicURL returns the base of the share links for a service
+// listening on addr without --public-url: its own address, when that is a
+// loopback one. Anywhere else the links have to name the host clients reach.
+func defaultPublicURL(addr net.Addr) (string, error) {
+	tcp, ok := addr.(*net.TCPAddr)
+	if !ok || !tcp.IP.IsLoopback() {
+		return "", fmt.Errorf("--public-url is required when listening on %s", addr)
+	}
+	return "http://" + addr.String(), nil
+}
+
+// validShareToken reports whether token looks like one newShareToken made,
+// so it is safe to use as a file or object name.
+func validShareToken(token string) bool {
+	if len(token) != 26 {
+		return false
+	}
+	for _, c := range token {
+		if (c < 'A' || c > 'Z') && (c < '2' || c > '7') {
+			return false
+		}
+	}
+	return true
+}
+
+// newShareToken returns a new share token and its delete token, which
+// starts with the share token so a delete request names its share.
+func newShareToken() (string, string) {
+	token := rand.Text()
+	return token, token + "." + rand.Text()
+}
+
+// shareExpiryFor returns when a share asked to expire at requested (RFC 3339,
+// "" for never) expires: at most maxAge from now.
+func (ss *shareServer) shareExpiryFor(requested string) (string, error) {
+	now := ss.now()
+	var at time.Time
+	if requested != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, requested); err != nil || !at.After(now) {
+			return "", fmt.Errorf("invalid expires_at %q", requested)
+		}
+	}
+	if ss.maxAge > 0 && (at.IsZero() || at.After(now.Add(ss.maxAge))) {
+		at = now.Add(ss.maxAge)
+	}
+	if at.IsZero() {
+		return "", nil
+	}
+	return at.UTC().Format(time.RFC3339), nil
+}
+
+// loadShare returns the share token, deleting it instead when it has
+// expired.
+func (ss *shareServer) loadShare(token string) (hostedShare, error) {
+	var hs hostedShare
+	if !validShareToken(token) {
+		return hs, errShareNotFound
+	}
+	data, err := ss.store.load(token)
+	if err != nil {
+		return hs, err
+	}
+	if err := json.Unmarshal(data, &hs); err != nil {
+		return hs, fmt.Errorf("reading share %s: %w", token, err)
+	}
+	if shareExpired(hs.ExpiresAt, ss.now()) {
+		if err := ss.store.remove(token); err != nil {
+			slog.Warn("serve-share: removing expired share", "token", token, "err", err)
+		}
+		return hs, errShareNotFound
+	}
+	return hs, nil
+}
+
+func (ss *shareServer) saveShare(token string, hs hostedShare) error {
+	data, err := json.Marshal(hs)
+	if err != nil {
+		return err
+	}
+	return ss.store.save(token, data)
+}
+
+// writeLoadError answers a request for a share loadShare couldn't return.
+func writeLoadError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errShareNotFound) {
+		writeShareError(w, http.StatusNotFound, "review not found")
+		return
+	}
+	slog.Error("serve-share: loading share", "err", err)
+	writeShareError(w, http.StatusInternalServerError, "could not load review")
+}
+
+// shareUpload is the body of a create or update request.
+type shareUpload struct {
+	shareDocument
+	DeleteToken string `json:"delete_token"`
+}
+
+func decodeShareUpload(w http.ResponseWriter, r *http.Request) (shareUpload, error) {
+	var up shareUpload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxShareUpload)).Decode(&up); err != nil {
+		return up, errors.New("invalid request body")
+	}
+	if up.Encrypted == "" && len(up.Files) == 0 {
+		return up, errors.New("a review needs files")
+	}
+	if up.Encrypted != "" && (len(up.Files) > 0 || len(up.Comments) > 0) {
+		return up, errors.New("an encrypted review can't also carry plain files or comments")
+	}
+	return up, nil
+}
+
+// handleReviews serves POST /api/reviews (create) and DELETE /api/reviews.
+func (ss *shareServer) handleReviews(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !ss.authorized(r) {
+		writeShareError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if r.Method == http.MethodDelete {
+		ss.handleDelete(w, r)
+		return
+	}
+	up, err := decodeShareUpload(w, r)
+	if err != nil {
+		writeShareError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if up.ExpiresAt, err = ss.shareExpiryFor(up.ExpiresAt); err != nil {
+		writeShareError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	up.ReviewRound = max(up.ReviewRound, 1)
+	token, deleteToken := newShareToken()
+	hs := hostedShare{
+		shareDocument:   up.shareDocument,
+		DeleteTokenHash: computeFileHash([]byte(deleteToken)),
+		CreatedAt:       ss.now().UTC().Format(time.RFC3339),
+	}
+	if err := ss.saveShare(token, hs); err != nil {
+		slog.Error("serve-share: saving share", "err", err)
+		writeShareError(w, http.StatusInternalServerError, "could not save review")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"url": ss.link(token), "delete_token": deleteToken, "expires_at": up.ExpiresAt})
+}
+
+// ownsShare reports whether deleteToken is hs's.
+func ownsShare(hs hostedShare, deleteToken string) bool {
+	return subtle.ConstantTimeCompare([]byte(computeFileHash([]byte(deleteToken))), []byte(hs.DeleteTokenHash)) == 1
+}
+
+// handleDelete serves DELETE /api/reviews, which names the share by its
+// delete token.
+func (ss *shareServer) handleDelete(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		DeleteToken string `json:"delete_token"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+		writeShareError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	token, _, _ := strings.Cut(body.DeleteToken, ".")
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	hs, err := ss.loadShare(token)
+	if err != nil {
+		writeLoadError(w, err)
+		return
+	}
+	if !ownsShare(hs, body.DeleteToken) {
+		writeShareError(w, http.StatusForbidden, "wrong delete token")
+		return
+	}
+	if err := ss.store.remove(token); err != nil {
+		slog.Error("serve-share: removing share", "err", err)
+		writeShareError(w, http.StatusInternalServerError, "could not delete review")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReview serves PUT /api/reviews/<token> (update) and the share's
+// /document and /comments.
+func (ss *shareServer) handleReview(w http.ResponseWriter, r *http.Request) {
+	token, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/reviews/"), "/")
+	switch {
+	case sub == "" && r.Method == http.MethodPut:
+		ss.handleUpdate(w, r, token)
+	case sub == "document" && r.Method == http.MethodGet:
+		hs, err := ss.loadShare(token)
+		if err != nil {
+			writeLoadError(w, err)
+			return
+		}
+		writeJSON(w, hs.shareDocument)
+	case sub == "comments" && r.Method == http.MethodGet:
+		hs, err := ss.loadShare(token)
+		if err != nil {
+			writeLoadError(w, err)
+			return
+		}
+		writeJSON(w, hostedShareComments(hs))
+	case sub == "comments" && r.Method == http.MethodPost:
+		ss.handleAddComment(w, r, token)
+	case sub == "" || sub == "document" || sub == "comments":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleUpdate replaces a share's files and comments with a new round. The
+// comments left on the share page are kept.
+func (ss *shareServer) handleUpdate(w http.ResponseWriter, r *http.Request, token string) {
+	if !ss.authorized(r) {
+		writeShareError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	up, err := decodeShareUpload(w, r)
+	if err != nil {
+		writeShareError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	hs, err := ss.loadShare(token)
+	if err != nil {
+		writeLoadError(w, err)
+		return
+	}
+	if !ownsShare(hs, up.DeleteToken) {
+		writeShareError(w, http.StatusForbidden, "wrong delete token")
+		return
+	}
+	old, _ := json.Marshal([]any{hs.Files, hs.Comments, hs.Encrypted})
+	updated, _ := json.Marshal([]any{up.Files, up.Comments, up.Encrypted})
+	changed := string(old) != string(updated)
+	if changed {
+		hs.Files, hs.Comments, hs.Encrypted, hs.Encryption = up.Files, up.Comments, up.Encrypted, up.Encryption
+		hs.ReviewRound++
+		if err := ss.saveShare(token, hs); err != nil {
+			slog.Error("serve-share: saving share", "err", err)
+			writeShareError(w, http.StatusInternalServerError, "could not save review")
+			return
+		}
+	}
+	writeJSON(w, map[string]any{"url": ss.link(token), "review_round": hs.ReviewRound, "changed": changed})
+}
+
+// hostedShareComments returns the comments GET /comments lists: those
+// uploaded with the share, which only the page can read on an encrypted
+// share, then those left on the page.
+func hostedShareComments(hs hostedShare) []webComment {
+	comments := make([]webComment, 0, len(hs.Comments)+len(hs.WebComments))
+	for _, c := range hs.Comments {
+		comments = append(comments, webComment{
+			Body:              c.Body,
+			FilePath:          c.File,
+			StartLine:         c.StartLine,
+			EndLine:           c.EndLine,
+			ReviewRound:       c.ReviewRound,
+			Resolved:          c.Resolved,
+			ExternalID:        c.ExternalID,
+			AuthorDisplayName: c.Author,
+			Quote:             c.Quote,
+			Scope:             c.Scope,
+		})
+	}
+	return append(comments, hs.WebComments...)
+}
+
+// handleAddComment serves POST /api/reviews/<token>/comments, a comment left
+// on the share page. Comments on an encrypted share must come sealed.
+func (ss *shareServer) handleAddComment(w http.ResponseWriter, r *http.Request, token string) {
+	if !ss.admitComment(w, r) {
+		return
+	}
+	var c webComment
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxShareComment)).Decode(&c); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeShareError(w, http.StatusRequestEntityTooLarge, "comment is too long")
+			return
+		}
+		writeShareError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	hs, err := ss.loadShare(token)
+	if err != nil {
+		writeLoadError(w, err)
+		return
+	}
+	switch {
+	case hs.Encrypted != "" && (c.Encrypted == "" || c.Body != ""):
+		writeShareError(w, http.StatusBadRequest, "comments on an encrypted review must be encrypted")
+		return
+	case hs.Encrypted == "" && strings.TrimSpace(c.Body) == "":
+		writeShareError(w, http.StatusBadRequest, "a comment needs a body")
+		return
+	case len(hs.WebComments) >= maxShareWebComments:
+		writeShareError(w, http.StatusTooManyRequests, "too many comments on this review")
+		return
+	}
+	if hs.Encrypted != "" {
+		c = webComment{Encrypted: c.Encrypted}
+	} else {
+		c.ExternalID = ""
+		c.ReviewRound = hs.ReviewRound
+	}
+	hs.WebComments = append(hs.WebComments, c)
+	if err := ss.saveShare(token, hs); err != nil {
+		slog.Error("serve-share: saving comment", "err", err)
+		writeShareError(w, http.StatusInternalServerError, "could not save comment")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(c)
+}
+
+// handlePage serves the share page, /r/<token>. It only checks the share
+// exists; the page fetches and renders it.
+func (ss *shareServer) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.URL.Path, "/r/")
+	if _, err := ss.loadShare(token); err != nil {
+		if errors.Is(err, errShareNotFound) {
+			http.Error(w, "This review doesn't exist, or it has expired or been unpublished.", http.StatusNotFound)
+			return
+		}
+		slog.Error("serve-share: loading share", "err", err)
+		http.Error(w, "Could not load review", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; script-src 'unsafe-inline'; style-src 'unsafe-inline'; connect-src 'self'")
+	if err := sharePageTemplate.Execute(w, token); err != nil {
+		slog.Error("serve-share: rendering page", "err", err)
+	}
+}
+
+// shareAuthTokens returns the auth tokens given with --auth-token and in
+// CRIT_SHARE_AUTH_TOKENS.
+func shareAuthTokens(flagTokens []string) []string {
+	tokens := flagTokens
+	for t := range strings.SplitSeq(os.Getenv(envShareAuthTokens), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+func printServeShareUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit serve-share [--listen <addr>] [--storage <dir>|s3://<bucket>[/<prefix>]]")
+	fmt.Fprintln(os.Stderr, "                        [--public-url <url>] [--trust-proxy] [--max-age <d>] [--auth-token <token>]...")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Runs a share service for crit share, crit fetch and the Share button: set")
+	fmt.Fprintln(os.Stderr, "share_url or CRIT_SHARE_URL to its public URL. Shares are kept in <dir>")
+	fmt.Fprintln(os.Stderr, "(~/.crit/shares by default) or an S3-compatible bucket, configured by the")
+	fmt.Fprintln(os.Stderr, "AWS_* environment variables (AWS_ENDPOINT_URL_S3 for non-AWS services).")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Options:")
+	fmt.Fprintln(os.Stderr, "  --listen <addr>      Address to listen on (default 127.0.0.1:8080)")
+	fmt.Fprintln(os.Stderr, "  --public-url <url>   Base of the share links, e.g. https://crit.example.com")
+	fmt.Fprintln(os.Stderr, "                       (required unless --listen is a loopback address)")
+	fmt.Fprintln(os.Stderr, "  --trust-proxy        Take client addresses, for rate limiting comments, from")
+	fmt.Fprintln(os.Stderr, "                       X-Forwarded-For; only behind a proxy that sets it")
+	fmt.Fprintln(os.Stderr, "  --max-age <d>        Longest a share lives, e.g. 30d; shorter expiries are kept")
+	fmt.Fprintln(os.Stderr, "  --auth-token <t>     Require this bearer token to create, update or delete")
+	fmt.Fprintln(os.Stderr, "                       shares (repeatable; also CRIT_SHARE_AUTH_TOKENS)")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "The share page decrypts encrypted shares in the browser, which needs HTTPS")
+	fmt.Fprintln(os.Stderr, "(or localhost): put the service behind a TLS-terminating proxy.")
+}
+
+// serveShareFlags holds the `crit serve-share` arguments.
+type serveShareFlags struct {
+	addr       string
+	storage    string
+	publicURL  string
+	maxAge     time.Duration
+	trustProxy bool
+	tokens     []string // --auth-token values
+}
+
+// parseServeShareFlags reads the `crit serve-share` arguments.
+func parseServeShareFlags(args []string) (serveShareFlags, error) {
+	f := serveShareFlags{addr: "127.0.0.1:8080", storage: "shares"}
+	if root, err := globalStorageRoot(); err == nil {
+		f.storage = filepath.Join(root, "shares")
+	}
+	var maxAge string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--listen", "--storage", "--public-url", "--max-age", "--auth-token":
+			if i+1 >= len(args) {
+				return f, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			switch arg {
+			case "--listen":
+				f.addr = args[i]
+			case "--storage":
+				f.storage = args[i]
+			case "--public-url":
+				f.publicURL = args[i]
+			case "--max-age":
+				maxAge = args[i]
+			default:
+				f.tokens = append(f.tokens, args[i])
+			}
+		case "--trust-proxy":
+			f.trustProxy = true
+		default:
+			return f, fmt.Errorf("unexpected argument %q", arg)
+		}
+	}
+	age, err := parseShareExpiry(maxAge)
+	if err != nil {
+		return f, fmt.Errorf("--max-age: %w", err)
+	}
+	f.maxAge = age
+	return f, nil
+}
+
+// runServeShare implements `crit serve-share`.
+func runServeShare(args []string) {
+	if slices.Contains(args, "--help") || slices.Contains(args, "-h") {
+		printServeShareUsage()
+		return
+	}
+	f, err := parseServeShareFlags(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		printServeShareUsage()
+		os.Exit(1)
+	}
+	store, err := openShareStore(f.storage)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: opening storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	listener, err := net.Listen("tcp", f.addr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	publicURL := f.publicURL
+	if publicURL == "" {
+		if publicURL, err = defaultPublicURL(listener.Addr()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	ss := newShareServer(store, publicURL)
+	ss.maxAge, ss.trustProxy, ss.authTokens = f.maxAge, f.trustProxy, shareAuthTokens(f.tokens)
+	fmt.Printf("Serving shares from %s on http://%s\n", f.storage, listener.Addr())
+	if len(ss.authTokens) == 0 {
+		fmt.Println("No auth tokens set: anyone who can reach it can share.")
+	}
+	httpServer := &http.Server{
+		Handler:           ss.handler(),
+		ReadHeaderTimeout: 15 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+	if err := httpServer.Serve(listener); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
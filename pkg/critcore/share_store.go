@@ -0,0 +1,235 @@
+package critcore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// shareStore keeps the shares `crit serve-share` hosts, one JSON document
+// per share token: in a directory, or in an S3-compatible bucket when the
+// storage is given as s3://<bucket>[/<prefix>].
+type shareStore interface {
+	load(token string) ([]byte, error) // errShareNotFound when there is none
+	save(token string, data []byte) error
+	remove(token string) error // removing a missing share is not an error
+}
+
+var errShareNotFound = errors.New("share not found")
+
+// openShareStore returns the store spec names: s3://<bucket>[/<prefix>] or
+// a directory.
+func openShareStore(spec string) (shareStore, error) {
+	if rest, ok := strings.CutPrefix(spec, "s3://"); ok {
+		return newS3ShareStore(rest)
+	}
+	if err := os.MkdirAll(spec, 0700); err != nil {
+		return nil, err
+	}
+	return dirShareStore{dir: spec}, nil
+}
+
+// dirShareStore keeps each share in <dir>/<token>.json.
+type dirShareStore struct {
+	dir string
+}
+
+func (d dirShareStore) path(token string) string {
+	return filepath.Join(d.dir, token+".json")
+}
+
+func (d dirShareStore) load(token string) ([]byte, error) {
+	data, err := os.ReadFile(d.path(token))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, errShareNotFound
+	}
+	return data, err
+}
+
+func (d dirShareStore) save(token string, data []byte) error {
+	return atomicWriteFile(d.path(token), data, 0600)
+}
+
+func (d dirShareStore) remove(token string) error {
+	if err := os.Remove(d.path(token)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// s3Credentials sign requests to an S3-compatible service.
+type s3Credentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// s3ShareStore keeps each share in the object <prefix>/<token>.json of a
+// bucket, addressed path-style (<endpoint>/<bucket>/<key>) so it works with
+// MinIO, R2 and the like as well as AWS. The endpoint, region and
+// credentials come from the usual AWS environment variables.
+type s3ShareStore struct {
+	endpoint string
+	bucket   string
+	prefix   string
+	region   string
+	creds    s3Credentials
+	client   *http.Client
+}
+
+func newS3ShareStore(spec string) (*s3ShareStore, error) {
+	bucket, prefix, _ := strings.Cut(spec, "/")
+	if bucket == "" {
+		return nil, errors.New("s3 storage needs a bucket: s3://<bucket>[/<prefix>]")
+	}
+	s := &s3ShareStore{
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+		region: firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		creds: s3Credentials{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	s.endpoint = strings.TrimSuffix(firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"), "/")
+	if s.endpoint == "" {
+		s.endpoint = "https://s3." + s.region + ".amazonaws.com"
+	}
+	if s.creds.accessKey == "" || s.creds.secretKey == "" {
+		return nil, errors.New("s3 storage needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return s, nil
+}
+
+// firstEnv returns the first of the environment variables names that is set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (s *s3ShareStore) objectURL(token string) string {
+	key := token + ".json"
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	return s.endpoint + "/" + s.bucket + "/" + key
+}
+
+func (s *s3ShareStore) do(method, token string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(token), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	signS3Request(req, body, s.creds, s.region, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s: %w", method, err)
+	}
+	return resp, nil
+}
+
+func (s *s3ShareStore) load(token string) ([]byte, error) {
+	resp, err := s.do(http.MethodGet, token, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errShareNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("s3 GET returned status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *s3ShareStore) save(token string, data []byte) error {
+	resp, err := s.do(http.MethodPut, token, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("s3 PUT returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *s3ShareStore) remove(token string) error {
+	resp, err := s.do(http.MethodDelete, token, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3 DELETE returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// signS3Request signs req with AWS Signature Version 4 for the s3 service,
+// covering the host and every header already set on req.
+func signS3Request(req *http.Request, payload []byte, creds s3Credentials, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	day := amzDate[:8]
+	payloadHash := computeFileHash(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	names := []string{"host"}
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		value := req.URL.Host
+		if name != "host" {
+			value = strings.TrimSpace(strings.Join(req.Header.Values(name), ","))
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+	signed := strings.Join(names, ";")
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, headers.String(), signed, payloadHash}, "\n")
+
+	scope := day + "/" + region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + computeFileHash([]byte(canonical))
+	key := []byte("AWS4" + creds.secretKey)
+	for _, part := range []string{day, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -1,7 +1,8 @@
-package main
+package critcore
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -296,7 +297,7 @@ func TestShareFilesToWeb_Success(t *testing.T) {
 	defer server.Close()
 
 	files := []shareFile{{Path: "plan.md", Content: "# Plan"}}
-	url, token, err := shareFilesToWeb(files, nil, server.URL, 1, "")
+	url, token, err := shareFilesToWeb(files, nil, server.URL, 1, "", shareOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -316,7 +317,7 @@ func TestShareFilesToWeb_ServerError(t *testing.T) {
 	defer server.Close()
 
 	files := []shareFile{{Path: "plan.md", Content: "# Plan"}}
-	_, _, err := shareFilesToWeb(files, nil, server.URL, 1, "")
+	_, _, err := shareFilesToWeb(files, nil, server.URL, 1, "", shareOptions{})
 	if err == nil {
 		t.Fatal("expected error for server error response")
 	}
@@ -324,7 +325,7 @@ func TestShareFilesToWeb_ServerError(t *testing.T) {
 
 func TestShareFilesToWeb_NetworkError(t *testing.T) {
 	files := []shareFile{{Path: "plan.md", Content: "# Plan"}}
-	_, _, err := shareFilesToWeb(files, nil, "http://localhost:1", 1, "")
+	_, _, err := shareFilesToWeb(files, nil, "http://localhost:1", 1, "", shareOptions{})
 	if err == nil {
 		t.Fatal("expected error for unreachable server")
 	}
@@ -447,7 +448,7 @@ func TestPersistShareState(t *testing.T) {
 	dir := t.TempDir()
 
 	// Persist to new .crit.json
-	err := persistShareState(filepath.Join(dir, ".crit.json"), "https://crit.md/r/abc", "tok_123", "")
+	err := persistShareState(filepath.Join(dir, ".crit.json"), "https://crit.md/r/abc", "tok_123", "", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -479,7 +480,7 @@ func TestPersistShareState_PreservesExisting(t *testing.T) {
 	os.WriteFile(filepath.Join(dir, ".crit.json"), data, 0644)
 
 	// Persist share state
-	err := persistShareState(filepath.Join(dir, ".crit.json"), "https://crit.md/r/def", "tok_456", "")
+	err := persistShareState(filepath.Join(dir, ".crit.json"), "https://crit.md/r/def", "tok_456", "", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -499,6 +500,29 @@ func TestPersistShareState_PreservesExisting(t *testing.T) {
 	}
 }
 
+func TestShareStateWriters_LeaveNewerSchemaAlone(t *testing.T) {
+	newer := `{"schema_version": 999, "files": {"plan.md": {"comments": []}}}`
+	writers := map[string]func(critPath string) error{
+		"persistShareState": func(p string) error { return persistShareState(p, "https://crit.md/r/abc", "dt", "", "") },
+		"updateShareState":  func(p string) error { return updateShareState(p, "hash", 2) },
+		"clearShareState":   clearShareState,
+		"mergeWebComments": func(p string) error {
+			return mergeWebComments(p, []webComment{{FilePath: "plan.md", StartLine: 1, EndLine: 1, Body: "hi"}})
+		},
+		"moveReviewFileEntry": func(p string) error { return moveReviewFileEntry(p, p, "plan.md", "plan-v2.md") },
+	}
+	for name, write := range writers {
+		critPath := filepath.Join(t.TempDir(), ".crit.json")
+		os.WriteFile(critPath, []byte(newer), 0644)
+		if err := write(critPath); !errors.Is(err, errNewerCritSchema) {
+			t.Errorf("%s: err = %v, want errNewerCritSchema", name, err)
+		}
+		if data, _ := os.ReadFile(critPath); string(data) != newer {
+			t.Errorf("%s overwrote the newer review file: %s", name, data)
+		}
+	}
+}
+
 func TestUpsertShareToWeb_CallsPUTOnChange(t *testing.T) {
 	putCalled := false
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1134,7 +1158,7 @@ func TestShareFilesToWeb_SendsBearerToken(t *testing.T) {
 	defer server.Close()
 
 	files := []shareFile{{Path: "plan.md", Content: "# Plan"}}
-	shareFilesToWeb(files, nil, server.URL, 1, "crit_testtoken")
+	shareFilesToWeb(files, nil, server.URL, 1, "crit_testtoken", shareOptions{})
 	if gotAuth != "Bearer crit_testtoken" {
 		t.Errorf("expected Authorization: Bearer crit_testtoken, got %q", gotAuth)
 	}
@@ -0,0 +1,214 @@
+package critcore
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ansiDim   = "\033[2m"
+	ansiGreen = "\033[32m"
+	ansiReset = "\033[0m"
+)
+
+// Status handles formatted terminal output for the crit review lifecycle.
+type Status struct {
+	w       io.Writer
+	color   bool
+	bell    bool   // ring on round-complete and finish (--bell / bell)
+	bellCmd string // run instead of writing the terminal bell (bell_command)
+	live    bool   // redraw the live status line in place (stderr is a terminal)
+	shown   string // last live line written in plain mode; "live" once drawn in place
+}
+
+// ring sounds the bell when enabled: bellCmd if set, otherwise BEL on w.
+func (s *Status) ring() {
+	if !s.bell {
+		return
+	}
+	fields := strings.Fields(s.bellCmd)
+	if len(fields) == 0 {
+		fmt.Fprint(s.w, "\a")
+		return
+	}
+	go func() {
+		if err := exec.Command(fields[0], fields[1:]...).Run(); err != nil {
+			slog.Warn("bell_command failed", "cmd", s.bellCmd, "err", err)
+		}
+	}()
+}
+
+func (s *Status) dim(text string) string {
+	if s.color {
+		return ansiDim + text + ansiReset
+	}
+	return text
+}
+
+func (s *Status) green(text string) string {
+	if s.color {
+		return ansiGreen + text + ansiReset
+	}
+	return text
+}
+
+func (s *Status) arrow() string {
+	return s.dim("→")
+}
+
+// Listening prints the server URL on startup.
+func (s *Status) Listening(url string) {
+	fmt.Fprintf(s.w, "  %s\n", s.dim("Listening on "+url))
+}
+
+// RoundFinished prints the round summary and finish confirmation.
+func (s *Status) RoundFinished(round, commentCount int, hasPrompt bool) {
+	if commentCount > 0 {
+		noun := "comments"
+		if commentCount == 1 {
+			noun = "comment"
+		}
+		fmt.Fprintf(s.w, "%s Round %d: %d %s added\n", s.arrow(), round, commentCount, noun)
+	}
+	if hasPrompt {
+		fmt.Fprintf(s.w, "%s Finish review — prompt copied %s\n", s.arrow(), s.green("✓"))
+	} else {
+		fmt.Fprintf(s.w, "%s Finish review\n", s.arrow())
+	}
+	s.ring()
+}
+
+// WaitingForAgent prints the waiting state.
+func (s *Status) WaitingForAgent() {
+	fmt.Fprintf(s.w, "%s %s\n", s.arrow(), s.dim("Waiting for agent…"))
+}
+
+// FileUpdated prints the edit detection summary. Skips output for 0 edits.
+func (s *Status) FileUpdated(editCount int) {
+	if editCount == 0 {
+		return
+	}
+	noun := "edits"
+	if editCount == 1 {
+		noun = "edit"
+	}
+	fmt.Fprintf(s.w, "%s %s\n", s.arrow(), s.dim(fmt.Sprintf("File updated (%d %s detected)", editCount, noun)))
+}
+
+// RoundReady prints the new round summary with resolved/open counts.
+func (s *Status) RoundReady(round, resolved, open int) {
+	line := fmt.Sprintf("Round %d: diff ready", round)
+	switch {
+	case resolved > 0 && open > 0:
+		line += " — " + s.green(fmt.Sprintf("%d resolved", resolved)) + fmt.Sprintf(", %d open", open)
+	case resolved > 0:
+		line += " — " + s.green(fmt.Sprintf("%d resolved", resolved))
+	case open > 0:
+		line += fmt.Sprintf(" — %d open", open)
+	}
+	fmt.Fprintf(s.w, "%s %s\n", s.arrow(), line)
+	s.ring()
+}
+
+// liveStatus is the session state behind the live status line, served by
+// /api/health as "session".
+type liveStatus struct {
+	Round        int    `json:"round"`
+	Open         int    `json:"open"`
+	Resolved     int    `json:"resolved"`
+	Browsers     int    `json:"browsers"`
+	Waiting      bool   `json:"waiting_for_agent"`
+	Finished     bool   `json:"finished"`
+	AgentStatus  string `json:"agent_status,omitempty"`  // the agent's last progress message
+	PendingRound string `json:"pending_round,omitempty"` // round-complete waiting for the browser's ack
+	LastActivity string `json:"last_activity,omitempty"` // RFC 3339
+}
+
+// LiveStatus reports the current round, comment counts and browser tabs.
+func (s *Session) LiveStatus() liveStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st := liveStatus{Round: max(s.ReviewRound, 1), Browsers: int(atomic.LoadInt32(&s.browserClients)), Waiting: s.waitingForAgent, Finished: s.finished, AgentStatus: s.agentStatus, PendingRound: s.pendingRound}
+	count := func(comments []Comment) {
+		for _, c := range comments {
+			if c.Resolved {
+				st.Resolved++
+			} else {
+				st.Open++
+			}
+		}
+	}
+	count(s.reviewComments)
+	for _, f := range s.Files {
+		count(f.Comments)
+	}
+	if !s.lastActivity.IsZero() {
+		st.LastActivity = s.lastActivity.UTC().Format(time.RFC3339)
+	}
+	return st
+}
+
+// liveLine renders st, e.g. "Round 2 · 3 open, 1 resolved · 1 browser ·
+// reviewing · active 2m ago".
+func liveLine(st liveStatus, now time.Time) string {
+	browsers := fmt.Sprintf("%d browsers", st.Browsers)
+	if st.Browsers == 1 {
+		browsers = "1 browser"
+	}
+	state := "reviewing"
+	switch {
+	case st.Finished:
+		state = "finished"
+	case st.PendingRound != "":
+		state = "next round waiting for the browser"
+	case st.Waiting && st.AgentStatus != "":
+		state = "agent: " + st.AgentStatus
+	case st.Waiting:
+		state = "waiting for agent"
+	}
+	line := fmt.Sprintf("Round %d · %d open, %d resolved · %s · %s", st.Round, st.Open, st.Resolved, browsers, state)
+	if t, err := time.Parse(time.RFC3339, st.LastActivity); err == nil {
+		line += " · active " + agoString(now.Sub(t))
+	}
+	return line
+}
+
+func agoString(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	default:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+}
+
+// Live shows st: redrawn in place on a terminal, or as a new line whenever
+// the state (not just the activity age) changes in plain mode.
+func (s *Status) Live(st liveStatus, now time.Time) {
+	if s.live {
+		fmt.Fprintf(s.w, "\r\033[K%s %s", s.arrow(), s.dim(liveLine(st, now)))
+		s.shown = "live"
+		return
+	}
+	st.LastActivity = ""
+	key := liveLine(st, now)
+	if key != s.shown {
+		s.shown = key
+		fmt.Fprintf(s.w, "%s %s\n", s.arrow(), key)
+	}
+}
+
+// EndLive clears the live line so later output starts on a clean line.
+func (s *Status) EndLive() {
+	if s.live && s.shown != "" {
+		fmt.Fprint(s.w, "\r\033[K")
+	}
+	s.shown = ""
+}
@@ -1,9 +1,10 @@
-package main
+package critcore
 
 import (
 	"bytes"
 	"strings"
 	"testing"
+	"time"
 )
 
 func testStatus() (*Status, *bytes.Buffer) {
@@ -140,3 +141,90 @@ func TestStatusColor_GreenInRoundReady(t *testing.T) {
 		t.Error("expected green ANSI code for resolved count")
 	}
 }
+
+func TestStatusBell(t *testing.T) {
+	s, buf := testStatus()
+	s.RoundReady(2, 0, 0)
+	if strings.Contains(buf.String(), "\a") {
+		t.Errorf("bell rung while disabled: %q", buf.String())
+	}
+	s.bell = true
+	buf.Reset()
+	s.RoundReady(2, 0, 0)
+	if want := "→ Round 2: diff ready\n\a"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	buf.Reset()
+	s.RoundFinished(1, 0, false)
+	if !strings.HasSuffix(buf.String(), "\a") {
+		t.Errorf("finish should ring: %q", buf.String())
+	}
+	s.bellCmd = "true"
+	buf.Reset()
+	s.RoundFinished(1, 0, false)
+	if strings.Contains(buf.String(), "\a") {
+		t.Errorf("bell_command should replace the terminal bell: %q", buf.String())
+	}
+}
+
+func TestDaemonBellStatus(t *testing.T) {
+	if daemonBellStatus(&serverConfig{bell: true}) != nil {
+		t.Error("the daemon has no terminal to ring without bell_command")
+	}
+	if daemonBellStatus(&serverConfig{bellCommand: "afplay x.aiff"}) != nil {
+		t.Error("bell_command without bell should stay silent")
+	}
+	if st := daemonBellStatus(&serverConfig{bell: true, bellCommand: "afplay x.aiff"}); st == nil || st.bellCmd != "afplay x.aiff" {
+		t.Errorf("status = %+v", st)
+	}
+}
+
+func TestLiveLine(t *testing.T) {
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	st := liveStatus{Round: 2, Open: 3, Resolved: 1, Browsers: 1, LastActivity: now.Add(-2 * time.Minute).Format(time.RFC3339)}
+	if got, want := liveLine(st, now), "Round 2 · 3 open, 1 resolved · 1 browser · reviewing · active 2m ago"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	st = liveStatus{Round: 1, Waiting: true}
+	if got, want := liveLine(st, now), "Round 1 · 0 open, 0 resolved · 0 browsers · waiting for agent"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	st.AgentStatus = "rewriting section 3"
+	if got, want := liveLine(st, now), "Round 1 · 0 open, 0 resolved · 0 browsers · agent: rewriting section 3"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	st.PendingRound = "round_1"
+	if got, want := liveLine(st, now), "Round 1 · 0 open, 0 resolved · 0 browsers · next round waiting for the browser"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	st = liveStatus{Round: 3, Resolved: 2, Finished: true}
+	if got, want := liveLine(st, now), "Round 3 · 0 open, 2 resolved · 0 browsers · finished"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestStatusLive_Plain(t *testing.T) {
+	s, buf := testStatus()
+	now := time.Now()
+	st := liveStatus{Round: 1, Open: 1, Browsers: 1, LastActivity: now.Format(time.RFC3339)}
+	s.Live(st, now)
+	s.Live(st, now.Add(5*time.Minute))
+	st.Open = 2
+	s.Live(st, now)
+	want := "→ Round 1 · 1 open, 0 resolved · 1 browser · reviewing\n→ Round 1 · 2 open, 0 resolved · 1 browser · reviewing\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestStatusLive_Redraws(t *testing.T) {
+	s, buf := testStatus()
+	s.live = true
+	s.Live(liveStatus{Round: 1}, time.Now())
+	s.Live(liveStatus{Round: 1, Open: 1}, time.Now())
+	s.EndLive()
+	want := "\r\033[K→ Round 1 · 0 open, 0 resolved · 0 browsers · reviewing\r\033[K→ Round 1 · 1 open, 0 resolved · 0 browsers · reviewing\r\033[K"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+package critcore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Storage layouts, selected with the "storage" config key.
+const (
+	storageGlobal  = "global"  // ~/.crit/{reviews,sessions,plans} (default)
+	storageProject = "project" // <project>/.crit/{reviews,sessions,plans}
+)
+
+var (
+	projectStorageOnce sync.Once
+	// projectStorage is <project>/.crit when the project layout is enabled,
+	// empty for the global layout. Resolved once per process.
+	projectStorage string
+	// storageSearchDir is where storageRoot starts looking for project
+	// config, set by useStorageConfigDir; "" means configProjectDir().
+	storageSearchDir string
+)
+
+// useStorageConfigDir makes storageRoot read the storage key from the project
+// config found from dir, the directory the caller passed to LoadConfig, so a
+// .crit.config.json next to a file argument decides the layout. Call it before
+// anything resolves a storage path.
+func useStorageConfigDir(dir string) {
+	storageSearchDir = dir
+}
+
+// storageRoot returns the directory holding crit's reviews/, sessions/ and
+// plans/ directories: ~/.crit by default, or <project>/.crit when the
+// "storage" config key is "project".
+func storageRoot() (string, error) {
+	projectStorageOnce.Do(func() {
+		dir := storageSearchDir
+		if dir == "" {
+			dir = configProjectDir()
+		}
+		projectStorage = resolveProjectStorage(dir)
+	})
+	if projectStorage != "" {
+		return projectStorage, nil
+	}
+	return globalStorageRoot()
+}
+
+// globalStorageRoot returns ~/.crit, the default (and legacy) layout.
+func globalStorageRoot() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding home directory: %w", err)
+	}
+	return filepath.Join(home, ".crit"), nil
+}
+
+// legacyStorageRoot returns ~/.crit when the project layout is active, so
+// reviews and daemons created before switching layouts are still found.
+// Returns "" when storage is already global (no separate legacy location).
+func legacyStorageRoot() string {
+	root, err := storageRoot()
+	if err != nil {
+		return ""
+	}
+	global, err := globalStorageRoot()
+	if err != nil || global == root {
+		return ""
+	}
+	return global
+}
+
+// configProjectDir returns the directory project config is read from: the
+// repository root, or the working directory outside a repository.
+func configProjectDir() string {
+	if vcs := DetectVCS(""); vcs != nil {
+		if root, err := vcs.RepoRoot(); err == nil && root != "" {
+			return root
+		}
+	}
+	cwd, _ := os.Getwd()
+	return cwd
+}
+
+// resolveProjectStorage reads only the "storage" key from the global config
+// and the project config LoadConfig would find from searchDir (project wins).
+// When the project layout is selected it returns .crit next to that project
+// config, or under configProjectDir() when there is none. It avoids
+// LoadConfig, which shells out to resolve the author, because storage paths
+// are needed on every invocation.
+func resolveProjectStorage(searchDir string) string {
+	if searchDir == "" {
+		return ""
+	}
+	configPath := findProjectConfig(searchDir)
+	global, _, _ := loadConfigFile(globalConfigPath())
+	project, _, _ := loadConfigFile(configPath)
+	storage := global.Storage
+	if project.Storage != "" {
+		storage = project.Storage
+	}
+	switch storage {
+	case "", storageGlobal:
+		return ""
+	case storageProject:
+		if _, err := os.Stat(configPath); err != nil {
+			return filepath.Join(configProjectDir(), ".crit")
+		}
+		return filepath.Join(filepath.Dir(configPath), ".crit")
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: unknown storage %q (want %q or %q), using global\n", storage, storageGlobal, storageProject)
+		return ""
+	}
+}
+
+// existsOrLegacy returns path, unless it doesn't exist and the same relative
+// location under the legacy root does — then the legacy path is returned so
+// existing reviews keep working after switching to the project layout.
+func existsOrLegacy(path string, rel ...string) string {
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	legacy := legacyStorageRoot()
+	if legacy == "" {
+		return path
+	}
+	legacyPath := filepath.Join(append([]string{legacy}, rel...)...)
+	if _, err := os.Stat(legacyPath); err == nil {
+		return legacyPath
+	}
+	return path
+}
+
+// sessionDirsForRead lists every sessions directory a live daemon's session
+// file may be in: the active layout first, then the legacy one.
+func sessionDirsForRead() []string {
+	var dirs []string
+	if dir, err := sessionsDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	if legacy := legacyStorageRoot(); legacy != "" {
+		dirs = append(dirs, filepath.Join(legacy, "sessions"))
+	}
+	return dirs
+}
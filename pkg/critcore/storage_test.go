@@ -0,0 +1,151 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withProjectStorage switches the process to the project storage layout rooted
+// at dir for the duration of the test.
+func withProjectStorage(t *testing.T, dir string) {
+	t.Helper()
+	projectStorageOnce.Do(func() {}) // keep storageRoot from resolving over us
+	old := projectStorage
+	projectStorage = dir
+	t.Cleanup(func() { projectStorage = old })
+}
+
+func TestResolveProjectStorage(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := t.TempDir()
+
+	if got := resolveProjectStorage(project); got != "" {
+		t.Errorf("no config: got %q, want global", got)
+	}
+
+	writeFile(t, filepath.Join(project, ".crit.config.json"), `{"storage": "project"}`)
+	if got, want := resolveProjectStorage(project), filepath.Join(project, ".crit"); got != want {
+		t.Errorf("project config: got %q, want %q", got, want)
+	}
+
+	// Project config overrides global.
+	writeFile(t, filepath.Join(home, ".crit.config.json"), `{"storage": "project"}`)
+	writeFile(t, filepath.Join(project, ".crit.config.json"), `{"storage": "global"}`)
+	if got := resolveProjectStorage(project); got != "" {
+		t.Errorf("project global over global project: got %q", got)
+	}
+
+	writeFile(t, filepath.Join(project, ".crit.config.json"), `{"storage": "elsewhere"}`)
+	if got := resolveProjectStorage(project); got != "" {
+		t.Errorf("unknown storage should fall back to global, got %q", got)
+	}
+}
+
+func TestResolveProjectStorage_NestedConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	docs := filepath.Join(repo, "docs")
+	plans := filepath.Join(docs, "plans")
+	if err := os.MkdirAll(plans, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(docs, ".crit.config.json"), `{"storage": "project"}`)
+
+	// Found by walking up from the file argument's directory, as LoadConfig
+	// does; storage goes next to that config.
+	if got, want := resolveProjectStorage(plans), filepath.Join(docs, ".crit"); got != want {
+		t.Errorf("from %s: got %q, want %q", plans, got, want)
+	}
+	if got := resolveProjectStorage(repo); got != "" {
+		t.Errorf("from repo root: got %q, want global", got)
+	}
+}
+
+func TestStorageRoot_GlobalDefault(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	withProjectStorage(t, "")
+
+	dir, err := reviewsDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, ".crit", "reviews"); dir != want {
+		t.Errorf("reviewsDir = %q, want %q", dir, want)
+	}
+	if legacyStorageRoot() != "" {
+		t.Error("global layout should have no legacy root")
+	}
+}
+
+func TestStorageRoot_ProjectLayout(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	proj := filepath.Join(t.TempDir(), ".crit")
+	withProjectStorage(t, proj)
+
+	sess, _ := sessionsDir()
+	if want := filepath.Join(proj, "sessions"); sess != want {
+		t.Errorf("sessionsDir = %q, want %q", sess, want)
+	}
+	plan, _ := planStorageDir("my-plan")
+	if want := filepath.Join(proj, "plans", "my-plan"); plan != want {
+		t.Errorf("planStorageDir = %q, want %q", plan, want)
+	}
+	rev, _ := reviewFilePath("abc123")
+	if want := filepath.Join(proj, "reviews", "abc123.json"); rev != want {
+		t.Errorf("reviewFilePath = %q, want %q", rev, want)
+	}
+}
+
+func TestReviewFilePath_LegacyFallback(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	proj := filepath.Join(t.TempDir(), ".crit")
+	withProjectStorage(t, proj)
+
+	legacy := filepath.Join(home, ".crit", "reviews", "old123.json")
+	writeFile(t, legacy, `{"files":{}}`)
+	if got, _ := reviewFilePath("old123"); got != legacy {
+		t.Errorf("existing legacy review: got %q, want %q", got, legacy)
+	}
+
+	// Once a project-layout file exists it takes precedence.
+	current := filepath.Join(proj, "reviews", "old123.json")
+	writeFile(t, current, `{"files":{}}`)
+	if got, _ := reviewFilePath("old123"); got != current {
+		t.Errorf("project review: got %q, want %q", got, current)
+	}
+}
+
+func TestReadSessionFile_LegacyFallback(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	withProjectStorage(t, filepath.Join(t.TempDir(), ".crit"))
+
+	legacyDir := filepath.Join(home, ".crit", "sessions")
+	writeFile(t, filepath.Join(legacyDir, "legacykey.json"), `{"pid": 7, "port": 9999, "cwd": "/repo"}`)
+
+	entry, err := readSessionFile("legacykey")
+	if err != nil {
+		t.Fatalf("readSessionFile: %v", err)
+	}
+	if entry.PID != 7 {
+		t.Errorf("PID = %d, want 7", entry.PID)
+	}
+
+	entries, keys := readAllSessionEntries()
+	if len(entries) != 1 || keys[0] != "legacykey" {
+		t.Errorf("readAllSessionEntries = %v %v", entries, keys)
+	}
+
+	removeSessionFile("legacykey")
+	if _, err := os.Stat(filepath.Join(legacyDir, "legacykey.json")); !os.IsNotExist(err) {
+		t.Error("removeSessionFile should remove legacy session files")
+	}
+}
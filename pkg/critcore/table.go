@@ -0,0 +1,187 @@
+package critcore
+
+import (
+	"encoding/csv"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxQuotedTableCells caps how many cells a single comment quotes in the
+// review file, so a comment spanning a large block doesn't copy the data.
+const maxQuotedTableCells = 100
+
+// reviewTable is a CSV/TSV file parsed for the /api/file table view. The first
+// record is treated as the header.
+type reviewTable struct {
+	Header   []string   `json:"header"`
+	Rows     [][]string `json:"rows"`
+	RowLines []int      `json:"row_lines"` // 1-based source line each row starts on
+
+	records [][]string
+	lines   [][2]int // first and last source line of each record, header included
+}
+
+// tableCell is one cell a comment refers to, quoted into the review file.
+// Row is 1-based over data rows; 0 is the header.
+type tableCell struct {
+	Row    int    `json:"row"`
+	Column string `json:"column"`
+	Value  string `json:"value"`
+}
+
+// tableCellRef is the cell a comment was made on (table_cell on POST
+// /api/file/comments): Row as in tableCell, Column by header name, so the
+// anchor follows its column when columns are reordered.
+type tableCellRef struct {
+	Row    int    `json:"row"`
+	Column string `json:"column"`
+}
+
+func isTablePath(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".tsv":
+		return true
+	}
+	return false
+}
+
+// parseTable parses content as CSV, or TSV for .tsv files. Ragged rows and
+// stray quotes are tolerated; a file that still fails to parse returns nil.
+func parseTable(path, content string) *reviewTable {
+	r := csv.NewReader(strings.NewReader(content))
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		r.Comma = '\t'
+	}
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	t := &reviewTable{Rows: [][]string{}, RowLines: []int{}}
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil
+		}
+		first, _ := r.FieldPos(0)
+		last, _ := r.FieldPos(len(rec) - 1)
+		last += strings.Count(rec[len(rec)-1], "\n")
+		t.records = append(t.records, rec)
+		t.lines = append(t.lines, [2]int{first, last})
+	}
+	if len(t.records) == 0 {
+		return nil
+	}
+	t.Header = t.records[0]
+	t.Rows = t.records[1:]
+	for _, l := range t.lines[1:] {
+		t.RowLines = append(t.RowLines, l[0])
+	}
+	return t
+}
+
+// addTableSnapshot adds the parsed table to a /api/file response for CSV/TSV
+// files that parse.
+func addTableSnapshot(snap map[string]any, path, content string) {
+	if !isTablePath(path) {
+		return
+	}
+	if t := parseTable(path, content); t != nil {
+		snap["table"] = t
+	}
+}
+
+// column returns the header name for column i, or "column N" past the header.
+func (t *reviewTable) column(i int) string {
+	if i < len(t.Header) && t.Header[i] != "" {
+		return t.Header[i]
+	}
+	return "column " + strconv.Itoa(i+1)
+}
+
+// cell returns the column index of ref in its row, if both still exist.
+func (t *reviewTable) cell(ref tableCellRef) (int, bool) {
+	if ref.Row < 0 || ref.Row >= len(t.records) {
+		return 0, false
+	}
+	for i := range t.records[ref.Row] {
+		if t.column(i) == ref.Column {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// relocateTableCell returns c's cell anchor moved to the row holding line (c's
+// remapped start), and that row's lines. The column is kept by name; ok is
+// false when no row holds line or the column is gone from it.
+func relocateTableCell(t *reviewTable, c Comment, line int) (ref tableCellRef, start, end int, ok bool) {
+	if c.TableCell == nil || t == nil {
+		return tableCellRef{}, 0, 0, false
+	}
+	for idx, l := range t.lines {
+		if l[0] <= line && line <= l[1] {
+			ref = tableCellRef{Row: idx, Column: c.TableCell.Column}
+			_, ok = t.cell(ref)
+			return ref, l[0], l[1], ok
+		}
+	}
+	return tableCellRef{}, 0, 0, false
+}
+
+// anchorTableComments fills TableCells on line comments with the cells their
+// lines cover. A comment made on a cell (TableCell) quotes just that cell; a
+// single-row comment that quotes part of the row is narrowed to the cells the
+// quote overlaps.
+func anchorTableComments(comments []Comment, t *reviewTable) {
+	for i := range comments {
+		c := &comments[i]
+		c.TableCells = nil
+		if c.Scope == "file" || c.Side == "old" || c.StartLine <= 0 {
+			continue
+		}
+		if c.TableCell != nil {
+			if col, ok := t.cell(*c.TableCell); ok {
+				c.TableCells = []tableCell{{Row: c.TableCell.Row, Column: c.TableCell.Column, Value: t.records[c.TableCell.Row][col]}}
+				continue
+			}
+		}
+		var rows []int
+		for idx, l := range t.lines {
+			if l[1] >= c.StartLine && l[0] <= c.EndLine {
+				rows = append(rows, idx)
+			}
+		}
+		for _, idx := range rows {
+			cols := quotedColumns(t.records[idx], c.Quote, len(rows) == 1)
+			for _, col := range cols {
+				if len(c.TableCells) == maxQuotedTableCells {
+					break
+				}
+				c.TableCells = append(c.TableCells, tableCell{Row: idx, Column: t.column(col), Value: t.records[idx][col]})
+			}
+			if len(c.TableCells) == maxQuotedTableCells {
+				break
+			}
+		}
+	}
+}
+
+// quotedColumns returns the columns of rec a comment refers to: those the
+// quote overlaps when narrowing applies and something matches, else all.
+func quotedColumns(rec []string, quote string, narrow bool) []int {
+	quote = strings.TrimSpace(quote)
+	var cols, all []int
+	for i, v := range rec {
+		all = append(all, i)
+		if narrow && quote != "" && v != "" && (strings.Contains(quote, v) || strings.Contains(v, quote)) {
+			cols = append(cols, i)
+		}
+	}
+	if len(cols) == 0 {
+		return all
+	}
+	return cols
+}
@@ -0,0 +1,136 @@
+package critcore
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+const testCSV = "id,name,score\n1,alice,90\n2,\"bob\nsmith\",75\n3,carol,88\n"
+
+func TestParseTable(t *testing.T) {
+	tbl := parseTable("scores.csv", testCSV)
+	if tbl == nil {
+		t.Fatal("parseTable returned nil")
+	}
+	if len(tbl.Header) != 3 || tbl.Header[2] != "score" || len(tbl.Rows) != 3 {
+		t.Fatalf("table = %+v", tbl)
+	}
+	if got := tbl.RowLines; len(got) != 3 || got[0] != 2 || got[1] != 3 || got[2] != 5 {
+		t.Errorf("RowLines = %v, want [2 3 5]", got)
+	}
+	tsv := parseTable("x.TSV", "a\tb\n1\t2\n")
+	if tsv == nil || len(tsv.Rows) != 1 || tsv.Rows[0][1] != "2" {
+		t.Errorf("tsv = %+v", tsv)
+	}
+}
+
+func TestAnchorTableComments(t *testing.T) {
+	tbl := parseTable("scores.csv", testCSV)
+	comments := []Comment{
+		{StartLine: 2, EndLine: 2, Quote: "90"},   // one cell
+		{StartLine: 4, EndLine: 5},                // second line of bob's row through carol
+		{StartLine: 1, EndLine: 1, Quote: "nope"}, // unmatched quote: whole header
+		{Scope: "file"},
+	}
+	anchorTableComments(comments, tbl)
+
+	if got := comments[0].TableCells; len(got) != 1 || got[0] != (tableCell{Row: 1, Column: "score", Value: "90"}) {
+		t.Errorf("quoted cell = %+v", got)
+	}
+	if got := comments[1].TableCells; len(got) != 6 || got[0].Row != 2 || got[1].Value != "bob\nsmith" || got[5] != (tableCell{Row: 3, Column: "score", Value: "88"}) {
+		t.Errorf("row span = %+v", got)
+	}
+	if got := comments[2].TableCells; len(got) != 3 || got[0].Row != 0 {
+		t.Errorf("header = %+v", got)
+	}
+	if comments[3].TableCells != nil {
+		t.Error("file comments get no cells")
+	}
+
+	// A cell anchor follows its column by name.
+	moved := parseTable("scores.csv", "score,id,name\n90,1,alice\n")
+	anchored := []Comment{{StartLine: 2, EndLine: 2, TableCell: &tableCellRef{Row: 1, Column: "name"}}}
+	anchorTableComments(anchored, moved)
+	if got := anchored[0].TableCells; len(got) != 1 || got[0] != (tableCell{Row: 1, Column: "name", Value: "alice"}) {
+		t.Errorf("anchored cell = %+v", got)
+	}
+}
+
+func TestPostFileComment_TableCell(t *testing.T) {
+	s, session := newTestServer(t)
+	f := session.Files[0]
+	f.Path = "scores.csv"
+	f.FileType = "code"
+	f.Content = testCSV
+
+	post := func(body string) (int, Comment) {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/api/file/comments?path=scores.csv", strings.NewReader(body)))
+		var c Comment
+		_ = json.Unmarshal(w.Body.Bytes(), &c)
+		return w.Code, c
+	}
+	if code, _ := post(`{"body":"x","table_cell":{"row":2,"column":"nope"}}`); code != 400 {
+		t.Errorf("unknown column: status %d, want 400", code)
+	}
+	code, c := post(`{"body":"full name?","table_cell":{"row":2,"column":"name"}}`)
+	if code != 201 || c.StartLine != 3 || c.EndLine != 4 || c.TableCell == nil || *c.TableCell != (tableCellRef{Row: 2, Column: "name"}) {
+		t.Fatalf("status %d, comment %+v", code, c)
+	}
+
+	// A row inserted above: the anchor moves down with bob's row.
+	session.mu.Lock()
+	f.PreviousContent = f.Content
+	f.PreviousComments = append([]Comment(nil), f.Comments...)
+	f.Content = "id,name,score\n0,zed,10\n1,alice,90\n2,\"bob\nsmith\",75\n3,carol,88\n"
+	session.mu.Unlock()
+	session.carryForwardComments()
+
+	got := session.GetComments("scores.csv")
+	if len(got) != 1 || got[0].StartLine != 4 || got[0].EndLine != 5 || got[0].TableCell == nil || got[0].TableCell.Row != 3 {
+		t.Fatalf("carried = %+v", got)
+	}
+	anchorTableComments(got, parseTable("scores.csv", f.Content))
+	if cells := got[0].TableCells; len(cells) != 1 || cells[0].Value != "bob\nsmith" {
+		t.Errorf("table_cells = %+v", cells)
+	}
+}
+
+func TestHandleFile_Table(t *testing.T) {
+	s, session := newTestServer(t)
+	f := session.Files[0]
+	f.Path = "scores.csv"
+	f.FileType = "code"
+	f.Content = testCSV
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/file?path=scores.csv", nil))
+	var resp struct {
+		Table *reviewTable `json:"table"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Table == nil || len(resp.Table.Rows) != 3 {
+		t.Errorf("table = %+v", resp.Table)
+	}
+
+	// The review file quotes the referenced cells.
+	session.AddComment("scores.csv", 3, 3, "", "typo in the name", "bob", "")
+	session.WriteFiles()
+	data, err := os.ReadFile(session.critJSONPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cj CritJSON
+	if err := json.Unmarshal(data, &cj); err != nil {
+		t.Fatal(err)
+	}
+	cells := cj.Files["scores.csv"].Comments[0].TableCells
+	if len(cells) != 1 || cells[0].Column != "name" {
+		t.Errorf("table_cells = %+v", cells)
+	}
+}
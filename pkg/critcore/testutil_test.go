@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"os"
@@ -8,6 +8,18 @@ import (
 	"testing"
 )
 
+// The crit binary embeds frontend/ and integrations/ and passes them to Main;
+// tests read them from the repo root instead. The path is made absolute up
+// front because some tests change directory.
+func init() {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	if err != nil {
+		panic(err)
+	}
+	frontendFS = os.DirFS(root)
+	integrationsFS = frontendFS
+}
+
 // initTestRepo creates a temp directory with a git repo and returns the path.
 // The repo has an initial commit on the "main" branch.
 func initTestRepo(t *testing.T) string {
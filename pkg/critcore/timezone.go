@@ -0,0 +1,41 @@
+package critcore
+
+import (
+	"fmt"
+	"time"
+	_ "time/tzdata" // zone names resolve without a system zoneinfo database (Windows)
+)
+
+// Review files keep their timestamps in UTC (RFC 3339). The timezone config
+// key picks the zone they're shown in: the history export writes its times
+// there, and /api/settings hands it to the review UI. Empty uses the
+// machine's zone.
+
+// displayTimeLayout is how the history export writes a time.
+const displayTimeLayout = "2006-01-02 15:04 MST"
+
+// loadTimezone resolves the timezone config key: an IANA name, "UTC" or
+// "Local"; empty is the machine's zone.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("timezone: unknown zone %q (want an IANA name such as \"Europe/Berlin\", or \"UTC\")", name)
+	}
+	return loc, nil
+}
+
+// displayTime formats the RFC 3339 timestamp ts in loc (nil for the
+// machine's zone), or returns "" when ts doesn't parse.
+func displayTime(ts string, loc *time.Location) string {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return ""
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	return t.In(loc).Format(displayTimeLayout)
+}
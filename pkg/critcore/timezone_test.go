@@ -0,0 +1,37 @@
+package critcore
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadTimezone(t *testing.T) {
+	if loc, err := loadTimezone(""); err != nil || loc != time.Local {
+		t.Errorf("empty = %v, %v", loc, err)
+	}
+	if loc, err := loadTimezone("Asia/Tokyo"); err != nil || loc.String() != "Asia/Tokyo" {
+		t.Errorf("Asia/Tokyo = %v, %v", loc, err)
+	}
+	if _, err := loadTimezone("Mars/Olympus"); err == nil {
+		t.Error("accepted an unknown zone")
+	}
+}
+
+func TestDisplayTime(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	if got := displayTime("2026-03-01T23:30:00Z", tokyo); got != "2026-03-02 08:30 JST" {
+		t.Errorf("displayTime = %q", got)
+	}
+	if got := displayTime("yesterday", tokyo); got != "" {
+		t.Errorf("unparsable = %q", got)
+	}
+}
+
+func TestRenderReviewHistory_UpdatedInZone(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	got := renderReviewHistory(CritJSON{ReviewRound: 1, UpdatedAt: "2026-03-01T23:30:00Z"}, "", tokyo)
+	if !strings.HasPrefix(got, "# Review history\n\n_Updated 2026-03-02 08:30 JST_\n\n## Round 1") {
+		t.Errorf("got:\n%s", got)
+	}
+}
@@ -0,0 +1,258 @@
+package critcore
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// crit triage walks the review's open comments in the terminal one at a
+// time, in the order the agent reads them (orderedComments), so a large
+// round can be cleaned up before it goes back: resolve what's moot,
+// reclassify severities, reword, or leave a comment for later. The review
+// file is written once at the end, and a running daemon picks the changes up
+// like any other external edit.
+
+// triageResult counts what a triage pass did.
+type triageResult struct {
+	resolved, reclassified, edited, deferred int
+}
+
+func (r triageResult) changed() bool {
+	return r.resolved+r.reclassified+r.edited > 0
+}
+
+func (r triageResult) summary() string {
+	return fmt.Sprintf("Resolved %d, reclassified %d, edited %d, deferred %d.", r.resolved, r.reclassified, r.edited, r.deferred)
+}
+
+// triageComments asks about each open comment in cj on out, reading answers
+// from in, and applies them to cj. edit returns the new body for a comment.
+// Comments not reached before quitting count as deferred.
+func triageComments(cj *CritJSON, in *bufio.Reader, out io.Writer, edit func(body string) (string, error)) triageResult {
+	var open []locatedComment
+	for _, lc := range orderedComments(*cj) {
+		if !lc.c.Resolved {
+			open = append(open, lc)
+		}
+	}
+	var res triageResult
+	for i, lc := range open {
+		fmt.Fprintf(out, "\n[%d/%d] %s\n", i+1, len(open), triageHeader(lc))
+		for line := range strings.SplitSeq(lc.c.Body, "\n") {
+			fmt.Fprintf(out, "  %s\n", line)
+		}
+		c, done := triageComment(lc.c, in, out, edit, &res)
+		if c.Resolved != lc.c.Resolved || c.Severity != lc.c.Severity || c.Body != lc.c.Body {
+			c.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+			replaceComment(cj, lc.file, c)
+		}
+		if done {
+			res.deferred += len(open) - i - 1
+			break
+		}
+	}
+	return res
+}
+
+// triageComment asks what to do with c until it is resolved, deferred or
+// the user quits, and returns the updated comment and whether to stop.
+func triageComment(c Comment, in *bufio.Reader, out io.Writer, edit func(string) (string, error), res *triageResult) (Comment, bool) {
+	for {
+		switch askLine(in, out, "Resolve, change severity, edit, defer or quit? [r/s/e/D/q]", "d") {
+		case "r", "resolve":
+			c.Resolved = true
+			res.resolved++
+			return c, false
+		case "s", "severity":
+			severity := askLine(in, out, fmt.Sprintf("Severity [%s/none]:", strings.Join(severityLevels, "/")), c.Severity)
+			if severity == "none" {
+				severity = ""
+			}
+			if err := checkSeverity(severity); err != nil {
+				fmt.Fprintf(out, "  %v\n", err)
+				continue
+			}
+			if severity != c.Severity {
+				c.Severity = severity
+				res.reclassified++
+			}
+		case "e", "edit":
+			body, err := edit(c.Body)
+			if err != nil {
+				fmt.Fprintf(out, "  %v\n", err)
+				continue
+			}
+			if body = strings.TrimSpace(body); body != "" && body != c.Body {
+				c.Body = body
+				res.edited++
+			}
+		case "d", "defer":
+			res.deferred++
+			return c, false
+		case "q", "quit":
+			res.deferred++
+			return c, true
+		default:
+			fmt.Fprintln(out, "  Answer r, s, e, d or q.")
+		}
+	}
+}
+
+// triageHeader is the "path:lines · severity · author" line above a comment.
+func triageHeader(lc locatedComment) string {
+	loc := "Review comment"
+	switch {
+	case lc.file == "":
+	case lc.c.Scope == "file" || lc.c.StartLine <= 0:
+		loc = lc.file
+	case lc.c.EndLine > lc.c.StartLine:
+		loc = fmt.Sprintf("%s:%d-%d", lc.file, lc.c.StartLine, lc.c.EndLine)
+	default:
+		loc = fmt.Sprintf("%s:%d", lc.file, lc.c.StartLine)
+	}
+	parts := []string{loc}
+	if lc.c.Severity != "" {
+		parts = append(parts, lc.c.Severity)
+	}
+	if lc.c.Author != "" {
+		parts = append(parts, lc.c.Author)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// replaceComment swaps the comment with c's ID in file (the review comments
+// when file is "") for c.
+func replaceComment(cj *CritJSON, file string, c Comment) {
+	match := func(m Comment) bool { return m.ID == c.ID }
+	if file == "" {
+		if i := slices.IndexFunc(cj.ReviewComments, match); i >= 0 {
+			cj.ReviewComments[i] = c
+		}
+		return
+	}
+	if i := slices.IndexFunc(cj.Files[file].Comments, match); i >= 0 {
+		cj.Files[file].Comments[i] = c
+	}
+}
+
+// lineEditor edits a comment body by reading a replacement line from in. An
+// empty line keeps the body.
+func lineEditor(in *bufio.Reader, out io.Writer) func(string) (string, error) {
+	return func(body string) (string, error) {
+		fmt.Fprint(out, "New text (empty keeps it): ")
+		line, _ := in.ReadString('\n')
+		if line = strings.TrimSpace(line); line == "" {
+			return body, nil
+		}
+		return line, nil
+	}
+}
+
+// externalEditor edits a comment body in editor (from $VISUAL or $EDITOR).
+func externalEditor(editor string) func(string) (string, error) {
+	return func(body string) (string, error) {
+		f, err := os.CreateTemp("", "crit-comment-*.md")
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(body + "\n")
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", err
+		}
+		parts := strings.Fields(editor)
+		cmd := exec.Command(parts[0], append(parts[1:], f.Name())...)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("running %s: %w", parts[0], err)
+		}
+		data, err := os.ReadFile(f.Name())
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+func printTriageUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: crit triage [--output <dir>] [--plan <slug>]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Steps through the review's open comments one at a time: resolve, change the")
+	fmt.Fprintln(os.Stderr, "severity, edit (in $VISUAL or $EDITOR when set) or defer each. Changes are")
+	fmt.Fprintln(os.Stderr, "saved to the review file when you reach the end or quit.")
+}
+
+// parseTriageFlags reads --output and --plan.
+func parseTriageFlags(args []string) (commentFlags, error) {
+	var f commentFlags
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "--output", "-o", "--plan":
+			if i+1 >= len(args) {
+				return f, fmt.Errorf("%s requires a value", arg)
+			}
+			i++
+			if arg == "--plan" {
+				f.plan = args[i]
+			} else {
+				f.outputDir = args[i]
+			}
+		default:
+			return f, fmt.Errorf("unexpected argument %q", arg)
+		}
+	}
+	return f, nil
+}
+
+func runTriage(args []string) {
+	if slices.Contains(args, "--help") || slices.Contains(args, "-h") {
+		printTriageUsage()
+		return
+	}
+	f, err := parseTriageFlags(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n\n", err)
+		printTriageUsage()
+		os.Exit(1)
+	}
+	resolveCommentFlags(&f)
+	critPath, err := resolveReviewPath(f.outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	cj, err := loadCritJSON(critPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !slices.ContainsFunc(orderedComments(cj), func(lc locatedComment) bool { return !lc.c.Resolved }) {
+		fmt.Println("No open comments to triage.")
+		return
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	edit := lineEditor(in, os.Stdout)
+	if editor := cmp.Or(os.Getenv("VISUAL"), os.Getenv("EDITOR")); strings.TrimSpace(editor) != "" {
+		edit = externalEditor(editor)
+	}
+	res := triageComments(&cj, in, os.Stdout, edit)
+	if res.changed() {
+		cj.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+		if err := saveCritJSON(critPath, cj); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Println("\n" + res.summary())
+}
@@ -0,0 +1,81 @@
+package critcore
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func triageFixture() CritJSON {
+	return CritJSON{
+		ReviewComments: []Comment{{ID: "r1", Body: "Overall looks fine"}},
+		Files: map[string]CritJSONFile{
+			"a.go": {Comments: []Comment{
+				{ID: "c1", StartLine: 3, EndLine: 3, Body: "Rename this", Severity: "nit"},
+				{ID: "c2", StartLine: 8, EndLine: 12, Body: "Missing error check"},
+				{ID: "c3", StartLine: 20, EndLine: 20, Body: "Already fixed", Resolved: true},
+			}},
+		},
+	}
+}
+
+func TestTriageComments(t *testing.T) {
+	cj := triageFixture()
+	// r1: defer. c1: bad severity, then major, then resolve. c2: edit, then quit.
+	in := bufio.NewReader(strings.NewReader("\ns\nhuge\ns\nmajor\nr\ne\nq\n"))
+	edit := func(body string) (string, error) { return body + " (and log it)\n", nil }
+	res := triageComments(&cj, in, io.Discard, edit)
+
+	if res != (triageResult{resolved: 1, reclassified: 1, edited: 1, deferred: 2}) {
+		t.Errorf("result = %+v", res)
+	}
+	c1, c2 := cj.Files["a.go"].Comments[0], cj.Files["a.go"].Comments[1]
+	if !c1.Resolved || c1.Severity != "major" || c1.UpdatedAt == "" {
+		t.Errorf("c1 = %+v", c1)
+	}
+	if c2.Body != "Missing error check (and log it)" || c2.Resolved {
+		t.Errorf("c2 = %+v", c2)
+	}
+	if r1 := cj.ReviewComments[0]; r1.Resolved || r1.UpdatedAt != "" {
+		t.Errorf("deferred comment changed: %+v", r1)
+	}
+}
+
+func TestTriageComments_EndOfInputDefers(t *testing.T) {
+	cj := triageFixture()
+	in := bufio.NewReader(strings.NewReader(""))
+	res := triageComments(&cj, in, io.Discard, lineEditor(in, io.Discard))
+	if res.changed() || res.deferred != 3 {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestTriageHeader(t *testing.T) {
+	tests := []struct {
+		lc   locatedComment
+		want string
+	}{
+		{locatedComment{c: Comment{Author: "ana"}}, "Review comment · ana"},
+		{locatedComment{file: "a.go", c: Comment{StartLine: 4, EndLine: 4, Severity: "major"}}, "a.go:4 · major"},
+		{locatedComment{file: "a.go", c: Comment{StartLine: 4, EndLine: 9}}, "a.go:4-9"},
+		{locatedComment{file: "a.go", c: Comment{Scope: "file"}}, "a.go"},
+	}
+	for _, tt := range tests {
+		if got := triageHeader(tt.lc); got != tt.want {
+			t.Errorf("triageHeader(%+v) = %q, want %q", tt.lc, got, tt.want)
+		}
+	}
+}
+
+func TestParseTriageFlags(t *testing.T) {
+	f, err := parseTriageFlags([]string{"-o", "out", "--plan", "auth"})
+	if err != nil || f.outputDir != "out" || f.plan != "auth" {
+		t.Errorf("flags = %+v, err = %v", f, err)
+	}
+	for _, bad := range [][]string{{"--output"}, {"extra"}} {
+		if _, err := parseTriageFlags(bad); err == nil {
+			t.Errorf("parseTriageFlags(%q) should fail", bad)
+		}
+	}
+}
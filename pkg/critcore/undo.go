@@ -0,0 +1,188 @@
+package critcore
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// The undo journal records comment actions made through the API (create,
+// edit, delete, resolve) so POST /api/undo and POST /api/redo can step back
+// and forth through them; the review UI binds them to Ctrl+Z and
+// Ctrl+Shift+Z. It lives in memory and covers the current round only:
+// carry-forward gives every comment a new ID, so round-complete clears it.
+// Changes made elsewhere (agent replies, edits to the review file) don't
+// block a step, but one whose comment is gone, or already back, is dropped
+// with a 409.
+
+// maxJournal is how many actions the journal keeps for undo.
+const maxJournal = 100
+
+// Journal operations.
+const (
+	journalCreate  = "create"
+	journalEdit    = "edit"
+	journalDelete  = "delete"
+	journalResolve = "resolve"
+)
+
+var (
+	errNothingToStep = errors.New("nothing to step through")
+	errJournalStale  = errors.New("the comment has changed since; the step was dropped")
+)
+
+// journalEntry is one recorded action.
+type journalEntry struct {
+	Op   string `json:"op"`
+	Path string `json:"path,omitempty"` // "" for a review comment
+	ID   string `json:"id"`
+	// other holds the comment as it is on the other side of the step: all
+	// of it for create and delete, the body for edit, the flag for resolve.
+	other Comment
+	index int // create and delete: the comment's place in its list
+}
+
+// undoJournal is the actions that can be undone, latest last, and those
+// undone since the last new action, which can be redone.
+type undoJournal struct {
+	undo, redo []journalEntry
+}
+
+// recordLocked adds an action to the journal, dropping the redo steps.
+// Callers hold s.mu.
+func (s *Session) recordLocked(e journalEntry) {
+	s.journal.undo = append(s.journal.undo, e)
+	if n := len(s.journal.undo); n > maxJournal {
+		s.journal.undo = slices.Delete(s.journal.undo, 0, n-maxJournal)
+	}
+	s.journal.redo = nil
+}
+
+// clearJournalLocked forgets every action. Callers hold s.mu.
+func (s *Session) clearJournalLocked() {
+	s.journal = undoJournal{}
+}
+
+// Undo reverts the latest action and returns it.
+func (s *Session) Undo() (journalEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stepJournalLocked(&s.journal.undo, &s.journal.redo, true)
+}
+
+// Redo applies the latest undone action again and returns it.
+func (s *Session) Redo() (journalEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stepJournalLocked(&s.journal.redo, &s.journal.undo, false)
+}
+
+// canStep reports whether there is anything to undo and to redo.
+func (s *Session) canStep() (undo, redo bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.journal.undo) > 0, len(s.journal.redo) > 0
+}
+
+// stepJournalLocked pops the latest entry from from, applies it (reverting
+// it when undo is set) and pushes it onto to. Callers hold s.mu.
+func (s *Session) stepJournalLocked(from, to *[]journalEntry, undo bool) (journalEntry, error) {
+	if len(*from) == 0 {
+		return journalEntry{}, errNothingToStep
+	}
+	e := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+
+	list := &s.reviewComments
+	if e.Path != "" {
+		f := s.fileByPathLocked(e.Path)
+		if f == nil {
+			return e, errJournalStale
+		}
+		list = &f.Comments
+	}
+	if err := s.applyStepLocked(list, &e, undo); err != nil {
+		return e, err
+	}
+	if i := slices.IndexFunc(*list, func(c Comment) bool { return c.ID == e.ID }); i >= 0 {
+		s.walPutLocked(e.Path, (*list)[i])
+	} else {
+		s.walDeleteLocked(e.Path, e.ID)
+	}
+	*to = append(*to, e)
+	s.scheduleWrite()
+	return e, nil
+}
+
+// applyStepLocked applies e to list, the comments it is about, leaving in
+// e.other what it replaced. Callers hold s.mu.
+func (s *Session) applyStepLocked(list *[]Comment, e *journalEntry, undo bool) error {
+	i := slices.IndexFunc(*list, func(c Comment) bool { return c.ID == e.ID })
+	// Undoing a delete and redoing a create put the comment back; every
+	// other step needs it there.
+	inserts := (e.Op == journalDelete && undo) || (e.Op == journalCreate && !undo)
+	if inserts != (i < 0) {
+		return errJournalStale
+	}
+	switch {
+	case inserts:
+		*list = slices.Insert(*list, min(e.index, len(*list)), e.other)
+		delete(s.deletedCommentIDs[e.Path], e.ID)
+	case e.Op == journalCreate || e.Op == journalDelete:
+		e.other, e.index = (*list)[i], i
+		*list = slices.Delete(*list, i, i+1)
+		if e.Path != "" {
+			s.trackDeletedComment(e.Path, e.ID)
+		}
+	case e.Op == journalEdit:
+		c := &(*list)[i]
+		c.Body, e.other.Body = e.other.Body, c.Body
+		c.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	default:
+		c := &(*list)[i]
+		c.Resolved, e.other.Resolved = e.other.Resolved, c.Resolved
+		c.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	return nil
+}
+
+// journalResponse is the answer to POST /api/undo and /api/redo.
+type journalResponse struct {
+	journalEntry
+	CanUndo bool `json:"can_undo"`
+	CanRedo bool `json:"can_redo"`
+}
+
+// handleUndo serves POST /api/undo.
+func (s *Server) handleUndo(w http.ResponseWriter, r *http.Request) {
+	s.stepJournal(w, r, "undo", (*Session).Undo)
+}
+
+// handleRedo serves POST /api/redo.
+func (s *Server) handleRedo(w http.ResponseWriter, r *http.Request) {
+	s.stepJournal(w, r, "redo", (*Session).Redo)
+}
+
+// stepJournal runs step, tells every open review UI to reload its comments
+// and answers with the step taken. An empty or stale journal gets a 409.
+func (s *Server) stepJournal(w http.ResponseWriter, r *http.Request, what string, step func(*Session) (journalEntry, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sess := s.session.Load()
+	e, err := step(sess)
+	if errors.Is(err, errNothingToStep) {
+		http.Error(w, "Nothing to "+what, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	sess.notify(SSEEvent{Type: "comments-changed"})
+	resp := journalResponse{journalEntry: e}
+	resp.CanUndo, resp.CanRedo = sess.canStep()
+	writeJSON(w, resp)
+}
@@ -0,0 +1,116 @@
+package critcore
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestUndoRedo_FileComments(t *testing.T) {
+	_, session := newTestServer(t)
+	a, _ := session.AddComment("test.md", 1, 1, "", "first", "", "")
+	b, _ := session.AddComment("test.md", 2, 2, "", "second", "", "")
+	session.UpdateComment("test.md", a.ID, "first, edited")
+	session.SetCommentResolved("test.md", b.ID, true)
+	session.DeleteComment("test.md", a.ID)
+
+	bodies := func() []string {
+		var out []string
+		for _, c := range session.GetComments("test.md") {
+			body := c.Body
+			if c.Resolved {
+				body += " (resolved)"
+			}
+			out = append(out, body)
+		}
+		return out
+	}
+	steps := []struct {
+		op   string
+		want []string
+	}{
+		{journalDelete, []string{"first, edited", "second (resolved)"}},
+		{journalResolve, []string{"first, edited", "second"}},
+		{journalEdit, []string{"first", "second"}},
+		{journalCreate, []string{"first"}},
+		{journalCreate, nil},
+	}
+	for _, step := range steps {
+		e, err := session.Undo()
+		if err != nil || e.Op != step.op {
+			t.Fatalf("undo: %s, %v, want %s", e.Op, err, step.op)
+		}
+		if got := bodies(); !slices.Equal(got, step.want) {
+			t.Errorf("after undoing %s: %q, want %q", step.op, got, step.want)
+		}
+	}
+	if _, err := session.Undo(); !errors.Is(err, errNothingToStep) {
+		t.Errorf("undo on empty journal: %v", err)
+	}
+
+	for i := len(steps) - 1; i > 0; i-- {
+		if _, err := session.Redo(); err != nil {
+			t.Fatalf("redo %s: %v", steps[i].op, err)
+		}
+		if got := bodies(); !slices.Equal(got, steps[i-1].want) {
+			t.Errorf("after redoing %s: %q, want %q", steps[i].op, got, steps[i-1].want)
+		}
+	}
+	if comments := session.GetComments("test.md"); comments[0].ID != a.ID {
+		t.Errorf("restored comment has ID %s, want %s", comments[0].ID, a.ID)
+	}
+
+	// A new action drops the redo steps.
+	session.AddComment("test.md", 3, 3, "", "third", "", "")
+	if _, err := session.Redo(); !errors.Is(err, errNothingToStep) {
+		t.Errorf("redo after a new action: %v", err)
+	}
+}
+
+func TestUndo_StaleStep(t *testing.T) {
+	_, session := newTestServer(t)
+	session.AddComment("test.md", 1, 1, "", "gone soon", "", "")
+	session.mu.Lock()
+	session.Files[0].Comments = nil // e.g. the review file was edited by hand
+	session.mu.Unlock()
+	if _, err := session.Undo(); !errors.Is(err, errJournalStale) {
+		t.Errorf("undo of a vanished comment: %v", err)
+	}
+	if _, err := session.Redo(); !errors.Is(err, errNothingToStep) {
+		t.Errorf("stale step kept for redo: %v", err)
+	}
+}
+
+func TestHandleUndo(t *testing.T) {
+	s, session := newTestServer(t)
+	post := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		return w
+	}
+	if w := post("/api/undo"); w.Code != 409 {
+		t.Errorf("empty journal: status = %d, want 409", w.Code)
+	}
+
+	rc := session.AddReviewComment("overall", "")
+	w := post("/api/undo")
+	var resp journalResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != 200 || resp.Op != journalCreate || resp.ID != rc.ID || resp.CanUndo || !resp.CanRedo {
+		t.Errorf("undo: status %d, body %s", w.Code, w.Body.String())
+	}
+	if n := len(session.GetReviewComments()); n != 0 {
+		t.Errorf("review comments after undo = %d", n)
+	}
+	if w := post("/api/redo"); w.Code != 200 || len(session.GetReviewComments()) != 1 {
+		t.Errorf("redo: status %d, comments %d", w.Code, len(session.GetReviewComments()))
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/api/undo", nil))
+	if w.Code != 405 {
+		t.Errorf("GET: status = %d, want 405", w.Code)
+	}
+}
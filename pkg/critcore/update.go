@@ -0,0 +1,341 @@
+package critcore
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// crit update replaces the running binary with the latest release asset for
+// this platform, after checking it against the release's checksums.txt
+// (sha256sum output, written by the release workflow). Releases are not
+// signed, so the checksum is the only verification.
+
+const (
+	defaultGitHubAPIURL = "https://api.github.com"
+	releaseChecksums    = "checksums.txt"
+	maxReleaseAssetSize = 200 << 20 // 200MB
+)
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Draft   bool   `json:"draft"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// assetURL returns the download URL of the release asset called name.
+func (r githubRelease) assetURL(name string) (string, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, true
+		}
+	}
+	return "", false
+}
+
+// fetchLatestRelease reads the latest crit release from the GitHub API at
+// apiBase.
+func fetchLatestRelease(client *http.Client, apiBase string) (githubRelease, error) {
+	resp, err := client.Get(apiBase + "/repos/tomasz-tomczyk/crit/releases/latest")
+	if err != nil {
+		return githubRelease{}, fmt.Errorf("fetching latest release: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return githubRelease{}, fmt.Errorf("fetching latest release: %s", resp.Status)
+	}
+	var release githubRelease
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&release); err != nil {
+		return githubRelease{}, fmt.Errorf("parsing latest release: %w", err)
+	}
+	if release.TagName == "" {
+		return githubRelease{}, errors.New("latest release has no tag")
+	}
+	return release, nil
+}
+
+// releaseAssetName returns the release binary built for goos/goarch, named
+// like install.sh expects ("crit-darwin-arm64").
+func releaseAssetName(goos, goarch string) (string, error) {
+	if (goos != "darwin" && goos != "linux") || (goarch != "amd64" && goarch != "arm64") {
+		return "", fmt.Errorf("no release binary for %s/%s", goos, goarch)
+	}
+	return "crit-" + goos + "-" + goarch, nil
+}
+
+// checksumFor finds name's SHA-256 in sha256sum output.
+func checksumFor(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", releaseChecksums, name)
+}
+
+func downloadReleaseFile(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxReleaseAssetSize))
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", url, err)
+	}
+	return data, nil
+}
+
+// downloadVerifiedAsset downloads the asset called name and checks it against
+// the release checksums.
+func downloadVerifiedAsset(client *http.Client, release githubRelease, name string) ([]byte, error) {
+	binURL, ok := release.assetURL(name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s asset", release.TagName, name)
+	}
+	sumsURL, ok := release.assetURL(releaseChecksums)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no %s, refusing an unverified update", release.TagName, releaseChecksums)
+	}
+	sums, err := downloadReleaseFile(client, sumsURL)
+	if err != nil {
+		return nil, err
+	}
+	want, err := checksumFor(sums, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := downloadReleaseFile(client, binURL)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("checksum mismatch for %s: got %s, want %s", name, got, want)
+	}
+	return data, nil
+}
+
+// replaceExecutable swaps the binary at path for data by renaming a file
+// written next to it, so a failed update leaves the old binary in place.
+func replaceExecutable(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".crit-update-*")
+	if err != nil {
+		return fmt.Errorf("writing new binary: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing new binary: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing new binary: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0111); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replacing %s: %w", path, err)
+	}
+	return nil
+}
+
+// parseSemver splits a version such as "v1.2.3-rc.1+build" into its numeric
+// core and its prerelease identifiers, dropping build metadata.
+func parseSemver(v string) (core [3]int, pre []string, ok bool) {
+	v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "+")
+	v, prerelease, hasPre := strings.Cut(v, "-")
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return core, nil, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return core, nil, false
+		}
+		core[i] = n
+	}
+	if hasPre {
+		pre = strings.Split(prerelease, ".")
+	}
+	return core, pre, true
+}
+
+// compareVersions orders two semver versions by precedence, returning -1, 0
+// or 1; ok is false when either doesn't parse. A prerelease sorts before its
+// release, and numeric identifiers before alphanumeric ones.
+func compareVersions(a, b string) (int, bool) {
+	coreA, preA, okA := parseSemver(a)
+	coreB, preB, okB := parseSemver(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	for i := range coreA {
+		if coreA[i] != coreB[i] {
+			return cmp.Compare(coreA[i], coreB[i]), true
+		}
+	}
+	switch {
+	case len(preA) == 0 && len(preB) == 0:
+		return 0, true
+	case len(preA) == 0:
+		return 1, true
+	case len(preB) == 0:
+		return -1, true
+	}
+	for i := 0; i < len(preA) && i < len(preB); i++ {
+		if c := comparePrerelease(preA[i], preB[i]); c != 0 {
+			return c, true
+		}
+	}
+	return cmp.Compare(len(preA), len(preB)), true
+}
+
+func comparePrerelease(a, b string) int {
+	na, errA := strconv.Atoi(a)
+	nb, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Compare(na, nb)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// selfUpdate updates the binary at exe from version current to the latest
+// release on channel. It reports what it did on w and returns the new version, or ""
+// when already up to date. It won't go back to an older release, as when the
+// installed version is a newer prerelease than the latest stable one, or
+// compare versions it can't parse, unless force is set.
+func selfUpdate(client *http.Client, apiBase, channel, exe, current string, checkOnly, force bool, w io.Writer) (string, error) {
+	name, err := releaseAssetName(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return "", err
+	}
+	release, err := fetchChannelRelease(client, apiBase, channel)
+	if err != nil {
+		return "", err
+	}
+	latest := strings.TrimPrefix(release.TagName, "v")
+	if latest == strings.TrimPrefix(current, "v") {
+		fmt.Fprintf(w, "crit %s is the latest version\n", current)
+		return "", nil
+	}
+	order, ok := compareVersions(latest, current)
+	switch {
+	case !ok && !force:
+		return "", fmt.Errorf("can't compare installed version %s with release %s; use --force to install it anyway", current, latest)
+	case ok && order == 0:
+		fmt.Fprintf(w, "crit %s is the latest version\n", current)
+		return "", nil
+	case ok && order < 0 && !force:
+		fmt.Fprintf(w, "crit %s is newer than the latest %s release %s; use --force to install %s anyway\n", current, channel, latest, latest)
+		return "", nil
+	}
+	if checkOnly {
+		fmt.Fprintf(w, "crit %s is available (installed: %s). Run `crit update` to install it.\n", latest, current)
+		return latest, nil
+	}
+	data, err := downloadVerifiedAsset(client, release, name)
+	if err != nil {
+		return "", err
+	}
+	if err := replaceExecutable(exe, data); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(w, "Updated crit %s → %s (%s)\n", current, latest, exe)
+	return latest, nil
+}
+
+// packageManagerFor names the package manager that owns the binary at exe,
+// whose files crit update must not overwrite.
+func packageManagerFor(exe string) string {
+	switch {
+	case strings.Contains(exe, "/Cellar/"):
+		return "Homebrew (brew upgrade crit)"
+	case strings.HasPrefix(exe, "/nix/store/"):
+		return "Nix"
+	}
+	return ""
+}
+
+func runUpdate(args []string) {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	checkOnly := fs.Bool("check", false, "Only report whether a newer version is available")
+	force := fs.Bool("force", false, "Install the latest release even when it is older than this version")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: crit update [--check] [--force]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "Replaces this crit binary with the latest release for this platform,")
+		fmt.Fprintln(os.Stderr, "verified against the release's checksums.txt. It never goes back to an")
+		fmt.Fprintln(os.Stderr, "older release, such as from a prerelease to the previous stable one,")
+		fmt.Fprintln(os.Stderr, "unless --force is given.")
+	}
+	fs.Parse(args)
+
+	if version == "dev" {
+		fmt.Fprintln(os.Stderr, "Error: this is a development build; update it with git and make instead")
+		os.Exit(1)
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: locating the crit binary: %v\n", err)
+		os.Exit(1)
+	}
+	if manager := packageManagerFor(exe); manager != "" && !*checkOnly {
+		fmt.Fprintf(os.Stderr, "Error: %s is managed by %s; update crit there instead\n", exe, manager)
+		os.Exit(1)
+	}
+	policy, err := resolveUpdatePolicy(LoadConfig(""))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	updated, err := selfUpdate(policy.httpClient(2*time.Minute), defaultGitHubAPIURL, policy.channel, exe, version, *checkOnly, *force, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if updated == "" || *checkOnly {
+		return
+	}
+	// Same as install.sh: re-sign ad hoc so macOS 15+ doesn't block the binary.
+	if runtime.GOOS == "darwin" && commandExists("codesign") {
+		exec.Command("codesign", "--force", "--sign", "-", exe).Run() //nolint:errcheck
+	}
+	fmt.Println("Running crit daemons keep the old version; restart them with `crit stop --all`.")
+}
@@ -0,0 +1,139 @@
+package critcore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The startup update check is shaped by three global-only config keys:
+// update_channel picks stable releases or prereleases too,
+// update_check_interval bounds how often the network is hit (the last answer
+// is cached in ~/.crit/update-check.json), and update_proxy routes the
+// request through a proxy instead of HTTPS_PROXY. crit update honors the
+// channel and proxy but always asks GitHub.
+
+const (
+	updateChannelStable        = "stable"
+	updateChannelPrerelease    = "prerelease"
+	defaultUpdateCheckInterval = 24 * time.Hour
+	updateCheckCacheFile       = "update-check.json"
+)
+
+// updatePolicy is the validated form of the update_* config keys.
+type updatePolicy struct {
+	channel  string
+	interval time.Duration // 0 checks on every start
+	proxy    *url.URL      // nil uses the proxy environment variables
+}
+
+// resolveUpdatePolicy validates the update_* keys of cfg, filling in the
+// stable channel and a daily check when they are unset.
+func resolveUpdatePolicy(cfg Config) (updatePolicy, error) {
+	p := updatePolicy{channel: updateChannelStable, interval: defaultUpdateCheckInterval}
+	switch cfg.UpdateChannel {
+	case "", updateChannelStable:
+	case updateChannelPrerelease:
+		p.channel = updateChannelPrerelease
+	default:
+		return updatePolicy{}, fmt.Errorf("unknown update_channel %q (want %q or %q)", cfg.UpdateChannel, updateChannelStable, updateChannelPrerelease)
+	}
+	if cfg.UpdateCheckInterval != "" {
+		d, err := time.ParseDuration(cfg.UpdateCheckInterval)
+		if err != nil || d < 0 {
+			return updatePolicy{}, fmt.Errorf("invalid update_check_interval %q (want a duration like \"24h\")", cfg.UpdateCheckInterval)
+		}
+		p.interval = d
+	}
+	if cfg.UpdateProxy != "" {
+		u, err := url.Parse(cfg.UpdateProxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return updatePolicy{}, fmt.Errorf("invalid update_proxy %q (want a URL like \"http://proxy:3128\")", cfg.UpdateProxy)
+		}
+		p.proxy = u
+	}
+	return p, nil
+}
+
+// httpClient returns a client for GitHub release requests that goes through
+// the configured proxy.
+func (p updatePolicy) httpClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if p.proxy != nil {
+		transport.Proxy = http.ProxyURL(p.proxy)
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// fetchChannelRelease returns the newest release on channel: the latest
+// stable release, or for prereleases the most recent published release of
+// either kind.
+func fetchChannelRelease(client *http.Client, apiBase, channel string) (githubRelease, error) {
+	if channel != updateChannelPrerelease {
+		return fetchLatestRelease(client, apiBase)
+	}
+	resp, err := client.Get(apiBase + "/repos/tomasz-tomczyk/crit/releases?per_page=20")
+	if err != nil {
+		return githubRelease{}, fmt.Errorf("fetching releases: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return githubRelease{}, fmt.Errorf("fetching releases: %s", resp.Status)
+	}
+	var releases []githubRelease
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&releases); err != nil {
+		return githubRelease{}, fmt.Errorf("parsing releases: %w", err)
+	}
+	for _, r := range releases {
+		if !r.Draft && r.TagName != "" {
+			return r, nil
+		}
+	}
+	return githubRelease{}, errors.New("no published releases")
+}
+
+// updateCheckCache records the last answer from GitHub so daemons started
+// within update_check_interval don't ask again.
+// Concurrent daemons may both write it; atomicWriteFile keeps it whole.
+type updateCheckCache struct {
+	CheckedAt     time.Time `json:"checked_at"`
+	Channel       string    `json:"channel"`
+	LatestVersion string    `json:"latest_version"`
+}
+
+// cachedLatestVersion returns the version cached at path if it was fetched
+// for the policy's channel less than interval before now.
+func cachedLatestVersion(path string, p updatePolicy, now time.Time) (string, bool) {
+	if path == "" || p.interval == 0 {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	var c updateCheckCache
+	if json.Unmarshal(data, &c) != nil || c.LatestVersion == "" || c.Channel != p.channel {
+		return "", false
+	}
+	if age := now.Sub(c.CheckedAt); age < 0 || age >= p.interval {
+		return "", false
+	}
+	return c.LatestVersion, true
+}
+
+func writeUpdateCheckCache(path string, c updateCheckCache) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return atomicWriteFile(path, data, 0644)
+}
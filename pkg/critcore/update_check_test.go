@@ -0,0 +1,123 @@
+package critcore
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolveUpdatePolicy(t *testing.T) {
+	p, err := resolveUpdatePolicy(Config{})
+	if err != nil || p.channel != updateChannelStable || p.interval != defaultUpdateCheckInterval || p.proxy != nil {
+		t.Fatalf("defaults = %+v, %v", p, err)
+	}
+	p, err = resolveUpdatePolicy(Config{UpdateChannel: "prerelease", UpdateCheckInterval: "0", UpdateProxy: "http://proxy:3128"})
+	if err != nil || p.channel != updateChannelPrerelease || p.interval != 0 || p.proxy.Host != "proxy:3128" {
+		t.Fatalf("configured = %+v, %v", p, err)
+	}
+	for _, cfg := range []Config{
+		{UpdateChannel: "nightly"},
+		{UpdateCheckInterval: "daily"},
+		{UpdateCheckInterval: "-1h"},
+		{UpdateProxy: "proxy:3128"},
+	} {
+		if _, err := resolveUpdatePolicy(cfg); err == nil {
+			t.Errorf("resolveUpdatePolicy(%+v) should fail", cfg)
+		}
+	}
+}
+
+func TestFetchChannelRelease_Prerelease(t *testing.T) {
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/tomasz-tomczyk/crit/releases":
+			fmt.Fprint(w, `[{"tag_name":"v2.0.0-rc2","draft":true},{"tag_name":"v2.0.0-rc1","prerelease":true},{"tag_name":"v1.9.0"}]`)
+		case "/repos/tomasz-tomczyk/crit/releases/latest":
+			fmt.Fprint(w, `{"tag_name":"v1.9.0"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer gh.Close()
+
+	for channel, want := range map[string]string{updateChannelStable: "v1.9.0", updateChannelPrerelease: "v2.0.0-rc1"} {
+		release, err := fetchChannelRelease(gh.Client(), gh.URL, channel)
+		if err != nil || release.TagName != want {
+			t.Errorf("%s: got %q, %v; want %q", channel, release.TagName, err, want)
+		}
+	}
+}
+
+func TestUpdatePolicy_HTTPClientUsesProxy(t *testing.T) {
+	var proxied atomic.Bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy sees the absolute target URL.
+		proxied.Store(r.URL.Host == "releases.invalid")
+		fmt.Fprint(w, `{"tag_name":"v9.9.9"}`)
+	}))
+	defer proxy.Close()
+
+	p, err := resolveUpdatePolicy(Config{UpdateProxy: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := fetchChannelRelease(p.httpClient(5*time.Second), "http://releases.invalid", p.channel)
+	if err != nil || release.TagName != "v9.9.9" || !proxied.Load() {
+		t.Fatalf("got %q, %v, proxied=%v", release.TagName, err, proxied.Load())
+	}
+}
+
+func TestCachedLatestVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), updateCheckCacheFile)
+	now := time.Now()
+	policy := updatePolicy{channel: updateChannelStable, interval: time.Hour}
+	if _, ok := cachedLatestVersion(path, policy, now); ok {
+		t.Fatal("missing cache should not be fresh")
+	}
+	if err := writeUpdateCheckCache(path, updateCheckCache{CheckedAt: now.Add(-30 * time.Minute), Channel: updateChannelStable, LatestVersion: "v1.2.3"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := cachedLatestVersion(path, policy, now); !ok || got != "v1.2.3" {
+		t.Errorf("fresh cache = %q, %v", got, ok)
+	}
+	if _, ok := cachedLatestVersion(path, policy, now.Add(time.Hour)); ok {
+		t.Error("cache older than the interval should be stale")
+	}
+	if _, ok := cachedLatestVersion(path, updatePolicy{channel: updateChannelPrerelease, interval: time.Hour}, now); ok {
+		t.Error("cache from another channel should be ignored")
+	}
+	if _, ok := cachedLatestVersion(path, updatePolicy{channel: updateChannelStable}, now); ok {
+		t.Error("a zero interval should always check")
+	}
+}
+
+func TestCheckForUpdates_UsesCache(t *testing.T) {
+	var hits atomic.Int32
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprint(w, `{"tag_name":"v9.9.9"}`)
+	}))
+	defer gh.Close()
+
+	s, _ := newTestServer(t)
+	s.currentVersion = "v1.0.0"
+	s.githubAPIURL = gh.URL
+	s.updatePolicy = updatePolicy{channel: updateChannelStable, interval: time.Hour}
+	s.updateCachePath = filepath.Join(t.TempDir(), updateCheckCacheFile)
+
+	s.CheckForUpdates()
+	s.CheckForUpdates()
+	if n := hits.Load(); n != 1 {
+		t.Errorf("GitHub hit %d times, want 1 (second check should use the cache)", n)
+	}
+	s.versionMu.RLock()
+	got := s.latestVersion
+	s.versionMu.RUnlock()
+	if got != "v9.9.9" {
+		t.Errorf("latestVersion = %q, want v9.9.9", got)
+	}
+}
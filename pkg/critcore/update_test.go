@@ -0,0 +1,179 @@
+package critcore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// releaseServer serves a fake GitHub latest-release API with a binary for
+// this platform and a checksums.txt listing sum for it.
+func releaseServer(t *testing.T, tag string, binary []byte, sum string) *httptest.Server {
+	t.Helper()
+	name, err := releaseAssetName(runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		t.Skip(err)
+	}
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/tomasz-tomczyk/crit/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"tag_name": tag,
+			"assets": []map[string]string{
+				{"name": name, "browser_download_url": srv.URL + "/dl/" + name},
+				{"name": "checksums.txt", "browser_download_url": srv.URL + "/dl/checksums.txt"},
+			},
+		})
+	})
+	mux.HandleFunc("/dl/"+name, func(w http.ResponseWriter, r *http.Request) { w.Write(binary) })
+	mux.HandleFunc("/dl/checksums.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  crit-other-arch\n%s  %s\n", strings.Repeat("0", 64), sum, name)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestReleaseAssetName(t *testing.T) {
+	if got, err := releaseAssetName("darwin", "arm64"); err != nil || got != "crit-darwin-arm64" {
+		t.Errorf("darwin/arm64 = %q, %v", got, err)
+	}
+	if _, err := releaseAssetName("windows", "amd64"); err == nil {
+		t.Error("expected an error for windows")
+	}
+}
+
+func TestChecksumFor(t *testing.T) {
+	sums := []byte("ABC123  crit-linux-amd64\ndef456 *crit-darwin-arm64\n")
+	if got, _ := checksumFor(sums, "crit-darwin-arm64"); got != "def456" {
+		t.Errorf("binary-mode entry = %q", got)
+	}
+	if got, _ := checksumFor(sums, "crit-linux-amd64"); got != "abc123" {
+		t.Errorf("checksum should be lowercased, got %q", got)
+	}
+	if _, err := checksumFor(sums, "crit-linux-arm64"); err == nil {
+		t.Error("expected an error for a missing entry")
+	}
+}
+
+func TestSelfUpdate_ReplacesBinary(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho new\n")
+	srv := releaseServer(t, "v1.3.0", binary, sha256Hex(binary))
+	exe := filepath.Join(t.TempDir(), "crit")
+	os.WriteFile(exe, []byte("old"), 0755)
+
+	var out strings.Builder
+	got, err := selfUpdate(srv.Client(), srv.URL, updateChannelStable, exe, "1.2.0", false, false, &out)
+	if err != nil || got != "1.3.0" {
+		t.Fatalf("selfUpdate = %q, %v", got, err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != string(binary) {
+		t.Errorf("binary = %q", data)
+	}
+	if info, _ := os.Stat(exe); info.Mode().Perm()&0100 == 0 {
+		t.Errorf("new binary is not executable: %v", info.Mode())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(exe)); len(entries) != 1 {
+		t.Errorf("temp file left behind: %v", entries)
+	}
+}
+
+func TestSelfUpdate_ChecksumMismatch(t *testing.T) {
+	srv := releaseServer(t, "v1.3.0", []byte("tampered"), sha256Hex([]byte("original")))
+	exe := filepath.Join(t.TempDir(), "crit")
+	os.WriteFile(exe, []byte("old"), 0755)
+
+	if _, err := selfUpdate(srv.Client(), srv.URL, updateChannelStable, exe, "1.2.0", false, false, &strings.Builder{}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("err = %v, want a checksum mismatch", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "old" {
+		t.Errorf("a failed update must keep the old binary, got %q", data)
+	}
+}
+
+func TestSelfUpdate_UpToDateAndCheck(t *testing.T) {
+	srv := releaseServer(t, "v1.3.0", []byte("new"), sha256Hex([]byte("new")))
+	exe := filepath.Join(t.TempDir(), "crit")
+	os.WriteFile(exe, []byte("old"), 0755)
+
+	var out strings.Builder
+	if got, err := selfUpdate(srv.Client(), srv.URL, updateChannelStable, exe, "v1.3.0", false, false, &out); err != nil || got != "" || !strings.Contains(out.String(), "latest version") {
+		t.Errorf("up to date: %q, %v, %q", got, err, out.String())
+	}
+	out.Reset()
+	if got, err := selfUpdate(srv.Client(), srv.URL, updateChannelStable, exe, "1.2.0", true, false, &out); err != nil || got != "1.3.0" || !strings.Contains(out.String(), "1.3.0 is available") {
+		t.Errorf("--check: %q, %v, %q", got, err, out.String())
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "old" {
+		t.Errorf("--check must not replace the binary, got %q", data)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.3.0", "v1.3.0", 0},
+		{"1.10.0", "1.9.9", 1},
+		{"1.3.0", "1.4.0-rc.1", -1},
+		{"1.4.0", "1.4.0-rc.1", 1},
+		{"1.4.0-rc.2", "1.4.0-rc.10", -1},
+		{"1.4.0-rc.1", "1.4.0-rc.1.1", -1},
+		{"1.4.0-1", "1.4.0-alpha", -1},
+		{"1.4.0+build.5", "1.4.0", 0},
+	} {
+		if got, ok := compareVersions(tc.a, tc.b); !ok || got != tc.want {
+			t.Errorf("compareVersions(%q, %q) = %d, %v, want %d", tc.a, tc.b, got, ok, tc.want)
+		}
+	}
+	if _, ok := compareVersions("1.3", "1.3.0"); ok {
+		t.Error("a version without a patch number should not parse")
+	}
+}
+
+func TestSelfUpdate_RefusesDowngrade(t *testing.T) {
+	binary := []byte("stable")
+	srv := releaseServer(t, "v1.3.0", binary, sha256Hex(binary))
+	exe := filepath.Join(t.TempDir(), "crit")
+	os.WriteFile(exe, []byte("old"), 0755)
+
+	var out strings.Builder
+	if got, err := selfUpdate(srv.Client(), srv.URL, updateChannelStable, exe, "1.4.0-rc.1", false, false, &out); err != nil || got != "" || !strings.Contains(out.String(), "--force") {
+		t.Errorf("newer prerelease: %q, %v, %q", got, err, out.String())
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "old" {
+		t.Fatalf("a downgrade must need --force, binary = %q", data)
+	}
+	if _, err := selfUpdate(srv.Client(), srv.URL, updateChannelStable, exe, "nightly", false, false, &out); err == nil {
+		t.Error("an unparseable version should need --force")
+	}
+	if got, err := selfUpdate(srv.Client(), srv.URL, updateChannelStable, exe, "1.4.0-rc.1", false, true, &out); err != nil || got != "1.3.0" {
+		t.Fatalf("--force: %q, %v", got, err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "stable" {
+		t.Errorf("--force should install the release, binary = %q", data)
+	}
+}
+
+func TestPackageManagerFor(t *testing.T) {
+	if packageManagerFor("/opt/homebrew/Cellar/crit/1.2.0/bin/crit") == "" {
+		t.Error("Homebrew binary not detected")
+	}
+	if packageManagerFor("/usr/local/bin/crit") != "" {
+		t.Error("a plain install should be updatable")
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"context"
@@ -63,8 +63,9 @@ type VCS interface {
 	// CommitLog returns the commits between baseRef and HEAD.
 	CommitLog(baseRef, dir string) ([]CommitInfo, error)
 
-	// WorkingTreeFingerprint returns a string representing the current working tree state.
-	WorkingTreeFingerprint() string
+	// WorkingTreeFingerprint returns a string representing the current working
+	// tree state, leaving out the paths skip reports true for.
+	WorkingTreeFingerprint(skip func(path string) bool) string
 
 	// UntrackedFiles returns untracked files, running from the specified directory.
 	UntrackedFiles(dir string) ([]FileChange, error)
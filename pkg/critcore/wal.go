@@ -0,0 +1,397 @@
+package critcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Comment changes reach the review file through scheduleWrite's 200ms
+// debounce, so a daemon that crashes in that window used to lose them. Every
+// method that changes a comment queues a record of just that comment, and
+// scheduleWrite hands the queue to flushWAL, which appends it to a
+// write-ahead log, <storage root>/journal/<hash of the review file
+// path>.jsonl (.crit/journal/ with project storage), and fsyncs it outside
+// s.mu. Each successful write of the review file checkpoints the log: it is
+// rewritten as a "base" record holding every comment the write contained and
+// the written file's hash, followed by the records made since. On startup the
+// daemon replays the records after the base onto the review file it loaded,
+// since they never reached it, or the base and the records when the review
+// file is missing or corrupt. When the review file no longer matches the
+// base's hash, something like `crit comment --reply-to` edited it after the
+// crash, and changes older than that edit don't overwrite the comments it
+// holds.
+// Commands that delete a review file delete its log too, so nothing brings
+// the comments back.
+
+// Write-ahead log operations.
+const (
+	walBase   = "base"   // every comment at the last write of the review file
+	walPut    = "put"    // a comment was added or changed
+	walDelete = "delete" // a comment was deleted
+)
+
+// walRecord is one line of the write-ahead log. Path "" is the review
+// comments.
+type walRecord struct {
+	Op      string                       `json:"op"`
+	Seq     uint64                       `json:"seq,omitempty"`
+	At      string                       `json:"at,omitempty"` // RFC 3339, when the change was made
+	Path    string                       `json:"path,omitempty"`
+	ID      string                       `json:"id,omitempty"`
+	Comment json.RawMessage              `json:"comment,omitempty"` // walPut
+	Files   map[string][]json.RawMessage `json:"files,omitempty"`   // walBase: the comments by path
+	Hash    string                       `json:"hash,omitempty"`    // walBase: the written review file's hash
+}
+
+// reviewJournalPath returns the write-ahead log of the review file critPath.
+func reviewJournalPath(critPath string) (string, error) {
+	root, err := storageRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "journal", computeFileHash([]byte(critPath))[:16]+".jsonl"), nil
+}
+
+// removeReviewJournal deletes the write-ahead log of the review file critPath.
+func removeReviewJournal(critPath string) {
+	if path, err := reviewJournalPath(critPath); err == nil {
+		os.Remove(path)
+	}
+}
+
+// walPutLocked queues a record of c, a comment on path ("" for review
+// comments) that was added or changed. Caller holds s.mu.
+func (s *Session) walPutLocked(path string, c Comment) {
+	if s.walPath == "" {
+		return
+	}
+	data, _ := json.Marshal(c)
+	s.queueWALLocked(walRecord{Op: walPut, Path: path, ID: c.ID, Comment: data})
+}
+
+// walDeleteLocked queues a record of the deletion of comment id on path.
+// Caller holds s.mu.
+func (s *Session) walDeleteLocked(path, id string) {
+	if s.walPath == "" {
+		return
+	}
+	s.queueWALLocked(walRecord{Op: walDelete, Path: path, ID: id})
+}
+
+func (s *Session) queueWALLocked(rec walRecord) {
+	s.walSeq++
+	rec.Seq = s.walSeq
+	rec.At = time.Now().UTC().Format(time.RFC3339Nano)
+	s.walPending = append(s.walPending, rec)
+}
+
+// walBaseRecord returns the base record of the review file cj, written as
+// data (nil when it was removed).
+func walBaseRecord(cj CritJSON, data []byte) walRecord {
+	rec := walRecord{Op: walBase, Files: make(map[string][]json.RawMessage)}
+	if data != nil {
+		rec.Hash = fileHash(data)
+	}
+	add := func(path string, comments []Comment) {
+		for _, c := range comments {
+			b, _ := json.Marshal(c)
+			rec.Files[path] = append(rec.Files[path], b)
+		}
+	}
+	for path, f := range cj.Files {
+		add(path, f.Comments)
+	}
+	add("", cj.ReviewComments)
+	return rec
+}
+
+func encodeWAL(records []walRecord) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rec := range records {
+		enc.Encode(rec) //nolint:errcheck // only RawMessage and strings
+	}
+	return buf.Bytes()
+}
+
+// appendWAL appends records to the log at path and syncs it.
+func appendWAL(path string, records []walRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(encodeWAL(records)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readWAL returns the records of the log at path, up to the first one that
+// doesn't parse: a line torn by the crash.
+func readWAL(path string) ([]walRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []walRecord
+	for line := range bytes.Lines(data) {
+		var rec walRecord
+		if json.Unmarshal(line, &rec) != nil {
+			break
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// flushWAL appends the queued records to the log and syncs it. scheduleWrite
+// starts it in a goroutine, so the sync doesn't hold s.mu; walMu keeps the
+// records in order.
+func (s *Session) flushWAL() {
+	s.walMu.Lock()
+	defer s.walMu.Unlock()
+	s.mu.Lock()
+	records, path := s.walPending, s.walPath
+	s.walPending = nil
+	s.mu.Unlock()
+	if len(records) == 0 || path == "" {
+		return
+	}
+	if err := appendWAL(path, records); err != nil {
+		slog.Warn("appending to the write-ahead log", "path", path, "err", err)
+	}
+	s.mu.Lock()
+	s.walLogged = append(s.walLogged, records...)
+	s.mu.Unlock()
+}
+
+// checkpointWAL rewrites the log once the review file at critPath holds cj,
+// written as data (nil when it was removed), from a snapshot taken after
+// record seq: a base record of cj, then the records made since. Queued
+// records up to seq are dropped, since the file has them. A renamed review
+// file's log moves with it.
+func (s *Session) checkpointWAL(critPath string, cj CritJSON, data []byte, seq uint64) {
+	s.mu.RLock()
+	enabled := s.walPath != ""
+	s.mu.RUnlock()
+	if !enabled {
+		return
+	}
+	path, _ := reviewJournalPath(critPath)
+	s.rewriteWAL(path, walBaseRecord(cj, data), seq)
+}
+
+// rewriteWAL replaces the log with base and the records after seq, moving it
+// to path first unless that is "".
+func (s *Session) rewriteWAL(path string, base walRecord, seq uint64) {
+	s.walMu.Lock()
+	defer s.walMu.Unlock()
+	s.mu.Lock()
+	if s.walPath == "" {
+		s.mu.Unlock()
+		return
+	}
+	if path != "" && path != s.walPath {
+		os.Remove(s.walPath)
+		s.walPath = path
+	}
+	written := func(rec walRecord) bool { return rec.Seq <= seq }
+	s.walLogged = slices.DeleteFunc(s.walLogged, written)
+	s.walPending = slices.DeleteFunc(s.walPending, written)
+	path = s.walPath
+	records := append([]walRecord{base}, s.walLogged...)
+	s.mu.Unlock()
+	if err := atomicWriteFile(path, encodeWAL(records), 0600); err != nil {
+		slog.Warn("checkpointing the write-ahead log", "path", path, "err", err)
+	}
+}
+
+// resetWAL empties the log after the session's comments were cleared.
+func (s *Session) resetWAL() {
+	s.mu.RLock()
+	seq := s.walSeq
+	s.mu.RUnlock()
+	s.rewriteWAL("", walBaseRecord(CritJSON{}, nil), seq)
+}
+
+// enableWAL starts logging the session's comment changes, first replaying
+// the log a crashed daemon left behind for the review file. Call it after
+// the review file has been loaded and before the session is served.
+func (s *Session) enableWAL() {
+	critPath := s.critJSONPath()
+	path, err := reviewJournalPath(critPath)
+	if err != nil {
+		return
+	}
+	records, _ := readWAL(path)
+	disk := readReviewFileState(critPath)
+	s.mu.Lock()
+	s.walPath = path
+	replayed := s.replayWALLocked(records, disk)
+	s.mu.Unlock()
+	if replayed {
+		slog.Info("replayed comment changes from the write-ahead log", "path", path)
+		s.WriteFiles()
+		return
+	}
+	s.checkpointWAL(critPath, disk.cj, disk.data, 0)
+}
+
+// reviewFileState is the review file on disk when the daemon starts.
+type reviewFileState struct {
+	data     []byte
+	cj       CritJSON
+	readable bool      // it exists and parses, or is from a newer crit and isn't replaced
+	mtime    time.Time // zero when missing
+}
+
+func readReviewFileState(critPath string) reviewFileState {
+	var st reviewFileState
+	info, err := os.Stat(critPath)
+	if err != nil {
+		return st
+	}
+	if st.data, err = os.ReadFile(critPath); err != nil {
+		return reviewFileState{}
+	}
+	st.mtime = info.ModTime()
+	err = unmarshalCritJSON(st.data, &st.cj)
+	st.readable = err == nil || errors.Is(err, errNewerCritSchema)
+	return st
+}
+
+// replayWALLocked applies the records after the log's base to the session's
+// comments, starting from the base itself when the review file was lost.
+// When the review file changed since the base, records older than the change
+// skip the comments it already has. Comments on paths the session doesn't
+// have come back as orphaned files. It returns whether any comment changed.
+// Caller holds s.mu.
+func (s *Session) replayWALLocked(records []walRecord, disk reviewFileState) bool {
+	start := 0
+	var base *walRecord
+	for i := range records {
+		if records[i].Op == walBase {
+			start, base = i+1, &records[i]
+		}
+	}
+	if start == len(records) && (disk.readable || base == nil || len(base.Files) == 0) {
+		return false
+	}
+
+	var comments map[string][]Comment
+	if !disk.readable && base != nil {
+		comments = base.baseComments()
+	} else {
+		comments = map[string][]Comment{"": s.reviewComments}
+		for _, f := range s.Files {
+			comments[f.Path] = f.Comments
+		}
+	}
+	editedSince := editedSinceBase(base, disk)
+	for _, rec := range records[start:] {
+		if !rec.staleFor(comments, editedSince) {
+			applyWALRecord(comments, rec)
+		}
+	}
+	s.setCommentsByPathLocked(comments)
+	return true
+}
+
+// editedSinceBase returns when the review file was changed by someone else
+// after the log's base was written, or zero when it wasn't.
+func editedSinceBase(base *walRecord, disk reviewFileState) time.Time {
+	if !disk.readable || base == nil || base.Hash == "" || base.Hash == fileHash(disk.data) {
+		return time.Time{}
+	}
+	return disk.mtime
+}
+
+// staleFor reports whether rec is a put made before the review file was
+// edited at editedSince for a comment the file has, which it would
+// overwrite. A zero editedSince makes nothing stale.
+func (rec walRecord) staleFor(comments map[string][]Comment, editedSince time.Time) bool {
+	if rec.Op != walPut || editedSince.IsZero() {
+		return false
+	}
+	at, err := time.Parse(time.RFC3339Nano, rec.At)
+	if err != nil || !at.Before(editedSince) {
+		return false
+	}
+	return slices.ContainsFunc(comments[rec.Path], func(c Comment) bool { return c.ID == rec.ID })
+}
+
+// baseComments decodes the comments of a base record by path.
+func (rec walRecord) baseComments() map[string][]Comment {
+	comments := make(map[string][]Comment)
+	for path, list := range rec.Files {
+		for _, data := range list {
+			var c Comment
+			if json.Unmarshal(data, &c) == nil {
+				comments[path] = append(comments[path], c)
+			}
+		}
+	}
+	return comments
+}
+
+// setCommentsByPathLocked replaces the session's comments with comments,
+// by path ("" for review comments). Caller holds s.mu.
+func (s *Session) setCommentsByPathLocked(comments map[string][]Comment) {
+	for _, f := range s.Files {
+		f.Comments = nonNilComments(comments[f.Path])
+		delete(comments, f.Path)
+	}
+	s.reviewComments = comments[""]
+	delete(comments, "")
+	orphaned := make(map[string]CritJSONFile, len(comments))
+	for path, list := range comments {
+		orphaned[path] = CritJSONFile{Comments: list}
+	}
+	s.appendOrphanedFiles(orphaned)
+}
+
+func applyWALRecord(comments map[string][]Comment, rec walRecord) {
+	list := comments[rec.Path]
+	switch rec.Op {
+	case walPut:
+		var c Comment
+		if json.Unmarshal(rec.Comment, &c) != nil {
+			return
+		}
+		for i := range list {
+			if list[i].ID == c.ID {
+				list[i] = c
+				return
+			}
+		}
+		comments[rec.Path] = append(list, c)
+	case walDelete:
+		for i := range list {
+			if list[i].ID == rec.ID {
+				comments[rec.Path] = append(list[:i:i], list[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func nonNilComments(list []Comment) []Comment {
+	if list == nil {
+		return []Comment{}
+	}
+	return list
+}
@@ -0,0 +1,205 @@
+package critcore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newWALSession returns a test session whose review file lives in a temp
+// storage root, with the write-ahead log enabled.
+func newWALSession(t *testing.T) *Session {
+	t.Helper()
+	storage := t.TempDir()
+	withProjectStorage(t, storage)
+	_, session := newTestServer(t)
+	session.ReviewFilePath = filepath.Join(storage, "reviews", "key1.json")
+	session.enableWAL()
+	return session
+}
+
+// restartSession loads a fresh session from the review file s wrote, as
+// runServe does after a crash, and replays the log.
+func restartSession(t *testing.T, s *Session) *Session {
+	t.Helper()
+	_, next := newTestServer(t)
+	next.ReviewFilePath = s.ReviewFilePath
+	next.loadCritJSON()
+	next.enableWAL()
+	return next
+}
+
+// stopWrites cancels the pending debounced write, as a crash would, once the
+// log has the queued records.
+func stopWrites(s *Session) {
+	s.mu.Lock()
+	s.writeTimer.Stop()
+	s.mu.Unlock()
+	s.flushWAL()
+}
+
+func TestWAL_ReplaysChangesTheDebounceLost(t *testing.T) {
+	s := newWALSession(t)
+	kept, _ := s.AddComment("test.md", 1, 1, "", "written", "", "")
+	s.WriteFiles()
+	lost, _ := s.AddComment("test.md", 2, 2, "", "not yet written", "", "")
+	s.UpdateComment("test.md", kept.ID, "edited after the write")
+	s.AddReviewComment("overall", "")
+	stopWrites(s) // the daemon crashes before the debounced write
+
+	next := restartSession(t, s)
+	comments := next.Files[0].Comments
+	if len(comments) != 2 || comments[0].Body != "edited after the write" || comments[1].ID != lost.ID {
+		t.Fatalf("comments = %+v", comments)
+	}
+	if len(next.reviewComments) != 1 {
+		t.Errorf("review comments = %+v", next.reviewComments)
+	}
+	// The replayed state is written straight away, which checkpoints the log.
+	cj, err := loadCritJSON(s.ReviewFilePath)
+	if err != nil || len(cj.Files["test.md"].Comments) != 2 {
+		t.Fatalf("review file = %+v, %v", cj, err)
+	}
+	records, _ := readWAL(next.walPath)
+	if len(records) != 1 || records[0].Op != walBase {
+		t.Errorf("log after checkpoint = %+v", records)
+	}
+}
+
+func TestWAL_ReplaysDelete(t *testing.T) {
+	s := newWALSession(t)
+	c, _ := s.AddComment("test.md", 1, 1, "", "soon gone", "", "")
+	s.AddComment("test.md", 2, 2, "", "stays", "", "")
+	s.WriteFiles()
+	s.DeleteComment("test.md", c.ID)
+	stopWrites(s)
+
+	next := restartSession(t, s)
+	if comments := next.Files[0].Comments; len(comments) != 1 || comments[0].Body != "stays" {
+		t.Errorf("comments = %+v", comments)
+	}
+}
+
+func TestWAL_RebuildsMissingOrCorruptReviewFile(t *testing.T) {
+	for name, damage := range map[string]func(string){
+		"missing": func(path string) { os.Remove(path) },
+		"corrupt": func(path string) { os.WriteFile(path, []byte(`{"files": {`), 0644) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newWALSession(t)
+			s.AddComment("test.md", 1, 1, "", "first", "", "")
+			s.WriteFiles()
+			s.AddComment("test.md", 2, 2, "", "second", "", "")
+			stopWrites(s)
+			damage(s.ReviewFilePath)
+
+			next := restartSession(t, s)
+			if comments := next.Files[0].Comments; len(comments) != 2 {
+				t.Errorf("comments = %+v", comments)
+			}
+		})
+	}
+}
+
+func TestWAL_NothingToReplay(t *testing.T) {
+	s := newWALSession(t)
+	s.AddComment("test.md", 1, 1, "", "written", "", "")
+	s.WriteFiles()
+
+	next := restartSession(t, s)
+	if len(next.Files[0].Comments) != 1 {
+		t.Errorf("comments = %+v", next.Files[0].Comments)
+	}
+}
+
+func TestWAL_ClearedReviewStaysCleared(t *testing.T) {
+	s := newWALSession(t)
+	s.AddComment("test.md", 1, 1, "", "written", "", "")
+	s.WriteFiles()
+	removeReviewJournal(s.ReviewFilePath)
+	os.Remove(s.ReviewFilePath)
+
+	next := restartSession(t, s)
+	if len(next.Files[0].Comments) != 0 {
+		t.Errorf("a cleared review came back: %+v", next.Files[0].Comments)
+	}
+}
+
+func TestWAL_OrphanedPath(t *testing.T) {
+	s := newWALSession(t)
+	s.AddComment("test.md", 1, 1, "", "on a file", "", "")
+	s.mu.Lock()
+	s.Files[0].Path = "gone.md"
+	s.walPutLocked("gone.md", s.Files[0].Comments[0])
+	s.scheduleWrite()
+	s.mu.Unlock()
+	stopWrites(s)
+	os.Remove(s.ReviewFilePath)
+
+	next := restartSession(t, s)
+	var orphan *FileEntry
+	for _, f := range next.Files {
+		if f.Path == "gone.md" {
+			orphan = f
+		}
+	}
+	if orphan == nil || !orphan.Orphaned || len(orphan.Comments) != 1 {
+		t.Errorf("files = %+v", next.Files)
+	}
+}
+
+func TestWAL_RecordsOnlyTheChangedComment(t *testing.T) {
+	s := newWALSession(t)
+	s.AddComment("test.md", 1, 1, "", "one", "", "")
+	c, _ := s.AddComment("test.md", 2, 2, "", "two", "", "")
+	s.WriteFiles()
+	s.UpdateComment("test.md", c.ID, "two, edited")
+	stopWrites(s)
+
+	records, _ := readWAL(s.walPath)
+	if len(records) != 2 || records[0].Op != walBase || records[1].Op != walPut || records[1].ID != c.ID {
+		t.Fatalf("log = %+v", records)
+	}
+	if len(records[0].Files["test.md"]) != 2 || records[0].Hash == "" {
+		t.Errorf("base = %+v", records[0])
+	}
+}
+
+func TestWAL_StaleRecordsDontOverwriteLaterEdits(t *testing.T) {
+	s := newWALSession(t)
+	c, _ := s.AddComment("test.md", 1, 1, "", "original", "", "")
+	s.WriteFiles()
+	s.UpdateComment("test.md", c.ID, "edited before the crash")
+	added, _ := s.AddComment("test.md", 2, 2, "", "added before the crash", "", "")
+	stopWrites(s)
+
+	// After the crash, `crit comment --reply-to` edits the review file.
+	time.Sleep(10 * time.Millisecond)
+	cj, _ := loadCritJSON(s.ReviewFilePath)
+	f := cj.Files["test.md"]
+	f.Comments[0].Replies = append(f.Comments[0].Replies, Reply{ID: "rp_1", Body: "done"})
+	cj.Files["test.md"] = f
+	saveCritJSON(s.ReviewFilePath, cj)
+
+	next := restartSession(t, s)
+	comments := next.Files[0].Comments
+	if len(comments) != 2 {
+		t.Fatalf("comments = %+v", comments)
+	}
+	if len(comments[0].Replies) != 1 || comments[0].Body != "original" {
+		t.Errorf("the stale edit overwrote the reply: %+v", comments[0])
+	}
+	if comments[1].ID != added.ID {
+		t.Errorf("a comment the review file doesn't have should still be replayed: %+v", comments[1])
+	}
+}
+
+func TestReadWAL_StopsAtTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	os.WriteFile(path, []byte(`{"op":"base"}`+"\n"+`{"op":"delete","path":"a.md","id":"c1"}`+"\n"+`{"op":"put","comm`), 0600)
+	records, err := readWAL(path)
+	if err != nil || len(records) != 2 || records[1].ID != "c1" {
+		t.Errorf("records = %+v, %v", records, err)
+	}
+}
@@ -1,12 +1,14 @@
-package main
+package critcore
 
 import (
-	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 // RefreshDiffs re-computes diff hunks for all files.
@@ -147,7 +149,7 @@ func (s *Session) RefreshFileList() {
 				}
 			} else if fc.Status != "deleted" {
 				if data, err := os.ReadFile(absPath); err == nil {
-					fe.Content = string(data)
+					fe.Content = textContent(data)
 					fe.FileHash = fileHash(data)
 				}
 			}
@@ -180,8 +182,20 @@ func (s *Session) Watch(stop <-chan struct{}) {
 //
 // Git status polling only runs during the "waiting for agent" phase (between
 // POST /api/finish and POST /api/round-complete). mergeExternalCritJSON runs
-// on every tick since it only uses os.Stat.
+// on every tick since it only uses os.Stat. Filesystem events on the repo root
+// and the directories of changed files trigger an immediate check so agent
+// edits show up without waiting for the next poll.
 func (s *Session) watchGit(stop <-chan struct{}) {
+	fw := newFSWatcher()
+	defer fw.close()
+	s.mu.RLock()
+	repoRoot := s.RepoRoot
+	s.mu.RUnlock()
+	fw.addDir(repoRoot)
+	s.watchSessionFiles(fw)
+
+	// Keep the 1s tick even with fsnotify: it establishes the fingerprint
+	// baseline promptly after the reviewer finishes a round.
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
@@ -192,122 +206,178 @@ func (s *Session) watchGit(stop <-chan struct{}) {
 
 	var lastFP string
 	wasWaiting := false
+	var debounce <-chan time.Time
+
+	check := func() {
+		// Check for external review file changes (e.g. crit comment).
+		s.mergeExternalCritJSON()
+
+		// Only poll VCS status while waiting for the agent to make edits.
+		if !s.isWaitingForAgent() {
+			wasWaiting = false
+			return
+		}
+
+		var fp string
+		if vcs != nil {
+			fp = vcs.WorkingTreeFingerprint(s.isReviewArtifact)
+		} else {
+			fp = WorkingTreeFingerprint(s.isReviewArtifact)
+		}
+		if !wasWaiting {
+			// Just entered waiting state — establish baseline.
+			lastFP = fp
+			wasWaiting = true
+			return
+		}
+		if fp == lastFP {
+			return
+		}
+		lastFP = fp
+
+		s.IncrementEdits()
+		s.notify(SSEEvent{
+			Type:    "edit-detected",
+			Content: fmt.Sprintf("%d", s.GetPendingEdits()),
+		})
+	}
 
 	for {
 		select {
 		case <-stop:
 			return
 		case <-ticker.C:
-			// Check for external review file changes (e.g. crit comment).
-			s.mergeExternalCritJSON()
-
-			// Only poll VCS status while waiting for the agent to make edits.
-			if !s.isWaitingForAgent() {
-				wasWaiting = false
-				continue
-			}
-
-			var fp string
-			if vcs != nil {
-				fp = vcs.WorkingTreeFingerprint()
-			} else {
-				fp = WorkingTreeFingerprint()
+			check()
+		case ev := <-fw.events():
+			if debounce == nil && fw.relevant(ev) {
+				debounce = time.After(fsEventDebounce)
 			}
-			if !wasWaiting {
-				// Just entered waiting state — establish baseline.
-				lastFP = fp
-				wasWaiting = true
-				continue
-			}
-			if fp == lastFP {
-				continue
-			}
-			lastFP = fp
-
-			s.IncrementEdits()
-			s.notify(SSEEvent{
-				Type:    "edit-detected",
-				Content: fmt.Sprintf("%d", s.GetPendingEdits()),
-			})
+		case <-debounce:
+			debounce = nil
+			check()
+		case err := <-fw.errors():
+			slog.Warn("file watcher error", "err", err)
 		case <-s.roundComplete:
 			s.handleRoundCompleteGit()
+			s.watchSessionFiles(fw)
 		}
 	}
 }
 
-// watchFileMtimes polls individual file mtimes for changes.
+// watchFileMtimes watches individual files for changes.
 // Used in files mode (explicit file args).
+//
+// fsnotify events trigger an immediate, debounced re-check; a slower mtime
+// poll runs as a fallback for filesystems that don't deliver events.
 func (s *Session) watchFileMtimes(stop <-chan struct{}) {
-	ticker := time.NewTicker(1 * time.Second)
+	fw := newFSWatcher()
+	defer fw.close()
+	s.watchSessionFiles(fw)
+
+	ticker := time.NewTicker(fw.pollInterval())
 	defer ticker.Stop()
 
 	// Track last mod times per file
 	lastMod := make(map[string]time.Time)
+	// Paths created or renamed recently, used to recognize renames.
+	created := make(map[string]time.Time)
+	var debounce <-chan time.Time
 
 	for {
 		select {
 		case <-stop:
 			return
 		case <-ticker.C:
-			// Check for external review file changes (e.g. crit comment).
-			s.mergeExternalCritJSON()
-
-			s.mu.RLock()
-			files := make([]*FileEntry, len(s.Files))
-			copy(files, s.Files)
-			s.mu.RUnlock()
-
-			changed := false
-			for _, f := range files {
-				info, err := os.Stat(f.AbsPath)
-				if err != nil {
-					continue
-				}
-				modTime := info.ModTime()
-				if modTime.Equal(lastMod[f.Path]) {
-					continue
-				}
-				lastMod[f.Path] = modTime
-
-				data, err := os.ReadFile(f.AbsPath)
-				if err != nil {
-					continue
-				}
-				hash := fileHash(data)
-
-				s.mu.Lock()
-				// Re-check hash under write lock to avoid racing with AddComment.
-				// Without this, a comment added between a read-lock check and this
-				// write lock would be silently discarded.
-				if hash == f.FileHash {
-					s.mu.Unlock()
-					continue
-				}
-				// Snapshot on first edit of a round (markdown files)
-				if f.FileType == "markdown" && s.pendingEdits == 0 {
-					f.PreviousContent = f.Content
-					f.PreviousComments = make([]Comment, len(f.Comments))
-					copy(f.PreviousComments, f.Comments)
-				}
-				f.Content = string(data)
-				f.FileHash = hash
-				s.mu.Unlock()
-				changed = true
+			s.detectRenames(created)
+			s.checkFileEdits(lastMod, false)
+			// Pick up files restored, added or renamed since the last tick.
+			s.watchSessionFiles(fw)
+		case ev := <-fw.events():
+			if ev.Has(fsnotify.Create) || ev.Has(fsnotify.Rename) {
+				created[filepath.Clean(ev.Name)] = time.Now()
 			}
-
-			if changed {
-				s.IncrementEdits()
-				s.notify(SSEEvent{
-					Type:    "edit-detected",
-					Content: fmt.Sprintf("%d", s.GetPendingEdits()),
-				})
+			if debounce == nil && (fw.relevant(ev) || ev.Has(fsnotify.Create)) {
+				debounce = time.After(fsEventDebounce)
 			}
+		case <-debounce:
+			debounce = nil
+			if s.detectRenames(created) {
+				s.watchSessionFiles(fw)
+			}
+			// Events fire for writes within the same mtime tick on coarse
+			// filesystems, so compare content hashes regardless of mtime.
+			s.checkFileEdits(lastMod, true)
+		case err := <-fw.errors():
+			slog.Warn("file watcher error", "err", err)
 		case <-s.roundComplete:
 			s.handleRoundCompleteFiles()
 		}
 	}
 }
 
+// checkFileEdits re-reads session files whose content changed on disk and
+// emits an edit-detected event if any did. When force is false, files whose
+// mtime is unchanged since the last check are skipped without being read.
+func (s *Session) checkFileEdits(lastMod map[string]time.Time, force bool) {
+	// Check for external review file changes (e.g. crit comment).
+	s.mergeExternalCritJSON()
+
+	s.mu.RLock()
+	files := make([]*FileEntry, len(s.Files))
+	copy(files, s.Files)
+	s.mu.RUnlock()
+
+	changed := false
+	for _, f := range files {
+		info, err := os.Stat(f.AbsPath)
+		if err != nil {
+			continue
+		}
+		modTime := info.ModTime()
+		if !force && modTime.Equal(lastMod[f.Path]) {
+			continue
+		}
+		lastMod[f.Path] = modTime
+
+		data, err := os.ReadFile(f.AbsPath)
+		if err != nil {
+			continue
+		}
+		hash := fileHash(data)
+
+		s.mu.Lock()
+		// Re-check hash under write lock to avoid racing with AddComment.
+		// Without this, a comment added between a read-lock check and this
+		// write lock would be silently discarded.
+		if hash == f.FileHash {
+			s.mu.Unlock()
+			continue
+		}
+		// Snapshot on first edit of a round (markdown files)
+		if f.FileType == "markdown" && s.pendingEdits == 0 {
+			f.PreviousContent = f.Content
+			f.PreviousComments = make([]Comment, len(f.Comments))
+			copy(f.PreviousComments, f.Comments)
+		}
+		f.setContent(data)
+		f.FileHash = hash
+		if f.CompareBase != "" {
+			f.DiffHunks = compareHunks(f)
+		}
+		s.mu.Unlock()
+		changed = true
+	}
+
+	if changed {
+		s.IncrementEdits()
+		s.saveResumeState()
+		s.notify(SSEEvent{
+			Type:    "edit-detected",
+			Content: fmt.Sprintf("%d", s.GetPendingEdits()),
+		})
+	}
+}
+
 func carryForwardComment(old Comment, newID string, now string) Comment {
 	return Comment{
 		ID:             newID,
@@ -323,14 +393,37 @@ func carryForwardComment(old Comment, newID string, now string) Comment {
 		CreatedAt:      old.CreatedAt,
 		UpdatedAt:      now,
 		Resolved:       old.Resolved,
+		Pinned:         old.Pinned,
+		Severity:       old.Severity,
+		IssueKey:       old.IssueKey,
+		IssueURL:       old.IssueURL,
+		Tool:           old.Tool,
+		Disposition:    old.Disposition,
 		CarriedForward: true,
 		Live:           old.Live,
 		ReviewRound:    old.ReviewRound,
 		Replies:        old.Replies,
 		GitHubID:       old.GitHubID,
+		DataPath:       old.DataPath,
+		TableCell:      old.TableCell,
+		Region:         old.Region,
+		StartCol:       old.StartCol,
+		EndCol:         old.EndCol,
 	}
 }
 
+// carryForwardLocked carries old forward under a new ID, recording the
+// rename so references to it can follow. Must be called with s.mu held for
+// writing.
+func (s *Session) carryForwardLocked(old Comment, now string) Comment {
+	carried := carryForwardComment(old, randomCommentID(), now)
+	if s.carriedIDs == nil {
+		s.carriedIDs = make(map[string]string)
+	}
+	s.carriedIDs[old.ID] = carried.ID
+	return carried
+}
+
 // carryForwardAllComments carries forward all PreviousComments at their original positions.
 // Must be called with s.mu held for writing.
 func (s *Session) carryForwardAllComments() {
@@ -341,7 +434,7 @@ func (s *Session) carryForwardAllComments() {
 			continue
 		}
 		for _, c := range f.PreviousComments {
-			carried := carryForwardComment(c, randomCommentID(), now)
+			carried := s.carryForwardLocked(c, now)
 			f.Comments = append(f.Comments, carried)
 			// Track the old ID as deleted so mergeFileSnapshotIntoCritJSON
 			// won't re-add the original from disk alongside the carried-forward copy.
@@ -365,18 +458,21 @@ func (s *Session) rereadFileContents(snapshotMarkdown bool) {
 		if snapshotMarkdown && f.FileType == "markdown" && f.PreviousContent == "" {
 			f.PreviousContent = f.Content
 		}
-		f.Content = string(data)
+		f.setContent(data)
 		f.FileHash = fileHash(data)
 	}
 }
 
 // finishRoundComplete emits terminal status and notifies SSE subscribers.
 func (s *Session) finishRoundComplete(edits int) {
+	s.saveResumeState()
+	s.syncReviewNote()
 	s.emitRoundStatus(edits)
 	s.notify(SSEEvent{
 		Type:    "file-changed",
 		Content: "session",
 	})
+	go s.runLinters()
 }
 
 // handleRoundCompleteGit handles round completion in git mode.
@@ -416,7 +512,9 @@ func (s *Session) handleRoundCompleteGit() {
 	s.restoreOrphanedComments()
 
 	s.mu.Lock()
+	s.rewriteCarriedRefsLocked()
 	s.ReviewRound++
+	s.clearJournalLocked()
 	s.mu.Unlock()
 
 	// Refresh diffs for all files
@@ -429,6 +527,12 @@ func (s *Session) handleRoundCompleteGit() {
 // Re-reads files, carries forward unresolved comments.
 // Must only be called from the single watcher goroutine (watchFileMtimes).
 func (s *Session) handleRoundCompleteFiles() {
+	// A remote document that changed since is picked up as an edit the
+	// watcher saw, so its comments carry forward like any other file's.
+	if s.refreshRemoteDocuments() {
+		s.checkFileEdits(make(map[string]time.Time), true)
+	}
+
 	s.mu.RLock()
 	edits := s.lastRoundEdits
 	s.mu.RUnlock()
@@ -447,7 +551,9 @@ func (s *Session) handleRoundCompleteFiles() {
 	// (snapshot markdown PreviousContent in case watcher hasn't polled yet)
 	s.mu.Lock()
 	s.rereadFileContents(true)
+	s.rewriteCarriedRefsLocked()
 	s.ReviewRound++
+	s.clearJournalLocked()
 	s.mu.Unlock()
 
 	s.finishRoundComplete(edits)
@@ -490,7 +596,7 @@ func (s *Session) loadResolvedComments() {
 		return
 	}
 	var cj CritJSON
-	if err := json.Unmarshal(data, &cj); err != nil {
+	if err := unmarshalCritJSON(data, &cj); err != nil {
 		return
 	}
 	s.mu.Lock()
@@ -657,6 +763,14 @@ func (s *Session) carryForwardFileComments(f *FileEntry) {
 	if newLineCount == 0 {
 		newLineCount = 1
 	}
+	var nodes []dataNode
+	if isDataFile(f.Path) {
+		nodes = dataNodes(f.Path, currContent)
+	}
+	var table *reviewTable
+	if isTablePath(f.Path) {
+		table = parseTable(f.Path, currContent)
+	}
 
 	s.mu.Lock()
 	f.Comments = nil // Clear before carry-forward to prevent duplicates
@@ -668,15 +782,23 @@ func (s *Session) carryForwardFileComments(f *FileEntry) {
 		// File-level comments have no line references. Old-side comments
 		// reference the base ref which doesn't change between rounds.
 		if c.Scope == "file" || c.Side == "old" {
-			f.Comments = append(f.Comments, carryForwardComment(c, randomCommentID(), now))
+			f.Comments = append(f.Comments, s.carryForwardLocked(c, now))
 			continue
 		}
 		newStart, newEnd := remapLines(lineMap, c.StartLine, c.EndLine, newLineCount)
-		carried := carryForwardComment(c, randomCommentID(), now)
+		carried := s.carryForwardLocked(c, now)
 		carried.StartLine = newStart
 		carried.EndLine = newEnd
-
-		if c.Anchor != "" {
+		carried.TableCell = nil // kept only where relocateTableCell finds the cell
+
+		// Anchor is left alone: it stays the text the comment was made on.
+		if start, ok := relocateByDataPath(nodes, c, newLineCount); ok {
+			carried.StartLine = start
+			carried.EndLine = min(start+c.EndLine-c.StartLine, newLineCount)
+		} else if ref, start, end, ok := relocateTableCell(table, c, newStart); ok {
+			carried.TableCell = &ref
+			carried.StartLine, carried.EndLine = start, end
+		} else if c.Anchor != "" {
 			corrStart, corrEnd, drift := verifyAndCorrectPosition(newLines, c.Anchor, newStart, newEnd)
 			carried.StartLine = corrStart
 			carried.EndLine = corrEnd
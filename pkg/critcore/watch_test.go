@@ -1,4 +1,4 @@
-package main
+package critcore
 
 import (
 	"encoding/json"
@@ -1228,3 +1228,81 @@ func TestCarryForwardComments_CodeFileOldSidePreservesPosition(t *testing.T) {
 		t.Errorf("Side should be preserved as %q, got %q", "old", carried.Side)
 	}
 }
+
+// TestWatchFileMtimes_DetectsRenameSave verifies that an editor-style save
+// (write temp file, rename over the original) is picked up via fsnotify well
+// before the fallback poll interval.
+func TestWatchFileMtimes_DetectsRenameSave(t *testing.T) {
+	fw := newFSWatcher()
+	if fw == nil {
+		t.Skip("fsnotify unavailable")
+	}
+	fw.close()
+	dir := t.TempDir()
+	mdPath := filepath.Join(dir, "plan.md")
+	content := "# Plan\n"
+	writeFile(t, mdPath, content)
+
+	s := &Session{
+		Mode:        "files",
+		RepoRoot:    dir,
+		ReviewRound: 1,
+		Files: []*FileEntry{
+			{
+				Path:     "plan.md",
+				AbsPath:  mdPath,
+				Status:   "modified",
+				FileType: "markdown",
+				Content:  content,
+				FileHash: fileHash([]byte(content)),
+				Comments: []Comment{},
+			},
+		},
+		subscribers:   make(map[chan SSEEvent]struct{}),
+		roundComplete: make(chan struct{}, 1),
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.watchFileMtimes(stop)
+	time.Sleep(200 * time.Millisecond) // let watcher register
+
+	// Two rapid saves via rename; the final content must win.
+	for _, body := range []string{"# Plan\n\nfirst\n", "# Plan\n\nsecond\n"} {
+		tmp := filepath.Join(dir, ".plan.md.swp")
+		writeFile(t, tmp, body)
+		if err := os.Rename(tmp, mdPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(fsPollFallback - time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.RLock()
+		got := s.Files[0].Content
+		s.mu.RUnlock()
+		if got == "# Plan\n\nsecond\n" {
+			if s.GetPendingEdits() == 0 {
+				t.Error("expected pending edits after rename save")
+			}
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("rename save not detected before fallback poll")
+}
+
+// TestFSWatcher_NilIsPollingFallback verifies a nil watcher is safe to use and
+// selects the 1s polling interval.
+func TestFSWatcher_NilIsPollingFallback(t *testing.T) {
+	var fw *fsWatcher
+	fw.add("/tmp/x")
+	fw.addDir("/tmp")
+	fw.close()
+	if fw.events() != nil || fw.errors() != nil {
+		t.Error("nil watcher should return nil channels")
+	}
+	if got := fw.pollInterval(); got != time.Second {
+		t.Errorf("pollInterval = %v, want 1s", got)
+	}
+}
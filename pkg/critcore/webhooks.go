@@ -0,0 +1,144 @@
+package critcore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Webhooks mirror review activity into other systems: the daemon POSTs each
+// hookEvent as JSON to the configured URLs, signed with the webhook's secret
+// the way GitHub signs its deliveries. Failed deliveries are retried with
+// backoff in the background; deliveries still pending when the daemon exits
+// are dropped, and deliveries of different events may arrive out of order.
+
+// webhookConfig is one entry of the webhooks config list (global-only).
+type webhookConfig struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"` // HMAC-SHA256 key for X-Crit-Signature-256; "" sends unsigned
+	Events []string `json:"events"` // event names to deliver; empty delivers all
+}
+
+// webhookEvents are the events a webhook can subscribe to.
+var webhookEvents = []string{hookCommentCreated, hookCommentUpdated, hookCommentResolved, hookRoundComplete, hookFinish}
+
+// webhookRetryDelays are the waits before each retry of a failed delivery.
+var webhookRetryDelays = []time.Duration{2 * time.Second, 10 * time.Second, time.Minute}
+
+const webhookTimeout = 10 * time.Second
+
+// validateWebhooks checks the webhooks config list.
+func validateWebhooks(hooks []webhookConfig) error {
+	for i, h := range hooks {
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhooks[%d]: invalid url %q (want an http or https URL)", i, h.URL)
+		}
+		for _, event := range h.Events {
+			if !slices.Contains(webhookEvents, event) {
+				return fmt.Errorf("webhooks[%d]: unknown event %q (want one of %v)", i, event, webhookEvents)
+			}
+		}
+	}
+	return nil
+}
+
+// wants reports whether the webhook subscribes to event.
+func (h webhookConfig) wants(event string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, event)
+}
+
+// resolveEvent names the event for setting a comment's resolved flag.
+func resolveEvent(resolved bool) string {
+	if resolved {
+		return hookCommentResolved
+	}
+	return hookCommentUpdated
+}
+
+// webhookSignature returns the X-Crit-Signature-256 value for body.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhooks delivers ev to each subscribed webhook in the background.
+func (s *Server) sendWebhooks(ev hookEvent) {
+	var targets []webhookConfig
+	for _, h := range s.webhooks {
+		if h.wants(ev.Event) {
+			targets = append(targets, h)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		slog.Error("webhook: encoding event", "event", ev.Event, "err", err)
+		return
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	for _, h := range targets {
+		go func() {
+			if err := deliverWebhook(client, h, ev.Event, body, webhookRetryDelays); err != nil {
+				slog.Warn("webhook delivery failed", "event", ev.Event, "url", h.URL, "err", err)
+			}
+		}()
+	}
+}
+
+// deliverWebhook POSTs body to h, retrying after each of delays when the
+// request fails, the receiver errors (5xx) or rate-limits (429). Every
+// attempt carries the same X-Crit-Delivery ID so receivers can deduplicate.
+func deliverWebhook(client *http.Client, h webhookConfig, event string, body []byte, delays []time.Duration) error {
+	delivery := randomID("wh_")
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		status, err := postWebhook(client, h, event, delivery, body)
+		switch {
+		case err != nil:
+			lastErr = err
+		case status < 300:
+			return nil
+		case status >= 500 || status == http.StatusTooManyRequests:
+			lastErr = fmt.Errorf("receiver answered %d", status)
+		default:
+			return fmt.Errorf("receiver answered %d", status)
+		}
+		if attempt >= len(delays) {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt+1, lastErr)
+		}
+		slog.Debug("webhook: retrying", "event", event, "url", h.URL, "err", lastErr, "in", delays[attempt])
+		time.Sleep(delays[attempt])
+	}
+}
+
+func postWebhook(client *http.Client, h webhookConfig, event, delivery string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "crit/"+version)
+	req.Header.Set("X-Crit-Event", event)
+	req.Header.Set("X-Crit-Delivery", delivery)
+	if h.Secret != "" {
+		req.Header.Set("X-Crit-Signature-256", webhookSignature(h.Secret, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,141 @@
+package critcore
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValidateWebhooks(t *testing.T) {
+	ok := []webhookConfig{{URL: "https://hooks.example.com/crit", Events: []string{hookFinish, hookCommentResolved}}, {URL: "http://localhost:9000"}}
+	if err := validateWebhooks(ok); err != nil {
+		t.Errorf("valid webhooks: %v", err)
+	}
+	for _, bad := range []webhookConfig{{URL: "hooks.example.com"}, {URL: "ftp://example.com"}, {URL: "https://example.com", Events: []string{"pushed"}}} {
+		if err := validateWebhooks([]webhookConfig{bad}); err == nil {
+			t.Errorf("validateWebhooks(%+v) should fail", bad)
+		}
+	}
+}
+
+func TestDeliverWebhook_SignsAndRetries(t *testing.T) {
+	var attempts atomic.Int32
+	var deliveries sync.Map
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get("X-Crit-Signature-256"), webhookSignature("s3cret", body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		if r.Header.Get("X-Crit-Event") != hookFinish {
+			t.Errorf("event header = %q", r.Header.Get("X-Crit-Event"))
+		}
+		deliveries.Store(r.Header.Get("X-Crit-Delivery"), true)
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer receiver.Close()
+
+	h := webhookConfig{URL: receiver.URL, Secret: "s3cret"}
+	delays := []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond}
+	if err := deliverWebhook(receiver.Client(), h, hookFinish, []byte(`{"event":"finish"}`), delays); err != nil {
+		t.Fatal(err)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("attempts = %d, want 3", n)
+	}
+	ids := 0
+	deliveries.Range(func(any, any) bool { ids++; return true })
+	if ids != 1 {
+		t.Errorf("retries used %d delivery IDs, want 1", ids)
+	}
+}
+
+func TestDeliverWebhook_GivesUp(t *testing.T) {
+	var attempts atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if r.Header.Get("X-Crit-Signature-256") != "" {
+			t.Error("unsigned webhook should not send a signature")
+		}
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	delays := []time.Duration{time.Millisecond}
+	if err := deliverWebhook(receiver.Client(), webhookConfig{URL: receiver.URL}, hookFinish, []byte(`{}`), delays); err == nil {
+		t.Error("expected an error after exhausting retries")
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("attempts = %d, want 2", n)
+	}
+	attempts.Store(0)
+	if err := deliverWebhook(receiver.Client(), webhookConfig{URL: receiver.URL + "/gone"}, hookFinish, []byte(`{}`), delays); err == nil {
+		t.Error("expected an error for a 404")
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("a 4xx should not be retried, attempts = %d", n)
+	}
+}
+
+func TestWebhooks_CommentEvents(t *testing.T) {
+	events := make(chan hookEvent, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev hookEvent
+		json.NewDecoder(r.Body).Decode(&ev)
+		events <- ev
+	}))
+	defer receiver.Close()
+
+	s, _ := newTestServer(t)
+	s.webhooks = []webhookConfig{{URL: receiver.URL, Events: []string{hookCommentCreated, hookCommentResolved}}}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/api/file/comments?path=test.md", strings.NewReader(`{"start_line":1,"end_line":1,"body":"Typo"}`)))
+	var c Comment
+	json.Unmarshal(w.Body.Bytes(), &c)
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/api/comment/"+c.ID+"?path=test.md", strings.NewReader(`{"body":"Typo here"}`)))
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/api/comment/"+c.ID+"/resolve?path=test.md", strings.NewReader(`{"resolved":true}`)))
+
+	var got []string
+	for range 2 {
+		select {
+		case ev := <-events:
+			if ev.Comment == nil || ev.Comment.ID != c.ID || ev.Path != "test.md" || ev.Time == "" {
+				t.Errorf("event = %+v", ev)
+			}
+			got = append(got, ev.Event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only got %v", got)
+		}
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []string{hookCommentCreated, hookCommentResolved}) {
+		t.Errorf("events = %v, want created and resolved (update filtered out)", got)
+	}
+	select {
+	case ev := <-events:
+		t.Errorf("unsubscribed event delivered: %s", ev.Event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMergeConfigs_WebhooksGlobalOnly(t *testing.T) {
+	global := Config{Webhooks: []webhookConfig{{URL: "https://mine.example.com"}}}
+	project := Config{Webhooks: []webhookConfig{{URL: "https://evil.example.com"}}}
+	merged := mergeConfigs(global, project, configPresence{})
+	if len(merged.Webhooks) != 1 || merged.Webhooks[0].URL != "https://mine.example.com" {
+		t.Errorf("webhooks = %+v, project config must not override them", merged.Webhooks)
+	}
+}
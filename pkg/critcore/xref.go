@@ -0,0 +1,176 @@
+package critcore
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// A comment or reply can point at another comment with #<id> ("same issue as
+// #c_1a2b3c applies here"). References are resolved whenever comments are
+// handed out (API, review file, exports, prompts): each comment lists the
+// comments it references and the ones referencing it, with their locations,
+// so an agent can follow multi-part feedback without searching for IDs.
+
+// Carried-forward comments get new IDs each round, so once a round's
+// comments are all carried, rewriteCarriedRefsLocked points every #<id> at
+// the new ID.
+
+// commentRefPattern matches #<id> for file comment ("c_1a2b3c", or legacy
+// "c3") and review comment ("r_1a2b3c") IDs. The # must not follow a word
+// character, & or /, so URL fragments and HTML entities are left alone.
+var commentRefPattern = regexp.MustCompile(`(^|[^\w&/#])#([cr]_?[0-9a-z]+)\b`)
+
+// commentLink locates a comment on either end of a reference.
+type commentLink struct {
+	ID        string `json:"id"`
+	Path      string `json:"path,omitempty"` // "" for review-level comments
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+}
+
+// label is the link's location as the review output prints it: "review",
+// "auth.go file" or "auth.go L12-L14".
+func (l commentLink) label() string {
+	if l.Path == "" {
+		return "review"
+	}
+	return l.Path + " " + compactLocation(Comment{StartLine: l.StartLine, EndLine: l.EndLine})
+}
+
+// commentRefIDs returns the distinct IDs referenced in text, in order.
+func commentRefIDs(text string) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, m := range commentRefPattern.FindAllStringSubmatch(text, -1) {
+		if id := m[2]; !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// commentLinks holds the resolved references between a session's comments.
+type commentLinks struct {
+	refs      map[string][]commentLink
+	backlinks map[string][]commentLink
+}
+
+// commentLinksLocked resolves the references in every comment body and reply.
+// IDs that don't name a comment in the session, and self-references, are
+// ignored. Callers hold s.mu.
+func (s *Session) commentLinksLocked() commentLinks {
+	type located struct {
+		link commentLink
+		c    Comment
+	}
+	var all []located
+	for _, c := range s.reviewComments {
+		all = append(all, located{commentLink{ID: c.ID}, c})
+	}
+	for _, f := range s.Files {
+		for _, c := range f.Comments {
+			link := commentLink{ID: c.ID, Path: f.Path}
+			if c.Scope != "file" {
+				link.StartLine, link.EndLine = c.StartLine, c.EndLine
+			}
+			all = append(all, located{link, c})
+		}
+	}
+	byID := make(map[string]commentLink, len(all))
+	for _, e := range all {
+		byID[e.link.ID] = e.link
+	}
+
+	l := commentLinks{refs: map[string][]commentLink{}, backlinks: map[string][]commentLink{}}
+	for _, e := range all {
+		text := e.c.Body
+		for _, r := range e.c.Replies {
+			text += "\n" + r.Body
+		}
+		for _, id := range commentRefIDs(text) {
+			target, ok := byID[id]
+			if !ok || id == e.link.ID {
+				continue
+			}
+			l.refs[e.link.ID] = append(l.refs[e.link.ID], target)
+			l.backlinks[id] = append(l.backlinks[id], e.link)
+		}
+	}
+	return l
+}
+
+// apply sets Refs and Backlinks on comments, replacing whatever they held.
+func (l commentLinks) apply(comments []Comment) {
+	for i := range comments {
+		comments[i].Refs = l.refs[comments[i].ID]
+		comments[i].Backlinks = l.backlinks[comments[i].ID]
+	}
+}
+
+// rewriteCarriedRefsLocked replaces the IDs of comments carried forward this
+// round with their new ones in every body and reply, then forgets them.
+// Callers hold s.mu for writing.
+func (s *Session) rewriteCarriedRefsLocked() {
+	ids := s.carriedIDs
+	s.carriedIDs = nil
+	if len(ids) == 0 {
+		return
+	}
+	for i := range s.reviewComments {
+		renameCommentRefs(&s.reviewComments[i], ids)
+	}
+	for _, f := range s.Files {
+		for i := range f.Comments {
+			renameCommentRefs(&f.Comments[i], ids)
+		}
+	}
+}
+
+// renameCommentRefs rewrites the #<id> references in c's body and replies
+// through ids. Replies are copied before they change, since carried comments
+// share them with the previous round's.
+func renameCommentRefs(c *Comment, ids map[string]string) {
+	rename := func(text string) string {
+		return commentRefPattern.ReplaceAllStringFunc(text, func(m string) string {
+			i := strings.IndexByte(m, '#')
+			if id, ok := ids[m[i+1:]]; ok {
+				return m[:i+1] + id
+			}
+			return m
+		})
+	}
+	c.Body = rename(c.Body)
+	var replies []Reply
+	for i, r := range c.Replies {
+		body := rename(r.Body)
+		if body == r.Body {
+			continue
+		}
+		if replies == nil {
+			replies = slices.Clone(c.Replies)
+		}
+		replies[i].Body = body
+	}
+	if replies != nil {
+		c.Replies = replies
+	}
+}
+
+// expandCommentRefs appends the location to each resolved #<id> in text,
+// e.g. "#c_1a2b3c (auth.go L12)", for output read as plain text.
+func expandCommentRefs(text string, refs []commentLink) string {
+	if len(refs) == 0 {
+		return text
+	}
+	return commentRefPattern.ReplaceAllStringFunc(text, func(m string) string {
+		id := m[strings.IndexByte(m, '#')+1:]
+		for _, r := range refs {
+			if r.ID == id {
+				return m + " (" + r.label() + ")"
+			}
+		}
+		return m
+	})
+}
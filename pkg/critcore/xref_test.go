@@ -0,0 +1,103 @@
+package critcore
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCommentRefIDs(t *testing.T) {
+	got := commentRefIDs("same as #c_1a2b3c and #c3, see #r_00ff11 (#c3 again)\n#c_9")
+	if want := "c_1a2b3c,c3,r_00ff11,c_9"; strings.Join(got, ",") != want {
+		t.Errorf("ids = %v, want %s", got, want)
+	}
+	for _, text := range []string{"page#c_1a2b3c", "&#c3;", "https://x.test/#c3", "##c3", "#rp_d7e2a0"} {
+		if ids := commentRefIDs(text); len(ids) > 0 {
+			t.Errorf("commentRefIDs(%q) = %v, want none", text, ids)
+		}
+	}
+}
+
+func TestCommentLinks(t *testing.T) {
+	_, session := newTestServer(t)
+	target, _ := session.AddComment("test.md", 2, 3, "", "root cause", "", "")
+	ref, _ := session.AddComment("test.md", 1, 1, "", "same issue as #"+target.ID+", not #c_ffffff", "", "")
+	session.UpdateComment("test.md", ref.ID, ref.Body+" or #"+ref.ID)
+	r := session.AddReviewComment("overall", "")
+	session.AddReply("test.md", target.ID, "covered by #"+r.ID, "agent")
+
+	byID := map[string]Comment{}
+	for _, c := range session.GetComments("test.md") {
+		byID[c.ID] = c
+	}
+	if refs := byID[ref.ID].Refs; len(refs) != 1 || refs[0] != (commentLink{ID: target.ID, Path: "test.md", StartLine: 2, EndLine: 3}) {
+		t.Errorf("refs = %+v", refs)
+	}
+	if bl := byID[target.ID].Backlinks; len(bl) != 1 || bl[0].ID != ref.ID {
+		t.Errorf("backlinks = %+v", bl)
+	}
+	if refs := byID[target.ID].Refs; len(refs) != 1 || refs[0] != (commentLink{ID: r.ID}) {
+		t.Errorf("reply refs = %+v", refs)
+	}
+	if bl := session.GetReviewComments()[0].Backlinks; len(bl) != 1 || bl[0].ID != target.ID {
+		t.Errorf("review comment backlinks = %+v", bl)
+	}
+
+	session.WriteFiles()
+	data, err := os.ReadFile(session.critJSONPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cj CritJSON
+	if err := json.Unmarshal(data, &cj); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cj.Files["test.md"].Comments {
+		if c.ID == ref.ID && len(c.Refs) != 1 {
+			t.Errorf("review file refs = %+v", c.Refs)
+		}
+	}
+
+	session.DeleteComment("test.md", target.ID)
+	if c := session.GetComments("test.md")[0]; len(c.Refs) != 0 {
+		t.Errorf("refs to a deleted comment should be dropped: %+v", c.Refs)
+	}
+}
+
+func TestExpandCommentRefs(t *testing.T) {
+	refs := []commentLink{{ID: "c_1a2b3c", Path: "auth.go", StartLine: 12, EndLine: 14}, {ID: "r_00ff11"}, {ID: "c_abcdef", Path: "README.md"}}
+	got := expandCommentRefs("see #c_1a2b3c, #r_00ff11, #c_abcdef and #c_999999", refs)
+	want := "see #c_1a2b3c (auth.go L12-L14), #r_00ff11 (review), #c_abcdef (README.md file) and #c_999999"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestCommentLinks_FollowCarryForward(t *testing.T) {
+	s := newTestSession(t)
+	target, _ := s.AddComment("plan.md", 1, 1, "", "root cause", "", "")
+	ref, _ := s.AddComment("main.go", 1, 1, "", "same issue as #"+target.ID, "", "")
+	s.AddReply("main.go", ref.ID, "still #"+target.ID, "agent")
+	s.AddReviewComment("see #"+ref.ID, "")
+	s.WriteFiles()
+
+	s.SignalRoundComplete()
+	s.handleRoundCompleteFiles()
+
+	carried := s.GetComments("plan.md")
+	comments := s.GetComments("main.go")
+	if len(carried) != 1 || len(comments) != 1 || carried[0].ID == target.ID {
+		t.Fatalf("carried plan.md = %+v, main.go = %+v", carried, comments)
+	}
+	c := comments[0]
+	if c.Body != "same issue as #"+carried[0].ID || c.Replies[0].Body != "still #"+carried[0].ID {
+		t.Errorf("body %q, reply %q: want references to %s", c.Body, c.Replies[0].Body, carried[0].ID)
+	}
+	if len(c.Refs) != 1 || c.Refs[0].ID != carried[0].ID {
+		t.Errorf("refs = %+v", c.Refs)
+	}
+	if rc := s.GetReviewComments(); len(rc) != 1 || rc[0].Body != "see #"+c.ID || len(rc[0].Refs) != 1 {
+		t.Errorf("review comment = %+v", rc)
+	}
+}
@@ -0,0 +1,134 @@
+// Package sharecrypt encrypts crit shares.
+//
+// Encrypted shares (`crit share --encrypt`, or share_encrypt in config) keep
+// the share service from reading what is shared. The usual payload — files,
+// comments and round — is sealed with AES-256-GCM under a fresh random key
+// and sent as {"encrypted": ..., "encryption": "aes-256-gcm"}; only the
+// review round and, on updates, the delete token stay readable. The key is
+// added to the share link's fragment (#key=...), which browsers never send to
+// the server, so the viewer decrypts the page in the browser. Comments
+// reviewers leave on an encrypted share come back from the service sealed
+// the same way, as {"encrypted": ...}. The link in the review file carries the
+// key, so later `crit share` runs keep updating the share encrypted.
+package sharecrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+)
+
+// Encryption names the cipher in encrypted share payloads.
+const Encryption = "aes-256-gcm"
+
+// keyParam is the share link fragment parameter holding the key.
+const keyParam = "key"
+
+// NewKey returns a random AES-256 key.
+func NewKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// WithKey returns link with key in its fragment.
+func WithKey(link string, key []byte) string {
+	v := url.Values{keyParam: {base64.RawURLEncoding.EncodeToString(key)}}
+	return link + "#" + v.Encode()
+}
+
+// LinkKey returns the key in a share link's fragment, or nil for a
+// share that isn't encrypted.
+func LinkKey(link string) ([]byte, error) {
+	u, err := url.Parse(link)
+	if err != nil || u.Fragment == "" {
+		return nil, err
+	}
+	values, err := url.ParseQuery(u.Fragment)
+	if err != nil || !values.Has(keyParam) {
+		return nil, err
+	}
+	key, err := base64.RawURLEncoding.DecodeString(values.Get(keyParam))
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("invalid key in share link")
+	}
+	return key, nil
+}
+
+// ReviewAPI returns the API URL of the review a share link points at,
+// e.g. https://crit.md/api/reviews/abc123, and the link's key.
+func ReviewAPI(link string) (string, []byte, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid share URL: %w", err)
+	}
+	key, err := LinkKey(link)
+	if err != nil {
+		return "", nil, err
+	}
+	return u.Scheme + "://" + u.Host + "/api/reviews/" + path.Base(u.Path), key, nil
+}
+
+func newCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts v's JSON under key, returning base64 of the nonce
+// followed by the ciphertext.
+func Seal(key []byte, v any) (string, error) {
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// Open decrypts sealed, from Seal, into v.
+func Open(key []byte, sealed string, v any) error {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return err
+	}
+	aead, err := newCipher(key)
+	if err != nil {
+		return err
+	}
+	if len(data) < aead.NonceSize() {
+		return errors.New("encrypted data is too short")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return errors.New("could not decrypt: wrong key or corrupted data")
+	}
+	return json.Unmarshal(plain, v)
+}
+
+// EncryptPayload seals payload, the JSON body of a share upload, under key.
+// The review round stays readable beside it.
+func EncryptPayload(key []byte, payload map[string]any) (map[string]any, error) {
+	sealed, err := Seal(key, payload)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting share: %w", err)
+	}
+	return map[string]any{
+		"encrypted":    sealed,
+		"encryption":   Encryption,
+		"review_round": payload["review_round"],
+	}, nil
+}
@@ -0,0 +1,40 @@
+package sharecrypt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSeal_RoundTrip(t *testing.T) {
+	key := NewKey()
+	sealed, err := Seal(key, map[string]string{"body": "secret plan"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "secret") {
+		t.Error("sealed data contains the plaintext")
+	}
+	var got map[string]string
+	if err := Open(key, sealed, &got); err != nil || got["body"] != "secret plan" {
+		t.Fatalf("Open = %v, %v", got, err)
+	}
+	if err := Open(NewKey(), sealed, &got); err == nil {
+		t.Error("opening with another key should fail")
+	}
+}
+
+func TestLinkKey(t *testing.T) {
+	key := NewKey()
+	link := WithKey("https://crit.md/r/abc123", key)
+	got, err := LinkKey(link)
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("LinkKey(%q) = %x, %v", link, got, err)
+	}
+	if got, err := LinkKey("https://crit.md/r/abc123"); got != nil || err != nil {
+		t.Errorf("plain link: %x, %v", got, err)
+	}
+	if _, err := LinkKey("https://crit.md/r/abc123#key=short"); err == nil {
+		t.Error("a bad key should fail")
+	}
+}